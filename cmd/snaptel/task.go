@@ -34,8 +34,8 @@ import (
 	"time"
 
 	"github.com/intelsdi-x/snap/mgmt/rest/client"
+	"github.com/intelsdi-x/snap/pkg/schedule"
 	"github.com/intelsdi-x/snap/scheduler/wmap"
-	"github.com/robfig/cron"
 	"github.com/urfave/cli"
 	"golang.org/x/crypto/ssh/terminal"
 
@@ -275,7 +275,7 @@ func (t *task) setScheduleFromCliOptions(ctx *cli.Context) error {
 		_, err := time.ParseDuration(interval)
 		if err != nil {
 			// if that didn't work, then try parsing the interval as cron job entry
-			_, e := cron.Parse(interval)
+			_, e := schedule.ParseCronEntry(interval)
 			if e != nil {
 				return fmt.Errorf("Usage error (bad interval value): cannot parse interval value '%v' either as a duration or a cron entry", interval)
 			}
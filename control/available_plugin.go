@@ -36,6 +36,7 @@ import (
 	"github.com/intelsdi-x/snap/control/plugin/client"
 	"github.com/intelsdi-x/snap/control/strategy"
 	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/cdata"
 	"github.com/intelsdi-x/snap/core/control_event"
 	"github.com/intelsdi-x/snap/core/ctypes"
 	"github.com/intelsdi-x/snap/core/serror"
@@ -427,7 +428,7 @@ func (ap *availablePlugins) getPool(key string) (strategy.Pool, serror.SnapError
 	return pool, nil
 }
 
-func (ap *availablePlugins) collectMetrics(pluginKey string, metricTypes []core.Metric, taskID string) ([]core.Metric, error) {
+func (ap *availablePlugins) collectMetrics(pluginKey string, metricTypes []core.Metric, taskID string, window *core.CollectWindow) ([]core.Metric, error) {
 	var results []core.Metric
 	pool, serr := ap.getPool(pluginKey)
 	if serr != nil {
@@ -443,7 +444,12 @@ func (ap *availablePlugins) collectMetrics(pluginKey string, metricTypes []core.
 		return nil, errors.New("Plugin strategy not set")
 	}
 
-	metricsToCollect, metricsFromCache := pool.CheckCache(metricTypes, taskID)
+	// the metrics of a window of a backfill are not the current ones, they
+	// are neither taken from the cache nor cached
+	metricsToCollect, metricsFromCache := metricTypes, []core.Metric(nil)
+	if window == nil {
+		metricsToCollect, metricsFromCache = pool.CheckCache(metricTypes, taskID)
+	}
 
 	if len(metricsToCollect) == 0 {
 		return metricsFromCache, nil
@@ -468,13 +474,21 @@ func (ap *availablePlugins) collectMetrics(pluginKey string, metricTypes []core.
 		return nil, serror.New(errors.New("unable to cast client to PluginCollectorClient"))
 	}
 
+	// the plugin is selected on the config of the metrics, so that the
+	// windows of a backfill are collected by the same plugin
+	if window != nil {
+		metricsToCollect = windowedMetrics(metricsToCollect, *window)
+	}
+
 	// collect metrics
 	metrics, err := cli.CollectMetrics(metricsToCollect)
 	if err != nil {
 		return nil, serror.New(err)
 	}
 
-	pool.UpdateCache(metrics, taskID)
+	if window == nil {
+		pool.UpdateCache(metrics, taskID)
+	}
 
 	results = make([]core.Metric, len(metricsFromCache)+len(metrics))
 	idx := 0
@@ -494,6 +508,29 @@ func (ap *availablePlugins) collectMetrics(pluginKey string, metricTypes []core.
 	return results, nil
 }
 
+// windowedMetrics returns copies of the metric types whose config holds the
+// start and the end of the window of a backfill they are collected for
+func windowedMetrics(mts []core.Metric, window core.CollectWindow) []core.Metric {
+	windowed := make([]core.Metric, len(mts))
+	for i, mt := range mts {
+		m, ok := mt.(*metricType)
+		if !ok {
+			windowed[i] = mt
+			continue
+		}
+		cfg := cdata.NewNode()
+		cfg.AddItem(core.BackfillWindowStartConfig, ctypes.ConfigValueStr{Value: window.Start.Format(time.RFC3339Nano)})
+		cfg.AddItem(core.BackfillWindowEndConfig, ctypes.ConfigValueStr{Value: window.End.Format(time.RFC3339Nano)})
+		if m.config != nil {
+			cfg = cfg.ReverseMerge(m.config)
+		}
+		c := *m
+		c.config = cfg
+		windowed[i] = &c
+	}
+	return windowed
+}
+
 func (ap *availablePlugins) streamMetrics(
 	pluginKey string,
 	metricTypes []core.Metric,
@@ -25,11 +25,14 @@ import (
 	"errors"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/intelsdi-x/snap/control/fixtures"
 	"github.com/intelsdi-x/snap/control/plugin"
 	"github.com/intelsdi-x/snap/control/plugin/client"
 	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -118,3 +121,24 @@ func TestAvailablePlugins(t *testing.T) {
 		So(err, ShouldNotBeNil)
 	})
 }
+
+func TestWindowedMetrics(t *testing.T) {
+	Convey("windowedMetrics", t, func() {
+		cfg := cdata.NewNode()
+		cfg.AddItem("region", ctypes.ConfigValueStr{Value: "eu"})
+		mt := &metricType{namespace: core.NewNamespace("intel", "mock", "foo"), config: cfg}
+		end := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+		windowed := windowedMetrics([]core.Metric{mt}, core.CollectWindow{Start: end.Add(-time.Hour), End: end})
+		Convey("passes the window in the config of copies of the metrics", func() {
+			So(windowed, ShouldHaveLength, 1)
+			table := windowed[0].Config().Table()
+			So(table[core.BackfillWindowStartConfig], ShouldResemble, ctypes.ConfigValueStr{Value: "2017-03-01T11:00:00Z"})
+			So(table[core.BackfillWindowEndConfig], ShouldResemble, ctypes.ConfigValueStr{Value: "2017-03-01T12:00:00Z"})
+			So(table["region"], ShouldResemble, ctypes.ConfigValueStr{Value: "eu"})
+			So(windowed[0].Namespace(), ShouldResemble, mt.Namespace())
+		})
+		Convey("leaves the metrics of the subscription as they are", func() {
+			So(mt.Config().Table(), ShouldHaveLength, 1)
+		})
+	})
+}
@@ -20,6 +20,7 @@ limitations under the License.
 package control
 
 import (
+	"context"
 	"crypto/sha256"
 	"errors"
 	"fmt"
@@ -28,6 +29,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -982,10 +984,43 @@ func (p *pluginControl) MetricExists(mns core.Namespace, ver int) bool {
 	return false
 }
 
+// SubscribedMetricTypes returns the metric types the requested metrics of the
+// subscription group resolved to, with their wildcards expanded, ordered by
+// namespace and version. The returned slice is a copy.
+func (p *pluginControl) SubscribedMetricTypes(id string) ([]core.Metric, error) {
+	pluginToMetricMap, _, err := p.subscriptionGroups.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	mts := metricsByNamespace{}
+	for _, m := range pluginToMetricMap {
+		mts = append(mts, m.Metrics()...)
+	}
+	sort.Sort(mts)
+	return mts, nil
+}
+
 // CollectMetrics is a blocking call to collector plugins returning a collection
 // of metrics and errors.  If an error is encountered no metrics will be
 // returned.
 func (p *pluginControl) CollectMetrics(id string, allTags map[string]map[string]string) (metrics []core.Metric, errs []error) {
+	return p.collectMetrics(context.Background(), id, allTags, nil)
+}
+
+// CollectMetricsDue collects the metrics of the subscription group which are
+// due, only calling the plugins of these metrics
+func (p *pluginControl) CollectMetricsDue(id string, allTags map[string]map[string]string, due func(core.Namespace) bool) ([]core.Metric, []error) {
+	return p.collectMetrics(context.Background(), id, allTags, due)
+}
+
+// CollectMetricsContext collects the metrics of the subscription group like
+// CollectMetricsDue, all of them when due is nil, and stops waiting on the
+// plugins once the context is done, returning the error of the context
+func (p *pluginControl) CollectMetricsContext(ctx context.Context, id string, allTags map[string]map[string]string, due func(core.Namespace) bool) ([]core.Metric, []error) {
+	return p.collectMetrics(ctx, id, allTags, due)
+}
+
+func (p *pluginControl) collectMetrics(ctx context.Context, id string, allTags map[string]map[string]string, due func(core.Namespace) bool) (metrics []core.Metric, errs []error) {
 	// If control is not started we don't want tasks to be able to
 	// go through a workflow.
 	if !p.Started {
@@ -1026,11 +1061,29 @@ func (p *pluginControl) CollectMetrics(id string, allTags map[string]map[string]
 	cMetrics := make(chan []core.Metric)
 	cError := make(chan error)
 	var wg sync.WaitGroup
+	// a collection of a backfill passes its window to the plugins
+	var window *core.CollectWindow
+	if w, ok := core.CollectWindowFromContext(ctx); ok {
+		window = &w
+	}
 
 	// For each available plugin call available plugin using RPC client and wait for response (goroutines)
 	for pluginKey, pmt := range pluginToMetricMap {
+		mts := pmt.metricTypes
+		if due != nil {
+			mts = make([]core.Metric, 0, len(pmt.metricTypes))
+			for _, mt := range pmt.metricTypes {
+				if due(mt.Namespace()) {
+					mts = append(mts, mt)
+				}
+			}
+			// the plugin is not called when none of its metrics are due
+			if len(mts) == 0 {
+				continue
+			}
+		}
 		// merge global plugin config into the config for the metric
-		for _, mt := range pmt.metricTypes {
+		for _, mt := range mts {
 			if mt.Config() != nil {
 				mt.Config().ReverseMergeInPlace(p.Config.Plugins.getPluginConfigDataNode(core.CollectorPluginType, pmt.plugin.Name(), pmt.plugin.Version()))
 			}
@@ -1039,15 +1092,21 @@ func (p *pluginControl) CollectMetrics(id string, allTags map[string]map[string]
 		wg.Add(1)
 
 		go func(pluginKey string, mt []core.Metric) {
-			mts, err := p.pluginRunner.AvailablePlugins().collectMetrics(pluginKey, mt, id)
+			mts, err := p.pluginRunner.AvailablePlugins().collectMetrics(pluginKey, mt, id, window)
 			if err != nil {
 				cError <- err
 			} else {
 				cMetrics <- mts
 			}
-		}(pluginKey, pmt.metricTypes)
+		}(pluginKey, mts)
 	}
 
+	// the plugins report to their own slices, which are only read once
+	// every plugin answered, as the collection may be given up before
+	var (
+		collected   []core.Metric
+		collectErrs []error
+	)
 	go func() {
 		for m := range cMetrics {
 			// Reapply standard tags after collection as a precaution.  It is common for
@@ -1056,21 +1115,36 @@ func (p *pluginControl) CollectMetrics(id string, allTags map[string]map[string]
 			for i := range m {
 				m[i] = p.pluginManager.AddStandardAndWorkflowTags(m[i], allTags)
 			}
-			metrics = append(metrics, m...)
+			collected = append(collected, m...)
 			wg.Done()
 		}
 	}()
 
 	go func() {
 		for e := range cError {
-			errs = append(errs, e)
+			collectErrs = append(collectErrs, e)
 			wg.Done()
 		}
 	}()
 
-	wg.Wait()
-	close(cMetrics)
-	close(cError)
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(cMetrics)
+		close(cError)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		controlLogger.WithFields(log.Fields{
+			"_block":                "CollectorMetrics",
+			"subscription-group-id": id,
+		}).Warn("collection given up")
+		return nil, append(errs, ctx.Err())
+	}
+	metrics = append(metrics, collected...)
+	errs = append(errs, collectErrs...)
 
 	if len(errs) > 0 {
 		return nil, errs
@@ -1226,6 +1300,18 @@ func (mts metricTypes) Plugin() core.CatalogedPlugin {
 	return mts.plugin
 }
 
+// metricsByNamespace orders metrics by namespace and version
+type metricsByNamespace []core.Metric
+
+func (m metricsByNamespace) Len() int      { return len(m) }
+func (m metricsByNamespace) Swap(i, j int) { m[i], m[j] = m[j], m[i] }
+func (m metricsByNamespace) Less(i, j int) bool {
+	if ni, nj := m[i].Namespace().String(), m[j].Namespace().String(); ni != nj {
+		return ni < nj
+	}
+	return m[i].Version() < m[j].Version()
+}
+
 func containsPlugin(slice []core.SubscribedPlugin, lookup subscribedPlugin) bool {
 	for _, plugin := range slice {
 		if plugin.Name() == lookup.Name() &&
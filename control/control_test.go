@@ -923,12 +923,24 @@ func TestMetricConfig(t *testing.T) {
 			errs := c.subscriptionGroups.validateMetric(m1)
 			So(errs, ShouldBeNil)
 		})
+		Convey("So errors should name the metric whose config is invalid", func() {
+			m1.Cfg = cdata.NewNode()
+			m1.Cfg.AddItem("password", ctypes.ConfigValueInt{Value: 1})
+			errs := c.subscriptionGroups.validateMetric(m1)
+			So(errs, ShouldNotBeEmpty)
+			So(errs[0].Fields()["metric"], ShouldResemble, m1.Namespace())
+			So(errs[0].Fields()["plugin"], ShouldEqual, "mock")
+			So(errs[0].Fields()[core.TaskErrorCodeField], ShouldEqual, core.TaskErrorConfigPolicy)
+			So(errs[0].Fields()[core.TaskErrorNamespaceField], ShouldEqual, m1.Namespace().String())
+		})
 		Convey("So metric should not be valid if does not occur in the catalog", func() {
 			m := fixtures.MockMetricType{
 				Namespace_: core.NewNamespace("intel", "mock", "bad"),
 			}
 			errs := c.subscriptionGroups.validateMetric(m)
 			So(errs, ShouldNotBeNil)
+			So(errs[0].Fields()[core.TaskErrorCodeField], ShouldEqual, core.TaskErrorMetricNotFound)
+			So(errs[0].Fields()[core.TaskErrorNamespaceField], ShouldEqual, "/intel/mock/bad")
 		})
 
 		c.Stop()
@@ -1314,6 +1326,14 @@ func TestFailedPlugin(t *testing.T) {
 		serrs := c.SubscribeDeps(taskID, r, cps, cdt)
 		So(serrs, ShouldBeNil)
 
+		// the task is subscribed to the metric it requested
+		subscribed, err := c.SubscribedMetricTypes(taskID)
+		So(err, ShouldBeNil)
+		So(len(subscribed), ShouldEqual, 1)
+		So(subscribed[0].Namespace().String(), ShouldEqual, "/intel/mock/foo")
+		_, err = c.SubscribedMetricTypes("unknown")
+		So(err, ShouldNotBeNil)
+
 		// retrieve loaded plugin
 		lp, err := c.pluginManager.get("collector" + core.Separator + "mock" + core.Separator + "2")
 		So(err, ShouldBeNil)
@@ -89,9 +89,18 @@ func (h *hostnameReaderType) Hostname() (name string) {
 
 func errorMetricNotFound(ns string, ver ...int) error {
 	if len(ver) > 0 {
-		return fmt.Errorf("Metric not found: %s (version: %d)", ns, ver[0])
+		return &core.SubscriptionError{
+			Kind:      core.ErrMetricNotFound,
+			Namespace: ns,
+			Version:   ver[0],
+			Err:       fmt.Errorf("Metric not found: %s (version: %d)", ns, ver[0]),
+		}
+	}
+	return &core.SubscriptionError{
+		Kind:      core.ErrMetricNotFound,
+		Namespace: ns,
+		Err:       fmt.Errorf("Metric not found: %s", ns),
 	}
-	return fmt.Errorf("Metric not found: %s", ns)
 }
 
 func errorMetricsNotFound(ns string, ver ...int) error {
@@ -22,10 +22,12 @@ limitations under the License.
 package control
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/intelsdi-x/snap/control/plugin"
 	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/serror"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -49,6 +51,25 @@ func TestAddTagsFromWorkflow(t *testing.T) {
 	})
 }
 
+func TestErrorMetricNotFound(t *testing.T) {
+	Convey("Given a metric not found error wrapped in a snap error", t, func() {
+		var err error = serror.New(errorMetricNotFound(bar, 2))
+		Convey("it keeps its message", func() {
+			So(err.Error(), ShouldEqual, "Metric not found: /intel/foo/bar (version: 2)")
+		})
+		Convey("it is classified as metric not found", func() {
+			So(errors.Is(err, core.ErrMetricNotFound), ShouldBeTrue)
+			So(errors.Is(err, core.ErrSubscriptionTemporary), ShouldBeFalse)
+		})
+		Convey("it carries the requested namespace and version", func() {
+			var subErr *core.SubscriptionError
+			So(errors.As(err, &subErr), ShouldBeTrue)
+			So(subErr.Namespace, ShouldEqual, bar)
+			So(subErr.Version, ShouldEqual, 2)
+		})
+	})
+}
+
 func TestContainsTuplePositive(t *testing.T) {
 	Convey("when tuple contains two items", t, func() {
 		dut := "(host0;host1)"
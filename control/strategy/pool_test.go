@@ -216,6 +216,46 @@ func TestPoolEligibility(t *testing.T) {
 	})
 }
 
+func TestPoolSubscriptions(t *testing.T) {
+	Convey("Given a pool subscribed to by two tasks", t, func() {
+		plg := NewMockAvailablePlugin()
+		pool, _ := NewPool(plg.String(), plg)
+		pool.Subscribe("task-1")
+		pool.Subscribe("task-2")
+		So(pool.SubscriptionCount(), ShouldEqual, 2)
+
+		Convey("When a task subscribes again", func() {
+			pool.Subscribe("task-1")
+			Convey("Then its subscription is counted once", func() {
+				So(pool.SubscriptionCount(), ShouldEqual, 2)
+			})
+		})
+		Convey("When one of the tasks unsubscribes", func() {
+			pool.Unsubscribe("task-1")
+			Convey("Then the other task is still subscribed", func() {
+				So(pool.SubscriptionCount(), ShouldEqual, 1)
+			})
+			Convey("Then unsubscribing the same task again has no effect", func() {
+				pool.Unsubscribe("task-1")
+				So(pool.SubscriptionCount(), ShouldEqual, 1)
+			})
+		})
+		Convey("When a task that never subscribed unsubscribes", func() {
+			pool.Unsubscribe("task-3")
+			Convey("Then no subscription is released", func() {
+				So(pool.SubscriptionCount(), ShouldEqual, 2)
+			})
+		})
+		Convey("When both tasks unsubscribe", func() {
+			pool.Unsubscribe("task-1")
+			pool.Unsubscribe("task-2")
+			Convey("Then the pool has no subscriptions left", func() {
+				So(pool.SubscriptionCount(), ShouldEqual, 0)
+			})
+		})
+	})
+}
+
 func TestPoolSelectAPDefaultRouter(t *testing.T) {
 	Convey("For plugin defined with default strategy", t, func() {
 		plugin := NewMockAvailablePlugin().WithStrategy(plugin.DefaultRouting)
@@ -231,8 +271,8 @@ func TestPoolSelectAPDefaultRouter(t *testing.T) {
 
 func TestPoolSelectAPConfigRouter(t *testing.T) {
 	Convey("Given task id and configuration", t, func() {
-		cfg := map[string]ctypes.ConfigValue{"foo": ctypes.ConfigValueStr{"bar"}}
-		otherCfg := map[string]ctypes.ConfigValue{"foo": ctypes.ConfigValueStr{"baz"}}
+		cfg := map[string]ctypes.ConfigValue{"foo": ctypes.ConfigValueStr{Value: "bar"}}
+		otherCfg := map[string]ctypes.ConfigValue{"foo": ctypes.ConfigValueStr{Value: "baz"}}
 
 		Convey("When plugin is defined with config based strategy", func() {
 			plugin := NewMockAvailablePlugin().WithStrategy(plugin.ConfigRouting)
@@ -277,7 +317,7 @@ func TestPoolSelectAPStickyRouter(t *testing.T) {
 			So(ap1, ShouldNotBeNil)
 			So(err, ShouldBeNil)
 
-			cfg := map[string]ctypes.ConfigValue{"foo": ctypes.ConfigValueStr{"bar"}}
+			cfg := map[string]ctypes.ConfigValue{"foo": ctypes.ConfigValueStr{Value: "bar"}}
 			ap2, err := pool.SelectAP("TaskID", cfg)
 			So(ap2, ShouldNotBeNil)
 			So(err, ShouldBeNil)
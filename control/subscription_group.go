@@ -245,10 +245,11 @@ func (s *subscriptionGroups) ValidateDeps(requested []core.RequestedMetric,
 		mergedConfig := plg.Config().ReverseMerge(
 			s.Config.Plugins.getPluginConfigDataNode(
 				typ, plg.Name(), plg.Version()))
+		// keep validating the remaining plugins so that every violation
+		// is returned at once
 		errs := s.validatePluginSubscription(plg, mergedConfig)
 		if len(errs) > 0 {
 			serrs = append(serrs, errs...)
-			return serrs
 		}
 	}
 	return
@@ -286,7 +287,11 @@ func (p *subscriptionGroups) validatePluginSubscription(pl core.SubscribedPlugin
 		if errs != nil && errs.HasErrors() {
 			for _, e := range errs.Errors() {
 				se := serror.New(e)
-				se.SetFields(map[string]interface{}{"name": pl.Name(), "version": pl.Version()})
+				se.SetFields(map[string]interface{}{
+					"name":                  pl.Name(),
+					"version":               pl.Version(),
+					core.TaskErrorCodeField: core.TaskErrorConfigPolicy,
+				})
 				serrs = append(serrs, se)
 			}
 		}
@@ -299,8 +304,10 @@ func (s *subscriptionGroups) validateMetric(
 	mts, err := s.metricCatalog.GetMetrics(metric.Namespace(), metric.Version())
 	if err != nil {
 		serrs = append(serrs, serror.New(err, map[string]interface{}{
-			"name":    metric.Namespace().String(),
-			"version": metric.Version(),
+			"name":                       metric.Namespace().String(),
+			"version":                    metric.Version(),
+			core.TaskErrorCodeField:      core.TaskErrorMetricNotFound,
+			core.TaskErrorNamespaceField: metric.Namespace().String(),
 		}))
 		return serrs
 	}
@@ -309,9 +316,13 @@ func (s *subscriptionGroups) validateMetric(
 		// No metric found return error.
 		if m == nil {
 			serrs = append(
-				serrs, serror.New(
-					fmt.Errorf("no metric found cannot subscribe: (%s) version(%d)",
-						metric.Namespace(), metric.Version())))
+				serrs, serror.New(&core.SubscriptionError{
+					Kind:      core.ErrMetricNotFound,
+					Namespace: metric.Namespace().String(),
+					Version:   metric.Version(),
+					Err: fmt.Errorf("no metric found cannot subscribe: (%s) version(%d)",
+						metric.Namespace(), metric.Version()),
+				}))
 			continue
 		}
 
@@ -319,7 +330,7 @@ func (s *subscriptionGroups) validateMetric(
 
 		typ, serr := core.ToPluginType(m.Plugin.TypeName())
 		if serr != nil {
-			serrs = append(serrs, serror.New(err))
+			serrs = append(serrs, serror.New(serr))
 			continue
 		}
 
@@ -337,19 +348,21 @@ func (s *subscriptionGroups) validateMetric(
 		// If no rules are defined for a metric, we set the metric's policy to an empty ConfigPolicyNode.
 		// Checking m.policy for nil will not work, we need to check if rules are nil.
 		if m.policy.HasRules() {
+			fields := log.Fields{
+				"metric":                     m.Namespace(),
+				"version":                    m.Version(),
+				"plugin":                     m.Plugin.Name(),
+				core.TaskErrorCodeField:      core.TaskErrorConfigPolicy,
+				core.TaskErrorNamespaceField: m.Namespace().String(),
+			}
 			if m.Config() == nil {
-				fields := log.Fields{
-					"metric":  m.Namespace(),
-					"version": m.Version(),
-					"plugin":  m.Plugin.Name(),
-				}
 				serrs = append(serrs, serror.New(ErrConfigRequiredForMetric, fields))
 				continue
 			}
 			ncdTable, errs := m.policy.Process(m.Config().Table())
 			if errs != nil && errs.HasErrors() {
 				for _, e := range errs.Errors() {
-					serrs = append(serrs, serror.New(e))
+					serrs = append(serrs, serror.New(e, fields))
 				}
 				continue
 			}
@@ -513,19 +526,19 @@ func (s *subscriptionGroup) subscribePlugins(id string,
 			// this is a remote plugin
 			pool, err := s.pluginRunner.AvailablePlugins().getOrCreatePool(plg.Key())
 			if err != nil {
-				serrs = append(serrs, serror.New(err))
+				serrs = append(serrs, pluginSubscriptionTemporaryError(plg, err))
 				return serrs
 			}
 			if pool.Count() < 1 {
 				var resp plugin.Response
 				res, err := http.Get(plg.Details.Uri.String())
 				if err != nil {
-					serrs = append(serrs, serror.New(err))
+					serrs = append(serrs, pluginSubscriptionTemporaryError(plg, err))
 					return serrs
 				}
 				body, err := ioutil.ReadAll(res.Body)
 				if err != nil {
-					serrs = append(serrs, serror.New(err))
+					serrs = append(serrs, pluginSubscriptionTemporaryError(plg, err))
 					return serrs
 				}
 				err = json.Unmarshal(body, &resp)
@@ -535,20 +548,20 @@ func (s *subscriptionGroup) subscribePlugins(id string,
 				}
 				ap, err := newAvailablePlugin(resp, s.eventManager, nil, s.grpcSecurity)
 				if err != nil {
-					serrs = append(serrs, serror.New(err))
+					serrs = append(serrs, pluginSubscriptionTemporaryError(plg, err))
 					return serrs
 				}
 				ap.SetIsRemote(true)
 				err = pool.Insert(ap)
 				if err != nil {
-					serrs = append(serrs, serror.New(err))
+					serrs = append(serrs, pluginSubscriptionTemporaryError(plg, err))
 					return serrs
 				}
 			}
 		} else {
 			pool, err := s.pluginRunner.AvailablePlugins().getOrCreatePool(plg.Key())
 			if err != nil {
-				serrs = append(serrs, serror.New(err))
+				serrs = append(serrs, pluginSubscriptionTemporaryError(plg, err))
 				return serrs
 			}
 			pool.Subscribe(id)
@@ -560,7 +573,7 @@ func (s *subscriptionGroup) subscribePlugins(id string,
 				}
 				err = s.pluginRunner.runPlugin(plg.Name(), plg.Details)
 				if err != nil {
-					serrs = append(serrs, serror.New(err))
+					serrs = append(serrs, pluginSubscriptionTemporaryError(plg, err))
 					return serrs
 				}
 			}
@@ -674,6 +687,20 @@ func comparePlugins(newPlugins,
 
 func pluginNotFoundError(pl core.SubscribedPlugin) serror.SnapError {
 	se := serror.New(fmt.Errorf("Plugin not found: type(%s) name(%s) version(%d)", pl.TypeName(), pl.Name(), pl.Version()))
+	se.SetFields(map[string]interface{}{
+		"name":                  pl.Name(),
+		"version":               pl.Version(),
+		"type":                  pl.TypeName(),
+		core.TaskErrorCodeField: core.TaskErrorPluginNotFound,
+	})
+	return se
+}
+
+func pluginSubscriptionTemporaryError(pl core.Plugin, err error) serror.SnapError {
+	se := serror.New(&core.SubscriptionError{
+		Kind: core.ErrSubscriptionTemporary,
+		Err:  err,
+	})
 	se.SetFields(map[string]interface{}{
 		"name":    pl.Name(),
 		"version": pl.Version(),
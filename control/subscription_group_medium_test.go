@@ -485,6 +485,22 @@ func TestSubscriptionGroups_ProcessSpecifiedDynamicPositive(t *testing.T) {
 			So(sg, ShouldNotBeNil)
 			serrs := sg.ValidateDeps([]core.RequestedMetric{requested}, []core.SubscribedPlugin{mock1}, cdata.NewTree())
 			So(serrs, ShouldBeNil)
+			Convey("Every plugin which fails validation is reported", func() {
+				missing1 := mockSubscribedPlugin{
+					typeName: core.ProcessorPluginType,
+					name:     "missing-processor",
+					version:  1,
+					config:   cdata.NewNode(),
+				}
+				missing2 := mockSubscribedPlugin{
+					typeName: core.PublisherPluginType,
+					name:     "missing-publisher",
+					version:  1,
+					config:   cdata.NewNode(),
+				}
+				serrs := sg.ValidateDeps([]core.RequestedMetric{requested}, []core.SubscribedPlugin{missing1, missing2}, cdata.NewTree())
+				So(len(serrs), ShouldEqual, 2)
+			})
 			Convey("Subscription group created for requested metric with specified instance of dynamic element and with wildcards", func() {
 				sg.Add("task-id", []core.RequestedMetric{requested}, cdata.NewTree(), []core.SubscribedPlugin{})
 				<-lpe.sub
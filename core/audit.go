@@ -0,0 +1,104 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// AuditOperation is a task management operation recorded in the audit log
+type AuditOperation string
+
+const (
+	// AuditCreateTask records the creation of a task
+	AuditCreateTask AuditOperation = "create-task"
+	// AuditUpdateTask records the update of the schedule or the workflow of a task
+	AuditUpdateTask AuditOperation = "update-task"
+	// AuditRemoveTask records the removal of a task
+	AuditRemoveTask AuditOperation = "remove-task"
+	// AuditStartTask records the start of a task
+	AuditStartTask AuditOperation = "start-task"
+	// AuditStopTask records the stop of a task
+	AuditStopTask AuditOperation = "stop-task"
+)
+
+// AuditEntry is a task management operation recorded in the audit log: who
+// asked for it and when, the task it applied to, the fields of the manifest
+// of the task it changed and its error if it failed
+type AuditEntry struct {
+	Time      time.Time      `json:"time"`
+	Caller    string         `json:"caller,omitempty"`
+	Source    string         `json:"source"`
+	Operation AuditOperation `json:"operation"`
+	TaskID    string         `json:"task_id,omitempty"`
+	Changes   []AuditChange  `json:"changes,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// AuditChange is a field of the manifest of a task changed by an operation,
+// as in "workflow.collect.config./intel/mock.password". A field added by
+// the operation has no value before it, a field removed no value after it.
+// Secret config values are redacted.
+type AuditChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// AuditFilter selects the entries of the audit log. Its empty fields select
+// every entry.
+type AuditFilter struct {
+	TaskID    string
+	Caller    string
+	Operation AuditOperation
+	// Since selects the entries recorded at or after it
+	Since time.Time
+}
+
+// Matches returns whether the entry is selected by the filter
+func (f AuditFilter) Matches(e AuditEntry) bool {
+	if f.TaskID != "" && e.TaskID != f.TaskID {
+		return false
+	}
+	if f.Caller != "" && e.Caller != f.Caller {
+		return false
+	}
+	if f.Operation != "" && e.Operation != f.Operation {
+		return false
+	}
+	return f.Since.IsZero() || !e.Time.Before(f.Since)
+}
+
+type callerKey struct{}
+
+// WithCaller returns a copy of the context carrying the identity of the
+// caller of the task management operations it is passed to, recorded in the
+// audit log
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext returns the identity of the caller carried by the
+// context, empty if there is none
+func CallerFromContext(ctx context.Context) string {
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller
+}
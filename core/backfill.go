@@ -0,0 +1,54 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// The config items a collector collecting a window of a backfill is passed,
+// the RFC 3339 times the window starts and ends at, the end excluded
+const (
+	BackfillWindowStartConfig = "backfill_window_start"
+	BackfillWindowEndConfig   = "backfill_window_end"
+)
+
+// CollectWindow is the past time range the metrics of a collection of a
+// backfill task are collected for
+type CollectWindow struct {
+	Start time.Time
+	End   time.Time
+}
+
+type collectWindowKey struct{}
+
+// WithCollectWindow returns a copy of the context carrying the window of the
+// collection it is passed to
+func WithCollectWindow(ctx context.Context, w CollectWindow) context.Context {
+	return context.WithValue(ctx, collectWindowKey{}, w)
+}
+
+// CollectWindowFromContext returns the window carried by the context, false
+// if the collection is not one of a backfill
+func CollectWindowFromContext(ctx context.Context) (CollectWindow, bool) {
+	w, ok := ctx.Value(collectWindowKey{}).(CollectWindow)
+	return w, ok
+}
@@ -22,14 +22,24 @@ package cdata
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
+	"io"
+	"strings"
 
 	"github.com/intelsdi-x/snap/pkg/ctree"
 )
 
+var (
+	// ErrConfigTreeRootMismatch - The error message for merging config data trees whose namespaces do not share their first element
+	ErrConfigTreeRootMismatch = errors.New("Config data trees with different root namespaces cannot be merged")
+)
+
 // Allows adding of config data by namespace and retrieving of data from tree
 // at a specific namespace (merging the relevant hierarchy). Uses pkg.ConfigTree.
 type ConfigDataTree struct {
 	cTree *ctree.ConfigTree
+	// base holds the config applied beneath the config of every namespace
+	base *ConfigDataNode
 }
 
 // Returns a new ConfigDataTree.
@@ -45,13 +55,31 @@ func (c *ConfigDataTree) GobEncode() ([]byte, error) {
 	if err := encoder.Encode(c.cTree); err != nil {
 		return nil, err
 	}
+	// the base follows the tree, so that a tree encoded without a base
+	// decodes the same
+	if c.base != nil {
+		if err := encoder.Encode(c.base); err != nil {
+			return nil, err
+		}
+	}
 	return w.Bytes(), nil
 }
 
 func (c *ConfigDataTree) GobDecode(buf []byte) error {
 	r := bytes.NewBuffer(buf)
 	decoder := gob.NewDecoder(r)
-	return decoder.Decode(&c.cTree)
+	if err := decoder.Decode(&c.cTree); err != nil {
+		return err
+	}
+	base := NewNode()
+	if err := decoder.Decode(base); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	c.base = base
+	return nil
 }
 
 // Adds a ConfigDataNode at the provided namespace.
@@ -60,16 +88,72 @@ func (c *ConfigDataTree) Add(ns []string, cdn *ConfigDataNode) {
 }
 
 // Returns a ConfigDataNode that is a merged version of the namespace provided.
+// The base of the tree, if any, is merged beneath it.
 func (c *ConfigDataTree) Get(ns []string) *ConfigDataNode {
-	n := c.cTree.Get(ns)
+	n := toConfigDataNode(c.cTree.Get(ns))
+	if c.base == nil {
+		return n
+	}
 	if n == nil {
+		return NewNode().ReverseMerge(c.base)
+	}
+	return n.ReverseMerge(c.base)
+}
+
+// SetBase sets the config applied beneath the config of every namespace of
+// the tree, the config of a namespace overriding the base.
+func (c *ConfigDataTree) SetBase(n *ConfigDataNode) {
+	c.base = n
+}
+
+// Base returns the config applied beneath the config of every namespace, or
+// nil if the tree has no base.
+func (c *ConfigDataTree) Base() *ConfigDataNode {
+	return c.base
+}
+
+// Merge adds the config of the other tree to this tree. Where both trees have
+// config at the same namespace, or both have a base, the items of the other
+// tree override the conflicting items of this tree. The namespaces of both
+// trees must share their first element, else ErrConfigTreeRootMismatch is
+// returned and this tree is left unchanged.
+func (c *ConfigDataTree) Merge(other *ConfigDataTree) error {
+	if other == nil {
 		return nil
 	}
+	if root, otherRoot := c.cTree.Root(), other.cTree.Root(); root != "" && otherRoot != "" && root != otherRoot {
+		return ErrConfigTreeRootMismatch
+	}
+	existing := map[string]*ConfigDataNode{}
+	for _, kn := range c.cTree.GetAll() {
+		existing[strings.Join(kn.Key, "/")] = toConfigDataNode(kn.Node)
+	}
+	for _, kn := range other.cTree.GetAll() {
+		n := toConfigDataNode(kn.Node)
+		if e, ok := existing[strings.Join(kn.Key, "/")]; ok {
+			n = n.ReverseMerge(e)
+		} else {
+			n = NewNode().ReverseMerge(n)
+		}
+		c.cTree.Add(kn.Key, n)
+	}
+	if other.base != nil {
+		if c.base == nil {
+			c.base = NewNode().ReverseMerge(other.base)
+		} else {
+			c.base = other.base.ReverseMerge(c.base)
+		}
+	}
+	return nil
+}
+
+func toConfigDataNode(n interface{}) *ConfigDataNode {
 	switch t := n.(type) {
+	case nil:
+		return nil
 	case ConfigDataNode:
 		return &t
 	default:
 		return t.(*ConfigDataNode)
-
 	}
 }
@@ -123,5 +123,110 @@ func TestConfigDataTree(t *testing.T) {
 
 		})
 
+		Convey("base", func() {
+			cdt := NewTree()
+			base := NewNode()
+			base.AddItem("user", ctypes.ConfigValueStr{Value: "root"})
+			base.AddItem("port", ctypes.ConfigValueInt{Value: 8080})
+			cdt.SetBase(base)
+			So(cdt.Base(), ShouldEqual, base)
+
+			Convey("is returned for a namespace without config", func() {
+				a := cdt.Get([]string{"intel", "mock", "foo"})
+				So(a, ShouldNotBeNil)
+				So(a.Table()["user"], ShouldResemble, ctypes.ConfigValueStr{Value: "root"})
+				So(a, ShouldNotEqual, base)
+			})
+			Convey("is overridden by the config of a namespace", func() {
+				cd := NewNode()
+				cd.AddItem("port", ctypes.ConfigValueInt{Value: 9090})
+				cdt.Add([]string{"intel", "mock"}, cd)
+				t := cdt.Get([]string{"intel", "mock", "foo"}).Table()
+				So(t["user"], ShouldResemble, ctypes.ConfigValueStr{Value: "root"})
+				So(t["port"], ShouldResemble, ctypes.ConfigValueInt{Value: 9090})
+				// the base itself is left unchanged
+				So(base.Table()["port"], ShouldResemble, ctypes.ConfigValueInt{Value: 8080})
+			})
+			Convey("is encoded & decoded", func() {
+				gob.Register(&ConfigDataNode{})
+				gob.Register(ctypes.ConfigValueStr{})
+				gob.Register(ctypes.ConfigValueInt{})
+				cdt.Add([]string{"intel"}, NewNode())
+				buf, err := cdt.GobEncode()
+				So(err, ShouldBeNil)
+				cdt2 := NewTree()
+				So(cdt2.GobDecode(buf), ShouldBeNil)
+				So(cdt2.Base(), ShouldNotBeNil)
+				So(cdt2.Base().Table()["port"], ShouldResemble, ctypes.ConfigValueInt{Value: 8080})
+			})
+		})
+
+		Convey("merge", func() {
+			cd1 := NewNode()
+			cd1.AddItem("user", ctypes.ConfigValueStr{Value: "root"})
+			cd1.AddItem("port", ctypes.ConfigValueInt{Value: 8080})
+			cd2 := NewNode()
+			cd2.AddItem("port", ctypes.ConfigValueInt{Value: 9090})
+			cd2.AddItem("debug", ctypes.ConfigValueBool{Value: true})
+
+			Convey("of empty trees leaves an empty tree", func() {
+				cdt := NewTree()
+				So(cdt.Merge(NewTree()), ShouldBeNil)
+				So(cdt.Get([]string{"intel"}), ShouldBeNil)
+				So(cdt.Merge(nil), ShouldBeNil)
+			})
+			Convey("into an empty tree copies the other tree", func() {
+				other := NewTree()
+				other.Add([]string{"intel", "mock"}, cd1)
+				cdt := NewTree()
+				So(cdt.Merge(other), ShouldBeNil)
+				So(cdt.Get([]string{"intel", "mock"}).Table(), ShouldResemble, cd1.Table())
+			})
+			Convey("of an empty tree leaves the tree unchanged", func() {
+				cdt := NewTree()
+				cdt.Add([]string{"intel", "mock"}, cd1)
+				So(cdt.Merge(NewTree()), ShouldBeNil)
+				So(cdt.Get([]string{"intel", "mock"}).Table(), ShouldResemble, cd1.Table())
+			})
+			Convey("overrides the conflicting items at the same namespace", func() {
+				cdt := NewTree()
+				cdt.Add([]string{"intel", "mock"}, cd1)
+				other := NewTree()
+				other.Add([]string{"intel", "mock"}, cd2)
+				So(cdt.Merge(other), ShouldBeNil)
+				t := cdt.Get([]string{"intel", "mock"}).Table()
+				So(t["user"], ShouldResemble, ctypes.ConfigValueStr{Value: "root"})
+				So(t["port"], ShouldResemble, ctypes.ConfigValueInt{Value: 9090})
+				So(t["debug"], ShouldResemble, ctypes.ConfigValueBool{Value: true})
+			})
+			Convey("keeps the more specific namespace winning", func() {
+				cdt := NewTree()
+				cdt.Add([]string{"intel", "mock", "foo"}, cd1)
+				other := NewTree()
+				other.Add([]string{"intel"}, cd2)
+				So(cdt.Merge(other), ShouldBeNil)
+				t := cdt.Get([]string{"intel", "mock", "foo"}).Table()
+				So(t["port"], ShouldResemble, ctypes.ConfigValueInt{Value: 8080})
+				So(t["debug"], ShouldResemble, ctypes.ConfigValueBool{Value: true})
+			})
+			Convey("merges the bases", func() {
+				cdt := NewTree()
+				cdt.SetBase(cd1)
+				other := NewTree()
+				other.SetBase(cd2)
+				So(cdt.Merge(other), ShouldBeNil)
+				t := cdt.Base().Table()
+				So(t["user"], ShouldResemble, ctypes.ConfigValueStr{Value: "root"})
+				So(t["port"], ShouldResemble, ctypes.ConfigValueInt{Value: 9090})
+			})
+			Convey("returns an error for different roots", func() {
+				cdt := NewTree()
+				cdt.Add([]string{"intel", "mock"}, cd1)
+				other := NewTree()
+				other.Add([]string{"acme", "mock"}, cd2)
+				So(cdt.Merge(other), ShouldEqual, ErrConfigTreeRootMismatch)
+				So(cdt.Get([]string{"intel", "mock"}).Table(), ShouldResemble, cd1.Table())
+			})
+		})
 	})
 }
@@ -41,6 +41,9 @@ func (c ConfigValueInt) MarshalJSON() ([]byte, error) {
 
 type ConfigValueStr struct {
 	Value string
+	// Secret marks a value such as a password, which is redacted from the
+	// tasks shown and encrypted when a task is saved or exported
+	Secret bool
 }
 
 func (c ConfigValueStr) Type() string {
@@ -0,0 +1,207 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ctypes
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// SecretRedacted is the value a secret config value is shown as
+const SecretRedacted = "********"
+
+const (
+	// secretField holds the plain value of a secret in a task manifest,
+	// as in {"secret": "password"}
+	secretField = "secret"
+	// encryptedField holds the encrypted value of a secret in a task
+	// manifest, as in {"encrypted": "..."}
+	encryptedField = "encrypted"
+)
+
+var (
+	// ErrSecretKeyNotSet - The error message for a secret config value encrypted or decrypted without a key provider
+	ErrSecretKeyNotSet = errors.New("No key provider is set to encrypt secret config values")
+	// ErrInvalidSecretKey - The error message for a secret key which is not an AES-128, AES-192 or AES-256 key
+	ErrInvalidSecretKey = errors.New("Secret key must be 16, 24 or 32 bytes long")
+	// ErrInvalidSecret - The error message for a secret config value which cannot be decoded or decrypted
+	ErrInvalidSecret = errors.New("Invalid secret config value")
+)
+
+// SecretKeyProvider provides the key secret config values are encrypted with
+type SecretKeyProvider interface {
+	SecretKey() ([]byte, error)
+}
+
+// StaticSecretKey is a SecretKeyProvider providing always the same key
+type StaticSecretKey []byte
+
+// SecretKey returns the key
+func (k StaticSecretKey) SecretKey() ([]byte, error) {
+	return []byte(k), nil
+}
+
+// FileSecretKeyProvider is a SecretKeyProvider reading the key, hex encoded,
+// from a file. The file is read each time the key is needed.
+type FileSecretKeyProvider struct {
+	path string
+}
+
+// NewFileSecretKeyProvider returns a FileSecretKeyProvider reading the key
+// from the file at path
+func NewFileSecretKeyProvider(path string) *FileSecretKeyProvider {
+	return &FileSecretKeyProvider{path: path}
+}
+
+// SecretKey returns the key held in the file
+func (f *FileSecretKeyProvider) SecretKey() ([]byte, error) {
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(strings.TrimSpace(string(data)))
+}
+
+var (
+	secretKeyMutex    sync.RWMutex
+	secretKeyProvider SecretKeyProvider
+)
+
+// SetSecretKeyProvider sets the provider of the key secret config values
+// are encrypted with. A nil provider leaves secrets unable to be encrypted.
+func SetSecretKeyProvider(p SecretKeyProvider) {
+	secretKeyMutex.Lock()
+	defer secretKeyMutex.Unlock()
+	secretKeyProvider = p
+}
+
+// NewSecret returns a secret string config value
+func NewSecret(value string) ConfigValueStr {
+	return ConfigValueStr{Value: value, Secret: true}
+}
+
+func secretCipher() (cipher.AEAD, error) {
+	secretKeyMutex.RLock()
+	p := secretKeyProvider
+	secretKeyMutex.RUnlock()
+	if p == nil {
+		return nil, ErrSecretKeyNotSet
+	}
+	key, err := p.SecretKey()
+	if err != nil {
+		return nil, err
+	}
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, ErrInvalidSecretKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptSecret encrypts a secret value with AES-GCM under the key of the
+// key provider, returning it base64 encoded
+func EncryptSecret(value string) (string, error) {
+	aead, err := secretCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(value), nil)), nil
+}
+
+// DecryptSecret decrypts a secret value encrypted by EncryptSecret
+func DecryptSecret(encrypted string) (string, error) {
+	aead, err := secretCipher()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", ErrInvalidSecret
+	}
+	value, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", ErrInvalidSecret
+	}
+	return string(value), nil
+}
+
+// IsSecret returns whether a config value decoded from a task manifest is a
+// secret, {"secret": value} or {"encrypted": value}
+func IsSecret(v interface{}) bool {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return false
+	}
+	_, plain := m[secretField]
+	_, encrypted := m[encryptedField]
+	return plain || encrypted
+}
+
+// SecretFromManifest returns the secret config value of a value decoded
+// from a task manifest, decrypting it if it is encrypted
+func SecretFromManifest(v interface{}) (ConfigValueStr, error) {
+	if !IsSecret(v) {
+		return ConfigValueStr{}, ErrInvalidSecret
+	}
+	m := v.(map[string]interface{})
+	if s, ok := m[secretField]; ok {
+		value, ok := s.(string)
+		if !ok {
+			return ConfigValueStr{}, ErrInvalidSecret
+		}
+		return NewSecret(value), nil
+	}
+	encrypted, ok := m[encryptedField].(string)
+	if !ok {
+		return ConfigValueStr{}, ErrInvalidSecret
+	}
+	value, err := DecryptSecret(encrypted)
+	if err != nil {
+		return ConfigValueStr{}, err
+	}
+	return NewSecret(value), nil
+}
+
+// EncryptedManifestSecret returns the form a secret value is saved or
+// exported in, {"encrypted": value}
+func EncryptedManifestSecret(value string) (map[string]interface{}, error) {
+	encrypted, err := EncryptSecret(value)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{encryptedField: encrypted}, nil
+}
@@ -22,6 +22,8 @@ package core
 import (
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/intelsdi-x/snap/pkg/schedule"
@@ -32,19 +34,156 @@ import (
 // swagger:model Schedule
 type Schedule struct {
 	// required: true
-	// enum: simple, windowed, streaming, cron
+	// enum: simple, windowed, streaming, cron, once, dependent, backfill, or a type registered
+	// with RegisterScheduleType
 	Type string `json:"type"`
 	// required: true
 	Interval       string     `json:"interval"`
 	StartTimestamp *time.Time `json:"start_timestamp,omitempty"`
 	StopTimestamp  *time.Time `json:"stop_timestamp,omitempty"`
 	Count          uint       `json:"count,omitempty"`
+	// upper bound of a random delay added to each firing of a simple or windowed
+	// schedule, a duration or a percentage of the interval as "10%"
+	Jitter string `json:"jitter,omitempty"`
+	// seed of the jitter, the delays are random when not provided
+	JitterSeed *int64 `json:"jitter_seed,omitempty"`
+	// point in time the firings of a simple or windowed schedule are aligned to
+	AlignTimestamp *time.Time `json:"align_timestamp,omitempty"`
+	// time of day, as "15:04", from which a simple or windowed schedule fires
+	DailyStart string `json:"daily_start,omitempty"`
+	// time of day, as "15:04", until which a simple or windowed schedule fires
+	DailyEnd string `json:"daily_end,omitempty"`
+	// name of the timezone of the daily window, the local timezone when not provided
+	Timezone string `json:"timezone,omitempty"`
+	// length of the windows a backfill schedule iterates over its range in
+	Window string `json:"window,omitempty"`
+	// start of the first window of a backfill schedule not collected yet, the
+	// start of its range when not provided
+	Progress *time.Time `json:"progress,omitempty"`
+	// settings of a schedule of a type registered with RegisterScheduleType
+	Options map[string]interface{} `json:"options,omitempty"`
 }
 
 var (
 	ErrMissingScheduleInterval = errors.New("missing `interval` in configuration of schedule")
+	// ErrMissingBackfillRange - The error message for a backfill schedule without the range it iterates over
+	ErrMissingBackfillRange = errors.New("missing `start_timestamp` or `stop_timestamp` in configuration of backfill schedule")
+	// ErrMissingBackfillWindow - The error message for a backfill schedule without the length of its windows
+	ErrMissingBackfillWindow = errors.New("missing `window` in configuration of backfill schedule")
 )
 
+// FormatDailyTime returns the time of day, as "15:04" or "15:04:05", which is
+// the given duration after midnight
+func FormatDailyTime(d time.Duration) string {
+	t := time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC).Add(d)
+	if d == 24*time.Hour {
+		return "24:00"
+	}
+	if t.Second() != 0 {
+		return t.Format("15:04:05")
+	}
+	return t.Format("15:04")
+}
+
+// parseDailyTime returns the duration after midnight of a time of day given
+// as "15:04" or "15:04:05", "24:00" being the end of the day
+func parseDailyTime(s string) (time.Duration, error) {
+	if s == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		if t, err = time.Parse("15:04:05", s); err != nil {
+			return 0, fmt.Errorf("invalid time of day `%s`", s)
+		}
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second, nil
+}
+
+// setDailyWindow sets the daily window of the schedule to the given times of
+// day in the given timezone
+func setDailyWindow(sch *schedule.WindowedSchedule, start, end, tz string) error {
+	var err error
+	if sch.DailyStart, err = parseDailyTime(start); err != nil {
+		return err
+	}
+	if sch.DailyEnd, err = parseDailyTime(end); err != nil {
+		return err
+	}
+	if tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return err
+		}
+		sch.SetLocation(loc)
+	}
+	return nil
+}
+
+// ScheduleFromSchedule returns the Schedule describing the given schedule so
+// that the schedule of an existing task can be serialized. It returns nil for
+// a schedule of none of the built-in or registered types.
+func ScheduleFromSchedule(s schedule.Schedule) *Schedule {
+	switch v := s.(type) {
+	case *schedule.WindowedSchedule:
+		sch := &Schedule{
+			Type:           "windowed",
+			Interval:       v.Interval.String(),
+			StartTimestamp: v.StartTime,
+			StopTimestamp:  v.StopTime,
+			Count:          v.Count,
+			AlignTimestamp: v.AlignTime,
+		}
+		if v.Jitter > 0 {
+			seed := v.JitterSeed()
+			sch.Jitter = v.Jitter.String()
+			sch.JitterSeed = &seed
+		}
+		if v.HasDailyWindow() {
+			sch.DailyStart = FormatDailyTime(v.DailyStart)
+			sch.DailyEnd = FormatDailyTime(v.DailyEnd)
+			sch.Timezone = v.Location().String()
+		}
+		return sch
+	case *schedule.CronSchedule:
+		return &Schedule{
+			Type:     "cron",
+			Interval: v.Entry(),
+		}
+	case *schedule.RunOnceSchedule:
+		sch := &Schedule{
+			Type: "once",
+		}
+		if !v.At.IsZero() {
+			at := v.At
+			sch.StartTimestamp = &at
+		}
+		return sch
+	case *schedule.StreamingSchedule:
+		return &Schedule{
+			Type: "streaming",
+		}
+	case *schedule.DependentSchedule:
+		return &Schedule{
+			Type: "dependent",
+		}
+	case *schedule.BackfillSchedule:
+		start, end, progress := v.Start, v.End, v.Progress()
+		sch := &Schedule{
+			Type:           "backfill",
+			StartTimestamp: &start,
+			StopTimestamp:  &end,
+			Window:         v.Window.String(),
+			Progress:       &progress,
+		}
+		if v.Interval > 0 {
+			sch.Interval = v.Interval.String()
+		}
+		return sch
+	}
+	return describeRegisteredSchedule(s)
+}
+
 func makeSchedule(s Schedule) (schedule.Schedule, error) {
 	switch s.Type {
 	case "simple", "windowed":
@@ -63,6 +202,25 @@ func makeSchedule(s Schedule) (schedule.Schedule, error) {
 			s.StopTimestamp,
 			s.Count,
 		)
+		sch.AlignTime = s.AlignTimestamp
+
+		if s.Jitter != "" {
+			j, err := parseJitter(s.Jitter, d)
+			if err != nil {
+				return nil, err
+			}
+			seed := time.Now().UnixNano()
+			if s.JitterSeed != nil {
+				seed = *s.JitterSeed
+			}
+			sch.SetJitter(j, seed)
+		}
+
+		if s.DailyStart != "" || s.DailyEnd != "" {
+			if err := setDailyWindow(sch, s.DailyStart, s.DailyEnd, s.Timezone); err != nil {
+				return nil, err
+			}
+		}
 
 		err = sch.Validate()
 		if err != nil {
@@ -75,6 +233,18 @@ func makeSchedule(s Schedule) (schedule.Schedule, error) {
 		}
 		sch := schedule.NewCronSchedule(s.Interval)
 
+		err := sch.Validate()
+		if err != nil {
+			return nil, err
+		}
+		return sch, nil
+	case "once":
+		var at time.Time
+		if s.StartTimestamp != nil {
+			at = *s.StartTimestamp
+		}
+		sch := schedule.NewRunOnceSchedule(at)
+
 		err := sch.Validate()
 		if err != nil {
 			return nil, err
@@ -82,7 +252,57 @@ func makeSchedule(s Schedule) (schedule.Schedule, error) {
 		return sch, nil
 	case "streaming":
 		return schedule.NewStreamingSchedule(), nil
+	case "dependent":
+		return schedule.NewDependentSchedule(), nil
+	case "backfill":
+		if s.StartTimestamp == nil || s.StopTimestamp == nil {
+			return nil, ErrMissingBackfillRange
+		}
+		if s.Window == "" {
+			return nil, ErrMissingBackfillWindow
+		}
+		window, err := time.ParseDuration(s.Window)
+		if err != nil {
+			return nil, err
+		}
+		var interval time.Duration
+		if s.Interval != "" {
+			if interval, err = time.ParseDuration(s.Interval); err != nil {
+				return nil, err
+			}
+		}
+		sch := schedule.NewBackfillSchedule(*s.StartTimestamp, *s.StopTimestamp, window, interval)
+		if s.Progress != nil {
+			sch.SetProgress(*s.Progress)
+		}
+		if err := sch.Validate(); err != nil {
+			return nil, err
+		}
+		return sch, nil
 	default:
+		if t, ok := LookupScheduleType(s.Type); ok {
+			sch, err := t.MakeSchedule(s)
+			if err != nil {
+				return nil, err
+			}
+			if err := sch.Validate(); err != nil {
+				return nil, err
+			}
+			return sch, nil
+		}
 		return nil, fmt.Errorf("unknown schedule type `%s`", s.Type)
 	}
 }
+
+// parseJitter returns the jitter given either as a duration or as a
+// percentage of the interval
+func parseJitter(jitter string, interval time.Duration) (time.Duration, error) {
+	if !strings.HasSuffix(jitter, "%") {
+		return time.ParseDuration(jitter)
+	}
+	pct, err := strconv.ParseFloat(strings.TrimSuffix(jitter, "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid jitter percentage `%s`", jitter)
+	}
+	return time.Duration(float64(interval) * pct / 100), nil
+}
@@ -0,0 +1,119 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/intelsdi-x/snap/pkg/schedule"
+)
+
+var (
+	// ErrScheduleTypeRegistered - The error message for a schedule type registered under the name of a built-in or already registered type
+	ErrScheduleTypeRegistered = errors.New("schedule type is already registered")
+	// ErrInvalidScheduleType - The error message for a schedule type registered without a name or an implementation
+	ErrInvalidScheduleType = errors.New("schedule type must have a name and an implementation")
+)
+
+// builtinScheduleTypes are the types of schedule made by makeSchedule, which
+// cannot be registered
+var builtinScheduleTypes = map[string]struct{}{
+	"simple":    {},
+	"windowed":  {},
+	"cron":      {},
+	"once":      {},
+	"streaming": {},
+	"dependent": {},
+	"backfill":  {},
+}
+
+// ScheduleType makes the schedules of a custom type, such as sunrise/sunset
+// or business hours, from the Schedule of a task manifest and describes them
+// back so that the tasks using them can be shown, saved and exported.
+type ScheduleType interface {
+	// MakeSchedule returns the schedule described by the Schedule of a task
+	// manifest, its settings being in Options
+	MakeSchedule(s Schedule) (schedule.Schedule, error)
+	// DescribeSchedule returns the Schedule describing a schedule, false if
+	// the schedule is not of the type. Its Type is set to the name the type
+	// is registered under.
+	DescribeSchedule(s schedule.Schedule) (*Schedule, bool)
+}
+
+var (
+	scheduleTypesMutex sync.RWMutex
+	scheduleTypes      = map[string]ScheduleType{}
+)
+
+// RegisterScheduleType registers a custom type of schedule under the name
+// the schedules of the task manifests give as their type
+func RegisterScheduleType(name string, t ScheduleType) error {
+	if name == "" || t == nil {
+		return ErrInvalidScheduleType
+	}
+	scheduleTypesMutex.Lock()
+	defer scheduleTypesMutex.Unlock()
+	if _, ok := builtinScheduleTypes[name]; ok {
+		return ErrScheduleTypeRegistered
+	}
+	if _, ok := scheduleTypes[name]; ok {
+		return ErrScheduleTypeRegistered
+	}
+	scheduleTypes[name] = t
+	return nil
+}
+
+// UnregisterScheduleType removes a custom type of schedule registered with
+// RegisterScheduleType
+func UnregisterScheduleType(name string) {
+	scheduleTypesMutex.Lock()
+	defer scheduleTypesMutex.Unlock()
+	delete(scheduleTypes, name)
+}
+
+// LookupScheduleType returns the custom type of schedule registered under
+// the name
+func LookupScheduleType(name string) (ScheduleType, bool) {
+	scheduleTypesMutex.RLock()
+	defer scheduleTypesMutex.RUnlock()
+	t, ok := scheduleTypes[name]
+	return t, ok
+}
+
+// describeRegisteredSchedule returns the Schedule describing a schedule of a
+// custom type, nil if it is of none of the registered types
+func describeRegisteredSchedule(s schedule.Schedule) *Schedule {
+	scheduleTypesMutex.RLock()
+	defer scheduleTypesMutex.RUnlock()
+	names := make([]string, 0, len(scheduleTypes))
+	for name := range scheduleTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if sch, ok := scheduleTypes[name].DescribeSchedule(s); ok {
+			sch.Type = name
+			return sch
+		}
+	}
+	return nil
+}
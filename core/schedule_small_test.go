@@ -26,6 +26,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/intelsdi-x/snap/pkg/schedule"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -83,6 +84,42 @@ func TestMakeSchedule(t *testing.T) {
 		So(rsched.GetState(), ShouldEqual, 0)
 	})
 
+	Convey("Simple schedule with determined align_timestamp", t, func() {
+		alignTime := time.Now().Add(-time.Hour)
+		sched1 := &Schedule{Type: "simple", Interval: "1m", AlignTimestamp: &alignTime}
+		rsched, err := makeSchedule(*sched1)
+		So(err, ShouldBeNil)
+		So(rsched, ShouldNotBeNil)
+		So(rsched.(*schedule.WindowedSchedule).AlignTime, ShouldEqual, &alignTime)
+		So(ScheduleFromSchedule(rsched).AlignTimestamp, ShouldEqual, &alignTime)
+	})
+
+	Convey("Simple schedule with jitter", t, func() {
+		sched1 := &Schedule{Type: "simple", Interval: "10s", Jitter: "2s"}
+		rsched, err := makeSchedule(*sched1)
+		So(err, ShouldBeNil)
+		So(rsched.(*schedule.WindowedSchedule).Jitter, ShouldEqual, 2*time.Second)
+	})
+
+	Convey("Simple schedule with jitter as a percentage of the interval", t, func() {
+		sched1 := &Schedule{Type: "simple", Interval: "10s", Jitter: "25%"}
+		rsched, err := makeSchedule(*sched1)
+		So(err, ShouldBeNil)
+		So(rsched.(*schedule.WindowedSchedule).Jitter, ShouldEqual, 2500*time.Millisecond)
+	})
+
+	Convey("Simple schedule with invalid jitter percentage", t, func() {
+		sched1 := &Schedule{Type: "simple", Interval: "10s", Jitter: "x%"}
+		rsched, err := makeSchedule(*sched1)
+		So(rsched, ShouldBeNil)
+		So(err.Error(), ShouldEqual, "invalid jitter percentage `x%`")
+
+		sched1.Jitter = "-10%"
+		rsched, err = makeSchedule(*sched1)
+		So(rsched, ShouldBeNil)
+		So(err, ShouldEqual, schedule.ErrInvalidJitter)
+	})
+
 	Convey("Windowed schedule with missing interval", t, func() {
 		sched1 := &Schedule{Type: "windowed"}
 		rsched, err := makeSchedule(*sched1)
@@ -219,4 +256,116 @@ func TestMakeSchedule(t *testing.T) {
 		So(err, ShouldNotBeNil)
 		So(err.Error(), ShouldStartWith, "Expected 5 or 6 fields, found ")
 	})
+
+	Convey("Once schedule without start_timestamp", t, func() {
+		sched1 := &Schedule{Type: "once"}
+		rsched, err := makeSchedule(*sched1)
+		So(err, ShouldBeNil)
+		So(rsched, ShouldNotBeNil)
+		So(rsched.GetState(), ShouldEqual, 0)
+	})
+
+	Convey("Once schedule with start_timestamp in the future", t, func() {
+		startTime := time.Now().Add(time.Minute)
+		sched1 := &Schedule{Type: "once", StartTimestamp: &startTime}
+		rsched, err := makeSchedule(*sched1)
+		So(err, ShouldBeNil)
+		So(rsched, ShouldNotBeNil)
+		So(rsched.GetState(), ShouldEqual, 0)
+	})
+
+	Convey("Once schedule with start_timestamp in the past", t, func() {
+		startTime := time.Now().Add(-time.Hour)
+		sched1 := &Schedule{Type: "once", StartTimestamp: &startTime}
+		rsched, err := makeSchedule(*sched1)
+		So(rsched, ShouldBeNil)
+		So(err, ShouldEqual, schedule.ErrRunTimeInPast)
+	})
+	Convey("Backfill schedule without range", t, func() {
+		stopTime := time.Now().Add(-time.Hour)
+		rsched, err := makeSchedule(Schedule{Type: "backfill", Window: "1h", StopTimestamp: &stopTime})
+		So(rsched, ShouldBeNil)
+		So(err, ShouldEqual, ErrMissingBackfillRange)
+	})
+	Convey("Backfill schedule without window", t, func() {
+		stopTime := time.Now().Add(-time.Hour)
+		startTime := stopTime.Add(-time.Hour)
+		rsched, err := makeSchedule(Schedule{Type: "backfill", StartTimestamp: &startTime, StopTimestamp: &stopTime})
+		So(rsched, ShouldBeNil)
+		So(err, ShouldEqual, ErrMissingBackfillWindow)
+	})
+	Convey("Backfill schedule with progress", t, func() {
+		stopTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+		startTime := stopTime.Add(-3 * time.Hour)
+		progress := startTime.Add(time.Hour)
+		sched1 := Schedule{Type: "backfill", Interval: "1s", Window: "1h0m0s", StartTimestamp: &startTime, StopTimestamp: &stopTime, Progress: &progress}
+		rsched, err := makeSchedule(sched1)
+		So(err, ShouldBeNil)
+		b, ok := rsched.(*schedule.BackfillSchedule)
+		So(ok, ShouldBeTrue)
+		So(b.Interval, ShouldEqual, time.Second)
+		from, _, _ := b.CurrentWindow()
+		So(from, ShouldResemble, progress)
+		Convey("is described back with its progress", func() {
+			So(ScheduleFromSchedule(b), ShouldResemble, &sched1)
+		})
+	})
+}
+
+// businessHoursSchedule is a custom type of schedule firing hourly from the
+// time of day it opens at
+type businessHoursSchedule struct {
+	*schedule.WindowedSchedule
+	opens string
+}
+
+type businessHoursScheduleType struct{}
+
+func (businessHoursScheduleType) MakeSchedule(s Schedule) (schedule.Schedule, error) {
+	opens, ok := s.Options["opens"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing `opens` in options of schedule")
+	}
+	return &businessHoursSchedule{WindowedSchedule: schedule.NewWindowedSchedule(time.Hour, nil, nil, 0), opens: opens}, nil
+}
+
+func (businessHoursScheduleType) DescribeSchedule(s schedule.Schedule) (*Schedule, bool) {
+	b, ok := s.(*businessHoursSchedule)
+	if !ok {
+		return nil, false
+	}
+	return &Schedule{Options: map[string]interface{}{"opens": b.opens}}, true
+}
+
+func TestRegisterScheduleType(t *testing.T) {
+	Convey("Registering a custom schedule type", t, func() {
+		So(RegisterScheduleType("business-hours", businessHoursScheduleType{}), ShouldBeNil)
+		defer UnregisterScheduleType("business-hours")
+
+		Convey("makes its schedules from the manifest", func() {
+			rsched, err := makeSchedule(Schedule{Type: "business-hours", Options: map[string]interface{}{"opens": "09:00"}})
+			So(err, ShouldBeNil)
+			So(rsched, ShouldHaveSameTypeAs, &businessHoursSchedule{})
+			Convey("and describes them back", func() {
+				So(ScheduleFromSchedule(rsched), ShouldResemble, &Schedule{Type: "business-hours", Options: map[string]interface{}{"opens": "09:00"}})
+			})
+		})
+		Convey("returns the error of the type for an invalid schedule", func() {
+			rsched, err := makeSchedule(Schedule{Type: "business-hours"})
+			So(rsched, ShouldBeNil)
+			So(err.Error(), ShouldEqual, "missing `opens` in options of schedule")
+		})
+		Convey("fails for a name already registered", func() {
+			So(RegisterScheduleType("business-hours", businessHoursScheduleType{}), ShouldEqual, ErrScheduleTypeRegistered)
+			So(RegisterScheduleType("cron", businessHoursScheduleType{}), ShouldEqual, ErrScheduleTypeRegistered)
+		})
+		Convey("fails without a name", func() {
+			So(RegisterScheduleType("", businessHoursScheduleType{}), ShouldEqual, ErrInvalidScheduleType)
+		})
+	})
+	Convey("Unregistered schedule types are unknown", t, func() {
+		rsched, err := makeSchedule(Schedule{Type: "business-hours"})
+		So(rsched, ShouldBeNil)
+		So(err.Error(), ShouldEqual, "unknown schedule type `business-hours`")
+	})
 }
@@ -20,6 +20,8 @@ limitations under the License.
 package scheduler_event
 
 import (
+	"time"
+
 	"github.com/intelsdi-x/snap/core"
 )
 
@@ -29,10 +31,17 @@ const (
 	TaskDeleted            = "Scheduler.TaskDeleted"
 	TaskStarted            = "Scheduler.TaskStarted"
 	TaskStopped            = "Scheduler.TaskStopped"
+	TaskPaused             = "Scheduler.TaskPaused"
 	TaskEnded              = "Scheduler.TaskEnded"
 	TaskDisabled           = "Scheduler.TaskDisabled"
 	MetricCollected        = "Scheduler.MetricsCollected"
 	MetricCollectionFailed = "Scheduler.MetricCollectionFailed"
+	WorkerRetired          = "Scheduler.WorkerRetired"
+	JobQueueOverflowed     = "Scheduler.JobQueueOverflowed"
+	JobDeadlineMissed      = "Scheduler.JobDeadlineMissed"
+	CircuitBreakerChanged  = "Scheduler.CircuitBreakerChanged"
+	BackfillProgressed     = "Scheduler.BackfillProgressed"
+	IntervalAdjusted       = "Scheduler.IntervalAdjusted"
 )
 
 type PluginsUnsubscribedEvent struct {
@@ -81,6 +90,14 @@ func (e TaskStoppedEvent) Namespace() string {
 	return TaskStopped
 }
 
+type TaskPausedEvent struct {
+	TaskID string
+}
+
+func (e TaskPausedEvent) Namespace() string {
+	return TaskPaused
+}
+
 type TaskEndedEvent struct {
 	TaskID string
 	Source string
@@ -102,6 +119,10 @@ func (e TaskDisabledEvent) Namespace() string {
 type MetricCollectedEvent struct {
 	TaskID  string
 	Metrics []core.Metric
+	// Start and Duration of the collection, zero for the metrics of a
+	// streaming task
+	Start    time.Time
+	Duration time.Duration
 }
 
 func (e MetricCollectedEvent) Namespace() string {
@@ -111,8 +132,76 @@ func (e MetricCollectedEvent) Namespace() string {
 type MetricCollectionFailedEvent struct {
 	TaskID string
 	Errors []error
+	// Start and Duration of the collection
+	Start    time.Time
+	Duration time.Duration
 }
 
 func (e MetricCollectionFailedEvent) Namespace() string {
 	return MetricCollectionFailed
 }
+
+type WorkerRetiredEvent struct {
+	Pool     string
+	WorkerID string
+}
+
+func (e WorkerRetiredEvent) Namespace() string {
+	return WorkerRetired
+}
+
+type JobQueueOverflowedEvent struct {
+	TaskID string
+	Queue  string
+}
+
+func (e JobQueueOverflowedEvent) Namespace() string {
+	return JobQueueOverflowed
+}
+
+type JobDeadlineMissedEvent struct {
+	TaskID string
+	Queue  string
+}
+
+func (e JobDeadlineMissedEvent) Namespace() string {
+	return JobDeadlineMissed
+}
+
+// CircuitBreakerChangedEvent is emitted when the circuit breaker of a
+// namespace prefix changes state after a collection of the task
+type CircuitBreakerChangedEvent struct {
+	TaskID string
+	Prefix string
+	// State is "open", "half-open" or "closed"
+	State string
+}
+
+func (e CircuitBreakerChangedEvent) Namespace() string {
+	return CircuitBreakerChanged
+}
+
+// BackfillProgressedEvent is emitted when a backfill task has collected a
+// window of its range, its progress being kept for the backfill to resume
+type BackfillProgressedEvent struct {
+	TaskID string
+	Start  time.Time
+	End    time.Time
+}
+
+func (e BackfillProgressedEvent) Namespace() string {
+	return BackfillProgressed
+}
+
+// IntervalAdjustedEvent is emitted when the interval of a task is stretched
+// by the scheduler under load, or tightened back once the load subsided
+type IntervalAdjustedEvent struct {
+	TaskID   string
+	Interval time.Duration
+	// Stretch is the factor the configured interval is multiplied by
+	Stretch uint
+}
+
+func (e IntervalAdjustedEvent) Namespace() string {
+	return IntervalAdjusted
+}
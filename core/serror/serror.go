@@ -69,6 +69,12 @@ func (p *snapError) Error() string {
 	return p.err.Error()
 }
 
+// Unwrap returns the error wrapped by the snapError, which lets errors.Is
+// and errors.As inspect the cause.
+func (p *snapError) Unwrap() error {
+	return p.err
+}
+
 func (p *snapError) String() string {
 	return p.Error()
 }
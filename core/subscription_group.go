@@ -29,4 +29,42 @@ var (
 	// ErrSubscriptionGroupDoesNotExist - error message when the subscription
 	// group does not exist
 	ErrSubscriptionGroupDoesNotExist = errors.New("Subscription does not exist")
+
+	// ErrMetricNotFound - error kind of a subscription failing because the
+	// requested metric is not in the metric catalog
+	ErrMetricNotFound = errors.New("Metric not found")
+
+	// ErrSubscriptionTemporary - error kind of a subscription failing for a
+	// reason which may clear up, such as a plugin which could not be started
+	// or reached. Subscribing again later may succeed.
+	ErrSubscriptionTemporary = errors.New("Subscription temporarily unavailable")
 )
+
+// SubscriptionError is returned when subscribing to a metric or a plugin fails.
+// The Kind is one of ErrMetricNotFound or ErrSubscriptionTemporary, so the
+// failure can be classified with errors.Is while errors.As gives access to
+// the namespace and the underlying cause.
+type SubscriptionError struct {
+	// Kind classifies the failure
+	Kind error
+	// Namespace of the requested metric, empty if a plugin failed
+	Namespace string
+	// Version of the requested metric
+	Version int
+	// Err is the underlying cause
+	Err error
+}
+
+func (e *SubscriptionError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying cause
+func (e *SubscriptionError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error is of the given kind
+func (e *SubscriptionError) Is(target error) bool {
+	return target == e.Kind
+}
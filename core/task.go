@@ -26,10 +26,12 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
 
+	"github.com/intelsdi-x/snap/core/cdata"
 	"github.com/intelsdi-x/snap/core/serror"
 	"github.com/intelsdi-x/snap/pkg/schedule"
 	"github.com/intelsdi-x/snap/scheduler/wmap"
@@ -44,6 +46,7 @@ const (
 	TaskFiring
 	TaskEnded
 	TaskStopping
+	TaskPaused
 )
 
 var (
@@ -54,9 +57,49 @@ var (
 		TaskFiring:   "Running",  // running (firing can happen so briefly we don't want to try and render it as a string state)
 		TaskEnded:    "Ended",    // ended, but resumable if the schedule is still valid and might fire again
 		TaskStopping: "Stopping", // channel has been closed, wait for TaskStopped state
+		TaskPaused:   "Paused",   // not firing but still subscribed, resumable
 	}
 )
 
+// MissedFirePolicy determines what a task does with the firings its schedule
+// missed while the task was stopped or busy firing
+type MissedFirePolicy int
+
+const (
+	// MissedFireSkip skips the missed firings. This is the default policy.
+	MissedFireSkip MissedFirePolicy = iota
+	// MissedFireCatchUp fires the missed firings, up to the max catch-up of
+	// the task, on the next tick of its schedule
+	MissedFireCatchUp
+	// MissedFireOnce fires once for all the missed firings on the next tick of
+	// the schedule of the task
+	MissedFireOnce
+)
+
+var missedFirePolicies = map[string]MissedFirePolicy{
+	"skip":      MissedFireSkip,
+	"catch-up":  MissedFireCatchUp,
+	"fire-once": MissedFireOnce,
+}
+
+// ParseMissedFirePolicy returns the policy of the given name, which is one of
+// "skip", "catch-up" or "fire-once".
+func ParseMissedFirePolicy(name string) (MissedFirePolicy, error) {
+	if p, ok := missedFirePolicies[name]; ok {
+		return p, nil
+	}
+	return MissedFireSkip, fmt.Errorf("unknown missed fire policy '%s'", name)
+}
+
+func (p MissedFirePolicy) String() string {
+	for name, v := range missedFirePolicies {
+		if v == p {
+			return name
+		}
+	}
+	return "unknown"
+}
+
 type TaskWatcherCloser interface {
 	Close() error
 }
@@ -69,6 +112,25 @@ type TaskWatcherHandler interface {
 	CatchTaskDisabled(string)
 }
 
+// TaskRun is the outcome of one collection of a task
+type TaskRun struct {
+	// Metrics collected, empty if the collection failed
+	Metrics []Metric
+	// Errors of the failed collection
+	Errors []error
+	// Start is when the collection started
+	Start time.Time
+	// Duration of the collection
+	Duration time.Duration
+}
+
+// TaskRunWatcherHandler is a TaskWatcherHandler also catching every run of
+// the task as it completes, whether its collection succeeded or failed
+type TaskRunWatcherHandler interface {
+	TaskWatcherHandler
+	CatchRun(TaskRun)
+}
+
 func (t TaskState) String() string {
 	return TaskStateLookup[t]
 }
@@ -84,10 +146,46 @@ type Task interface {
 	MissedCount() uint
 	FailedCount() uint
 	LastFailureMessage() string
+	LastError() error
 	LastRunTime() *time.Time
+	NextFireTime() (time.Time, bool)
+	LastRun() (time.Time, bool)
+	NextRun() (time.Time, bool)
 	CreationTime() *time.Time
 	DeadlineDuration() time.Duration
 	SetDeadlineDuration(time.Duration)
+	Timeout() time.Duration
+	SetTimeout(time.Duration)
+	StopAt() time.Time
+	SetStopAt(time.Time)
+	MaxConcurrent() int
+	SetMaxConcurrent(int)
+	Retry() (int, time.Duration)
+	SetRetry(int, time.Duration)
+	MissedFirePolicy() MissedFirePolicy
+	SetMissedFirePolicy(MissedFirePolicy)
+	MaxCatchUp() int
+	SetMaxCatchUp(int)
+	Priority() int
+	SetPriority(int)
+	QueueFullPolicy() string
+	SetQueueFullPolicy(string)
+	MaxRuns() uint
+	SetMaxRuns(uint)
+	ScheduledRuns() uint
+	SetFiredCount(hits, scheduledRuns uint)
+	Labels() map[string]string
+	SetLabels(map[string]string)
+	Tenant() string
+	SetTenant(string)
+	Dependencies() ([]string, bool)
+	SetDependencies([]string, bool)
+	Stats() TaskStats
+	CollectWindow() time.Duration
+	SetCollectWindow(time.Duration)
+	BaseConfig() *cdata.ConfigDataNode
+	SetBaseConfig(*cdata.ConfigDataNode)
+	SubscribedMetricTypes() []Metric
 	SetTaskID(id string)
 	SetStopOnFailure(int)
 	MaxCollectDuration() time.Duration
@@ -121,6 +219,223 @@ func TaskDeadlineDuration(v time.Duration) TaskOption {
 	}
 }
 
+// OptionTimeout sets the tasks execution timeout.
+// The timeout is the amount of time a worker will wait on one of the tasks
+// jobs before abandoning it and recording the run as failed. The collection
+// of an abandoned job is cancelled. A zero value disables the timeout.
+func OptionTimeout(v time.Duration) TaskOption {
+	return func(t Task) TaskOption {
+		previous := t.Timeout()
+		t.SetTimeout(v)
+		log.WithFields(log.Fields{
+			"_module":      "core",
+			"_block":       "OptionTimeout",
+			"task-id":      t.ID(),
+			"task-name":    t.GetName(),
+			"task timeout": t.Timeout(),
+		}).Debug("Setting timeout on task")
+		return OptionTimeout(previous)
+	}
+}
+
+// OptionStopAt sets the time at which the task ends.
+// A task past its stop time ends on its next schedule tick instead of firing,
+// whatever its schedule. Stopping and starting the task keeps its stop time.
+func OptionStopAt(v time.Time) TaskOption {
+	return func(t Task) TaskOption {
+		previous := t.StopAt()
+		t.SetStopAt(v)
+		log.WithFields(log.Fields{
+			"_module":        "core",
+			"_block":         "OptionStopAt",
+			"task-id":        t.ID(),
+			"task-name":      t.GetName(),
+			"task stop time": t.StopAt(),
+		}).Debug("Setting stop time on task")
+		return OptionStopAt(previous)
+	}
+}
+
+// OptionStopAfter sets the task to end once the given duration has elapsed
+// from the time the option is applied, see OptionStopAt.
+func OptionStopAfter(d time.Duration) TaskOption {
+	return OptionStopAt(time.Now().Add(d))
+}
+
+// OptionMaxConcurrent sets the number of firings of the task which may run at
+// the same time. A tick of the schedule is skipped while that many firings of
+// the task have not finished. It defaults to 1.
+func OptionMaxConcurrent(n int) TaskOption {
+	return func(t Task) TaskOption {
+		previous := t.MaxConcurrent()
+		t.SetMaxConcurrent(n)
+		log.WithFields(log.Fields{
+			"_module":             "core",
+			"_block":              "OptionMaxConcurrent",
+			"task-id":             t.ID(),
+			"task-name":           t.GetName(),
+			"max concurrent runs": t.MaxConcurrent(),
+		}).Debug("Setting the maximum of concurrent firings of task")
+		return OptionMaxConcurrent(previous)
+	}
+}
+
+// OptionRetry sets the number of times a failed job of the task is retried.
+// The first retry waits for the backoff, which doubles for each following
+// retry. Retries are bounded by the deadline of the job and, for interval
+// schedules, never delay the next firing of the task.
+func OptionRetry(count int, backoff time.Duration) TaskOption {
+	return func(t Task) TaskOption {
+		previousCount, previousBackoff := t.Retry()
+		t.SetRetry(count, backoff)
+		log.WithFields(log.Fields{
+			"_module":       "core",
+			"_block":        "OptionRetry",
+			"task-id":       t.ID(),
+			"task-name":     t.GetName(),
+			"retries":       count,
+			"retry backoff": backoff,
+		}).Debug("Setting the retries of failed jobs of task")
+		return OptionRetry(previousCount, previousBackoff)
+	}
+}
+
+// OptionMissedFirePolicy sets whether the task fires the firings its schedule
+// missed while the task was stopped or busy firing. It defaults to
+// MissedFireSkip.
+func OptionMissedFirePolicy(p MissedFirePolicy) TaskOption {
+	return func(t Task) TaskOption {
+		previous := t.MissedFirePolicy()
+		t.SetMissedFirePolicy(p)
+		log.WithFields(log.Fields{
+			"_module":            "core",
+			"_block":             "OptionMissedFirePolicy",
+			"task-id":            t.ID(),
+			"task-name":          t.GetName(),
+			"missed fire policy": p.String(),
+		}).Debug("Setting the missed fire policy of task")
+		return OptionMissedFirePolicy(previous)
+	}
+}
+
+// OptionMaxCatchUp sets the number of missed firings a task with the
+// MissedFireCatchUp policy fires at most on a tick of its schedule.
+func OptionMaxCatchUp(n int) TaskOption {
+	return func(t Task) TaskOption {
+		previous := t.MaxCatchUp()
+		t.SetMaxCatchUp(n)
+		log.WithFields(log.Fields{
+			"_module":      "core",
+			"_block":       "OptionMaxCatchUp",
+			"task-id":      t.ID(),
+			"task-name":    t.GetName(),
+			"max catch-up": n,
+		}).Debug("Setting the maximum of caught up firings of task")
+		return OptionMaxCatchUp(previous)
+	}
+}
+
+// The priority classes of the tasks, the priorities named "high", "normal" and
+// "low" in task manifests
+const (
+	// PriorityHigh is the priority of the tasks whose collections should not
+	// wait behind the others, such as health checks
+	PriorityHigh = 10
+	// PriorityNormal is the priority of a task by default
+	PriorityNormal = 0
+	// PriorityLow is the priority of bulk tasks, whose jobs wait behind the
+	// jobs of the other tasks
+	PriorityLow = -10
+)
+
+var priorityClasses = map[string]int{
+	"high":   PriorityHigh,
+	"normal": PriorityNormal,
+	"low":    PriorityLow,
+}
+
+// ParsePriorityClass returns the priority of the given class, which is one of
+// "high", "normal" or "low"
+func ParsePriorityClass(class string) (int, error) {
+	if p, ok := priorityClasses[class]; ok {
+		return p, nil
+	}
+	return PriorityNormal, fmt.Errorf("unknown priority class '%s'", class)
+}
+
+// OptionPriority sets the priority of the jobs of a task. The jobs of a task
+// with a higher priority are worked ahead of the waiting jobs of tasks with
+// a lower priority. The priority is usually one of PriorityHigh,
+// PriorityNormal and PriorityLow.
+func OptionPriority(p int) TaskOption {
+	return func(t Task) TaskOption {
+		previous := t.Priority()
+		t.SetPriority(p)
+		log.WithFields(log.Fields{
+			"_module":   "core",
+			"_block":    "OptionPriority",
+			"task-id":   t.ID(),
+			"task-name": t.GetName(),
+			"priority":  p,
+		}).Debug("Setting the priority of task")
+		return OptionPriority(previous)
+	}
+}
+
+// OptionQueueFullPolicy sets the policy applied to the jobs of a task when
+// their work queue is full, one of "drop-newest", "drop-oldest" or "block",
+// in place of the policy of the scheduler.
+func OptionQueueFullPolicy(p string) TaskOption {
+	return func(t Task) TaskOption {
+		previous := t.QueueFullPolicy()
+		t.SetQueueFullPolicy(p)
+		log.WithFields(log.Fields{
+			"_module":           "core",
+			"_block":            "OptionQueueFullPolicy",
+			"task-id":           t.ID(),
+			"task-name":         t.GetName(),
+			"queue-full-policy": p,
+		}).Debug("Setting the queue full policy of task")
+		return OptionQueueFullPolicy(previous)
+	}
+}
+
+// OptionCollectWindow sets the window over which a task accumulates the
+// metrics collected by its firings. The process and publish jobs of the
+// workflow run once per window on all the metrics collected during it,
+// instead of on every firing. A window of 0, the default, disables it.
+func OptionCollectWindow(d time.Duration) TaskOption {
+	return func(t Task) TaskOption {
+		previous := t.CollectWindow()
+		t.SetCollectWindow(d)
+		log.WithFields(log.Fields{
+			"_module":        "core",
+			"_block":         "OptionCollectWindow",
+			"task-id":        t.ID(),
+			"task-name":      t.GetName(),
+			"collect window": d,
+		}).Debug("Setting the collect window of task")
+		return OptionCollectWindow(previous)
+	}
+}
+
+// OptionBaseConfig sets the config applied beneath the config of every
+// namespace of the workflow of a task. The config given for a namespace in
+// the workflow overrides the items of the base config.
+func OptionBaseConfig(n *cdata.ConfigDataNode) TaskOption {
+	return func(t Task) TaskOption {
+		previous := t.BaseConfig()
+		t.SetBaseConfig(n)
+		log.WithFields(log.Fields{
+			"_module":   "core",
+			"_block":    "OptionBaseConfig",
+			"task-id":   t.ID(),
+			"task-name": t.GetName(),
+		}).Debug("Setting the base config of task")
+		return OptionBaseConfig(previous)
+	}
+}
+
 // TaskStopOnFailure sets the tasks stopOnFailure
 // The stopOnFailure is the number of consecutive task failures that will
 // trigger disabling the task
@@ -139,6 +454,98 @@ func OptionStopOnFailure(v int) TaskOption {
 	}
 }
 
+// OptionMaxRuns sets the number of times a task fires on its schedule before
+// it ends, the task fires until its schedule ends if 0
+func OptionMaxRuns(n uint) TaskOption {
+	return func(t Task) TaskOption {
+		previous := t.MaxRuns()
+		t.SetMaxRuns(n)
+		log.WithFields(log.Fields{
+			"_module":   "core",
+			"_block":    "OptionMaxRuns",
+			"task-id":   t.ID(),
+			"task-name": t.GetName(),
+			"max-runs":  n,
+		}).Debug("Setting the maximum number of runs of task")
+		return OptionMaxRuns(previous)
+	}
+}
+
+// OptionFiredCount sets the number of times a task has fired and, out of
+// them, the number of times it fired on its schedule, which count towards
+// its max runs. It carries the counts of a task over to the task restored
+// or imported from it.
+func OptionFiredCount(hits, scheduledRuns uint) TaskOption {
+	return func(t Task) TaskOption {
+		previousHits, previousRuns := t.HitCount(), t.ScheduledRuns()
+		t.SetFiredCount(hits, scheduledRuns)
+		log.WithFields(log.Fields{
+			"_module":        "core",
+			"_block":         "OptionFiredCount",
+			"task-id":        t.ID(),
+			"task-name":      t.GetName(),
+			"hit-count":      hits,
+			"scheduled-runs": scheduledRuns,
+		}).Debug("Setting the fired count of task")
+		return OptionFiredCount(previousHits, previousRuns)
+	}
+}
+
+// OptionLabels sets the key/value labels of a task, which select the task in
+// a label selector such as "env=prod,service=web"
+func OptionLabels(labels map[string]string) TaskOption {
+	return func(t Task) TaskOption {
+		previous := t.Labels()
+		t.SetLabels(labels)
+		log.WithFields(log.Fields{
+			"_module":   "core",
+			"_block":    "OptionLabels",
+			"task-id":   t.ID(),
+			"task-name": t.GetName(),
+			"labels":    labels,
+		}).Debug("Setting the labels of task")
+		return OptionLabels(previous)
+	}
+}
+
+// OptionTenant sets the tenant a task belongs to, the task counting against
+// the quota of the tenant. A task created with a context carrying a tenant
+// belongs to that tenant and cannot be given another one.
+func OptionTenant(tenant string) TaskOption {
+	return func(t Task) TaskOption {
+		previous := t.Tenant()
+		t.SetTenant(tenant)
+		log.WithFields(log.Fields{
+			"_module":   "core",
+			"_block":    "OptionTenant",
+			"task-id":   t.ID(),
+			"task-name": t.GetName(),
+			"tenant":    tenant,
+		}).Debug("Setting the tenant of task")
+		return OptionTenant(previous)
+	}
+}
+
+// OptionDependsOn sets the ids of the tasks a task depends on. The task, on a
+// dependent schedule, fires once each of them completed a successful run
+// since it last fired. With passMetrics, the metrics collected by those runs
+// are added to the metrics the task collects.
+func OptionDependsOn(ids []string, passMetrics bool) TaskOption {
+	return func(t Task) TaskOption {
+		previous, previousPass := t.Dependencies()
+		t.SetDependencies(ids, passMetrics)
+		log.WithFields(log.Fields{
+			"_module":      "core",
+			"_block":       "OptionDependsOn",
+			"task-id":      t.ID(),
+			"task-name":    t.GetName(),
+			"depends-on":   ids,
+			"pass-metrics": passMetrics,
+		}).Debug("Setting the dependencies of task")
+		return OptionDependsOn(previous, previousPass)
+	}
+}
+
 // SetTaskName sets the name of the task.
 // This is optional.
 // If task name is not set, the task name is then defaulted to "Task-<task-id>"
@@ -174,20 +581,74 @@ func SetMaxCollectDuration(d time.Duration) TaskOption {
 	}
 }
 
+// TaskStats holds the run counters of a task along with the latencies of
+// the collect, process and publish jobs of its workflow. BufferedMetrics is
+// the number of metrics held by the publish batches of the workflow,
+// FlushedBatches the number of batches published so far and DuplicateMetrics
+// the number of metrics dropped as they were collected more than once by a
+// collection.
+type TaskStats struct {
+	HitCount           uint
+	MissedCount        uint
+	FailedCount        uint
+	LastRunTime        time.Time
+	LastFailureMessage string
+	Collect            LatencyStats
+	Process            LatencyStats
+	Publish            LatencyStats
+	BufferedMetrics    uint
+	FlushedBatches     uint64
+	DuplicateMetrics   uint64
+}
+
+// LatencyStats summarizes how long the jobs of a phase of a workflow took,
+// from their submission to the end of their last attempt. The count and the
+// sum cover every job while the percentiles only cover the most recent ones.
+type LatencyStats struct {
+	Count uint64
+	Sum   time.Duration
+	P50   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+}
+
+// TaskErrors holds the errors of a task operation. The errors of creating,
+// validating and updating a task are attributed to the phase they occurred
+// in, a code and the namespace of the metric they are about, as returned by
+// TaskErrorAttribution.
 type TaskErrors interface {
+	error
 	Errors() []serror.SnapError
 }
 
 type TaskCreationRequest struct {
-	Name               string            `json:"name"`
-	Version            int               `json:"version"`
-	Deadline           string            `json:"deadline"`
-	Workflow           *wmap.WorkflowMap `json:"workflow"`
-	Schedule           *Schedule         `json:"schedule"`
-	Start              bool              `json:"start"`
-	MaxFailures        int               `json:"max-failures"`
-	MaxCollectDuration string            `json:"max-collect-duration"`
-	MaxMetricsBuffer   int64             `json:"max-metrics-buffer"`
+	Name               string                 `json:"name"`
+	Version            int                    `json:"version"`
+	Deadline           string                 `json:"deadline"`
+	Timeout            string                 `json:"timeout"`
+	Workflow           *wmap.WorkflowMap      `json:"workflow"`
+	Schedule           *Schedule              `json:"schedule"`
+	Start              bool                   `json:"start"`
+	MaxFailures        int                    `json:"max-failures"`
+	MaxCollectDuration string                 `json:"max-collect-duration"`
+	MaxMetricsBuffer   int64                  `json:"max-metrics-buffer"`
+	MaxConcurrent      int                    `json:"max-concurrent"`
+	Retries            int                    `json:"retries"`
+	RetryBackoff       string                 `json:"retry-backoff"`
+	MissedFirePolicy   string                 `json:"missed-fire-policy"`
+	MaxCatchUp         int                    `json:"max-catch-up"`
+	Priority           int                    `json:"priority"`
+	QueueFullPolicy    string                 `json:"queue-full-policy"`
+	MaxRuns            uint                   `json:"max-runs"`
+	StopAt             *time.Time             `json:"stop-at,omitempty"`
+	HitCount           uint                   `json:"hit-count,omitempty"`
+	ScheduledRuns      uint                   `json:"scheduled-runs,omitempty"`
+	Labels             map[string]string      `json:"labels"`
+	Tenant             string                 `json:"tenant,omitempty"`
+	CollectWindow      string                 `json:"collect-window"`
+	DependsOn          []string               `json:"depends-on,omitempty"`
+	PassMetrics        bool                   `json:"pass-metrics,omitempty"`
+	Config             map[string]interface{} `json:"config"`
 }
 
 func (tr *TaskCreationRequest) UnmarshalJSON(data []byte) error {
@@ -205,6 +666,10 @@ func (tr *TaskCreationRequest) UnmarshalJSON(data []byte) error {
 			if err := json.Unmarshal(v, &(tr.Deadline)); err != nil {
 				return fmt.Errorf("%v (while parsing 'deadline')", err)
 			}
+		case "timeout":
+			if err := json.Unmarshal(v, &(tr.Timeout)); err != nil {
+				return fmt.Errorf("%v (while parsing 'timeout')", err)
+			}
 		case "workflow":
 			if err := json.Unmarshal(v, &(tr.Workflow)); err != nil {
 				return err
@@ -233,6 +698,82 @@ func (tr *TaskCreationRequest) UnmarshalJSON(data []byte) error {
 			if err := json.Unmarshal(v, &(tr.MaxMetricsBuffer)); err != nil {
 				return fmt.Errorf("%v (while parsing 'max-metrics-buffer')", err)
 			}
+		case "max-concurrent":
+			if err := json.Unmarshal(v, &(tr.MaxConcurrent)); err != nil {
+				return fmt.Errorf("%v (while parsing 'max-concurrent')", err)
+			}
+		case "retries":
+			if err := json.Unmarshal(v, &(tr.Retries)); err != nil {
+				return fmt.Errorf("%v (while parsing 'retries')", err)
+			}
+		case "retry-backoff":
+			if err := json.Unmarshal(v, &(tr.RetryBackoff)); err != nil {
+				return fmt.Errorf("%v (while parsing 'retry-backoff')", err)
+			}
+		case "missed-fire-policy":
+			if err := json.Unmarshal(v, &(tr.MissedFirePolicy)); err != nil {
+				return fmt.Errorf("%v (while parsing 'missed-fire-policy')", err)
+			}
+		case "max-catch-up":
+			if err := json.Unmarshal(v, &(tr.MaxCatchUp)); err != nil {
+				return fmt.Errorf("%v (while parsing 'max-catch-up')", err)
+			}
+		case "priority":
+			// the priority is either a number or the name of a class
+			var class string
+			if json.Unmarshal(v, &class) == nil {
+				p, err := ParsePriorityClass(class)
+				if err != nil {
+					return fmt.Errorf("%v (while parsing 'priority')", err)
+				}
+				tr.Priority = p
+			} else if err := json.Unmarshal(v, &(tr.Priority)); err != nil {
+				return fmt.Errorf("%v (while parsing 'priority')", err)
+			}
+		case "queue-full-policy":
+			if err := json.Unmarshal(v, &(tr.QueueFullPolicy)); err != nil {
+				return fmt.Errorf("%v (while parsing 'queue-full-policy')", err)
+			}
+		case "max-runs":
+			if err := json.Unmarshal(v, &(tr.MaxRuns)); err != nil {
+				return fmt.Errorf("%v (while parsing 'max-runs')", err)
+			}
+		case "stop-at":
+			if err := json.Unmarshal(v, &(tr.StopAt)); err != nil {
+				return fmt.Errorf("%v (while parsing 'stop-at')", err)
+			}
+		case "hit-count":
+			if err := json.Unmarshal(v, &(tr.HitCount)); err != nil {
+				return fmt.Errorf("%v (while parsing 'hit-count')", err)
+			}
+		case "scheduled-runs":
+			if err := json.Unmarshal(v, &(tr.ScheduledRuns)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduled-runs')", err)
+			}
+		case "labels":
+			if err := json.Unmarshal(v, &(tr.Labels)); err != nil {
+				return fmt.Errorf("%v (while parsing 'labels')", err)
+			}
+		case "tenant":
+			if err := json.Unmarshal(v, &(tr.Tenant)); err != nil {
+				return fmt.Errorf("%v (while parsing 'tenant')", err)
+			}
+		case "collect-window":
+			if err := json.Unmarshal(v, &(tr.CollectWindow)); err != nil {
+				return fmt.Errorf("%v (while parsing 'collect-window')", err)
+			}
+		case "depends-on":
+			if err := json.Unmarshal(v, &(tr.DependsOn)); err != nil {
+				return fmt.Errorf("%v (while parsing 'depends-on')", err)
+			}
+		case "pass-metrics":
+			if err := json.Unmarshal(v, &(tr.PassMetrics)); err != nil {
+				return fmt.Errorf("%v (while parsing 'pass-metrics')", err)
+			}
+		case "config":
+			if err := json.Unmarshal(v, &(tr.Config)); err != nil {
+				return fmt.Errorf("%v (while parsing 'config')", err)
+			}
 		default:
 			return fmt.Errorf("Unrecognized key '%v' in task creation request", k)
 		}
@@ -255,6 +796,19 @@ func CreateTaskFromContent(body io.ReadCloser,
 	if err != nil {
 		return nil, err
 	}
+	return CreateTaskFromRequest(tr, mode, fp)
+}
+
+// CreateTaskFromRequest creates a task according to a task creation request.
+// Mode and the function pointer are used as by CreateTaskFromContent, the
+// given options are applied before the ones derived from the request.
+func CreateTaskFromRequest(tr *TaskCreationRequest,
+	mode *bool,
+	fp func(sch schedule.Schedule,
+		wfMap *wmap.WorkflowMap,
+		startOnCreate bool,
+		opts ...TaskOption) (Task, TaskErrors),
+	opts ...TaskOption) (Task, error) {
 
 	if err := validateTaskRequest(tr); err != nil {
 		return nil, err
@@ -265,7 +819,6 @@ func CreateTaskFromContent(body io.ReadCloser,
 		return nil, err
 	}
 
-	var opts []TaskOption
 	if tr.Deadline != "" {
 		dl, err := time.ParseDuration(tr.Deadline)
 		if err != nil {
@@ -274,6 +827,14 @@ func CreateTaskFromContent(body io.ReadCloser,
 		opts = append(opts, TaskDeadlineDuration(dl))
 	}
 
+	if tr.Timeout != "" {
+		to, err := time.ParseDuration(tr.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, OptionTimeout(to))
+	}
+
 	if tr.Name != "" {
 		opts = append(opts, SetTaskName(tr.Name))
 	}
@@ -292,6 +853,82 @@ func CreateTaskFromContent(body io.ReadCloser,
 		opts = append(opts, SetMaxMetricsBuffer(tr.MaxMetricsBuffer))
 	}
 
+	if tr.MaxConcurrent != 0 {
+		opts = append(opts, OptionMaxConcurrent(tr.MaxConcurrent))
+	}
+
+	if tr.Retries != 0 {
+		var backoff time.Duration
+		if tr.RetryBackoff != "" {
+			var err error
+			backoff, err = time.ParseDuration(tr.RetryBackoff)
+			if err != nil {
+				return nil, err
+			}
+		}
+		opts = append(opts, OptionRetry(tr.Retries, backoff))
+	}
+
+	if tr.MissedFirePolicy != "" {
+		p, err := ParseMissedFirePolicy(tr.MissedFirePolicy)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, OptionMissedFirePolicy(p))
+	}
+
+	if tr.MaxCatchUp != 0 {
+		opts = append(opts, OptionMaxCatchUp(tr.MaxCatchUp))
+	}
+
+	if tr.Priority != 0 {
+		opts = append(opts, OptionPriority(tr.Priority))
+	}
+
+	if tr.QueueFullPolicy != "" {
+		opts = append(opts, OptionQueueFullPolicy(tr.QueueFullPolicy))
+	}
+
+	if tr.MaxRuns != 0 {
+		opts = append(opts, OptionMaxRuns(tr.MaxRuns))
+	}
+
+	if tr.StopAt != nil {
+		opts = append(opts, OptionStopAt(*tr.StopAt))
+	}
+
+	if tr.HitCount != 0 || tr.ScheduledRuns != 0 {
+		opts = append(opts, OptionFiredCount(tr.HitCount, tr.ScheduledRuns))
+	}
+
+	if len(tr.Labels) > 0 {
+		opts = append(opts, OptionLabels(tr.Labels))
+	}
+
+	if tr.Tenant != "" {
+		opts = append(opts, OptionTenant(tr.Tenant))
+	}
+
+	if tr.CollectWindow != "" {
+		cw, err := time.ParseDuration(tr.CollectWindow)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, OptionCollectWindow(cw))
+	}
+
+	if len(tr.DependsOn) > 0 {
+		opts = append(opts, OptionDependsOn(tr.DependsOn, tr.PassMetrics))
+	}
+
+	if len(tr.Config) > 0 {
+		n, err := wmap.ConfigDataNodeFromMap(tr.Config)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, OptionBaseConfig(n))
+	}
+
 	if tr.MaxCollectDuration != "" {
 		dl, err := time.ParseDuration(tr.MaxCollectDuration)
 		if err != nil {
@@ -311,7 +948,7 @@ func CreateTaskFromContent(body io.ReadCloser,
 
 			log.WithFields(log.Fields{
 				"_file":     "core/task.go",
-				"_function": "CreateTaskFromContent",
+				"_function": "CreateTaskFromRequest",
 				"_error":    e.Error(),
 				"_fields":   e.Fields(),
 			}).Error("error creating task")
@@ -344,7 +981,7 @@ func UnmarshalBody(in interface{}, body io.ReadCloser) (int, error) {
 }
 
 func validateTaskRequest(tr *TaskCreationRequest) error {
-	if tr.Schedule == nil || *tr.Schedule == (Schedule{}) {
+	if tr.Schedule == nil || reflect.DeepEqual(*tr.Schedule, Schedule{}) {
 		return fmt.Errorf("Task must include a schedule, and the schedule must not be empty")
 	}
 
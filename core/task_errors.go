@@ -0,0 +1,148 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"errors"
+
+	"github.com/intelsdi-x/snap/core/serror"
+)
+
+// The fields a task error is attributed with
+const (
+	// TaskErrorPhaseField holds the TaskPhase the error occurred in
+	TaskErrorPhaseField = "phase"
+	// TaskErrorCodeField holds the TaskErrorCode of the error
+	TaskErrorCodeField = "code"
+	// TaskErrorNamespaceField holds the namespace of the metric the error
+	// is about, if any
+	TaskErrorNamespaceField = "namespace"
+)
+
+// TaskPhase is the phase of a task operation an error occurred in
+type TaskPhase string
+
+const (
+	// TaskPhaseScheduler covers the checks the scheduler makes before it
+	// validates a task, such as the state of the task to update, and the
+	// adding of a created task to the scheduler
+	TaskPhaseScheduler TaskPhase = "scheduler"
+	// TaskPhaseValidation covers the validation of the schedule, the
+	// workflow and the options of the task
+	TaskPhaseValidation TaskPhase = "validation"
+	// TaskPhaseDependencies covers the validation of the metrics and
+	// plugins the workflow depends on
+	TaskPhaseDependencies TaskPhase = "dependencies"
+	// TaskPhaseSubscription covers the subscription of the plugins of the
+	// task when it is started or updated
+	TaskPhaseSubscription TaskPhase = "subscription"
+	// TaskPhaseCollection covers a collection run on behalf of the task
+	TaskPhaseCollection TaskPhase = "collection"
+)
+
+// TaskErrorCode classifies a task error
+type TaskErrorCode string
+
+const (
+	// TaskErrorSchedulerUnavailable - the scheduler is not started or not
+	// the leader
+	TaskErrorSchedulerUnavailable TaskErrorCode = "scheduler_unavailable"
+	// TaskErrorTaskNotFound - the task to update does not exist
+	TaskErrorTaskNotFound TaskErrorCode = "task_not_found"
+	// TaskErrorTaskNotUpdatable - the task cannot be updated in its state
+	// or as it streams
+	TaskErrorTaskNotUpdatable TaskErrorCode = "task_not_updatable"
+	// TaskErrorTaskNotAdded - the task could not be added, as its name is
+	// already in use
+	TaskErrorTaskNotAdded TaskErrorCode = "task_not_added"
+	// TaskErrorInvalidSchedule - the schedule of the task is not valid
+	TaskErrorInvalidSchedule TaskErrorCode = "invalid_schedule"
+	// TaskErrorInvalidWorkflow - the workflow of the task is not valid
+	TaskErrorInvalidWorkflow TaskErrorCode = "invalid_workflow"
+	// TaskErrorInvalidOption - an option of the task is not valid
+	TaskErrorInvalidOption TaskErrorCode = "invalid_option"
+	// TaskErrorInvalidDependency - a plugin of the workflow cannot be used by
+	// the task
+	TaskErrorInvalidDependency TaskErrorCode = "invalid_dependency"
+	// TaskErrorMetricNotFound - a metric of the workflow is not in the
+	// metric catalog
+	TaskErrorMetricNotFound TaskErrorCode = "metric_not_found"
+	// TaskErrorPluginNotFound - a plugin of the workflow is not loaded
+	TaskErrorPluginNotFound TaskErrorCode = "plugin_not_found"
+	// TaskErrorConfigPolicy - the config of a metric or a plugin does not
+	// satisfy its config policy
+	TaskErrorConfigPolicy TaskErrorCode = "config_policy"
+	// TaskErrorSubscriptionFailed - a plugin could not be subscribed
+	TaskErrorSubscriptionFailed TaskErrorCode = "subscription_failed"
+	// TaskErrorSubscriptionTemporary - a plugin could not be subscribed for
+	// a reason which may clear up, as with ErrSubscriptionTemporary
+	TaskErrorSubscriptionTemporary TaskErrorCode = "subscription_temporary"
+	// TaskErrorCollectionFailed - a collection of the task failed
+	TaskErrorCollectionFailed TaskErrorCode = "collection_failed"
+	// TaskErrorCancelled - the operation was cancelled or timed out
+	TaskErrorCancelled TaskErrorCode = "cancelled"
+	// TaskErrorNotAuthorized - the authorizer of the scheduler denied the
+	// operation
+	TaskErrorNotAuthorized TaskErrorCode = "not_authorized"
+	// TaskErrorQuotaExceeded - the operation would take the tenant of the
+	// task over its quota
+	TaskErrorQuotaExceeded TaskErrorCode = "quota_exceeded"
+)
+
+// TaskErrorAttribution returns the phase, the code and the namespace a task
+// error is attributed with. They are empty if the error is not attributed.
+func TaskErrorAttribution(e serror.SnapError) (TaskPhase, TaskErrorCode, string) {
+	fields := e.Fields()
+	phase, _ := fields[TaskErrorPhaseField].(TaskPhase)
+	code, _ := fields[TaskErrorCodeField].(TaskErrorCode)
+	ns, _ := fields[TaskErrorNamespaceField].(string)
+	return phase, code, ns
+}
+
+// AttributeTaskError attributes a task error to the phase. The code and the
+// namespace the error was already attributed with are kept, otherwise the
+// code is derived from a SubscriptionError wrapped by the error, or is the
+// given one.
+func AttributeTaskError(e serror.SnapError, phase TaskPhase, code TaskErrorCode) serror.SnapError {
+	fields := map[string]interface{}{}
+	for k, v := range e.Fields() {
+		fields[k] = v
+	}
+	fields[TaskErrorPhaseField] = phase
+	var subErr *SubscriptionError
+	if errors.As(e, &subErr) {
+		if _, ok := fields[TaskErrorCodeField]; !ok {
+			switch subErr.Kind {
+			case ErrMetricNotFound:
+				fields[TaskErrorCodeField] = TaskErrorMetricNotFound
+			case ErrSubscriptionTemporary:
+				fields[TaskErrorCodeField] = TaskErrorSubscriptionTemporary
+			}
+		}
+		if _, ok := fields[TaskErrorNamespaceField]; !ok && subErr.Namespace != "" {
+			fields[TaskErrorNamespaceField] = subErr.Namespace
+		}
+	}
+	if _, ok := fields[TaskErrorCodeField]; !ok {
+		fields[TaskErrorCodeField] = code
+	}
+	e.SetFields(fields)
+	return e
+}
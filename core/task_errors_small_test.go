@@ -0,0 +1,69 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/intelsdi-x/snap/core/serror"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAttributeTaskError(t *testing.T) {
+	Convey("An error without attribution", t, func() {
+		e := AttributeTaskError(serror.New(errors.New("bad"), map[string]interface{}{"name": "x"}),
+			TaskPhaseValidation, TaskErrorInvalidWorkflow)
+		Convey("Should get the phase and the code", func() {
+			phase, code, ns := TaskErrorAttribution(e)
+			So(phase, ShouldEqual, TaskPhaseValidation)
+			So(code, ShouldEqual, TaskErrorInvalidWorkflow)
+			So(ns, ShouldBeEmpty)
+			So(e.Fields()["name"], ShouldEqual, "x")
+		})
+	})
+	Convey("An error with a code", t, func() {
+		e := AttributeTaskError(serror.New(errors.New("bad"), map[string]interface{}{
+			TaskErrorCodeField:      TaskErrorConfigPolicy,
+			TaskErrorNamespaceField: "/intel/mock/foo",
+		}), TaskPhaseDependencies, TaskErrorInvalidDependency)
+		Convey("Should keep its code and its namespace", func() {
+			phase, code, ns := TaskErrorAttribution(e)
+			So(phase, ShouldEqual, TaskPhaseDependencies)
+			So(code, ShouldEqual, TaskErrorConfigPolicy)
+			So(ns, ShouldEqual, "/intel/mock/foo")
+		})
+	})
+	Convey("A subscription error", t, func() {
+		e := AttributeTaskError(serror.New(&SubscriptionError{
+			Kind:      ErrMetricNotFound,
+			Namespace: "/intel/mock/bar",
+			Err:       errors.New("no metric found"),
+		}), TaskPhaseSubscription, TaskErrorSubscriptionFailed)
+		Convey("Should be classified by its kind", func() {
+			phase, code, ns := TaskErrorAttribution(e)
+			So(phase, ShouldEqual, TaskPhaseSubscription)
+			So(code, ShouldEqual, TaskErrorMetricNotFound)
+			So(ns, ShouldEqual, "/intel/mock/bar")
+		})
+	})
+}
@@ -22,6 +22,7 @@ limitations under the License.
 package core
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -46,6 +47,10 @@ func (t *taskErrors) Errors() []serror.SnapError {
 	return t.errs
 }
 
+func (t *taskErrors) Error() string {
+	return ""
+}
+
 const (
 	DUMMY_FILE = "dummy.txt"
 	YAML_FILE  = "./mock-file.yaml"
@@ -247,6 +252,29 @@ func TestCreateTaskRequest(t *testing.T) {
 	})
 }
 
+func TestTaskCreationRequestPriority(t *testing.T) {
+	Convey("Priority class", t, func() {
+		tr := TaskCreationRequest{}
+		So(json.Unmarshal([]byte(`{"priority":"high"}`), &tr), ShouldBeNil)
+		So(tr.Priority, ShouldEqual, PriorityHigh)
+		So(json.Unmarshal([]byte(`{"priority":"low"}`), &tr), ShouldBeNil)
+		So(tr.Priority, ShouldEqual, PriorityLow)
+	})
+
+	Convey("Numeric priority", t, func() {
+		tr := TaskCreationRequest{}
+		So(json.Unmarshal([]byte(`{"priority":5}`), &tr), ShouldBeNil)
+		So(tr.Priority, ShouldEqual, 5)
+	})
+
+	Convey("Unknown priority class", t, func() {
+		tr := TaskCreationRequest{}
+		err := json.Unmarshal([]byte(`{"priority":"urgent"}`), &tr)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "unknown priority class 'urgent' (while parsing 'priority')")
+	})
+}
+
 func TestCreateTaskFromContent(t *testing.T) {
 
 	Convey("Non existing file", t, func() {
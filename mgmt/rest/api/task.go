@@ -1,6 +1,8 @@
 package api
 
 import (
+	"context"
+
 	"github.com/intelsdi-x/snap/core"
 	"github.com/intelsdi-x/snap/core/serror"
 	"github.com/intelsdi-x/snap/pkg/schedule"
@@ -9,11 +11,16 @@ import (
 
 type Tasks interface {
 	CreateTask(schedule.Schedule, *wmap.WorkflowMap, bool, ...core.TaskOption) (core.Task, core.TaskErrors)
+	CreateTaskWithContext(context.Context, schedule.Schedule, *wmap.WorkflowMap, bool, ...core.TaskOption) (core.Task, core.TaskErrors)
 	GetTasks() map[string]core.Task
 	GetTask(string) (core.Task, error)
 	StartTask(string) []serror.SnapError
+	StartTaskWithContext(context.Context, string) []serror.SnapError
 	StopTask(string) []serror.SnapError
+	StopTaskWithContext(context.Context, string) []serror.SnapError
 	RemoveTask(string) error
+	RemoveTaskWithContext(context.Context, string) error
 	WatchTask(string, core.TaskWatcherHandler) (core.TaskWatcherCloser, error)
 	EnableTask(string) (core.Task, error)
+	AuditLog(core.AuditFilter) []core.AuditEntry
 }
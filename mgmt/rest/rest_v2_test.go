@@ -36,6 +36,7 @@ import (
 	"testing"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/ghodss/yaml"
 	"github.com/intelsdi-x/snap/core/cdata"
 	"github.com/intelsdi-x/snap/core/ctypes"
 	"github.com/intelsdi-x/snap/mgmt/rest/v2/mock"
@@ -294,6 +295,24 @@ func TestV2Task(t *testing.T) {
 			)
 		})
 
+		Convey("Add tasks from a YAML manifest - v2/tasks", func() {
+			manifest, err := yaml.JSONToYAML([]byte(mock.TASK))
+			So(err, ShouldBeNil)
+			resp, err := http.Post(
+				fmt.Sprintf("http://localhost:%d/v2/tasks", r.port),
+				"application/x-yaml",
+				bytes.NewReader(manifest))
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, 201)
+			body, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldBeNil)
+			So(
+				string(body),
+				ShouldResemble,
+				fmt.Sprintf(mock.ADD_TASK_RESPONSE, r.port),
+			)
+		})
+
 		Convey("Get tasks - v2/tasks", func() {
 			resp, err := http.Get(
 				fmt.Sprintf("http://localhost:%d/v2/tasks", r.port))
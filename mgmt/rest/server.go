@@ -37,6 +37,7 @@ import (
 
 	"strings"
 
+	"github.com/intelsdi-x/snap/core"
 	"github.com/intelsdi-x/snap/mgmt/rest/api"
 	"github.com/intelsdi-x/snap/mgmt/rest/v1"
 	"github.com/intelsdi-x/snap/mgmt/rest/v2"
@@ -166,6 +167,8 @@ func (s *Server) authMiddleware(rw http.ResponseWriter, r *http.Request, next ht
 	s.setAllowedOrigins(rw, reqOrigin)
 
 	defer r.Body.Close()
+	// the caller of the task operations is recorded in the audit log
+	r = r.WithContext(core.WithCaller(r.Context(), requestCaller(r)))
 	if s.auth {
 		_, password, ok := r.BasicAuth()
 		// If we have valid password or going to tribe/agreements endpoint
@@ -181,6 +184,19 @@ func (s *Server) authMiddleware(rw http.ResponseWriter, r *http.Request, next ht
 	}
 }
 
+// requestCaller returns the identity of the caller of a request, the user of
+// its basic authentication or else the host it comes from
+func requestCaller(r *http.Request) string {
+	if user, _, ok := r.BasicAuth(); ok && user != "" {
+		return user
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 // CORS origins have to be turned on explicitly in the global config.
 // Otherwise, it defaults to the same origin.
 func (s *Server) setAllowedOrigins(rw http.ResponseWriter, ro string) {
@@ -1,3 +1,4 @@
+//go:build legacy || small || medium || large
 // +build legacy small medium large
 
 /*
@@ -21,9 +22,11 @@ limitations under the License.
 package fixtures
 
 import (
+	"context"
 	"time"
 
 	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/cdata"
 	"github.com/intelsdi-x/snap/core/serror"
 	"github.com/intelsdi-x/snap/pkg/schedule"
 	"github.com/intelsdi-x/snap/scheduler/wmap"
@@ -76,10 +79,22 @@ func (t *mockTask) SetID(string)                        { return }
 func (t *mockTask) MissedCount() uint                   { return 0 }
 func (t *mockTask) FailedCount() uint                   { return 0 }
 func (t *mockTask) LastFailureMessage() string          { return "" }
+func (t *mockTask) LastError() error                    { return nil }
 func (t *mockTask) LastRunTime() *time.Time             { return &time.Time{} }
 func (t *mockTask) CreationTime() *time.Time            { return &time.Time{} }
 func (t *mockTask) DeadlineDuration() time.Duration     { return 4 }
 func (t *mockTask) SetDeadlineDuration(time.Duration)   { return }
+func (t *mockTask) Timeout() time.Duration              { return 0 }
+func (t *mockTask) SetTimeout(time.Duration)            { return }
+func (t *mockTask) StopAt() time.Time                   { return time.Time{} }
+func (t *mockTask) LastRun() (time.Time, bool)          { return time.Time{}, false }
+func (t *mockTask) NextRun() (time.Time, bool)          { return time.Time{}, false }
+func (t *mockTask) NextFireTime() (time.Time, bool)     { return time.Time{}, false }
+func (t *mockTask) MaxConcurrent() int                  { return 1 }
+func (t *mockTask) SetMaxConcurrent(int)                { return }
+func (t *mockTask) Retry() (int, time.Duration)         { return 0, 0 }
+func (t *mockTask) SetRetry(int, time.Duration)         { return }
+func (t *mockTask) SetStopAt(time.Time)                 { return }
 func (t *mockTask) SetTaskID(id string)                 { return }
 func (t *mockTask) SetStopOnFailure(int)                { return }
 func (t *mockTask) GetStopOnFailure() int               { return 0 }
@@ -99,6 +114,31 @@ func (t *mockTask) Schedule() schedule.Schedule {
 }
 func (t *mockTask) MaxFailures() int { return 10 }
 
+func (t *mockTask) MissedFirePolicy() core.MissedFirePolicy   { return core.MissedFireSkip }
+func (t *mockTask) SetMissedFirePolicy(core.MissedFirePolicy) { return }
+func (t *mockTask) MaxCatchUp() int                           { return 0 }
+func (t *mockTask) SetMaxCatchUp(int)                         { return }
+func (t *mockTask) Priority() int                             { return 0 }
+func (t *mockTask) SetPriority(int)                           { return }
+func (t *mockTask) QueueFullPolicy() string                   { return "" }
+func (t *mockTask) SetQueueFullPolicy(string)                 { return }
+func (t *mockTask) MaxRuns() uint                             { return 0 }
+func (t *mockTask) SetMaxRuns(uint)                           { return }
+func (t *mockTask) ScheduledRuns() uint                       { return 0 }
+func (t *mockTask) SetFiredCount(uint, uint)                  { return }
+func (t *mockTask) Labels() map[string]string                 { return nil }
+func (t *mockTask) SetLabels(map[string]string)               { return }
+func (t *mockTask) Tenant() string                            { return "" }
+func (t *mockTask) SetTenant(string)                          { return }
+func (t *mockTask) Dependencies() ([]string, bool)            { return nil, false }
+func (t *mockTask) SetDependencies([]string, bool)            { return }
+func (t *mockTask) Stats() core.TaskStats                     { return core.TaskStats{} }
+func (t *mockTask) CollectWindow() time.Duration              { return 0 }
+func (t *mockTask) SetCollectWindow(time.Duration)            { return }
+func (t *mockTask) BaseConfig() *cdata.ConfigDataNode         { return nil }
+func (t *mockTask) SetBaseConfig(*cdata.ConfigDataNode)       { return }
+func (t *mockTask) SubscribedMetricTypes() []core.Metric      { return nil }
+
 type MockTaskManager struct{}
 
 func (m *MockTaskManager) GetTask(id string) (core.Task, error) {
@@ -129,12 +169,30 @@ func (m *MockTaskManager) CreateTask(
 		MyState:             "failed",
 		MyHref:              "http://localhost:8181/v2/tasks/MyTaskID"}, nil
 }
+func (m *MockTaskManager) CreateTaskWithContext(
+	ctx context.Context,
+	sch schedule.Schedule,
+	wmap *wmap.WorkflowMap,
+	start bool,
+	opts ...core.TaskOption) (core.Task, core.TaskErrors) {
+	return m.CreateTask(sch, wmap, start, opts...)
+}
 func (m *MockTaskManager) GetTasks() map[string]core.Task {
 	return taskCatalog
 }
 func (m *MockTaskManager) StartTask(id string) []serror.SnapError { return nil }
 func (m *MockTaskManager) StopTask(id string) []serror.SnapError  { return nil }
 func (m *MockTaskManager) RemoveTask(id string) error             { return nil }
+func (m *MockTaskManager) StartTaskWithContext(ctx context.Context, id string) []serror.SnapError {
+	return nil
+}
+func (m *MockTaskManager) StopTaskWithContext(ctx context.Context, id string) []serror.SnapError {
+	return nil
+}
+func (m *MockTaskManager) RemoveTaskWithContext(ctx context.Context, id string) error { return nil }
+func (m *MockTaskManager) AuditLog(filter core.AuditFilter) []core.AuditEntry {
+	return []core.AuditEntry{}
+}
 func (m *MockTaskManager) WatchTask(id string, handler core.TaskWatcherHandler) (core.TaskWatcherCloser, error) {
 	return nil, nil
 }
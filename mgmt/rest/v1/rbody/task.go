@@ -223,6 +223,17 @@ func assertSchedule(s schedule.Schedule, t *AddScheduledTask) {
 			Interval:       v.Interval.String(),
 			StartTimestamp: v.StartTime,
 			StopTimestamp:  v.StopTime,
+			AlignTimestamp: v.AlignTime,
+		}
+		if v.Jitter > 0 {
+			seed := v.JitterSeed()
+			t.Schedule.Jitter = v.Jitter.String()
+			t.Schedule.JitterSeed = &seed
+		}
+		if v.HasDailyWindow() {
+			t.Schedule.DailyStart = core.FormatDailyTime(v.DailyStart)
+			t.Schedule.DailyEnd = core.FormatDailyTime(v.DailyEnd)
+			t.Schedule.Timezone = v.Location().String()
 		}
 		return
 	case *schedule.CronSchedule:
@@ -231,6 +242,16 @@ func assertSchedule(s schedule.Schedule, t *AddScheduledTask) {
 			Interval: v.Entry(),
 		}
 		return
+	case *schedule.RunOnceSchedule:
+		t.Schedule = &core.Schedule{
+			Type: "once",
+		}
+		if !v.At.IsZero() {
+			t.Schedule.StartTimestamp = &v.At
+		}
+		return
+	default:
+		t.Schedule = core.ScheduleFromSchedule(s)
 	}
 }
 
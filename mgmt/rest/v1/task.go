@@ -30,6 +30,8 @@ import (
 	log "github.com/Sirupsen/logrus"
 	"github.com/intelsdi-x/snap/core"
 	"github.com/intelsdi-x/snap/mgmt/rest/v1/rbody"
+	"github.com/intelsdi-x/snap/pkg/schedule"
+	"github.com/intelsdi-x/snap/scheduler/wmap"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -42,11 +44,18 @@ var (
 	ErrTaskDisabledNotRunnable = errors.New("Task is disabled. Cannot be started")
 	ErrNoActionSpecified       = errors.New("No action was specified in the request")
 	ErrWrongAction             = errors.New("Wrong action requested")
+	ErrTaskNotAuthorized       = errors.New("Task operation not authorized")
 )
 
 func (s *apiV1) addTask(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	task, err := core.CreateTaskFromContent(r.Body, nil, s.taskManager.CreateTask)
+	task, err := core.CreateTaskFromContent(r.Body, nil, func(sch schedule.Schedule, wfMap *wmap.WorkflowMap, start bool, opts ...core.TaskOption) (core.Task, core.TaskErrors) {
+		return s.taskManager.CreateTaskWithContext(r.Context(), sch, wfMap, start, opts...)
+	})
 	if err != nil {
+		if strings.Contains(err.Error(), ErrTaskNotAuthorized.Error()) {
+			rbody.Write(403, rbody.FromError(err), w)
+			return
+		}
 		rbody.Write(500, rbody.FromError(err), w)
 		return
 	}
@@ -192,7 +201,7 @@ func (s *apiV1) watchTask(w http.ResponseWriter, r *http.Request, p httprouter.P
 
 func (s *apiV1) startTask(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	id := p.ByName("id")
-	errs := s.taskManager.StartTask(id)
+	errs := s.taskManager.StartTaskWithContext(r.Context(), id)
 	if errs != nil {
 		if strings.Contains(errs[0].Error(), ErrTaskNotFound.Error()) {
 			rbody.Write(404, rbody.FromSnapErrors(errs), w)
@@ -202,6 +211,10 @@ func (s *apiV1) startTask(w http.ResponseWriter, r *http.Request, p httprouter.P
 			rbody.Write(409, rbody.FromSnapErrors(errs), w)
 			return
 		}
+		if strings.Contains(errs[0].Error(), ErrTaskNotAuthorized.Error()) {
+			rbody.Write(403, rbody.FromSnapErrors(errs), w)
+			return
+		}
 		rbody.Write(500, rbody.FromSnapErrors(errs), w)
 		return
 	}
@@ -211,12 +224,16 @@ func (s *apiV1) startTask(w http.ResponseWriter, r *http.Request, p httprouter.P
 
 func (s *apiV1) stopTask(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	id := p.ByName("id")
-	errs := s.taskManager.StopTask(id)
+	errs := s.taskManager.StopTaskWithContext(r.Context(), id)
 	if errs != nil {
 		if strings.Contains(errs[0].Error(), ErrTaskNotFound.Error()) {
 			rbody.Write(404, rbody.FromSnapErrors(errs), w)
 			return
 		}
+		if strings.Contains(errs[0].Error(), ErrTaskNotAuthorized.Error()) {
+			rbody.Write(403, rbody.FromSnapErrors(errs), w)
+			return
+		}
 		rbody.Write(500, rbody.FromSnapErrors(errs), w)
 		return
 	}
@@ -225,12 +242,16 @@ func (s *apiV1) stopTask(w http.ResponseWriter, r *http.Request, p httprouter.Pa
 
 func (s *apiV1) removeTask(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	id := p.ByName("id")
-	err := s.taskManager.RemoveTask(id)
+	err := s.taskManager.RemoveTaskWithContext(r.Context(), id)
 	if err != nil {
 		if strings.Contains(err.Error(), ErrTaskNotFound.Error()) {
 			rbody.Write(404, rbody.FromError(err), w)
 			return
 		}
+		if strings.Contains(err.Error(), ErrTaskNotAuthorized.Error()) {
+			rbody.Write(403, rbody.FromError(err), w)
+			return
+		}
 		rbody.Write(500, rbody.FromError(err), w)
 		return
 	}
@@ -265,6 +265,7 @@ func (s *apiV2) GetRoutes() []api.Route {
 		//
 		// Responses:
 		// 201: TaskResponse
+		// 403: ErrorResponse
 		// 500: ErrorResponse
 		// 401: UnauthResponse
 		api.Route{Method: "POST", Path: prefix + "/tasks", Handle: s.addTask},
@@ -285,6 +286,7 @@ func (s *apiV2) GetRoutes() []api.Route {
 		// Responses:
 		// 204: TaskResponse
 		// 400: ErrorResponse
+		// 403: ErrorResponse
 		// 409: ErrorResponse
 		// 500: ErrorResponse
 		// 401: UnauthResponse
@@ -302,10 +304,28 @@ func (s *apiV2) GetRoutes() []api.Route {
 		//
 		// Responses:
 		// 204: TaskResponse
+		// 403: ErrorResponse
 		// 404: ErrorResponse
 		// 500: TaskErrorResponse
 		// 401: UnauthResponse
 		api.Route{Method: "DELETE", Path: prefix + "/tasks/:id", Handle: s.removeTask},
+		// swagger:route GET /audit audit getAuditLog
+		//
+		// Get Audit Log
+		//
+		// The creations, updates, removals, starts and stops of the tasks,
+		// oldest first, with their caller and the changes of the task manifests.
+		//
+		// Produces:
+		// application/json
+		//
+		// Schemes: http, https
+		//
+		// Responses:
+		// 200: AuditLogResponse
+		// 400: ErrorResponse
+		// 401: UnauthResponse
+		api.Route{Method: "GET", Path: prefix + "/audit", Handle: s.getAuditLog},
 	}
 	return routes
 }
@@ -0,0 +1,76 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/intelsdi-x/snap/core"
+	"github.com/julienschmidt/httprouter"
+)
+
+// AuditLogResponse returns the task management operations of the audit log.
+//
+// swagger:response AuditLogResponse
+type AuditLogResp struct {
+	// in: body
+	Body struct {
+		Entries []core.AuditEntry `json:"entries"`
+	}
+}
+
+// AuditLogParams defines the query parameters selecting the entries of the audit log.
+//
+// swagger:parameters getAuditLog
+type AuditLogParams struct {
+	// in: query
+	Task string `json:"task"`
+	// in: query
+	Caller string `json:"caller"`
+	// in: query
+	Operation string `json:"operation"`
+	// RFC 3339 time of the oldest entries selected
+	//
+	// in: query
+	Since string `json:"since"`
+}
+
+type AuditLogResponse struct {
+	Entries []core.AuditEntry `json:"entries"`
+}
+
+func (s *apiV2) getAuditLog(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	q := r.URL.Query()
+	filter := core.AuditFilter{
+		TaskID:    q.Get("task"),
+		Caller:    q.Get("caller"),
+		Operation: core.AuditOperation(q.Get("operation")),
+	}
+	if since := q.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			Write(400, FromError(err), w)
+			return
+		}
+		filter.Since = t
+	}
+	Write(200, AuditLogResponse{Entries: s.taskManager.AuditLog(filter)}, w)
+}
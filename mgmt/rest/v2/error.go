@@ -31,6 +31,7 @@ const (
 	ErrPluginAlreadyLoaded     = "plugin is already loaded"
 	ErrTaskNotFound            = "task not found"
 	ErrTaskDisabledNotRunnable = "task is disabled"
+	ErrTaskNotAuthorized       = "operation not authorized"
 )
 
 var (
@@ -20,12 +20,16 @@ limitations under the License.
 package v2
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/ghodss/yaml"
 	"github.com/intelsdi-x/snap/core"
 	"github.com/intelsdi-x/snap/core/serror"
 	"github.com/intelsdi-x/snap/pkg/schedule"
@@ -135,8 +139,28 @@ func (s *Task) CreationTime() time.Time {
 }
 
 func (s *apiV2) addTask(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	task, err := core.CreateTaskFromContent(r.Body, nil, s.taskManager.CreateTask)
+	// a task manifest is accepted in YAML as well as JSON
+	if isYAML(r.Header.Get("Content-Type")) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			Write(500, FromError(err), w)
+			return
+		}
+		js, err := yaml.YAMLToJSON(b)
+		if err != nil {
+			Write(400, FromError(err), w)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(js))
+	}
+	task, err := core.CreateTaskFromContent(r.Body, nil, func(sch schedule.Schedule, wfMap *wmap.WorkflowMap, start bool, opts ...core.TaskOption) (core.Task, core.TaskErrors) {
+		return s.taskManager.CreateTaskWithContext(r.Context(), sch, wfMap, start, opts...)
+	})
 	if err != nil {
+		if strings.Contains(err.Error(), ErrTaskNotAuthorized) {
+			Write(403, FromError(err), w)
+			return
+		}
 		Write(500, FromError(err), w)
 		return
 	}
@@ -188,9 +212,9 @@ func (s *apiV2) updateTaskState(w http.ResponseWriter, r *http.Request, p httpro
 				errs = append(errs, serror.New(err))
 			}
 		case "start":
-			errs = s.taskManager.StartTask(id)
+			errs = s.taskManager.StartTaskWithContext(r.Context(), id)
 		case "stop":
-			errs = s.taskManager.StopTask(id)
+			errs = s.taskManager.StopTaskWithContext(r.Context(), id)
 		default:
 			errs = append(errs, serror.New(ErrWrongAction))
 		}
@@ -208,6 +232,9 @@ func (s *apiV2) updateTaskState(w http.ResponseWriter, r *http.Request, p httpro
 		case ErrTaskDisabledNotRunnable:
 			statusCode = 409
 		}
+		if strings.Contains(errs[0].Error(), ErrTaskNotAuthorized) {
+			statusCode = 403
+		}
 		Write(statusCode, FromSnapErrors(errs), w)
 		return
 	}
@@ -216,18 +243,35 @@ func (s *apiV2) updateTaskState(w http.ResponseWriter, r *http.Request, p httpro
 
 func (s *apiV2) removeTask(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	id := p.ByName("id")
-	err := s.taskManager.RemoveTask(id)
+	err := s.taskManager.RemoveTaskWithContext(r.Context(), id)
 	if err != nil {
 		if strings.Contains(err.Error(), ErrTaskNotFound) {
 			Write(404, FromError(err), w)
 			return
 		}
+		if strings.Contains(err.Error(), ErrTaskNotAuthorized) {
+			Write(403, FromError(err), w)
+			return
+		}
 		Write(500, FromError(err), w)
 		return
 	}
 	Write(204, nil, w)
 }
 
+// isYAML returns true if the content type is one of the YAML media types
+func isYAML(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mt {
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return true
+	}
+	return false
+}
+
 func taskURI(host string, t core.Task) string {
 	return fmt.Sprintf("%s://%s/%s/tasks/%s", protocolPrefix, host, version, t.ID())
 }
@@ -267,6 +311,17 @@ func (t *Task) assertSchedule(s schedule.Schedule) {
 			Interval:       v.Interval.String(),
 			StartTimestamp: v.StartTime,
 			StopTimestamp:  v.StopTime,
+			AlignTimestamp: v.AlignTime,
+		}
+		if v.Jitter > 0 {
+			seed := v.JitterSeed()
+			t.Schedule.Jitter = v.Jitter.String()
+			t.Schedule.JitterSeed = &seed
+		}
+		if v.HasDailyWindow() {
+			t.Schedule.DailyStart = core.FormatDailyTime(v.DailyStart)
+			t.Schedule.DailyEnd = core.FormatDailyTime(v.DailyEnd)
+			t.Schedule.Timezone = v.Location().String()
 		}
 		return
 	case *schedule.CronSchedule:
@@ -275,5 +330,15 @@ func (t *Task) assertSchedule(s schedule.Schedule) {
 			Interval: v.Entry(),
 		}
 		return
+	case *schedule.RunOnceSchedule:
+		t.Schedule = &core.Schedule{
+			Type: "once",
+		}
+		if !v.At.IsZero() {
+			t.Schedule.StartTimestamp = &v.At
+		}
+		return
+	default:
+		t.Schedule = core.ScheduleFromSchedule(s)
 	}
 }
@@ -1,3 +1,4 @@
+//go:build legacy
 // +build legacy
 
 /*
@@ -32,6 +33,7 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/cdata"
 	"github.com/intelsdi-x/snap/core/serror"
 	"github.com/intelsdi-x/snap/mgmt/tribe/agreement"
 	"github.com/intelsdi-x/snap/pkg/schedule"
@@ -62,10 +64,22 @@ func (t *mockTask) SetID(string)                              { return }
 func (t *mockTask) MissedCount() uint                         { return 0 }
 func (t *mockTask) FailedCount() uint                         { return 0 }
 func (t *mockTask) LastFailureMessage() string                { return "" }
+func (t *mockTask) LastError() error                          { return nil }
 func (t *mockTask) LastRunTime() *time.Time                   { return nil }
 func (t *mockTask) CreationTime() *time.Time                  { return nil }
 func (t *mockTask) DeadlineDuration() time.Duration           { return 0 }
 func (t *mockTask) SetDeadlineDuration(time.Duration)         { return }
+func (t *mockTask) Timeout() time.Duration                    { return 0 }
+func (t *mockTask) SetTimeout(time.Duration)                  { return }
+func (t *mockTask) StopAt() time.Time                         { return time.Time{} }
+func (t *mockTask) LastRun() (time.Time, bool)                { return time.Time{}, false }
+func (t *mockTask) NextRun() (time.Time, bool)                { return time.Time{}, false }
+func (t *mockTask) NextFireTime() (time.Time, bool)           { return time.Time{}, false }
+func (t *mockTask) MaxConcurrent() int                        { return 1 }
+func (t *mockTask) SetMaxConcurrent(int)                      { return }
+func (t *mockTask) Retry() (int, time.Duration)               { return 0, 0 }
+func (t *mockTask) SetRetry(int, time.Duration)               { return }
+func (t *mockTask) SetStopAt(time.Time)                       { return }
 func (t *mockTask) SetTaskID(id string)                       { return }
 func (t *mockTask) SetStopOnFailure(int)                      { return }
 func (t *mockTask) GetStopOnFailure() int                     { return 0 }
@@ -76,6 +90,30 @@ func (t *mockTask) MaxFailures() int                          { return 10 }
 func (t *mockTask) MaxMetricsBuffer() int64                   { return 0 }
 func (t *mockTask) SetMaxMetricsBuffer(int64)                 {}
 func (t *mockTask) MaxCollectDuration() time.Duration         { return time.Second }
+func (t *mockTask) MissedFirePolicy() core.MissedFirePolicy   { return core.MissedFireSkip }
+func (t *mockTask) SetMissedFirePolicy(core.MissedFirePolicy) { return }
+func (t *mockTask) MaxCatchUp() int                           { return 0 }
+func (t *mockTask) SetMaxCatchUp(int)                         { return }
+func (t *mockTask) Priority() int                             { return 0 }
+func (t *mockTask) SetPriority(int)                           { return }
+func (t *mockTask) QueueFullPolicy() string                   { return "" }
+func (t *mockTask) SetQueueFullPolicy(string)                 { return }
+func (t *mockTask) MaxRuns() uint                             { return 0 }
+func (t *mockTask) SetMaxRuns(uint)                           { return }
+func (t *mockTask) ScheduledRuns() uint                       { return 0 }
+func (t *mockTask) SetFiredCount(uint, uint)                  { return }
+func (t *mockTask) Labels() map[string]string                 { return nil }
+func (t *mockTask) SetLabels(map[string]string)               { return }
+func (t *mockTask) Tenant() string                            { return "" }
+func (t *mockTask) SetTenant(string)                          { return }
+func (t *mockTask) Dependencies() ([]string, bool)            { return nil, false }
+func (t *mockTask) SetDependencies([]string, bool)            { return }
+func (t *mockTask) Stats() core.TaskStats                     { return core.TaskStats{} }
+func (t *mockTask) CollectWindow() time.Duration              { return 0 }
+func (t *mockTask) SetCollectWindow(time.Duration)            { return }
+func (t *mockTask) BaseConfig() *cdata.ConfigDataNode         { return nil }
+func (t *mockTask) SetBaseConfig(*cdata.ConfigDataNode)       { return }
+func (t *mockTask) SubscribedMetricTypes() []core.Metric      { return nil }
 func (t *mockTask) SetMaxCollectDuration(time.Duration)       {}
 
 func getTestConfig() *Config {
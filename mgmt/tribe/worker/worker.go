@@ -587,6 +587,18 @@ func getSchedule(s *core.Schedule) schedule.Schedule {
 		//todo
 		//return schedule.NewStreamingSchedule()
 	default:
+		if t, ok := core.LookupScheduleType(s.Type); ok {
+			sch, err := t.MakeSchedule(*s)
+			if err != nil {
+				logger.Error(err)
+				return nil
+			}
+			if err := sch.Validate(); err != nil {
+				logger.Error(err)
+				return nil
+			}
+			return sch
+		}
 		logger.Error("unknown schedule type")
 	}
 	return nil
@@ -149,6 +149,15 @@ func (c *ConfigTree) getAll(node *node, key []string, res *[]keyNode) []keyNode
 	return *res
 }
 
+// Root returns the first element of the namespaces of the tree, which is
+// shared by every node of the tree, or an empty string for an empty tree
+func (c *ConfigTree) Root() string {
+	if c.root == nil || len(c.root.keys) == 0 {
+		return ""
+	}
+	return c.root.keys[0]
+}
+
 // Get returns a tree node given the namespace
 func (c *ConfigTree) Get(ns []string) Node {
 	c.log(fmt.Sprintf("Get on ns (%s)\n", ns))
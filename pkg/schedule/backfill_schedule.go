@@ -0,0 +1,204 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+var (
+	// ErrInvalidBackfillWindow - Error message for the window of a backfill schedule must be greater than 0
+	ErrInvalidBackfillWindow = errors.New("Backfill window must be greater than 0")
+	// ErrBackfillInFuture - Error message for the range of a backfill schedule cannot end in the future
+	ErrBackfillInFuture = errors.New("Backfill range cannot end in the future")
+)
+
+// BackfillSchedule is a schedule iterating over a past time range in windows
+// of a fixed length, firing once for each window. CurrentWindow returns the
+// window the next firing collects, and the schedule only moves on to the
+// following window once Advance is called for it, so that a window failing
+// to be collected is collected again and a backfill restarted from its
+// Progress resumes from the first window not collected.
+type BackfillSchedule struct {
+	Start time.Time
+	End   time.Time
+	// Window is the length of the windows, the last one ending at End may
+	// be shorter
+	Window time.Duration
+	// Interval is the pause between two firings, the windows are collected
+	// back to back when zero
+	Interval time.Duration
+	state    ScheduleState
+
+	progressMutex sync.Mutex
+	// progress is the start of the first window not collected yet
+	progress time.Time
+	fireTime
+}
+
+// NewBackfillSchedule returns an instance of BackfillSchedule iterating over
+// the range from start to end in windows of the given length
+func NewBackfillSchedule(start, end time.Time, window, interval time.Duration) *BackfillSchedule {
+	return &BackfillSchedule{
+		Start:    start,
+		End:      end,
+		Window:   window,
+		Interval: interval,
+		progress: start,
+	}
+}
+
+// GetState returns ScheduleState of BackfillSchedule
+func (b *BackfillSchedule) GetState() ScheduleState {
+	return b.state
+}
+
+// Validate returns an error if the window or the interval is not valid, or if
+// the range does not end after it starts or ends in the future
+func (b *BackfillSchedule) Validate() error {
+	if b.Window <= 0 {
+		return ErrInvalidBackfillWindow
+	}
+	if b.Interval < 0 {
+		return ErrInvalidInterval
+	}
+	if !b.End.After(b.Start) {
+		return ErrStopBeforeStart
+	}
+	if b.End.After(now()) {
+		return ErrBackfillInFuture
+	}
+	// the schedule passed validation, set as active
+	b.state = Active
+	return nil
+}
+
+// Progress returns the start of the first window not collected yet, the end
+// of the range once every window is collected
+func (b *BackfillSchedule) Progress() time.Time {
+	b.progressMutex.Lock()
+	defer b.progressMutex.Unlock()
+	return b.progress
+}
+
+// SetProgress sets the start of the first window not collected yet, so that
+// a backfill resumes where it was, within the range
+func (b *BackfillSchedule) SetProgress(t time.Time) {
+	b.progressMutex.Lock()
+	defer b.progressMutex.Unlock()
+	switch {
+	case t.Before(b.Start):
+		t = b.Start
+	case t.After(b.End):
+		t = b.End
+	}
+	b.progress = t
+}
+
+// CurrentWindow returns the window the next firing collects. It returns
+// false once every window is collected.
+func (b *BackfillSchedule) CurrentWindow() (time.Time, time.Time, bool) {
+	b.progressMutex.Lock()
+	defer b.progressMutex.Unlock()
+	if !b.progress.Before(b.End) {
+		return time.Time{}, time.Time{}, false
+	}
+	end := b.progress.Add(b.Window)
+	if end.After(b.End) {
+		end = b.End
+	}
+	return b.progress, end, true
+}
+
+// Advance moves on past the window ending at end once it is collected
+func (b *BackfillSchedule) Advance(end time.Time) {
+	b.progressMutex.Lock()
+	defer b.progressMutex.Unlock()
+	if end.After(b.progress) {
+		b.progress = end
+	}
+	if b.progress.After(b.End) {
+		b.progress = b.End
+	}
+}
+
+// Wait blocks for the interval after the last firing, immediately for the
+// first one, and returns an active response. It returns an ended response
+// once every window is collected.
+func (b *BackfillSchedule) Wait(last time.Time) Response {
+	return b.WaitOrCancel(last, nil)
+}
+
+// WaitOrCancel waits as Wait does unless cancel is closed first, it returns
+// nil then
+func (b *BackfillSchedule) WaitOrCancel(last time.Time, cancel <-chan struct{}) Response {
+	if _, _, ok := b.CurrentWindow(); !ok {
+		logger.WithFields(log.Fields{
+			"_block": "backfill-wait",
+		}).Debug("backfill has ended")
+		b.state = Ended
+		b.setNextFireTime(time.Time{})
+		return &BackfillScheduleResponse{
+			state:    b.state,
+			lastTime: now(),
+		}
+	}
+	if !last.IsZero() && b.Interval > 0 {
+		if !b.sleepUntil(last.Add(b.Interval), cancel) {
+			return nil
+		}
+	}
+	return &BackfillScheduleResponse{
+		state:    b.state,
+		lastTime: now(),
+	}
+}
+
+// BackfillScheduleResponse is the response from BackfillSchedule
+// conforming to ScheduleResponse interface
+type BackfillScheduleResponse struct {
+	state    ScheduleState
+	lastTime time.Time
+}
+
+// State returns the state of the Schedule
+func (b *BackfillScheduleResponse) State() ScheduleState {
+	return b.state
+}
+
+// Error returns last error
+func (b *BackfillScheduleResponse) Error() error {
+	return nil
+}
+
+// Missed returns any missed intervals, a backfill collecting every window
+// does not miss any
+func (b *BackfillScheduleResponse) Missed() uint {
+	return 0
+}
+
+// LastTime returns the last response time
+func (b *BackfillScheduleResponse) LastTime() time.Time {
+	return b.lastTime
+}
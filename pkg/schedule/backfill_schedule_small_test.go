@@ -0,0 +1,91 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBackfillSchedule(t *testing.T) {
+	end := time.Now().Add(-time.Hour).Truncate(time.Hour)
+	start := end.Add(-150 * time.Minute)
+	Convey("a past range is valid", t, func() {
+		b := NewBackfillSchedule(start, end, time.Hour, 0)
+		So(b.Validate(), ShouldBeNil)
+		So(b.GetState(), ShouldEqual, Active)
+	})
+	Convey("an invalid range or window is invalid", t, func() {
+		So(NewBackfillSchedule(start, end, 0, 0).Validate(), ShouldEqual, ErrInvalidBackfillWindow)
+		So(NewBackfillSchedule(end, start, time.Hour, 0).Validate(), ShouldEqual, ErrStopBeforeStart)
+		So(NewBackfillSchedule(start, time.Now().Add(time.Hour), time.Hour, 0).Validate(), ShouldEqual, ErrBackfillInFuture)
+	})
+	Convey("iterates over the range in windows", t, func() {
+		b := NewBackfillSchedule(start, end, time.Hour, 0)
+		So(b.Validate(), ShouldBeNil)
+		var windows [][2]time.Time
+		for {
+			resp := b.Wait(time.Now())
+			if resp.State() == Ended {
+				break
+			}
+			from, to, ok := b.CurrentWindow()
+			So(ok, ShouldBeTrue)
+			windows = append(windows, [2]time.Time{from, to})
+			b.Advance(to)
+		}
+		So(windows, ShouldResemble, [][2]time.Time{
+			{start, start.Add(time.Hour)},
+			{start.Add(time.Hour), start.Add(2 * time.Hour)},
+			{start.Add(2 * time.Hour), end},
+		})
+		So(b.Progress(), ShouldResemble, end)
+		So(b.GetState(), ShouldEqual, Ended)
+	})
+	Convey("collects a window again until it is advanced past", t, func() {
+		b := NewBackfillSchedule(start, end, time.Hour, 0)
+		from, _, _ := b.CurrentWindow()
+		again, _, _ := b.CurrentWindow()
+		So(again, ShouldResemble, from)
+	})
+	Convey("resumes from its progress", t, func() {
+		b := NewBackfillSchedule(start, end, time.Hour, 0)
+		b.SetProgress(start.Add(2 * time.Hour))
+		from, to, ok := b.CurrentWindow()
+		So(ok, ShouldBeTrue)
+		So(from, ShouldResemble, start.Add(2*time.Hour))
+		So(to, ShouldResemble, end)
+		b.SetProgress(end.Add(time.Hour))
+		So(b.Progress(), ShouldResemble, end)
+	})
+	Convey("waits for the interval between two windows", t, func() {
+		b := NewBackfillSchedule(start, end, time.Hour, 100*time.Millisecond)
+		So(b.Validate(), ShouldBeNil)
+		before := time.Now()
+		b.Wait(time.Time{})
+		So(time.Since(before), ShouldBeLessThan, 50*time.Millisecond)
+		b.Wait(time.Now())
+		So(time.Since(before), ShouldBeGreaterThanOrEqualTo, 90*time.Millisecond)
+	})
+}
@@ -0,0 +1,132 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time of the schedules and of the scheduler firing
+// them. It is replaced with SetClock to control time in tests.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// After returns a channel receiving the current time once d has elapsed
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+var (
+	clockMutex   sync.RWMutex
+	currentClock Clock = realClock{}
+)
+
+// SetClock sets the clock of the schedules and of the scheduler, a nil
+// clock restores the wall clock.
+func SetClock(c Clock) {
+	clockMutex.Lock()
+	defer clockMutex.Unlock()
+	if c == nil {
+		c = realClock{}
+	}
+	currentClock = c
+}
+
+// CurrentClock returns the clock set with SetClock, the wall clock by default
+func CurrentClock() Clock {
+	clockMutex.RLock()
+	defer clockMutex.RUnlock()
+	return currentClock
+}
+
+func now() time.Time {
+	return CurrentClock().Now()
+}
+
+func since(t time.Time) time.Duration {
+	return now().Sub(t)
+}
+
+// ManualClock is a Clock which only moves when it is advanced
+type ManualClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []manualWaiter
+}
+
+type manualWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewManualClock returns a ManualClock set to t
+func NewManualClock(t time.Time) *ManualClock {
+	return &ManualClock{now: t}
+}
+
+// Now returns the time the clock was set or advanced to
+func (m *ManualClock) Now() time.Time {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.now
+}
+
+// After returns a channel receiving the time once the clock is advanced by d
+func (m *ManualClock) After(d time.Duration) <-chan time.Time {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- m.now
+		return ch
+	}
+	m.waiters = append(m.waiters, manualWaiter{at: m.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward by d, releasing the waiters whose time
+// has come
+func (m *ManualClock) Advance(d time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.now = m.now.Add(d)
+	waiting := m.waiters[:0]
+	for _, w := range m.waiters {
+		if w.at.After(m.now) {
+			waiting = append(waiting, w)
+			continue
+		}
+		w.ch <- m.now
+	}
+	m.waiters = waiting
+}
+
+// Waiters returns the number of callers of After waiting for the clock to
+// be advanced, so that a test can advance it once the schedule is waiting
+func (m *ManualClock) Waiters() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.waiters)
+}
@@ -0,0 +1,166 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// waitForWaiters blocks until a caller is waiting on the clock
+func waitForWaiters(c *ManualClock) {
+	for c.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestManualClock(t *testing.T) {
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	Convey("ManualClock", t, func() {
+		c := NewManualClock(start)
+		Convey("only moves when advanced", func() {
+			So(c.Now(), ShouldResemble, start)
+			c.Advance(time.Minute)
+			So(c.Now(), ShouldResemble, start.Add(time.Minute))
+		})
+		Convey("releases the waiters whose time has come", func() {
+			short := c.After(time.Second)
+			long := c.After(time.Minute)
+			So(c.Waiters(), ShouldEqual, 2)
+			c.Advance(time.Second)
+			So(<-short, ShouldResemble, start.Add(time.Second))
+			So(c.Waiters(), ShouldEqual, 1)
+			select {
+			case <-long:
+				t.Fatal("waiter released early")
+			default:
+			}
+			c.Advance(time.Hour)
+			So(<-long, ShouldResemble, start.Add(time.Hour+time.Second))
+			So(c.Waiters(), ShouldEqual, 0)
+		})
+		Convey("does not wait for no duration", func() {
+			So(<-c.After(0), ShouldResemble, start)
+		})
+	})
+	Convey("Schedules follow the clock set", t, func() {
+		c := NewManualClock(start)
+		SetClock(c)
+		defer SetClock(nil)
+		Convey("a windowed schedule fires on its interval", func() {
+			s := NewWindowedSchedule(time.Hour, nil, nil, 0)
+			So(s.Validate(), ShouldBeNil)
+			r := s.Wait(time.Time{})
+			So(r.LastTime(), ShouldResemble, start)
+
+			responses := make(chan Response)
+			go func() { responses <- s.Wait(r.LastTime()) }()
+			waitForWaiters(c)
+			So(s.NextFireTime(), ShouldResemble, start.Add(time.Hour))
+			c.Advance(time.Hour)
+			r = <-responses
+			So(r.LastTime(), ShouldResemble, start.Add(time.Hour))
+			So(r.Missed(), ShouldEqual, 0)
+		})
+		Convey("a windowed schedule counts the intervals missed across a clock jump", func() {
+			s := NewWindowedSchedule(time.Hour, nil, nil, 0)
+			So(s.Validate(), ShouldBeNil)
+			r := s.Wait(time.Time{})
+
+			responses := make(chan Response)
+			go func() { responses <- s.Wait(r.LastTime()) }()
+			waitForWaiters(c)
+			// the process is suspended through five intervals
+			c.Advance(5*time.Hour + 30*time.Minute)
+			r = <-responses
+			So(r.LastTime(), ShouldResemble, start.Add(5*time.Hour+30*time.Minute))
+			So(r.Missed(), ShouldEqual, 4)
+			// and the missed intervals are not counted again
+			go func() { responses <- s.Wait(r.LastTime()) }()
+			waitForWaiters(c)
+			c.Advance(time.Hour)
+			So((<-responses).Missed(), ShouldEqual, 0)
+		})
+		Convey("a jittered schedule counts the ticks missed across a clock jump", func() {
+			s := NewWindowedSchedule(time.Hour, nil, nil, 0)
+			s.SetJitter(time.Minute, 1)
+			So(s.Validate(), ShouldBeNil)
+			responses := make(chan Response)
+			go func() { responses <- s.Wait(time.Time{}) }()
+			waitForWaiters(c)
+			c.Advance(time.Minute)
+			r := <-responses
+
+			go func() { responses <- s.Wait(r.LastTime()) }()
+			waitForWaiters(c)
+			// the tick of the first hour fires late, the ticks of the second
+			// to the fifth hours were missed
+			c.Advance(5*time.Hour + 30*time.Minute)
+			r = <-responses
+			So(r.Missed(), ShouldEqual, 4)
+			go func() { responses <- s.Wait(r.LastTime()) }()
+			waitForWaiters(c)
+			So(s.NextFireTime(), ShouldHappenOnOrBetween, start.Add(6*time.Hour), start.Add(6*time.Hour+time.Minute))
+			c.Advance(time.Hour)
+			So((<-responses).Missed(), ShouldEqual, 0)
+		})
+		Convey("a windowed schedule gives up a cancelled wait", func() {
+			s := NewWindowedSchedule(time.Hour, nil, nil, 0)
+			s.SetJitter(time.Minute, 1)
+			So(s.Validate(), ShouldBeNil)
+			cancel := make(chan struct{})
+			responses := make(chan Response)
+			go func() { responses <- s.WaitOrCancel(time.Time{}, cancel) }()
+			waitForWaiters(c)
+			close(cancel)
+			So(<-responses, ShouldBeNil)
+			// the next wait starts over, the timer of the cancelled wait
+			// is still pending on the clock
+			go func() { responses <- s.Wait(time.Time{}) }()
+			for c.Waiters() < 2 {
+				time.Sleep(time.Millisecond)
+			}
+			So(s.NextFireTime(), ShouldHappenOnOrBetween, start, start.Add(time.Minute))
+			c.Advance(time.Minute)
+			So(<-responses, ShouldNotBeNil)
+		})
+		Convey("a windowed schedule ends at its stop time", func() {
+			stop := start.Add(90 * time.Minute)
+			s := NewWindowedSchedule(time.Hour, nil, &stop, 0)
+			So(s.Validate(), ShouldBeNil)
+			r := s.Wait(time.Time{})
+			So(r.State(), ShouldEqual, Active)
+
+			responses := make(chan Response)
+			go func() { responses <- s.Wait(r.LastTime()) }()
+			waitForWaiters(c)
+			c.Advance(time.Hour)
+			So((<-responses).State(), ShouldEqual, Active)
+			go func() { responses <- s.Wait(start.Add(time.Hour)) }()
+			waitForWaiters(c)
+			c.Advance(time.Hour)
+			So((<-responses).State(), ShouldEqual, Ended)
+		})
+	})
+}
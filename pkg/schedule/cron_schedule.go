@@ -20,6 +20,7 @@ package schedule
 
 import (
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/robfig/cron"
@@ -31,19 +32,44 @@ var ErrMissingCronEntry = errors.New("Cron entry is missing")
 // CronSchedule is a schedule that waits as long as specified in cron entry
 type CronSchedule struct {
 	entry    string
-	enabled  bool
 	state    ScheduleState
-	schedule *cron.Cron
+	location *time.Location
+	schedule cron.Schedule
+	fireTime
 }
 
-// NewCronSchedule creates and starts new cron schedule and returns an instance of CronSchedule
+// NewCronSchedule creates a new cron schedule evaluated in the local timezone
+// and returns an instance of CronSchedule
 func NewCronSchedule(entry string) *CronSchedule {
-	schedule := cron.New()
+	return NewCronScheduleInLocation(entry, time.Local)
+}
+
+// NewCronScheduleInLocation creates a new cron schedule whose entry is evaluated
+// in the given timezone and returns an instance of CronSchedule
+func NewCronScheduleInLocation(entry string, loc *time.Location) *CronSchedule {
+	if loc == nil {
+		loc = time.Local
+	}
 	return &CronSchedule{
 		entry:    entry,
-		schedule: schedule,
-		enabled:  false,
+		location: loc,
+	}
+}
+
+// ParseCronEntry parses a cron entry. Standard crontab entries with five
+// fields (minute, hour, day of month, month, day of week) fire at the
+// beginning of the minute. Entries with six fields are prefixed with a
+// seconds field. Descriptors like "@hourly" or "@every 1h30m" are also
+// accepted.
+func ParseCronEntry(entry string) (cron.Schedule, error) {
+	if strings.TrimSpace(entry) == "" {
+		return nil, ErrMissingCronEntry
+	}
+	entry = strings.TrimSpace(entry)
+	if fields := strings.Fields(entry); entry[0] != '@' && len(fields) == 5 {
+		entry = "0 " + entry
 	}
+	return cron.Parse(entry)
 }
 
 // Entry returns the cron schedule entry
@@ -51,6 +77,11 @@ func (c *CronSchedule) Entry() string {
 	return c.entry
 }
 
+// Location returns the timezone the cron schedule entry is evaluated in
+func (c *CronSchedule) Location() *time.Location {
+	return c.location
+}
+
 // GetState returns state of CronSchedule
 func (c *CronSchedule) GetState() ScheduleState {
 	return c.state
@@ -58,42 +89,58 @@ func (c *CronSchedule) GetState() ScheduleState {
 
 // Validate returns error if cron entry doesn't match crontab format
 func (c *CronSchedule) Validate() error {
-	if c.entry == "" {
-		return ErrMissingCronEntry
-	}
-	_, err := cron.Parse(c.entry)
+	s, err := ParseCronEntry(c.entry)
 	if err != nil {
 		return err
 	}
+	c.schedule = s
 	return nil
 }
 
+// FireTimes returns up to max of the points in time the schedule fires at
+// after from and up to to. It returns none if the cron entry is not valid.
+func (c *CronSchedule) FireTimes(from, to time.Time, max int) []time.Time {
+	s := c.schedule
+	if s == nil {
+		var err error
+		if s, err = ParseCronEntry(c.entry); err != nil {
+			return nil
+		}
+	}
+	var times []time.Time
+	for next := s.Next(from.In(c.location)); len(times) < max && !next.IsZero() && !next.After(to); next = s.Next(next) {
+		times = append(times, next)
+	}
+	return times
+}
+
 // Wait waits as long as specified in cron entry
 func (c *CronSchedule) Wait(last time.Time) Response {
+	return c.WaitOrCancel(last, nil)
+}
+
+// WaitOrCancel waits as Wait does unless cancel is closed first, it returns
+// nil then
+func (c *CronSchedule) WaitOrCancel(last time.Time, cancel <-chan struct{}) Response {
 	var err error
-	now := time.Now()
+	now := CurrentClock().Now().In(c.location)
 
 	// first run
 	if (last == time.Time{}) {
 		last = now
 	}
-	// schedule not enabled, either due to first run or invalid cron entry
-	if !c.enabled {
-		err = c.schedule.AddFunc(c.entry, func() {})
-		if err != nil {
+	// schedule not parsed yet, either due to first run or invalid cron entry
+	if c.schedule == nil {
+		if err = c.Validate(); err != nil {
 			c.state = Error
-		} else {
-			c.enabled = true
 		}
 	}
 
 	var misses uint
-	if c.enabled {
-		s := c.schedule.Entries()[0].Schedule
-
+	if c.schedule != nil {
 		// calculate misses
-		for next := last; next.Before(now); {
-			next = s.Next(next)
+		for next := last.In(c.location); next.Before(now); {
+			next = c.schedule.Next(next)
 			if next.After(now) {
 				break
 			}
@@ -101,15 +148,16 @@ func (c *CronSchedule) Wait(last time.Time) Response {
 		}
 
 		// wait
-		waitTime := s.Next(now)
-		time.Sleep(waitTime.Sub(now))
+		if !c.sleepUntil(c.schedule.Next(now), cancel) {
+			return nil
+		}
 	}
 
 	return &CronScheduleResponse{
 		state:    c.GetState(),
 		err:      err,
 		missed:   misses,
-		lastTime: time.Now(),
+		lastTime: CurrentClock().Now(),
 	}
 }
 
@@ -41,6 +41,38 @@ func TestCronSchedule(t *testing.T) {
 			e := c.Validate()
 			So(e, ShouldEqual, ErrMissingCronEntry)
 		})
+		Convey("valid standard five fields cron entry", func() {
+			i := "*/5 * * * *"
+			c := NewCronSchedule(i)
+			e := c.Validate()
+			So(e, ShouldBeNil)
+			Convey("fires at the beginning of the minute", func() {
+				s, err := ParseCronEntry(i)
+				So(err, ShouldBeNil)
+				next := s.Next(time.Date(2017, 6, 1, 10, 1, 30, 0, time.UTC))
+				So(next, ShouldResemble, time.Date(2017, 6, 1, 10, 5, 0, 0, time.UTC))
+			})
+		})
+		Convey("valid cron entry firing on February 29th", func() {
+			i := "0 0 29 2 *"
+			c := NewCronSchedule(i)
+			e := c.Validate()
+			So(e, ShouldBeNil)
+			s, err := ParseCronEntry(i)
+			So(err, ShouldBeNil)
+			next := s.Next(time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC))
+			So(next, ShouldResemble, time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC))
+		})
+		Convey("cron entry in a given timezone", func() {
+			loc := time.FixedZone("UTC+2", 2*60*60)
+			c := NewCronScheduleInLocation("0 12 * * *", loc)
+			So(c.Validate(), ShouldBeNil)
+			So(c.Location(), ShouldEqual, loc)
+			Convey("defaults to the local timezone", func() {
+				So(NewCronSchedule("0 12 * * *").Location(), ShouldEqual, time.Local)
+				So(NewCronScheduleInLocation("0 12 * * *", nil).Location(), ShouldEqual, time.Local)
+			})
+		})
 		Convey("invalid cron entry", func() {
 			i := "invalid cron entry"
 			c := NewCronSchedule(i)
@@ -0,0 +1,62 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"sync"
+)
+
+// DependentSchedule is a trigger schedule fired by the scheduler once the
+// tasks a task depends on have completed their runs, instead of by a send on
+// a channel held by its creator.
+type DependentSchedule struct {
+	*TriggerSchedule
+	mutex   sync.Mutex
+	trigger chan<- struct{}
+	ended   bool
+}
+
+// NewDependentSchedule returns a DependentSchedule
+func NewDependentSchedule() *DependentSchedule {
+	s, trigger := NewTriggerSchedule()
+	return &DependentSchedule{
+		TriggerSchedule: s,
+		trigger:         trigger,
+	}
+}
+
+// Trigger fires the schedule, unless it has ended
+func (s *DependentSchedule) Trigger() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.ended {
+		s.trigger <- struct{}{}
+	}
+}
+
+// End ends the schedule, the task waiting on it ends
+func (s *DependentSchedule) End() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.ended {
+		s.ended = true
+		close(s.trigger)
+	}
+}
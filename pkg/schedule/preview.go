@@ -0,0 +1,67 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"errors"
+	"time"
+)
+
+// MaxPreviewFireTimes is the most fire times returned by PreviewSchedule
+const MaxPreviewFireTimes = 10000
+
+var (
+	// ErrPreviewNotSupported - Error message for a schedule whose fire times cannot be computed ahead
+	ErrPreviewNotSupported = errors.New("Schedule cannot be previewed")
+	// ErrPreviewTruncated - Error message for a preview holding more than MaxPreviewFireTimes fire times
+	ErrPreviewTruncated = errors.New("Preview truncated to the maximum number of fire times")
+)
+
+// Previewer is a Schedule whose fire times can be computed ahead, without
+// waiting on it
+type Previewer interface {
+	Schedule
+	// FireTimes returns up to max of the points in time the schedule fires
+	// at from from to to, both included, for a task started on it at from.
+	// The schedule is left unchanged.
+	FireTimes(from, to time.Time, max int) []time.Time
+}
+
+// PreviewSchedule returns the points in time the schedule fires at from
+// from to to, both included, for a task started on it at from, so that they
+// can be shown before the task is created. The offsets of the jitter of a
+// windowed schedule are left out. It returns ErrPreviewNotSupported for the
+// schedules which do not fire on time, such as the streaming, trigger or
+// dependent schedules, and the first MaxPreviewFireTimes along with
+// ErrPreviewTruncated if the range holds more.
+func PreviewSchedule(sch Schedule, from, to time.Time) ([]time.Time, error) {
+	if to.Before(from) {
+		return nil, ErrStopBeforeStart
+	}
+	p, ok := sch.(Previewer)
+	if !ok {
+		return nil, ErrPreviewNotSupported
+	}
+	times := p.FireTimes(from, to, MaxPreviewFireTimes+1)
+	if len(times) > MaxPreviewFireTimes {
+		return times[:MaxPreviewFireTimes], ErrPreviewTruncated
+	}
+	return times, nil
+}
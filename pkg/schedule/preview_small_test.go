@@ -0,0 +1,96 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPreviewSchedule(t *testing.T) {
+	from := time.Date(2017, 3, 1, 10, 0, 0, 0, time.UTC)
+	Convey("PreviewSchedule", t, func() {
+		Convey("returns the ticks of a windowed schedule", func() {
+			times, err := PreviewSchedule(NewWindowedSchedule(time.Minute, nil, nil, 0), from, from.Add(3*time.Minute))
+			So(err, ShouldBeNil)
+			So(times, ShouldResemble, []time.Time{from, from.Add(time.Minute), from.Add(2 * time.Minute), from.Add(3 * time.Minute)})
+		})
+		Convey("starts a windowed schedule on its start time and stops it after its count", func() {
+			start := from.Add(time.Hour)
+			times, err := PreviewSchedule(NewWindowedSchedule(time.Minute, &start, nil, 2), from, from.Add(2*time.Hour))
+			So(err, ShouldBeNil)
+			So(times, ShouldResemble, []time.Time{start, start.Add(time.Minute), start.Add(2 * time.Minute)})
+		})
+		Convey("aligns the ticks of an aligned windowed schedule", func() {
+			s := NewWindowedSchedule(10*time.Minute, nil, nil, 0)
+			align := from.Add(-time.Hour + 5*time.Minute)
+			s.AlignTime = &align
+			times, err := PreviewSchedule(s, from, from.Add(20*time.Minute))
+			So(err, ShouldBeNil)
+			So(times, ShouldResemble, []time.Time{from.Add(5 * time.Minute), from.Add(15 * time.Minute)})
+		})
+		Convey("skips the ticks outside of the daily window", func() {
+			s := NewDailyWindowedSchedule(time.Hour, nil, nil, 9*time.Hour, 11*time.Hour)
+			s.SetLocation(time.UTC)
+			times, err := PreviewSchedule(s, from, from.Add(23*time.Hour+30*time.Minute))
+			So(err, ShouldBeNil)
+			So(times, ShouldResemble, []time.Time{from, from.Add(23 * time.Hour)})
+		})
+		Convey("returns the stretched ticks of a stretched windowed schedule", func() {
+			s := NewWindowedSchedule(time.Minute, nil, nil, 0)
+			s.SetStretch(2)
+			times, err := PreviewSchedule(s, from, from.Add(3*time.Minute))
+			So(err, ShouldBeNil)
+			So(times, ShouldResemble, []time.Time{from, from.Add(2 * time.Minute)})
+		})
+		Convey("returns the fire times of a cron schedule", func() {
+			s := NewCronScheduleInLocation("0 */30 * * * *", time.UTC)
+			times, err := PreviewSchedule(s, from, from.Add(time.Hour))
+			So(err, ShouldBeNil)
+			So(times, ShouldResemble, []time.Time{from.Add(30 * time.Minute), from.Add(time.Hour)})
+		})
+		Convey("returns the run time of a run once schedule", func() {
+			at := from.Add(time.Minute)
+			times, err := PreviewSchedule(NewRunOnceSchedule(at), from, from.Add(time.Hour))
+			So(err, ShouldBeNil)
+			So(times, ShouldResemble, []time.Time{at})
+			times, err = PreviewSchedule(NewRunOnceSchedule(at), from, from.Add(time.Second))
+			So(err, ShouldBeNil)
+			So(times, ShouldBeEmpty)
+		})
+		Convey("truncates the long previews", func() {
+			times, err := PreviewSchedule(NewWindowedSchedule(time.Second, nil, nil, 0), from, from.Add(24*time.Hour))
+			So(err, ShouldEqual, ErrPreviewTruncated)
+			So(len(times), ShouldEqual, MaxPreviewFireTimes)
+		})
+		Convey("does not preview the schedules which do not fire on time", func() {
+			_, err := PreviewSchedule(NewStreamingSchedule(), from, from.Add(time.Hour))
+			So(err, ShouldEqual, ErrPreviewNotSupported)
+		})
+		Convey("rejects a range ending before it starts", func() {
+			_, err := PreviewSchedule(NewWindowedSchedule(time.Minute, nil, nil, 0), from, from.Add(-time.Minute))
+			So(err, ShouldEqual, ErrStopBeforeStart)
+		})
+	})
+}
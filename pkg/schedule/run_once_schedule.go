@@ -0,0 +1,157 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"errors"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// DefaultRunOnceGracePeriod - The default time a run once schedule is still valid after its run time
+	DefaultRunOnceGracePeriod = time.Minute
+)
+
+var (
+	// ErrRunTimeInPast - Error message for the run time of a run once schedule is in the past
+	ErrRunTimeInPast = errors.New("Run time is in the past")
+	// ErrAlreadyRun - Error message for the run once schedule has already fired
+	ErrAlreadyRun = errors.New("Run once schedule has already fired")
+)
+
+// RunOnceSchedule is a schedule that fires a single time at a specific point in time
+type RunOnceSchedule struct {
+	At time.Time
+	// GracePeriod is how long after `At` the schedule is still considered valid
+	GracePeriod time.Duration
+	state       ScheduleState
+	fired       bool
+	fireTime
+}
+
+// NewRunOnceSchedule returns an instance of RunOnceSchedule firing at the given time.
+// A zero time means the schedule fires as soon as the task is started.
+func NewRunOnceSchedule(at time.Time) *RunOnceSchedule {
+	return &RunOnceSchedule{
+		At:          at,
+		GracePeriod: DefaultRunOnceGracePeriod,
+	}
+}
+
+// GetState returns ScheduleState of RunOnceSchedule
+func (r *RunOnceSchedule) GetState() ScheduleState {
+	return r.state
+}
+
+// Validate returns an error if the schedule has already fired or if the run
+// time is further in the past than the grace period
+func (r *RunOnceSchedule) Validate() error {
+	if r.fired {
+		return ErrAlreadyRun
+	}
+	if !r.At.IsZero() && now().After(r.At.Add(r.GracePeriod)) {
+		return ErrRunTimeInPast
+	}
+	// the schedule passed validation, set as active
+	r.state = Active
+	return nil
+}
+
+// FireTimes returns the run time if it is up to to, from if the run time has
+// passed or is not set, as the schedule then fires once the task is started.
+// It returns none once the schedule has fired.
+func (r *RunOnceSchedule) FireTimes(from, to time.Time, max int) []time.Time {
+	if r.fired || max < 1 {
+		return nil
+	}
+	at := r.At
+	if at.Before(from) {
+		at = from
+	}
+	if at.After(to) {
+		return nil
+	}
+	return []time.Time{at}
+}
+
+// Wait blocks until the run time on the first call and returns an active
+// response. Every following call returns an ended response immediately.
+func (r *RunOnceSchedule) Wait(last time.Time) Response {
+	return r.WaitOrCancel(last, nil)
+}
+
+// WaitOrCancel waits as Wait does unless cancel is closed first, it returns
+// nil then and the schedule still fires on the next call
+func (r *RunOnceSchedule) WaitOrCancel(last time.Time, cancel <-chan struct{}) Response {
+	if r.fired {
+		logger.WithFields(log.Fields{
+			"_block": "run-once-wait",
+		}).Debug("schedule has ended")
+		r.state = Ended
+		r.setNextFireTime(time.Time{})
+		return &RunOnceScheduleResponse{
+			state:    r.state,
+			lastTime: now(),
+		}
+	}
+	if wait := r.At.Sub(now()); wait > 0 {
+		logger.WithFields(log.Fields{
+			"_block":         "run-once-wait",
+			"sleep-duration": wait,
+		}).Debug("Waiting for run time")
+		if !r.sleepUntil(r.At, cancel) {
+			return nil
+		}
+	}
+	r.fired = true
+	return &RunOnceScheduleResponse{
+		state:    r.state,
+		lastTime: now(),
+	}
+}
+
+// RunOnceScheduleResponse is the response from RunOnceSchedule
+// conforming to ScheduleResponse interface
+type RunOnceScheduleResponse struct {
+	state    ScheduleState
+	lastTime time.Time
+}
+
+// State returns the state of the Schedule
+func (r *RunOnceScheduleResponse) State() ScheduleState {
+	return r.state
+}
+
+// Error returns last error
+func (r *RunOnceScheduleResponse) Error() error {
+	return nil
+}
+
+// Missed returns any missed intervals
+func (r *RunOnceScheduleResponse) Missed() uint {
+	return 0
+}
+
+// LastTime returns the last response time
+func (r *RunOnceScheduleResponse) LastTime() time.Time {
+	return r.lastTime
+}
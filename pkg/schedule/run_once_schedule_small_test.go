@@ -0,0 +1,63 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRunOnceSchedule(t *testing.T) {
+	Convey("run time in the future is valid", t, func() {
+		r := NewRunOnceSchedule(time.Now().Add(time.Second))
+		So(r.Validate(), ShouldBeNil)
+		So(r.GetState(), ShouldEqual, Active)
+	})
+	Convey("run time within the grace period is valid", t, func() {
+		r := NewRunOnceSchedule(time.Now().Add(-time.Second))
+		So(r.Validate(), ShouldBeNil)
+	})
+	Convey("run time before the grace period is invalid", t, func() {
+		r := NewRunOnceSchedule(time.Now().Add(-time.Second))
+		r.GracePeriod = time.Millisecond * 100
+		So(r.Validate(), ShouldEqual, ErrRunTimeInPast)
+	})
+	Convey("test Wait()", t, func() {
+		r := NewRunOnceSchedule(time.Now().Add(time.Millisecond * 100))
+		So(r.Validate(), ShouldBeNil)
+
+		before := time.Now()
+		resp := r.Wait(time.Time{})
+		So(time.Since(before), ShouldBeGreaterThanOrEqualTo, time.Millisecond*90)
+		So(resp.State(), ShouldEqual, Active)
+		So(resp.Missed(), ShouldEqual, 0)
+
+		Convey("ends after the first firing", func() {
+			resp := r.Wait(resp.LastTime())
+			So(resp.State(), ShouldEqual, Ended)
+			So(r.GetState(), ShouldEqual, Ended)
+			So(r.Validate(), ShouldEqual, ErrAlreadyRun)
+		})
+	})
+}
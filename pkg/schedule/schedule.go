@@ -21,6 +21,7 @@ package schedule
 
 import (
 	"errors"
+	"sync"
 	"time"
 )
 
@@ -31,6 +32,10 @@ var (
 	ErrInvalidStopTime = errors.New("Stop time is in the past")
 	// ErrStopBeforeStart - Error message for the stop time cannot occur before start time
 	ErrStopBeforeStart = errors.New("Stop time cannot occur before start time")
+	// ErrInvalidJitter - Error message for the schedule jitter cannot be negative
+	ErrInvalidJitter = errors.New("Jitter cannot be negative")
+	// ErrInvalidDailyWindow - Error message for the daily window must end after it starts within a day
+	ErrInvalidDailyWindow = errors.New("Daily window must end after it starts within a day")
 )
 
 // ScheduleState int type
@@ -53,6 +58,19 @@ type Schedule interface {
 	Validate() error
 	// Blocks until time to fire and returns a schedule.Response
 	Wait(time.Time) Response
+	// Returns the point in time the schedule is waiting to fire at, or the
+	// zero time if it is not known
+	NextFireTime() time.Time
+}
+
+// CancellableSchedule is a Schedule whose waiting can be given up, so that a
+// task stopped while waiting on its schedule does not leave a wait running
+// on the schedule when it is started again
+type CancellableSchedule interface {
+	Schedule
+	// WaitOrCancel blocks as Wait does until it is time to fire, or until
+	// cancel is closed in which case it returns nil
+	WaitOrCancel(last time.Time, cancel <-chan struct{}) Response
 }
 
 // Response interface defines the behavior of schedule response
@@ -67,15 +85,49 @@ type Response interface {
 	LastTime() time.Time
 }
 
-func waitOnInterval(last time.Time, i time.Duration) (uint, time.Time) {
+// fireTime records the point in time a schedule waits to fire at. It is
+// guarded since it is read while the schedule is waiting.
+type fireTime struct {
+	mutex sync.Mutex
+	next  time.Time
+}
+
+// NextFireTime returns the point in time the schedule fires next
+func (f *fireTime) NextFireTime() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.next
+}
+
+func (f *fireTime) setNextFireTime(t time.Time) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.next = t
+}
+
+// sleepUntil records t as the next fire time and blocks until then, or until
+// cancel is closed in which case it returns false
+func (f *fireTime) sleepUntil(t time.Time, cancel <-chan struct{}) bool {
+	f.setNextFireTime(t)
+	select {
+	case <-CurrentClock().After(t.Sub(now())):
+		return true
+	case <-cancel:
+		return false
+	}
+}
+
+// nextOnInterval returns the number of intervals missed since last and the
+// point in time the next interval fires at
+func nextOnInterval(last time.Time, i time.Duration) (uint, time.Time) {
 	// first run
 	if (last == time.Time{}) {
 		// for the first run, do not wait on interval
 		// and schedule workflow execution immediately
-		return uint(0), time.Now()
+		return uint(0), now()
 	}
 	// Get the difference in time.Duration since last in nanoseconds (int64)
-	timeDiff := time.Since(last).Nanoseconds()
+	timeDiff := since(last).Nanoseconds()
 	// cache our schedule interval in nanoseconds
 	nanoInterval := i.Nanoseconds()
 	// use modulo operation to obtain the remainder of time over last interval
@@ -83,7 +135,38 @@ func waitOnInterval(last time.Time, i time.Duration) (uint, time.Time) {
 	// subtract remainder from
 	missed := (timeDiff - remainder) / nanoInterval // timeDiff.Nanoseconds() % s.Interval.Nanoseconds()
 	waitDuration := nanoInterval - remainder
-	// Wait until predicted interval fires
-	time.Sleep(time.Duration(waitDuration))
-	return uint(missed), time.Now()
+	return uint(missed), now().Add(time.Duration(waitDuration))
+}
+
+// nextAlignedTick returns the first point in time not before t which lies
+// a whole number of intervals after align
+func nextAlignedTick(align time.Time, i time.Duration, t time.Time) time.Time {
+	if !t.After(align) {
+		return align
+	}
+	nanoInterval := i.Nanoseconds()
+	n := (t.Sub(align).Nanoseconds() + nanoInterval - 1) / nanoInterval
+	return align.Add(time.Duration(n * nanoInterval))
+}
+
+// nextOnAlignedInterval returns the number of boundaries missed since last
+// and the next boundary of the intervals aligned to align
+func nextOnAlignedInterval(last, align time.Time, i time.Duration) (uint, time.Time) {
+	next := nextAlignedTick(align, i, now())
+	var missed uint
+	if (last != time.Time{}) {
+		// never fire twice on the same boundary
+		if !next.After(last) {
+			next = next.Add(i)
+		}
+		// the boundaries between the last firing and the next one were missed
+		if last.After(align) {
+			lastTick := last.Sub(align).Nanoseconds() / i.Nanoseconds()
+			nextTick := next.Sub(align).Nanoseconds() / i.Nanoseconds()
+			if nextTick-lastTick > 1 {
+				missed = uint(nextTick - lastTick - 1)
+			}
+		}
+	}
+	return missed, next
 }
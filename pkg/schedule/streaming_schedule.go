@@ -47,6 +47,17 @@ func (s *StreamingSchedule) Wait(last time.Time) Response {
 	return &StreamingScheduleResponse{}
 }
 
+// WaitOrCancel returns at once as Wait does
+func (s *StreamingSchedule) WaitOrCancel(last time.Time, cancel <-chan struct{}) Response {
+	return s.Wait(last)
+}
+
+// NextFireTime returns the zero time since a streaming schedule does not fire
+// at points in time
+func (s *StreamingSchedule) NextFireTime() time.Time {
+	return time.Time{}
+}
+
 // StreamingScheduleResponse a response from SimpleSchedule conforming to ScheduleResponse interface
 type StreamingScheduleResponse struct{}
 
@@ -0,0 +1,169 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// TriggerSchedule is a schedule firing whenever it is triggered by a send on
+// its trigger channel instead of at points in time. The schedule ends once
+// the trigger channel is closed.
+//
+// A send never waits for the task to be done firing: the triggers received
+// while the task fires are coalesced into a single firing once it is done.
+type TriggerSchedule struct {
+	mutex sync.Mutex
+	state ScheduleState
+	// triggered is the time of the last trigger
+	triggered time.Time
+	closed    bool
+	// changed is closed and replaced whenever the schedule is triggered or
+	// its trigger channel is closed
+	changed chan struct{}
+}
+
+// NewTriggerSchedule returns a TriggerSchedule along with the channel
+// triggering it
+func NewTriggerSchedule() (*TriggerSchedule, chan<- struct{}) {
+	s := &TriggerSchedule{
+		changed: make(chan struct{}),
+	}
+	trigger := make(chan struct{})
+	go s.receive(trigger)
+	return s, trigger
+}
+
+// receive records the triggers sent on the channel until it is closed
+func (s *TriggerSchedule) receive(trigger <-chan struct{}) {
+	for range trigger {
+		s.mutex.Lock()
+		s.triggered = now()
+		s.broadcast()
+		s.mutex.Unlock()
+	}
+	s.mutex.Lock()
+	s.closed = true
+	s.broadcast()
+	s.mutex.Unlock()
+}
+
+// broadcast wakes up the callers of Wait, it is called with the mutex held
+func (s *TriggerSchedule) broadcast() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// GetState returns the schedule state
+func (s *TriggerSchedule) GetState() ScheduleState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.state
+}
+
+// Validate always passes, a trigger schedule is valid at any time
+func (s *TriggerSchedule) Validate() error {
+	return nil
+}
+
+// Wait blocks until the schedule is triggered after last, or since Wait was
+// called for the first firing, and returns an active response. It returns an
+// ended response once the trigger channel is closed.
+func (s *TriggerSchedule) Wait(last time.Time) Response {
+	return s.WaitOrCancel(last, nil)
+}
+
+// WaitOrCancel waits as Wait does unless cancel is closed first, it returns
+// nil then
+func (s *TriggerSchedule) WaitOrCancel(last time.Time, cancel <-chan struct{}) Response {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if last.IsZero() {
+		last = now()
+	}
+	for {
+		// a trigger received before the channel was closed still fires
+		if s.triggered.After(last) {
+			return &TriggerScheduleResponse{
+				state:    s.state,
+				lastTime: s.triggered,
+			}
+		}
+		if s.closed {
+			logger.WithFields(log.Fields{
+				"_block": "trigger-wait",
+			}).Debug("schedule has ended")
+			s.state = Ended
+			return &TriggerScheduleResponse{
+				state:    s.state,
+				lastTime: now(),
+			}
+		}
+		changed := s.changed
+		s.mutex.Unlock()
+		var cancelled bool
+		select {
+		case <-changed:
+		case <-cancel:
+			cancelled = true
+		}
+		s.mutex.Lock()
+		if cancelled {
+			return nil
+		}
+	}
+}
+
+// NextFireTime returns the zero time since a trigger schedule does not fire
+// at points in time
+func (s *TriggerSchedule) NextFireTime() time.Time {
+	return time.Time{}
+}
+
+// TriggerScheduleResponse is the response from TriggerSchedule
+// conforming to ScheduleResponse interface
+type TriggerScheduleResponse struct {
+	state    ScheduleState
+	lastTime time.Time
+}
+
+// State returns the state of the Schedule
+func (t *TriggerScheduleResponse) State() ScheduleState {
+	return t.state
+}
+
+// Error returns last error
+func (t *TriggerScheduleResponse) Error() error {
+	return nil
+}
+
+// Missed returns any missed intervals, the triggers received while the task
+// fires are coalesced rather than missed
+func (t *TriggerScheduleResponse) Missed() uint {
+	return 0
+}
+
+// LastTime returns the time of the trigger the response fires on
+func (t *TriggerScheduleResponse) LastTime() time.Time {
+	return t.lastTime
+}
@@ -0,0 +1,112 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2017 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTriggerSchedule(t *testing.T) {
+	Convey("a trigger schedule", t, func() {
+		s, trigger := NewTriggerSchedule()
+		So(s.Validate(), ShouldBeNil)
+		So(s.GetState(), ShouldEqual, Active)
+		So(s.NextFireTime().IsZero(), ShouldBeTrue)
+		responses := make(chan Response, 1)
+		wait := func(last time.Time) {
+			go func() { responses <- s.Wait(last) }()
+		}
+		Convey("fires on each trigger", func() {
+			wait(time.Now())
+			select {
+			case <-responses:
+				t.Fatal("fired without being triggered")
+			case <-time.After(time.Millisecond * 20):
+			}
+			trigger <- struct{}{}
+			r := <-responses
+			So(r.State(), ShouldEqual, Active)
+			So(r.Missed(), ShouldEqual, 0)
+			So(r.LastTime().IsZero(), ShouldBeFalse)
+
+			wait(r.LastTime())
+			trigger <- struct{}{}
+			r2 := <-responses
+			So(r2.State(), ShouldEqual, Active)
+			So(r2.LastTime(), ShouldHappenAfter, r.LastTime())
+		})
+		Convey("coalesces the triggers received while firing", func() {
+			last := time.Now()
+			trigger <- struct{}{}
+			So(s.Wait(last).State(), ShouldEqual, Active)
+			// the task fires, meanwhile it is triggered twice
+			fired := time.Now()
+			trigger <- struct{}{}
+			trigger <- struct{}{}
+			time.Sleep(time.Millisecond * 10)
+			So(s.Wait(fired).State(), ShouldEqual, Active)
+			wait(time.Now())
+			select {
+			case <-responses:
+				t.Fatal("fired twice on coalesced triggers")
+			case <-time.After(time.Millisecond * 20):
+			}
+			close(trigger)
+			So((<-responses).State(), ShouldEqual, Ended)
+		})
+		Convey("ends once the trigger channel is closed", func() {
+			last := time.Now()
+			trigger <- struct{}{}
+			close(trigger)
+			// the trigger sent before closing still fires
+			r := s.Wait(last)
+			So(r.State(), ShouldEqual, Active)
+			So(s.Wait(r.LastTime()).State(), ShouldEqual, Ended)
+			So(s.GetState(), ShouldEqual, Ended)
+		})
+	})
+}
+
+func TestDependentSchedule(t *testing.T) {
+	Convey("a dependent schedule", t, func() {
+		s := NewDependentSchedule()
+		So(s.Validate(), ShouldBeNil)
+		So(s.GetState(), ShouldEqual, Active)
+		Convey("fires when triggered", func() {
+			last := time.Now()
+			s.Trigger()
+			r := s.Wait(last)
+			So(r.State(), ShouldEqual, Active)
+			So(r.LastTime(), ShouldHappenAfter, last)
+		})
+		Convey("ends once ended, and is not triggered anymore", func() {
+			s.End()
+			So(s.Wait(time.Now()).State(), ShouldEqual, Ended)
+			s.Trigger()
+			s.End()
+			So(s.GetState(), ShouldEqual, Ended)
+		})
+	})
+}
@@ -20,6 +20,8 @@ limitations under the License.
 package schedule
 
 import (
+	"math/rand"
+	"sync"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -31,12 +33,29 @@ var (
 
 // WindowedSchedule is a schedule that waits on an interval within a specific time window
 type WindowedSchedule struct {
-	Interval   time.Duration
-	StartTime  *time.Time
-	StopTime   *time.Time
-	Count      uint
+	Interval  time.Duration
+	StartTime *time.Time
+	StopTime  *time.Time
+	Count     uint
+	Jitter    time.Duration
+	AlignTime *time.Time
+	// DailyStart and DailyEnd are the offsets from midnight between which
+	// the schedule fires, the schedule fires all day when both are zero
+	DailyStart time.Duration
+	DailyEnd   time.Duration
+	location   *time.Location
 	state      ScheduleState
 	stopOnTime *time.Time
+	rand       *rand.Rand
+	jitterSeed int64
+	// lastTick is the unjittered point in time of the latest interval
+	lastTick time.Time
+
+	stretchMutex sync.Mutex
+	// stretch is the factor the interval is multiplied by, set by the
+	// scheduler when it is under load
+	stretch uint
+	fireTime
 }
 
 // NewWindowedSchedule returns an instance of WindowedSchedule with given interval, start and stop timestamp
@@ -60,6 +79,103 @@ func NewWindowedSchedule(i time.Duration, start *time.Time, stop *time.Time, cou
 	}
 }
 
+// NewDailyWindowedSchedule returns an instance of WindowedSchedule with given interval, start and stop timestamp
+// which only fires within a daily window, between windowStart and windowEnd after midnight. Outside of the window
+// the schedule waits for the window to open again. The window is in local time unless set with SetLocation.
+func NewDailyWindowedSchedule(i time.Duration, start *time.Time, stop *time.Time, windowStart, windowEnd time.Duration) *WindowedSchedule {
+	return &WindowedSchedule{
+		Interval:   i,
+		StartTime:  start,
+		StopTime:   stop,
+		DailyStart: windowStart,
+		DailyEnd:   windowEnd,
+	}
+}
+
+// NewSimpleScheduleAt returns an instance of WindowedSchedule with given interval which fires on the
+// boundaries `start + k*interval`, the first firing happens on the next boundary. Tasks created at
+// different times with the same interval and start fire at the same points in time.
+func NewSimpleScheduleAt(i time.Duration, start time.Time) *WindowedSchedule {
+	return &WindowedSchedule{
+		Interval:  i,
+		AlignTime: &start,
+	}
+}
+
+// SetJitter delays each firing of the schedule by a random offset in [0, max),
+// recomputed for every interval. The offset is capped at the interval so the
+// schedule fires exactly once per interval. The offsets are generated from the
+// given seed, so schedules sharing a seed fire with the same offsets.
+func (w *WindowedSchedule) SetJitter(max time.Duration, seed int64) {
+	w.Jitter = max
+	w.jitterSeed = seed
+	w.rand = rand.New(rand.NewSource(seed))
+}
+
+// JitterSeed returns the seed the jitter offsets are generated from
+func (w *WindowedSchedule) JitterSeed() int64 {
+	return w.jitterSeed
+}
+
+// SetLocation sets the timezone the daily window of the schedule is in
+func (w *WindowedSchedule) SetLocation(loc *time.Location) {
+	w.location = loc
+}
+
+// Location returns the timezone the daily window of the schedule is in
+func (w *WindowedSchedule) Location() *time.Location {
+	if w.location == nil {
+		return time.Local
+	}
+	return w.location
+}
+
+// SetStretch multiplies the interval the schedule waits by the factor from
+// the next wait on, 0 and 1 restoring the interval
+func (w *WindowedSchedule) SetStretch(factor uint) {
+	w.stretchMutex.Lock()
+	defer w.stretchMutex.Unlock()
+	w.stretch = factor
+}
+
+// Stretch returns the factor the interval of the schedule is multiplied by
+func (w *WindowedSchedule) Stretch() uint {
+	w.stretchMutex.Lock()
+	defer w.stretchMutex.Unlock()
+	if w.stretch == 0 {
+		return 1
+	}
+	return w.stretch
+}
+
+// EffectiveInterval returns the interval the schedule waits, stretched
+func (w *WindowedSchedule) EffectiveInterval() time.Duration {
+	return w.Interval * time.Duration(w.Stretch())
+}
+
+// HasDailyWindow returns whether the schedule only fires within a daily window
+func (w *WindowedSchedule) HasDailyWindow() bool {
+	return w.DailyStart != 0 || w.DailyEnd != 0
+}
+
+// inDailyWindow returns t if it is within the daily window of the schedule,
+// otherwise the point in time the daily window opens next
+func (w *WindowedSchedule) inDailyWindow(t time.Time) time.Time {
+	if !w.HasDailyWindow() {
+		return t
+	}
+	lt := t.In(w.Location())
+	midnight := time.Date(lt.Year(), lt.Month(), lt.Day(), 0, 0, 0, 0, lt.Location())
+	offset := lt.Sub(midnight)
+	if offset < w.DailyStart {
+		return midnight.Add(w.DailyStart)
+	}
+	if offset >= w.DailyEnd {
+		return time.Date(lt.Year(), lt.Month(), lt.Day()+1, 0, 0, 0, 0, lt.Location()).Add(w.DailyStart)
+	}
+	return t
+}
+
 // setStopOnTime calculates and set the value of the windowed `stopOnTime` which is the right window boundary.
 // `stopOnTime` is determined by `StopTime` or, if it is not provided, calculated based on count and interval.
 func (w *WindowedSchedule) setStopOnTime() {
@@ -69,11 +185,11 @@ func (w *WindowedSchedule) setStopOnTime() {
 
 		// if start is not set or points in the past,
 		// use the current time to calculate stopOnTime
-		if w.StartTime != nil && time.Now().Before(*w.StartTime) {
+		if w.StartTime != nil && now().Before(*w.StartTime) {
 			newStop = w.StartTime.Add(time.Duration(w.Count) * w.Interval)
 		} else {
 			// set a new stop timestamp from this point in time
-			newStop = time.Now().Add(time.Duration(w.Count) * w.Interval)
+			newStop = now().Add(time.Duration(w.Count) * w.Interval)
 		}
 		// set calculated new stop
 		w.stopOnTime = &newStop
@@ -92,7 +208,7 @@ func (w *WindowedSchedule) GetState() ScheduleState {
 // Validate validates the start, stop and duration interval of WindowedSchedule
 func (w *WindowedSchedule) Validate() error {
 	// if the stop time was set but it is in the past, return an error
-	if w.StopTime != nil && time.Now().After(*w.StopTime) {
+	if w.StopTime != nil && now().After(*w.StopTime) {
 		return ErrInvalidStopTime
 	}
 
@@ -106,14 +222,154 @@ func (w *WindowedSchedule) Validate() error {
 		return ErrInvalidInterval
 	}
 
+	// if the jitter is less than zero, return an error
+	if w.Jitter < 0 {
+		return ErrInvalidJitter
+	}
+
+	// if the daily window does not end after it starts within a day, return an error
+	if w.HasDailyWindow() && (w.DailyStart < 0 || w.DailyEnd <= w.DailyStart || w.DailyEnd > 24*time.Hour) {
+		return ErrInvalidDailyWindow
+	}
+
 	// the schedule passed validation, set as active
 	w.state = Active
 	return nil
 }
 
+// waitInterval waits the interval, stretched by the factor set with SetStretch
+// and delayed by the jitter if one was set. It returns false if cancel is
+// closed meanwhile.
+func (w *WindowedSchedule) waitInterval(last time.Time, cancel <-chan struct{}) (uint, bool) {
+	interval := w.EffectiveInterval()
+	if w.Jitter <= 0 {
+		var m uint
+		var next time.Time
+		if w.AlignTime != nil {
+			m, next = nextOnAlignedInterval(last, *w.AlignTime, interval)
+		} else {
+			m, next = nextOnInterval(last, interval)
+		}
+		// outside of the daily window wait for the window to open, on the
+		// first boundary within the window for an aligned schedule
+		if open := w.inDailyWindow(next); !open.Equal(next) {
+			next = open
+			if w.AlignTime != nil {
+				next = nextAlignedTick(*w.AlignTime, interval, open)
+			}
+		}
+		// Wait until predicted interval fires
+		if !w.sleepUntil(next, cancel) {
+			return 0, false
+		}
+		// the intervals which elapsed while the process was suspended or
+		// the clock jumped past the firing were missed as well
+		if late := since(next); late >= interval {
+			m += uint(late / interval)
+		}
+		return m, true
+	}
+	if w.rand == nil {
+		w.SetJitter(w.Jitter, now().UnixNano())
+	}
+	var missed uint
+	// a cancelled wait leaves the ticks as they were
+	lastTick := w.lastTick
+	if (last == time.Time{}) || (w.lastTick == time.Time{}) {
+		// for the first run, start the first interval now
+		// or on the next boundary of an aligned schedule
+		w.lastTick = now()
+		if w.AlignTime != nil {
+			w.lastTick = nextAlignedTick(*w.AlignTime, interval, w.lastTick)
+		}
+	} else {
+		// intervals which elapsed entirely since the last tick were missed
+		elapsed := since(w.lastTick).Nanoseconds() / interval.Nanoseconds()
+		missed = uint(elapsed)
+		w.lastTick = w.lastTick.Add(time.Duration(elapsed+1) * interval)
+	}
+	// outside of the daily window the next interval starts when it opens
+	if open := w.inDailyWindow(w.lastTick); !open.Equal(w.lastTick) {
+		w.lastTick = open
+		if w.AlignTime != nil {
+			w.lastTick = nextAlignedTick(*w.AlignTime, interval, open)
+		}
+	}
+	// the offset is kept within the interval, so the ticks never overlap
+	max := w.Jitter
+	if max > interval {
+		max = interval
+	}
+	offset := time.Duration(w.rand.Int63n(max.Nanoseconds()))
+	next := w.lastTick.Add(offset)
+	logger.WithFields(log.Fields{
+		"_block":         "windowed-wait",
+		"jitter":         offset,
+		"sleep-duration": next.Sub(now()),
+	}).Debug("Waiting for jittered interval")
+	if !w.sleepUntil(next, cancel) {
+		w.lastTick = lastTick
+		return 0, false
+	}
+	// the ticks which elapsed while the process was suspended or the clock
+	// jumped past the firing were missed as well
+	if elapsed := since(w.lastTick) / interval; elapsed > 0 {
+		missed += uint(elapsed)
+		w.lastTick = w.lastTick.Add(elapsed * interval)
+	}
+	return missed, true
+}
+
+// FireTimes returns up to max of the points in time the schedule fires at
+// from from to to, for a task started on it at from, the offsets of the
+// jitter being left out
+func (w *WindowedSchedule) FireTimes(from, to time.Time, max int) []time.Time {
+	interval := w.EffectiveInterval()
+	if interval <= 0 {
+		return nil
+	}
+	// the schedule fires once started, or once its window has started
+	start := from
+	if w.StartTime != nil && w.StartTime.After(start) {
+		start = *w.StartTime
+	}
+	// the stop determined by the count is set on the first wait, as in
+	// setStopOnTime
+	stop := w.StopTime
+	if stop == nil && w.Count != 0 {
+		s := start.Add(time.Duration(w.Count) * w.Interval)
+		stop = &s
+	}
+	next := start
+	if w.AlignTime != nil {
+		next = nextAlignedTick(*w.AlignTime, interval, start)
+	}
+	var times []time.Time
+	for len(times) < max {
+		if open := w.inDailyWindow(next); !open.Equal(next) {
+			next = open
+			if w.AlignTime != nil {
+				next = nextAlignedTick(*w.AlignTime, interval, open)
+			}
+		}
+		if next.After(to) || (stop != nil && next.After(*stop)) {
+			break
+		}
+		times = append(times, next)
+		next = next.Add(interval)
+	}
+	return times
+}
+
 // Wait waits the window interval and return.
 // Otherwise, it exits with a completed state
 func (w *WindowedSchedule) Wait(last time.Time) Response {
+	return w.WaitOrCancel(last, nil)
+}
+
+// WaitOrCancel waits as Wait does unless cancel is closed first, it returns
+// nil then
+func (w *WindowedSchedule) WaitOrCancel(last time.Time, cancel <-chan struct{}) Response {
 	// If within the window we wait our interval and return
 	// otherwise we exit with a completed state.
 	var m uint
@@ -127,28 +383,33 @@ func (w *WindowedSchedule) Wait(last time.Time) Response {
 	// Do we even have a specific start time?
 	if w.StartTime != nil {
 		// Wait till it is time to start if before the window start
-		if time.Now().Before(*w.StartTime) {
-			wait := w.StartTime.Sub(time.Now())
+		if now().Before(*w.StartTime) {
+			wait := w.StartTime.Sub(now())
 			logger.WithFields(log.Fields{
 				"_block":         "windowed-wait",
 				"sleep-duration": wait,
 			}).Debug("Waiting for window to start")
-			time.Sleep(wait)
+			if !w.sleepUntil(*w.StartTime, cancel) {
+				return nil
+			}
 		}
 	}
 
 	// Do we even have a stop time?
 	if w.stopOnTime != nil {
-		if time.Now().Before(*w.stopOnTime) {
+		if now().Before(*w.stopOnTime) {
 			logger.WithFields(log.Fields{
 				"_block":           "windowed-wait",
-				"time-before-stop": w.stopOnTime.Sub(time.Now()),
+				"time-before-stop": w.stopOnTime.Sub(now()),
 			}).Debug("Within window, calling interval")
 
-			m, _ = waitOnInterval(last, w.Interval)
+			var ok bool
+			if m, ok = w.waitInterval(last, cancel); !ok {
+				return nil
+			}
 
 			// check if the schedule should be ended after waiting on interval
-			if time.Now().After(*w.stopOnTime) {
+			if now().After(*w.stopOnTime) {
 				logger.WithFields(log.Fields{
 					"_block": "windowed-wait",
 				}).Debug("schedule has ended")
@@ -163,13 +424,16 @@ func (w *WindowedSchedule) Wait(last time.Time) Response {
 		}
 	} else {
 		// This has no end like a simple schedule
-		m, _ = waitOnInterval(last, w.Interval)
+		var ok bool
+		if m, ok = w.waitInterval(last, cancel); !ok {
+			return nil
+		}
 
 	}
 	return &WindowedScheduleResponse{
 		state:    w.GetState(),
 		missed:   m,
-		lastTime: time.Now(),
+		lastTime: now(),
 	}
 }
 
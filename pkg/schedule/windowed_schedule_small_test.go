@@ -67,6 +67,12 @@ func TestWindowedScheduleValidation(t *testing.T) {
 		err := w.Validate()
 		So(err, ShouldEqual, ErrStopBeforeStart)
 	})
+	Convey("negative jitter", t, func() {
+		w := NewWindowedSchedule(time.Millisecond*100, nil, nil, 0)
+		w.SetJitter(time.Millisecond*-1, 1)
+		err := w.Validate()
+		So(err, ShouldEqual, ErrInvalidJitter)
+	})
 	Convey("test Wait()", t, func() {
 		interval := 100
 		overage := 467
@@ -91,3 +97,214 @@ func TestWindowedScheduleValidation(t *testing.T) {
 		So(afterMS, ShouldBeLessThan, shouldWait+10)
 	})
 }
+
+func TestWindowedScheduleJitter(t *testing.T) {
+	Convey("Given two schedules with jitter and the same seed", t, func() {
+		s1 := NewWindowedSchedule(time.Millisecond*100, nil, nil, 0)
+		s1.SetJitter(time.Millisecond*50, 42)
+		s2 := NewWindowedSchedule(time.Millisecond*100, nil, nil, 0)
+		s2.SetJitter(time.Millisecond*50, 42)
+		Convey("they generate the same offsets", func() {
+			So(s1.JitterSeed(), ShouldEqual, 42)
+			for i := 0; i < 10; i++ {
+				So(s1.rand.Int63n(int64(s1.Jitter)), ShouldEqual, s2.rand.Int63n(int64(s2.Jitter)))
+			}
+		})
+	})
+	Convey("Given a schedule with a jitter greater than the interval", t, func() {
+		interval := time.Millisecond * 50
+		s := NewWindowedSchedule(interval, nil, nil, 0)
+		s.SetJitter(time.Second, 7)
+		err := s.Validate()
+		So(err, ShouldBeNil)
+		Convey("it fires exactly once within every interval", func() {
+			start := time.Now()
+			var last time.Time
+			for i := 0; i < 5; i++ {
+				r := s.Wait(last)
+				So(r.Error(), ShouldBeNil)
+				So(r.State(), ShouldEqual, Active)
+				So(r.Missed(), ShouldEqual, 0)
+				elapsed := r.LastTime().Sub(start)
+				So(elapsed, ShouldBeGreaterThanOrEqualTo, time.Duration(i)*interval)
+				So(elapsed, ShouldBeLessThan, time.Duration(i+1)*interval+time.Millisecond*10)
+				last = r.LastTime()
+			}
+		})
+	})
+	Convey("Given a schedule with jitter and a slow workflow", t, func() {
+		interval := time.Millisecond * 50
+		s := NewWindowedSchedule(interval, nil, nil, 0)
+		s.SetJitter(time.Millisecond*10, 7)
+		err := s.Validate()
+		So(err, ShouldBeNil)
+		Convey("the intervals elapsed in between are reported as missed", func() {
+			r := s.Wait(time.Time{})
+			time.Sleep(interval*2 + time.Millisecond*15)
+			r = s.Wait(r.LastTime())
+			So(r.Missed(), ShouldEqual, 2)
+		})
+	})
+}
+
+func TestSimpleScheduleAt(t *testing.T) {
+	Convey("invalid an interval", t, func() {
+		s := NewSimpleScheduleAt(0, time.Now())
+		So(s.Validate(), ShouldEqual, ErrInvalidInterval)
+		s = NewSimpleScheduleAt(time.Millisecond*-1, time.Now())
+		So(s.Validate(), ShouldEqual, ErrInvalidInterval)
+	})
+	Convey("next aligned tick", t, func() {
+		align := time.Unix(1000, 0)
+		So(nextAlignedTick(align, time.Second, align.Add(-time.Minute)), ShouldResemble, align)
+		So(nextAlignedTick(align, time.Second, align), ShouldResemble, align)
+		So(nextAlignedTick(align, time.Second, align.Add(time.Millisecond)), ShouldResemble, align.Add(time.Second))
+		So(nextAlignedTick(align, time.Second, align.Add(time.Second*3)), ShouldResemble, align.Add(time.Second*3))
+	})
+	Convey("Given a schedule aligned to a start time in the past", t, func() {
+		interval := time.Millisecond * 100
+		start := time.Now().Add(time.Millisecond * -230)
+		s := NewSimpleScheduleAt(interval, start)
+		So(s.Validate(), ShouldBeNil)
+		Convey("it fires on the boundaries after the start time", func() {
+			var last time.Time
+			for i := 3; i < 6; i++ {
+				r := s.Wait(last)
+				So(r.Error(), ShouldBeNil)
+				So(r.State(), ShouldEqual, Active)
+				So(r.Missed(), ShouldEqual, 0)
+				So(r.LastTime(), ShouldHappenOnOrAfter, start.Add(time.Duration(i)*interval))
+				So(r.LastTime(), ShouldHappenBefore, start.Add(time.Duration(i)*interval+time.Millisecond*10))
+				last = r.LastTime()
+			}
+		})
+		Convey("the boundaries elapsed in between are reported as missed", func() {
+			r := s.Wait(time.Time{})
+			time.Sleep(interval*2 + time.Millisecond*15)
+			r = s.Wait(r.LastTime())
+			So(r.Missed(), ShouldEqual, 2)
+		})
+	})
+	Convey("Given an aligned schedule with jitter", t, func() {
+		interval := time.Millisecond * 100
+		start := time.Now().Add(time.Millisecond * -30)
+		s := NewSimpleScheduleAt(interval, start)
+		s.SetJitter(time.Millisecond*20, 7)
+		So(s.Validate(), ShouldBeNil)
+		Convey("it fires within the jitter after the next boundary", func() {
+			r := s.Wait(time.Time{})
+			So(r.LastTime(), ShouldHappenOnOrAfter, start.Add(interval))
+			So(r.LastTime(), ShouldHappenBefore, start.Add(interval+time.Millisecond*30))
+		})
+	})
+}
+
+func TestWindowedScheduleNextFireTime(t *testing.T) {
+	Convey("Given a windowed schedule", t, func() {
+		interval := time.Millisecond * 100
+		s := NewWindowedSchedule(interval, nil, nil, 0)
+		So(s.Validate(), ShouldBeNil)
+		So(s.NextFireTime().IsZero(), ShouldBeTrue)
+		Convey("the next fire time is an interval after the last firing", func() {
+			r := s.Wait(time.Time{})
+			done := make(chan Response)
+			go func() { done <- s.Wait(r.LastTime()) }()
+			time.Sleep(time.Millisecond * 20)
+			So(s.NextFireTime(), ShouldHappenWithin, time.Millisecond*5, r.LastTime().Add(interval))
+			r2 := <-done
+			So(r2.LastTime(), ShouldHappenOnOrAfter, s.NextFireTime())
+		})
+	})
+	Convey("Given a windowed schedule with jitter", t, func() {
+		interval := time.Millisecond * 100
+		s := NewWindowedSchedule(interval, nil, nil, 0)
+		s.SetJitter(time.Millisecond*50, 7)
+		So(s.Validate(), ShouldBeNil)
+		Convey("the next fire time includes the jitter", func() {
+			done := make(chan Response)
+			go func() { done <- s.Wait(time.Time{}) }()
+			time.Sleep(time.Millisecond * 5)
+			next := s.NextFireTime()
+			r := <-done
+			So(r.LastTime(), ShouldHappenOnOrAfter, next)
+			So(r.LastTime(), ShouldHappenBefore, next.Add(time.Millisecond*10))
+		})
+	})
+}
+
+func TestWindowedScheduleStretch(t *testing.T) {
+	Convey("Given a windowed schedule stretched by a factor", t, func() {
+		interval := time.Millisecond * 50
+		s := NewWindowedSchedule(interval, nil, nil, 0)
+		So(s.Validate(), ShouldBeNil)
+		So(s.Stretch(), ShouldEqual, 1)
+		s.SetStretch(3)
+		So(s.EffectiveInterval(), ShouldEqual, interval*3)
+		Convey("it waits the stretched interval", func() {
+			r := s.Wait(time.Time{})
+			r2 := s.Wait(r.LastTime())
+			So(r2.LastTime().Sub(r.LastTime()), ShouldBeGreaterThanOrEqualTo, interval*3-time.Millisecond*5)
+			So(r2.Missed(), ShouldEqual, 0)
+		})
+		Convey("it waits the interval again once restored", func() {
+			s.SetStretch(0)
+			So(s.EffectiveInterval(), ShouldEqual, interval)
+			r := s.Wait(time.Time{})
+			r2 := s.Wait(r.LastTime())
+			So(r2.LastTime().Sub(r.LastTime()), ShouldBeLessThan, interval*2)
+		})
+	})
+}
+
+func TestDailyWindowedSchedule(t *testing.T) {
+	Convey("invalid a daily window", t, func() {
+		s := NewDailyWindowedSchedule(time.Minute, nil, nil, 17*time.Hour, 9*time.Hour)
+		So(s.Validate(), ShouldEqual, ErrInvalidDailyWindow)
+		s = NewDailyWindowedSchedule(time.Minute, nil, nil, 9*time.Hour, 9*time.Hour)
+		So(s.Validate(), ShouldEqual, ErrInvalidDailyWindow)
+		s = NewDailyWindowedSchedule(time.Minute, nil, nil, 9*time.Hour, 25*time.Hour)
+		So(s.Validate(), ShouldEqual, ErrInvalidDailyWindow)
+		s = NewDailyWindowedSchedule(-time.Minute, nil, nil, 9*time.Hour, 17*time.Hour)
+		So(s.Validate(), ShouldEqual, ErrInvalidInterval)
+	})
+	Convey("Given a schedule firing from 09:00 to 17:00", t, func() {
+		s := NewDailyWindowedSchedule(time.Minute, nil, nil, 9*time.Hour, 17*time.Hour)
+		So(s.Validate(), ShouldBeNil)
+		So(s.Location(), ShouldEqual, time.Local)
+		loc := time.FixedZone("UTC+2", 2*60*60)
+		s.SetLocation(loc)
+		day := time.Date(2017, 3, 1, 0, 0, 0, 0, loc)
+		Convey("it waits for the window to open before 09:00", func() {
+			So(s.inDailyWindow(day.Add(8*time.Hour)), ShouldResemble, day.Add(9*time.Hour))
+		})
+		Convey("it fires within the window", func() {
+			at := day.Add(10 * time.Hour)
+			So(s.inDailyWindow(at), ShouldResemble, at)
+		})
+		Convey("it waits for the window of the next day from 17:00", func() {
+			So(s.inDailyWindow(day.Add(17*time.Hour)).Equal(day.Add(33*time.Hour)), ShouldBeTrue)
+		})
+		Convey("the window is in its timezone", func() {
+			at := time.Date(2017, 3, 1, 6, 30, 0, 0, time.UTC)
+			So(s.inDailyWindow(at).Equal(time.Date(2017, 3, 1, 7, 0, 0, 0, time.UTC)), ShouldBeTrue)
+		})
+	})
+	Convey("Given a schedule whose daily window opens shortly", t, func() {
+		now := time.Now().UTC()
+		midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		start := now.Sub(midnight) + time.Millisecond*150
+		if start+time.Second > 24*time.Hour {
+			// too close to midnight for the window to open on the same day
+			return
+		}
+		s := NewDailyWindowedSchedule(time.Millisecond*10, nil, nil, start, start+time.Second)
+		s.SetLocation(time.UTC)
+		So(s.Validate(), ShouldBeNil)
+		Convey("it stays active and fires once the window opens", func() {
+			r := s.Wait(time.Time{})
+			So(r.State(), ShouldEqual, Active)
+			So(r.LastTime(), ShouldHappenOnOrAfter, midnight.Add(start))
+			So(r.LastTime(), ShouldHappenBefore, midnight.Add(start+time.Millisecond*20))
+		})
+	})
+}
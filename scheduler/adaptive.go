@@ -0,0 +1,221 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/intelsdi-x/snap/core/scheduler_event"
+	"github.com/intelsdi-x/snap/pkg/schedule"
+)
+
+var errCPUStatsUnavailable = errors.New("cpu counters not found")
+
+// adaptiveRelaxRatio is the share of its threshold a load must fall under
+// for the intervals to be tightened back, so that a load hovering around a
+// threshold does not stretch and tighten them on every check
+const adaptiveRelaxRatio = 0.75
+
+// adaptiveIntervals stretches the intervals of the tasks while the work
+// queues or the CPU of the host are loaded over their thresholds, doubling
+// the stretch on each check up to maxStretch, and tightens them back by
+// halving it once the load has subsided
+type adaptiveIntervals struct {
+	maxStretch     uint
+	queueThreshold float64
+	// cpuThreshold is 0 when the CPU of the host is not checked
+	cpuThreshold  float64
+	checkInterval time.Duration
+	// queueLoad returns the percentage of its limit the fullest work queue
+	// is filled to
+	queueLoad func() float64
+	// cpuLoad returns the busy percentage of the CPU of the host since it
+	// was last called, false if it is not known
+	cpuLoad func() (float64, bool)
+
+	mutex   sync.Mutex
+	stretch uint
+}
+
+func newAdaptiveIntervals(maxStretch, queueThreshold, cpuThreshold uint, checkInterval time.Duration, queueLoad func() float64) *adaptiveIntervals {
+	return &adaptiveIntervals{
+		maxStretch:     maxStretch,
+		queueThreshold: float64(queueThreshold),
+		cpuThreshold:   float64(cpuThreshold),
+		checkInterval:  checkInterval,
+		queueLoad:      queueLoad,
+		cpuLoad:        newCPUSampler().load,
+		stretch:        1,
+	}
+}
+
+// current returns the factor the intervals are stretched by
+func (a *adaptiveIntervals) current() uint {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.stretch
+}
+
+// adjust returns the previous and the new stretch for the saturation of the
+// work queues and the load of the CPU, in percent
+func (a *adaptiveIntervals) adjust(queue, cpu float64, cpuKnown bool) (uint, uint) {
+	checkCPU := a.cpuThreshold > 0 && cpuKnown
+	loaded := queue >= a.queueThreshold || (checkCPU && cpu >= a.cpuThreshold)
+	relaxed := queue < a.queueThreshold*adaptiveRelaxRatio &&
+		(!checkCPU || cpu < a.cpuThreshold*adaptiveRelaxRatio)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	prev := a.stretch
+	switch {
+	case loaded && a.stretch < a.maxStretch:
+		a.stretch *= 2
+		if a.stretch > a.maxStretch {
+			a.stretch = a.maxStretch
+		}
+	case relaxed && a.stretch > 1:
+		a.stretch /= 2
+	}
+	return prev, a.stretch
+}
+
+// runAdaptiveIntervals checks the load every check interval until the
+// context is done, closing done then
+func (s *scheduler) runAdaptiveIntervals(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	tick := time.NewTicker(s.adaptive.checkInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		s.adaptIntervals()
+	}
+}
+
+// adaptIntervals adjusts the stretch to the current load and applies it to
+// the tasks on an interval, emitting an event for each task whose interval
+// changed. The tasks created since the last check are stretched as well.
+func (s *scheduler) adaptIntervals() {
+	queue := s.adaptive.queueLoad()
+	cpu, cpuKnown := s.adaptive.cpuLoad()
+	prev, stretch := s.adaptive.adjust(queue, cpu, cpuKnown)
+	if prev != stretch {
+		schedulerLogger.WithFields(log.Fields{
+			"_block":           "adapt-intervals",
+			"previous-stretch": prev,
+			"stretch":          stretch,
+			"queue-saturation": queue,
+			"cpu":              cpu,
+		}).Info("adjusting the intervals of the tasks to the load")
+	}
+	for _, t := range s.tasks.Table() {
+		ws, ok := t.Schedule().(*schedule.WindowedSchedule)
+		if !ok || ws.Stretch() == stretch {
+			continue
+		}
+		ws.SetStretch(stretch)
+		s.eventManager.Emit(&scheduler_event.IntervalAdjustedEvent{
+			TaskID:   t.id,
+			Interval: ws.EffectiveInterval(),
+			Stretch:  stretch,
+		})
+	}
+}
+
+// cpuSampler computes the busy percentage of the CPU of the host from the
+// counters of /proc/stat between two samples
+type cpuSampler struct {
+	mutex       sync.Mutex
+	path        string
+	idle, total uint64
+}
+
+func newCPUSampler() *cpuSampler {
+	return &cpuSampler{path: "/proc/stat"}
+}
+
+// load returns the busy percentage of the CPU since the previous sample. It
+// returns false on the first sample, or if the counters cannot be read as
+// on the hosts which do not provide /proc/stat.
+func (c *cpuSampler) load() (float64, bool) {
+	idle, total, err := c.read()
+	if err != nil {
+		return 0, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	prevIdle, prevTotal := c.idle, c.total
+	c.idle, c.total = idle, total
+	if prevTotal == 0 || total <= prevTotal {
+		return 0, false
+	}
+	return 100 * (1 - float64(idle-prevIdle)/float64(total-prevTotal)), true
+}
+
+// read returns the idle and the total time of the CPU, summing the iowait
+// time into the idle one
+func (c *cpuSampler) read() (uint64, uint64, error) {
+	f, err := os.Open(c.path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+		var idle, total uint64
+		// the guest counters following the steal one are counted in the
+		// user ones already
+		for i, field := range fields[1:] {
+			if i == 8 {
+				break
+			}
+			v, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return 0, 0, err
+			}
+			// the fourth and fifth counters are the idle and iowait ones
+			if i == 3 || i == 4 {
+				idle += v
+			}
+			total += v
+		}
+		return idle, total, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+	return 0, 0, errCPUStatsUnavailable
+}
@@ -0,0 +1,134 @@
+// +build legacy
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/pkg/schedule"
+)
+
+func TestAdaptiveIntervals(t *testing.T) {
+	Convey("adaptiveIntervals", t, func() {
+		a := newAdaptiveIntervals(8, 80, 90, time.Second, nil)
+		stretchFor := func(queue, cpu float64, cpuKnown bool) uint {
+			_, stretch := a.adjust(queue, cpu, cpuKnown)
+			return stretch
+		}
+		Convey("doubles the stretch up to its max while the queues are loaded", func() {
+			So(stretchFor(85, 0, true), ShouldEqual, 2)
+			So(stretchFor(85, 0, true), ShouldEqual, 4)
+			So(stretchFor(85, 0, true), ShouldEqual, 8)
+			So(stretchFor(100, 0, true), ShouldEqual, 8)
+			Convey("keeps it while the load is between the thresholds", func() {
+				So(stretchFor(70, 0, true), ShouldEqual, 8)
+			})
+			Convey("halves it back once the load has subsided", func() {
+				So(stretchFor(10, 0, true), ShouldEqual, 4)
+				So(stretchFor(10, 0, true), ShouldEqual, 2)
+				So(stretchFor(10, 0, true), ShouldEqual, 1)
+				So(stretchFor(10, 0, true), ShouldEqual, 1)
+			})
+		})
+		Convey("stretches the intervals while the CPU is busy", func() {
+			So(stretchFor(0, 95, true), ShouldEqual, 2)
+			So(stretchFor(0, 80, true), ShouldEqual, 2)
+			So(stretchFor(0, 50, true), ShouldEqual, 1)
+		})
+		Convey("leaves the CPU out when its load is not known", func() {
+			So(stretchFor(0, 95, false), ShouldEqual, 1)
+		})
+		Convey("caps the stretch at a max which is not a power of two", func() {
+			a := newAdaptiveIntervals(3, 80, 0, time.Second, nil)
+			a.adjust(90, 0, false)
+			_, stretch := a.adjust(90, 0, false)
+			So(stretch, ShouldEqual, 3)
+		})
+	})
+	Convey("cpuSampler", t, func() {
+		dir, err := ioutil.TempDir("", "cpu-sampler")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		c := &cpuSampler{path: filepath.Join(dir, "stat")}
+		write := func(line string) {
+			So(ioutil.WriteFile(c.path, []byte(line+"\ncpu0 1 2 3 4 5 6 7 8 9 10\n"), 0600), ShouldBeNil)
+		}
+		Convey("returns the busy percentage between two samples", func() {
+			write("cpu  100 0 100 700 100 0 0 0 50 0")
+			_, ok := c.load()
+			So(ok, ShouldBeFalse)
+			write("cpu  250 0 250 1100 200 0 0 0 90 0")
+			load, ok := c.load()
+			So(ok, ShouldBeTrue)
+			So(load, ShouldAlmostEqual, 37.5)
+		})
+		Convey("is not known without counters", func() {
+			_, ok := c.load()
+			So(ok, ShouldBeFalse)
+		})
+	})
+	Convey("a scheduler adapting the intervals", t, func() {
+		s := New(GetDefaultConfig(), WithAdaptiveIntervals(4, 80, 0))
+		So(s.adaptive, ShouldNotBeNil)
+		queue := 90.0
+		s.adaptive.queueLoad = func() float64 { return queue }
+		s.adaptive.cpuLoad = func() (float64, bool) { return 0, false }
+		events := s.Events()
+		ws := schedule.NewWindowedSchedule(time.Second, nil, nil, 0)
+		s.tasks.add(&task{id: "1", name: "windowed", schedule: ws})
+		s.tasks.add(&task{id: "2", name: "once", schedule: schedule.NewRunOnceSchedule(time.Now())})
+		Convey("stretches the tasks on an interval sending an event for each", func() {
+			s.adaptIntervals()
+			So(ws.Stretch(), ShouldEqual, 2)
+			s.adaptIntervals()
+			So(ws.Stretch(), ShouldEqual, 4)
+			So(s.Health().IntervalStretch, ShouldEqual, 4)
+			intervals := []time.Duration{}
+			for len(intervals) < 2 {
+				select {
+				case e := <-events:
+					So(e.Type, ShouldEqual, EventIntervalAdjusted)
+					So(e.TaskID, ShouldEqual, "1")
+					intervals = append(intervals, e.Interval)
+				case <-time.After(time.Second):
+					So("no event sent", ShouldBeEmpty)
+					return
+				}
+			}
+			So(intervals, ShouldContain, 2*time.Second)
+			So(intervals, ShouldContain, 4*time.Second)
+			Convey("and tightens them back once the queues are drained", func() {
+				queue = 0
+				s.adaptIntervals()
+				So(ws.Stretch(), ShouldEqual, 2)
+				s.adaptIntervals()
+				So(ws.Stretch(), ShouldEqual, 1)
+			})
+		})
+	})
+}
@@ -0,0 +1,199 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/ctypes"
+	"github.com/intelsdi-x/snap/core/serror"
+	"github.com/intelsdi-x/snap/pkg/chrono"
+)
+
+// auditLog keeps the last size task management operations, the oldest
+// being dropped first
+type auditLog struct {
+	mutex   sync.Mutex
+	size    int
+	entries []core.AuditEntry
+	// next is the index the next entry is written at once the log is full
+	next int
+}
+
+func newAuditLog(size uint) *auditLog {
+	return &auditLog{size: int(size)}
+}
+
+func (a *auditLog) add(e core.AuditEntry) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if len(a.entries) < a.size {
+		a.entries = append(a.entries, e)
+		return
+	}
+	a.entries[a.next] = e
+	a.next = (a.next + 1) % a.size
+}
+
+// query returns the entries selected by the filter, oldest first
+func (a *auditLog) query(filter core.AuditFilter) []core.AuditEntry {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	entries := []core.AuditEntry{}
+	for i := range a.entries {
+		e := a.entries[(a.next+i)%len(a.entries)]
+		if filter.Matches(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// AuditLog returns the task management operations recorded in the audit log
+// selected by the filter, oldest first. The log keeps the last
+// audit_log_size operations; it is empty if the log is disabled.
+func (s *scheduler) AuditLog(filter core.AuditFilter) []core.AuditEntry {
+	if s.auditLog == nil {
+		return []core.AuditEntry{}
+	}
+	return s.auditLog.query(filter)
+}
+
+// audit records an operation on a task in the audit log, with the identity
+// of the caller carried by the context and the changes of the manifest of
+// the task from before to after the operation
+func (s *scheduler) audit(ctx context.Context, op core.AuditOperation, taskID, source string, before, after *core.TaskCreationRequest, err error) {
+	if s.auditLog == nil {
+		return
+	}
+	e := core.AuditEntry{
+		Time:      chrono.Chrono.Now(),
+		Caller:    core.CallerFromContext(ctx),
+		Source:    source,
+		Operation: op,
+		TaskID:    taskID,
+		Changes:   manifestChanges(before, after),
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	s.auditLog.add(e)
+	schedulerLogger.WithFields(log.Fields{
+		"_block":    "audit",
+		"operation": op,
+		"task-id":   taskID,
+		"caller":    e.Caller,
+		"source":    source,
+		"changes":   len(e.Changes),
+	}).Debug("task operation audited")
+}
+
+// auditError returns the first of the errors of an operation
+func auditError(errs []serror.SnapError) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs[0]
+}
+
+// auditManifest returns the manifest of a task the changes of an operation
+// are taken from, its secret config values redacted. The fired counts of the
+// task are left out, as they change with each firing and not by operations.
+func auditManifest(t *task) *core.TaskCreationRequest {
+	if t == nil {
+		return nil
+	}
+	tr, _ := buildTaskCreationRequest(t, core.ScheduleFromSchedule(t.Schedule()), t.workflow.workflowMap.Redacted(),
+		func(string) (interface{}, error) {
+			return ctypes.SecretRedacted, nil
+		})
+	tr.HitCount, tr.ScheduledRuns = 0, 0
+	return tr
+}
+
+// manifestChanges returns the fields of a manifest which differ from before
+// to after, sorted by field. A nil manifest has no fields.
+func manifestChanges(before, after *core.TaskCreationRequest) []core.AuditChange {
+	b, a := flattenManifest(before), flattenManifest(after)
+	var fields []string
+	for f, v := range b {
+		if !reflect.DeepEqual(v, a[f]) {
+			fields = append(fields, f)
+		}
+	}
+	for f := range a {
+		if _, ok := b[f]; !ok {
+			fields = append(fields, f)
+		}
+	}
+	sort.Strings(fields)
+	var changes []core.AuditChange
+	for _, f := range fields {
+		changes = append(changes, core.AuditChange{Field: f, Before: b[f], After: a[f]})
+	}
+	return changes
+}
+
+// flattenManifest returns the values of the fields of a manifest in its json
+// form, keyed by their path
+func flattenManifest(tr *core.TaskCreationRequest) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if tr == nil {
+		return fields
+	}
+	data, err := json.Marshal(tr)
+	if err != nil {
+		return fields
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fields
+	}
+	flatten("", v, fields)
+	return fields
+}
+
+func flatten(path string, v interface{}, fields map[string]interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, mv := range v {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			flatten(p, mv, fields)
+		}
+	case []interface{}:
+		for i, sv := range v {
+			flatten(fmt.Sprintf("%s[%d]", path, i), sv, fields)
+		}
+	case nil:
+	default:
+		fields[path] = v
+	}
+}
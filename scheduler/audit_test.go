@@ -0,0 +1,85 @@
+// +build legacy
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/core"
+)
+
+func TestAuditLogEntries(t *testing.T) {
+	Convey("auditLog", t, func() {
+		a := newAuditLog(3)
+		at := time.Now()
+		for i, id := range []string{"a", "b", "c", "d"} {
+			a.add(core.AuditEntry{Time: at.Add(time.Duration(i) * time.Second), TaskID: id, Operation: core.AuditStartTask})
+		}
+		ids := func(entries []core.AuditEntry) []string {
+			var ids []string
+			for _, e := range entries {
+				ids = append(ids, e.TaskID)
+			}
+			return ids
+		}
+		Convey("keeps the last entries, oldest first", func() {
+			So(ids(a.query(core.AuditFilter{})), ShouldResemble, []string{"b", "c", "d"})
+		})
+		Convey("returns the entries selected by the filter", func() {
+			So(ids(a.query(core.AuditFilter{TaskID: "c"})), ShouldResemble, []string{"c"})
+			So(ids(a.query(core.AuditFilter{Since: at.Add(2 * time.Second)})), ShouldResemble, []string{"c", "d"})
+			So(a.query(core.AuditFilter{Operation: core.AuditStopTask}), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestManifestChanges(t *testing.T) {
+	Convey("manifestChanges", t, func() {
+		before := &core.TaskCreationRequest{
+			Name:     "task",
+			Deadline: "5s",
+			Labels:   map[string]string{"env": "dev"},
+			Schedule: &core.Schedule{Type: "simple", Interval: "1s"},
+		}
+		Convey("returns the fields which changed, sorted", func() {
+			after := *before
+			after.Labels = map[string]string{"env": "prod", "team": "ops"}
+			after.Schedule = &core.Schedule{Type: "simple", Interval: "10s"}
+			So(manifestChanges(before, &after), ShouldResemble, []core.AuditChange{
+				{Field: "labels.env", Before: "dev", After: "prod"},
+				{Field: "labels.team", After: "ops"},
+				{Field: "schedule.interval", Before: "1s", After: "10s"},
+			})
+		})
+		Convey("returns no change for the same manifest", func() {
+			So(manifestChanges(before, before), ShouldBeEmpty)
+		})
+		Convey("returns every field of a removed manifest", func() {
+			changes := manifestChanges(before, nil)
+			So(changes, ShouldContain, core.AuditChange{Field: "name", Before: "task"})
+			So(changes, ShouldContain, core.AuditChange{Field: "deadline", Before: "5s"})
+		})
+	})
+}
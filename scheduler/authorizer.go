@@ -0,0 +1,125 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/intelsdi-x/snap/core"
+)
+
+// Authorization is a task management operation submitted to the Authorizer
+// of the scheduler before it runs
+type Authorization struct {
+	Operation core.AuditOperation
+	// Caller is the principal carried by the context of the operation, as
+	// set by core.WithCaller, empty if there is none
+	Caller string
+	// Source is where the operation comes from: "user", "tribe" or
+	// "autodiscover"
+	Source string
+	// TaskID is empty for the creation of a task
+	TaskID string
+	// Tenant is the tenant of the task, as set by core.WithTenant or
+	// core.OptionTenant, empty if it belongs to none
+	Tenant string
+	Labels map[string]string
+	// Namespaces are the namespaces of the metrics the task collects,
+	// sorted. The namespaces of an update are the ones of the workflow of
+	// the task before and after it.
+	Namespaces []string
+}
+
+// Authorizer decides whether a task management operation may run. An
+// operation it returns an error for is denied with ErrNotAuthorized.
+type Authorizer interface {
+	Authorize(Authorization) error
+}
+
+// AuthorizerFunc is a function used as an Authorizer
+type AuthorizerFunc func(Authorization) error
+
+// Authorize returns f(a)
+func (f AuthorizerFunc) Authorize(a Authorization) error {
+	return f(a)
+}
+
+// SetAuthorizer sets the authorizer of the creations, updates, removals,
+// starts and stops of the tasks. A nil authorizer lets every operation run.
+func (s *scheduler) SetAuthorizer(a Authorizer) {
+	s.authorizerMutex.Lock()
+	s.authorizer = a
+	s.authorizerMutex.Unlock()
+	schedulerLogger.WithFields(log.Fields{
+		"_block": "set-authorizer",
+	}).Debug("authorizer linked")
+}
+
+func (s *scheduler) getAuthorizer() Authorizer {
+	s.authorizerMutex.RLock()
+	defer s.authorizerMutex.RUnlock()
+	return s.authorizer
+}
+
+// authorize submits an operation on the tasks to the authorizer, the labels
+// and the namespaces being the ones of the tasks
+func (s *scheduler) authorize(ctx context.Context, op core.AuditOperation, taskID, source string, tasks ...*task) error {
+	authorizer := s.getAuthorizer()
+	if authorizer == nil {
+		return nil
+	}
+	a := Authorization{
+		Operation: op,
+		Caller:    core.CallerFromContext(ctx),
+		Source:    source,
+		TaskID:    taskID,
+	}
+	seen := make(map[string]struct{})
+	for _, t := range tasks {
+		if a.Labels == nil {
+			a.Labels = t.Labels()
+		}
+		if a.Tenant == "" {
+			a.Tenant = t.tenant
+		}
+		for _, m := range t.workflow.metrics {
+			seen[m.Namespace().String()] = struct{}{}
+		}
+	}
+	for ns := range seen {
+		a.Namespaces = append(a.Namespaces, ns)
+	}
+	sort.Strings(a.Namespaces)
+	if err := authorizer.Authorize(a); err != nil {
+		schedulerLogger.WithFields(log.Fields{
+			"_block":    "authorize",
+			"_error":    err.Error(),
+			"operation": op,
+			"task-id":   taskID,
+			"caller":    a.Caller,
+		}).Warn(ErrNotAuthorized)
+		return fmt.Errorf("%v %v", ErrNotAuthorized, err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/scheduler_event"
+	"github.com/intelsdi-x/snap/pkg/schedule"
+)
+
+// startBackfill runs the workflow for the current window of the backfill
+// schedule of the task, the context of its collect job carrying the window
+// for the metric manager to pass it to the collectors. The backfill only
+// moves on to the next window once every job of the firing succeeded, a
+// window failing or skipped being collected again on the next firing.
+func (s *schedulerWorkflow) startBackfill(ctx context.Context, t *task, bs *schedule.BackfillSchedule, recordFailure func([]error)) {
+	start, end, ok := bs.CurrentWindow()
+	if !ok {
+		return
+	}
+	ctx = core.WithCollectWindow(ctx, core.CollectWindow{Start: start, End: end})
+	var failed int32
+	record := func(errs []error) {
+		atomic.StoreInt32(&failed, 1)
+		recordFailure(errs)
+	}
+	j, event := s.collect(ctx, t, record)
+	if event != nil {
+		defer s.eventEmitter.Emit(event)
+	}
+	if j == nil {
+		return
+	}
+	workJobs(ctx, s.processNodes, s.publishNodes, t, j, record)
+	if atomic.LoadInt32(&failed) == 1 {
+		return
+	}
+	bs.Advance(end)
+	workflowLogger.WithFields(log.Fields{
+		"_block":       "backfill",
+		"task-id":      t.id,
+		"task-name":    t.name,
+		"window-start": start,
+		"window-end":   end,
+	}).Debug("Backfill window collected")
+	s.eventEmitter.Emit(&scheduler_event.BackfillProgressedEvent{
+		TaskID: t.id,
+		Start:  start,
+		End:    end,
+	})
+}
@@ -0,0 +1,337 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+const (
+	// builtinFilter keeps the metrics whose value is within the min and max
+	// of the config of the step
+	builtinFilter = "filter"
+	// builtinDownsample keeps one metric of every keep_every metrics of a
+	// namespace, or the average of every average metrics of a namespace
+	builtinDownsample = "downsample"
+	// builtinRate emits the change of the value of each series of metrics
+	// since the previous firing, per second or as it is
+	builtinRate = "rate"
+)
+
+const (
+	// rateModeRate divides the change of a value by the seconds elapsed
+	// between the timestamps of the two metrics
+	rateModeRate = "rate"
+	// rateModeDelta emits the change of a value as it is
+	rateModeDelta = "delta"
+
+	// counterWrapReset takes a value lower than the previous one for a reset
+	// counter, no change is emitted and the value is the base of the next one
+	counterWrapReset = "reset"
+	// counterWrapWrap takes a value lower than the previous one for a counter
+	// which wrapped around past its counter_max
+	counterWrapWrap = "wrap"
+	// counterWrapNone emits the negative change of a value lower than the
+	// previous one, for gauges
+	counterWrapNone = "none"
+)
+
+// builtinStep is a process step of a workflow run by the scheduler in place
+// of a processor plugin. It implements processesMetrics so that its jobs are
+// worked, timed out and retried like the jobs of the processor plugins.
+type builtinStep struct {
+	kind string
+	// min and max bound the values kept by a filter, each only when set
+	min, max       float64
+	hasMin, hasMax bool
+	// keepEvery and average are the number of metrics of a namespace a
+	// downsample step keeps one of, or averages
+	keepEvery int
+	average   int
+
+	mutex sync.Mutex
+	// seen counts the metrics of each namespace handed to a downsample step
+	// keeping one of every keepEvery, and sums adds up the values of each
+	// namespace of the window of a step averaging them
+	seen map[string]int
+	sums map[string]*averageWindow
+
+	// mode, counterWrap and counterMax configure a rate step, and previous
+	// holds the last value of each series the change of the next value is
+	// computed against
+	mode        string
+	counterWrap string
+	counterMax  float64
+	previous    map[string]rateSample
+}
+
+// rateSample is the value of a series of metrics at a point in time
+type rateSample struct {
+	value float64
+	at    time.Time
+}
+
+// averageWindow holds the values of a namespace averaged by a downsample step
+type averageWindow struct {
+	sum   float64
+	count int
+}
+
+// newBuiltinStep returns the built-in step of the given kind configured by
+// the config node of its workflow node
+func newBuiltinStep(kind string, cfg *cdata.ConfigDataNode) (*builtinStep, error) {
+	b := &builtinStep{kind: kind}
+	table := cfg.Table()
+	switch kind {
+	case builtinFilter:
+		var ok bool
+		if v, set := table["min"]; set {
+			if b.min, ok = configNumber(v); !ok {
+				return nil, ErrInvalidBuiltinStep
+			}
+			b.hasMin = true
+		}
+		if v, set := table["max"]; set {
+			if b.max, ok = configNumber(v); !ok {
+				return nil, ErrInvalidBuiltinStep
+			}
+			b.hasMax = true
+		}
+		if !b.hasMin && !b.hasMax {
+			return nil, ErrInvalidBuiltinStep
+		}
+	case builtinDownsample:
+		if v, set := table["keep_every"]; set {
+			n, ok := v.(ctypes.ConfigValueInt)
+			if !ok || n.Value < 1 {
+				return nil, ErrInvalidBuiltinStep
+			}
+			b.keepEvery = n.Value
+			b.seen = map[string]int{}
+		}
+		if v, set := table["average"]; set {
+			n, ok := v.(ctypes.ConfigValueInt)
+			if !ok || n.Value < 1 {
+				return nil, ErrInvalidBuiltinStep
+			}
+			b.average = n.Value
+			b.sums = map[string]*averageWindow{}
+		}
+		// a step either keeps one metric of every few, or averages them
+		if (b.keepEvery == 0) == (b.average == 0) {
+			return nil, ErrInvalidBuiltinStep
+		}
+	case builtinRate:
+		b.mode = rateModeRate
+		b.counterWrap = counterWrapReset
+		b.previous = map[string]rateSample{}
+		if v, set := table["mode"]; set {
+			s, ok := v.(ctypes.ConfigValueStr)
+			if !ok || (s.Value != rateModeRate && s.Value != rateModeDelta) {
+				return nil, ErrInvalidBuiltinStep
+			}
+			b.mode = s.Value
+		}
+		if v, set := table["counter_wrap"]; set {
+			s, ok := v.(ctypes.ConfigValueStr)
+			if !ok || (s.Value != counterWrapReset && s.Value != counterWrapWrap && s.Value != counterWrapNone) {
+				return nil, ErrInvalidBuiltinStep
+			}
+			b.counterWrap = s.Value
+		}
+		if v, set := table["counter_max"]; set {
+			var ok bool
+			if b.counterMax, ok = configNumber(v); !ok || b.counterMax <= 0 {
+				return nil, ErrInvalidBuiltinStep
+			}
+		}
+		// a counter wraps around past its maximum
+		if (b.counterWrap == counterWrapWrap) != (b.counterMax > 0) {
+			return nil, ErrInvalidBuiltinStep
+		}
+	default:
+		return nil, ErrUnknownBuiltinStep
+	}
+	return b, nil
+}
+
+// ProcessMetrics filters, downsamples or computes the rates of the metrics.
+// The metrics whose value is not a number are passed on as they are.
+func (b *builtinStep) ProcessMetrics(mts []core.Metric, _ map[string]ctypes.ConfigValue, _ string, _ string, _ int) ([]core.Metric, []error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	out := make([]core.Metric, 0, len(mts))
+	for _, m := range mts {
+		v, ok := metricNumber(m.Data())
+		if !ok {
+			out = append(out, m)
+			continue
+		}
+		switch {
+		case b.kind == builtinRate:
+			if r, ok := b.rate(m, v); ok {
+				out = append(out, r)
+			}
+		case b.kind == builtinFilter:
+			if (!b.hasMin || v >= b.min) && (!b.hasMax || v <= b.max) {
+				out = append(out, m)
+			}
+		case b.keepEvery > 0:
+			ns := m.Namespace().String()
+			if b.seen[ns]%b.keepEvery == 0 {
+				out = append(out, m)
+			}
+			b.seen[ns]++
+		default:
+			ns := m.Namespace().String()
+			w, ok := b.sums[ns]
+			if !ok {
+				w = &averageWindow{}
+				b.sums[ns] = w
+			}
+			w.sum += v
+			w.count++
+			if w.count == b.average {
+				avg := metricTypeOf(m)
+				avg.Data_ = w.sum / float64(w.count)
+				out = append(out, avg)
+				delete(b.sums, ns)
+			}
+		}
+	}
+	return out, nil
+}
+
+// rate returns the metric of the change of the value of the series of the
+// metric since its previous metric, false for the first metric of a series
+// and for a change which cannot be computed. It is called with the mutex of
+// the step held.
+func (b *builtinStep) rate(m core.Metric, v float64) (core.Metric, bool) {
+	key := seriesKey(m)
+	prev, seen := b.previous[key]
+	b.previous[key] = rateSample{value: v, at: m.Timestamp()}
+	if !seen {
+		return nil, false
+	}
+	delta := v - prev.value
+	if delta < 0 {
+		switch b.counterWrap {
+		case counterWrapReset:
+			return nil, false
+		case counterWrapWrap:
+			delta = b.counterMax - prev.value + v + 1
+		}
+	}
+	r := metricTypeOf(m)
+	if b.mode == rateModeDelta {
+		r.Data_ = delta
+		return r, true
+	}
+	elapsed := m.Timestamp().Sub(prev.at).Seconds()
+	if elapsed <= 0 {
+		return nil, false
+	}
+	r.Data_ = delta / elapsed
+	if r.Unit_ != "" {
+		r.Unit_ += "/s"
+	}
+	return r, true
+}
+
+// seriesKey returns the key of the series of metrics of the metric, made of
+// its namespace and its tags
+func seriesKey(m core.Metric) string {
+	tags := m.Tags()
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := []string{m.Namespace().String()}
+	for _, k := range keys {
+		parts = append(parts, k+"="+tags[k])
+	}
+	return strings.Join(parts, ",")
+}
+
+// metricTypeOf returns a copy of the metric
+func metricTypeOf(m core.Metric) plugin.MetricType {
+	return plugin.MetricType{
+		Namespace_:          m.Namespace(),
+		LastAdvertisedTime_: m.LastAdvertisedTime(),
+		Version_:            m.Version(),
+		Config_:             m.Config(),
+		Data_:               m.Data(),
+		Tags_:               m.Tags(),
+		Unit_:               m.Unit(),
+		Description_:        m.Description(),
+		Timestamp_:          m.Timestamp(),
+	}
+}
+
+// configNumber returns the value of an int or float config value
+func configNumber(v ctypes.ConfigValue) (float64, bool) {
+	switch n := v.(type) {
+	case ctypes.ConfigValueInt:
+		return float64(n.Value), true
+	case ctypes.ConfigValueFloat:
+		return n.Value, true
+	}
+	return 0, false
+}
+
+// metricNumber returns the value of the data of a metric holding a number
+func metricNumber(data interface{}) (float64, bool) {
+	switch n := data.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,208 @@
+// +build legacy
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+	"github.com/intelsdi-x/snap/scheduler/wmap"
+)
+
+func TestBuiltinStep(t *testing.T) {
+	newMetric := func(data interface{}, ns ...string) core.Metric {
+		return plugin.MetricType{Namespace_: core.NewNamespace(ns...), Data_: data}
+	}
+	config := func(items map[string]ctypes.ConfigValue) *cdata.ConfigDataNode {
+		return cdata.FromTable(items)
+	}
+	values := func(mts []core.Metric) []interface{} {
+		out := make([]interface{}, len(mts))
+		for i, m := range mts {
+			out[i] = m.Data()
+		}
+		return out
+	}
+	Convey("newBuiltinStep", t, func() {
+		Convey("refuses an unknown step", func() {
+			_, err := newBuiltinStep("sample", cdata.NewNode())
+			So(err, ShouldEqual, ErrUnknownBuiltinStep)
+		})
+		Convey("refuses a filter without bounds", func() {
+			_, err := newBuiltinStep(builtinFilter, cdata.NewNode())
+			So(err, ShouldEqual, ErrInvalidBuiltinStep)
+		})
+		Convey("refuses a downsample both keeping and averaging", func() {
+			_, err := newBuiltinStep(builtinDownsample, config(map[string]ctypes.ConfigValue{
+				"keep_every": ctypes.ConfigValueInt{Value: 2},
+				"average":    ctypes.ConfigValueInt{Value: 2},
+			}))
+			So(err, ShouldEqual, ErrInvalidBuiltinStep)
+		})
+	})
+	Convey("A filter step", t, func() {
+		b, err := newBuiltinStep(builtinFilter, config(map[string]ctypes.ConfigValue{
+			"min": ctypes.ConfigValueInt{Value: 10},
+			"max": ctypes.ConfigValueFloat{Value: 20.5},
+		}))
+		So(err, ShouldBeNil)
+		mts := []core.Metric{
+			newMetric(5, "a"), newMetric(10, "a"), newMetric(int64(20), "b"),
+			newMetric(20.6, "b"), newMetric("text", "c"),
+		}
+		Convey("keeps the values within its bounds and the values which are not numbers", func() {
+			out, errs := b.ProcessMetrics(mts, nil, "", "", 0)
+			So(errs, ShouldBeEmpty)
+			So(values(out), ShouldResemble, []interface{}{10, int64(20), "text"})
+		})
+	})
+	Convey("A downsample step keeping one of every few metrics", t, func() {
+		b, err := newBuiltinStep(builtinDownsample, config(map[string]ctypes.ConfigValue{
+			"keep_every": ctypes.ConfigValueInt{Value: 3},
+		}))
+		So(err, ShouldBeNil)
+		Convey("counts the metrics of each namespace across firings", func() {
+			out, _ := b.ProcessMetrics([]core.Metric{newMetric(1, "a"), newMetric(2, "a"), newMetric(1, "b")}, nil, "", "", 0)
+			So(values(out), ShouldResemble, []interface{}{1, 1})
+			out, _ = b.ProcessMetrics([]core.Metric{newMetric(3, "a"), newMetric(4, "a")}, nil, "", "", 0)
+			So(values(out), ShouldResemble, []interface{}{4})
+		})
+	})
+	Convey("A downsample step averaging metrics", t, func() {
+		b, err := newBuiltinStep(builtinDownsample, config(map[string]ctypes.ConfigValue{
+			"average": ctypes.ConfigValueInt{Value: 2},
+		}))
+		So(err, ShouldBeNil)
+		Convey("emits the average of each window of a namespace", func() {
+			out, _ := b.ProcessMetrics([]core.Metric{newMetric(1, "a"), newMetric(4.0, "b")}, nil, "", "", 0)
+			So(out, ShouldBeEmpty)
+			out, _ = b.ProcessMetrics([]core.Metric{newMetric(2, "a"), newMetric(6, "b"), newMetric(8, "a")}, nil, "", "", 0)
+			So(values(out), ShouldResemble, []interface{}{1.5, 5.0})
+			So(out[0].Namespace().String(), ShouldEqual, "/a")
+		})
+	})
+	Convey("newBuiltinStep refuses a rate", t, func() {
+		Convey("of an unknown mode", func() {
+			_, err := newBuiltinStep(builtinRate, config(map[string]ctypes.ConfigValue{
+				"mode": ctypes.ConfigValueStr{Value: "derivative"},
+			}))
+			So(err, ShouldEqual, ErrInvalidBuiltinStep)
+		})
+		Convey("wrapping without a counter_max", func() {
+			_, err := newBuiltinStep(builtinRate, config(map[string]ctypes.ConfigValue{
+				"counter_wrap": ctypes.ConfigValueStr{Value: "wrap"},
+			}))
+			So(err, ShouldEqual, ErrInvalidBuiltinStep)
+		})
+	})
+	Convey("A rate step", t, func() {
+		start := time.Now()
+		sample := func(data interface{}, after time.Duration, ns string, tags map[string]string) core.Metric {
+			return plugin.MetricType{Namespace_: core.NewNamespace(ns), Data_: data, Timestamp_: start.Add(after), Tags_: tags, Unit_: "B"}
+		}
+		b, err := newBuiltinStep(builtinRate, cdata.NewNode())
+		So(err, ShouldBeNil)
+		Convey("emits the change per second of each series across firings", func() {
+			out, _ := b.ProcessMetrics([]core.Metric{
+				sample(100, 0, "a", nil),
+				sample(10, 0, "a", map[string]string{"if": "eth1"}),
+				sample("text", 0, "b", nil),
+			}, nil, "", "", 0)
+			So(values(out), ShouldResemble, []interface{}{"text"})
+			out, _ = b.ProcessMetrics([]core.Metric{
+				sample(300, 10*time.Second, "a", nil),
+				sample(40, 5*time.Second, "a", map[string]string{"if": "eth1"}),
+			}, nil, "", "", 0)
+			So(values(out), ShouldResemble, []interface{}{20.0, 6.0})
+			So(out[0].Unit(), ShouldEqual, "B/s")
+		})
+		Convey("takes a decreasing counter for a reset by default", func() {
+			b.ProcessMetrics([]core.Metric{sample(100, 0, "a", nil)}, nil, "", "", 0)
+			out, _ := b.ProcessMetrics([]core.Metric{sample(5, time.Second, "a", nil)}, nil, "", "", 0)
+			So(out, ShouldBeEmpty)
+			out, _ = b.ProcessMetrics([]core.Metric{sample(15, 2*time.Second, "a", nil)}, nil, "", "", 0)
+			So(values(out), ShouldResemble, []interface{}{10.0})
+		})
+	})
+	Convey("A delta step", t, func() {
+		sample := func(data interface{}) core.Metric {
+			return plugin.MetricType{Namespace_: core.NewNamespace("a"), Data_: data}
+		}
+		Convey("wraps a counter past its counter_max", func() {
+			b, err := newBuiltinStep(builtinRate, config(map[string]ctypes.ConfigValue{
+				"mode":         ctypes.ConfigValueStr{Value: "delta"},
+				"counter_wrap": ctypes.ConfigValueStr{Value: "wrap"},
+				"counter_max":  ctypes.ConfigValueInt{Value: 255},
+			}))
+			So(err, ShouldBeNil)
+			b.ProcessMetrics([]core.Metric{sample(uint8(250))}, nil, "", "", 0)
+			out, _ := b.ProcessMetrics([]core.Metric{sample(uint8(4))}, nil, "", "", 0)
+			So(values(out), ShouldResemble, []interface{}{10.0})
+		})
+		Convey("emits the negative change of a gauge", func() {
+			b, err := newBuiltinStep(builtinRate, config(map[string]ctypes.ConfigValue{
+				"mode":         ctypes.ConfigValueStr{Value: "delta"},
+				"counter_wrap": ctypes.ConfigValueStr{Value: "none"},
+			}))
+			So(err, ShouldBeNil)
+			b.ProcessMetrics([]core.Metric{sample(7.5)}, nil, "", "", 0)
+			out, _ := b.ProcessMetrics([]core.Metric{sample(2.5)}, nil, "", "", 0)
+			So(values(out), ShouldResemble, []interface{}{-5.0})
+		})
+	})
+	Convey("A workflow with a built-in step", t, func() {
+		w := wmap.NewWorkflowMap()
+		w.Collect.AddMetric("/intel/mock/foo", 1)
+		pr := &wmap.ProcessWorkflowMapNode{Builtin: builtinFilter, Config: map[string]interface{}{"max": 10}}
+		pr.Add(wmap.NewPublishNode("file", 1))
+		w.Collect.Add(pr)
+		Convey("is valid", func() {
+			So(validateWorkflowMap(w), ShouldBeEmpty)
+		})
+		Convey("subscribes no plugin for the step", func() {
+			wf, err := wmapToWorkflow(w)
+			So(err, ShouldBeNil)
+			So(wf.processNodes[0].builtin, ShouldNotBeNil)
+			plugins := getWorkflowPlugins(wf.processNodes, wf.publishNodes, wf.metrics)[""].subscribedPlugins
+			So(plugins, ShouldHaveLength, 1)
+			So(plugins[0].Name(), ShouldEqual, "file")
+		})
+		Convey("is refused with an invalid config", func() {
+			w.Collect.Process[0].Config = map[string]interface{}{"max": "ten"}
+			errs := validateWorkflowMap(w)
+			So(errs, ShouldHaveLength, 1)
+			So(errs[0].Error(), ShouldEqual, ErrInvalidBuiltinStep.Error()+": collect.process[0]")
+		})
+		Convey("is refused with a plugin name", func() {
+			w.Collect.Process[0].PluginName = "passthru"
+			errs := validateWorkflowMap(w)
+			So(errs, ShouldHaveLength, 1)
+			So(errs[0].Error(), ShouldEqual, ErrBuiltinStepWithPlugin.Error()+": collect.process[0]")
+		})
+	})
+}
@@ -0,0 +1,199 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"context"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/serror"
+)
+
+// StartTasks starts the tasks in the given order, a paused task is resumed.
+// The errors of the tasks which could not be started are returned together,
+// each error holding the id of its task in its "task-id" field. A
+// transactional start gives up on the first failure and rolls back the tasks
+// it started: they are stopped again, or paused again if they were paused.
+func (s *scheduler) StartTasks(ids []string, transactional bool) core.TaskErrors {
+	te := &taskErrors{}
+	var started []bulkTask
+	for _, id := range ids {
+		bt := s.bulkTask(id)
+		if errs := s.startTask(context.Background(), id, "user"); len(errs) > 0 {
+			te.addTaskErrors(id, errs)
+			if transactional {
+				s.rollback("start-tasks", started, func(bt bulkTask) []serror.SnapError {
+					if bt.state == core.TaskPaused {
+						return s.PauseTask(bt.id)
+					}
+					return s.stopTask(context.Background(), bt.id, "user")
+				})
+				return te
+			}
+			continue
+		}
+		started = append(started, bt)
+	}
+	return te
+}
+
+// StopTasks stops the tasks in the given order. The errors of the tasks which
+// could not be stopped are returned together as in StartTasks. A
+// transactional stop first checks that every task can be stopped and stops
+// none otherwise, a task failing to stop meanwhile rolls back the tasks
+// already stopped: they are started again, and paused again if they were
+// paused.
+func (s *scheduler) StopTasks(ids []string, transactional bool) core.TaskErrors {
+	te := &taskErrors{}
+	if transactional {
+		for _, id := range ids {
+			if err := s.stoppable(id); err != nil {
+				te.addTaskErrors(id, []serror.SnapError{serror.New(err)})
+			}
+		}
+		if len(te.errs) > 0 {
+			return te
+		}
+	}
+	var stopped []bulkTask
+	for _, id := range ids {
+		bt := s.bulkTask(id)
+		if errs := s.stopTask(context.Background(), id, "user"); len(errs) > 0 {
+			te.addTaskErrors(id, errs)
+			if transactional {
+				s.rollback("stop-tasks", stopped, func(bt bulkTask) []serror.SnapError {
+					// a task is only started again once done stopping
+					if t, err := s.getTask(bt.id); err == nil && t.State() == core.TaskStopping {
+						t.waitForStop(t.DeadlineDuration())
+					}
+					if errs := s.startTask(context.Background(), bt.id, "user"); len(errs) > 0 {
+						return errs
+					}
+					if bt.state == core.TaskPaused {
+						return s.PauseTask(bt.id)
+					}
+					return nil
+				})
+				return te
+			}
+			continue
+		}
+		stopped = append(stopped, bt)
+	}
+	return te
+}
+
+// RemoveTasks removes the tasks in the given order. The errors of the tasks
+// which could not be removed are returned together as in StartTasks. As a
+// removed task cannot be restored, a transactional removal first checks that
+// every task can be removed and removes none otherwise.
+func (s *scheduler) RemoveTasks(ids []string, transactional bool) core.TaskErrors {
+	te := &taskErrors{}
+	if transactional {
+		for _, id := range ids {
+			if err := s.removable(id); err != nil {
+				te.addTaskErrors(id, []serror.SnapError{serror.New(err)})
+			}
+		}
+		if len(te.errs) > 0 {
+			return te
+		}
+	}
+	for _, id := range ids {
+		if err := s.removeTask(context.Background(), id, "user"); err != nil {
+			te.addTaskErrors(id, []serror.SnapError{serror.New(err)})
+		}
+	}
+	return te
+}
+
+// bulkTask is a task of a bulk operation along with its state before the
+// operation, which a rollback brings it back to
+type bulkTask struct {
+	id    string
+	state core.TaskState
+}
+
+func (s *scheduler) bulkTask(id string) bulkTask {
+	bt := bulkTask{id: id}
+	if t, err := s.getTask(id); err == nil {
+		bt.state = t.State()
+	}
+	return bt
+}
+
+// rollback undoes a bulk operation on the tasks in the reverse order, the
+// errors of the rollback are only logged as the operation already failed
+func (s *scheduler) rollback(block string, tasks []bulkTask, undo func(bulkTask) []serror.SnapError) {
+	for i := len(tasks) - 1; i >= 0; i-- {
+		for _, err := range undo(tasks[i]) {
+			schedulerLogger.WithFields(log.Fields{
+				"_block":  block,
+				"_error":  err.Error(),
+				"task-id": tasks[i].id,
+			}).Error("error rolling back task")
+		}
+	}
+}
+
+// stoppable returns the error StopTask would return for the task, if any
+func (s *scheduler) stoppable(id string) error {
+	t, err := s.getTask(id)
+	if err != nil {
+		return err
+	}
+	switch t.State() {
+	case core.TaskStopped:
+		return ErrTaskAlreadyStopped
+	case core.TaskEnded:
+		return ErrTaskEndedNotStoppable
+	case core.TaskDisabled:
+		return ErrTaskDisabledNotStoppable
+	}
+	return nil
+}
+
+// removable returns the error RemoveTask would return for the task, if any
+func (s *scheduler) removable(id string) error {
+	t, err := s.getTask(id)
+	if err != nil {
+		return err
+	}
+	if state := t.State(); state == core.TaskSpinning || state == core.TaskFiring {
+		return ErrTaskNotStopped
+	}
+	return nil
+}
+
+// addTaskErrors adds the errors of a task, setting the id of the task in
+// their fields
+func (t *taskErrors) addTaskErrors(id string, errs []serror.SnapError) {
+	for _, err := range errs {
+		fields := map[string]interface{}{}
+		for k, v := range err.Fields() {
+			fields[k] = v
+		}
+		fields["task-id"] = id
+		err.SetFields(fields)
+		t.errs = append(t.errs, err)
+	}
+}
@@ -0,0 +1,242 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/pkg/chrono"
+)
+
+// CircuitState is the state of the circuit breaker of a namespace prefix
+type CircuitState int
+
+const (
+	// CircuitClosed lets the collections through
+	CircuitClosed CircuitState = iota
+	// CircuitOpen skips the collections until its cooldown is over
+	CircuitOpen
+	// CircuitHalfOpen lets a single collection through to probe whether
+	// the metrics can be collected again
+	CircuitHalfOpen
+)
+
+var circuitStateLookup = map[CircuitState]string{
+	CircuitClosed:   "closed",
+	CircuitOpen:     "open",
+	CircuitHalfOpen: "half-open",
+}
+
+func (c CircuitState) String() string {
+	return circuitStateLookup[c]
+}
+
+// circuit is the breaker of the collections of the metrics under a namespace
+// prefix
+type circuit struct {
+	state CircuitState
+	// failures is the number of consecutive failed collections
+	failures uint
+	// cooldown is how long the circuit stays open, doubled each time a
+	// probe fails
+	cooldown  time.Duration
+	openUntil time.Time
+}
+
+// circuitTransition is a change of state of the circuit of a prefix, caused
+// by a collection of the task
+type circuitTransition struct {
+	taskID string
+	prefix string
+	state  CircuitState
+}
+
+// circuitBreakers trips the circuit of a namespace prefix once the
+// collections of the metrics under it failed threshold times in a row. The
+// collections of the tasks with metrics under an open circuit are skipped
+// until its cooldown is over, then a single collection probes the circuit,
+// closing it if it succeeds or opening it again for twice as long, up to the
+// maximum cooldown, if it fails.
+type circuitBreakers struct {
+	mutex       sync.Mutex
+	threshold   uint
+	cooldown    time.Duration
+	maxCooldown time.Duration
+	// depth is the number of elements of a namespace in its prefix
+	depth    int
+	circuits map[string]*circuit
+	// changed is called on each transition of a circuit
+	changed func(taskID, prefix string, state CircuitState)
+}
+
+func newCircuitBreakers(threshold uint, cooldown, maxCooldown time.Duration, depth int, changed func(taskID, prefix string, state CircuitState)) *circuitBreakers {
+	if maxCooldown < cooldown {
+		maxCooldown = cooldown
+	}
+	return &circuitBreakers{
+		threshold:   threshold,
+		cooldown:    cooldown,
+		maxCooldown: maxCooldown,
+		depth:       depth,
+		circuits:    make(map[string]*circuit),
+		changed:     changed,
+	}
+}
+
+// prefixes returns the namespace prefixes of the metrics, sorted
+func (b *circuitBreakers) prefixes(mts []core.RequestedMetric) []string {
+	return namespacePrefixes(mts, b.depth)
+}
+
+// namespacePrefixes returns the prefixes of depth elements of the namespaces
+// of the metrics, sorted
+func namespacePrefixes(mts []core.RequestedMetric, depth int) []string {
+	seen := make(map[string]struct{})
+	for _, m := range mts {
+		ns := m.Namespace()
+		if len(ns) > depth {
+			ns = ns[:depth]
+		}
+		seen[ns.String()] = struct{}{}
+	}
+	prefixes := make([]string, 0, len(seen))
+	for p := range seen {
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// allow returns whether a collection of the task of the metrics under the
+// prefixes may run, none of their circuits being open. The circuits whose
+// cooldown is over turn half-open and let the collection through as their
+// probe.
+func (b *circuitBreakers) allow(taskID string, prefixes []string) bool {
+	var transitions []circuitTransition
+	defer func() { b.notify(transitions) }()
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	at := chrono.Chrono.Now()
+	for _, p := range prefixes {
+		c, ok := b.circuits[p]
+		if !ok {
+			continue
+		}
+		switch c.state {
+		case CircuitHalfOpen:
+			// the probe of the circuit is running
+			return false
+		case CircuitOpen:
+			if at.Before(c.openUntil) {
+				return false
+			}
+		}
+	}
+	for _, p := range prefixes {
+		if c, ok := b.circuits[p]; ok && c.state == CircuitOpen {
+			c.state = CircuitHalfOpen
+			transitions = append(transitions, circuitTransition{taskID: taskID, prefix: p, state: CircuitHalfOpen})
+		}
+	}
+	return true
+}
+
+// record records whether a collection of the task of the metrics under the
+// prefixes failed
+func (b *circuitBreakers) record(taskID string, prefixes []string, failed bool) {
+	var transitions []circuitTransition
+	defer func() { b.notify(transitions) }()
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	at := chrono.Chrono.Now()
+	for _, p := range prefixes {
+		c, ok := b.circuits[p]
+		if !failed {
+			if !ok {
+				continue
+			}
+			if c.state == CircuitHalfOpen {
+				transitions = append(transitions, circuitTransition{taskID: taskID, prefix: p, state: CircuitClosed})
+			}
+			// a collection started before the circuit opened leaves it open
+			if c.state != CircuitOpen {
+				delete(b.circuits, p)
+			}
+			continue
+		}
+		if !ok {
+			c = &circuit{cooldown: b.cooldown}
+			b.circuits[p] = c
+		}
+		switch c.state {
+		case CircuitClosed:
+			c.failures++
+			if c.failures < b.threshold {
+				continue
+			}
+		case CircuitHalfOpen:
+			c.cooldown *= 2
+			if c.cooldown > b.maxCooldown {
+				c.cooldown = b.maxCooldown
+			}
+		case CircuitOpen:
+			continue
+		}
+		c.state = CircuitOpen
+		c.openUntil = at.Add(c.cooldown)
+		transitions = append(transitions, circuitTransition{taskID: taskID, prefix: p, state: CircuitOpen})
+	}
+}
+
+// states returns the state of the circuit of each prefix which is not closed
+func (b *circuitBreakers) states() map[string]CircuitState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	states := make(map[string]CircuitState)
+	for p, c := range b.circuits {
+		if c.state != CircuitClosed {
+			states[p] = c.state
+		}
+	}
+	return states
+}
+
+// notify logs the transitions and passes them to changed, outside of the
+// lock of the breakers
+func (b *circuitBreakers) notify(transitions []circuitTransition) {
+	for _, tr := range transitions {
+		schedulerLogger.WithFields(log.Fields{
+			"_block":  "circuit-breaker",
+			"task-id": tr.taskID,
+			"prefix":  tr.prefix,
+			"state":   tr.state.String(),
+		}).Info("circuit breaker changed state")
+		if b.changed != nil {
+			b.changed(tr.taskID, tr.prefix, tr.state)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// +build legacy
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/pkg/chrono"
+)
+
+func TestCircuitBreakers(t *testing.T) {
+	Convey("circuitBreakers", t, func() {
+		defer chrono.Chrono.Reset()
+		defer chrono.Chrono.Continue()
+		chrono.Chrono.Pause()
+
+		var states []CircuitState
+		b := newCircuitBreakers(2, time.Second, 3*time.Second, 2, func(taskID, prefix string, state CircuitState) {
+			states = append(states, state)
+		})
+		prefixes := b.prefixes([]core.RequestedMetric{
+			&metric{namespace: core.NewNamespace("intel", "mock", "foo")},
+			&metric{namespace: core.NewNamespace("intel", "mock", "bar")},
+			&metric{namespace: core.NewNamespace("intel")},
+		})
+		Convey("groups the metrics by namespace prefix", func() {
+			So(prefixes, ShouldResemble, []string{"/intel", "/intel/mock"})
+		})
+		Convey("stays closed until the collections failed threshold times in a row", func() {
+			b.record("t1", prefixes, true)
+			b.record("t1", prefixes, false)
+			b.record("t1", prefixes, true)
+			So(b.allow("t1", prefixes), ShouldBeTrue)
+			So(states, ShouldBeEmpty)
+		})
+		Convey("once open", func() {
+			b.record("t1", prefixes, true)
+			b.record("t1", prefixes, true)
+			So(states, ShouldResemble, []CircuitState{CircuitOpen, CircuitOpen})
+			So(b.states(), ShouldResemble, map[string]CircuitState{"/intel": CircuitOpen, "/intel/mock": CircuitOpen})
+			Convey("skips the collections of any task with metrics under the prefixes", func() {
+				So(b.allow("t1", prefixes), ShouldBeFalse)
+				So(b.allow("t2", []string{"/intel/mock"}), ShouldBeFalse)
+				So(b.allow("t3", []string{"/acme"}), ShouldBeTrue)
+			})
+			Convey("lets a single probe through once the cooldown is over", func() {
+				chrono.Chrono.Forward(time.Second)
+				So(b.allow("t1", prefixes), ShouldBeTrue)
+				So(b.allow("t2", prefixes), ShouldBeFalse)
+				So(b.states()["/intel/mock"], ShouldEqual, CircuitHalfOpen)
+				Convey("closing the circuits if it succeeds", func() {
+					b.record("t1", prefixes, false)
+					So(b.states(), ShouldBeEmpty)
+					So(states[len(states)-1], ShouldEqual, CircuitClosed)
+					So(b.allow("t2", prefixes), ShouldBeTrue)
+				})
+				Convey("opening them again for longer if it fails", func() {
+					b.record("t1", prefixes, true)
+					So(b.states()["/intel/mock"], ShouldEqual, CircuitOpen)
+					// the clock is forwarded from the time it was paused
+					chrono.Chrono.Forward(2 * time.Second)
+					So(b.allow("t1", prefixes), ShouldBeFalse)
+					chrono.Chrono.Forward(3 * time.Second)
+					So(b.allow("t1", prefixes), ShouldBeTrue)
+				})
+			})
+		})
+	})
+}
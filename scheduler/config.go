@@ -26,17 +26,63 @@ import (
 
 // default configuration values
 const (
-	defaultWorkManagerQueueSize uint = 25
-	defaultWorkManagerPoolSize  uint = 4
+	defaultWorkManagerQueueSize         uint = 25
+	defaultWorkManagerPoolSize          uint = 4
+	defaultWorkManagerQueueFullPolicy        = "drop-newest"
+	defaultWorkManagerMaxWorkerRestarts      = defaultMaxWorkerRestarts
+	defaultTaskEventBufferSize          uint = TaskEventBufferSize
+	defaultLeaderLeaseTTL               uint = 15
+	defaultCircuitBreakerCooldown       uint = 30
+	defaultCircuitBreakerMaxCooldown    uint = 300
+	defaultCircuitBreakerPrefixDepth    uint = 2
+	defaultThrottlePrefixDepth          uint = 2
+	defaultAuditLogSize                 uint = 1000
+	defaultAdaptiveQueueThreshold       uint = 80
+	defaultAdaptiveCPUThreshold         uint = 90
+	defaultAdaptiveCheckInterval        uint = 10
+	defaultStopDrainPolicy                   = "graceful"
+	defaultTaskHistorySize              uint = 100
 )
 
 // holds the configuration passed in through the SNAP config file
-//   Note: if this struct is modified, then the switch statement in the
-//         UnmarshalJSON method in this same file needs to be modified to
-//         match the field mapping that is defined here
+//
+//	Note: if this struct is modified, then the switch statement in the
+//	      UnmarshalJSON method in this same file needs to be modified to
+//	      match the field mapping that is defined here
 type Config struct {
-	WorkManagerQueueSize uint `json:"work_manager_queue_size"yaml:"work_manager_queue_size"`
-	WorkManagerPoolSize  uint `json:"work_manager_pool_size"yaml:"work_manager_pool_size"`
+	WorkManagerQueueSize         uint   `json:"work_manager_queue_size"yaml:"work_manager_queue_size"`
+	WorkManagerPoolSize          uint   `json:"work_manager_pool_size"yaml:"work_manager_pool_size"`
+	WorkManagerProcessQueueSize  uint   `json:"work_manager_process_queue_size"yaml:"work_manager_process_queue_size"`
+	WorkManagerProcessPoolSize   uint   `json:"work_manager_process_pool_size"yaml:"work_manager_process_pool_size"`
+	WorkManagerPublishQueueSize  uint   `json:"work_manager_publish_queue_size"yaml:"work_manager_publish_queue_size"`
+	WorkManagerPublishPoolSize   uint   `json:"work_manager_publish_pool_size"yaml:"work_manager_publish_pool_size"`
+	WorkManagerTaskQueueSize     uint   `json:"work_manager_task_queue_size"yaml:"work_manager_task_queue_size"`
+	WorkManagerQueueFullPolicy   string `json:"work_manager_queue_full_policy"yaml:"work_manager_queue_full_policy"`
+	WorkManagerMaxWorkerRestarts uint   `json:"work_manager_max_worker_restarts"yaml:"work_manager_max_worker_restarts"`
+	TaskEventBufferSize          uint   `json:"task_event_buffer_size"yaml:"task_event_buffer_size"`
+	TaskStorePath                string `json:"task_store_path"yaml:"task_store_path"`
+	LeaderLeasePath              string `json:"leader_lease_path"yaml:"leader_lease_path"`
+	LeaderLeaseTTL               uint   `json:"leader_lease_ttl"yaml:"leader_lease_ttl"`
+	UniqueTaskNames              bool   `json:"unique_task_names"yaml:"unique_task_names"`
+	DeadLetterPath               string `json:"dead_letter_path"yaml:"dead_letter_path"`
+	SecretKeyPath                string `json:"secret_key_path"yaml:"secret_key_path"`
+	CircuitBreakerThreshold      uint   `json:"circuit_breaker_threshold"yaml:"circuit_breaker_threshold"`
+	CircuitBreakerCooldown       uint   `json:"circuit_breaker_cooldown"yaml:"circuit_breaker_cooldown"`
+	CircuitBreakerMaxCooldown    uint   `json:"circuit_breaker_max_cooldown"yaml:"circuit_breaker_max_cooldown"`
+	CircuitBreakerPrefixDepth    uint   `json:"circuit_breaker_prefix_depth"yaml:"circuit_breaker_prefix_depth"`
+	MaxConcurrentCollections     uint   `json:"max_concurrent_collections"yaml:"max_concurrent_collections"`
+	ThrottlePrefixDepth          uint   `json:"throttle_prefix_depth"yaml:"throttle_prefix_depth"`
+	AuditLogSize                 uint   `json:"audit_log_size"yaml:"audit_log_size"`
+	AdaptiveMaxStretch           uint   `json:"adaptive_max_stretch"yaml:"adaptive_max_stretch"`
+	AdaptiveQueueThreshold       uint   `json:"adaptive_queue_threshold"yaml:"adaptive_queue_threshold"`
+	AdaptiveCPUThreshold         uint   `json:"adaptive_cpu_threshold"yaml:"adaptive_cpu_threshold"`
+	AdaptiveCheckInterval        uint   `json:"adaptive_check_interval"yaml:"adaptive_check_interval"`
+	StopDrainPolicy              string `json:"stop_drain_policy"yaml:"stop_drain_policy"`
+	TenantMaxTasks               uint   `json:"tenant_max_tasks"yaml:"tenant_max_tasks"`
+	TenantMaxMetrics             uint   `json:"tenant_max_metrics"yaml:"tenant_max_metrics"`
+	TenantMaxFiresPerMinute      uint   `json:"tenant_max_fires_per_minute"yaml:"tenant_max_fires_per_minute"`
+	TaskHistorySize              uint   `json:"task_history_size"yaml:"task_history_size"`
+	TaskHistoryPath              string `json:"task_history_path"yaml:"task_history_path"`
 }
 
 const (
@@ -51,6 +97,126 @@ const (
 					"work_manager_pool_size" : {
 						"type": "integer",
 						"minimum": 1
+					},
+					"work_manager_process_queue_size" : {
+						"type": "integer",
+						"minimum": 0
+					},
+					"work_manager_process_pool_size" : {
+						"type": "integer",
+						"minimum": 0
+					},
+					"work_manager_publish_queue_size" : {
+						"type": "integer",
+						"minimum": 0
+					},
+					"work_manager_publish_pool_size" : {
+						"type": "integer",
+						"minimum": 0
+					},
+					"work_manager_task_queue_size" : {
+						"type": "integer",
+						"minimum": 0
+					},
+					"work_manager_queue_full_policy" : {
+						"type": "string",
+						"enum": ["drop-newest", "drop-oldest", "block"]
+					},
+					"work_manager_max_worker_restarts" : {
+						"type": "integer",
+						"minimum": 0
+					},
+					"task_event_buffer_size" : {
+						"type": "integer",
+						"minimum": 1
+					},
+					"task_store_path" : {
+						"type": "string"
+					},
+					"leader_lease_path" : {
+						"type": "string"
+					},
+					"leader_lease_ttl" : {
+						"type": "integer",
+						"minimum": 1
+					},
+					"unique_task_names" : {
+						"type": "boolean"
+					},
+					"dead_letter_path" : {
+						"type": "string"
+					},
+					"secret_key_path" : {
+						"type": "string"
+					},
+					"circuit_breaker_threshold" : {
+						"type": "integer",
+						"minimum": 0
+					},
+					"circuit_breaker_cooldown" : {
+						"type": "integer",
+						"minimum": 1
+					},
+					"circuit_breaker_max_cooldown" : {
+						"type": "integer",
+						"minimum": 1
+					},
+					"circuit_breaker_prefix_depth" : {
+						"type": "integer",
+						"minimum": 1
+					},
+					"max_concurrent_collections" : {
+						"type": "integer",
+						"minimum": 0
+					},
+					"throttle_prefix_depth" : {
+						"type": "integer",
+						"minimum": 1
+					},
+					"audit_log_size" : {
+						"type": "integer",
+						"minimum": 0
+					},
+					"adaptive_max_stretch" : {
+						"type": "integer",
+						"minimum": 0
+					},
+					"adaptive_queue_threshold" : {
+						"type": "integer",
+						"minimum": 1,
+						"maximum": 100
+					},
+					"adaptive_cpu_threshold" : {
+						"type": "integer",
+						"minimum": 0,
+						"maximum": 100
+					},
+					"adaptive_check_interval" : {
+						"type": "integer",
+						"minimum": 1
+					},
+					"stop_drain_policy" : {
+						"type": "string",
+						"enum": ["graceful", "abandon"]
+					},
+					"tenant_max_tasks" : {
+						"type": "integer",
+						"minimum": 0
+					},
+					"tenant_max_metrics" : {
+						"type": "integer",
+						"minimum": 0
+					},
+					"tenant_max_fires_per_minute" : {
+						"type": "integer",
+						"minimum": 0
+					},
+					"task_history_size" : {
+						"type": "integer",
+						"minimum": 0
+					},
+					"task_history_path" : {
+						"type": "string"
 					}
 				},
 				"additionalProperties": false
@@ -61,8 +227,209 @@ const (
 // get the default snapteld configuration
 func GetDefaultConfig() *Config {
 	return &Config{
-		WorkManagerQueueSize: defaultWorkManagerQueueSize,
-		WorkManagerPoolSize:  defaultWorkManagerPoolSize,
+		WorkManagerQueueSize:         defaultWorkManagerQueueSize,
+		WorkManagerPoolSize:          defaultWorkManagerPoolSize,
+		WorkManagerQueueFullPolicy:   defaultWorkManagerQueueFullPolicy,
+		WorkManagerMaxWorkerRestarts: defaultWorkManagerMaxWorkerRestarts,
+		TaskEventBufferSize:          defaultTaskEventBufferSize,
+		LeaderLeaseTTL:               defaultLeaderLeaseTTL,
+		CircuitBreakerCooldown:       defaultCircuitBreakerCooldown,
+		CircuitBreakerMaxCooldown:    defaultCircuitBreakerMaxCooldown,
+		CircuitBreakerPrefixDepth:    defaultCircuitBreakerPrefixDepth,
+		ThrottlePrefixDepth:          defaultThrottlePrefixDepth,
+		AuditLogSize:                 defaultAuditLogSize,
+		AdaptiveQueueThreshold:       defaultAdaptiveQueueThreshold,
+		AdaptiveCPUThreshold:         defaultAdaptiveCPUThreshold,
+		AdaptiveCheckInterval:        defaultAdaptiveCheckInterval,
+		StopDrainPolicy:              defaultStopDrainPolicy,
+		TaskHistorySize:              defaultTaskHistorySize,
+	}
+}
+
+// SchedulerOption overrides a setting of the configuration given to New
+type SchedulerOption func(c *Config)
+
+// WithPoolSize sets the number of workers of each worker pool
+func WithPoolSize(n uint) SchedulerOption {
+	return func(c *Config) {
+		c.WorkManagerPoolSize = n
+	}
+}
+
+// WithQueueSize sets the number of jobs each work queue holds
+func WithQueueSize(n uint) SchedulerOption {
+	return func(c *Config) {
+		c.WorkManagerQueueSize = n
+	}
+}
+
+// WithProcessPoolSize sets the number of workers of the process worker pool,
+// the size of the other pools if 0
+func WithProcessPoolSize(n uint) SchedulerOption {
+	return func(c *Config) {
+		c.WorkManagerProcessPoolSize = n
+	}
+}
+
+// WithProcessQueueSize sets the number of jobs the process work queue holds,
+// the size of the other queues if 0
+func WithProcessQueueSize(n uint) SchedulerOption {
+	return func(c *Config) {
+		c.WorkManagerProcessQueueSize = n
+	}
+}
+
+// WithPublishPoolSize sets the number of workers of the publish worker pool,
+// the size of the other pools if 0
+func WithPublishPoolSize(n uint) SchedulerOption {
+	return func(c *Config) {
+		c.WorkManagerPublishPoolSize = n
+	}
+}
+
+// WithPublishQueueSize sets the number of jobs the publish work queue holds,
+// the size of the other queues if 0
+func WithPublishQueueSize(n uint) SchedulerOption {
+	return func(c *Config) {
+		c.WorkManagerPublishQueueSize = n
+	}
+}
+
+// WithTaskQueueSize sets the number of jobs of each task each work queue
+// holds, the queue sizes alone apply if 0
+func WithTaskQueueSize(n uint) SchedulerOption {
+	return func(c *Config) {
+		c.WorkManagerTaskQueueSize = n
+	}
+}
+
+// WithQueueFullPolicy sets what happens to a job submitted to a full work queue
+func WithQueueFullPolicy(p QueueFullPolicy) SchedulerOption {
+	return func(c *Config) {
+		c.WorkManagerQueueFullPolicy = p.String()
+	}
+}
+
+// WithEventBufferSize sets the number of task events buffered for each
+// subscriber of Events before the events are dropped for it
+func WithEventBufferSize(n uint) SchedulerOption {
+	return func(c *Config) {
+		c.TaskEventBufferSize = n
+	}
+}
+
+// WithTaskStorePath sets the file the tasks are kept in across restarts
+func WithTaskStorePath(path string) SchedulerOption {
+	return func(c *Config) {
+		c.TaskStorePath = path
+	}
+}
+
+// WithLeaderLeasePath sets the file the lease of the leader is kept in, for
+// the schedulers sharing a task store to elect the one firing the tasks
+func WithLeaderLeasePath(path string) SchedulerOption {
+	return func(c *Config) {
+		c.LeaderLeasePath = path
+	}
+}
+
+// WithLeaderLeaseTTL sets the number of seconds the lease of the leader lasts
+// unless renewed
+func WithLeaderLeaseTTL(n uint) SchedulerOption {
+	return func(c *Config) {
+		c.LeaderLeaseTTL = n
+	}
+}
+
+// WithUniqueTaskNames sets whether creating a task with the name of an
+// existing task fails
+func WithUniqueTaskNames(unique bool) SchedulerOption {
+	return func(c *Config) {
+		c.UniqueTaskNames = unique
+	}
+}
+
+// WithDeadLetterPath sets the directory the metrics of the publish jobs which
+// failed once their retries were exhausted are spooled to
+func WithDeadLetterPath(path string) SchedulerOption {
+	return func(c *Config) {
+		c.DeadLetterPath = path
+	}
+}
+
+// WithSecretKeyPath sets the file holding the key, hex encoded, the secret
+// config values of the tasks are encrypted with when they are saved or
+// exported
+func WithSecretKeyPath(path string) SchedulerOption {
+	return func(c *Config) {
+		c.SecretKeyPath = path
+	}
+}
+
+// WithCircuitBreaker sets the number of consecutive failed collections of
+// the metrics under a namespace prefix which open its circuit breaker, 0
+// disabling the breakers, and the number of seconds an opened circuit stays
+// open before it is probed
+func WithCircuitBreaker(threshold, cooldown uint) SchedulerOption {
+	return func(c *Config) {
+		c.CircuitBreakerThreshold = threshold
+		c.CircuitBreakerCooldown = cooldown
+	}
+}
+
+// WithMaxConcurrentCollections sets the number of collections of the metrics
+// under a namespace prefix which may run at once, 0 leaving them unlimited
+func WithMaxConcurrentCollections(max uint) SchedulerOption {
+	return func(c *Config) {
+		c.MaxConcurrentCollections = max
+	}
+}
+
+// WithAuditLogSize sets the number of task management operations kept in
+// the audit log, 0 disabling the log
+func WithAuditLogSize(size uint) SchedulerOption {
+	return func(c *Config) {
+		c.AuditLogSize = size
+	}
+}
+
+// WithAdaptiveIntervals enables the stretching of the intervals of the tasks
+// up to maxStretch times while the work queues are filled over queueThreshold
+// percent of their limit or the CPU of the host is busy over cpuThreshold
+// percent, 0 leaving the CPU out
+func WithAdaptiveIntervals(maxStretch, queueThreshold, cpuThreshold uint) SchedulerOption {
+	return func(c *Config) {
+		c.AdaptiveMaxStretch = maxStretch
+		c.AdaptiveQueueThreshold = queueThreshold
+		c.AdaptiveCPUThreshold = cpuThreshold
+	}
+}
+
+// WithDrainPolicy sets how the work in flight is handled when the scheduler
+// stops
+func WithDrainPolicy(p DrainPolicy) SchedulerOption {
+	return func(c *Config) {
+		c.StopDrainPolicy = p.String()
+	}
+}
+
+// WithTenantQuota sets the quota of each tenant whose quota is not set with
+// SetTenantQuota
+func WithTenantQuota(q TenantQuota) SchedulerOption {
+	return func(c *Config) {
+		c.TenantMaxTasks = q.MaxTasks
+		c.TenantMaxMetrics = q.MaxMetrics
+		c.TenantMaxFiresPerMinute = q.MaxFiresPerMinute
+	}
+}
+
+// WithTaskHistory sets the number of runs kept in the run history of each
+// task, 0 disabling it, and the directory they are saved in, none keeping
+// them in memory only
+func WithTaskHistory(size uint, path string) SchedulerOption {
+	return func(c *Config) {
+		c.TaskHistorySize = size
+		c.TaskHistoryPath = path
 	}
 }
 
@@ -88,6 +455,130 @@ func (c *Config) UnmarshalJSON(data []byte) error {
 			if err := json.Unmarshal(v, &(c.WorkManagerPoolSize)); err != nil {
 				return fmt.Errorf("%v (while parsing 'scheduler::work_manager_pool_size')", err)
 			}
+		case "work_manager_process_queue_size":
+			if err := json.Unmarshal(v, &(c.WorkManagerProcessQueueSize)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::work_manager_process_queue_size')", err)
+			}
+		case "work_manager_process_pool_size":
+			if err := json.Unmarshal(v, &(c.WorkManagerProcessPoolSize)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::work_manager_process_pool_size')", err)
+			}
+		case "work_manager_publish_queue_size":
+			if err := json.Unmarshal(v, &(c.WorkManagerPublishQueueSize)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::work_manager_publish_queue_size')", err)
+			}
+		case "work_manager_publish_pool_size":
+			if err := json.Unmarshal(v, &(c.WorkManagerPublishPoolSize)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::work_manager_publish_pool_size')", err)
+			}
+		case "work_manager_task_queue_size":
+			if err := json.Unmarshal(v, &(c.WorkManagerTaskQueueSize)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::work_manager_task_queue_size')", err)
+			}
+		case "work_manager_queue_full_policy":
+			if err := json.Unmarshal(v, &(c.WorkManagerQueueFullPolicy)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::work_manager_queue_full_policy')", err)
+			}
+		case "work_manager_max_worker_restarts":
+			if err := json.Unmarshal(v, &(c.WorkManagerMaxWorkerRestarts)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::work_manager_max_worker_restarts')", err)
+			}
+		case "task_event_buffer_size":
+			if err := json.Unmarshal(v, &(c.TaskEventBufferSize)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::task_event_buffer_size')", err)
+			}
+		case "task_store_path":
+			if err := json.Unmarshal(v, &(c.TaskStorePath)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::task_store_path')", err)
+			}
+		case "leader_lease_path":
+			if err := json.Unmarshal(v, &(c.LeaderLeasePath)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::leader_lease_path')", err)
+			}
+		case "leader_lease_ttl":
+			if err := json.Unmarshal(v, &(c.LeaderLeaseTTL)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::leader_lease_ttl')", err)
+			}
+		case "unique_task_names":
+			if err := json.Unmarshal(v, &(c.UniqueTaskNames)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::unique_task_names')", err)
+			}
+		case "dead_letter_path":
+			if err := json.Unmarshal(v, &(c.DeadLetterPath)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::dead_letter_path')", err)
+			}
+		case "circuit_breaker_threshold":
+			if err := json.Unmarshal(v, &(c.CircuitBreakerThreshold)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::circuit_breaker_threshold')", err)
+			}
+		case "circuit_breaker_cooldown":
+			if err := json.Unmarshal(v, &(c.CircuitBreakerCooldown)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::circuit_breaker_cooldown')", err)
+			}
+		case "circuit_breaker_max_cooldown":
+			if err := json.Unmarshal(v, &(c.CircuitBreakerMaxCooldown)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::circuit_breaker_max_cooldown')", err)
+			}
+		case "circuit_breaker_prefix_depth":
+			if err := json.Unmarshal(v, &(c.CircuitBreakerPrefixDepth)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::circuit_breaker_prefix_depth')", err)
+			}
+		case "max_concurrent_collections":
+			if err := json.Unmarshal(v, &(c.MaxConcurrentCollections)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::max_concurrent_collections')", err)
+			}
+		case "throttle_prefix_depth":
+			if err := json.Unmarshal(v, &(c.ThrottlePrefixDepth)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::throttle_prefix_depth')", err)
+			}
+		case "audit_log_size":
+			if err := json.Unmarshal(v, &(c.AuditLogSize)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::audit_log_size')", err)
+			}
+		case "adaptive_max_stretch":
+			if err := json.Unmarshal(v, &(c.AdaptiveMaxStretch)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::adaptive_max_stretch')", err)
+			}
+		case "adaptive_queue_threshold":
+			if err := json.Unmarshal(v, &(c.AdaptiveQueueThreshold)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::adaptive_queue_threshold')", err)
+			}
+		case "adaptive_cpu_threshold":
+			if err := json.Unmarshal(v, &(c.AdaptiveCPUThreshold)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::adaptive_cpu_threshold')", err)
+			}
+		case "adaptive_check_interval":
+			if err := json.Unmarshal(v, &(c.AdaptiveCheckInterval)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::adaptive_check_interval')", err)
+			}
+		case "stop_drain_policy":
+			if err := json.Unmarshal(v, &(c.StopDrainPolicy)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::stop_drain_policy')", err)
+			}
+		case "tenant_max_tasks":
+			if err := json.Unmarshal(v, &(c.TenantMaxTasks)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::tenant_max_tasks')", err)
+			}
+		case "tenant_max_metrics":
+			if err := json.Unmarshal(v, &(c.TenantMaxMetrics)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::tenant_max_metrics')", err)
+			}
+		case "tenant_max_fires_per_minute":
+			if err := json.Unmarshal(v, &(c.TenantMaxFiresPerMinute)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::tenant_max_fires_per_minute')", err)
+			}
+		case "task_history_size":
+			if err := json.Unmarshal(v, &(c.TaskHistorySize)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::task_history_size')", err)
+			}
+		case "task_history_path":
+			if err := json.Unmarshal(v, &(c.TaskHistoryPath)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::task_history_path')", err)
+			}
+		case "secret_key_path":
+			if err := json.Unmarshal(v, &(c.SecretKeyPath)); err != nil {
+				return fmt.Errorf("%v (while parsing 'scheduler::secret_key_path')", err)
+			}
 		default:
 			return fmt.Errorf("Unrecognized key '%v' in global config file while parsing 'scheduler'", k)
 		}
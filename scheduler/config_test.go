@@ -107,5 +107,14 @@ func TestSchedulerDefaultConfig(t *testing.T) {
 		Convey("WorkManagerPoolSize should equal 4", func() {
 			So(cfg.WorkManagerPoolSize, ShouldEqual, 4)
 		})
+		Convey("The process and publish queues and pools should be sized like the collect ones", func() {
+			So(cfg.WorkManagerProcessQueueSize, ShouldEqual, 0)
+			So(cfg.WorkManagerProcessPoolSize, ShouldEqual, 0)
+			So(cfg.WorkManagerPublishQueueSize, ShouldEqual, 0)
+			So(cfg.WorkManagerPublishPoolSize, ShouldEqual, 0)
+		})
+		Convey("Task names should not have to be unique", func() {
+			So(cfg.UniqueTaskNames, ShouldBeFalse)
+		})
 	})
 }
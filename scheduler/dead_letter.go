@@ -0,0 +1,285 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/pborman/uuid"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/cdata"
+)
+
+// DeadLetter is the batch of metrics of a publish job which still failed
+// once its retries were exhausted, along with the publisher and the error
+type DeadLetter struct {
+	ID            string                `json:"id"`
+	Time          time.Time             `json:"time"`
+	TaskID        string                `json:"task_id"`
+	PluginName    string                `json:"plugin_name"`
+	PluginVersion int                   `json:"plugin_version"`
+	Target        string                `json:"target,omitempty"`
+	Config        *cdata.ConfigDataNode `json:"config"`
+	Metrics       []plugin.MetricType   `json:"metrics"`
+	Error         string                `json:"error"`
+}
+
+// DeadLetterSink keeps the dead letters of a scheduler until they are
+// replayed with ReplayDeadLetters
+type DeadLetterSink interface {
+	// Put keeps a dead letter
+	Put(DeadLetter) error
+	// List returns the dead letters kept, oldest first
+	List() ([]DeadLetter, error)
+	// Remove discards the dead letter with the given id
+	Remove(id string) error
+}
+
+// FileDeadLetterSink is a DeadLetterSink spooling each dead letter to its own
+// file of a directory
+type FileDeadLetterSink struct {
+	dir string
+	// mutex serializes the changes to the directory
+	mutex sync.Mutex
+}
+
+// NewFileDeadLetterSink returns a FileDeadLetterSink spooling the dead
+// letters to the directory at dir, which is created on the first put
+func NewFileDeadLetterSink(dir string) *FileDeadLetterSink {
+	return &FileDeadLetterSink{dir: dir}
+}
+
+// Put writes the dead letter to a file named after its time and id, so that
+// the files sort in the order the letters were put
+func (f *FileDeadLetterSink) Put(dl DeadLetter) error {
+	data, err := json.Marshal(dl)
+	if err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := os.MkdirAll(f.dir, 0755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%020d-%s.json", dl.Time.UnixNano(), dl.ID)
+	return writeFileAtomic(filepath.Join(f.dir, name), data)
+}
+
+// List reads the dead letters spooled to the directory, none if it does not
+// exist
+func (f *FileDeadLetterSink) List() ([]DeadLetter, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	names, err := f.files()
+	if err != nil {
+		return nil, err
+	}
+	dls := make([]DeadLetter, 0, len(names))
+	for _, name := range names {
+		data, err := ioutil.ReadFile(filepath.Join(f.dir, name))
+		if err != nil {
+			return nil, err
+		}
+		var dl DeadLetter
+		if err := json.Unmarshal(data, &dl); err != nil {
+			return nil, fmt.Errorf("%v (while reading the dead letter %s)", err, name)
+		}
+		dls = append(dls, dl)
+	}
+	return dls, nil
+}
+
+// Remove deletes the file of the dead letter with the given id
+func (f *FileDeadLetterSink) Remove(id string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	names, err := f.files()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if strings.HasSuffix(name, "-"+id+".json") {
+			return os.Remove(filepath.Join(f.dir, name))
+		}
+	}
+	return ErrDeadLetterNotFound
+}
+
+// files returns the sorted names of the dead letter files of the directory
+func (f *FileDeadLetterSink) files() ([]string, error) {
+	infos, err := ioutil.ReadDir(f.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, info := range infos {
+		if !info.IsDir() && filepath.Ext(info.Name()) == ".json" {
+			names = append(names, info.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// SetDeadLetterSink sets the sink keeping the metrics of the publish jobs
+// which failed once their retries were exhausted, the metrics are dropped
+// if nil
+func (s *scheduler) SetDeadLetterSink(sink DeadLetterSink) {
+	s.deadLetterMutex.Lock()
+	s.deadLetters = sink
+	s.deadLetterMutex.Unlock()
+	schedulerLogger.WithFields(log.Fields{
+		"_block": "set-dead-letter-sink",
+	}).Debug("dead letter sink linked")
+}
+
+func (s *scheduler) getDeadLetterSink() DeadLetterSink {
+	s.deadLetterMutex.RLock()
+	defer s.deadLetterMutex.RUnlock()
+	return s.deadLetters
+}
+
+// putDeadLetter keeps the metrics of a failed publish job of a task in the
+// dead letter sink, if any
+func (s *scheduler) putDeadLetter(t *task, pu *publishNode, mts []core.Metric, errs []error) {
+	sink := s.getDeadLetterSink()
+	if sink == nil {
+		return
+	}
+	dl := DeadLetter{
+		ID:            uuid.New(),
+		Time:          time.Now(),
+		TaskID:        t.id,
+		PluginName:    pu.Name(),
+		PluginVersion: pu.Version(),
+		Target:        pu.Target,
+		Config:        pu.Config(),
+		Metrics:       make([]plugin.MetricType, len(mts)),
+		Error:         runError(errs).Error(),
+	}
+	for i, m := range mts {
+		dl.Metrics[i] = metricTypeOf(m)
+	}
+	if err := sink.Put(dl); err != nil {
+		schedulerLogger.WithFields(log.Fields{
+			"_block":         "put-dead-letter",
+			"_error":         err.Error(),
+			"task-id":        t.id,
+			"publish-name":   pu.Name(),
+			"metrics-count":  len(mts),
+			"publish-errors": len(errs),
+		}).Error("error keeping the metrics of a failed publish job")
+	}
+}
+
+// ReplayDeadLetters publishes again the metrics of the dead letters kept in
+// the dead letter sink, in the order they were put, and removes the letters
+// published. A letter whose publish fails again is kept. The letters of a
+// remote target are published through the managers of their task, and are
+// kept if the task was removed. It returns the number of letters published.
+// Can return errors ErrSchedulerNotStarted, ErrDeadLetterSinkNotSet, the
+// error of the context or the errors of the publish jobs.
+func (s *scheduler) ReplayDeadLetters(ctx context.Context) (uint, error) {
+	logger := schedulerLogger.WithFields(log.Fields{
+		"_block": "replay-dead-letters",
+	})
+	if s.getState() != schedulerStarted {
+		logger.Error(ErrSchedulerNotStarted)
+		return 0, ErrSchedulerNotStarted
+	}
+	sink := s.getDeadLetterSink()
+	if sink == nil {
+		logger.Error(ErrDeadLetterSinkNotSet)
+		return 0, ErrDeadLetterSinkNotSet
+	}
+	dls, err := sink.List()
+	if err != nil {
+		logger.WithField("_error", err.Error()).Error("error listing the dead letters")
+		return 0, err
+	}
+	var replayed uint
+	var errs []error
+	for _, dl := range dls {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if err := s.replayDeadLetter(dl); err != nil {
+			logger.WithFields(log.Fields{
+				"_error":         err.Error(),
+				"dead-letter-id": dl.ID,
+				"task-id":        dl.TaskID,
+			}).Warn("error replaying a dead letter")
+			errs = append(errs, err)
+			continue
+		}
+		if err := sink.Remove(dl.ID); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		replayed++
+	}
+	return replayed, runError(errs)
+}
+
+// replayDeadLetter publishes the metrics of a dead letter
+func (s *scheduler) replayDeadLetter(dl DeadLetter) error {
+	var publisher publishesMetrics = s.getMetricManager()
+	if dl.Target != "" {
+		t, err := s.getTask(dl.TaskID)
+		if err != nil {
+			return err
+		}
+		mgr, err := t.RemoteManagers.Get(dl.Target)
+		if err != nil {
+			return err
+		}
+		publisher = mgr
+	}
+	mts := make([]core.Metric, len(dl.Metrics))
+	for i := range dl.Metrics {
+		mts[i] = dl.Metrics[i]
+	}
+	config := dl.Config
+	if config == nil {
+		config = cdata.NewNode()
+	}
+	pj := &collectorJob{
+		metrics: mts,
+		coreJob: newCoreJob(collectJobType, time.Now().Add(DefaultDeadlineDuration), dl.TaskID, "", 0),
+	}
+	j := newPublishJob(pj, dl.PluginName, dl.PluginVersion, "", config.Table(), publisher, dl.TaskID)
+	return runError(s.workManager.Work(j).Promise().Await())
+}
@@ -0,0 +1,166 @@
+// +build legacy
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+func TestFileDeadLetterSink(t *testing.T) {
+	Convey("FileDeadLetterSink", t, func() {
+		dir, err := ioutil.TempDir("", "dead-letters")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		sink := NewFileDeadLetterSink(filepath.Join(dir, "spool"))
+		Convey("lists nothing before the first put", func() {
+			dls, err := sink.List()
+			So(err, ShouldBeNil)
+			So(dls, ShouldBeEmpty)
+		})
+		Convey("lists the letters put in order", func() {
+			start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+			So(sink.Put(DeadLetter{ID: "b", Time: start.Add(time.Second), Error: "second"}), ShouldBeNil)
+			So(sink.Put(DeadLetter{ID: "a", Time: start, Error: "first"}), ShouldBeNil)
+			dls, err := sink.List()
+			So(err, ShouldBeNil)
+			So(dls, ShouldHaveLength, 2)
+			So(dls[0].Error, ShouldEqual, "first")
+			So(dls[1].Error, ShouldEqual, "second")
+			Convey("and removes a letter by its id", func() {
+				So(sink.Remove("a"), ShouldBeNil)
+				dls, err := sink.List()
+				So(err, ShouldBeNil)
+				So(dls, ShouldHaveLength, 1)
+				So(dls[0].ID, ShouldEqual, "b")
+				So(sink.Remove("a"), ShouldEqual, ErrDeadLetterNotFound)
+			})
+		})
+	})
+}
+
+func TestDeadLetters(t *testing.T) {
+	log.SetLevel(log.FatalLevel)
+	Convey("A scheduler with a dead letter sink", t, func() {
+		dir, err := ioutil.TempDir("", "dead-letters")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		dp := &deadLetterPublisher{mockMetricManager: &mockMetricManager{}, fail: true}
+		s := New(GetDefaultConfig(), WithDeadLetterPath(dir))
+		s.SetMetricManager(dp)
+		So(s.Start(), ShouldBeNil)
+		defer s.Stop()
+		tsk := &task{
+			manager:          s.workManager,
+			id:               "1",
+			name:             "mock",
+			RemoteManagers:   newManagers(dp),
+			deadLetter:       s.putDeadLetter,
+			deadlineDuration: DefaultDeadlineDuration,
+		}
+		config := cdata.NewNode()
+		config.AddItem("file", ctypes.ConfigValueStr{Value: "/tmp/out"})
+		pu := &publishNode{config: config, name: "pujob", version: 2}
+		mts := []core.Metric{plugin.MetricType{Namespace_: core.NewNamespace("intel", "mock", "foo"), Data_: "bar"}}
+		pj := &collectorJob{metrics: mts, coreJob: newCoreJob(collectJobType, time.Now().Add(time.Second), "1", "", 0)}
+		workJobs(context.Background(), nil, []*publishNode{pu}, tsk, pj, tsk.RecordFailure)
+		Convey("keeps the metrics of a failed publish job", func() {
+			dls, err := s.getDeadLetterSink().List()
+			So(err, ShouldBeNil)
+			So(dls, ShouldHaveLength, 1)
+			So(dls[0].TaskID, ShouldEqual, "1")
+			So(dls[0].PluginName, ShouldEqual, "pujob")
+			So(dls[0].PluginVersion, ShouldEqual, 2)
+			So(dls[0].Error, ShouldEqual, "publish failed")
+			So(dls[0].Metrics, ShouldHaveLength, 1)
+			So(dls[0].Metrics[0].Namespace().String(), ShouldEqual, "/intel/mock/foo")
+		})
+		Convey("keeps a letter failing again on replay", func() {
+			replayed, err := s.ReplayDeadLetters(context.Background())
+			So(err, ShouldNotBeNil)
+			So(replayed, ShouldEqual, 0)
+			dls, _ := s.getDeadLetterSink().List()
+			So(dls, ShouldHaveLength, 1)
+		})
+		Convey("replays the letters and removes them", func() {
+			dp.setFail(false)
+			replayed, err := s.ReplayDeadLetters(context.Background())
+			So(err, ShouldBeNil)
+			So(replayed, ShouldEqual, 1)
+			So(dp.published, ShouldHaveLength, 1)
+			So(dp.published[0].config["file"], ShouldResemble, ctypes.ConfigValueStr{Value: "/tmp/out"})
+			So(dp.published[0].metrics[0].Data(), ShouldEqual, "bar")
+			dls, _ := s.getDeadLetterSink().List()
+			So(dls, ShouldBeEmpty)
+		})
+		Convey("drops the metrics without a sink", func() {
+			s.SetDeadLetterSink(nil)
+			workJobs(context.Background(), nil, []*publishNode{pu}, tsk, pj, tsk.RecordFailure)
+			_, err := s.ReplayDeadLetters(context.Background())
+			So(err, ShouldEqual, ErrDeadLetterSinkNotSet)
+		})
+	})
+}
+
+// deadLetterPublisher fails its publish jobs while fail is set and records
+// the ones succeeding
+type deadLetterPublisher struct {
+	*mockMetricManager
+	sync.Mutex
+	fail      bool
+	published []publishedBatch
+}
+
+type publishedBatch struct {
+	metrics []core.Metric
+	config  map[string]ctypes.ConfigValue
+}
+
+func (d *deadLetterPublisher) setFail(fail bool) {
+	d.Lock()
+	defer d.Unlock()
+	d.fail = fail
+}
+
+func (d *deadLetterPublisher) PublishMetrics(mts []core.Metric, config map[string]ctypes.ConfigValue, _ string, _ string, _ int) []error {
+	d.Lock()
+	defer d.Unlock()
+	if d.fail {
+		return []error{errors.New("publish failed")}
+	}
+	d.published = append(d.published, publishedBatch{metrics: mts, config: config})
+	return nil
+}
@@ -0,0 +1,199 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/pkg/schedule"
+)
+
+// upstreamRuns holds the successful runs completed by the tasks a task
+// depends on since the task last fired
+type upstreamRuns struct {
+	// completed holds the ids of the tasks which completed a run
+	completed map[string]struct{}
+	// metrics collected by the runs, passed to the next firing of the task
+	metrics []core.Metric
+}
+
+// completeUpstream records a successful run of the task of the id, which the
+// task depends on. It returns true once each of the tasks the task depends on
+// completed a run, the task firing then on the completed runs.
+func (t *task) completeUpstream(id string, mts []core.Metric) bool {
+	t.upstreamMutex.Lock()
+	defer t.upstreamMutex.Unlock()
+	if t.upstream.completed == nil {
+		t.upstream.completed = make(map[string]struct{})
+	}
+	t.upstream.completed[id] = struct{}{}
+	if t.passMetrics {
+		t.upstream.metrics = append(t.upstream.metrics, mts...)
+	}
+	for _, dep := range t.dependsOn {
+		if _, ok := t.upstream.completed[dep]; !ok {
+			return false
+		}
+	}
+	t.upstream.completed = nil
+	return true
+}
+
+// takeUpstreamMetrics returns the metrics collected by the runs of the tasks
+// the task depends on since they were last taken
+func (t *task) takeUpstreamMetrics() []core.Metric {
+	t.upstreamMutex.Lock()
+	defer t.upstreamMutex.Unlock()
+	mts := t.upstream.metrics
+	t.upstream.metrics = nil
+	return mts
+}
+
+// dependsOnTask returns whether the task depends on the task of the id
+func (t *task) dependsOnTask(id string) bool {
+	for _, dep := range t.dependsOn {
+		if dep == id {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDependentSchedule checks that a task with dependencies is on a
+// dependent schedule, and that only such a task is
+func checkDependentSchedule(sch schedule.Schedule, dependsOn []string) error {
+	if _, dependent := sch.(*schedule.DependentSchedule); dependent != (len(dependsOn) > 0) {
+		return ErrDependentSchedule
+	}
+	return nil
+}
+
+// checkDependencies checks that the tasks the task depends on exist and that
+// the dependencies of the tasks do not form a cycle once the task is added.
+// It is called with the dependency mutex held so that two tasks created at
+// once cannot form a cycle.
+func (s *scheduler) checkDependencies(t *task) error {
+	if err := checkDependentSchedule(t.Schedule(), t.dependsOn); err != nil {
+		return err
+	}
+	if len(t.dependsOn) == 0 {
+		return nil
+	}
+	tasks := s.tasks.Table()
+	tasks[t.id] = t
+	for _, id := range t.dependsOn {
+		if _, ok := tasks[id]; !ok {
+			return fmt.Errorf("%v: ID(%v)", ErrDependencyNotFound, id)
+		}
+	}
+	if dependsOnItself(t.id, tasks) {
+		return fmt.Errorf("%v: ID(%v)", ErrDependencyCycle, t.id)
+	}
+	return nil
+}
+
+// dependsOnItself returns whether the task of the id depends, directly or
+// through other tasks, on itself. The dependencies of the tasks already
+// created do not form a cycle, so only a cycle through the task is looked
+// for.
+func dependsOnItself(id string, tasks map[string]*task) bool {
+	visited := make(map[string]struct{})
+	var visit func(from string) bool
+	visit = func(from string) bool {
+		t, ok := tasks[from]
+		if !ok {
+			return false
+		}
+		for _, dep := range t.dependsOn {
+			if dep == id {
+				return true
+			}
+			if _, ok := visited[dep]; ok {
+				continue
+			}
+			visited[dep] = struct{}{}
+			if visit(dep) {
+				return true
+			}
+		}
+		return false
+	}
+	return visit(id)
+}
+
+// triggerDependents records the successful run of the task of the id for
+// the running tasks depending on it, firing those whose dependencies all
+// completed a run
+func (s *scheduler) triggerDependents(id string, mts []core.Metric) {
+	for _, t := range s.tasks.Table() {
+		if !t.dependsOnTask(id) {
+			continue
+		}
+		if state := t.State(); state != core.TaskSpinning && state != core.TaskFiring {
+			continue
+		}
+		if !t.completeUpstream(id, mts) {
+			continue
+		}
+		sch, ok := t.Schedule().(*schedule.DependentSchedule)
+		if !ok {
+			continue
+		}
+		schedulerLogger.WithFields(log.Fields{
+			"_block":        "trigger-dependents",
+			"task-id":       t.id,
+			"upstream-task": id,
+		}).Debug("triggering dependent task")
+		sch.Trigger()
+	}
+}
+
+// orderByDependencies orders the saved tasks so that the tasks a task
+// depends on are restored before it
+func orderByDependencies(saved []savedTask) []savedTask {
+	byID := make(map[string]savedTask, len(saved))
+	for _, st := range saved {
+		byID[st.ID] = st
+	}
+	ordered := make([]savedTask, 0, len(saved))
+	placed := make(map[string]struct{}, len(saved))
+	var place func(st savedTask)
+	place = func(st savedTask) {
+		if _, ok := placed[st.ID]; ok {
+			return
+		}
+		placed[st.ID] = struct{}{}
+		if st.Task != nil {
+			for _, dep := range st.Task.DependsOn {
+				if d, ok := byID[dep]; ok {
+					place(d)
+				}
+			}
+		}
+		ordered = append(ordered, st)
+	}
+	for _, st := range saved {
+		place(st)
+	}
+	return ordered
+}
@@ -0,0 +1,76 @@
+// +build legacy
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/core"
+)
+
+func TestDependencyGraph(t *testing.T) {
+	Convey("dependsOnItself", t, func() {
+		tasks := map[string]*task{
+			"a": {id: "a"},
+			"b": {id: "b", dependsOn: []string{"a"}},
+			"c": {id: "c", dependsOn: []string{"a", "b"}},
+		}
+		Convey("is false for acyclic dependencies", func() {
+			So(dependsOnItself("c", tasks), ShouldBeFalse)
+		})
+		Convey("finds a cycle through other tasks", func() {
+			tasks["a"] = &task{id: "a", dependsOn: []string{"c"}}
+			So(dependsOnItself("a", tasks), ShouldBeTrue)
+		})
+		Convey("finds a task depending on itself", func() {
+			tasks["d"] = &task{id: "d", dependsOn: []string{"d"}}
+			So(dependsOnItself("d", tasks), ShouldBeTrue)
+		})
+	})
+	Convey("orderByDependencies restores the tasks depended on first", t, func() {
+		saved := []savedTask{
+			{ID: "c", Task: &core.TaskCreationRequest{DependsOn: []string{"b"}}},
+			{ID: "b", Task: &core.TaskCreationRequest{DependsOn: []string{"a", "missing"}}},
+			{ID: "a", Task: &core.TaskCreationRequest{}},
+			{ID: "d"},
+		}
+		var ids []string
+		for _, st := range orderByDependencies(saved) {
+			ids = append(ids, st.ID)
+		}
+		So(ids, ShouldResemble, []string{"a", "b", "c", "d"})
+	})
+	Convey("completeUpstream", t, func() {
+		tsk := &task{id: "c", dependsOn: []string{"a", "b"}, passMetrics: true}
+		mts := []core.Metric{&metric{namespace: core.NewNamespace("foo")}}
+		Convey("fires the task once each of its dependencies completed a run", func() {
+			So(tsk.completeUpstream("a", mts), ShouldBeFalse)
+			So(tsk.completeUpstream("a", mts), ShouldBeFalse)
+			So(tsk.completeUpstream("b", mts), ShouldBeTrue)
+			So(tsk.takeUpstreamMetrics(), ShouldHaveLength, 3)
+			So(tsk.takeUpstreamMetrics(), ShouldBeEmpty)
+			So(tsk.completeUpstream("b", mts), ShouldBeFalse)
+		})
+	})
+}
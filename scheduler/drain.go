@@ -0,0 +1,137 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DrainPolicy is how the scheduler handles the work in flight when it stops
+type DrainPolicy int
+
+const (
+	// DrainGraceful finishes the firing in progress of every task, flushes
+	// the metrics buffered by the collect windows and the publish batches of
+	// the tasks and completes the jobs queued before the tasks are
+	// unsubscribed, for as long as the context of the stop is not done. This
+	// is the default policy.
+	DrainGraceful DrainPolicy = iota
+	// DrainAbandon cancels the jobs queued or being worked right away and
+	// drops the metrics buffered by the tasks
+	DrainAbandon
+)
+
+var drainPolicies = map[string]DrainPolicy{
+	"graceful": DrainGraceful,
+	"abandon":  DrainAbandon,
+}
+
+// ParseDrainPolicy returns the policy of the given name, which is one of
+// "graceful" or "abandon".
+func ParseDrainPolicy(name string) (DrainPolicy, error) {
+	if p, ok := drainPolicies[name]; ok {
+		return p, nil
+	}
+	return DrainGraceful, fmt.Errorf("unknown drain policy '%s'", name)
+}
+
+func (p DrainPolicy) String() string {
+	for name, v := range drainPolicies {
+		if v == p {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+// DrainProgress is the progress of the scheduler stopping, returned by
+// DrainProgress so that a caller stopping the scheduler can report it
+type DrainProgress struct {
+	Policy DrainPolicy
+	// Draining is set while the scheduler is stopping, and Done once the
+	// last stop has returned
+	Draining  bool
+	Done      bool
+	StartTime time.Time
+	EndTime   time.Time
+	// Tasks is the number of tasks being stopped, StoppedTasks the number of
+	// them whose firing in progress is done and whose buffered metrics are
+	// flushed
+	Tasks        int
+	StoppedTasks int
+	// QueuedJobs and InFlightJobs are the jobs left to complete while
+	// draining
+	QueuedJobs   uint
+	InFlightJobs uint
+	// CancelledJobs is the number of jobs cancelled, once done
+	CancelledJobs uint
+}
+
+// drainState holds the progress of the scheduler stopping
+type drainState struct {
+	mutex    sync.Mutex
+	progress DrainProgress
+}
+
+func (d *drainState) start(policy DrainPolicy, tasks int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.progress = DrainProgress{
+		Policy:    policy,
+		Draining:  true,
+		StartTime: time.Now(),
+		Tasks:     tasks,
+	}
+}
+
+func (d *drainState) taskStopped() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.progress.StoppedTasks++
+}
+
+func (d *drainState) end(cancelled uint) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.progress.Draining = false
+	d.progress.Done = true
+	d.progress.EndTime = time.Now()
+	d.progress.CancelledJobs = cancelled
+}
+
+func (d *drainState) get() DrainProgress {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.progress
+}
+
+// DrainProgress returns the progress of the scheduler stopping. It may be
+// called while Stop runs, the zero DrainProgress being returned if the
+// scheduler was never stopped.
+func (s *scheduler) DrainProgress() DrainProgress {
+	p := s.drain.get()
+	if p.Draining {
+		p.QueuedJobs = s.workManager.Stats().QueuedJobs
+		p.InFlightJobs = s.workManager.inFlightJobs()
+	}
+	return p
+}
@@ -0,0 +1,125 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/intelsdi-x/snap/core"
+)
+
+const (
+	// DurationSampleSize - The number of the most recent firing durations
+	// the percentiles of a task are computed from.
+	DurationSampleSize = 256
+)
+
+// durationSummary summarizes the durations of the firings of a task in a
+// bounded amount of memory. The minimum, maximum and average cover every
+// firing while the percentiles cover the last DurationSampleSize firings.
+type durationSummary struct {
+	mutex   sync.Mutex
+	count   uint64
+	total   time.Duration
+	min     time.Duration
+	max     time.Duration
+	samples []time.Duration
+	// next is the index of the sample replaced by the next observation once
+	// the samples are full
+	next int
+}
+
+func newDurationSummary() *durationSummary {
+	return &durationSummary{
+		samples: make([]time.Duration, 0, DurationSampleSize),
+	}
+}
+
+func (s *durationSummary) observe(d time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.count == 0 || d < s.min {
+		s.min = d
+	}
+	if d > s.max {
+		s.max = d
+	}
+	s.count++
+	s.total += d
+	if len(s.samples) < cap(s.samples) {
+		s.samples = append(s.samples, d)
+		return
+	}
+	s.samples[s.next] = d
+	s.next = (s.next + 1) % len(s.samples)
+}
+
+// stats returns the minimum, maximum, average and 95th percentile of the
+// observed durations, all zero when nothing was observed yet.
+func (s *durationSummary) stats() (min, max, avg, p95 time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.count == 0 {
+		return 0, 0, 0, 0
+	}
+	sorted := s.sorted()
+	return s.min, s.max, s.total / time.Duration(s.count), percentile(sorted, 95)
+}
+
+// latency returns the number of observed durations along with their 50th,
+// 95th and 99th percentiles
+func (s *durationSummary) latency() core.LatencyStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.count == 0 {
+		return core.LatencyStats{}
+	}
+	sorted := s.sorted()
+	return core.LatencyStats{
+		Count: s.count,
+		Sum:   s.total,
+		P50:   percentile(sorted, 50),
+		P95:   percentile(sorted, 95),
+		P99:   percentile(sorted, 99),
+	}
+}
+
+// sorted returns a sorted copy of the samples, it is called with the mutex
+// held
+func (s *durationSummary) sorted() durations {
+	sorted := make(durations, len(s.samples))
+	copy(sorted, s.samples)
+	sort.Sort(sorted)
+	return sorted
+}
+
+// percentile returns the nearest-rank percentile of sorted durations
+func percentile(sorted durations, p int) time.Duration {
+	rank := (len(sorted)*p + 99) / 100
+	return sorted[rank-1]
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
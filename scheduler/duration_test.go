@@ -0,0 +1,79 @@
+// +build legacy
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/core"
+)
+
+func TestDurationSummary(t *testing.T) {
+	Convey("durationSummary", t, func() {
+		s := newDurationSummary()
+		Convey("is all zero before any firing", func() {
+			min, max, avg, p95 := s.stats()
+			So(min, ShouldEqual, 0)
+			So(max, ShouldEqual, 0)
+			So(avg, ShouldEqual, 0)
+			So(p95, ShouldEqual, 0)
+		})
+		Convey("summarizes the observed durations", func() {
+			for i := 1; i <= 100; i++ {
+				s.observe(time.Duration(i) * time.Millisecond)
+			}
+			min, max, avg, p95 := s.stats()
+			So(min, ShouldEqual, time.Millisecond)
+			So(max, ShouldEqual, 100*time.Millisecond)
+			So(avg, ShouldEqual, 50500*time.Microsecond)
+			So(p95, ShouldEqual, 95*time.Millisecond)
+		})
+		Convey("reports the latency percentiles of the observed durations", func() {
+			So(s.latency(), ShouldResemble, core.LatencyStats{})
+			for i := 1; i <= 100; i++ {
+				s.observe(time.Duration(i) * time.Millisecond)
+			}
+			So(s.latency(), ShouldResemble, core.LatencyStats{
+				Count: 100,
+				Sum:   5050 * time.Millisecond,
+				P50:   50 * time.Millisecond,
+				P95:   95 * time.Millisecond,
+				P99:   99 * time.Millisecond,
+			})
+		})
+		Convey("keeps a bounded number of samples", func() {
+			s.observe(time.Hour)
+			for i := 0; i < 2*DurationSampleSize; i++ {
+				s.observe(time.Second)
+			}
+			So(len(s.samples), ShouldEqual, DurationSampleSize)
+			min, max, _, p95 := s.stats()
+			So(min, ShouldEqual, time.Second)
+			// the maximum covers every firing, the percentile the recent ones
+			So(max, ShouldEqual, time.Hour)
+			So(p95, ShouldEqual, time.Second)
+		})
+	})
+}
@@ -0,0 +1,196 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// TaskEventBufferSize - The default number of events buffered for each
+	// subscriber, set with WithEventBufferSize.
+	TaskEventBufferSize = 100
+)
+
+// TaskEventType identifies the kind of a task lifecycle event
+type TaskEventType int
+
+const (
+	// EventTaskCreated is sent when a task is created
+	EventTaskCreated TaskEventType = iota
+	// EventTaskStarted is sent when a task is started
+	EventTaskStarted
+	// EventTaskStopped is sent when a task has stopped
+	EventTaskStopped
+	// EventTaskFired is sent when a firing of a task collected metrics
+	EventTaskFired
+	// EventTaskFailed is sent when a firing of a task failed to collect metrics
+	EventTaskFailed
+	// EventTaskEnded is sent when the schedule of a task has ended
+	EventTaskEnded
+	// EventTaskDisabled is sent when a task is disabled after failing
+	EventTaskDisabled
+	// EventTaskRemoved is sent when a task is removed
+	EventTaskRemoved
+	// EventTaskPaused is sent when a task has paused
+	EventTaskPaused
+	// EventTaskQueueOverflow is sent when a job of a task is dropped because
+	// its work queue was full
+	EventTaskQueueOverflow
+	// EventTaskDeadlineMissed is sent when a job of a task is dropped because
+	// its deadline passed before a worker could run it
+	EventTaskDeadlineMissed
+	// EventCircuitOpened is sent when the circuit breaker of a namespace
+	// prefix opens after the collections of its metrics failed
+	EventCircuitOpened
+	// EventCircuitHalfOpen is sent when the circuit breaker of a namespace
+	// prefix lets a collection through to probe its metrics
+	EventCircuitHalfOpen
+	// EventCircuitClosed is sent when the circuit breaker of a namespace
+	// prefix closes after its probe succeeded
+	EventCircuitClosed
+	// EventIntervalAdjusted is sent when the interval of a task is
+	// stretched or tightened back to the load of the scheduler
+	EventIntervalAdjusted
+)
+
+var taskEventTypeLookup = map[TaskEventType]string{
+	EventTaskCreated:        "task-created",
+	EventTaskStarted:        "task-started",
+	EventTaskStopped:        "task-stopped",
+	EventTaskFired:          "task-fired",
+	EventTaskFailed:         "task-failed",
+	EventTaskEnded:          "task-ended",
+	EventTaskDisabled:       "task-disabled",
+	EventTaskRemoved:        "task-removed",
+	EventTaskPaused:         "task-paused",
+	EventTaskQueueOverflow:  "task-queue-overflow",
+	EventTaskDeadlineMissed: "task-deadline-missed",
+	EventCircuitOpened:      "circuit-opened",
+	EventCircuitHalfOpen:    "circuit-half-open",
+	EventCircuitClosed:      "circuit-closed",
+	EventIntervalAdjusted:   "interval-adjusted",
+}
+
+func (t TaskEventType) String() string {
+	return taskEventTypeLookup[t]
+}
+
+// TaskEvent is a task lifecycle event sent to the subscribers of the scheduler
+type TaskEvent struct {
+	Type      TaskEventType
+	TaskID    string
+	Timestamp time.Time
+	// Err holds the error of a failed firing or the reason a task was disabled
+	Err error
+	// Queue is the work queue of the dropped job of a queue overflow or a
+	// missed deadline, "collect", "process" or "publish"
+	Queue string
+	// Prefix is the namespace prefix of a circuit breaker event, the task
+	// being the one whose collection changed the state of the circuit
+	Prefix string
+	// Interval is the interval of the task once adjusted to the load
+	Interval time.Duration
+}
+
+// taskEventSubscriber is the channel of a subscriber along with the number
+// of events it was not sent because its buffer was full
+type taskEventSubscriber struct {
+	ch      chan TaskEvent
+	dropped uint64
+}
+
+type taskEventBus struct {
+	mutex      sync.Mutex
+	bufferSize int
+	subs       []*taskEventSubscriber
+	// dropped is the number of events dropped for all the subscribers,
+	// including the ones which unsubscribed since
+	dropped uint64
+}
+
+func newTaskEventBus(bufferSize int) *taskEventBus {
+	if bufferSize <= 0 {
+		bufferSize = TaskEventBufferSize
+	}
+	return &taskEventBus{
+		bufferSize: bufferSize,
+		subs:       make([]*taskEventSubscriber, 0),
+	}
+}
+
+func (b *taskEventBus) subscribe() <-chan TaskEvent {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	sub := &taskEventSubscriber{ch: make(chan TaskEvent, b.bufferSize)}
+	b.subs = append(b.subs, sub)
+	return sub.ch
+}
+
+// unsubscribe removes and closes the channel of a subscriber, it does
+// nothing for a channel which is not subscribed
+func (b *taskEventBus) unsubscribe(ch <-chan TaskEvent) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for i, sub := range b.subs {
+		if sub.ch == ch {
+			b.subs = append(b.subs[:i], b.subs[i+1:]...)
+			close(sub.ch)
+			return
+		}
+	}
+}
+
+// publish sends the event to every subscriber without blocking, a
+// subscriber that is not keeping up misses the event and has its dropped
+// counter incremented.
+func (b *taskEventBus) publish(e TaskEvent) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, sub := range b.subs {
+		select {
+		case sub.ch <- e:
+		default:
+			sub.dropped++
+			b.dropped++
+			schedulerLogger.WithFields(log.Fields{
+				"_block":     "publish-event",
+				"task-id":    e.TaskID,
+				"event-type": e.Type.String(),
+				"dropped":    sub.dropped,
+			}).Warn("Dropping task event for a slow subscriber")
+		}
+	}
+}
+
+// stats returns the number of events dropped for all the subscribers and
+// for each current subscriber
+func (b *taskEventBus) stats() (uint64, map[<-chan TaskEvent]uint64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	subs := make(map[<-chan TaskEvent]uint64, len(b.subs))
+	for _, sub := range b.subs {
+		subs[sub.ch] = sub.dropped
+	}
+	return b.dropped, subs
+}
@@ -0,0 +1,89 @@
+// +build legacy
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTaskEventBus(t *testing.T) {
+	log.SetLevel(log.FatalLevel)
+	Convey("Task event bus", t, func() {
+		bus := newTaskEventBus(TaskEventBufferSize)
+		ch1 := bus.subscribe()
+		ch2 := bus.subscribe()
+		Convey("sends the events to every subscriber", func() {
+			bus.publish(TaskEvent{Type: EventTaskStarted, TaskID: "1"})
+			e1 := <-ch1
+			e2 := <-ch2
+			So(e1.Type, ShouldEqual, EventTaskStarted)
+			So(e1.TaskID, ShouldEqual, "1")
+			So(e2, ShouldResemble, e1)
+		})
+		Convey("drops the events of a subscriber which is not keeping up", func() {
+			for i := 0; i < TaskEventBufferSize+10; i++ {
+				bus.publish(TaskEvent{Type: EventTaskFired, TaskID: "1"})
+			}
+			So(len(ch1), ShouldEqual, TaskEventBufferSize)
+			So(len(ch2), ShouldEqual, TaskEventBufferSize)
+		})
+		Convey("counts the events dropped for each subscriber", func() {
+			for i := 0; i < TaskEventBufferSize; i++ {
+				bus.publish(TaskEvent{Type: EventTaskFired, TaskID: "1"})
+			}
+			<-ch2
+			for i := 0; i < 3; i++ {
+				bus.publish(TaskEvent{Type: EventTaskFired, TaskID: "1"})
+			}
+			total, subs := bus.stats()
+			So(total, ShouldEqual, 5)
+			So(subs[ch1], ShouldEqual, 3)
+			So(subs[ch2], ShouldEqual, 2)
+			Convey("and keeps the total once a subscriber unsubscribes", func() {
+				bus.unsubscribe(ch1)
+				total, subs := bus.stats()
+				So(total, ShouldEqual, 5)
+				So(len(subs), ShouldEqual, 1)
+			})
+		})
+		Convey("buffers the configured number of events", func() {
+			small := newTaskEventBus(2)
+			ch := small.subscribe()
+			for i := 0; i < 5; i++ {
+				small.publish(TaskEvent{Type: EventTaskFired, TaskID: "1"})
+			}
+			So(len(ch), ShouldEqual, 2)
+			total, _ := small.stats()
+			So(total, ShouldEqual, 3)
+		})
+		Convey("closes the channel of a subscriber on unsubscribe", func() {
+			bus.unsubscribe(ch1)
+			bus.publish(TaskEvent{Type: EventTaskStopped, TaskID: "1"})
+			_, ok := <-ch1
+			So(ok, ShouldBeFalse)
+			So((<-ch2).Type, ShouldEqual, EventTaskStopped)
+		})
+	})
+}
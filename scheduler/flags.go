@@ -38,6 +38,12 @@ var (
 		EnvVar: "WORK_MANAGER_POOL_SIZE",
 	}
 
+	flSchedulerQueueFullPolicy = cli.StringFlag{
+		Name:   "work-manager-queue-full-policy",
+		Usage:  fmt.Sprintf("Policy for jobs submitted to a full work manager queue, one of drop-newest, drop-oldest or block (default: %v)", defaultWorkManagerQueueFullPolicy),
+		EnvVar: "WORK_MANAGER_QUEUE_FULL_POLICY",
+	}
+
 	// Flags consumed by snapteld
-	Flags = []cli.Flag{flSchedulerQueueSize, flSchedulerPoolSize}
+	Flags = []cli.Flag{flSchedulerQueueSize, flSchedulerPoolSize, flSchedulerQueueFullPolicy}
 )
@@ -0,0 +1,150 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"github.com/intelsdi-x/snap/core"
+)
+
+// HealthStatus is the overall status of the scheduler reported by Health
+type HealthStatus string
+
+const (
+	// HealthOK is the status of a scheduler running as usual
+	HealthOK HealthStatus = "ok"
+	// HealthDegraded is the status of a scheduler running its tasks while
+	// some of them, or some of its components, are failing
+	HealthDegraded HealthStatus = "degraded"
+	// HealthUnavailable is the status of a scheduler not running its tasks
+	HealthUnavailable HealthStatus = "unavailable"
+)
+
+// healthQueueSaturation is the percentage of its limit a work queue is
+// filled to from which the scheduler is degraded
+const healthQueueSaturation = 90
+
+// HealthReport is the structured status of the scheduler returned by Health,
+// meant to be served as is by a liveness or readiness endpoint
+type HealthReport struct {
+	Status HealthStatus `json:"status"`
+	// Ready is set when the scheduler runs its tasks, that is unless the
+	// status is HealthUnavailable
+	Ready bool `json:"ready"`
+	// State is the state of the scheduler, "started" or "stopped"
+	State  string `json:"state"`
+	Leader bool   `json:"leader"`
+	// MetricManager is set when the scheduler is linked to a metric manager
+	MetricManager bool `json:"metric_manager"`
+	LiveWorkers   uint `json:"live_workers"`
+	// QueueSaturation is the percentage of its limit the fullest work queue
+	// is filled to, 0 for unlimited queues
+	QueueSaturation float64 `json:"queue_saturation"`
+	// FailingTasks is the number of tasks disabled on failures or whose
+	// last firing failed
+	FailingTasks int `json:"failing_tasks"`
+	// OpenCircuits is the number of namespace prefixes whose circuit
+	// breaker is open, their collections being skipped
+	OpenCircuits int `json:"open_circuits"`
+	// IntervalStretch is the factor the intervals of the tasks are stretched
+	// by under load, 1 unless adaptive intervals are enabled
+	IntervalStretch uint `json:"interval_stretch"`
+	// TaskStore is set when the tasks are kept in a task store, and
+	// TaskStoreError is the error of the last load or save of the store,
+	// empty once the tasks are saved again
+	TaskStore      bool   `json:"task_store"`
+	TaskStoreError string `json:"task_store_error,omitempty"`
+	// Reasons explains a status other than HealthOK
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// Err returns the reason the scheduler is unavailable, nil if it is not
+func (h HealthReport) Err() error {
+	switch {
+	case h.State != "started":
+		return ErrSchedulerNotStarted
+	case !h.MetricManager:
+		return ErrMetricManagerNotSet
+	case h.LiveWorkers == 0:
+		return ErrNoLiveWorkers
+	}
+	return nil
+}
+
+// Health returns the status of the scheduler. It is unavailable when it is
+// not started, its metric manager is not set or none of its workers is
+// running, and degraded when a work queue is nearly full, tasks are failing,
+// circuit breakers are open or its task store fails. It is cheap enough to be
+// called by a liveness or readiness probe.
+func (s *scheduler) Health() HealthReport {
+	h := HealthReport{
+		State:           "stopped",
+		Leader:          s.IsLeader(),
+		MetricManager:   s.getMetricManager() != nil,
+		LiveWorkers:     s.workManager.Stats().LiveWorkers,
+		QueueSaturation: s.workManager.saturation(),
+		IntervalStretch: 1,
+		TaskStore:       s.persistence.store != nil,
+	}
+	if s.adaptive != nil {
+		h.IntervalStretch = s.adaptive.current()
+	}
+	if s.getState() == schedulerStarted {
+		h.State = "started"
+	}
+	for _, t := range s.tasks.Table() {
+		if t.State() == core.TaskDisabled || t.failing() {
+			h.FailingTasks++
+		}
+	}
+	for _, state := range s.CircuitBreakers() {
+		if state == CircuitOpen {
+			h.OpenCircuits++
+		}
+	}
+	if err := s.persistence.storeError(); err != nil {
+		h.TaskStoreError = err.Error()
+	}
+
+	h.Status = HealthOK
+	if err := h.Err(); err != nil {
+		h.Status = HealthUnavailable
+		h.Reasons = append(h.Reasons, err.Error())
+	}
+	degraded := func(reason string) {
+		if h.Status == HealthOK {
+			h.Status = HealthDegraded
+		}
+		h.Reasons = append(h.Reasons, reason)
+	}
+	if h.QueueSaturation >= healthQueueSaturation {
+		degraded("work queues nearly full")
+	}
+	if h.FailingTasks > 0 {
+		degraded("tasks failing")
+	}
+	if h.OpenCircuits > 0 {
+		degraded("circuit breakers open")
+	}
+	if h.TaskStoreError != "" {
+		degraded("task store failing")
+	}
+	h.Ready = h.Status != HealthUnavailable
+	return h
+}
@@ -0,0 +1,379 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// TaskRun is the outcome of a firing of a task kept in the run history of the
+// task: when it started, how long it took in nanoseconds, the number of
+// metrics it collected and the errors of its failed jobs
+type TaskRun struct {
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Metrics  int           `json:"metrics"`
+	Errors   []string      `json:"errors,omitempty"`
+}
+
+// RunHistoryStore keeps the run history of the tasks across restarts
+type RunHistoryStore interface {
+	// Append keeps a run of the task
+	Append(taskID string, run TaskRun) error
+	// Load returns the last runs of the task kept, oldest first, every
+	// run kept if limit is 0
+	Load(taskID string, limit int) ([]TaskRun, error)
+	// Remove discards the runs of the task
+	Remove(taskID string) error
+}
+
+// FileRunHistoryStore is a RunHistoryStore appending the runs of each task to
+// a file of its own in a directory, one JSON run per line
+type FileRunHistoryStore struct {
+	dir  string
+	size int
+	// mutex serializes the changes to the directory, lines counts the lines
+	// of the files appended to since the store was opened
+	mutex sync.Mutex
+	lines map[string]int
+}
+
+// NewFileRunHistoryStore returns a FileRunHistoryStore keeping at least the
+// last size runs of each task in the directory at dir, which is created on
+// the first append. A file is compacted to its last size runs once it holds
+// twice as many.
+func NewFileRunHistoryStore(dir string, size uint) *FileRunHistoryStore {
+	return &FileRunHistoryStore{
+		dir:   dir,
+		size:  int(size),
+		lines: make(map[string]int),
+	}
+}
+
+// path returns the file of the runs of the task, its id being escaped so that
+// it cannot name a file outside of the directory
+func (f *FileRunHistoryStore) path(taskID string) string {
+	return filepath.Join(f.dir, url.QueryEscape(taskID)+".jsonl")
+}
+
+// Append writes the run at the end of the file of the task
+func (f *FileRunHistoryStore) Append(taskID string, run TaskRun) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := os.MkdirAll(f.dir, 0755); err != nil {
+		return err
+	}
+	n, ok := f.lines[taskID]
+	if !ok {
+		runs, err := f.read(taskID)
+		if err != nil {
+			return err
+		}
+		n = len(runs)
+	}
+	file, err := os.OpenFile(f.path(taskID), os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	// the run is written on a line of its own after a partial line left by
+	// an interrupted append
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			data = append([]byte{'\n'}, data...)
+		}
+	}
+	_, err = file.Write(data)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	n++
+	if f.size > 0 && n >= 2*f.size {
+		if err := f.compact(taskID); err != nil {
+			return err
+		}
+		n = f.size
+	}
+	f.lines[taskID] = n
+	return nil
+}
+
+// compact rewrites the file of the task with its last size runs only
+func (f *FileRunHistoryStore) compact(taskID string) error {
+	runs, err := f.read(taskID)
+	if err != nil {
+		return err
+	}
+	if len(runs) > f.size {
+		runs = runs[len(runs)-f.size:]
+	}
+	var buf bytes.Buffer
+	for _, run := range runs {
+		data, err := json.Marshal(run)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return writeFileAtomic(f.path(taskID), buf.Bytes())
+}
+
+// Load reads the last runs of the task, none if it has no file
+func (f *FileRunHistoryStore) Load(taskID string, limit int) ([]TaskRun, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	runs, err := f.read(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(runs) > limit {
+		runs = runs[len(runs)-limit:]
+	}
+	return runs, nil
+}
+
+// read returns the runs of the file of the task. A line which cannot be
+// parsed, as the last line of a file whose append was interrupted by a
+// crash, is skipped.
+func (f *FileRunHistoryStore) read(taskID string) ([]TaskRun, error) {
+	file, err := os.Open(f.path(taskID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var runs []TaskRun
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var run TaskRun
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			continue
+		}
+		runs = append(runs, run)
+	}
+	return runs, scanner.Err()
+}
+
+// Remove deletes the file of the task
+func (f *FileRunHistoryStore) Remove(taskID string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	delete(f.lines, taskID)
+	if err := os.Remove(f.path(taskID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// runHistory keeps the last size runs of a task, the oldest being dropped
+// first
+type runHistory struct {
+	mutex sync.Mutex
+	size  int
+	runs  []TaskRun
+	// next is the index the next run is written at once the history is full
+	next int
+}
+
+func newRunHistory(size uint) *runHistory {
+	return &runHistory{size: int(size)}
+}
+
+func (h *runHistory) add(run TaskRun) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(h.runs) < h.size {
+		h.runs = append(h.runs, run)
+		return
+	}
+	h.runs[h.next] = run
+	h.next = (h.next + 1) % h.size
+}
+
+// last returns the last runs, oldest first, every run kept if limit is 0
+func (h *runHistory) last(limit int) []TaskRun {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	n := len(h.runs)
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	runs := make([]TaskRun, 0, n)
+	for i := len(h.runs) - n; i < len(h.runs); i++ {
+		runs = append(runs, h.runs[(h.next+i)%len(h.runs)])
+	}
+	return runs
+}
+
+// runRecord accumulates the outcome of a firing of a task, reached from the
+// jobs of the firing through its context
+type runRecord struct {
+	mutex   sync.Mutex
+	metrics int
+	errors  []string
+}
+
+type runRecordKey struct{}
+
+func withRunRecord(ctx context.Context, r *runRecord) context.Context {
+	return context.WithValue(ctx, runRecordKey{}, r)
+}
+
+// recordCollected adds the metrics collected to the run record carried by
+// the context, if any
+func recordCollected(ctx context.Context, metrics int) {
+	if r, ok := ctx.Value(runRecordKey{}).(*runRecord); ok {
+		r.mutex.Lock()
+		r.metrics += metrics
+		r.mutex.Unlock()
+	}
+}
+
+// failures returns recordFailure recording the errors in the run record as
+// well
+func (r *runRecord) failures(recordFailure func([]error)) func([]error) {
+	return func(errs []error) {
+		r.mutex.Lock()
+		for _, err := range errs {
+			r.errors = append(r.errors, err.Error())
+		}
+		r.mutex.Unlock()
+		recordFailure(errs)
+	}
+}
+
+// recordRun adds the firing of the task started at start to its run history,
+// and to the run history store of the scheduler if there is one
+func (t *task) recordRun(start time.Time, r *runRecord) {
+	if t.history == nil {
+		return
+	}
+	r.mutex.Lock()
+	run := TaskRun{
+		Start:    start,
+		Duration: now().Sub(start),
+		Metrics:  r.metrics,
+		Errors:   r.errors,
+	}
+	r.mutex.Unlock()
+	t.history.add(run)
+	if t.historyStore == nil {
+		return
+	}
+	if store := t.historyStore(); store != nil {
+		if err := store.Append(t.id, run); err != nil {
+			taskLogger.WithFields(log.Fields{
+				"_block":    "record-run",
+				"_error":    err.Error(),
+				"task-id":   t.id,
+				"task-name": t.name,
+			}).Warn("failed to save the run of the task")
+		}
+	}
+}
+
+// SetRunHistoryStore sets the store keeping the run history of the tasks
+// across restarts, the history is only kept in memory if nil
+func (s *scheduler) SetRunHistoryStore(store RunHistoryStore) {
+	s.runHistoryMutex.Lock()
+	s.runHistory = store
+	s.runHistoryMutex.Unlock()
+	schedulerLogger.WithFields(log.Fields{
+		"_block": "set-run-history-store",
+	}).Debug("run history store linked")
+}
+
+func (s *scheduler) getRunHistoryStore() RunHistoryStore {
+	s.runHistoryMutex.RLock()
+	defer s.runHistoryMutex.RUnlock()
+	return s.runHistory
+}
+
+// GetTaskHistory returns the last limit runs of the task, oldest first, every
+// run kept if limit is 0. The scheduler keeps the last task_history_size
+// runs of each task; the history is empty if it is disabled.
+func (s *scheduler) GetTaskHistory(id string, limit int) ([]TaskRun, error) {
+	t, err := s.getTask(id)
+	if err != nil {
+		return nil, err
+	}
+	if t.history == nil {
+		return []TaskRun{}, nil
+	}
+	return t.history.last(limit), nil
+}
+
+// loadRunHistory fills the run history of the task, as it is created again
+// under its id, with the runs kept in the run history store
+func (s *scheduler) loadRunHistory(t *task) {
+	store := s.getRunHistoryStore()
+	if store == nil || t.history == nil {
+		return
+	}
+	runs, err := store.Load(t.id, t.history.size)
+	if err != nil {
+		schedulerLogger.WithFields(log.Fields{
+			"_block":  "load-run-history",
+			"_error":  err.Error(),
+			"task-id": t.id,
+		}).Warn("failed to load the run history of the task")
+		return
+	}
+	for _, run := range runs {
+		t.history.add(run)
+	}
+}
+
+// removeRunHistory discards the runs of a removed task from the run history
+// store
+func (s *scheduler) removeRunHistory(id string) {
+	store := s.getRunHistoryStore()
+	if store == nil {
+		return
+	}
+	if err := store.Remove(id); err != nil {
+		schedulerLogger.WithFields(log.Fields{
+			"_block":  "remove-run-history",
+			"_error":  err.Error(),
+			"task-id": id,
+		}).Warn("failed to remove the run history of the task")
+	}
+}
@@ -0,0 +1,123 @@
+// +build legacy
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRunHistory(t *testing.T) {
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	runAt := func(i int) TaskRun {
+		return TaskRun{Start: start.Add(time.Duration(i) * time.Minute), Metrics: i}
+	}
+	starts := func(runs []TaskRun) []int {
+		var metrics []int
+		for _, r := range runs {
+			metrics = append(metrics, r.Metrics)
+		}
+		return metrics
+	}
+	Convey("runHistory", t, func() {
+		h := newRunHistory(3)
+		for i := 1; i <= 4; i++ {
+			h.add(runAt(i))
+		}
+		Convey("keeps the last runs, oldest first", func() {
+			So(starts(h.last(0)), ShouldResemble, []int{2, 3, 4})
+		})
+		Convey("returns the last runs up to the limit", func() {
+			So(starts(h.last(2)), ShouldResemble, []int{3, 4})
+			So(starts(h.last(10)), ShouldResemble, []int{2, 3, 4})
+		})
+	})
+	Convey("FileRunHistoryStore", t, func() {
+		dir, err := ioutil.TempDir("", "run-history")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		store := NewFileRunHistoryStore(filepath.Join(dir, "history"), 2)
+		Convey("returns no run for a task without any", func() {
+			runs, err := store.Load("missing", 0)
+			So(err, ShouldBeNil)
+			So(runs, ShouldBeEmpty)
+		})
+		Convey("keeps the runs of each task", func() {
+			So(store.Append("a", runAt(1)), ShouldBeNil)
+			So(store.Append("b", runAt(2)), ShouldBeNil)
+			So(store.Append("a", runAt(3)), ShouldBeNil)
+			runs, err := store.Load("a", 0)
+			So(err, ShouldBeNil)
+			So(starts(runs), ShouldResemble, []int{1, 3})
+			So(runs[0].Start.Equal(start.Add(time.Minute)), ShouldBeTrue)
+			Convey("compacting them to the last ones", func() {
+				So(store.Append("a", runAt(5)), ShouldBeNil)
+				So(store.Append("a", runAt(7)), ShouldBeNil)
+				reopened := NewFileRunHistoryStore(filepath.Join(dir, "history"), 2)
+				runs, err := reopened.Load("a", 0)
+				So(err, ShouldBeNil)
+				So(starts(runs), ShouldResemble, []int{5, 7})
+			})
+			Convey("up to the limit", func() {
+				runs, err := store.Load("a", 1)
+				So(err, ShouldBeNil)
+				So(starts(runs), ShouldResemble, []int{3})
+			})
+			Convey("until they are removed", func() {
+				So(store.Remove("a"), ShouldBeNil)
+				runs, err := store.Load("a", 0)
+				So(err, ShouldBeNil)
+				So(runs, ShouldBeEmpty)
+				So(store.Remove("a"), ShouldBeNil)
+			})
+		})
+		Convey("skips a truncated run", func() {
+			So(store.Append("a", runAt(1)), ShouldBeNil)
+			f, err := os.OpenFile(store.path("a"), os.O_WRONLY|os.O_APPEND, 0644)
+			So(err, ShouldBeNil)
+			f.Write([]byte(`{"start":`))
+			f.Close()
+			runs, err := store.Load("a", 0)
+			So(err, ShouldBeNil)
+			So(starts(runs), ShouldResemble, []int{1})
+			Convey("and appends the next one on a line of its own", func() {
+				So(store.Append("a", runAt(2)), ShouldBeNil)
+				runs, err := store.Load("a", 0)
+				So(err, ShouldBeNil)
+				So(starts(runs), ShouldResemble, []int{1, 2})
+			})
+		})
+		Convey("keeps the file of a task id naming a path within the directory", func() {
+			So(store.Append("../escape", runAt(1)), ShouldBeNil)
+			_, err := os.Stat(filepath.Join(dir, "escape.jsonl"))
+			So(os.IsNotExist(err), ShouldBeTrue)
+			runs, err := store.Load("../escape", 0)
+			So(err, ShouldBeNil)
+			So(runs, ShouldHaveLength, 1)
+		})
+	})
+}
@@ -20,6 +20,8 @@ limitations under the License.
 package scheduler
 
 import (
+	"context"
+	"math/rand"
 	"sync"
 	"time"
 
@@ -28,6 +30,7 @@ import (
 	"github.com/intelsdi-x/snap/core"
 	"github.com/intelsdi-x/snap/core/cdata"
 	"github.com/intelsdi-x/snap/core/ctypes"
+	"github.com/intelsdi-x/snap/pkg/chrono"
 	. "github.com/intelsdi-x/snap/pkg/promise"
 )
 
@@ -81,6 +84,19 @@ type job interface {
 	Errors() []error
 	StartTime() time.Time
 	Deadline() time.Time
+	Timeout() time.Duration
+	SetTimeout(time.Duration)
+	Context() context.Context
+	Cancel()
+	SetRetry(count int, backoff time.Duration, until time.Time)
+	SetRetryJitter(time.Duration)
+	NextRetry() (time.Duration, bool)
+	Retried() int
+	ResetErrors()
+	Priority() int
+	SetPriority(int)
+	QueueFullPolicy() (QueueFullPolicy, bool)
+	SetQueueFullPolicy(QueueFullPolicy)
 	Name() string
 	Version() int
 	Type() jobType
@@ -99,11 +115,29 @@ type coreJob struct {
 	taskID    string
 	jtype     jobType
 	deadline  time.Time
+	timeout   time.Duration
 	starttime time.Time
 	errors    []error
+
+	// ctx is cancelled when the job is abandoned
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	retries      int
+	retryBackoff time.Duration
+	retryUntil   time.Time
+	retryJitter  time.Duration
+	retried      int
+
+	priority int
+	// queueFullPolicy applies to the job in place of the policy of its queue
+	// when hasQueueFullPolicy is set
+	queueFullPolicy    QueueFullPolicy
+	hasQueueFullPolicy bool
 }
 
 func newCoreJob(t jobType, deadline time.Time, taskID string, name string, version int) *coreJob {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &coreJob{
 		jtype:     t,
 		name:      name,
@@ -112,6 +146,8 @@ func newCoreJob(t jobType, deadline time.Time, taskID string, name string, versi
 		taskID:    taskID,
 		errors:    make([]error, 0),
 		starttime: time.Now(),
+		ctx:       ctx,
+		cancel:    cancel,
 	}
 }
 
@@ -123,6 +159,113 @@ func (c *coreJob) Deadline() time.Time {
 	return c.deadline
 }
 
+// Timeout returns how long a worker runs the job before abandoning it.
+// Zero means the job is never abandoned.
+func (c *coreJob) Timeout() time.Duration {
+	return c.timeout
+}
+
+func (c *coreJob) SetTimeout(d time.Duration) {
+	c.timeout = d
+}
+
+// Context returns the context of the job, which is cancelled once the job is
+// abandoned by its worker
+func (c *coreJob) Context() context.Context {
+	return c.ctx
+}
+
+// Cancel cancels the context of the job, telling the metric manager running
+// the job to give up on it
+func (c *coreJob) Cancel() {
+	c.cancel()
+}
+
+// setParentContext derives the context of a job not run yet from ctx, for
+// the values ctx carries, such as the span of the job, to reach the plugins
+func (c *coreJob) setParentContext(ctx context.Context) {
+	c.cancel()
+	c.ctx, c.cancel = context.WithCancel(ctx)
+}
+
+// SetRetry sets the number of times the job is retried after failing. The
+// first retry waits for the backoff, which doubles for each following retry.
+// A retry which would not start before until, when it is set, or before the
+// deadline of the job is not attempted.
+func (c *coreJob) SetRetry(count int, backoff time.Duration, until time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	c.retries = count
+	c.retryBackoff = backoff
+	c.retryUntil = until
+}
+
+// SetRetryJitter sets the maximum random delay added to the backoff of each
+// retry, so that the jobs failing together are not all retried at once
+func (c *coreJob) SetRetryJitter(d time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.retryJitter = d
+}
+
+// NextRetry returns how long to wait before retrying the failed job, or false
+// if the job must not be retried anymore.
+func (c *coreJob) NextRetry() (time.Duration, bool) {
+	c.Lock()
+	defer c.Unlock()
+	if c.retried >= c.retries {
+		return 0, false
+	}
+	delay := c.retryBackoff << uint(c.retried)
+	if c.retryJitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(c.retryJitter)))
+	}
+	at := chrono.Chrono.Now().Add(delay)
+	if !at.Before(c.deadline) || (!c.retryUntil.IsZero() && !at.Before(c.retryUntil)) {
+		return 0, false
+	}
+	c.retried++
+	return delay, true
+}
+
+// Retried returns the number of times the job was retried
+func (c *coreJob) Retried() int {
+	c.Lock()
+	defer c.Unlock()
+	return c.retried
+}
+
+// ResetErrors clears the errors of a failed job before it is retried
+func (c *coreJob) ResetErrors() {
+	c.Lock()
+	defer c.Unlock()
+	c.errors = make([]error, 0)
+}
+
+// Priority returns the priority of the job in the work queue
+func (c *coreJob) Priority() int {
+	return c.priority
+}
+
+// SetPriority sets the priority of the job, a job is queued ahead of the
+// jobs with a lower priority
+func (c *coreJob) SetPriority(p int) {
+	c.priority = p
+}
+
+// QueueFullPolicy returns the policy applied to the job when its queue is
+// full, false if the policy of the queue applies
+func (c *coreJob) QueueFullPolicy() (QueueFullPolicy, bool) {
+	return c.queueFullPolicy, c.hasQueueFullPolicy
+}
+
+// SetQueueFullPolicy sets the policy applied to the job in place of the
+// policy of its queue when the queue is full
+func (c *coreJob) SetQueueFullPolicy(p QueueFullPolicy) {
+	c.queueFullPolicy = p
+	c.hasQueueFullPolicy = true
+}
+
 func (c *coreJob) Name() string {
 	return c.name
 }
@@ -156,7 +299,12 @@ func (c *coreJob) AddErrors(errs ...error) {
 }
 
 func (c *coreJob) Errors() []error {
-	return c.errors
+	c.Lock()
+	defer c.Unlock()
+	// a copy is returned as an abandoned job may still add errors
+	errs := make([]error, len(c.errors))
+	copy(errs, c.errors)
+	return errs
 }
 
 func (c *coreJob) TaskID() string {
@@ -170,6 +318,8 @@ type collectorJob struct {
 	metrics        []core.Metric
 	configDataTree *cdata.ConfigDataTree
 	tags           map[string]map[string]string
+	// due tells which metrics are collected by the job, all of them if nil
+	due func(core.Namespace) bool
 }
 
 func newCollectorJob(
@@ -240,7 +390,20 @@ func (c *collectorJob) Run() {
 		}
 	}
 
-	ret, errs := c.collector.CollectMetrics(c.TaskID(), c.tags)
+	var (
+		ret  []core.Metric
+		errs []error
+	)
+	if cc, ok := c.collector.(collectsMetricsContext); ok {
+		ret, errs = cc.CollectMetricsContext(c.Context(), c.TaskID(), c.tags, c.due)
+	} else if dc, ok := c.collector.(collectsDueMetrics); ok && c.due != nil {
+		ret, errs = dc.CollectMetricsDue(c.TaskID(), c.tags, c.due)
+	} else {
+		ret, errs = c.collector.CollectMetrics(c.TaskID(), c.tags)
+		if c.due != nil {
+			ret = filterDue(ret, c.due)
+		}
+	}
 
 	log.WithFields(log.Fields{
 		"_module":      "scheduler-job",
@@ -263,6 +426,17 @@ func (c *collectorJob) Run() {
 	}
 }
 
+// filterDue returns the metrics which are due
+func filterDue(mts []core.Metric, due func(core.Namespace) bool) []core.Metric {
+	ret := make([]core.Metric, 0, len(mts))
+	for _, m := range mts {
+		if due(m.Namespace()) {
+			ret = append(ret, m)
+		}
+	}
+	return ret
+}
+
 type processJob struct {
 	*coreJob
 	processor processesMetrics
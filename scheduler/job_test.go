@@ -26,6 +26,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/intelsdi-x/snap/control/plugin"
 	"github.com/intelsdi-x/snap/core"
 	"github.com/intelsdi-x/snap/core/cdata"
 
@@ -94,6 +95,29 @@ func TestCollectorJob(t *testing.T) {
 			So(cj.Errors(), ShouldResemble, []error{e1, e2, e3})
 		})
 	})
+	Convey("NextRetry()", t, func() {
+		Convey("it should double the backoff for each retry", func() {
+			cj := newCollectorJob([]core.RequestedMetric{}, defaultDeadline, &mockCollector{}, cdt, "taskid", tags)
+			cj.SetRetry(2, time.Millisecond, time.Time{})
+			d, ok := cj.NextRetry()
+			So(ok, ShouldBeTrue)
+			So(d, ShouldEqual, time.Millisecond)
+			d, ok = cj.NextRetry()
+			So(ok, ShouldBeTrue)
+			So(d, ShouldEqual, 2*time.Millisecond)
+			_, ok = cj.NextRetry()
+			So(ok, ShouldBeFalse)
+		})
+		Convey("it should add up to the jitter to the backoff", func() {
+			cj := newCollectorJob([]core.RequestedMetric{}, defaultDeadline, &mockCollector{}, cdt, "taskid", tags)
+			cj.SetRetry(1, time.Millisecond, time.Time{})
+			cj.SetRetryJitter(time.Millisecond)
+			d, ok := cj.NextRetry()
+			So(ok, ShouldBeTrue)
+			So(d, ShouldBeGreaterThanOrEqualTo, time.Millisecond)
+			So(d, ShouldBeLessThan, 2*time.Millisecond)
+		})
+	})
 	Convey("Run()", t, func() {
 		Convey("it should complete without errors", func() {
 			cj := newCollectorJob([]core.RequestedMetric{}, defaultDeadline, &mockCollector{}, cdt, "taskid", tags)
@@ -122,3 +146,44 @@ func TestQueuedJob(t *testing.T) {
 		})
 	})
 }
+
+// dueCollector is a collector of the metrics given, able to collect only the
+// metrics which are due
+type dueCollector struct {
+	metrics []core.Metric
+	dueRuns int
+}
+
+func (d *dueCollector) CollectMetrics(string, map[string]map[string]string) ([]core.Metric, []error) {
+	return d.metrics, nil
+}
+
+func (d *dueCollector) CollectMetricsDue(_ string, _ map[string]map[string]string, due func(core.Namespace) bool) ([]core.Metric, []error) {
+	d.dueRuns++
+	return filterDue(d.metrics, due), nil
+}
+
+func TestCollectorJobDue(t *testing.T) {
+	log.SetLevel(log.FatalLevel)
+	cpu := plugin.MetricType{Namespace_: core.NewNamespace("intel", "cpu")}
+	disk := plugin.MetricType{Namespace_: core.NewNamespace("intel", "disk")}
+	onlyCPU := func(ns core.Namespace) bool { return ns.String() == cpu.Namespace().String() }
+	Convey("Run() with the metrics due", t, func() {
+		Convey("it should collect the due metrics from a collector able to", func() {
+			c := &dueCollector{metrics: []core.Metric{cpu, disk}}
+			cj := newCollectorJob(nil, defaultDeadline, c, cdata.NewTree(), "taskid", nil)
+			cj.(*collectorJob).due = onlyCPU
+			cj.Run()
+			So(c.dueRuns, ShouldEqual, 1)
+			So(cj.(*collectorJob).Metrics(), ShouldResemble, []core.Metric{cpu})
+		})
+		Convey("it should drop the metrics which are not due otherwise", func() {
+			c := &dueCollector{metrics: []core.Metric{cpu, disk}}
+			cj := newCollectorJob(nil, defaultDeadline, struct{ collectsMetrics }{c}, cdata.NewTree(), "taskid", nil)
+			cj.(*collectorJob).due = onlyCPU
+			cj.Run()
+			So(c.dueRuns, ShouldEqual, 0)
+			So(cj.(*collectorJob).Metrics(), ShouldResemble, []core.Metric{cpu})
+		})
+	})
+}
@@ -0,0 +1,329 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+var errLeaseLocked = errors.New("leader lease is locked")
+
+// LeaderElector elects the leader among the scheduler instances sharing a
+// task store. Only the leader restores the tasks of the store and fires them,
+// the other instances stand by until they are elected.
+type LeaderElector interface {
+	// Campaign blocks until the instance with the given id is elected leader,
+	// or until the context is done, returning its error. The channel returned
+	// is closed once the instance is no longer the leader.
+	Campaign(ctx context.Context, id string) (<-chan struct{}, error)
+	// Resign gives up the leadership of the instance with the given id, the
+	// channel returned by its campaign is closed
+	Resign(id string) error
+}
+
+// FileLeaderElector is a LeaderElector keeping the lease of the leader in a
+// file on a storage shared by the instances. The leader renews its lease every
+// third of its ttl, and loses the leadership once it fails to renew it before
+// it expires. An instance takes over the lease once it has expired, so the
+// clocks of the instances must agree far closer than the ttl.
+type FileLeaderElector struct {
+	path string
+	ttl  time.Duration
+
+	mutex sync.Mutex
+	// leases holds the leases renewed for each instance elected
+	leases map[string]*heldLease
+}
+
+// leaderLease is the content of the lease file
+type leaderLease struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+// heldLease is a lease renewed for an instance until it resigns
+type heldLease struct {
+	resign chan struct{}
+	lost   chan struct{}
+}
+
+// NewFileLeaderElector returns a FileLeaderElector keeping the lease in the
+// file at path, lasting ttl unless renewed
+func NewFileLeaderElector(path string, ttl time.Duration) *FileLeaderElector {
+	if ttl <= 0 {
+		ttl = time.Duration(defaultLeaderLeaseTTL) * time.Second
+	}
+	return &FileLeaderElector{
+		path:   path,
+		ttl:    ttl,
+		leases: map[string]*heldLease{},
+	}
+}
+
+// Campaign tries to acquire the lease every third of its ttl until it is
+// acquired or the context is done
+func (f *FileLeaderElector) Campaign(ctx context.Context, id string) (<-chan struct{}, error) {
+	logger := schedulerLogger.WithFields(log.Fields{
+		"_block":      "campaign",
+		"instance-id": id,
+	})
+	tick := time.NewTicker(f.ttl / 3)
+	defer tick.Stop()
+	for {
+		expires, err := f.acquire(id)
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"_error": err.Error(),
+			}).Warning("error acquiring the leader lease")
+		}
+		if !expires.IsZero() {
+			l := &heldLease{
+				resign: make(chan struct{}),
+				lost:   make(chan struct{}),
+			}
+			f.mutex.Lock()
+			f.leases[id] = l
+			f.mutex.Unlock()
+			go f.renew(id, expires, l)
+			return l.lost, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-tick.C:
+		}
+	}
+}
+
+// renew renews the lease of an instance every third of its ttl until the
+// instance resigns or the lease is lost. A renewal failing on an error is
+// retried as long as the lease has not expired.
+func (f *FileLeaderElector) renew(id string, expires time.Time, l *heldLease) {
+	defer close(l.lost)
+	logger := schedulerLogger.WithFields(log.Fields{
+		"_block":      "renew-lease",
+		"instance-id": id,
+	})
+	tick := time.NewTicker(f.ttl / 3)
+	defer tick.Stop()
+	for {
+		select {
+		case <-l.resign:
+			return
+		case <-tick.C:
+		}
+		renewed, err := f.acquire(id)
+		switch {
+		case err != nil && time.Now().Add(f.ttl/3).Before(expires):
+			logger.WithFields(log.Fields{
+				"_error": err.Error(),
+			}).Warning("error renewing the leader lease")
+		case err != nil:
+			logger.WithFields(log.Fields{
+				"_error": err.Error(),
+			}).Error("leader lease expired")
+			return
+		case renewed.IsZero():
+			logger.Error("leader lease taken over by another instance")
+			return
+		default:
+			expires = renewed
+		}
+	}
+}
+
+// Resign stops renewing the lease of the instance and releases it, so that
+// a standby instance is elected without waiting for the lease to expire
+func (f *FileLeaderElector) Resign(id string) error {
+	f.mutex.Lock()
+	l, ok := f.leases[id]
+	delete(f.leases, id)
+	f.mutex.Unlock()
+	if !ok {
+		return nil
+	}
+	close(l.resign)
+	<-l.lost
+
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	lease, err := f.read()
+	if err != nil || lease == nil || lease.Holder != id {
+		return err
+	}
+	return os.Remove(f.path)
+}
+
+// acquire takes the lease for the instance, or renews it if the instance
+// holds it, and returns when the lease expires. It returns a zero time while
+// the lease is held by another instance.
+func (f *FileLeaderElector) acquire(id string) (time.Time, error) {
+	unlock, err := f.lock()
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer unlock()
+	lease, err := f.read()
+	if err != nil {
+		return time.Time{}, err
+	}
+	now := time.Now()
+	if lease != nil && lease.Holder != id && now.Before(lease.Expires) {
+		return time.Time{}, nil
+	}
+	acquired := leaderLease{
+		Holder:  id,
+		Expires: now.Add(f.ttl),
+	}
+	data, err := json.Marshal(acquired)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := writeFileAtomic(f.path, data); err != nil {
+		return time.Time{}, err
+	}
+	return acquired.Expires, nil
+}
+
+// read returns the lease kept in the file, nil if there is none
+func (f *FileLeaderElector) read() (*leaderLease, error) {
+	data, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	lease := &leaderLease{}
+	if err := json.Unmarshal(data, lease); err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+// lock keeps the other instances from reading or writing the lease until the
+// returned function is called. The lock is a directory next to the lease
+// file, as creating a directory is atomic on every file system; a lock left
+// by an instance which crashed holding it is removed once older than the ttl.
+func (f *FileLeaderElector) lock() (func(), error) {
+	path := f.path + ".lock"
+	deadline := time.Now().Add(f.ttl / 3)
+	for {
+		err := os.Mkdir(path, 0700)
+		if err == nil {
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if fi, err := os.Stat(path); err == nil && time.Since(fi.ModTime()) > f.ttl {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, errLeaseLocked
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// SetLeaderElector sets the elector of the leader among the schedulers
+// sharing the task store of the scheduler. A scheduler with an elector stands
+// by once started, and restores and fires the tasks of the store only while it
+// is the leader. It must be set before the scheduler is started.
+func (s *scheduler) SetLeaderElector(le LeaderElector) {
+	s.elector = le
+	schedulerLogger.WithFields(log.Fields{
+		"_block":      "set-leader-elector",
+		"instance-id": s.instanceID,
+	}).Debug("leader elector linked")
+}
+
+// IsLeader returns whether the scheduler fires its tasks, the scheduler
+// always leads without a leader elector
+func (s *scheduler) IsLeader() bool {
+	return s.elector == nil || atomic.LoadInt32(&s.leading) == 1
+}
+
+// campaign stands the scheduler for election until the context is done. Once
+// elected the scheduler restores the tasks of the task store, the running
+// ones subscribing to their plugins again, and steps down when it loses the
+// leadership.
+func (s *scheduler) campaign(ctx context.Context, mm managesMetrics, done chan struct{}) {
+	defer close(done)
+	logger := schedulerLogger.WithFields(log.Fields{
+		"_block":      "campaign",
+		"instance-id": s.instanceID,
+	})
+	for {
+		logger.Info("scheduler standing by")
+		lost, err := s.elector.Campaign(ctx, s.instanceID)
+		if err != nil {
+			return
+		}
+		logger.Info("scheduler elected leader")
+		atomic.StoreInt32(&s.leading, 1)
+		// the tasks are restored on each election as the previous leader
+		// may have changed them since
+		s.persistence.restored = false
+		s.loadStartupTasks(mm)
+		select {
+		case <-lost:
+			logger.Warning("scheduler lost the leadership")
+			s.stepDown()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// stepDown stops the tasks of a scheduler which is no longer the leader and
+// drops them, as the leader restores them from the task store. The tasks are
+// not saved meanwhile, so that the store keeps them as they were.
+func (s *scheduler) stepDown() {
+	atomic.StoreInt32(&s.leading, 0)
+	tasks := s.tasks.Table()
+	var wg sync.WaitGroup
+	for _, t := range tasks {
+		wg.Add(1)
+		go func(t *task) {
+			defer wg.Done()
+			t.Kill()
+			<-t.stopped()
+			t.UnsubscribePlugins()
+		}(t)
+	}
+	wg.Wait()
+	for _, t := range tasks {
+		s.tasks.remove(t)
+	}
+}
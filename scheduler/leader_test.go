@@ -0,0 +1,111 @@
+// +build legacy
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFileLeaderElector(t *testing.T) {
+	Convey("FileLeaderElector", t, func() {
+		dir, err := ioutil.TempDir("", "leader-lease")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "leader.lease")
+		ttl := 300 * time.Millisecond
+		a := NewFileLeaderElector(path, ttl)
+		b := NewFileLeaderElector(path, ttl)
+		defer a.Resign("a")
+		defer b.Resign("b")
+		campaign := func(le LeaderElector, id string, timeout time.Duration) (<-chan struct{}, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			return le.Campaign(ctx, id)
+		}
+
+		Convey("elects a single leader", func() {
+			lost, err := campaign(a, "a", ttl)
+			So(err, ShouldBeNil)
+			_, err = campaign(b, "b", 2*ttl)
+			So(err, ShouldResemble, context.DeadlineExceeded)
+			// the lease is renewed meanwhile
+			select {
+			case <-lost:
+				t.Fatal("leadership lost")
+			default:
+			}
+
+			Convey("and elects another one once it resigns", func() {
+				So(a.Resign("a"), ShouldBeNil)
+				<-lost
+				_, err := os.Stat(path)
+				So(os.IsNotExist(err), ShouldBeTrue)
+				lost, err := campaign(b, "b", ttl)
+				So(err, ShouldBeNil)
+				So(b.Resign("b"), ShouldBeNil)
+				<-lost
+			})
+		})
+		Convey("takes over a lease which expired", func() {
+			data, _ := json.Marshal(leaderLease{Holder: "crashed", Expires: time.Now().Add(ttl / 2)})
+			So(ioutil.WriteFile(path, data, 0600), ShouldBeNil)
+			start := time.Now()
+			lost, err := campaign(b, "b", 2*ttl)
+			So(err, ShouldBeNil)
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, ttl/2)
+			So(b.Resign("b"), ShouldBeNil)
+			<-lost
+		})
+		Convey("notifies the leader of a lease taken over", func() {
+			lost, err := campaign(a, "a", ttl)
+			So(err, ShouldBeNil)
+			data, _ := json.Marshal(leaderLease{Holder: "other", Expires: time.Now().Add(time.Hour)})
+			So(ioutil.WriteFile(path, data, 0600), ShouldBeNil)
+			select {
+			case <-lost:
+			case <-time.After(ttl):
+				t.Fatal("leadership not lost")
+			}
+			// the lease of the other instance is not released
+			So(a.Resign("a"), ShouldBeNil)
+			_, err = os.Stat(path)
+			So(err, ShouldBeNil)
+		})
+		Convey("removes a lock left by a crashed instance", func() {
+			So(os.Mkdir(path+".lock", 0700), ShouldBeNil)
+			old := time.Now().Add(-2 * ttl)
+			So(os.Chtimes(path+".lock", old, old), ShouldBeNil)
+			lost, err := campaign(a, "a", ttl)
+			So(err, ShouldBeNil)
+			So(a.Resign("a"), ShouldBeNil)
+			<-lost
+		})
+	})
+}
@@ -51,6 +51,19 @@ func (m *managers) Add(key string, val managesMetrics) {
 	m.mutex.Unlock()
 }
 
+// addMissing adds the remote managers of other which are not in m
+func (m *managers) addMissing(other managers) {
+	other.mutex.RLock()
+	defer other.mutex.RUnlock()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for key, val := range other.remoteManagers {
+		if _, ok := m.remoteManagers[key]; !ok {
+			m.remoteManagers[key] = val
+		}
+	}
+}
+
 // Returns the managesMetric instance that maps to given
 // string. If an empty string is given, will instead return
 // the local instance passed in on initialization.
@@ -0,0 +1,70 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/intelsdi-x/snap/core"
+)
+
+// metricWindow accumulates the metrics collected by the firings of a task
+// with a collect window, until the window closes
+type metricWindow struct {
+	mutex sync.Mutex
+	// opened is the time of the first collection of the window, zero while
+	// the window is empty
+	opened  time.Time
+	metrics []core.Metric
+}
+
+// add appends the metrics collected at the given time to the window. When a
+// window of duration d has elapsed since it was opened, the metrics of the
+// closed window are returned along with true, and the metrics collected at
+// the given time open the next window.
+func (w *metricWindow) add(at time.Time, d time.Duration, mts []core.Metric) ([]core.Metric, bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	var closed []core.Metric
+	ok := false
+	if !w.opened.IsZero() && at.Sub(w.opened) >= d {
+		closed, ok = w.metrics, true
+		w.opened, w.metrics = time.Time{}, nil
+	}
+	if w.opened.IsZero() {
+		w.opened = at
+	}
+	w.metrics = append(w.metrics, mts...)
+	return closed, ok
+}
+
+// flush returns the metrics of the window and empties it, whether or not
+// the window has elapsed. It returns false when the window is empty.
+func (w *metricWindow) flush() ([]core.Metric, bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.opened.IsZero() {
+		return nil, false
+	}
+	mts := w.metrics
+	w.opened, w.metrics = time.Time{}, nil
+	return mts, true
+}
@@ -0,0 +1,56 @@
+// +build legacy
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMetricWindow(t *testing.T) {
+	Convey("metricWindow", t, func() {
+		var w metricWindow
+		start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+		mts := []core.Metric{&metric{namespace: core.NewNamespace("intel", "mock", "foo")}}
+		Convey("has nothing to flush while empty", func() {
+			_, ok := w.flush()
+			So(ok, ShouldBeFalse)
+		})
+		Convey("closes once its duration has elapsed since it was opened", func() {
+			_, closed := w.add(start, time.Minute, mts)
+			So(closed, ShouldBeFalse)
+			_, closed = w.add(start.Add(59*time.Second), time.Minute, mts)
+			So(closed, ShouldBeFalse)
+			closedMts, closed := w.add(start.Add(time.Minute), time.Minute, mts)
+			So(closed, ShouldBeTrue)
+			So(len(closedMts), ShouldEqual, 2)
+			Convey("and opens the next window with the metrics added", func() {
+				flushed, ok := w.flush()
+				So(ok, ShouldBeTrue)
+				So(len(flushed), ShouldEqual, 1)
+			})
+		})
+	})
+}
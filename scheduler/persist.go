@@ -0,0 +1,326 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/ghodss/yaml"
+
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/ctypes"
+	"github.com/intelsdi-x/snap/pkg/schedule"
+	"github.com/intelsdi-x/snap/scheduler/wmap"
+)
+
+var (
+	// ErrScheduleNotSavable - The error message for a task whose schedule cannot be serialized
+	ErrScheduleNotSavable = errors.New("Schedule of the task cannot be saved")
+	// ErrSecretNotSavable - The error message for a task whose secret config values cannot be encrypted
+	ErrSecretNotSavable = errors.New("Secret config values of the task cannot be encrypted")
+	// ErrTaskNotRestored - The error message for a saved task which could not be restored
+	ErrTaskNotRestored = errors.New("Task could not be restored")
+	// ErrUnknownManifestFormat - The error message for a task manifest format which is not supported
+	ErrUnknownManifestFormat = errors.New("Unknown task manifest format")
+)
+
+// ManifestFormat is the encoding of a task manifest written by ExportTask
+type ManifestFormat int
+
+const (
+	// ManifestJSON encodes a task manifest as JSON
+	ManifestJSON ManifestFormat = iota
+	// ManifestYAML encodes a task manifest as YAML
+	ManifestYAML
+)
+
+// savedTask is the form in which SaveTasks writes a task
+type savedTask struct {
+	ID    string                    `json:"id"`
+	State string                    `json:"state"`
+	Task  *core.TaskCreationRequest `json:"task"`
+}
+
+// SaveTasks writes the tasks of the scheduler to w as JSON, to be restored
+// by LoadTasks. The workflow map of a task holds its metrics and their
+// config, which are saved along with its schedule, options and state.
+// Tasks on a trigger schedule are not saved, as the channel triggering them
+// does not outlive the process, nor are the tasks autodiscovered on Start,
+// which are created again from their files.
+func (s *scheduler) SaveTasks(w io.Writer) error {
+	saved, err := s.savedTasks()
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(saved)
+}
+
+// savedTasks returns the tasks of the scheduler in the form SaveTasks writes
+// them
+func (s *scheduler) savedTasks() ([]savedTask, error) {
+	tasks := s.tasks.Table()
+	saved := make([]savedTask, 0, len(tasks))
+	for _, t := range tasks {
+		if _, trigger := t.Schedule().(*schedule.TriggerSchedule); trigger || t.autodiscovered {
+			continue
+		}
+		tr, err := taskCreationRequest(t)
+		if err != nil {
+			return nil, err
+		}
+		state := t.State()
+		// a task saved while firing is running, it is started when restored
+		if state == core.TaskFiring {
+			state = core.TaskSpinning
+		}
+		saved = append(saved, savedTask{
+			ID:    t.id,
+			State: state.String(),
+			Task:  tr,
+		})
+	}
+	return saved, nil
+}
+
+// taskCreationRequest returns the creation request the task would be created
+// again from, holding its schedule, workflow map, options and config
+func taskCreationRequest(t *task) (*core.TaskCreationRequest, error) {
+	sch := core.ScheduleFromSchedule(t.Schedule())
+	if sch == nil {
+		return nil, fmt.Errorf("%v: ID(%v)", ErrScheduleNotSavable, t.id)
+	}
+	// secret config values are never written in plain text
+	wf, err := t.workflow.workflowMap.Encrypted()
+	if err != nil {
+		return nil, fmt.Errorf("%v: ID(%v): %v", ErrSecretNotSavable, t.id, err)
+	}
+	tr, err := buildTaskCreationRequest(t, sch, wf, func(value string) (interface{}, error) {
+		return ctypes.EncryptedManifestSecret(value)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%v: ID(%v): %v", ErrSecretNotSavable, t.id, err)
+	}
+	return tr, nil
+}
+
+// buildTaskCreationRequest returns the creation request of a task with the
+// schedule and the workflow map given, its secret base config values being
+// replaced by the value returned by secret
+func buildTaskCreationRequest(t *task, sch *core.Schedule, wf *wmap.WorkflowMap, secret func(value string) (interface{}, error)) (*core.TaskCreationRequest, error) {
+	tr := &core.TaskCreationRequest{
+		Name:             t.name,
+		Version:          1,
+		Deadline:         t.deadlineDuration.String(),
+		Workflow:         wf,
+		Schedule:         sch,
+		MaxFailures:      t.stopOnFailure,
+		MaxMetricsBuffer: t.maxMetricsBuffer,
+		MaxConcurrent:    t.maxConcurrent,
+		Priority:         t.priority,
+		QueueFullPolicy:  t.queueFullPolicy,
+		MaxRuns:          t.maxRuns,
+		HitCount:         t.HitCount(),
+		ScheduledRuns:    t.ScheduledRuns(),
+		Labels:           t.Labels(),
+		Tenant:           t.tenant,
+	}
+	if stopAt := t.StopAt(); !stopAt.IsZero() {
+		tr.StopAt = &stopAt
+	}
+	if t.timeout > 0 {
+		tr.Timeout = t.timeout.String()
+	}
+	if t.missedFirePolicy != core.MissedFireSkip {
+		tr.MissedFirePolicy = t.missedFirePolicy.String()
+		tr.MaxCatchUp = t.maxCatchUp
+	}
+	if base := t.BaseConfig(); base != nil {
+		tr.Config = make(map[string]interface{})
+		for k, v := range base.Table() {
+			if str, ok := v.(ctypes.ConfigValueStr); ok && str.Secret {
+				v, err := secret(str.Value)
+				if err != nil {
+					return nil, err
+				}
+				tr.Config[k] = v
+				continue
+			}
+			tr.Config[k] = v
+		}
+	}
+	if t.retries > 0 {
+		tr.Retries = t.retries
+		tr.RetryBackoff = t.retryBackoff.String()
+	}
+	if t.maxCollectDuration > 0 {
+		tr.MaxCollectDuration = t.maxCollectDuration.String()
+	}
+	if t.collectWindow > 0 {
+		tr.CollectWindow = t.collectWindow.String()
+	}
+	if len(t.dependsOn) > 0 {
+		tr.DependsOn, tr.PassMetrics = t.Dependencies()
+	}
+	return tr, nil
+}
+
+// LoadTasks creates the tasks saved by SaveTasks, under their original ids.
+// Tasks which were running are started again, subscribing to their plugins,
+// and tasks which were disabled are restored disabled. A task failing to be
+// restored does not stop the others from being loaded; an error is returned
+// for each of them.
+func (s *scheduler) LoadTasks(r io.Reader) []error {
+	var saved []savedTask
+	if err := json.NewDecoder(r).Decode(&saved); err != nil {
+		return []error{err}
+	}
+	errs, _ := s.loadTasks(saved)
+	return errs
+}
+
+// loadTasks creates the saved tasks like LoadTasks and returns the error of
+// each task which could not be restored along with the task
+func (s *scheduler) loadTasks(saved []savedTask) ([]error, []savedTask) {
+	var (
+		errs       []error
+		unrestored []savedTask
+	)
+	for _, st := range orderByDependencies(saved) {
+		if err := s.loadTask(st); err != nil {
+			schedulerLogger.WithFields(log.Fields{
+				"_block":  "load-tasks",
+				"_error":  err.Error(),
+				"task-id": st.ID,
+			}).Error(ErrTaskNotRestored)
+			errs = append(errs, fmt.Errorf("%v: ID(%v): %v", ErrTaskNotRestored, st.ID, err))
+			unrestored = append(unrestored, st)
+		}
+	}
+	return errs, unrestored
+}
+
+func (s *scheduler) loadTask(st savedTask) error {
+	if st.Task == nil {
+		return errors.New("Missing task creation request")
+	}
+	start := st.State == core.TaskSpinning.String()
+	tsk, err := core.CreateTaskFromRequest(st.Task, &start, s.CreateTask, core.SetTaskID(st.ID))
+	if err != nil {
+		return err
+	}
+	if st.State == core.TaskDisabled.String() {
+		t, err := s.getTask(tsk.ID())
+		if err != nil {
+			return err
+		}
+		t.setState(core.TaskDisabled)
+	}
+	return nil
+}
+
+// ExportTask returns the manifest of a task, the task creation request in
+// the format read by snaptel and the autodiscovery of tasks, holding its
+// metrics and their config, its schedule, workflow and options. The manifest
+// starts the task on creation if the task is running. It is read back by
+// CreateTaskFromManifest.
+func (s *scheduler) ExportTask(id string, format ManifestFormat) ([]byte, error) {
+	t, err := s.getTask(id)
+	if err != nil {
+		return nil, err
+	}
+	tr, err := taskCreationRequest(t)
+	if err != nil {
+		return nil, err
+	}
+	switch t.State() {
+	case core.TaskSpinning, core.TaskFiring:
+		tr.Start = true
+	}
+	js, err := json.MarshalIndent(tr, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case ManifestJSON:
+		return js, nil
+	case ManifestYAML:
+		return yaml.JSONToYAML(js)
+	}
+	return nil, ErrUnknownManifestFormat
+}
+
+// CreateTaskFromManifest creates a task from its JSON or YAML manifest,
+// which is validated like a task created through CreateTask. The task is
+// started on creation if the manifest says so.
+func (s *scheduler) CreateTaskFromManifest(manifest []byte) (core.Task, error) {
+	// a JSON manifest is valid YAML as well
+	js, err := yaml.YAMLToJSON(manifest)
+	if err != nil {
+		return nil, err
+	}
+	tr := &core.TaskCreationRequest{}
+	if err := json.Unmarshal(js, tr); err != nil {
+		return nil, err
+	}
+	return core.CreateTaskFromRequest(tr, nil, s.CreateTask)
+}
+
+// CreateTaskFromTemplate creates a task from a manifest template, a JSON or
+// YAML manifest holding variables such as {{.hostname}} or {{.interval}}.
+// The variables are resolved from vars, then from the environment, so that
+// one manifest can create a task for each host. A variable missing from both
+// fails the creation of the task.
+func (s *scheduler) CreateTaskFromTemplate(manifest []byte, vars map[string]string) (core.Task, error) {
+	m, err := renderManifest(manifest, vars)
+	if err != nil {
+		return nil, err
+	}
+	return s.CreateTaskFromManifest(m)
+}
+
+// renderManifest resolves the variables of a manifest template
+func renderManifest(manifest []byte, vars map[string]string) ([]byte, error) {
+	data := map[string]string{}
+	for _, e := range os.Environ() {
+		if i := strings.Index(e, "="); i > 0 {
+			data[e[:i]] = e[i+1:]
+		}
+	}
+	for k, v := range vars {
+		data[k] = v
+	}
+	tmpl, err := template.New("manifest").Option("missingkey=error").Parse(string(manifest))
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,161 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/intelsdi-x/snap/core"
+)
+
+// promLabelEscaper escapes the label values of the Prometheus text format
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// promWriter writes the metrics of the Prometheus text format, keeping the
+// first error so that the callers need not check each write
+type promWriter struct {
+	w   io.Writer
+	err error
+}
+
+// header writes the help and type lines of a metric
+func (p *promWriter) header(name, typ, help string) {
+	p.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (p *promWriter) printf(format string, a ...interface{}) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, a...)
+	}
+}
+
+// WritePrometheus writes the counters of the tasks and of the work queues and
+// worker pools in the Prometheus text exposition format. The metrics of a task
+// are labelled with its name, or its id if it has none, and with its id.
+func (s *scheduler) WritePrometheus(w io.Writer) error {
+	p := &promWriter{w: w}
+	stats := s.Stats()
+
+	p.header("snap_worker_queue_depth", "gauge", "Number of jobs waiting in the work queues.")
+	p.printf("snap_worker_queue_depth %d\n", stats.QueuedJobs)
+	p.header("snap_worker_queue_size", "gauge", "Number of jobs each work queue holds.")
+	p.printf("snap_worker_queue_size %d\n", stats.QueueSize)
+	p.header("snap_worker_pool_size", "gauge", "Number of workers of each worker pool.")
+	p.printf("snap_worker_pool_size{pool=\"collect\"} %d\n", stats.CollectPoolSize)
+	p.printf("snap_worker_pool_size{pool=\"process\"} %d\n", stats.ProcessPoolSize)
+	p.printf("snap_worker_pool_size{pool=\"publish\"} %d\n", stats.PublishPoolSize)
+	p.header("snap_worker_active", "gauge", "Number of workers running a job.")
+	p.printf("snap_worker_active %d\n", stats.ActiveWorkers)
+	p.header("snap_worker_jobs_processed_total", "counter", "Number of jobs run by the workers.")
+	p.printf("snap_worker_jobs_processed_total %d\n", stats.ProcessedJobs)
+	p.header("snap_worker_jobs_dropped_total", "counter", "Number of jobs refused by the work queues.")
+	p.printf("snap_worker_jobs_dropped_total %d\n", stats.DroppedJobs)
+	p.header("snap_worker_jobs_panicked_total", "counter", "Number of jobs which panicked while running.")
+	p.printf("snap_worker_jobs_panicked_total %d\n", stats.PanickedJobs)
+	p.header("snap_worker_restarted_total", "counter", "Number of crashed workers which were replaced.")
+	p.printf("snap_worker_restarted_total %d\n", stats.RestartedWorkers)
+	p.header("snap_worker_retired_total", "counter", "Number of crashed workers which were not replaced.")
+	p.printf("snap_worker_retired_total %d\n", stats.RetiredWorkers)
+	p.header("snap_task_events_dropped_total", "counter", "Number of task events dropped for slow subscribers.")
+	p.printf("snap_task_events_dropped_total %d\n", stats.DroppedEvents)
+
+	table := s.tasks.Table()
+	states := map[string]int{}
+	for _, st := range core.TaskStateLookup {
+		states[st] = 0
+	}
+	for _, t := range table {
+		states[t.State().String()]++
+	}
+	stateNames := make([]string, 0, len(states))
+	for st := range states {
+		stateNames = append(stateNames, st)
+	}
+	sort.Strings(stateNames)
+	p.header("snap_tasks", "gauge", "Number of tasks in each state.")
+	for _, st := range stateNames {
+		p.printf("snap_tasks{state=\"%s\"} %d\n", st, states[st])
+	}
+
+	ids := make([]string, 0, len(table))
+	for id := range table {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	tasks := make([]*task, len(ids))
+	labels := make([]string, len(ids))
+	for i, id := range ids {
+		t := table[id]
+		name := t.GetName()
+		if name == "" {
+			name = t.ID()
+		}
+		tasks[i] = t
+		labels[i] = fmt.Sprintf(`{task="%s",id="%s"}`, promLabelEscaper.Replace(name), promLabelEscaper.Replace(t.ID()))
+	}
+
+	p.header("snap_task_hits_total", "counter", "Number of firings of the task.")
+	for i, t := range tasks {
+		p.printf("snap_task_hits_total%s %d\n", labels[i], t.HitCount())
+	}
+	p.header("snap_task_failures_total", "counter", "Number of failed firings of the task.")
+	for i, t := range tasks {
+		p.printf("snap_task_failures_total%s %d\n", labels[i], t.FailedCount())
+	}
+	p.header("snap_task_missed_total", "counter", "Number of intervals the task missed.")
+	for i, t := range tasks {
+		p.printf("snap_task_missed_total%s %d\n", labels[i], t.MissedCount())
+	}
+	p.header("snap_task_skipped_ticks_total", "counter", "Number of ticks the task skipped as its previous firings had not finished.")
+	for i, t := range tasks {
+		p.printf("snap_task_skipped_ticks_total%s %d\n", labels[i], t.SkippedCount())
+	}
+	p.header("snap_task_dropped_jobs_total", "counter", "Number of jobs of the task dropped as their deadline passed or their queue was full.")
+	for i, t := range tasks {
+		p.printf("snap_task_dropped_jobs_total%s %d\n", labels[i], t.DroppedCount())
+	}
+	p.header("snap_task_last_duration_seconds", "gauge", "Duration of the last finished firing of the task.")
+	for i, t := range tasks {
+		p.printf("snap_task_last_duration_seconds%s %g\n", labels[i], t.LastFireDuration().Seconds())
+	}
+	p.header("snap_task_job_latency_seconds", "summary", "Duration of the jobs of the task from their submission to the end of their last attempt.")
+	for i, t := range tasks {
+		stats := t.Stats()
+		for _, phase := range []struct {
+			name    string
+			latency core.LatencyStats
+		}{
+			{"collect", stats.Collect},
+			{"process", stats.Process},
+			{"publish", stats.Publish},
+		} {
+			l := strings.TrimSuffix(labels[i], "}") + `,phase="` + phase.name + `"`
+			p.printf("snap_task_job_latency_seconds%s,quantile=\"0.5\"} %g\n", l, phase.latency.P50.Seconds())
+			p.printf("snap_task_job_latency_seconds%s,quantile=\"0.95\"} %g\n", l, phase.latency.P95.Seconds())
+			p.printf("snap_task_job_latency_seconds%s,quantile=\"0.99\"} %g\n", l, phase.latency.P99.Seconds())
+			p.printf("snap_task_job_latency_seconds_sum%s} %g\n", l, phase.latency.Sum.Seconds())
+			p.printf("snap_task_job_latency_seconds_count%s} %d\n", l, phase.latency.Count)
+		}
+	}
+	return p.err
+}
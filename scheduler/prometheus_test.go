@@ -0,0 +1,95 @@
+// +build legacy
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/pkg/schedule"
+	"github.com/intelsdi-x/snap/scheduler/wmap"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestWritePrometheus(t *testing.T) {
+	log.SetLevel(log.FatalLevel)
+	Convey("WritePrometheus()", t, func() {
+		s := New(GetDefaultConfig(), WithPoolSize(2), WithQueueSize(5))
+		s.SetMetricManager(new(mockMetricManager))
+		So(s.Start(), ShouldBeNil)
+		w := wmap.NewWorkflowMap()
+		w.Collect.AddMetric("/foo/bar", 1)
+		tsk, te := s.CreateTask(schedule.NewWindowedSchedule(time.Second, nil, nil, 0), w, false, core.SetTaskName(`my "task"`))
+		So(te.Errors(), ShouldBeEmpty)
+		tk := tsk.(*task)
+		tk.hitCount = 3
+		tk.failedRuns = 1
+		tk.lastFireDuration = int64(time.Millisecond * 250)
+
+		var buf bytes.Buffer
+		So(s.WritePrometheus(&buf), ShouldBeNil)
+		out := buf.String()
+		Convey("writes the counters of the work queues and worker pools", func() {
+			So(out, ShouldContainSubstring, "# TYPE snap_worker_queue_depth gauge\nsnap_worker_queue_depth 0\n")
+			So(out, ShouldContainSubstring, "\nsnap_worker_pool_size{pool=\"collect\"} 2\n")
+			So(out, ShouldContainSubstring, "\nsnap_worker_pool_size{pool=\"process\"} 2\n")
+			So(out, ShouldContainSubstring, "\nsnap_worker_pool_size{pool=\"publish\"} 2\n")
+			So(out, ShouldContainSubstring, "\nsnap_worker_queue_size 5\n")
+		})
+		Convey("writes the counters of the tasks labelled with their name", func() {
+			labels := `{task="my \"task\"",id="` + tsk.ID() + `"}`
+			So(out, ShouldContainSubstring, "# TYPE snap_task_hits_total counter\nsnap_task_hits_total"+labels+" 3\n")
+			So(out, ShouldContainSubstring, "\nsnap_task_failures_total"+labels+" 1\n")
+			So(out, ShouldContainSubstring, "\nsnap_task_last_duration_seconds"+labels+" 0.25\n")
+		})
+		Convey("writes the number of tasks in each state", func() {
+			So(out, ShouldContainSubstring, "# TYPE snap_tasks gauge\n")
+			So(out, ShouldContainSubstring, "\nsnap_tasks{state=\"Stopped\"} 1\n")
+			So(out, ShouldContainSubstring, "\nsnap_tasks{state=\"Running\"} 0\n")
+		})
+		Convey("writes the job latencies of the tasks by phase", func() {
+			tk.latencies[collectJobType].observe(time.Second)
+			buf.Reset()
+			So(s.WritePrometheus(&buf), ShouldBeNil)
+			out := buf.String()
+			labels := `{task="my \"task\"",id="` + tsk.ID() + `",phase="collect"`
+			So(out, ShouldContainSubstring, "# TYPE snap_task_job_latency_seconds summary\n")
+			So(out, ShouldContainSubstring, "\nsnap_task_job_latency_seconds"+labels+`,quantile="0.99"} 1`+"\n")
+			So(out, ShouldContainSubstring, "\nsnap_task_job_latency_seconds_count"+labels+"} 1\n")
+			So(out, ShouldContainSubstring, "\nsnap_task_job_latency_seconds_sum"+labels+"} 1\n")
+		})
+		Convey("returns the error of the writer", func() {
+			So(s.WritePrometheus(failingWriter{}), ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,110 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/intelsdi-x/snap/core"
+)
+
+// publishBatch buffers the metrics handed to a publish node over several
+// firings, so that they are published in fewer, larger publish jobs
+type publishBatch struct {
+	// size is the number of metrics making a full batch, 0 if unbounded
+	size int
+	// maxAge is how long the oldest metrics of a batch are held, 0 if
+	// they are held until the batch is full
+	maxAge time.Duration
+
+	mutex sync.Mutex
+	// opened is the time the first metrics were added to the batch, zero
+	// while the batch is empty
+	opened  time.Time
+	metrics []core.Metric
+	// flushed counts the batches returned by add and flush
+	flushed uint64
+}
+
+// newPublishBatch returns the batch of a publish node, nil if the node
+// publishes the metrics of every firing right away
+func newPublishBatch(size int, maxAge string) (*publishBatch, error) {
+	if size < 0 {
+		return nil, ErrInvalidPublishBatch
+	}
+	b := &publishBatch{size: size}
+	if maxAge != "" {
+		var err error
+		if b.maxAge, err = time.ParseDuration(maxAge); err != nil || b.maxAge < 0 {
+			return nil, ErrInvalidPublishBatch
+		}
+	}
+	if b.size == 0 && b.maxAge == 0 {
+		return nil, nil
+	}
+	return b, nil
+}
+
+// add appends the metrics handed to the node at the given time to the batch.
+// Once the batch holds size metrics, or its oldest metrics were added maxAge
+// ago, its metrics are returned along with true and the batch is emptied.
+func (b *publishBatch) add(at time.Time, mts []core.Metric) ([]core.Metric, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if len(mts) == 0 && b.opened.IsZero() {
+		return nil, false
+	}
+	if b.opened.IsZero() {
+		b.opened = at
+	}
+	b.metrics = append(b.metrics, mts...)
+	if (b.size > 0 && len(b.metrics) >= b.size) || (b.maxAge > 0 && at.Sub(b.opened) >= b.maxAge) {
+		return b.take(), true
+	}
+	return nil, false
+}
+
+// flush returns the metrics of the batch and empties it, whether or not the
+// batch is full. It returns false when the batch is empty.
+func (b *publishBatch) flush() ([]core.Metric, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.opened.IsZero() {
+		return nil, false
+	}
+	return b.take(), true
+}
+
+// take empties the batch and returns its metrics, the lock must be held
+func (b *publishBatch) take() []core.Metric {
+	mts := b.metrics
+	b.opened, b.metrics = time.Time{}, nil
+	b.flushed++
+	return mts
+}
+
+// stats returns the number of metrics held by the batch and the number of
+// batches flushed so far
+func (b *publishBatch) stats() (uint, uint64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return uint(len(b.metrics)), b.flushed
+}
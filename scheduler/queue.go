@@ -21,6 +21,7 @@ package scheduler
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 )
 
@@ -31,16 +32,85 @@ var (
 
 type jobHandler func(queuedJob)
 
+// QueueFullPolicy determines what happens to a job submitted to a full work queue
+type QueueFullPolicy int
+
+const (
+	// PolicyDropNewest refuses the submitted job, unless the newest queued job
+	// of the lowest priority has a lower priority than the submitted job and
+	// is evicted to make room for it. This is the default policy.
+	PolicyDropNewest QueueFullPolicy = iota
+	// PolicyDropOldest evicts the oldest queued job of the lowest priority to
+	// make room for the submitted job. A submitted job with a lower priority
+	// than every queued job is refused.
+	PolicyDropOldest
+	// PolicyBlock blocks the submitter until there is room in the queue
+	PolicyBlock
+)
+
+var queueFullPolicies = map[string]QueueFullPolicy{
+	"drop-newest": PolicyDropNewest,
+	"drop-oldest": PolicyDropOldest,
+	"block":       PolicyBlock,
+}
+
+// ParseQueueFullPolicy returns the policy of the given name, which is one of
+// "drop-newest", "drop-oldest" or "block".
+func ParseQueueFullPolicy(name string) (QueueFullPolicy, error) {
+	if p, ok := queueFullPolicies[name]; ok {
+		return p, nil
+	}
+	return PolicyDropNewest, fmt.Errorf("unknown queue full policy '%s'", name)
+}
+
+func (p QueueFullPolicy) String() string {
+	for name, v := range queueFullPolicies {
+		if v == p {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+// queue holds the jobs of each task in a dedicated lane of up to taskLimit
+// jobs, within the limit of the whole queue. The jobs are popped from the
+// lanes in turn, the jobs of a higher priority first, so that the workers
+// taking the popped jobs are not tied to a task: a worker left idle by the
+// tasks with no job queued works the jobs of the busy ones, without a task
+// firing often monopolizing the queue and starving the other tasks.
 type queue struct {
 	Event chan queuedJob
 	Err   chan *queuingError
 
 	handler jobHandler
 	limit   uint
-	kill    chan struct{}
-	items   []queuedJob
-	mutex   *sync.Mutex
-	status  queueStatus
+	// taskLimit is the number of jobs queued for each task, the queue limit
+	// alone applies if 0
+	taskLimit uint
+	kill      chan struct{}
+	// lanes holds the jobs queued for each task, and order the tasks with
+	// jobs queued in the order their lanes are popped in
+	lanes  map[string]*lane
+	order  []string
+	count  int
+	seq    uint64
+	mutex  *sync.Mutex
+	status queueStatus
+	policy QueueFullPolicy
+	// freed is signaled when a job is popped, to resume a blocked queue
+	freed chan struct{}
+	// held holds the jobs submitted to the full queue under the PolicyBlock
+	// of their task while the queue itself does not block, they are queued
+	// in order as jobs are popped. Up to the limit of the queue are held,
+	// the other jobs are refused.
+	held []queuedJob
+}
+
+// lane holds the jobs queued for a task ordered by priority, each numbered in
+// the order it was queued
+type lane struct {
+	jobs []queuedJob
+	seqs []uint64
 }
 
 type queueStatus int
@@ -68,9 +138,10 @@ func newQueue(limit uint, handler jobHandler) *queue {
 		handler: handler,
 		limit:   limit,
 		kill:    make(chan struct{}),
-		items:   []queuedJob{},
+		lanes:   map[string]*lane{},
 		mutex:   &sync.Mutex{},
 		status:  queueStopped,
+		freed:   make(chan struct{}, 1),
 	}
 }
 
@@ -99,25 +170,39 @@ func (q *queue) Stop() {
 	}
 }
 
+// Len returns the number of jobs waiting in the queue, including the jobs
+// held until there is room in the queue
+func (q *queue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.length() + len(q.held)
+}
+
 /*
    Below is the private, internal functionality of the queue.
    These functions are not thread-safe, and should not be used
    outside the queue itself.  The only interaction between a queue
    and outside consumers should be through the Event chan, the
-   Err chan, Start(), or Stop().
+   Err chan, Start(), Stop(), or Len().
 */
 
 func (q *queue) start() {
 	for {
+		events := q.Event
+		if q.policy == PolicyBlock && q.full() {
+			// stop receiving jobs until one is popped, which blocks the submitters
+			events = nil
+		}
 		select {
-		case e := <-q.Event:
-			if err := q.push(e); err != nil {
-				qe := &queuingError{
-					Err: err,
-					Job: e.Job(),
-				}
-				q.Err <- qe
-				e.Promise().Complete([]error{qe}) // Signal job termination.
+		case <-q.freed:
+			continue
+		case e := <-events:
+			evicted, err := q.push(e)
+			if evicted != nil {
+				q.reject(evicted, errLimitExceeded)
+			}
+			if err != nil {
+				q.reject(e, err)
 				continue
 			}
 
@@ -143,6 +228,16 @@ func (q *queue) start() {
 
 }
 
+// reject reports the queuing error of a job and signals its termination
+func (q *queue) reject(j queuedJob, err error) {
+	qe := &queuingError{
+		Err: err,
+		Job: j.Job(),
+	}
+	q.Err <- qe
+	j.Promise().Complete([]error{qe}) // Signal job termination.
+}
+
 func (q *queue) handle() {
 	for {
 		item, err := q.pop()
@@ -157,21 +252,154 @@ func (q *queue) handle() {
 }
 
 func (q *queue) length() int {
-	return len(q.items)
+	return q.count
 }
 
-func (q *queue) push(j queuedJob) error {
+func (q *queue) full() bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.limit != 0 && uint(q.length()) >= q.limit
+}
+
+// fits returns whether the job can be queued without exceeding the limit of
+// the queue or of the lane of its task
+func (q *queue) fits(j queuedJob) bool {
+	if q.limit != 0 && uint(q.length())+1 > q.limit {
+		return false
+	}
+	l := q.lanes[j.Job().TaskID()]
+	return q.taskLimit == 0 || l == nil || uint(len(l.jobs)) < q.taskLimit
+}
+
+// push adds a job to the lane of its task behind the queued jobs of the same
+// or a higher priority. If the queue or the lane is full, a queued job of the
+// lowest priority of the queue, or of the lane if it is full, is evicted and
+// returned: the newest one if its priority is lower than the priority of the
+// job, or under PolicyDropOldest the oldest one unless its priority is
+// higher. The job is refused otherwise. The policy of the task of the job, if
+// it has one, applies in place of the policy of the queue unless the queue
+// blocks its submitters.
+func (q *queue) push(j queuedJob) (queuedJob, error) {
 
 	q.mutex.Lock()
 	defer q.mutex.Unlock()
 
-	if q.limit == 0 || uint(q.length())+1 <= q.limit {
-		q.items = append(q.items, j)
-		return nil
+	policy := q.policy
+	if p, ok := j.Job().QueueFullPolicy(); ok && q.policy != PolicyBlock {
+		policy = p
 	}
-	return errLimitExceeded
+	var evicted queuedJob
+	if !q.fits(j) {
+		if policy == PolicyBlock {
+			if uint(len(q.held)) >= q.holdLimit() {
+				return nil, errLimitExceeded
+			}
+			q.held = append(q.held, j)
+			return nil, nil
+		}
+		if q.length() == 0 {
+			return nil, errLimitExceeded
+		}
+		lanes := q.order
+		if l := q.lanes[j.Job().TaskID()]; l != nil && q.taskLimit != 0 && uint(len(l.jobs)) >= q.taskLimit {
+			lanes = []string{j.Job().TaskID()}
+		}
+		id, at := q.victim(lanes, policy != PolicyDropOldest)
+		evicted = q.lanes[id].jobs[at]
+		lowest := evicted.Job().Priority()
+		if j.Job().Priority() < lowest || (policy != PolicyDropOldest && j.Job().Priority() == lowest) {
+			return nil, errLimitExceeded
+		}
+		q.remove(id, at)
+	}
+
+	q.insert(j)
+	return evicted, nil
+}
+
+// holdLimit returns the number of jobs held at most, the limit of the queue,
+// or the limit of a lane for a queue bounding the lanes alone
+func (q *queue) holdLimit() uint {
+	if q.limit != 0 {
+		return q.limit
+	}
+	return q.taskLimit
+}
+
+// victim returns the lane and the index in the lane of the job of the lowest
+// priority of the given lanes queued first, or last if newest is set
+func (q *queue) victim(lanes []string, newest bool) (string, int) {
+	var (
+		victim string
+		at     = -1
+	)
+	for _, id := range lanes {
+		l := q.lanes[id]
+		// the jobs of the lowest priority are at the back of their lane
+		i := len(l.jobs) - 1
+		p := l.jobs[i].Job().Priority()
+		for !newest && i > 0 && l.jobs[i-1].Job().Priority() == p {
+			i--
+		}
+		if at >= 0 {
+			v := q.lanes[victim]
+			lowest := v.jobs[at].Job().Priority()
+			if p > lowest || (p == lowest && (l.seqs[i] > v.seqs[at]) != newest) {
+				continue
+			}
+		}
+		victim, at = id, i
+	}
+	return victim, at
 }
 
+// insert queues the job in the lane of its task after the last job of the
+// same or a higher priority
+func (q *queue) insert(j queuedJob) {
+	id := j.Job().TaskID()
+	l := q.lanes[id]
+	if l == nil {
+		l = &lane{}
+		q.lanes[id] = l
+		q.order = append(q.order, id)
+	}
+	i := len(l.jobs)
+	for i > 0 && l.jobs[i-1].Job().Priority() < j.Job().Priority() {
+		i--
+	}
+	l.jobs = append(l.jobs, nil)
+	copy(l.jobs[i+1:], l.jobs[i:])
+	l.jobs[i] = j
+	l.seqs = append(l.seqs, 0)
+	copy(l.seqs[i+1:], l.seqs[i:])
+	l.seqs[i] = q.seq
+	q.seq++
+	q.count++
+}
+
+// remove removes the job at the given index of the lane of a task, the lane
+// is dropped once empty
+func (q *queue) remove(id string, i int) queuedJob {
+	l := q.lanes[id]
+	j := l.jobs[i]
+	l.jobs = append(l.jobs[:i], l.jobs[i+1:]...)
+	l.seqs = append(l.seqs[:i], l.seqs[i+1:]...)
+	q.count--
+	if len(l.jobs) == 0 {
+		delete(q.lanes, id)
+		for k, o := range q.order {
+			if o == id {
+				q.order = append(q.order[:k], q.order[k+1:]...)
+				break
+			}
+		}
+	}
+	return j
+}
+
+// pop returns the first job of the first lane in turn among the lanes whose
+// first job has the highest priority. The lane popped goes behind the others
+// so that the tasks of the same priority have their jobs worked in turn.
 func (q *queue) pop() (queuedJob, error) {
 
 	q.mutex.Lock()
@@ -183,8 +411,29 @@ func (q *queue) pop() (queuedJob, error) {
 		return j, errQueueEmpty
 	}
 
-	j = q.items[0]
-	q.items = q.items[1:]
+	next := 0
+	for k := 1; k < len(q.order); k++ {
+		if q.lanes[q.order[k]].jobs[0].Job().Priority() > q.lanes[q.order[next]].jobs[0].Job().Priority() {
+			next = k
+		}
+	}
+	id := q.order[next]
+	j = q.remove(id, 0)
+	if _, ok := q.lanes[id]; ok {
+		q.order = append(append(q.order[:next], q.order[next+1:]...), id)
+	}
+	// the popped job makes room for the job held the longest which fits
+	for k, h := range q.held {
+		if q.fits(h) {
+			q.insert(h)
+			q.held = append(q.held[:k], q.held[k+1:]...)
+			break
+		}
+	}
 
+	select {
+	case q.freed <- struct{}{}:
+	default:
+	}
 	return j, nil
 }
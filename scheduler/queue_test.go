@@ -84,7 +84,7 @@ func TestQueue(t *testing.T) {
 		q := newQueue(3, func(queuedJob) { time.Sleep(1 * time.Second) })
 		q.Start()
 		for i := 0; i < 5; i++ {
-			q.Event <- newQueuedJob(&collectorJob{})
+			q.Event <- newQueuedJob(&collectorJob{coreJob: &coreJob{}})
 		}
 		err := <-q.Err
 		So(err, ShouldNotBeNil)
@@ -92,6 +92,318 @@ func TestQueue(t *testing.T) {
 		q.Stop()
 	})
 
+	Convey("it evicts the oldest job under PolicyDropOldest", t, func() {
+		release := make(chan struct{})
+		q := newQueue(2, func(j queuedJob) {
+			<-release
+			j.Promise().Complete([]error{})
+		})
+		q.policy = PolicyDropOldest
+		q.Start()
+		qjs := make([]queuedJob, 4)
+		for i := range qjs {
+			qjs[i] = newQueuedJob(&collectorJob{coreJob: &coreJob{}})
+		}
+		// the first job is handled and blocks, the next two fill the queue
+		q.Event <- qjs[0]
+		time.Sleep(10 * time.Millisecond)
+		q.Event <- qjs[1]
+		q.Event <- qjs[2]
+		go func() { q.Event <- qjs[3] }()
+		err := <-q.Err
+		So(err.Err, ShouldResemble, errLimitExceeded)
+		So(qjs[1].Promise().Await(), ShouldNotBeEmpty)
+		close(release)
+		So(qjs[0].Promise().Await(), ShouldBeEmpty)
+		So(qjs[2].Promise().Await(), ShouldBeEmpty)
+		So(qjs[3].Promise().Await(), ShouldBeEmpty)
+		q.Stop()
+	})
+
+	Convey("it works the jobs of a higher priority first", t, func() {
+		release := make(chan struct{})
+		worked := []string{}
+		q := newQueue(5, func(j queuedJob) {
+			<-release
+			worked = append(worked, j.Job().Name())
+			j.Promise().Complete([]error{})
+		})
+		q.Start()
+		jobs := []struct {
+			name     string
+			priority int
+		}{{"first", 0}, {"low-1", 0}, {"high-1", 5}, {"low-2", 0}, {"high-2", 5}, {"mid", 1}}
+		qjs := make([]queuedJob, len(jobs))
+		for i, j := range jobs {
+			cj := &collectorJob{coreJob: newCoreJob(collectJobType, time.Now().Add(time.Minute), "", j.name, 0)}
+			cj.SetPriority(j.priority)
+			qjs[i] = newQueuedJob(cj)
+			q.Event <- qjs[i]
+			if i == 0 {
+				// the first job is handled and blocks while the others queue up
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+		for q.Len() < len(jobs)-1 {
+			time.Sleep(time.Millisecond)
+		}
+		close(release)
+		for _, qj := range qjs {
+			qj.Promise().Await()
+		}
+		So(worked, ShouldResemble, []string{"first", "high-1", "high-2", "mid", "low-1", "low-2"})
+		q.Stop()
+	})
+
+	Convey("it evicts the oldest job of the lowest priority under PolicyDropOldest", t, func() {
+		release := make(chan struct{})
+		q := newQueue(2, func(j queuedJob) {
+			<-release
+			j.Promise().Complete([]error{})
+		})
+		q.policy = PolicyDropOldest
+		q.Start()
+		qjs := make([]queuedJob, 5)
+		for i, p := range []int{0, 1, 0, 2, -1} {
+			cj := &collectorJob{coreJob: &coreJob{}}
+			cj.SetPriority(p)
+			qjs[i] = newQueuedJob(cj)
+		}
+		// the first job is handled and blocks, the next two fill the queue
+		q.Event <- qjs[0]
+		time.Sleep(10 * time.Millisecond)
+		q.Event <- qjs[1]
+		q.Event <- qjs[2]
+		// the high priority job evicts the low priority one
+		go func() { q.Event <- qjs[3] }()
+		err := <-q.Err
+		So(err.Err, ShouldResemble, errLimitExceeded)
+		So(qjs[2].Promise().Await(), ShouldNotBeEmpty)
+		// a job of a lower priority than every queued job is refused
+		go func() { q.Event <- qjs[4] }()
+		err = <-q.Err
+		So(err.Job, ShouldEqual, qjs[4].Job())
+		close(release)
+		So(qjs[0].Promise().Await(), ShouldBeEmpty)
+		So(qjs[1].Promise().Await(), ShouldBeEmpty)
+		So(qjs[3].Promise().Await(), ShouldBeEmpty)
+		q.Stop()
+	})
+
+	Convey("it evicts the newest job of a lower priority under PolicyDropNewest", t, func() {
+		release := make(chan struct{})
+		q := newQueue(2, func(j queuedJob) {
+			<-release
+			j.Promise().Complete([]error{})
+		})
+		q.Start()
+		qjs := make([]queuedJob, 5)
+		for i, p := range []int{0, -10, -10, 10, 10} {
+			cj := &collectorJob{coreJob: &coreJob{}}
+			cj.SetPriority(p)
+			qjs[i] = newQueuedJob(cj)
+		}
+		// the first job is handled and blocks, the next two fill the queue
+		q.Event <- qjs[0]
+		time.Sleep(10 * time.Millisecond)
+		q.Event <- qjs[1]
+		q.Event <- qjs[2]
+		// the high priority job evicts the newest low priority one
+		go func() { q.Event <- qjs[3] }()
+		err := <-q.Err
+		So(err.Job, ShouldEqual, qjs[2].Job())
+		// and the next one the last low priority one
+		go func() { q.Event <- qjs[4] }()
+		err = <-q.Err
+		So(err.Job, ShouldEqual, qjs[1].Job())
+		// a job of the same priority as every queued job is refused
+		refused := newQueuedJob(&collectorJob{coreJob: &coreJob{}})
+		refused.Job().SetPriority(10)
+		go func() { q.Event <- refused }()
+		err = <-q.Err
+		So(err.Job, ShouldEqual, refused.Job())
+		close(release)
+		So(qjs[0].Promise().Await(), ShouldBeEmpty)
+		So(qjs[3].Promise().Await(), ShouldBeEmpty)
+		So(qjs[4].Promise().Await(), ShouldBeEmpty)
+		q.Stop()
+	})
+
+	Convey("it blocks the submitter under PolicyBlock", t, func() {
+		release := make(chan struct{})
+		q := newQueue(1, func(j queuedJob) {
+			<-release
+			j.Promise().Complete([]error{})
+		})
+		q.policy = PolicyBlock
+		q.Start()
+		qjs := make([]queuedJob, 3)
+		for i := range qjs {
+			qjs[i] = newQueuedJob(&collectorJob{coreJob: &coreJob{}})
+		}
+		q.Event <- qjs[0]
+		time.Sleep(10 * time.Millisecond)
+		q.Event <- qjs[1]
+		submitted := make(chan struct{})
+		go func() {
+			q.Event <- qjs[2]
+			close(submitted)
+		}()
+		select {
+		case <-submitted:
+			t.Fatal("the submitter of a job to a full queue should block")
+		case <-time.After(50 * time.Millisecond):
+		}
+		close(release)
+		<-submitted
+		for _, qj := range qjs {
+			So(qj.Promise().Await(), ShouldBeEmpty)
+		}
+		q.Stop()
+	})
+
+	Convey("it applies the policy of the task of a job in place of its own", t, func() {
+		release := make(chan struct{})
+		q := newQueue(1, func(j queuedJob) {
+			<-release
+			j.Promise().Complete([]error{})
+		})
+		q.Start()
+		qjs := make([]queuedJob, 4)
+		for i := range qjs {
+			qjs[i] = newQueuedJob(&collectorJob{coreJob: &coreJob{}})
+		}
+		qjs[2].Job().SetQueueFullPolicy(PolicyBlock)
+		qjs[3].Job().SetQueueFullPolicy(PolicyDropOldest)
+		// the first job is handled and blocks, the second fills the queue
+		q.Event <- qjs[0]
+		time.Sleep(10 * time.Millisecond)
+		q.Event <- qjs[1]
+		// the job of a blocking task is held until there is room
+		q.Event <- qjs[2]
+		for q.Len() < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		// the job of a task dropping the oldest job evicts the queued one
+		go func() { q.Event <- qjs[3] }()
+		err := <-q.Err
+		So(err.Job, ShouldEqual, qjs[1].Job())
+		close(release)
+		So(qjs[0].Promise().Await(), ShouldBeEmpty)
+		So(qjs[2].Promise().Await(), ShouldBeEmpty)
+		So(qjs[3].Promise().Await(), ShouldBeEmpty)
+		q.Stop()
+	})
+
+	Convey("it holds up to its limit of jobs of blocking tasks", t, func() {
+		release := make(chan struct{})
+		q := newQueue(1, func(j queuedJob) {
+			<-release
+			j.Promise().Complete([]error{})
+		})
+		q.Start()
+		qjs := make([]queuedJob, 4)
+		for i := range qjs {
+			qjs[i] = newQueuedJob(&collectorJob{coreJob: &coreJob{}})
+			qjs[i].Job().SetQueueFullPolicy(PolicyBlock)
+		}
+		// the first job is handled and blocks, the second fills the queue
+		// and the third is held
+		q.Event <- qjs[0]
+		time.Sleep(10 * time.Millisecond)
+		q.Event <- qjs[1]
+		q.Event <- qjs[2]
+		// the job submitted while as many jobs as the queue holds are held
+		// is refused
+		go func() { q.Event <- qjs[3] }()
+		err := <-q.Err
+		So(err.Job, ShouldEqual, qjs[3].Job())
+		So(err.Err, ShouldEqual, errLimitExceeded)
+		So(q.Len(), ShouldEqual, 2)
+		close(release)
+		for _, qj := range qjs[:3] {
+			So(qj.Promise().Await(), ShouldBeEmpty)
+		}
+		q.Stop()
+	})
+
+	Convey("it works the jobs of the tasks in turn", t, func() {
+		release := make(chan struct{})
+		worked := []string{}
+		q := newQueue(10, func(j queuedJob) {
+			<-release
+			worked = append(worked, j.Job().Name())
+			j.Promise().Complete([]error{})
+		})
+		q.Start()
+		jobs := []struct {
+			task, name string
+			priority   int
+		}{{"noisy", "first", 0}, {"noisy", "noisy-1", 0}, {"noisy", "noisy-2", 0}, {"noisy", "noisy-3", 0},
+			{"quiet", "quiet-1", 0}, {"other", "other-1", 0}, {"urgent", "urgent-1", 1}, {"quiet", "quiet-2", 0}}
+		qjs := make([]queuedJob, len(jobs))
+		for i, j := range jobs {
+			cj := &collectorJob{coreJob: newCoreJob(collectJobType, time.Now().Add(time.Minute), j.task, j.name, 0)}
+			cj.SetPriority(j.priority)
+			qjs[i] = newQueuedJob(cj)
+			q.Event <- qjs[i]
+			if i == 0 {
+				// the first job is handled and blocks while the others queue up
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+		for q.Len() < len(jobs)-1 {
+			time.Sleep(time.Millisecond)
+		}
+		close(release)
+		for _, qj := range qjs {
+			qj.Promise().Await()
+		}
+		So(worked, ShouldResemble, []string{"first", "urgent-1", "noisy-1", "quiet-1", "other-1", "noisy-2", "quiet-2", "noisy-3"})
+		q.Stop()
+	})
+
+	Convey("it bounds the jobs queued for each task", t, func() {
+		release := make(chan struct{})
+		q := newQueue(10, func(j queuedJob) {
+			<-release
+			j.Promise().Complete([]error{})
+		})
+		q.taskLimit = 2
+		q.Start()
+		newJob := func(task string) queuedJob {
+			return newQueuedJob(&collectorJob{coreJob: newCoreJob(collectJobType, time.Now().Add(time.Minute), task, "", 0)})
+		}
+		qjs := []queuedJob{newJob("noisy"), newJob("noisy"), newJob("noisy"), newJob("noisy"), newJob("quiet")}
+		// the first job is handled and blocks, the next two fill the lane of the task
+		q.Event <- qjs[0]
+		time.Sleep(10 * time.Millisecond)
+		q.Event <- qjs[1]
+		q.Event <- qjs[2]
+		go func() { q.Event <- qjs[3] }()
+		err := <-q.Err
+		So(err.Job, ShouldEqual, qjs[3].Job())
+		// a job of another task is queued meanwhile
+		q.Event <- qjs[4]
+		for i := 0; i < 100 && q.Len() < 3; i++ {
+			time.Sleep(time.Millisecond)
+		}
+		So(q.Len(), ShouldEqual, 3)
+
+		Convey("evicting the oldest job of the task under PolicyDropOldest", func() {
+			evicting := newJob("noisy")
+			evicting.Job().SetQueueFullPolicy(PolicyDropOldest)
+			go func() { q.Event <- evicting }()
+			err := <-q.Err
+			So(err.Job, ShouldEqual, qjs[1].Job())
+			close(release)
+			for _, qj := range []queuedJob{qjs[0], qjs[2], qjs[4], evicting} {
+				So(qj.Promise().Await(), ShouldBeEmpty)
+			}
+			q.Stop()
+		})
+	})
+
 	Convey("stop closes the queue", t, func() {
 		q := newQueue(3, func(queuedJob) { time.Sleep(1 * time.Second) })
 		q.Start()
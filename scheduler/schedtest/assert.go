@@ -0,0 +1,101 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedtest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/core"
+)
+
+// pollInterval is the wall time between two checks of a condition waited for
+const pollInterval = 5 * time.Millisecond
+
+// waitFor polls cond until it holds or timeout elapses, in wall time, as the
+// clock of the schedules may be a manual one
+func waitFor(cond func() bool, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(pollInterval)
+	}
+	return true
+}
+
+// WaitForHits waits up to timeout for the task to have fired at least n
+// times. It returns an error with the number of firings otherwise.
+func WaitForHits(t core.Task, n uint, timeout time.Duration) error {
+	if !waitFor(func() bool { return t.HitCount() >= n }, timeout) {
+		return fmt.Errorf("task %s fired %d times after %v, expected %d", t.ID(), t.HitCount(), timeout, n)
+	}
+	return nil
+}
+
+// WaitForState waits up to timeout for the task to be in the state. It
+// returns an error with the state of the task otherwise.
+func WaitForState(t core.Task, state core.TaskState, timeout time.Duration) error {
+	if !waitFor(func() bool { return t.State() == state }, timeout) {
+		return fmt.Errorf("task %s %s after %v, expected %s", t.ID(), t.State(), timeout, state)
+	}
+	return nil
+}
+
+// WaitForSubscription waits up to timeout for the task to be subscribed to
+// its plugins, or unsubscribed from them when subscribed is false
+func WaitForSubscription(m *MetricManager, taskID string, subscribed bool, timeout time.Duration) error {
+	if !waitFor(func() bool { return m.Subscribed(taskID) == subscribed }, timeout) {
+		if subscribed {
+			return fmt.Errorf("task %s not subscribed to its plugins after %v", taskID, timeout)
+		}
+		return fmt.Errorf("task %s still subscribed to its plugins after %v", taskID, timeout)
+	}
+	return nil
+}
+
+// AssertHits fails the test unless the task fires n times within timeout,
+// and no more
+func AssertHits(tb testing.TB, t core.Task, n uint, timeout time.Duration) {
+	if err := WaitForHits(t, n, timeout); err != nil {
+		tb.Fatal(err)
+	}
+	if hits := t.HitCount(); hits != n {
+		tb.Fatalf("task %s fired %d times, expected %d", t.ID(), hits, n)
+	}
+}
+
+// AssertSubscribed fails the test unless the task is subscribed to its
+// plugins within timeout
+func AssertSubscribed(tb testing.TB, m *MetricManager, taskID string, timeout time.Duration) {
+	if err := WaitForSubscription(m, taskID, true, timeout); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// AssertUnsubscribed fails the test unless the task is unsubscribed from its
+// plugins within timeout
+func AssertUnsubscribed(tb testing.TB, m *MetricManager, taskID string, timeout time.Duration) {
+	if err := WaitForSubscription(m, taskID, false, timeout); err != nil {
+		tb.Fatal(err)
+	}
+}
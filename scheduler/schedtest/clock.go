@@ -0,0 +1,66 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedtest
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/intelsdi-x/snap/pkg/schedule"
+)
+
+// Clock is a manual clock the schedules of the tasks wait on, so that a test
+// fires them by advancing it rather than by waiting for their intervals. It
+// replaces the clock of the schedules until it is closed.
+type Clock struct {
+	*schedule.ManualClock
+}
+
+// NewClock returns a Clock set to t, set as the clock of the schedules
+func NewClock(t time.Time) *Clock {
+	c := &Clock{ManualClock: schedule.NewManualClock(t)}
+	schedule.SetClock(c.ManualClock)
+	return c
+}
+
+// Close restores the wall clock of the schedules
+func (c *Clock) Close() {
+	schedule.SetClock(nil)
+}
+
+// WaitForWaiters waits up to timeout, in wall time, for n schedules to be
+// waiting on the clock. It returns an error with the number of waiters
+// otherwise.
+func (c *Clock) WaitForWaiters(n int, timeout time.Duration) error {
+	if !waitFor(func() bool { return c.Waiters() >= n }, timeout) {
+		return fmt.Errorf("%d schedules waiting on the clock after %v, expected %d", c.Waiters(), timeout, n)
+	}
+	return nil
+}
+
+// Tick waits up to timeout for n schedules to be waiting on the clock, then
+// advances it by d
+func (c *Clock) Tick(n int, d, timeout time.Duration) error {
+	if err := c.WaitForWaiters(n, timeout); err != nil {
+		return err
+	}
+	c.Advance(d)
+	return nil
+}
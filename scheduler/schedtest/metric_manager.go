@@ -0,0 +1,199 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package schedtest provides an in-memory metric manager, a manual clock and
+// assertions for the applications embedding the scheduler to test their
+// tasks deterministically, without loading plugins in control.
+package schedtest
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/cdata"
+	"github.com/intelsdi-x/snap/core/ctypes"
+	"github.com/intelsdi-x/snap/core/serror"
+)
+
+// ErrStreamingNotSupported - The error message for a streaming task run with the fake metric manager
+var ErrStreamingNotSupported = errors.New("Streaming is not supported by the fake metric manager")
+
+// MetricManager is an in-memory metric manager which is given to the
+// scheduler with SetMetricManager in place of control. Its collections return
+// the metrics set with SetMetrics, and it records the subscriptions of the
+// tasks and the metrics they processed and published.
+type MetricManager struct {
+	mutex      sync.Mutex
+	metrics    []core.Metric
+	collectErr error
+	validation []serror.SnapError
+	// subscribed holds the tasks subscribed to their plugins
+	subscribed    map[string]bool
+	subscriptions map[string]int
+	collections   map[string]int
+	processed     map[string][]core.Metric
+	published     map[string][]core.Metric
+}
+
+// NewMetricManager returns a MetricManager collecting no metrics
+func NewMetricManager() *MetricManager {
+	return &MetricManager{
+		subscribed:    map[string]bool{},
+		subscriptions: map[string]int{},
+		collections:   map[string]int{},
+		processed:     map[string][]core.Metric{},
+		published:     map[string][]core.Metric{},
+	}
+}
+
+// SetMetrics sets the metrics returned by the collections
+func (m *MetricManager) SetMetrics(mts ...core.Metric) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.metrics = mts
+}
+
+// FailCollections makes the collections fail on err, nil making them
+// succeed again
+func (m *MetricManager) FailCollections(err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.collectErr = err
+}
+
+// FailValidation makes the validation of the tasks created afterwards fail
+// on errs, none making it succeed again
+func (m *MetricManager) FailValidation(errs ...serror.SnapError) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.validation = errs
+}
+
+// Subscribed returns whether the task is subscribed to its plugins
+func (m *MetricManager) Subscribed(taskID string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.subscribed[taskID]
+}
+
+// SubscribedTasks returns the IDs of the tasks subscribed to their plugins,
+// sorted
+func (m *MetricManager) SubscribedTasks() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	ids := make([]string, 0, len(m.subscribed))
+	for id := range m.subscribed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Subscriptions returns the number of times the task subscribed to its
+// plugins
+func (m *MetricManager) Subscriptions(taskID string) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.subscriptions[taskID]
+}
+
+// Collections returns the number of collections of the task
+func (m *MetricManager) Collections(taskID string) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.collections[taskID]
+}
+
+// Processed returns the metrics processed for the task, in order
+func (m *MetricManager) Processed(taskID string) []core.Metric {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]core.Metric(nil), m.processed[taskID]...)
+}
+
+// Published returns the metrics published for the task, in order
+func (m *MetricManager) Published(taskID string) []core.Metric {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]core.Metric(nil), m.published[taskID]...)
+}
+
+// CollectMetrics returns the metrics set with SetMetrics
+func (m *MetricManager) CollectMetrics(taskID string, _ map[string]map[string]string) ([]core.Metric, []error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.collections[taskID]++
+	if m.collectErr != nil {
+		return nil, []error{m.collectErr}
+	}
+	return append([]core.Metric(nil), m.metrics...), nil
+}
+
+// StreamMetrics fails, the streaming tasks cannot be run
+func (m *MetricManager) StreamMetrics(string, map[string]map[string]string, time.Duration, int64) (chan []core.Metric, chan error, []error) {
+	return nil, nil, []error{ErrStreamingNotSupported}
+}
+
+// ProcessMetrics records the metrics and returns them unchanged
+func (m *MetricManager) ProcessMetrics(mts []core.Metric, _ map[string]ctypes.ConfigValue, taskID, _ string, _ int) ([]core.Metric, []error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.processed[taskID] = append(m.processed[taskID], mts...)
+	return mts, nil
+}
+
+// PublishMetrics records the metrics
+func (m *MetricManager) PublishMetrics(mts []core.Metric, _ map[string]ctypes.ConfigValue, taskID, _ string, _ int) []error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.published[taskID] = append(m.published[taskID], mts...)
+	return nil
+}
+
+// GetAutodiscoverPaths returns no path, no task being autodiscovered
+func (m *MetricManager) GetAutodiscoverPaths() []string {
+	return nil
+}
+
+// ValidateDeps returns the errors set with FailValidation
+func (m *MetricManager) ValidateDeps([]core.RequestedMetric, []core.SubscribedPlugin, *cdata.ConfigDataTree, ...core.SubscribedPluginAssert) []serror.SnapError {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.validation
+}
+
+// SubscribeDeps subscribes the task to its plugins
+func (m *MetricManager) SubscribeDeps(taskID string, _ []core.RequestedMetric, _ []core.SubscribedPlugin, _ *cdata.ConfigDataTree) []serror.SnapError {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.subscribed[taskID] = true
+	m.subscriptions[taskID]++
+	return nil
+}
+
+// UnsubscribeDeps unsubscribes the task from its plugins
+func (m *MetricManager) UnsubscribeDeps(taskID string) []serror.SnapError {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.subscribed, taskID)
+	return nil
+}
@@ -0,0 +1,99 @@
+// +build small
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedtest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/serror"
+	"github.com/intelsdi-x/snap/pkg/schedule"
+	"github.com/intelsdi-x/snap/scheduler"
+	"github.com/intelsdi-x/snap/scheduler/wmap"
+)
+
+func TestHarness(t *testing.T) {
+	log.SetLevel(log.FatalLevel)
+	Convey("Given a scheduler run with the fake metric manager and a manual clock", t, func() {
+		clock := NewClock(time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC))
+		defer clock.Close()
+		mm := NewMetricManager()
+		mm.SetMetrics(plugin.MetricType{Namespace_: core.NewNamespace("intel", "mock", "foo"), Data_: 1})
+		s := scheduler.New(scheduler.GetDefaultConfig())
+		s.SetMetricManager(mm)
+		So(s.Start(), ShouldBeNil)
+		defer s.Stop()
+		w := wmap.NewWorkflowMap()
+		w.Collect.AddMetric("/intel/mock/foo", 1)
+		w.Collect.Add(wmap.NewPublishNode("file", 1))
+
+		Convey("a task started fires once", func() {
+			tk, errs := s.CreateTask(schedule.NewWindowedSchedule(time.Minute, nil, nil, 0), w, true)
+			So(errs.Errors(), ShouldBeEmpty)
+			So(WaitForHits(tk, 1, time.Second), ShouldBeNil)
+			So(mm.Subscribed(tk.ID()), ShouldBeTrue)
+			So(mm.SubscribedTasks(), ShouldResemble, []string{tk.ID()})
+
+			Convey("and once more on each interval the clock is advanced by", func() {
+				So(clock.Tick(1, time.Minute, time.Second), ShouldBeNil)
+				So(WaitForHits(tk, 2, time.Second), ShouldBeNil)
+				So(clock.Tick(1, time.Minute, time.Second), ShouldBeNil)
+				So(WaitForHits(tk, 3, time.Second), ShouldBeNil)
+				So(tk.HitCount(), ShouldEqual, 3)
+				So(mm.Collections(tk.ID()), ShouldEqual, 3)
+				So(mm.Published(tk.ID()), ShouldHaveLength, 3)
+			})
+			Convey("and is unsubscribed from its plugins once stopped", func() {
+				So(s.StopTask(tk.ID()), ShouldBeEmpty)
+				So(WaitForState(tk, core.TaskStopped, time.Second), ShouldBeNil)
+				So(WaitForSubscription(mm, tk.ID(), false, time.Second), ShouldBeNil)
+				So(mm.Subscriptions(tk.ID()), ShouldEqual, 1)
+			})
+		})
+		Convey("a task failing to collect records the failures", func() {
+			mm.FailCollections(errors.New("collector down"))
+			tk, errs := s.CreateTask(schedule.NewWindowedSchedule(time.Minute, nil, nil, 0), w, true)
+			So(errs.Errors(), ShouldBeEmpty)
+			So(WaitForHits(tk, 1, time.Second), ShouldBeNil)
+			So(tk.FailedCount(), ShouldEqual, 1)
+			So(mm.Published(tk.ID()), ShouldBeEmpty)
+		})
+		Convey("a task whose metrics do not validate is not created", func() {
+			mm.FailValidation(serror.New(errors.New("metric not found")))
+			_, errs := s.CreateTask(schedule.NewWindowedSchedule(time.Minute, nil, nil, 0), w, false)
+			So(errs.Errors(), ShouldNotBeEmpty)
+			So(mm.SubscribedTasks(), ShouldBeEmpty)
+		})
+		Convey("the waits time out", func() {
+			tk, errs := s.CreateTask(schedule.NewWindowedSchedule(time.Minute, nil, nil, 0), w, false)
+			So(errs.Errors(), ShouldBeEmpty)
+			So(WaitForHits(tk, 1, 10*time.Millisecond), ShouldNotBeNil)
+			So(clock.WaitForWaiters(1, 10*time.Millisecond), ShouldNotBeNil)
+		})
+	})
+}
@@ -20,6 +20,7 @@ limitations under the License.
 package scheduler
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -27,6 +28,8 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -34,6 +37,7 @@ import (
 	"github.com/ghodss/yaml"
 
 	"github.com/intelsdi-x/gomit"
+	"github.com/pborman/uuid"
 
 	"github.com/intelsdi-x/snap/control/plugin"
 	"github.com/intelsdi-x/snap/core"
@@ -58,6 +62,8 @@ var (
 	ErrMetricManagerNotSet = errors.New("MetricManager is not set.")
 	// ErrSchedulerNotStarted - The error message for scheduler is not started
 	ErrSchedulerNotStarted = errors.New("Scheduler is not started.")
+	// ErrSchedulerNotLeader - The error message for a task created on a scheduler standing by for the leadership
+	ErrSchedulerNotLeader = errors.New("Scheduler is not the leader. Tasks are created on the leader.")
 	// ErrTaskAlreadyRunning - The error message for task is already running
 	ErrTaskAlreadyRunning = errors.New("Task is already running.")
 	// ErrTaskAlreadyStopped - The error message for task is already stopped
@@ -68,10 +74,46 @@ var (
 	ErrTaskDisabledNotStoppable = errors.New("Task is disabled. Only running tasks can be stopped.")
 	// ErrTaskEndedNotStoppable - The error message for when a task is ended and cannot be stopped
 	ErrTaskEndedNotStoppable = errors.New("Task is ended. Only running tasks can be stopped.")
+	// ErrTaskNotPausable - The error message for when a task which is not running cannot be paused
+	ErrTaskNotPausable = errors.New("Task is not running. Only running tasks can be paused.")
+	// ErrStreamingTaskNotPausable - The error message for when a streaming task cannot be paused
+	ErrStreamingTaskNotPausable = errors.New("A streaming task cannot be paused.")
 	// ErrPluginIncompatibleWithScheduleType - The error message for when a streaming schedule type references a non streaming plugin or vice versa.
 	ErrPluginIncompatibleWithScheduleType = errors.New("Plugin is incompatible with the tasks schedule type.")
 	// ErrMultipleStreamingPlugins - The error message when a task with a streaming schedule refers to multiple streaming plugins.
 	ErrMultipleStreamingPlugins = errors.New("Multiple streaming plugins within the same task is not supported.")
+	// ErrTaskEndedScheduleNotUpdatable - The error message for when a task is ended and its schedule cannot be updated
+	ErrTaskEndedScheduleNotUpdatable = errors.New("Task is ended. Its schedule cannot be updated.")
+	// ErrStreamingScheduleNotUpdatable - The error message for when the schedule of a task is updated from or to a streaming schedule
+	ErrStreamingScheduleNotUpdatable = errors.New("A schedule cannot be updated from or to a streaming schedule.")
+	// ErrTaskEndedNotUpdatable - The error message for when a task is ended and cannot be updated
+	ErrTaskEndedNotUpdatable = errors.New("Task is ended. It cannot be updated.")
+	// ErrStreamingTaskNotUpdatable - The error message for when the workflow of a streaming task is updated
+	ErrStreamingTaskNotUpdatable = errors.New("The workflow of a streaming task cannot be updated.")
+	// ErrInvalidPoolSize - The error message for the worker pool size must be greater than 0
+	ErrInvalidPoolSize = errors.New("Worker pool size must be greater than 0.")
+	// ErrNoLiveWorkers - The error message for no worker running to work the jobs
+	ErrNoLiveWorkers = errors.New("No worker is running.")
+	// ErrTaskEndedNotRunnable - The error message for when a task is ended and cannot be run
+	ErrTaskEndedNotRunnable = errors.New("Task is ended. It cannot be run.")
+	// ErrStreamingTaskNotRunnable - The error message for when a streaming task is run off its schedule
+	ErrStreamingTaskNotRunnable = errors.New("A streaming task cannot be run off its schedule.")
+	// ErrInvalidTaskFilter - The error message for a task filter with a negative offset or limit
+	ErrInvalidTaskFilter = errors.New("Task filter offset and limit must not be negative.")
+	// ErrInvalidLabelSelector - The error message for a label selector which is not a list of key=value pairs
+	ErrInvalidLabelSelector = errors.New("Label selector must be a comma separated list of key=value pairs.")
+	// ErrDeadLetterSinkNotSet - The error message for dead letters replayed without a dead letter sink
+	ErrDeadLetterSinkNotSet = errors.New("Dead letter sink is not set.")
+	// ErrDeadLetterNotFound - The error message for a dead letter which is not kept in the sink
+	ErrDeadLetterNotFound = errors.New("Dead letter not found.")
+	// ErrDependencyNotFound - The error message for a task depending on a task which does not exist
+	ErrDependencyNotFound = errors.New("Task depended on not found.")
+	// ErrDependencyCycle - The error message for a task whose dependencies lead back to itself
+	ErrDependencyCycle = errors.New("Task dependencies form a cycle.")
+	// ErrDependentSchedule - The error message for a task with dependencies which is not on a dependent schedule or vice versa
+	ErrDependentSchedule = errors.New("A task must be on a dependent schedule if and only if it depends on other tasks.")
+	// ErrNotAuthorized - The error message for a task operation denied by the authorizer of the scheduler
+	ErrNotAuthorized = errors.New("Task operation not authorized.")
 )
 
 type schedulerState int
@@ -81,6 +123,9 @@ const (
 	schedulerStarted
 )
 
+// defaultStopTimeout is how long Stop waits for the work in flight to drain
+const defaultStopTimeout = 30 * time.Second
+
 type depGroupMap map[string]struct {
 	requestedMetrics  []core.RequestedMetric
 	subscribedPlugins []core.SubscribedPlugin
@@ -103,6 +148,27 @@ type managesMetrics interface {
 	UnsubscribeDeps(string) []serror.SnapError
 }
 
+// listsSubscribedMetrics is implemented by the metric managers able to list
+// the metric types a task is subscribed to, such as control
+type listsSubscribedMetrics interface {
+	SubscribedMetricTypes(taskID string) ([]core.Metric, error)
+}
+
+// collectsDueMetrics is implemented by the metric managers able to collect
+// only the metrics of a task due on a firing, such as control, sparing the
+// plugins of the metrics which are not due
+type collectsDueMetrics interface {
+	CollectMetricsDue(string, map[string]map[string]string, func(core.Namespace) bool) ([]core.Metric, []error)
+}
+
+// collectsMetricsContext is implemented by the metric managers able to give
+// up on a collection once its context is cancelled, such as control. The
+// metrics which are not due are not collected when due is set, as with
+// collectsDueMetrics.
+type collectsMetricsContext interface {
+	CollectMetricsContext(context.Context, string, map[string]map[string]string, func(core.Namespace) bool) ([]core.Metric, []error)
+}
+
 type collectsMetrics interface {
 	CollectMetrics(string, map[string]map[string]string) ([]core.Metric, []error)
 }
@@ -126,6 +192,65 @@ type scheduler struct {
 	state           schedulerState
 	eventManager    *gomit.EventController
 	taskWatcherColl *taskWatcherCollection
+	events          *taskEventBus
+	persistence     *taskPersistence
+	// stateMutex guards the state and the metric manager, so that they can
+	// be read by the public methods while the scheduler is started, stopped
+	// or linked to its metric manager
+	stateMutex sync.RWMutex
+	// lifecycleMutex serializes Start and Stop
+	lifecycleMutex sync.Mutex
+	// elector elects the leader among the schedulers sharing the task store,
+	// the scheduler identifying itself by instanceID. leading is set to 1
+	// while the scheduler is the leader.
+	elector    LeaderElector
+	instanceID string
+	leading    int32
+	// stopCampaign ends the campaign of a started scheduler, campaignDone is
+	// closed once it has ended
+	stopCampaign context.CancelFunc
+	campaignDone chan struct{}
+	// deadLetters keeps the metrics of the publish jobs which failed once
+	// their retries were exhausted, guarded by deadLetterMutex
+	deadLetters     DeadLetterSink
+	deadLetterMutex sync.RWMutex
+	// historySize is the number of runs kept in the run history of each
+	// task, runHistory keeps them across restarts, guarded by
+	// runHistoryMutex
+	historySize     uint
+	runHistory      RunHistoryStore
+	runHistoryMutex sync.RWMutex
+	// breakers skip the collections of the metrics failing repeatedly, nil
+	// if disabled
+	breakers *circuitBreakers
+	// throttle limits the collections of the metrics under a namespace
+	// prefix running at once, nil if they are unlimited
+	throttle *collectionThrottle
+	// dependencyMutex is held while the dependencies and the tenant quota of
+	// a task are checked and the task is added, or its workflow swapped
+	dependencyMutex sync.Mutex
+	// tenants holds the quotas of the tenants
+	tenants *tenantQuotas
+	// auditLog records the task management operations, nil if disabled
+	auditLog *auditLog
+	// authorizer decides whether the task management operations may run,
+	// guarded by authorizerMutex
+	authorizer      Authorizer
+	authorizerMutex sync.RWMutex
+	// tracer starts the spans of the firings of the tasks, guarded by
+	// tracerMutex
+	tracer      Tracer
+	tracerMutex sync.RWMutex
+	// adaptive stretches the intervals of the tasks under load, nil if
+	// disabled. stopAdaptive ends its checks, adaptiveDone is closed once
+	// they have ended.
+	adaptive     *adaptiveIntervals
+	stopAdaptive context.CancelFunc
+	adaptiveDone chan struct{}
+	// drainPolicy is the policy applied by Stop and StopWithContext, and
+	// drain holds the progress of the last stop
+	drainPolicy DrainPolicy
+	drain       drainState
 }
 
 type managesWork interface {
@@ -238,9 +363,19 @@ func autoDiscoverTasks(taskFiles []os.FileInfo, fullPath string,
 }
 
 // New returns an instance of the scheduler
+// The options override the settings of the given configuration, which
+// defaults to GetDefaultConfig() when nil. The configuration is not modified.
 // The MetricManager must be set before the scheduler can be started.
 // The MetricManager must be started before it can be used.
-func New(cfg *Config) *scheduler {
+func New(cfg *Config, opts ...SchedulerOption) *scheduler {
+	c := GetDefaultConfig()
+	if cfg != nil {
+		*c = *cfg
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	cfg = c
 	schedulerLogger.WithFields(log.Fields{
 		"_block": "New",
 		"value":  cfg.WorkManagerQueueSize,
@@ -249,24 +384,139 @@ func New(cfg *Config) *scheduler {
 		"_block": "New",
 		"value":  cfg.WorkManagerPoolSize,
 	}).Info("Setting work manager pool size")
-	opts := []workManagerOption{
+	policy := PolicyDropNewest
+	if cfg.WorkManagerQueueFullPolicy != "" {
+		p, err := ParseQueueFullPolicy(cfg.WorkManagerQueueFullPolicy)
+		if err != nil {
+			schedulerLogger.WithFields(log.Fields{
+				"_block": "New",
+				"_error": err.Error(),
+			}).Warning("Using the default work manager queue full policy")
+		}
+		policy = p
+	}
+	schedulerLogger.WithFields(log.Fields{
+		"_block": "New",
+		"value":  policy.String(),
+	}).Info("Setting work manager queue full policy")
+	// the process and publish queues and pools are sized like the collect
+	// ones unless they are given their own size
+	sizeOr := func(size, dflt uint) uint {
+		if size == 0 {
+			return dflt
+		}
+		return size
+	}
+	wmOpts := []workManagerOption{
+		QueueFullPolicyOption(policy),
 		CollectQSizeOption(cfg.WorkManagerQueueSize),
 		CollectWkrSizeOption(cfg.WorkManagerPoolSize),
-		PublishQSizeOption(cfg.WorkManagerQueueSize),
-		PublishWkrSizeOption(cfg.WorkManagerPoolSize),
-		ProcessQSizeOption(cfg.WorkManagerQueueSize),
-		ProcessWkrSizeOption(cfg.WorkManagerPoolSize),
+		PublishQSizeOption(sizeOr(cfg.WorkManagerPublishQueueSize, cfg.WorkManagerQueueSize)),
+		PublishWkrSizeOption(sizeOr(cfg.WorkManagerPublishPoolSize, cfg.WorkManagerPoolSize)),
+		ProcessQSizeOption(sizeOr(cfg.WorkManagerProcessQueueSize, cfg.WorkManagerQueueSize)),
+		ProcessWkrSizeOption(sizeOr(cfg.WorkManagerProcessPoolSize, cfg.WorkManagerPoolSize)),
+		MaxWorkerRestartsOption(cfg.WorkManagerMaxWorkerRestarts),
+		TaskQSizeOption(cfg.WorkManagerTaskQueueSize),
 	}
 	s := &scheduler{
 		tasks:           newTaskCollection(),
 		eventManager:    gomit.NewEventController(),
 		taskWatcherColl: newTaskWatcherCollection(),
+		events:          newTaskEventBus(int(cfg.TaskEventBufferSize)),
+		persistence:     &taskPersistence{},
+		instanceID:      uuid.New(),
+	}
+	s.tasks.uniqueNames = cfg.UniqueTaskNames
+	if cfg.StopDrainPolicy != "" {
+		p, err := ParseDrainPolicy(cfg.StopDrainPolicy)
+		if err != nil {
+			schedulerLogger.WithFields(log.Fields{
+				"_block": "New",
+				"_error": err.Error(),
+			}).Warning("Using the default drain policy")
+		}
+		s.drainPolicy = p
+	}
+	if cfg.TaskStorePath != "" {
+		s.persistence.store = NewFileTaskStore(cfg.TaskStorePath)
 	}
+	if cfg.DeadLetterPath != "" {
+		s.deadLetters = NewFileDeadLetterSink(cfg.DeadLetterPath)
+	}
+	s.historySize = cfg.TaskHistorySize
+	if cfg.TaskHistoryPath != "" && cfg.TaskHistorySize > 0 {
+		s.runHistory = NewFileRunHistoryStore(cfg.TaskHistoryPath, cfg.TaskHistorySize)
+	}
+	if cfg.SecretKeyPath != "" {
+		ctypes.SetSecretKeyProvider(ctypes.NewFileSecretKeyProvider(cfg.SecretKeyPath))
+	}
+	if cfg.CircuitBreakerThreshold > 0 {
+		s.breakers = newCircuitBreakers(
+			cfg.CircuitBreakerThreshold,
+			time.Duration(cfg.CircuitBreakerCooldown)*time.Second,
+			time.Duration(cfg.CircuitBreakerMaxCooldown)*time.Second,
+			int(cfg.CircuitBreakerPrefixDepth),
+			func(taskID, prefix string, state CircuitState) {
+				s.eventManager.Emit(&scheduler_event.CircuitBreakerChangedEvent{
+					TaskID: taskID,
+					Prefix: prefix,
+					State:  state.String(),
+				})
+			})
+	}
+	if cfg.MaxConcurrentCollections > 0 {
+		s.throttle = newCollectionThrottle(cfg.MaxConcurrentCollections, int(cfg.ThrottlePrefixDepth))
+	}
+	s.tenants = newTenantQuotas(TenantQuota{
+		MaxTasks:          cfg.TenantMaxTasks,
+		MaxMetrics:        cfg.TenantMaxMetrics,
+		MaxFiresPerMinute: cfg.TenantMaxFiresPerMinute,
+	})
+	if cfg.AuditLogSize > 0 {
+		s.auditLog = newAuditLog(cfg.AuditLogSize)
+	}
+	if cfg.LeaderLeasePath != "" {
+		s.elector = NewFileLeaderElector(cfg.LeaderLeasePath, time.Duration(cfg.LeaderLeaseTTL)*time.Second)
+	}
+	wmOpts = append(wmOpts, workerRetiredOption(func(pool, workerID string) {
+		s.eventManager.Emit(&scheduler_event.WorkerRetiredEvent{
+			Pool:     pool,
+			WorkerID: workerID,
+		})
+	}))
+	wmOpts = append(wmOpts, jobDroppedOption(func(queue string, j job, err error) {
+		switch err {
+		case errLimitExceeded:
+			s.eventManager.Emit(&scheduler_event.JobQueueOverflowedEvent{
+				TaskID: j.TaskID(),
+				Queue:  queue,
+			})
+		case errJobOverdue:
+			s.eventManager.Emit(&scheduler_event.JobDeadlineMissedEvent{
+				TaskID: j.TaskID(),
+				Queue:  queue,
+			})
+		}
+	}))
 
 	// we are setting the size of the queue and number of workers for
 	// collect, process and publish consistently for now
-	s.workManager = newWorkManager(opts...)
-	s.workManager.Start()
+	s.workManager = newWorkManager(wmOpts...)
+	if cfg.AdaptiveMaxStretch > 1 {
+		s.adaptive = newAdaptiveIntervals(
+			cfg.AdaptiveMaxStretch,
+			cfg.AdaptiveQueueThreshold,
+			cfg.AdaptiveCPUThreshold,
+			time.Duration(cfg.AdaptiveCheckInterval)*time.Second,
+			s.workManager.saturation)
+	}
+	if err := s.workManager.Start(); err != nil {
+		// the error is returned again when starting the scheduler
+		schedulerLogger.WithFields(log.Fields{
+			"_block": "New",
+			"_error": err.Error(),
+		}).Error("error on work manager start")
+	}
 	s.eventManager.RegisterHandler(HandlerRegistrationName, s)
 
 	return s
@@ -280,6 +530,23 @@ func (t *taskErrors) Errors() []serror.SnapError {
 	return t.errs
 }
 
+// Error returns the messages of the errors
+func (t *taskErrors) Error() string {
+	msgs := make([]string, len(t.errs))
+	for i, e := range t.errs {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// add adds the errors which occurred in the phase, attributing them with the
+// code unless the error says better
+func (t *taskErrors) add(phase core.TaskPhase, code core.TaskErrorCode, errs ...serror.SnapError) {
+	for _, e := range errs {
+		t.errs = append(t.errs, core.AttributeTaskError(e, phase, code))
+	}
+}
+
 func (s *scheduler) Name() string {
 	return "scheduler"
 }
@@ -288,16 +555,33 @@ func (s *scheduler) RegisterEventHandler(name string, h gomit.Handler) error {
 	return s.eventManager.RegisterHandler(name, h)
 }
 
-// CreateTask creates and returns task
+// CreateTask creates and returns task.
+// The workflow and its dependencies are validated in either case. If
+// startOnCreate is false the task is left in the stopped state and its plugins
+// are not subscribed until the task is started with StartTask, so a stopped
+// task can be removed with RemoveTask without holding any subscriptions.
 func (s *scheduler) CreateTask(sch schedule.Schedule, wfMap *wmap.WorkflowMap, startOnCreate bool, opts ...core.TaskOption) (core.Task, core.TaskErrors) {
-	return s.createTask(sch, wfMap, startOnCreate, "user", opts...)
+	return s.createTask(context.Background(), sch, wfMap, startOnCreate, "user", opts...)
+}
+
+// CreateTaskWithContext creates a task the same way CreateTask does. When the
+// context is cancelled while the dependencies of the task are validated or
+// subscribed, the creation is aborted, the plugins already subscribed are
+// unsubscribed and the error of the context is returned in the task errors.
+func (s *scheduler) CreateTaskWithContext(ctx context.Context, sch schedule.Schedule, wfMap *wmap.WorkflowMap, startOnCreate bool, opts ...core.TaskOption) (core.Task, core.TaskErrors) {
+	return s.createTask(ctx, sch, wfMap, startOnCreate, "user", opts...)
 }
 
 func (s *scheduler) CreateTaskTribe(sch schedule.Schedule, wfMap *wmap.WorkflowMap, startOnCreate bool, opts ...core.TaskOption) (core.Task, core.TaskErrors) {
-	return s.createTask(sch, wfMap, startOnCreate, "tribe", opts...)
+	return s.createTask(context.Background(), sch, wfMap, startOnCreate, "tribe", opts...)
+}
+
+// createAutodiscoveredTask creates a task found in an autodiscover path
+func (s *scheduler) createAutodiscoveredTask(sch schedule.Schedule, wfMap *wmap.WorkflowMap, startOnCreate bool, opts ...core.TaskOption) (core.Task, core.TaskErrors) {
+	return s.createTask(context.Background(), sch, wfMap, startOnCreate, "autodiscover", opts...)
 }
 
-func (s *scheduler) createTask(sch schedule.Schedule, wfMap *wmap.WorkflowMap, startOnCreate bool, source string, opts ...core.TaskOption) (core.Task, core.TaskErrors) {
+func (s *scheduler) createTask(ctx context.Context, sch schedule.Schedule, wfMap *wmap.WorkflowMap, startOnCreate bool, source string, opts ...core.TaskOption) (core.Task, core.TaskErrors) {
 	logger := schedulerLogger.WithFields(log.Fields{
 		"_block":          "create-task",
 		"source":          source,
@@ -307,40 +591,251 @@ func (s *scheduler) createTask(sch schedule.Schedule, wfMap *wmap.WorkflowMap, s
 	te := &taskErrors{
 		errs: make([]serror.SnapError, 0),
 	}
+	// fail records the failed creation in the audit log
+	fail := func(errs *taskErrors) (core.Task, core.TaskErrors) {
+		s.audit(ctx, core.AuditCreateTask, "", source, nil, nil, auditError(errs.Errors()))
+		return nil, errs
+	}
 
 	// Return error if we are not started.
-	if s.state != schedulerStarted {
-		te.errs = append(te.errs, serror.New(ErrSchedulerNotStarted))
+	if s.getState() != schedulerStarted {
+		te.add(core.TaskPhaseScheduler, core.TaskErrorSchedulerUnavailable, serror.New(ErrSchedulerNotStarted))
 		f := buildErrorsLog(te.Errors(), logger)
 		f.Error(ErrSchedulerNotStarted.Error())
+		return fail(te)
+	}
+	if !s.IsLeader() {
+		te.add(core.TaskPhaseScheduler, core.TaskErrorSchedulerUnavailable, serror.New(ErrSchedulerNotLeader))
+		f := buildErrorsLog(te.Errors(), logger)
+		f.Error(ErrSchedulerNotLeader.Error())
+		return fail(te)
+	}
+
+	task, verrs := s.validateTask(ctx, sch, wfMap, logger, opts...)
+	if verrs != nil {
+		return fail(verrs)
+	}
+
+	// Do not add the task if the creation was cancelled while validating it
+	if err := ctx.Err(); err != nil {
+		te.add(core.TaskPhaseScheduler, core.TaskErrorCancelled, serror.New(err))
+		f := buildErrorsLog(te.Errors(), logger)
+		f.Error("task creation cancelled")
+		return fail(te)
+	}
+	if err := assignTenant(ctx, task); err != nil {
+		te.add(core.TaskPhaseValidation, core.TaskErrorInvalidOption, serror.New(err))
+		f := buildErrorsLog(te.Errors(), logger)
+		f.Error("tenant of the task not valid")
+		return fail(te)
+	}
+	if err := s.authorize(ctx, core.AuditCreateTask, "", source, task); err != nil {
+		te.add(core.TaskPhaseScheduler, core.TaskErrorNotAuthorized, serror.New(err))
+		f := buildErrorsLog(te.Errors(), logger)
+		f.Error("task creation not authorized")
+		return fail(te)
+	}
+
+	task.autodiscovered = source == "autodiscover"
+	s.dependencyMutex.Lock()
+	if err := s.checkDependencies(task); err != nil {
+		s.dependencyMutex.Unlock()
+		te.add(core.TaskPhaseValidation, core.TaskErrorInvalidDependency, serror.New(err))
+		f := buildErrorsLog(te.Errors(), logger)
+		f.Error("dependencies of the task not valid")
+		return fail(te)
+	}
+	if err := s.checkTenantQuota(task.tenant, "", len(task.workflow.metrics)); err != nil {
+		s.dependencyMutex.Unlock()
+		te.add(core.TaskPhaseScheduler, core.TaskErrorQuotaExceeded, serror.New(err))
+		f := buildErrorsLog(te.Errors(), logger)
+		f.Error("tenant quota exceeded")
+		return fail(te)
+	}
+	// Add task to taskCollection
+	err := s.tasks.add(task)
+	s.dependencyMutex.Unlock()
+	if err != nil {
+		te.add(core.TaskPhaseScheduler, core.TaskErrorTaskNotAdded, serror.New(err))
+		f := buildErrorsLog(te.Errors(), logger)
+		f.Error("errors during task creation")
+		return fail(te)
+	}
+	s.loadRunHistory(task)
+	s.audit(ctx, core.AuditCreateTask, task.id, source, nil, auditManifest(task), nil)
+
+	logger.WithFields(log.Fields{
+		"task-id":    task.ID(),
+		"task-state": task.State(),
+	}).Info("task created")
+
+	event := &scheduler_event.TaskCreatedEvent{
+		TaskID:        task.id,
+		StartOnCreate: startOnCreate,
+		Source:        source,
+	}
+	defer s.eventManager.Emit(event)
+
+	if startOnCreate {
+		logger.WithFields(log.Fields{
+			"task-id": task.ID(),
+			"source":  source,
+		}).Info("starting task on creation")
+
+		errs := s.startTask(ctx, task.id, "user")
+		if errs != nil {
+			te.add(core.TaskPhaseSubscription, core.TaskErrorSubscriptionFailed, errs...)
+		}
+	}
+
+	return task, te
+}
+
+// ValidateTask validates a task the same way CreateTask does, checking its
+// schedule, its workflow and that the metrics and plugins of the workflow are
+// available, without creating the task or subscribing to its plugins.
+func (s *scheduler) ValidateTask(sch schedule.Schedule, wfMap *wmap.WorkflowMap, opts ...core.TaskOption) core.TaskErrors {
+	logger := schedulerLogger.WithFields(log.Fields{
+		"_block": "validate-task",
+	})
+	t, te := s.validateTask(context.Background(), sch, wfMap, logger, opts...)
+	if te != nil {
+		return te
+	}
+	if err := s.checkDependencies(t); err != nil {
+		te = &taskErrors{
+			errs: make([]serror.SnapError, 0),
+		}
+		te.add(core.TaskPhaseValidation, core.TaskErrorInvalidDependency, serror.New(err))
+		f := buildErrorsLog(te.Errors(), logger)
+		f.Error("dependencies of the task not valid")
+		return te
+	}
+	return nil
+}
+
+// ValidateTaskCollect validates a task like ValidateTask, then exercises one
+// collection of its metrics and returns the metrics collected, matched and
+// deduplicated like the metrics of a firing. The collectors of the task are subscribed for the
+// collection only, under an id of their own, and are unsubscribed once it is
+// done. The metrics are neither processed nor published and the task is not
+// created. A streaming task cannot be collected this way.
+func (s *scheduler) ValidateTaskCollect(ctx context.Context, sch schedule.Schedule, wfMap *wmap.WorkflowMap, opts ...core.TaskOption) ([]core.Metric, core.TaskErrors) {
+	logger := schedulerLogger.WithFields(log.Fields{
+		"_block": "validate-task-collect",
+	})
+	t, te := s.validateTask(ctx, sch, wfMap, logger, opts...)
+	if te != nil {
+		return nil, te
+	}
+	te = &taskErrors{
+		errs: make([]serror.SnapError, 0),
+	}
+	if t.isStream {
+		logger.Error(ErrStreamingTaskNotRunnable)
+		te.add(core.TaskPhaseCollection, core.TaskErrorCollectionFailed, serror.New(ErrStreamingTaskNotRunnable))
+		return nil, te
+	}
+	// the metrics are collected by the local manager, under an id which
+	// is not the one of any task
+	id := "validate-" + t.id
+	mgr, _ := t.RemoteManagers.Get("")
+	if errs := mgr.SubscribeDeps(id, t.workflow.metrics, nil, t.workflow.configTree); len(errs) > 0 {
+		te.add(core.TaskPhaseSubscription, core.TaskErrorSubscriptionFailed, errs...)
+		f := buildErrorsLog(te.Errors(), logger)
+		f.Error("failed to subscribe the collectors of the task")
 		return nil, te
 	}
+	defer mgr.UnsubscribeDeps(id)
 
+	type collection struct {
+		mts  []core.Metric
+		errs []error
+	}
+	done := make(chan collection, 1)
+	go func() {
+		mts, errs := mgr.CollectMetrics(id, t.workflow.tags)
+		done <- collection{mts: mts, errs: errs}
+	}()
+	select {
+	case c := <-done:
+		for _, err := range c.errs {
+			te.add(core.TaskPhaseCollection, core.TaskErrorCollectionFailed, serror.New(err))
+		}
+		if len(te.errs) > 0 {
+			f := buildErrorsLog(te.Errors(), logger)
+			f.Error("collection of the task failed")
+			return nil, te
+		}
+		mts, _ := dedupMetrics(matchingMetrics(c.mts, t.workflow.match))
+		return mts, nil
+	case <-ctx.Done():
+		te.add(core.TaskPhaseCollection, core.TaskErrorCancelled, serror.New(ctx.Err()))
+		logger.WithField("_error", ctx.Err()).Warn("stopped waiting for the collection of the task")
+		return nil, te
+	}
+}
+
+// validateTask builds a task from the schedule and the workflow map and
+// validates its dependencies. The task is neither added to the task
+// collection nor subscribed to its plugins.
+func (s *scheduler) validateTask(ctx context.Context, sch schedule.Schedule, wfMap *wmap.WorkflowMap, logger *log.Entry, opts ...core.TaskOption) (*task, *taskErrors) {
 	// Ensure the schedule is valid at this point and time.
 	if err := sch.Validate(); err != nil {
-		te.errs = append(te.errs, serror.New(err))
+		te := &taskErrors{
+			errs: make([]serror.SnapError, 0),
+		}
+		te.add(core.TaskPhaseValidation, core.TaskErrorInvalidSchedule, serror.New(err))
 		f := buildErrorsLog(te.Errors(), logger)
 		f.Error("schedule passed not valid")
 		return nil, te
 	}
+	return s.validateWorkflow(ctx, sch, wfMap, logger, opts...)
+}
+
+// validateWorkflow builds a task from the workflow map, to be fired on the
+// schedule which is already valid, and validates its dependencies like
+// validateTask
+func (s *scheduler) validateWorkflow(ctx context.Context, sch schedule.Schedule, wfMap *wmap.WorkflowMap, logger *log.Entry, opts ...core.TaskOption) (*task, *taskErrors) {
+	// Create a container for task errors
+	te := &taskErrors{
+		errs: make([]serror.SnapError, 0),
+	}
 
 	// Generate a workflow from the workflow map
 	wf, err := wmapToWorkflow(wfMap)
 	if err != nil {
-		te.errs = append(te.errs, serror.New(err))
+		te.add(core.TaskPhaseValidation, core.TaskErrorInvalidWorkflow, serror.New(err))
 		f := buildErrorsLog(te.Errors(), logger)
 		f.Error("Unable to generate workflow from workflow map")
 		return nil, te
 	}
 
+	// Ensure the workflow is well formed before subscribing to anything.
+	if errs := validateWorkflowMap(wfMap); len(errs) > 0 {
+		te.add(core.TaskPhaseValidation, core.TaskErrorInvalidWorkflow, errs...)
+		f := buildErrorsLog(te.Errors(), logger)
+		f.Error("workflow map passed not valid")
+		return nil, te
+	}
+
 	// Create the task object
-	task, err := newTask(sch, wf, s.workManager, s.metricManager, s.eventManager, opts...)
+	task, err := newTask(sch, wf, s.workManager, s.getMetricManager(), s.eventManager, opts...)
 	if err != nil {
-		te.errs = append(te.errs, serror.New(err))
+		te.add(core.TaskPhaseValidation, core.TaskErrorInvalidOption, serror.New(err))
 		f := buildErrorsLog(te.Errors(), logger)
 		f.Error("Unable to create task")
 		return nil, te
 	}
+	task.deadLetter = s.putDeadLetter
+	task.breakers = s.breakers
+	task.throttle = s.throttle
+	task.tenants = s.tenants
+	if s.historySize > 0 {
+		task.history = newRunHistory(s.historySize)
+		task.historyStore = s.getRunHistoryStore
+	}
+	task.tracer = s.getTracer
 
 	// subscribedPluginAsserts includes rules that need to be evaluated once we
 	// have mapped the metrics to specific collector plugins.  Examples include
@@ -402,70 +897,54 @@ func (s *scheduler) createTask(sch schedule.Schedule, wfMap *wmap.WorkflowMap, s
 			})
 		}
 
+		if err := ctx.Err(); err != nil {
+			te.add(core.TaskPhaseDependencies, core.TaskErrorCancelled, serror.New(err))
+			return nil, te
+		}
 		manager, err := task.RemoteManagers.Get(k)
 		if err != nil {
-			te.errs = append(te.errs, serror.New(err))
+			te.add(core.TaskPhaseDependencies, core.TaskErrorInvalidDependency, serror.New(err))
 			return nil, te
 		}
 		var errs []serror.SnapError
 		errs = manager.ValidateDeps(group.requestedMetrics, group.subscribedPlugins, wf.configTree, subscribedPluginAsserts...)
 
 		if len(errs) > 0 {
-			te.errs = append(te.errs, errs...)
+			te.add(core.TaskPhaseDependencies, core.TaskErrorInvalidDependency, errs...)
 			return nil, te
 		}
 	}
 
-	// Add task to taskCollection
-	if err := s.tasks.add(task); err != nil {
-		te.errs = append(te.errs, serror.New(err))
-		f := buildErrorsLog(te.Errors(), logger)
-		f.Error("errors during task creation")
-		return nil, te
-	}
-
-	logger.WithFields(log.Fields{
-		"task-id":    task.ID(),
-		"task-state": task.State(),
-	}).Info("task created")
-
-	event := &scheduler_event.TaskCreatedEvent{
-		TaskID:        task.id,
-		StartOnCreate: startOnCreate,
-		Source:        source,
-	}
-	defer s.eventManager.Emit(event)
-
-	if startOnCreate {
-		logger.WithFields(log.Fields{
-			"task-id": task.ID(),
-			"source":  source,
-		}).Info("starting task on creation")
-
-		errs := s.StartTask(task.id)
-		if errs != nil {
-			te.errs = append(te.errs, errs...)
-		}
-	}
-
-	return task, te
+	return task, nil
 }
 
 // RemoveTask given a tasks id.  The task must be stopped.
+// A task that is still stopping is given up to its deadline duration to
+// finish the in-flight workflow execution before it is removed.
 // Can return errors ErrTaskNotFound and ErrTaskNotStopped.
 func (s *scheduler) RemoveTask(id string) error {
-	return s.removeTask(id, "user")
+	return s.removeTask(context.Background(), id, "user")
+}
+
+// RemoveTaskWithContext removes a task the same way RemoveTask does, the
+// context carrying the identity of the caller recorded in the audit log
+func (s *scheduler) RemoveTaskWithContext(ctx context.Context, id string) error {
+	return s.removeTask(ctx, id, "user")
 }
 
 func (s *scheduler) RemoveTaskTribe(id string) error {
-	return s.removeTask(id, "tribe")
+	return s.removeTask(context.Background(), id, "tribe")
 }
 
-func (s *scheduler) removeTask(id, source string) error {
+func (s *scheduler) removeTask(ctx context.Context, id, source string) (err error) {
 	logger := schedulerLogger.WithFields(log.Fields{
 		"_block": "remove-task",
 		"source": source,
 	})
+	var removed *core.TaskCreationRequest
+	defer func() {
+		s.audit(ctx, core.AuditRemoveTask, id, source, removed, nil, err)
+	}()
 	t, err := s.getTask(id)
 	if err != nil {
 		logger.WithFields(log.Fields{
@@ -473,24 +952,291 @@ func (s *scheduler) removeTask(id, source string) error {
 		}).Error(ErrTaskNotFound)
 		return err
 	}
+
+	t.lifecycleMutex.Lock()
+	defer t.lifecycleMutex.Unlock()
+
+	if err := s.authorize(ctx, core.AuditRemoveTask, id, source, t); err != nil {
+		return err
+	}
+	if t.State() == core.TaskStopping && !t.waitForStop(t.DeadlineDuration()) {
+		logger.WithFields(log.Fields{
+			"task id": id,
+		}).Error(ErrTaskNotStopped)
+		return ErrTaskNotStopped
+	}
+
+	manifest := auditManifest(t)
+	if err := s.tasks.remove(t); err != nil {
+		return err
+	}
+	removed = manifest
+	// nothing triggers the schedule of a removed task anymore
+	if sch, ok := t.Schedule().(*schedule.DependentSchedule); ok {
+		sch.End()
+	}
+	// a task which just stopped may not have been unsubscribed by the event
+	// handler yet, and the handler cannot find it once it is removed
+	t.UnsubscribePlugins()
+	s.removeRunHistory(t.id)
 	event := &scheduler_event.TaskDeletedEvent{
 		TaskID: t.id,
 		Source: source,
 	}
-
 	defer s.eventManager.Emit(event)
-	return s.tasks.remove(t)
+	return nil
+}
+
+// DeleteAllTasks stops and removes every task, unsubscribing their plugins.
+// The last workflow execution of a running task is waited for as in
+// RemoveTask, so that no work of the removed tasks is left to the workers.
+// A task which cannot be removed is kept and its error returned.
+func (s *scheduler) DeleteAllTasks() []error {
+	var errs []error
+	for id, t := range s.tasks.Table() {
+		if state := t.State(); state == core.TaskSpinning || state == core.TaskFiring {
+			// a task which stopped or ended meanwhile is removed all the same
+			s.stopTask(context.Background(), id, "user")
+		}
+		if err := s.removeTask(context.Background(), id, "user"); err != nil {
+			errs = append(errs, fmt.Errorf("%v: ID(%v)", err, id))
+		}
+	}
+	return errs
+}
+
+// UpdateTaskSchedule replaces the schedule of a task. A running task fires on
+// the new schedule from its next tick, while its workflow and the
+// subscriptions of its plugins are left untouched.
+// Can return errors ErrTaskNotFound, ErrTaskEndedScheduleNotUpdatable,
+// ErrStreamingScheduleNotUpdatable and the validation error of the schedule.
+func (s *scheduler) UpdateTaskSchedule(id string, sch schedule.Schedule) (err error) {
+	logger := schedulerLogger.WithFields(log.Fields{
+		"_block":  "update-task-schedule",
+		"task-id": id,
+	})
+	var before, after *core.TaskCreationRequest
+	defer func() {
+		s.audit(context.Background(), core.AuditUpdateTask, id, "user", before, after, err)
+	}()
+	t, err := s.getTask(id)
+	if err != nil {
+		logger.Error(ErrTaskNotFound)
+		return err
+	}
+
+	t.lifecycleMutex.Lock()
+	defer t.lifecycleMutex.Unlock()
+
+	if err := s.authorize(context.Background(), core.AuditUpdateTask, id, "user", t); err != nil {
+		return err
+	}
+	if t.State() == core.TaskEnded {
+		logger.Error(ErrTaskEndedScheduleNotUpdatable)
+		return ErrTaskEndedScheduleNotUpdatable
+	}
+	if _, stream := sch.(*schedule.StreamingSchedule); stream || t.isStream {
+		logger.Error(ErrStreamingScheduleNotUpdatable)
+		return ErrStreamingScheduleNotUpdatable
+	}
+	if err := checkDependentSchedule(sch, t.dependsOn); err != nil {
+		logger.Error(err)
+		return err
+	}
+	if err := sch.Validate(); err != nil {
+		logger.WithFields(log.Fields{
+			"_error": err.Error(),
+		}).Error("schedule passed not valid")
+		return err
+	}
+	before = auditManifest(t)
+	t.setSchedule(sch)
+	after = auditManifest(t)
+	logger.Info("task schedule updated")
+	return nil
+}
+
+// UpdateTask replaces the schedule and the workflow of a task at once,
+// keeping its id, its state and its counters. A nil schedule or workflow map
+// keeps the current one. The workflow map, holding the metrics of the task
+// and their config, is validated like the one of a created task, under the
+// base config of the task. The plugins of a running task are swapped between
+// two firings, subscribing the new metrics and plugins before unsubscribing
+// the old ones, so that the task keeps running its old workflow if the new
+// one cannot be subscribed. The task fires the new workflow on the new
+// schedule from its next firing.
+// Can return errors ErrTaskNotFound, ErrTaskEndedNotUpdatable,
+// ErrStreamingTaskNotUpdatable, ErrStreamingScheduleNotUpdatable and the
+// validation errors of the schedule and of the workflow.
+func (s *scheduler) UpdateTask(id string, sch schedule.Schedule, wfMap *wmap.WorkflowMap) core.TaskErrors {
+	return s.updateTask(context.Background(), id, sch, wfMap)
+}
+
+// UpdateTaskWithContext updates a task the same way UpdateTask does, the
+// context carrying the identity of the caller recorded in the audit log
+func (s *scheduler) UpdateTaskWithContext(ctx context.Context, id string, sch schedule.Schedule, wfMap *wmap.WorkflowMap) core.TaskErrors {
+	return s.updateTask(ctx, id, sch, wfMap)
+}
+
+func (s *scheduler) updateTask(ctx context.Context, id string, sch schedule.Schedule, wfMap *wmap.WorkflowMap) (terrs core.TaskErrors) {
+	logger := schedulerLogger.WithFields(log.Fields{
+		"_block":  "update-task",
+		"task-id": id,
+	})
+	var before, after *core.TaskCreationRequest
+	defer func() {
+		var err error
+		if terrs != nil {
+			err = auditError(terrs.Errors())
+		}
+		s.audit(ctx, core.AuditUpdateTask, id, "user", before, after, err)
+	}()
+	te := &taskErrors{
+		errs: make([]serror.SnapError, 0),
+	}
+	t, err := s.getTask(id)
+	if err != nil {
+		logger.Error(ErrTaskNotFound)
+		te.add(core.TaskPhaseScheduler, core.TaskErrorTaskNotFound, serror.New(err))
+		return te
+	}
+
+	t.lifecycleMutex.Lock()
+	defer t.lifecycleMutex.Unlock()
+
+	if t.State() == core.TaskEnded {
+		logger.Error(ErrTaskEndedNotUpdatable)
+		te.add(core.TaskPhaseScheduler, core.TaskErrorTaskNotUpdatable, serror.New(ErrTaskEndedNotUpdatable))
+		return te
+	}
+	if t.isStream && wfMap != nil {
+		logger.Error(ErrStreamingTaskNotUpdatable)
+		te.add(core.TaskPhaseScheduler, core.TaskErrorTaskNotUpdatable, serror.New(ErrStreamingTaskNotUpdatable))
+		return te
+	}
+	if sch != nil {
+		if _, stream := sch.(*schedule.StreamingSchedule); stream || t.isStream {
+			logger.Error(ErrStreamingScheduleNotUpdatable)
+			te.add(core.TaskPhaseScheduler, core.TaskErrorTaskNotUpdatable, serror.New(ErrStreamingScheduleNotUpdatable))
+			return te
+		}
+		if err := checkDependentSchedule(sch, t.dependsOn); err != nil {
+			logger.Error(err)
+			te.add(core.TaskPhaseValidation, core.TaskErrorInvalidSchedule, serror.New(err))
+			return te
+		}
+		if err := sch.Validate(); err != nil {
+			te.add(core.TaskPhaseValidation, core.TaskErrorInvalidSchedule, serror.New(err))
+			f := buildErrorsLog(te.Errors(), logger)
+			f.Error("schedule passed not valid")
+			return te
+		}
+	}
+
+	manifest := auditManifest(t)
+	authorized := false
+	if wfMap != nil {
+		vsch := sch
+		if vsch == nil {
+			vsch = t.Schedule()
+		}
+		var opts []core.TaskOption
+		if base := t.BaseConfig(); base != nil {
+			opts = append(opts, core.OptionBaseConfig(base))
+		}
+		// the schedule was validated above, the current schedule of the
+		// task is not validated again while the task waits on it
+		updated, verrs := s.validateWorkflow(context.Background(), vsch, wfMap, logger, opts...)
+		if verrs != nil {
+			return verrs
+		}
+		if err := s.authorize(ctx, core.AuditUpdateTask, id, "user", t, updated); err != nil {
+			te.add(core.TaskPhaseScheduler, core.TaskErrorNotAuthorized, serror.New(err))
+			return te
+		}
+		authorized = true
+		s.dependencyMutex.Lock()
+		if err := s.checkTenantQuota(t.tenant, t.id, len(updated.workflow.metrics)); err != nil {
+			s.dependencyMutex.Unlock()
+			te.add(core.TaskPhaseScheduler, core.TaskErrorQuotaExceeded, serror.New(err))
+			f := buildErrorsLog(te.Errors(), logger)
+			f.Error("tenant quota exceeded")
+			return te
+		}
+		errs := t.swapWorkflow(context.Background(), updated.workflow, updated.RemoteManagers)
+		s.dependencyMutex.Unlock()
+		if len(errs) > 0 {
+			te.add(core.TaskPhaseSubscription, core.TaskErrorSubscriptionFailed, errs...)
+			f := buildErrorsLog(te.Errors(), logger)
+			f.Error("workflow of the task not swapped")
+			return te
+		}
+	}
+	if !authorized {
+		if err := s.authorize(ctx, core.AuditUpdateTask, id, "user", t); err != nil {
+			te.add(core.TaskPhaseScheduler, core.TaskErrorNotAuthorized, serror.New(err))
+			return te
+		}
+	}
+	if sch != nil {
+		t.setSchedule(sch)
+	}
+	before, after = manifest, auditManifest(t)
+	logger.Info("task updated")
+	s.persistTasks()
+	return nil
+}
+
+// CircuitBreakers returns the state of the circuit breaker of each namespace
+// prefix which is open or half-open, the collections of the metrics under the
+// other prefixes running as usual. It is empty if the breakers are disabled.
+func (s *scheduler) CircuitBreakers() map[string]CircuitState {
+	if s.breakers == nil {
+		return map[string]CircuitState{}
+	}
+	return s.breakers.states()
+}
+
+// InFlightCollections returns the number of collections of the metrics under
+// each namespace prefix running at once, as limited by
+// max_concurrent_collections. It is empty if the collections are unlimited.
+func (s *scheduler) InFlightCollections() map[string]int {
+	if s.throttle == nil {
+		return map[string]int{}
+	}
+	return s.throttle.inFlight()
 }
 
 // GetTasks returns a copy of the tasks in a map where the task id is the key
 func (s *scheduler) GetTasks() map[string]core.Task {
-	tasks := make(map[string]core.Task)
-	for id, t := range s.tasks.Table() {
-		tasks[id] = t
+	list, _ := s.ListTasks(TaskFilter{})
+	tasks := make(map[string]core.Task, len(list))
+	for _, t := range list {
+		tasks[t.ID()] = t
 	}
 	return tasks
 }
 
+// ListTasks returns the tasks selected by the filter ordered by their
+// creation time
+func (s *scheduler) ListTasks(filter TaskFilter) ([]core.Task, error) {
+	if err := filter.validate(); err != nil {
+		schedulerLogger.WithFields(log.Fields{
+			"_block": "list-tasks",
+			"_error": err.Error(),
+			"offset": filter.Offset,
+			"limit":  filter.Limit,
+		}).Error("invalid task filter")
+		return nil, err
+	}
+	list := s.tasks.list(filter)
+	tasks := make([]core.Task, len(list))
+	for i, t := range list {
+		tasks[i] = t
+	}
+	return tasks, nil
+}
+
 // GetTask provided the task id a task is returned
 func (s *scheduler) GetTask(id string) (core.Task, error) {
 	t, err := s.getTask(id)
@@ -505,20 +1251,59 @@ func (s *scheduler) GetTask(id string) (core.Task, error) {
 	return t, nil
 }
 
-// StartTask provided a task id a task is started
+// GetTaskByName provided a task name a task is returned
+func (s *scheduler) GetTaskByName(name string) (core.Task, error) {
+	t := s.tasks.GetByName(name)
+	if t == nil {
+		err := fmt.Errorf("%v: name(%v)", ErrTaskNotFound, name)
+		schedulerLogger.WithFields(log.Fields{
+			"_block":    "get-task-by-name",
+			"_error":    ErrTaskNotFound,
+			"task-name": name,
+		}).Error("error getting task")
+		return nil, err
+	}
+	return t, nil
+}
+
+// GetTasksByLabel returns the tasks carrying all the labels of the selector,
+// such as "env=prod,service=web", ordered by their creation time
+func (s *scheduler) GetTasksByLabel(selector string) ([]core.Task, error) {
+	labels, err := ParseLabelSelector(selector)
+	if err != nil {
+		schedulerLogger.WithFields(log.Fields{
+			"_block":   "get-tasks-by-label",
+			"_error":   err.Error(),
+			"selector": selector,
+		}).Error("invalid label selector")
+		return nil, err
+	}
+	return s.ListTasks(TaskFilter{Labels: labels})
+}
+
+// StartTask provided a task id a task is started, a paused task is resumed
 func (s *scheduler) StartTask(id string) []serror.SnapError {
-	return s.startTask(id, "user")
+	return s.startTask(context.Background(), id, "user")
+}
+
+// StartTaskWithContext starts a task the same way StartTask does, the
+// context carrying the identity of the caller recorded in the audit log
+func (s *scheduler) StartTaskWithContext(ctx context.Context, id string) []serror.SnapError {
+	return s.startTask(ctx, id, "user")
 }
 
 func (s *scheduler) StartTaskTribe(id string) []serror.SnapError {
-	return s.startTask(id, "tribe")
+	return s.startTask(context.Background(), id, "tribe")
 }
 
-func (s *scheduler) startTask(id, source string) []serror.SnapError {
+func (s *scheduler) startTask(ctx context.Context, id, source string) (errs []serror.SnapError) {
 	logger := schedulerLogger.WithFields(log.Fields{
 		"_block": "start-task",
 		"source": source,
 	})
+	defer func() {
+		s.audit(ctx, core.AuditStartTask, id, source, nil, nil, auditError(errs))
+	}()
 
 	t, err := s.getTask(id)
 	if err != nil {
@@ -532,7 +1317,18 @@ func (s *scheduler) startTask(id, source string) []serror.SnapError {
 		}
 	}
 
-	if t.state == core.TaskDisabled {
+	// Serialize with any concurrent start or stop of the same task so that
+	// plugins are subscribed only once per start.
+	t.lifecycleMutex.Lock()
+	defer t.lifecycleMutex.Unlock()
+
+	if err := s.authorize(ctx, core.AuditStartTask, id, source, t); err != nil {
+		return []serror.SnapError{
+			serror.New(err),
+		}
+	}
+	state := t.State()
+	if state == core.TaskDisabled {
 		logger.WithFields(log.Fields{
 			"task-id": t.ID(),
 		}).Error("Task is disabled and must be enabled before starting")
@@ -541,7 +1337,7 @@ func (s *scheduler) startTask(id, source string) []serror.SnapError {
 		}
 	}
 
-	if t.state == core.TaskFiring || t.state == core.TaskSpinning {
+	if state == core.TaskFiring || state == core.TaskSpinning {
 		logger.WithFields(log.Fields{
 			"task-id":    t.ID(),
 			"task-state": t.State(),
@@ -552,7 +1348,7 @@ func (s *scheduler) startTask(id, source string) []serror.SnapError {
 	}
 
 	// Ensure the schedule is valid at this point and time.
-	if err := t.schedule.Validate(); err != nil {
+	if err := t.Schedule().Validate(); err != nil {
 		errs := []serror.SnapError{
 			serror.New(err),
 		}
@@ -561,9 +1357,12 @@ func (s *scheduler) startTask(id, source string) []serror.SnapError {
 		return errs
 	}
 
-	// subscribe plugins to task
-	if _, err := t.SubscribePlugins(); len(err) != 0 {
-		return err
+	// subscribe plugins to task, the plugins of a paused task are still
+	// subscribed
+	if state != core.TaskPaused {
+		if _, err := t.subscribePlugins(ctx); len(err) != 0 {
+			return err
+		}
 	}
 
 	event := &scheduler_event.TaskStartedEvent{
@@ -581,18 +1380,27 @@ func (s *scheduler) startTask(id, source string) []serror.SnapError {
 
 // StopTask provided a task id a task is stopped
 func (s *scheduler) StopTask(id string) []serror.SnapError {
-	return s.stopTask(id, "user")
+	return s.stopTask(context.Background(), id, "user")
+}
+
+// StopTaskWithContext stops a task the same way StopTask does, the context
+// carrying the identity of the caller recorded in the audit log
+func (s *scheduler) StopTaskWithContext(ctx context.Context, id string) []serror.SnapError {
+	return s.stopTask(ctx, id, "user")
 }
 
 func (s *scheduler) StopTaskTribe(id string) []serror.SnapError {
-	return s.stopTask(id, "tribe")
+	return s.stopTask(context.Background(), id, "tribe")
 }
 
-func (s *scheduler) stopTask(id, source string) []serror.SnapError {
+func (s *scheduler) stopTask(ctx context.Context, id, source string) (errs []serror.SnapError) {
 	logger := schedulerLogger.WithFields(log.Fields{
 		"_block": "stop-task",
 		"source": source,
 	})
+	defer func() {
+		s.audit(ctx, core.AuditStopTask, id, source, nil, nil, auditError(errs))
+	}()
 	t, err := s.getTask(id)
 	if err != nil {
 		logger.WithFields(log.Fields{
@@ -604,7 +1412,28 @@ func (s *scheduler) stopTask(id, source string) []serror.SnapError {
 		}
 	}
 
-	switch t.state {
+	t.lifecycleMutex.Lock()
+	defer t.lifecycleMutex.Unlock()
+
+	if err := s.authorize(ctx, core.AuditStopTask, id, source, t); err != nil {
+		return []serror.SnapError{
+			serror.New(err),
+		}
+	}
+	switch t.State() {
+	case core.TaskPaused:
+		// a paused task is not spinning, it is only left to unsubscribe it
+		t.Lock()
+		t.setState(core.TaskStopped)
+		t.Unlock()
+		s.eventManager.Emit(&scheduler_event.TaskStoppedEvent{
+			TaskID: t.ID(),
+			Source: source,
+		})
+		logger.WithFields(log.Fields{
+			"task-id":    t.ID(),
+			"task-state": t.State(),
+		}).Info("paused task stopped")
 	case core.TaskStopped:
 		logger.WithFields(log.Fields{
 			"task-id":    t.ID(),
@@ -640,6 +1469,126 @@ func (s *scheduler) stopTask(id, source string) []serror.SnapError {
 	return nil
 }
 
+// PauseTask stops a running task from firing on its schedule while keeping
+// its plugins subscribed and its workflow in place. StartTask resumes the
+// task without subscribing its plugins again, and StopTask unsubscribes them.
+// Can return errors ErrTaskNotFound, ErrTaskNotPausable and
+// ErrStreamingTaskNotPausable.
+func (s *scheduler) PauseTask(id string) []serror.SnapError {
+	logger := schedulerLogger.WithFields(log.Fields{
+		"_block":  "pause-task",
+		"task-id": id,
+	})
+	t, err := s.getTask(id)
+	if err != nil {
+		logger.WithFields(log.Fields{
+			"_error": err.Error(),
+		}).Error("error pausing task")
+		return []serror.SnapError{
+			serror.New(err),
+		}
+	}
+
+	t.lifecycleMutex.Lock()
+	defer t.lifecycleMutex.Unlock()
+
+	if t.isStream {
+		logger.Error(ErrStreamingTaskNotPausable)
+		return []serror.SnapError{
+			serror.New(ErrStreamingTaskNotPausable),
+		}
+	}
+	if state := t.State(); state != core.TaskSpinning && state != core.TaskFiring {
+		logger.WithFields(log.Fields{
+			"task-state": state,
+		}).Error(ErrTaskNotPausable)
+		return []serror.SnapError{
+			serror.New(ErrTaskNotPausable),
+		}
+	}
+	t.Pause()
+	logger.WithFields(log.Fields{
+		"task-state": t.State(),
+	}).Info("task paused")
+	return nil
+}
+
+// RunTaskNow fires the task once right away regardless of its schedule and
+// blocks until the firing completes or the context is done. The firing only
+// counts in the hit count, failures, durations and run history of the task
+// when record is set, and never moves the next firing of its schedule. A
+// task which is not running is subscribed for the firing only, and cannot be
+// started or stopped until the firing completes.
+// Can return errors ErrSchedulerNotStarted, ErrTaskNotFound,
+// ErrTaskEndedNotRunnable, ErrStreamingTaskNotRunnable, the error of the
+// context or the errors of the firing.
+func (s *scheduler) RunTaskNow(ctx context.Context, id string, record bool) error {
+	logger := schedulerLogger.WithFields(log.Fields{
+		"_block":  "run-task-now",
+		"task-id": id,
+	})
+	if s.getState() != schedulerStarted {
+		logger.Error(ErrSchedulerNotStarted)
+		return ErrSchedulerNotStarted
+	}
+	t, err := s.getTask(id)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	t.lifecycleMutex.Lock()
+	if t.State() == core.TaskEnded {
+		t.lifecycleMutex.Unlock()
+		logger.Error(ErrTaskEndedNotRunnable)
+		return ErrTaskEndedNotRunnable
+	}
+	if t.isStream {
+		t.lifecycleMutex.Unlock()
+		logger.Error(ErrStreamingTaskNotRunnable)
+		return ErrStreamingTaskNotRunnable
+	}
+	subscribed := atomic.LoadInt32(&t.subscribed) == 1
+	if subscribed {
+		t.lifecycleMutex.Unlock()
+	} else if _, errs := t.subscribePlugins(ctx); len(errs) > 0 {
+		t.lifecycleMutex.Unlock()
+		return errs[0]
+	}
+
+	done := make(chan []error, 1)
+	go func() {
+		errs := t.runNow(record)
+		if !subscribed {
+			t.UnsubscribePlugins()
+			t.lifecycleMutex.Unlock()
+		}
+		done <- errs
+	}()
+	select {
+	case errs := <-done:
+		return runError(errs)
+	case <-ctx.Done():
+		logger.WithField("_error", ctx.Err()).Warn("stopped waiting for the task firing")
+		return ctx.Err()
+	}
+}
+
+// runError returns the error of a firing given the errors of its failed jobs
+func runError(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Errorf("%d errors firing the task: %s", len(errs), strings.Join(msgs, "; "))
+}
+
 //EnableTask changes state from disabled to stopped
 func (s *scheduler) EnableTask(id string) (core.Task, error) {
 	t, e := s.getTask(id)
@@ -671,20 +1620,55 @@ func (s *scheduler) EnableTask(id string) (core.Task, error) {
 
 // Start starts the scheduler
 func (s *scheduler) Start() error {
-	if s.metricManager == nil {
+	s.lifecycleMutex.Lock()
+	defer s.lifecycleMutex.Unlock()
+	mm := s.getMetricManager()
+	if mm == nil {
 		schedulerLogger.WithFields(log.Fields{
 			"_block": "start-scheduler",
 			"_error": ErrMetricManagerNotSet.Error(),
 		}).Error("error on scheduler start")
 		return ErrMetricManagerNotSet
 	}
-	s.state = schedulerStarted
+	if err := s.workManager.Start(); err != nil {
+		schedulerLogger.WithFields(log.Fields{
+			"_block": "start-scheduler",
+			"_error": err.Error(),
+		}).Error("error on scheduler start")
+		return err
+	}
+	s.workManager.Resume()
+	s.setState(schedulerStarted)
 	schedulerLogger.WithFields(log.Fields{
 		"_block": "start-scheduler",
 	}).Info("scheduler started")
+	if s.adaptive != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.stopAdaptive = cancel
+		s.adaptiveDone = make(chan struct{})
+		go s.runAdaptiveIntervals(ctx, s.adaptiveDone)
+	}
+
+	if s.elector != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.stopCampaign = cancel
+		s.campaignDone = make(chan struct{})
+		go s.campaign(ctx, mm, s.campaignDone)
+		return nil
+	}
+	s.loadStartupTasks(mm)
+	return nil
+}
+
+// loadStartupTasks restores the tasks kept in the task store and creates the
+// tasks of the auto discover paths
+func (s *scheduler) loadStartupTasks(mm managesMetrics) {
+	// the tasks kept in the task store are restored before the tasks are
+	// autodiscovered, which are not kept in the store
+	s.restoreTasks()
 
 	//Autodiscover
-	autoDiscoverPaths := s.metricManager.GetAutodiscoverPaths()
+	autoDiscoverPaths := mm.GetAutodiscoverPaths()
 	if autoDiscoverPaths != nil && len(autoDiscoverPaths) != 0 {
 		schedulerLogger.WithFields(log.Fields{
 			"_block": "start-scheduler",
@@ -723,38 +1707,298 @@ func (s *scheduler) Start() error {
 				}
 				taskFiles = append(taskFiles, file)
 			}
-			autoDiscoverTasks(taskFiles, fullPath, s.CreateTask)
+			autoDiscoverTasks(taskFiles, fullPath, s.createAutodiscoveredTask)
 		}
 	} else {
 		schedulerLogger.WithFields(log.Fields{
 			"_block": "start-scheduler",
 		}).Info("auto discover path is disabled")
 	}
-
-	return nil
 }
 
+// Stop stops the scheduler with its configured drain policy, waiting up to
+// defaultStopTimeout for the work in flight to drain.
 func (s *scheduler) Stop() {
-	s.state = schedulerStopped
+	ctx, cancel := context.WithTimeout(context.Background(), defaultStopTimeout)
+	defer cancel()
+	s.StopWithContext(ctx)
+}
+
+// StopWithContext stops the scheduler with its configured drain policy, as
+// StopWithPolicy does.
+func (s *scheduler) StopWithContext(ctx context.Context) uint {
+	return s.StopWithPolicy(ctx, s.drainPolicy)
+}
+
+// StopWithPolicy stops the scheduler. With DrainGraceful it blocks until the
+// firings in progress are completed, the metrics buffered by the tasks are
+// flushed and the work queues are drained, or until the context is done.
+// With DrainAbandon the jobs in flight are cancelled right away and the
+// buffered metrics dropped. No new collect jobs are accepted once the
+// scheduler is stopping. The jobs still in flight when the context is done
+// are cancelled, and it returns only once the workers have exited and the
+// plugins of every task are unsubscribed. It returns the number of jobs
+// cancelled. The progress of the stop is returned by DrainProgress
+// meanwhile.
+func (s *scheduler) StopWithPolicy(ctx context.Context, policy DrainPolicy) uint {
+	s.lifecycleMutex.Lock()
+	defer s.lifecycleMutex.Unlock()
+	s.setState(schedulerStopped)
+	if s.stopCampaign != nil {
+		s.stopCampaign()
+		<-s.campaignDone
+		s.stopCampaign = nil
+	}
+	if s.stopAdaptive != nil {
+		s.stopAdaptive()
+		<-s.adaptiveDone
+		s.stopAdaptive = nil
+	}
 	// stop all tasks that are not already stopped
-	for _, t := range s.tasks.table {
-		// Kill ensure another task can't turn it back on while we are shutting down
-		t.Kill()
+	tasks := s.tasks.Table()
+	// only the running and paused tasks are subscribed to their plugins, as
+	// in stopTask; a stopped task may be subscribed by RunTaskNow for the
+	// firing it is running, which unsubscribes it once the firing is done
+	var subscribed []*task
+	for _, t := range tasks {
+		switch t.State() {
+		case core.TaskSpinning, core.TaskFiring, core.TaskPaused:
+			subscribed = append(subscribed, t)
+		}
+	}
+	s.drain.start(policy, len(tasks))
+	// the jobs are cancelled without waiting for the firings in progress
+	// when they are abandoned
+	drainCtx := ctx
+	if policy == DrainAbandon {
+		var cancel context.CancelFunc
+		drainCtx, cancel = context.WithCancel(ctx)
+		cancel()
+		for _, t := range tasks {
+			atomic.StoreInt32(&t.abandoned, 1)
+		}
+	}
+	var wg sync.WaitGroup
+	for _, t := range tasks {
+		wg.Add(1)
+		go func(t *task) {
+			defer wg.Done()
+			// Kill ensure another task can't turn it back on while we are shutting down
+			// it blocks until the in-flight workflow execution of the task is done
+			t.Kill()
+			<-t.stopped()
+			s.drain.taskStopped()
+		}(t)
+	}
+	killed := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(killed)
+	}()
+	select {
+	case <-killed:
+	case <-drainCtx.Done():
+	}
+
+	cancelled := s.workManager.Shutdown(drainCtx)
+	if policy == DrainAbandon {
+		// the firings in progress return once their jobs are cancelled
+		select {
+		case <-killed:
+		case <-ctx.Done():
+		}
+	}
+	if cancelled > 0 {
+		schedulerLogger.WithFields(log.Fields{
+			"_block":         "stop-scheduler",
+			"cancelled-jobs": cancelled,
+		}).Warning("scheduler stopped before the work queues were drained")
+	}
+	// paused tasks are left subscribed by Kill, and the handler of the
+	// events of the killed tasks may not have unsubscribed them yet
+	for _, t := range subscribed {
+		t.UnsubscribePlugins()
+	}
+	if s.elector != nil {
+		// the tasks are left to the next leader, which restores them from
+		// the task store
+		for _, t := range tasks {
+			s.tasks.remove(t)
+		}
+		if atomic.CompareAndSwapInt32(&s.leading, 1, 0) {
+			if err := s.elector.Resign(s.instanceID); err != nil {
+				schedulerLogger.WithFields(log.Fields{
+					"_block": "stop-scheduler",
+					"_error": err.Error(),
+				}).Error("error resigning the leadership")
+			}
+		}
 	}
+	s.drain.end(cancelled)
 	schedulerLogger.WithFields(log.Fields{
-		"_block": "stop-scheduler",
+		"_block":       "stop-scheduler",
+		"drain-policy": policy.String(),
 	}).Info("scheduler stopped")
+	return cancelled
+}
+
+// getState returns the state of the scheduler
+func (s *scheduler) getState() schedulerState {
+	s.stateMutex.RLock()
+	defer s.stateMutex.RUnlock()
+	return s.state
+}
+
+// setState sets the state of the scheduler
+func (s *scheduler) setState(state schedulerState) {
+	s.stateMutex.Lock()
+	defer s.stateMutex.Unlock()
+	s.state = state
+}
+
+// getMetricManager returns the metric manager linked to the scheduler
+func (s *scheduler) getMetricManager() managesMetrics {
+	s.stateMutex.RLock()
+	defer s.stateMutex.RUnlock()
+	return s.metricManager
+}
+
+// SchedulerStats holds the counters of the scheduler work queues and
+// worker pools.
+type SchedulerStats struct {
+	// QueuedJobs is the number of jobs waiting in the work queues
+	QueuedJobs uint
+	// QueueSize is the configured limit of each work queue
+	QueueSize uint
+	// ActiveWorkers is the number of workers currently running a job
+	ActiveWorkers uint
+	// LiveWorkers is the number of workers of all the pools which have not stopped
+	LiveWorkers uint
+	// PoolSize is the configured number of workers of the collect worker pool,
+	// kept for the readers of the stats which predate the per-type pools
+	PoolSize uint
+	// CollectPoolSize is the configured number of workers of the collect pool
+	CollectPoolSize uint
+	// ProcessPoolSize is the configured number of workers of the process pool
+	ProcessPoolSize uint
+	// PublishPoolSize is the configured number of workers of the publish pool
+	PublishPoolSize uint
+	// ProcessedJobs is the total number of jobs run by the workers
+	ProcessedJobs uint64
+	// DroppedJobs is the total number of jobs refused because a queue was
+	// full, the job was overdue or the scheduler was stopping
+	DroppedJobs uint64
+	// PanickedJobs is the total number of jobs which panicked and were failed
+	PanickedJobs uint64
+	// RestartedWorkers is the number of workers replaced after they crashed
+	RestartedWorkers uint64
+	// RetiredWorkers is the number of crashed workers which were not replaced
+	// as too many workers crashed, each shrinking its worker pool by one
+	RetiredWorkers uint64
+	// FailedRuns is the number of failed firings of the tasks
+	FailedRuns uint64
+	// SkippedTicks is the number of schedule ticks the tasks did not fire on
+	// because their previous firings had not finished
+	SkippedTicks uint64
+	// RetriedJobs is the number of times the failed jobs of the tasks were retried
+	RetriedJobs uint64
+	// DroppedEvents is the number of task events dropped for the subscribers
+	// of Events whose buffer was full, including the ones which unsubscribed
+	DroppedEvents uint64
+	// SubscriberDroppedEvents is the number of task events dropped for each
+	// current subscriber, keyed by the channel returned by Events
+	SubscriberDroppedEvents map[<-chan TaskEvent]uint64
+}
+
+// Stats returns the current counters of the work queues and worker pools
+// and the ticks skipped by the tasks
+func (s *scheduler) Stats() SchedulerStats {
+	stats := s.workManager.Stats()
+	for _, t := range s.tasks.Table() {
+		stats.SkippedTicks += t.SkippedCount()
+		stats.RetriedJobs += t.RetriedCount()
+		stats.FailedRuns += uint64(t.FailedCount())
+	}
+	stats.DroppedEvents, stats.SubscriberDroppedEvents = s.events.stats()
+	return stats
+}
+
+// SetPoolSize grows or shrinks the collect, process and publish worker pools
+// to their own number of workers without losing queued jobs. Passing the
+// current size of a pool, as reported by Stats, leaves it as it is.
+// Can return error ErrInvalidPoolSize.
+func (s *scheduler) SetPoolSize(collect, process, publish int) error {
+	if collect <= 0 || process <= 0 || publish <= 0 {
+		return ErrInvalidPoolSize
+	}
+	s.workManager.SetPoolSize(collectJobType, uint(collect))
+	s.workManager.SetPoolSize(processJobType, uint(process))
+	s.workManager.SetPoolSize(publishJobType, uint(publish))
+	schedulerLogger.WithFields(log.Fields{
+		"_block":  "set-pool-size",
+		"collect": collect,
+		"process": process,
+		"publish": publish,
+	}).Info("Setting work manager pool size")
+	return nil
 }
 
 // Set metricManager for scheduler
 func (s *scheduler) SetMetricManager(mm managesMetrics) {
+	s.stateMutex.Lock()
 	s.metricManager = mm
+	s.stateMutex.Unlock()
 	schedulerLogger.WithFields(log.Fields{
 		"_block": "set-metric-manager",
 	}).Debug("metric manager linked")
 }
 
+// Events returns a new channel receiving the lifecycle events of all tasks.
 //
+// Events are lossy under overload: each call returns a distinct channel
+// buffering up to the configured TaskEventBufferSize events, and an event
+// occurring while the buffer of a subscriber is full is dropped for that
+// subscriber only, so the scheduler is never blocked by a slow consumer. The
+// events dropped are counted in the DroppedEvents and SubscriberDroppedEvents
+// of Stats. As events are handled asynchronously they may be received out of
+// order, their Timestamp is the time at which they occurred. A consumer
+// detaches with Unsubscribe.
+func (s *scheduler) Events() <-chan TaskEvent {
+	return s.events.subscribe()
+}
+
+// Unsubscribe closes a channel returned by Events and stops sending it
+// events. The events still buffered can be received until the channel is
+// drained. It does nothing for a channel which is not subscribed.
+func (s *scheduler) Unsubscribe(ch <-chan TaskEvent) {
+	s.events.unsubscribe(ch)
+}
+
+// publishEvent sends a task event to the subscribers of the scheduler
+func (s *scheduler) publishEvent(e gomit.Event, t TaskEventType, taskID string, err error) {
+	s.events.publish(TaskEvent{
+		Type:      t,
+		TaskID:    taskID,
+		Timestamp: e.Header.Time,
+		Err:       err,
+	})
+}
+
+// publishQueueEvent sends the event of a job dropped from the queue to the
+// subscribers
+func (s *scheduler) publishQueueEvent(e gomit.Event, t TaskEventType, taskID, queue string) {
+	s.events.publish(TaskEvent{
+		Type:      t,
+		TaskID:    taskID,
+		Timestamp: e.Header.Time,
+		Queue:     queue,
+	})
+}
+
+// WatchTask calls the handler on the collections and the state changes of a
+// task until the returned closer is closed. A handler which is also a
+// core.TaskRunWatcherHandler catches every run of the task, with the errors
+// of a failed collection and the timing of the collection.
 func (s *scheduler) WatchTask(id string, tw core.TaskWatcherHandler) (core.TaskWatcherCloser, error) {
 	task, err := s.getTask(id)
 	if err != nil {
@@ -781,6 +2025,13 @@ func (s *scheduler) HandleGomitEvent(e gomit.Event) {
 			"metric-count":    len(v.Metrics),
 		}).Debug("event received")
 		s.taskWatcherColl.handleMetricCollected(v.TaskID, v.Metrics)
+		s.triggerDependents(v.TaskID, v.Metrics)
+		s.taskWatcherColl.handleTaskRun(v.TaskID, core.TaskRun{
+			Metrics:  v.Metrics,
+			Start:    v.Start,
+			Duration: v.Duration,
+		})
+		s.publishEvent(e, EventTaskFired, v.TaskID, nil)
 	case *scheduler_event.MetricCollectionFailedEvent:
 		log.WithFields(log.Fields{
 			"_module":         "scheduler-events",
@@ -789,6 +2040,16 @@ func (s *scheduler) HandleGomitEvent(e gomit.Event) {
 			"task-id":         v.TaskID,
 			"errors-count":    v.Errors,
 		}).Debug("event received")
+		s.taskWatcherColl.handleTaskRun(v.TaskID, core.TaskRun{
+			Errors:   v.Errors,
+			Start:    v.Start,
+			Duration: v.Duration,
+		})
+		var err error
+		if len(v.Errors) > 0 {
+			err = v.Errors[len(v.Errors)-1]
+		}
+		s.publishEvent(e, EventTaskFailed, v.TaskID, err)
 	case *scheduler_event.TaskStartedEvent:
 		log.WithFields(log.Fields{
 			"_module":         "scheduler-events",
@@ -797,6 +2058,8 @@ func (s *scheduler) HandleGomitEvent(e gomit.Event) {
 			"task-id":         v.TaskID,
 		}).Debug("event received")
 		s.taskWatcherColl.handleTaskStarted(v.TaskID)
+		s.publishEvent(e, EventTaskStarted, v.TaskID, nil)
+		s.persistTasks()
 	case *scheduler_event.TaskStoppedEvent:
 		log.WithFields(log.Fields{
 			"_module":         "scheduler-events",
@@ -804,10 +2067,23 @@ func (s *scheduler) HandleGomitEvent(e gomit.Event) {
 			"event-namespace": e.Namespace(),
 			"task-id":         v.TaskID,
 		}).Debug("event received")
-		// We need to unsubscribe from deps when a task has stopped
-		task, _ := s.getTask(v.TaskID)
-		task.UnsubscribePlugins()
+		// We need to unsubscribe from deps when a task has stopped.
+		// A removed task has its deps unsubscribed on removal.
+		if task, err := s.getTask(v.TaskID); err == nil {
+			task.UnsubscribePlugins()
+		}
 		s.taskWatcherColl.handleTaskStopped(v.TaskID)
+		s.publishEvent(e, EventTaskStopped, v.TaskID, nil)
+		s.persistTasks()
+	case *scheduler_event.TaskPausedEvent:
+		log.WithFields(log.Fields{
+			"_module":         "scheduler-events",
+			"_block":          "handle-events",
+			"event-namespace": e.Namespace(),
+			"task-id":         v.TaskID,
+		}).Debug("event received")
+		s.publishEvent(e, EventTaskPaused, v.TaskID, nil)
+		s.persistTasks()
 	case *scheduler_event.TaskEndedEvent:
 		log.WithFields(log.Fields{
 			"_module":         "scheduler-events",
@@ -815,10 +2091,14 @@ func (s *scheduler) HandleGomitEvent(e gomit.Event) {
 			"event-namespace": e.Namespace(),
 			"task-id":         v.TaskID,
 		}).Debug("event received")
-		// We need to unsubscribe from deps when a task has ended
-		task, _ := s.getTask(v.TaskID)
-		task.UnsubscribePlugins()
+		// We need to unsubscribe from deps when a task has ended.
+		// A removed task has its deps unsubscribed on removal.
+		if task, err := s.getTask(v.TaskID); err == nil {
+			task.UnsubscribePlugins()
+		}
 		s.taskWatcherColl.handleTaskEnded(v.TaskID)
+		s.publishEvent(e, EventTaskEnded, v.TaskID, nil)
+		s.persistTasks()
 	case *scheduler_event.TaskDisabledEvent:
 		log.WithFields(log.Fields{
 			"_module":         "scheduler-events",
@@ -828,9 +2108,102 @@ func (s *scheduler) HandleGomitEvent(e gomit.Event) {
 			"disabled-reason": v.Why,
 		}).Debug("event received")
 		// We need to unsubscribe from deps when a task goes disabled
-		task, _ := s.getTask(v.TaskID)
-		task.UnsubscribePlugins()
+		if task, err := s.getTask(v.TaskID); err == nil {
+			task.UnsubscribePlugins()
+		}
 		s.taskWatcherColl.handleTaskDisabled(v.TaskID, v.Why)
+		s.publishEvent(e, EventTaskDisabled, v.TaskID, errors.New(v.Why))
+		s.persistTasks()
+	case *scheduler_event.TaskCreatedEvent:
+		log.WithFields(log.Fields{
+			"_module":         "scheduler-events",
+			"_block":          "handle-events",
+			"event-namespace": e.Namespace(),
+			"task-id":         v.TaskID,
+		}).Debug("event received")
+		s.publishEvent(e, EventTaskCreated, v.TaskID, nil)
+		s.persistTasks()
+	case *scheduler_event.TaskDeletedEvent:
+		log.WithFields(log.Fields{
+			"_module":         "scheduler-events",
+			"_block":          "handle-events",
+			"event-namespace": e.Namespace(),
+			"task-id":         v.TaskID,
+		}).Debug("event received")
+		s.publishEvent(e, EventTaskRemoved, v.TaskID, nil)
+		s.persistTasks()
+	case *scheduler_event.BackfillProgressedEvent:
+		log.WithFields(log.Fields{
+			"_module":         "scheduler-events",
+			"_block":          "handle-events",
+			"event-namespace": e.Namespace(),
+			"task-id":         v.TaskID,
+			"window-end":      v.End,
+		}).Debug("event received")
+		// the progress is kept for the backfill to resume from the next
+		// window once restored
+		s.persistTasks()
+	case *scheduler_event.IntervalAdjustedEvent:
+		log.WithFields(log.Fields{
+			"_module":         "scheduler-events",
+			"_block":          "handle-events",
+			"event-namespace": e.Namespace(),
+			"task-id":         v.TaskID,
+			"interval":        v.Interval,
+			"stretch":         v.Stretch,
+		}).Debug("event received")
+		s.events.publish(TaskEvent{
+			Type:      EventIntervalAdjusted,
+			TaskID:    v.TaskID,
+			Timestamp: e.Header.Time,
+			Interval:  v.Interval,
+		})
+	case *scheduler_event.CircuitBreakerChangedEvent:
+		log.WithFields(log.Fields{
+			"_module":         "scheduler-events",
+			"_block":          "handle-events",
+			"event-namespace": e.Namespace(),
+			"task-id":         v.TaskID,
+			"prefix":          v.Prefix,
+			"state":           v.State,
+		}).Debug("event received")
+		t := EventCircuitClosed
+		switch v.State {
+		case CircuitOpen.String():
+			t = EventCircuitOpened
+		case CircuitHalfOpen.String():
+			t = EventCircuitHalfOpen
+		}
+		s.events.publish(TaskEvent{
+			Type:      t,
+			TaskID:    v.TaskID,
+			Timestamp: e.Header.Time,
+			Prefix:    v.Prefix,
+		})
+	case *scheduler_event.JobQueueOverflowedEvent:
+		log.WithFields(log.Fields{
+			"_module":         "scheduler-events",
+			"_block":          "handle-events",
+			"event-namespace": e.Namespace(),
+			"task-id":         v.TaskID,
+			"queue":           v.Queue,
+		}).Debug("event received")
+		if task, err := s.getTask(v.TaskID); err == nil {
+			atomic.AddUint64(&task.droppedJobs, 1)
+		}
+		s.publishQueueEvent(e, EventTaskQueueOverflow, v.TaskID, v.Queue)
+	case *scheduler_event.JobDeadlineMissedEvent:
+		log.WithFields(log.Fields{
+			"_module":         "scheduler-events",
+			"_block":          "handle-events",
+			"event-namespace": e.Namespace(),
+			"task-id":         v.TaskID,
+			"queue":           v.Queue,
+		}).Debug("event received")
+		if task, err := s.getTask(v.TaskID); err == nil {
+			atomic.AddUint64(&task.droppedJobs, 1)
+		}
+		s.publishQueueEvent(e, EventTaskDeadlineMissed, v.TaskID, v.Queue)
 	case *scheduler_event.PluginsUnsubscribedEvent:
 		log.WithFields(log.Fields{
 			"_module":         "scheduler-events",
@@ -869,6 +2242,11 @@ func getWorkflowPlugins(prnodes []*processNode, pbnodes []*publishNode, requeste
 
 func walkWorkflowForDeps(prnodes []*processNode, pbnodes []*publishNode, requestedMetrics []core.RequestedMetric, depGroup depGroupMap) depGroupMap {
 	for _, pr := range prnodes {
+		if pr.builtin != nil {
+			// a built-in step is run by the scheduler, it has no plugin
+			walkWorkflowForDeps(pr.ProcessNodes, pr.PublishNodes, requestedMetrics, depGroup)
+			continue
+		}
 		processors := depGroup[pr.Target]
 		if _, ok := depGroup[pr.Target]; ok {
 			processors.subscribedPlugins = append(processors.subscribedPlugins, pr)
@@ -22,7 +22,16 @@ limitations under the License.
 package scheduler
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -44,6 +53,20 @@ type mockMetricManager struct {
 	failuredSoFar              int
 	autodiscoverPaths          []string
 	timeToWait                 time.Duration
+	// when set, SubscribeDeps succeeds and counts the subscriptions
+	acceptSubscriptions bool
+	subscriptionCount   int32
+	unsubscriptionCount int32
+	// when set, called by ValidateDeps
+	onValidate func()
+	// when set to 1, CollectMetrics fails
+	failCollecting int32
+	// the number of CollectMetrics running and the most of them which ran
+	// at once
+	collecting    int32
+	maxCollecting int32
+	// the metrics returned by CollectMetrics
+	collected []core.Metric
 }
 
 func (m *mockMetricManager) StreamMetrics(string, map[string]map[string]string, time.Duration, int64) (chan []core.Metric, chan error, []error) {
@@ -51,8 +74,18 @@ func (m *mockMetricManager) StreamMetrics(string, map[string]map[string]string,
 }
 
 func (m *mockMetricManager) CollectMetrics(string, map[string]map[string]string) ([]core.Metric, []error) {
+	n := atomic.AddInt32(&m.collecting, 1)
+	defer atomic.AddInt32(&m.collecting, -1)
+	for max := atomic.LoadInt32(&m.maxCollecting); n > max; max = atomic.LoadInt32(&m.maxCollecting) {
+		if atomic.CompareAndSwapInt32(&m.maxCollecting, max, n) {
+			break
+		}
+	}
 	time.Sleep(m.timeToWait)
-	return nil, nil
+	if atomic.LoadInt32(&m.failCollecting) == 1 {
+		return nil, []error{errors.New("collection error")}
+	}
+	return m.collected, nil
 }
 
 func (m *mockMetricManager) PublishMetrics([]core.Metric, map[string]ctypes.ConfigValue, string, string, int) []error {
@@ -64,6 +97,9 @@ func (m *mockMetricManager) ProcessMetrics([]core.Metric, map[string]ctypes.Conf
 }
 
 func (m *mockMetricManager) ValidateDeps(mts []core.RequestedMetric, prs []core.SubscribedPlugin, ctree *cdata.ConfigDataTree, asserts ...core.SubscribedPluginAssert) []serror.SnapError {
+	if m.onValidate != nil {
+		m.onValidate()
+	}
 	if m.failValidatingMetrics {
 		return []serror.SnapError{
 			serror.New(errors.New("metric validation error")),
@@ -72,12 +108,19 @@ func (m *mockMetricManager) ValidateDeps(mts []core.RequestedMetric, prs []core.
 	return nil
 }
 func (m *mockMetricManager) SubscribeDeps(taskID string, reqs []core.RequestedMetric, prs []core.SubscribedPlugin, ctree *cdata.ConfigDataTree) []serror.SnapError {
+	if m.acceptSubscriptions {
+		atomic.AddInt32(&m.subscriptionCount, 1)
+		return nil
+	}
 	return []serror.SnapError{
 		serror.New(errors.New("metric validation error")),
 	}
 }
 
 func (m *mockMetricManager) UnsubscribeDeps(taskID string) []serror.SnapError {
+	if m.acceptSubscriptions {
+		atomic.AddInt32(&m.unsubscriptionCount, 1)
+	}
 	return nil
 }
 
@@ -229,6 +272,60 @@ func TestCreateTask(t *testing.T) {
 			So(errs.Errors(), ShouldBeEmpty)
 			So(tsk, ShouldNotBeNil)
 		})
+	})
+	Convey("Calling CreateTask with a malformed workflow", t, func() {
+		c := &mockMetricManager{acceptSubscriptions: true}
+		s := New(GetDefaultConfig())
+		s.SetMetricManager(c)
+		s.Start()
+		wf := newMockWorkflowMap()
+		wf.Collect.Add(wmap.NewProcessNode("machine", 1))
+		wf.Collect.AddConfigItem("/foo/qux", "user", "root")
+		sch := schedule.NewWindowedSchedule(interval, nil, nil, 0)
+		tsk, errs := s.CreateTask(sch, wf, true)
+		Convey("Should return an error for each offending node before subscribing", func() {
+			So(tsk, ShouldBeNil)
+			So(errs.Errors(), ShouldHaveLength, 2)
+			msgs := []string{errs.Errors()[0].Error(), errs.Errors()[1].Error()}
+			So(msgs, ShouldContain, ErrConfigForUnknownMetric.Error()+": collect.config[/foo/qux]")
+			So(msgs, ShouldContain, ErrOrphanedProcessNode.Error()+": collect.process[1]")
+			So(atomic.LoadInt32(&c.subscriptionCount), ShouldEqual, 0)
+		})
+		s.Stop()
+	})
+	Convey("Calling CreateTask without starting the tasks on creation", t, func() {
+		c := &mockMetricManager{acceptSubscriptions: true}
+		s := New(GetDefaultConfig())
+		s.SetMetricManager(c)
+		s.Start()
+		tasks := []core.Task{}
+		for i := 0; i < 3; i++ {
+			sch := schedule.NewWindowedSchedule(interval, nil, nil, 0)
+			tsk, errs := s.CreateTask(sch, w, false)
+			So(errs.Errors(), ShouldBeEmpty)
+			So(tsk, ShouldNotBeNil)
+			tasks = append(tasks, tsk)
+		}
+		Convey("the tasks should be stopped and not subscribed", func() {
+			for _, tsk := range tasks {
+				So(tsk.State(), ShouldEqual, core.TaskStopped)
+			}
+			So(atomic.LoadInt32(&c.subscriptionCount), ShouldEqual, 0)
+		})
+		Convey("the tasks should be removable", func() {
+			for _, tsk := range tasks {
+				So(s.RemoveTask(tsk.ID()), ShouldBeNil)
+			}
+			So(s.GetTasks(), ShouldBeEmpty)
+			So(atomic.LoadInt32(&c.subscriptionCount), ShouldEqual, 0)
+		})
+		Convey("the tasks should be subscribed once started", func() {
+			for _, tsk := range tasks {
+				So(s.StartTask(tsk.ID()), ShouldBeEmpty)
+			}
+			So(atomic.LoadInt32(&c.subscriptionCount), ShouldEqual, 3)
+		})
+		s.Stop()
 	}) //end of tests for a simple scheduler
 
 	Convey("Calling CreateTask for a windowed schedule", t, func() {
@@ -409,6 +506,22 @@ func TestStopTask(t *testing.T) {
 		})
 	})
 
+	Convey("Calling State on a firing task", t, func() {
+		c.timeToWait = 500 * time.Millisecond
+		sc := schedule.NewWindowedSchedule(time.Second, nil, nil, 0)
+		t, _ := s.CreateTask(sc, w, false)
+		t.(*task).Spin()
+		// allowing things to settle and waiting for task state to change to firing
+		time.Sleep(100 * time.Millisecond)
+		Convey("Should not wait for the workflow execution to finish", func() {
+			begin := time.Now()
+			So(t.State(), ShouldEqual, core.TaskFiring)
+			So(time.Since(begin), ShouldBeLessThan, c.timeToWait/2)
+		})
+		t.(*task).Stop()
+		c.timeToWait = 0
+	})
+
 	Convey("Calling StopTask on a stopped task", t, func() {
 		sch := schedule.NewWindowedSchedule(interval, nil, nil, 0)
 		tskStopped, _ := s.CreateTask(sch, w, false)
@@ -514,6 +627,43 @@ func TestStartTask(t *testing.T) {
 
 		task.Stop()
 	})
+	Convey("Calling StartTask concurrently on a stopped task", t, func() {
+		c := &mockMetricManager{acceptSubscriptions: true}
+		s := New(GetDefaultConfig())
+		s.SetMetricManager(c)
+		s.Start()
+		sch := schedule.NewWindowedSchedule(time.Second, nil, nil, 0)
+		tsk, _ := s.CreateTask(sch, w, false)
+		So(tsk, ShouldNotBeNil)
+
+		var wg sync.WaitGroup
+		results := make(chan []serror.SnapError, 10)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results <- s.StartTask(tsk.ID())
+			}()
+		}
+		wg.Wait()
+		close(results)
+
+		started := 0
+		for errs := range results {
+			if errs == nil {
+				started++
+				continue
+			}
+			So(errs[0].Error(), ShouldEqual, ErrTaskAlreadyRunning.Error())
+		}
+		Convey("Only one call should start the task", func() {
+			So(started, ShouldEqual, 1)
+		})
+		Convey("Plugins should be subscribed only once", func() {
+			So(atomic.LoadInt32(&c.subscriptionCount), ShouldEqual, 1)
+		})
+		s.Stop()
+	})
 	Convey("Calling StartTask on a disabled task", t, func() {
 		sch := schedule.NewWindowedSchedule(interval, nil, nil, 0)
 		tskDisabled, _ := s.CreateTask(sch, w, false)
@@ -668,3 +818,1792 @@ func TestEnableTask(t *testing.T) {
 
 	s.Stop()
 }
+
+func TestRemoveTask(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	c := new(mockMetricManager)
+	cfg := GetDefaultConfig()
+	s := New(cfg)
+	s.SetMetricManager(c)
+	w := newMockWorkflowMap()
+	s.Start()
+
+	Convey("Calling RemoveTask on an unknown task", t, func() {
+		err := s.RemoveTask("unknown")
+		Convey("Should return an error", func() {
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, ErrTaskNotFound.Error())
+		})
+	})
+	Convey("Calling RemoveTask on a stopped task", t, func() {
+		sch := schedule.NewWindowedSchedule(interval, nil, nil, 0)
+		tsk, _ := s.CreateTask(sch, w, false)
+		So(tsk, ShouldNotBeNil)
+
+		err := s.RemoveTask(tsk.ID())
+		Convey("Should not return an error", func() {
+			So(err, ShouldBeNil)
+		})
+		Convey("The task should not be found anymore", func() {
+			_, err := s.GetTask(tsk.ID())
+			So(err, ShouldNotBeNil)
+		})
+	})
+	Convey("Calling RemoveTask on a running task", t, func() {
+		sch := schedule.NewWindowedSchedule(interval, nil, nil, 0)
+		tsk, _ := s.CreateTask(sch, w, false)
+		So(tsk, ShouldNotBeNil)
+		task := s.tasks.Get(tsk.ID())
+		task.Spin()
+
+		err := s.RemoveTask(tsk.ID())
+		Convey("Should return an error", func() {
+			So(err, ShouldEqual, ErrTaskNotStopped)
+		})
+		task.Stop()
+	})
+	Convey("Calling RemoveTask on a stopping task", t, func() {
+		c.timeToWait = 300 * time.Millisecond
+		sch := schedule.NewWindowedSchedule(time.Second, nil, nil, 0)
+		tsk, _ := s.CreateTask(sch, w, false)
+		So(tsk, ShouldNotBeNil)
+		task := s.tasks.Get(tsk.ID())
+		task.Spin()
+		// wait for the task to be firing
+		time.Sleep(100 * time.Millisecond)
+		So(task.State(), ShouldEqual, core.TaskFiring)
+
+		task.Stop()
+		err := s.RemoveTask(tsk.ID())
+		Convey("Should wait for the last workflow execution and not return an error", func() {
+			So(err, ShouldBeNil)
+			So(task.State(), ShouldEqual, core.TaskStopped)
+			So(task.HitCount(), ShouldEqual, 1)
+		})
+		c.timeToWait = 0
+	})
+
+	s.Stop()
+}
+
+func TestDeleteAllTasks(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	Convey("Calling DeleteAllTasks", t, func() {
+		c := &mockMetricManager{acceptSubscriptions: true, timeToWait: 100 * time.Millisecond}
+		s := New(GetDefaultConfig())
+		s.SetMetricManager(c)
+		s.Start()
+		w := newMockWorkflowMap()
+		for i := 0; i < 3; i++ {
+			sch := schedule.NewWindowedSchedule(interval, nil, nil, 0)
+			// the first task is left stopped
+			_, errs := s.CreateTask(sch, w, i > 0)
+			So(errs.Errors(), ShouldBeEmpty)
+		}
+		So(atomic.LoadInt32(&c.subscriptionCount), ShouldEqual, 2)
+		// wait for the running tasks to be firing
+		time.Sleep(50 * time.Millisecond)
+
+		errs := s.DeleteAllTasks()
+		Convey("Should remove every task", func() {
+			So(errs, ShouldBeEmpty)
+			So(s.GetTasks(), ShouldBeEmpty)
+		})
+		Convey("Should unsubscribe the running tasks", func() {
+			So(atomic.LoadInt32(&c.unsubscriptionCount), ShouldEqual, 2)
+		})
+		Convey("Should leave no work of the tasks to the workers", func() {
+			stats := s.Stats()
+			So(stats.QueuedJobs, ShouldEqual, 0)
+			So(stats.ActiveWorkers, ShouldEqual, 0)
+		})
+		s.Stop()
+	})
+}
+
+func TestPauseTask(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	Convey("Calling PauseTask", t, func() {
+		c := &mockMetricManager{acceptSubscriptions: true}
+		s := New(GetDefaultConfig())
+		s.SetMetricManager(c)
+		s.Start()
+		w := newMockWorkflowMap()
+		sch := schedule.NewWindowedSchedule(interval, nil, nil, 0)
+		tsk, errs := s.CreateTask(sch, w, false)
+		So(errs.Errors(), ShouldBeEmpty)
+		awaitState := func(state core.TaskState) bool {
+			for i := 0; i < 100; i++ {
+				if tsk.State() == state {
+					return true
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			return false
+		}
+
+		Convey("Should refuse to pause a task which is not running", func() {
+			perrs := s.PauseTask(tsk.ID())
+			So(perrs, ShouldHaveLength, 1)
+			So(perrs[0].Error(), ShouldEqual, ErrTaskNotPausable.Error())
+		})
+		Convey("Should pause a running task without unsubscribing it", func() {
+			So(s.StartTask(tsk.ID()), ShouldBeEmpty)
+			So(s.PauseTask(tsk.ID()), ShouldBeEmpty)
+			So(awaitState(core.TaskPaused), ShouldBeTrue)
+			So(tsk.State().String(), ShouldEqual, "Paused")
+			hits := tsk.HitCount()
+			time.Sleep(3 * interval)
+			So(tsk.HitCount(), ShouldEqual, hits)
+			So(atomic.LoadInt32(&c.subscriptionCount), ShouldEqual, 1)
+			So(atomic.LoadInt32(&c.unsubscriptionCount), ShouldEqual, 0)
+
+			Convey("and resume it on StartTask without subscribing it again", func() {
+				So(s.StartTask(tsk.ID()), ShouldBeEmpty)
+				So(tsk.State(), ShouldBeIn, core.TaskSpinning, core.TaskFiring)
+				So(atomic.LoadInt32(&c.subscriptionCount), ShouldEqual, 1)
+				So(s.StopTask(tsk.ID()), ShouldBeEmpty)
+			})
+			Convey("and unsubscribe it on StopTask", func() {
+				So(s.StopTask(tsk.ID()), ShouldBeEmpty)
+				So(tsk.State(), ShouldEqual, core.TaskStopped)
+				for i := 0; i < 100 && atomic.LoadInt32(&c.unsubscriptionCount) == 0; i++ {
+					time.Sleep(10 * time.Millisecond)
+				}
+				So(atomic.LoadInt32(&c.unsubscriptionCount), ShouldEqual, 1)
+			})
+			Convey("and remove it", func() {
+				So(s.RemoveTask(tsk.ID()), ShouldBeNil)
+				So(atomic.LoadInt32(&c.unsubscriptionCount), ShouldEqual, 1)
+			})
+		})
+		s.Stop()
+	})
+}
+
+func TestBulkTaskOperations(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	Convey("Calling the bulk task operations", t, func() {
+		c := &mockMetricManager{acceptSubscriptions: true}
+		s := New(GetDefaultConfig())
+		s.SetMetricManager(c)
+		s.Start()
+		w := newMockWorkflowMap()
+		var ids []string
+		for i := 0; i < 3; i++ {
+			tsk, errs := s.CreateTask(schedule.NewWindowedSchedule(interval, nil, nil, 0), w, false)
+			So(errs.Errors(), ShouldBeEmpty)
+			ids = append(ids, tsk.ID())
+		}
+		state := func(id string) core.TaskState {
+			tsk, err := s.GetTask(id)
+			So(err, ShouldBeNil)
+			return tsk.State()
+		}
+		awaitState := func(id string, st core.TaskState) bool {
+			for i := 0; i < 100; i++ {
+				if state(id) == st {
+					return true
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			return false
+		}
+
+		Convey("StartTasks should start every task it can", func() {
+			errs := s.StartTasks([]string{ids[0], "missing", ids[1]}, false)
+			So(errs.Errors(), ShouldHaveLength, 1)
+			So(errs.Errors()[0].Error(), ShouldContainSubstring, ErrTaskNotFound.Error())
+			So(errs.Errors()[0].Fields()["task-id"], ShouldEqual, "missing")
+			So(state(ids[0]), ShouldBeIn, core.TaskSpinning, core.TaskFiring)
+			So(state(ids[1]), ShouldBeIn, core.TaskSpinning, core.TaskFiring)
+			So(state(ids[2]), ShouldEqual, core.TaskStopped)
+		})
+		Convey("A transactional StartTasks should roll back the started tasks", func() {
+			So(s.StartTask(ids[2]), ShouldBeEmpty)
+			So(s.PauseTask(ids[2]), ShouldBeEmpty)
+			So(awaitState(ids[2], core.TaskPaused), ShouldBeTrue)
+
+			errs := s.StartTasks([]string{ids[0], ids[2], "missing", ids[1]}, true)
+			So(errs.Errors(), ShouldHaveLength, 1)
+			So(errs.Errors()[0].Fields()["task-id"], ShouldEqual, "missing")
+			So(awaitState(ids[0], core.TaskStopped), ShouldBeTrue)
+			So(state(ids[1]), ShouldEqual, core.TaskStopped)
+			So(awaitState(ids[2], core.TaskPaused), ShouldBeTrue)
+			So(atomic.LoadInt32(&c.subscriptionCount)-atomic.LoadInt32(&c.unsubscriptionCount), ShouldEqual, 1)
+		})
+		Convey("StopTasks should stop every task it can", func() {
+			So(s.StartTasks(ids[:2], false).Errors(), ShouldBeEmpty)
+			errs := s.StopTasks(ids, false)
+			So(errs.Errors(), ShouldHaveLength, 1)
+			So(errs.Errors()[0].Error(), ShouldEqual, ErrTaskAlreadyStopped.Error())
+			So(errs.Errors()[0].Fields()["task-id"], ShouldEqual, ids[2])
+			So(awaitState(ids[0], core.TaskStopped), ShouldBeTrue)
+			So(awaitState(ids[1], core.TaskStopped), ShouldBeTrue)
+		})
+		Convey("A transactional StopTasks should stop no task if one cannot be stopped", func() {
+			So(s.StartTasks(ids[:2], false).Errors(), ShouldBeEmpty)
+			errs := s.StopTasks(ids, true)
+			So(errs.Errors(), ShouldHaveLength, 1)
+			So(errs.Errors()[0].Fields()["task-id"], ShouldEqual, ids[2])
+			So(state(ids[0]), ShouldBeIn, core.TaskSpinning, core.TaskFiring)
+			So(state(ids[1]), ShouldBeIn, core.TaskSpinning, core.TaskFiring)
+			So(s.StopTasks(ids[:2], true).Errors(), ShouldBeEmpty)
+		})
+		Convey("RemoveTasks should remove every task it can", func() {
+			So(s.StartTask(ids[1]), ShouldBeEmpty)
+			errs := s.RemoveTasks(ids, false)
+			So(errs.Errors(), ShouldHaveLength, 1)
+			So(errs.Errors()[0].Error(), ShouldEqual, ErrTaskNotStopped.Error())
+			So(errs.Errors()[0].Fields()["task-id"], ShouldEqual, ids[1])
+			So(s.GetTasks(), ShouldHaveLength, 1)
+			So(s.StopTask(ids[1]), ShouldBeEmpty)
+		})
+		Convey("A transactional RemoveTasks should remove no task if one cannot be removed", func() {
+			So(s.StartTask(ids[1]), ShouldBeEmpty)
+			errs := s.RemoveTasks(ids, true)
+			So(errs.Errors(), ShouldHaveLength, 1)
+			So(errs.Errors()[0].Fields()["task-id"], ShouldEqual, ids[1])
+			So(s.GetTasks(), ShouldHaveLength, 3)
+			So(s.StopTask(ids[1]), ShouldBeEmpty)
+			So(s.RemoveTasks(ids, true).Errors(), ShouldBeEmpty)
+			So(s.GetTasks(), ShouldBeEmpty)
+		})
+		s.Stop()
+	})
+}
+
+func TestStopScheduler(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	w := newMockWorkflowMap()
+
+	Convey("Calling StopWithContext on a scheduler with a firing task", t, func() {
+		c := &mockMetricManager{acceptSubscriptions: true, timeToWait: 300 * time.Millisecond}
+		s := New(GetDefaultConfig())
+		s.SetMetricManager(c)
+		s.Start()
+		sch := schedule.NewWindowedSchedule(time.Second, nil, nil, 0)
+		tsk, _ := s.CreateTask(sch, w, true)
+		So(tsk, ShouldNotBeNil)
+		// allowing things to settle and waiting for task state to change to firing
+		time.Sleep(100 * time.Millisecond)
+		So(tsk.State(), ShouldEqual, core.TaskFiring)
+
+		Convey("Should wait for the workflow execution to finish", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			abandoned := s.StopWithContext(ctx)
+			So(abandoned, ShouldEqual, 0)
+			So(tsk.HitCount(), ShouldEqual, 1)
+			// the killed task is done spinning once the scheduler is stopped
+			So(tsk.State(), ShouldEqual, core.TaskStopped)
+		})
+		Convey("Should return the abandoned jobs when the context expires", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			abandoned := s.StopWithContext(ctx)
+			So(abandoned, ShouldEqual, 1)
+			So(s.workManager.Stats().LiveWorkers, ShouldEqual, 0)
+		})
+		Convey("Should report the progress of the drain", func() {
+			So(s.DrainProgress().Done, ShouldBeFalse)
+			done := make(chan uint)
+			go func() { done <- s.StopWithContext(context.Background()) }()
+			time.Sleep(50 * time.Millisecond)
+			p := s.DrainProgress()
+			So(p.Draining, ShouldBeTrue)
+			So(p.Policy, ShouldEqual, DrainGraceful)
+			So(p.Tasks, ShouldEqual, 1)
+			So(p.StoppedTasks, ShouldEqual, 0)
+			So(p.InFlightJobs, ShouldEqual, 1)
+			So(<-done, ShouldEqual, 0)
+			p = s.DrainProgress()
+			So(p.Draining, ShouldBeFalse)
+			So(p.Done, ShouldBeTrue)
+			So(p.StoppedTasks, ShouldEqual, 1)
+			So(p.InFlightJobs, ShouldEqual, 0)
+		})
+		Convey("Should cancel the jobs in flight when abandoning them", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			start := time.Now()
+			abandoned := s.StopWithPolicy(ctx, DrainAbandon)
+			So(abandoned, ShouldEqual, 1)
+			So(time.Since(start), ShouldBeLessThan, 150*time.Millisecond)
+			So(tsk.State(), ShouldEqual, core.TaskStopped)
+			p := s.DrainProgress()
+			So(p.Policy, ShouldEqual, DrainAbandon)
+			So(p.CancelledJobs, ShouldEqual, 1)
+			So(atomic.LoadInt32(&c.unsubscriptionCount), ShouldEqual, 1)
+		})
+		Convey("Should unsubscribe the plugins of every task", func() {
+			s.StopWithContext(context.Background())
+			So(atomic.LoadInt32(&c.unsubscriptionCount), ShouldEqual, 1)
+		})
+		Convey("Should leave the plugins of a stopped task run once subscribed", func() {
+			stopped, _ := s.CreateTask(sch, w, false)
+			So(stopped, ShouldNotBeNil)
+			// RunTaskNow subscribes a stopped task for the firing it runs
+			atomic.StoreInt32(&stopped.(*task).subscribed, 1)
+			s.StopWithContext(context.Background())
+			So(atomic.LoadInt32(&c.unsubscriptionCount), ShouldEqual, 1)
+			So(atomic.LoadInt32(&stopped.(*task).subscribed), ShouldEqual, 1)
+		})
+		Convey("Should not accept collect jobs until started again", func() {
+			s.StopWithContext(context.Background())
+			errs := s.workManager.Work(newCollectorJob(nil, time.Second, c, nil, "", nil)).Promise().Await()
+			So(errs, ShouldNotBeEmpty)
+			So(errs[0], ShouldEqual, errWorkManagerDraining)
+			s.Start()
+			So(s.workManager.draining, ShouldBeFalse)
+			s.Stop()
+		})
+	})
+}
+
+// TestSchedulerConcurrentUse is meant to be run with the race detector, it
+// calls the public methods of the scheduler the way concurrent API requests
+// would.
+func TestSchedulerConcurrentUse(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	Convey("Calling the scheduler concurrently", t, func() {
+		c := &mockMetricManager{acceptSubscriptions: true}
+		s := New(GetDefaultConfig())
+		s.SetMetricManager(c)
+		So(s.Start(), ShouldBeNil)
+
+		var wg sync.WaitGroup
+		run := func(f func()) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 10; i++ {
+					f()
+				}
+			}()
+		}
+		run(func() {
+			sch := schedule.NewWindowedSchedule(time.Millisecond*10, nil, nil, 0)
+			if tsk, errs := s.CreateTask(sch, newMockWorkflowMap(), true); len(errs.Errors()) == 0 {
+				s.StopTask(tsk.ID())
+			}
+		})
+		run(func() {
+			s.Stop()
+			s.Start()
+		})
+		run(func() { s.SetMetricManager(c) })
+		run(func() {
+			s.Health()
+			s.Stats()
+			s.ListTasks(TaskFilter{})
+			for id := range s.GetTasks() {
+				s.GetTask(id)
+			}
+		})
+		wg.Wait()
+
+		Convey("Should leave it usable", func() {
+			So(s.Start(), ShouldBeNil)
+			So(s.Health().Err(), ShouldBeNil)
+			s.Stop()
+		})
+	})
+}
+
+func TestSetPoolSize(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	s := New(GetDefaultConfig())
+	Convey("Calling SetPoolSize with a size lower than 1", t, func() {
+		So(s.SetPoolSize(0, 1, 1), ShouldEqual, ErrInvalidPoolSize)
+		So(s.SetPoolSize(1, -1, 1), ShouldEqual, ErrInvalidPoolSize)
+		So(s.workManager.collectWkrSize, ShouldEqual, defaultWorkManagerPoolSize)
+	})
+	Convey("Calling SetPoolSize concurrently", t, func() {
+		var wg sync.WaitGroup
+		for i := 1; i <= 8; i++ {
+			wg.Add(1)
+			go func(n int) {
+				defer wg.Done()
+				s.SetPoolSize(n, n, n)
+			}(i)
+		}
+		wg.Wait()
+		Convey("Should leave the pools consistent with their size", func() {
+			So(len(s.workManager.collectWkrs), ShouldEqual, s.workManager.collectWkrSize)
+			So(len(s.workManager.processWkrs), ShouldEqual, s.workManager.processWkrSize)
+			So(len(s.workManager.publishWkrs), ShouldEqual, s.workManager.publishWkrSize)
+		})
+	})
+	Convey("Calling SetPoolSize with the current sizes of the other pools", t, func() {
+		So(s.SetPoolSize(2, 3, 4), ShouldBeNil)
+		stats := s.Stats()
+		So(s.SetPoolSize(int(stats.CollectPoolSize), 1, int(stats.PublishPoolSize)), ShouldBeNil)
+		Convey("Should resize only the given pool", func() {
+			stats := s.Stats()
+			So(stats.CollectPoolSize, ShouldEqual, 2)
+			So(stats.ProcessPoolSize, ShouldEqual, 1)
+			So(stats.PublishPoolSize, ShouldEqual, 4)
+		})
+	})
+}
+
+func TestEvents(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	c := &mockMetricManager{acceptSubscriptions: true}
+	s := New(GetDefaultConfig())
+	s.SetMetricManager(c)
+	s.Start()
+	w := newMockWorkflowMap()
+	// awaitEvent waits for the next event of the given type on the channel
+	awaitEvent := func(ch <-chan TaskEvent, et TaskEventType) (TaskEvent, bool) {
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case e := <-ch:
+				if e.Type == et {
+					return e, true
+				}
+			case <-timeout:
+				return TaskEvent{}, false
+			}
+		}
+	}
+
+	Convey("Calling Events on a scheduler", t, func() {
+		ch1 := s.Events()
+		ch2 := s.Events()
+		sch := schedule.NewWindowedSchedule(interval, nil, nil, 0)
+		tsk, _ := s.CreateTask(sch, w, false)
+		So(tsk, ShouldNotBeNil)
+
+		Convey("Should send the lifecycle events of a task to every subscriber", func() {
+			for _, ch := range []<-chan TaskEvent{ch1, ch2} {
+				e, ok := awaitEvent(ch, EventTaskCreated)
+				So(ok, ShouldBeTrue)
+				So(e.TaskID, ShouldEqual, tsk.ID())
+				So(e.Timestamp.IsZero(), ShouldBeFalse)
+			}
+			So(s.StartTask(tsk.ID()), ShouldBeEmpty)
+			_, ok := awaitEvent(ch1, EventTaskStarted)
+			So(ok, ShouldBeTrue)
+			_, ok = awaitEvent(ch1, EventTaskFired)
+			So(ok, ShouldBeTrue)
+			So(s.StopTask(tsk.ID()), ShouldBeEmpty)
+			_, ok = awaitEvent(ch1, EventTaskStopped)
+			So(ok, ShouldBeTrue)
+			So(s.RemoveTask(tsk.ID()), ShouldBeNil)
+			e, ok := awaitEvent(ch1, EventTaskRemoved)
+			So(ok, ShouldBeTrue)
+			So(e.TaskID, ShouldEqual, tsk.ID())
+		})
+		Convey("Should send the deadline missed events of a task", func() {
+			sch := schedule.NewWindowedSchedule(interval, nil, nil, 0)
+			overdue, _ := s.CreateTask(sch, w, true, core.TaskDeadlineDuration(time.Nanosecond))
+			So(overdue, ShouldNotBeNil)
+			e, ok := awaitEvent(ch1, EventTaskDeadlineMissed)
+			So(ok, ShouldBeTrue)
+			So(e.TaskID, ShouldEqual, overdue.ID())
+			So(e.Queue, ShouldEqual, "collect")
+			So(s.tasks.Get(overdue.ID()).DroppedCount(), ShouldBeGreaterThan, 0)
+			_, ok = awaitEvent(ch1, EventTaskFailed)
+			So(ok, ShouldBeTrue)
+			So(s.StopTask(overdue.ID()), ShouldBeEmpty)
+			_, ok = awaitEvent(ch1, EventTaskStopped)
+			So(ok, ShouldBeTrue)
+			So(s.RemoveTask(overdue.ID()), ShouldBeNil)
+			So(s.RemoveTask(tsk.ID()), ShouldBeNil)
+		})
+		Convey("Should end a task once it reached its maximum number of runs", func() {
+			sch := schedule.NewWindowedSchedule(interval, nil, nil, 0)
+			limited, _ := s.CreateTask(sch, w, true, core.OptionMaxRuns(3))
+			So(limited, ShouldNotBeNil)
+			unsubscriptions := atomic.LoadInt32(&c.unsubscriptionCount)
+			e, ok := awaitEvent(ch1, EventTaskEnded)
+			So(ok, ShouldBeTrue)
+			So(e.TaskID, ShouldEqual, limited.ID())
+			So(limited.State(), ShouldEqual, core.TaskEnded)
+			So(limited.HitCount(), ShouldEqual, 3)
+			So(atomic.LoadInt32(&c.unsubscriptionCount), ShouldBeGreaterThan, unsubscriptions)
+			So(s.RemoveTask(limited.ID()), ShouldBeNil)
+			So(s.RemoveTask(tsk.ID()), ShouldBeNil)
+		})
+		Convey("Should close the channel of a subscriber calling Unsubscribe", func() {
+			s.Unsubscribe(ch1)
+			for range ch1 {
+			}
+			_, ok := <-ch1
+			So(ok, ShouldBeFalse)
+			So(s.RemoveTask(tsk.ID()), ShouldBeNil)
+			_, ok = awaitEvent(ch2, EventTaskRemoved)
+			So(ok, ShouldBeTrue)
+		})
+		s.Unsubscribe(ch1)
+		s.Unsubscribe(ch2)
+	})
+	s.Stop()
+}
+
+func TestTaskStats(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	s := New(GetDefaultConfig())
+	s.SetMetricManager(&mockMetricManager{acceptSubscriptions: true, timeToWait: time.Millisecond})
+	s.Start()
+	w := newMockWorkflowMap()
+
+	Convey("Calling Stats on a task", t, func() {
+		sch := schedule.NewWindowedSchedule(interval, nil, nil, 0)
+		tsk, _ := s.CreateTask(sch, w, false, core.OptionMaxRuns(3))
+		So(tsk, ShouldNotBeNil)
+		So(tsk.Stats().Collect.Count, ShouldEqual, 0)
+		So(s.StartTask(tsk.ID()), ShouldBeEmpty)
+		for tsk.State() != core.TaskEnded {
+			time.Sleep(interval)
+		}
+		Convey("Should return its run counters and the latencies of its jobs", func() {
+			stats := tsk.Stats()
+			So(stats.HitCount, ShouldEqual, 3)
+			So(stats.FailedCount, ShouldEqual, 0)
+			So(stats.LastRunTime.IsZero(), ShouldBeFalse)
+			So(stats.Collect.Count, ShouldEqual, 3)
+			So(stats.Collect.P50, ShouldBeGreaterThanOrEqualTo, time.Millisecond)
+			So(stats.Collect.P99, ShouldBeGreaterThanOrEqualTo, stats.Collect.P50)
+			// each firing runs two process and two publish jobs
+			So(stats.Process.Count, ShouldEqual, 6)
+			So(stats.Publish.Count, ShouldEqual, 6)
+		})
+		So(s.RemoveTask(tsk.ID()), ShouldBeNil)
+	})
+	s.Stop()
+}
+
+func TestValidateTask(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	c := &mockMetricManager{acceptSubscriptions: true}
+	s := New(GetDefaultConfig())
+	s.SetMetricManager(c)
+	s.Start()
+	w := newMockWorkflowMap()
+
+	Convey("Calling ValidateTask for a valid task", t, func() {
+		sch := schedule.NewWindowedSchedule(interval, nil, nil, 0)
+		errs := s.ValidateTask(sch, w)
+		Convey("Should not return an error", func() {
+			So(errs, ShouldBeNil)
+		})
+		Convey("Should neither create the task nor subscribe to its plugins", func() {
+			So(s.GetTasks(), ShouldBeEmpty)
+			So(atomic.LoadInt32(&c.subscriptionCount), ShouldEqual, 0)
+		})
+	})
+	Convey("Calling ValidateTask with an invalid schedule", t, func() {
+		sch := schedule.NewWindowedSchedule(0, nil, nil, 0)
+		errs := s.ValidateTask(sch, w)
+		Convey("Should return the schedule error", func() {
+			So(errs, ShouldNotBeNil)
+			So(errs.Errors()[0].Error(), ShouldEqual, schedule.ErrInvalidInterval.Error())
+		})
+		Convey("Should attribute the error to the validation of the schedule", func() {
+			phase, code, _ := core.TaskErrorAttribution(errs.Errors()[0])
+			So(phase, ShouldEqual, core.TaskPhaseValidation)
+			So(code, ShouldEqual, core.TaskErrorInvalidSchedule)
+			So(errs.Error(), ShouldEqual, schedule.ErrInvalidInterval.Error())
+		})
+	})
+	Convey("Calling ValidateTask with metrics which fail to validate", t, func() {
+		c.failValidatingMetrics = true
+		sch := schedule.NewWindowedSchedule(interval, nil, nil, 0)
+		errs := s.ValidateTask(sch, w)
+		Convey("Should return the validation errors", func() {
+			So(errs, ShouldNotBeNil)
+			So(errs.Errors()[0].Error(), ShouldEqual, "metric validation error")
+			So(s.GetTasks(), ShouldBeEmpty)
+		})
+		Convey("Should attribute the errors to the validation of the dependencies", func() {
+			phase, code, _ := core.TaskErrorAttribution(errs.Errors()[0])
+			So(phase, ShouldEqual, core.TaskPhaseDependencies)
+			So(code, ShouldEqual, core.TaskErrorInvalidDependency)
+		})
+		c.failValidatingMetrics = false
+	})
+	Convey("Calling ValidateTaskCollect for a valid task", t, func() {
+		sch := schedule.NewWindowedSchedule(interval, nil, nil, 0)
+		subscribed := atomic.LoadInt32(&c.subscriptionCount)
+		unsubscribed := atomic.LoadInt32(&c.unsubscriptionCount)
+		_, errs := s.ValidateTaskCollect(context.Background(), sch, w)
+		Convey("Should collect the metrics without creating the task", func() {
+			So(errs, ShouldBeNil)
+			So(s.GetTasks(), ShouldBeEmpty)
+		})
+		Convey("Should subscribe the collectors for the collection only", func() {
+			So(atomic.LoadInt32(&c.subscriptionCount), ShouldEqual, subscribed+1)
+			So(atomic.LoadInt32(&c.unsubscriptionCount), ShouldEqual, unsubscribed+1)
+		})
+	})
+	Convey("Calling ValidateTaskCollect for an invalid task", t, func() {
+		c.failValidatingMetrics = true
+		subscribed := atomic.LoadInt32(&c.subscriptionCount)
+		sch := schedule.NewWindowedSchedule(interval, nil, nil, 0)
+		_, errs := s.ValidateTaskCollect(context.Background(), sch, w)
+		Convey("Should return the validation errors without collecting", func() {
+			So(errs, ShouldNotBeNil)
+			So(errs.Errors()[0].Error(), ShouldEqual, "metric validation error")
+			So(atomic.LoadInt32(&c.subscriptionCount), ShouldEqual, subscribed)
+		})
+		c.failValidatingMetrics = false
+	})
+	Convey("Calling ValidateTaskCollect with a collection outliving the context", t, func() {
+		c.timeToWait = time.Millisecond * 200
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+		defer cancel()
+		sch := schedule.NewWindowedSchedule(interval, nil, nil, 0)
+		_, errs := s.ValidateTaskCollect(ctx, sch, w)
+		Convey("Should return the error of the context", func() {
+			So(errs, ShouldNotBeNil)
+			So(errs.Errors()[0].Error(), ShouldEqual, context.DeadlineExceeded.Error())
+		})
+		c.timeToWait = 0
+	})
+	s.Stop()
+}
+
+func TestGetTaskByName(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	s := newScheduler()
+	s.Start()
+	w := newMockWorkflowMap()
+	sch := schedule.NewWindowedSchedule(interval, nil, nil, 0)
+	tsk, _ := s.CreateTask(sch, w, false, core.SetTaskName("named-task"))
+
+	Convey("Calling GetTaskByName with the name of a task", t, func() {
+		So(tsk, ShouldNotBeNil)
+		found, err := s.GetTaskByName("named-task")
+		Convey("Should return the task", func() {
+			So(err, ShouldBeNil)
+			So(found.ID(), ShouldEqual, tsk.ID())
+		})
+	})
+	Convey("Calling GetTaskByName with an unknown name", t, func() {
+		found, err := s.GetTaskByName("unknown")
+		Convey("Should return an error", func() {
+			So(found, ShouldBeNil)
+			So(err.Error(), ShouldContainSubstring, ErrTaskNotFound.Error())
+		})
+	})
+	Convey("Calling CreateTask with the name of an existing task", t, func() {
+		tsk2, errs := s.CreateTask(sch, w, false, core.SetTaskName("named-task"))
+		Convey("Should create the task", func() {
+			So(errs.Errors(), ShouldBeEmpty)
+			So(tsk2, ShouldNotBeNil)
+			So(s.GetTasks(), ShouldHaveLength, 2)
+			found, err := s.GetTaskByName("named-task")
+			So(err, ShouldBeNil)
+			So(found.ID(), ShouldEqual, tsk.ID())
+		})
+		s.RemoveTask(tsk2.ID())
+	})
+	s.Stop()
+	Convey("Calling CreateTask with the name of an existing task while names are unique", t, func() {
+		s := New(GetDefaultConfig(), WithUniqueTaskNames(true))
+		s.SetMetricManager(newMockMetricManager())
+		s.Start()
+		_, errs := s.CreateTask(sch, w, false, core.SetTaskName("named-task"))
+		So(errs.Errors(), ShouldBeEmpty)
+		tsk2, errs := s.CreateTask(sch, w, false, core.SetTaskName("named-task"))
+		Convey("Should return an error", func() {
+			So(tsk2, ShouldBeNil)
+			So(errs.Errors(), ShouldNotBeEmpty)
+			So(errs.Errors()[0].Error(), ShouldContainSubstring, ErrTaskNameAlreadyInUse.Error())
+			So(s.GetTasks(), ShouldHaveLength, 1)
+		})
+		s.Stop()
+	})
+}
+
+func TestConcurrentTaskAccess(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	s := newScheduler()
+	s.Start()
+	w := newMockWorkflowMap()
+
+	Convey("Calling CreateTask, GetTasks and GetTask concurrently", t, func() {
+		const n = 20
+		var wg sync.WaitGroup
+		ids := make(chan string, n)
+		for i := 0; i < n; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				sch := schedule.NewWindowedSchedule(interval, nil, nil, 0)
+				if tsk, errs := s.CreateTask(sch, w, false); len(errs.Errors()) == 0 {
+					ids <- tsk.ID()
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for id := range s.GetTasks() {
+					s.GetTask(id)
+				}
+			}()
+		}
+		wg.Wait()
+		close(ids)
+		Convey("Should create every task", func() {
+			So(ids, ShouldHaveLength, n)
+			So(s.GetTasks(), ShouldHaveLength, n)
+			for id := range ids {
+				_, err := s.GetTask(id)
+				So(err, ShouldBeNil)
+			}
+		})
+	})
+	s.Stop()
+}
+
+func TestSaveLoadTasks(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	w := newMockWorkflowMap()
+
+	Convey("Calling LoadTasks with the tasks saved by SaveTasks", t, func() {
+		s := New(GetDefaultConfig())
+		s.SetMetricManager(&mockMetricManager{acceptSubscriptions: true})
+		s.Start()
+		newSchedule := func() schedule.Schedule {
+			return schedule.NewWindowedSchedule(time.Second, nil, nil, 0)
+		}
+		running, _ := s.CreateTask(newSchedule(), w, true, core.SetTaskName("running"), core.OptionTimeout(time.Minute))
+		stopAt := time.Now().Add(time.Hour)
+		stopped, _ := s.CreateTask(newSchedule(), w, false, core.SetTaskName("stopped"), core.OptionStopOnFailure(3),
+			core.TaskDeadlineDuration(3*time.Second), core.OptionStopAt(stopAt), core.OptionFiredCount(7, 5))
+		disabled, _ := s.CreateTask(newSchedule(), w, false, core.SetTaskName("disabled"))
+		So(running, ShouldNotBeNil)
+		So(stopped, ShouldNotBeNil)
+		So(disabled, ShouldNotBeNil)
+		So(running.State(), ShouldBeIn, []core.TaskState{core.TaskSpinning, core.TaskFiring})
+		s.tasks.Get(disabled.ID()).setState(core.TaskDisabled)
+
+		buf := &bytes.Buffer{}
+		So(s.SaveTasks(buf), ShouldBeNil)
+		s.Stop()
+
+		c := &mockMetricManager{acceptSubscriptions: true}
+		s2 := New(GetDefaultConfig())
+		s2.SetMetricManager(c)
+		s2.Start()
+		errs := s2.LoadTasks(buf)
+		Convey("Should restore every task under its id and name", func() {
+			So(errs, ShouldBeEmpty)
+			So(s2.GetTasks(), ShouldHaveLength, 3)
+			for _, tsk := range []core.Task{running, stopped, disabled} {
+				restored, err := s2.GetTask(tsk.ID())
+				So(err, ShouldBeNil)
+				So(restored.GetName(), ShouldEqual, tsk.GetName())
+			}
+		})
+		Convey("Should restore the options and the state of the tasks", func() {
+			r, _ := s2.GetTask(running.ID())
+			So(r.State(), ShouldBeIn, []core.TaskState{core.TaskSpinning, core.TaskFiring})
+			So(r.Timeout(), ShouldEqual, time.Minute)
+			So(atomic.LoadInt32(&c.subscriptionCount), ShouldEqual, 1)
+			st, _ := s2.GetTask(stopped.ID())
+			So(st.State(), ShouldEqual, core.TaskStopped)
+			So(st.GetStopOnFailure(), ShouldEqual, 3)
+			So(st.DeadlineDuration(), ShouldEqual, 3*time.Second)
+			So(st.StopAt().Equal(stopped.StopAt()), ShouldBeTrue)
+			So(st.HitCount(), ShouldEqual, stopped.HitCount())
+			So(st.ScheduledRuns(), ShouldEqual, stopped.ScheduledRuns())
+			So(st.ScheduledRuns(), ShouldEqual, 5)
+			d, _ := s2.GetTask(disabled.ID())
+			So(d.State(), ShouldEqual, core.TaskDisabled)
+		})
+		s2.Stop()
+	})
+	Convey("Calling LoadTasks with a task which cannot be restored", t, func() {
+		s := newScheduler()
+		s.Start()
+		sch := schedule.NewWindowedSchedule(time.Second, nil, nil, 0)
+		tsk, _ := s.CreateTask(sch, w, false)
+		So(tsk, ShouldNotBeNil)
+		buf := &bytes.Buffer{}
+		So(s.SaveTasks(buf), ShouldBeNil)
+		// the task is restored in the scheduler which already has it
+		errs := s.LoadTasks(buf)
+		Convey("Should return an error for the task", func() {
+			So(errs, ShouldHaveLength, 1)
+			So(errs[0].Error(), ShouldContainSubstring, ErrTaskNotRestored.Error())
+			So(errs[0].Error(), ShouldContainSubstring, tsk.ID())
+			So(s.GetTasks(), ShouldHaveLength, 1)
+		})
+		s.Stop()
+	})
+}
+
+func TestExportImportState(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	w := newMockWorkflowMap()
+
+	Convey("Calling ImportState with the state written by ExportState", t, func() {
+		s := New(GetDefaultConfig())
+		s.SetMetricManager(&mockMetricManager{acceptSubscriptions: true})
+		s.Start()
+		sch := schedule.NewWindowedSchedule(time.Second, nil, nil, 0)
+		running, _ := s.CreateTask(sch, w, true, core.SetTaskName("running"))
+		failed, _ := s.CreateTask(schedule.NewWindowedSchedule(time.Second, nil, nil, 0), w, false, core.SetTaskName("failed"))
+		So(running, ShouldNotBeNil)
+		So(failed, ShouldNotBeNil)
+		s.tasks.Get(failed.ID()).restoreStats(core.TaskStats{FailedCount: 2, MissedCount: 4, LastFailureMessage: "collection failed"})
+
+		buf := &bytes.Buffer{}
+		So(s.ExportState(buf), ShouldBeNil)
+		s.Stop()
+
+		s2 := New(GetDefaultConfig())
+		s2.SetMetricManager(&mockMetricManager{acceptSubscriptions: true})
+		s2.Start()
+		errs := s2.ImportState(buf)
+		Convey("Should restore the tasks with their state and stats", func() {
+			So(errs, ShouldBeEmpty)
+			So(s2.GetTasks(), ShouldHaveLength, 2)
+			r, err := s2.GetTask(running.ID())
+			So(err, ShouldBeNil)
+			So(r.GetName(), ShouldEqual, "running")
+			So(r.State(), ShouldBeIn, []core.TaskState{core.TaskSpinning, core.TaskFiring})
+			f, err := s2.GetTask(failed.ID())
+			So(err, ShouldBeNil)
+			So(f.State(), ShouldEqual, core.TaskStopped)
+			stats := f.Stats()
+			So(stats.FailedCount, ShouldEqual, 2)
+			So(stats.MissedCount, ShouldEqual, 4)
+			So(stats.LastFailureMessage, ShouldEqual, "collection failed")
+		})
+		s2.Stop()
+	})
+	Convey("Calling ImportState with a document of an unsupported version", t, func() {
+		s := newScheduler()
+		errs := s.ImportState(strings.NewReader(`{"version": 2, "tasks": []}`))
+		Convey("Should return ErrUnsupportedStateVersion", func() {
+			So(errs, ShouldHaveLength, 1)
+			So(errs[0].Error(), ShouldContainSubstring, ErrUnsupportedStateVersion.Error())
+		})
+	})
+}
+
+func TestAuditLog(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	w := newMockWorkflowMap()
+
+	Convey("Managing a task with the identity of the caller in the context", t, func() {
+		s := New(GetDefaultConfig())
+		s.SetMetricManager(&mockMetricManager{acceptSubscriptions: true})
+		s.Start()
+		ctx := core.WithCaller(context.Background(), "alice")
+		sch := schedule.NewWindowedSchedule(time.Second, nil, nil, 0)
+		tsk, errs := s.CreateTaskWithContext(ctx, sch, w, false, core.SetTaskName("audited"))
+		So(errs.Errors(), ShouldBeEmpty)
+		So(s.UpdateTaskWithContext(ctx, tsk.ID(), schedule.NewWindowedSchedule(time.Minute, nil, nil, 0), nil), ShouldBeNil)
+		So(s.StartTaskWithContext(ctx, tsk.ID()), ShouldBeEmpty)
+		So(s.StartTaskWithContext(ctx, tsk.ID()), ShouldNotBeEmpty)
+		So(s.StopTaskWithContext(ctx, tsk.ID()), ShouldBeEmpty)
+		So(s.RemoveTask(tsk.ID()), ShouldBeNil)
+		entries := s.AuditLog(core.AuditFilter{TaskID: tsk.ID()})
+		Convey("Should record each operation with its caller, oldest first", func() {
+			So(entries, ShouldHaveLength, 6)
+			var ops []core.AuditOperation
+			for _, e := range entries {
+				ops = append(ops, e.Operation)
+			}
+			So(ops, ShouldResemble, []core.AuditOperation{core.AuditCreateTask, core.AuditUpdateTask,
+				core.AuditStartTask, core.AuditStartTask, core.AuditStopTask, core.AuditRemoveTask})
+			So(entries[0].Caller, ShouldEqual, "alice")
+			So(entries[0].Source, ShouldEqual, "user")
+			So(entries[0].Changes, ShouldContain, core.AuditChange{Field: "name", After: "audited"})
+			So(entries[5].Caller, ShouldBeEmpty)
+		})
+		Convey("Should record the changes of the manifest of an update", func() {
+			So(entries[1].Changes, ShouldResemble, []core.AuditChange{{Field: "schedule.interval", Before: "1s", After: "1m0s"}})
+		})
+		Convey("Should record the error of a failed operation", func() {
+			So(entries[2].Error, ShouldBeEmpty)
+			So(entries[3].Error, ShouldEqual, ErrTaskAlreadyRunning.Error())
+			So(entries[3].Changes, ShouldBeEmpty)
+		})
+		Convey("Should select the entries of the caller", func() {
+			So(s.AuditLog(core.AuditFilter{Caller: "alice"}), ShouldHaveLength, 5)
+		})
+		s.Stop()
+	})
+	Convey("Calling AuditLog with the audit log disabled", t, func() {
+		s := New(GetDefaultConfig(), WithAuditLogSize(0))
+		s.SetMetricManager(&mockMetricManager{acceptSubscriptions: true})
+		s.Start()
+		sch := schedule.NewWindowedSchedule(time.Second, nil, nil, 0)
+		s.CreateTask(sch, w, false)
+		Convey("Should return no entry", func() {
+			So(s.AuditLog(core.AuditFilter{}), ShouldBeEmpty)
+		})
+		s.Stop()
+	})
+}
+
+func TestAuthorizer(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	w := newMockWorkflowMap()
+
+	Convey("Managing tasks with an authorizer set", t, func() {
+		s := New(GetDefaultConfig())
+		s.SetMetricManager(&mockMetricManager{acceptSubscriptions: true})
+		s.Start()
+		var mutex sync.Mutex
+		var authorized []Authorization
+		// only admin may create or remove the tasks
+		s.SetAuthorizer(AuthorizerFunc(func(a Authorization) error {
+			mutex.Lock()
+			authorized = append(authorized, a)
+			mutex.Unlock()
+			if a.Caller != "admin" && (a.Operation == core.AuditCreateTask || a.Operation == core.AuditRemoveTask) {
+				return errors.New("admin only")
+			}
+			return nil
+		}))
+		admin := core.WithCaller(context.Background(), "admin")
+		bob := core.WithCaller(context.Background(), "bob")
+		sch := schedule.NewWindowedSchedule(time.Second, nil, nil, 0)
+		tsk, errs := s.CreateTaskWithContext(admin, sch, w, false, core.OptionLabels(map[string]string{"team": "ops"}))
+		So(errs.Errors(), ShouldBeEmpty)
+		Convey("Should pass the caller, the labels and the namespaces of the task", func() {
+			So(authorized, ShouldHaveLength, 1)
+			So(authorized[0].Operation, ShouldEqual, core.AuditCreateTask)
+			So(authorized[0].Caller, ShouldEqual, "admin")
+			So(authorized[0].Source, ShouldEqual, "user")
+			So(authorized[0].Labels, ShouldResemble, map[string]string{"team": "ops"})
+			So(authorized[0].Namespaces, ShouldResemble, []string{"/foo/bar", "/foo/baz"})
+		})
+		Convey("Should deny the operations the authorizer returns an error for", func() {
+			_, errs := s.CreateTaskWithContext(bob, schedule.NewWindowedSchedule(time.Second, nil, nil, 0), w, false)
+			So(errs.Errors(), ShouldHaveLength, 1)
+			So(errs.Error(), ShouldContainSubstring, ErrNotAuthorized.Error())
+			_, code, _ := core.TaskErrorAttribution(errs.Errors()[0])
+			So(code, ShouldEqual, core.TaskErrorNotAuthorized)
+			err := s.RemoveTaskWithContext(bob, tsk.ID())
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "admin only")
+			So(s.GetTasks(), ShouldHaveLength, 1)
+			So(s.AuditLog(core.AuditFilter{Caller: "bob", Operation: core.AuditRemoveTask})[0].Error, ShouldEqual, err.Error())
+		})
+		Convey("Should let the other operations run", func() {
+			So(s.StartTaskWithContext(bob, tsk.ID()), ShouldBeEmpty)
+			So(s.StopTaskWithContext(bob, tsk.ID()), ShouldBeEmpty)
+			So(s.RemoveTaskWithContext(admin, tsk.ID()), ShouldBeNil)
+		})
+		Convey("Should let every operation run once the authorizer is unset", func() {
+			s.SetAuthorizer(nil)
+			So(s.RemoveTaskWithContext(bob, tsk.ID()), ShouldBeNil)
+		})
+		s.Stop()
+	})
+}
+
+func TestTenants(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	w := newMockWorkflowMap()
+
+	Convey("Managing the tasks of tenants", t, func() {
+		s := New(GetDefaultConfig(), WithTenantQuota(TenantQuota{MaxTasks: 2, MaxMetrics: 3}))
+		s.SetMetricManager(&mockMetricManager{acceptSubscriptions: true})
+		s.Start()
+		teamA := core.WithTenant(context.Background(), "team-a")
+		newSchedule := func() schedule.Schedule {
+			return schedule.NewWindowedSchedule(time.Second, nil, nil, 0)
+		}
+		tsk, errs := s.CreateTaskWithContext(teamA, newSchedule(), w, false)
+		So(errs.Errors(), ShouldBeEmpty)
+		So(tsk.Tenant(), ShouldEqual, "team-a")
+		Convey("Should refuse a task taking the tenant over its metrics", func() {
+			_, errs := s.CreateTaskWithContext(teamA, newSchedule(), w, false)
+			So(errs.Errors(), ShouldHaveLength, 1)
+			So(errs.Error(), ShouldContainSubstring, ErrTenantMetricQuotaExceeded.Error())
+			_, code, _ := core.TaskErrorAttribution(errs.Errors()[0])
+			So(code, ShouldEqual, core.TaskErrorQuotaExceeded)
+			u := s.TenantUsage("team-a")
+			So(u.Tasks, ShouldEqual, 1)
+			So(u.Metrics, ShouldEqual, 2)
+		})
+		Convey("Should refuse a task taking the tenant over its tasks", func() {
+			s.SetTenantQuota("team-a", TenantQuota{MaxTasks: 1})
+			_, errs := s.CreateTaskWithContext(teamA, newSchedule(), w, false)
+			So(errs.Errors(), ShouldHaveLength, 1)
+			So(errs.Error(), ShouldContainSubstring, ErrTenantTaskQuotaExceeded.Error())
+			Convey("but not within the quota of another tenant", func() {
+				_, errs := s.CreateTask(newSchedule(), w, false, core.OptionTenant("team-b"))
+				So(errs.Errors(), ShouldBeEmpty)
+			})
+		})
+		Convey("Should refuse a task of another tenant than the one of its context", func() {
+			_, errs := s.CreateTaskWithContext(teamA, newSchedule(), w, false, core.OptionTenant("team-b"))
+			So(errs.Errors(), ShouldHaveLength, 1)
+			So(errs.Error(), ShouldContainSubstring, ErrTenantMismatch.Error())
+		})
+		Convey("Should refuse an update taking the tenant over its metrics", func() {
+			s.SetTenantQuota("team-a", TenantQuota{MaxMetrics: 2})
+			wider := newMockWorkflowMap()
+			wider.Collect.AddMetric("/foo/qux", 1)
+			errs := s.UpdateTask(tsk.ID(), nil, wider)
+			So(errs, ShouldNotBeNil)
+			So(errs.Error(), ShouldContainSubstring, ErrTenantMetricQuotaExceeded.Error())
+			So(s.UpdateTask(tsk.ID(), nil, w), ShouldBeNil)
+		})
+		Convey("Should list only the tasks of the tenant of the context", func() {
+			other, errs := s.CreateTask(newSchedule(), w, false)
+			So(errs.Errors(), ShouldBeEmpty)
+			So(s.GetTasks(), ShouldHaveLength, 2)
+			tasks := s.GetTasksWithContext(teamA)
+			So(tasks, ShouldHaveLength, 1)
+			So(tasks, ShouldContainKey, tsk.ID())
+			So(s.GetTasksWithContext(core.WithTenant(context.Background(), "team-b")), ShouldBeEmpty)
+			So(s.GetTasksWithContext(context.Background()), ShouldContainKey, other.ID())
+		})
+		Convey("Should keep the tenant of a saved task", func() {
+			tr, err := taskCreationRequest(tsk.(*task))
+			So(err, ShouldBeNil)
+			So(tr.Tenant, ShouldEqual, "team-a")
+		})
+		s.Stop()
+	})
+}
+
+func TestTaskHistory(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	w := newMockWorkflowMap()
+
+	Convey("Firing a task with the run history enabled", t, func() {
+		dir, err := ioutil.TempDir("", "task-history")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		c := &mockMetricManager{
+			acceptSubscriptions: true,
+			collected:           []core.Metric{&metric{namespace: core.NewNamespace("foo", "bar")}},
+		}
+		s := New(GetDefaultConfig(), WithTaskHistory(2, dir))
+		s.SetMetricManager(c)
+		s.Start()
+		tsk, errs := s.CreateTask(schedule.NewWindowedSchedule(time.Hour, nil, nil, 0), w, false)
+		So(errs.Errors(), ShouldBeEmpty)
+		So(s.RunTaskNow(context.Background(), tsk.ID(), true), ShouldBeNil)
+		atomic.StoreInt32(&c.failCollecting, 1)
+		So(s.RunTaskNow(context.Background(), tsk.ID(), true), ShouldNotBeNil)
+		Convey("Should keep the outcome of each run", func() {
+			runs, err := s.GetTaskHistory(tsk.ID(), 0)
+			So(err, ShouldBeNil)
+			So(runs, ShouldHaveLength, 2)
+			So(runs[0].Metrics, ShouldEqual, 1)
+			So(runs[0].Errors, ShouldBeEmpty)
+			So(runs[1].Metrics, ShouldEqual, 0)
+			So(runs[1].Errors, ShouldResemble, []string{"collection error"})
+			So(runs[1].Start, ShouldHappenOnOrAfter, runs[0].Start)
+		})
+		Convey("Should only keep the last runs", func() {
+			So(s.RunTaskNow(context.Background(), tsk.ID(), true), ShouldNotBeNil)
+			runs, err := s.GetTaskHistory(tsk.ID(), 0)
+			So(err, ShouldBeNil)
+			So(runs, ShouldHaveLength, 2)
+			So(runs[0].Errors, ShouldNotBeEmpty)
+			last, err := s.GetTaskHistory(tsk.ID(), 1)
+			So(err, ShouldBeNil)
+			So(last, ShouldResemble, runs[1:])
+		})
+		Convey("Should not keep the runs which are not recorded", func() {
+			So(s.RunTaskNow(context.Background(), tsk.ID(), false), ShouldNotBeNil)
+			runs, _ := s.GetTaskHistory(tsk.ID(), 0)
+			So(runs[0].Errors, ShouldBeEmpty)
+		})
+		Convey("Should restore the runs of a task created again under its id", func() {
+			s2 := New(GetDefaultConfig(), WithTaskHistory(2, dir))
+			s2.SetMetricManager(c)
+			s2.Start()
+			defer s2.Stop()
+			again, errs := s2.CreateTask(schedule.NewWindowedSchedule(time.Hour, nil, nil, 0), w, false, core.SetTaskID(tsk.ID()))
+			So(errs.Errors(), ShouldBeEmpty)
+			runs, err := s2.GetTaskHistory(again.ID(), 0)
+			So(err, ShouldBeNil)
+			So(runs, ShouldHaveLength, 2)
+			So(runs[0].Metrics, ShouldEqual, 1)
+		})
+		Convey("Should discard the runs of a removed task", func() {
+			So(s.RemoveTask(tsk.ID()), ShouldBeNil)
+			_, err := s.GetTaskHistory(tsk.ID(), 0)
+			So(err, ShouldNotBeNil)
+			files, _ := ioutil.ReadDir(dir)
+			So(files, ShouldBeEmpty)
+		})
+		s.Stop()
+	})
+}
+
+func TestTaskManifest(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	w := newMockWorkflowMap()
+
+	Convey("Calling CreateTaskFromManifest with the manifest of ExportTask", t, func() {
+		s := New(GetDefaultConfig())
+		s.SetMetricManager(&mockMetricManager{acceptSubscriptions: true})
+		s.Start()
+		sch := schedule.NewWindowedSchedule(time.Second, nil, nil, 0)
+		stopAt := time.Now().Add(time.Hour)
+		tsk, _ := s.CreateTask(sch, w, false, core.SetTaskName("exported"), core.OptionStopOnFailure(3), core.OptionMaxRuns(5),
+			core.OptionLabels(map[string]string{"env": "prod"}), core.TaskDeadlineDuration(3*time.Second),
+			core.OptionStopAt(stopAt), core.OptionFiredCount(4, 3))
+		So(tsk, ShouldNotBeNil)
+
+		s2 := New(GetDefaultConfig())
+		s2.SetMetricManager(&mockMetricManager{acceptSubscriptions: true})
+		s2.Start()
+		Convey("Should create the task in either format", func() {
+			for _, format := range []ManifestFormat{ManifestJSON, ManifestYAML} {
+				manifest, err := s.ExportTask(tsk.ID(), format)
+				So(err, ShouldBeNil)
+				created, err := s2.CreateTaskFromManifest(manifest)
+				So(err, ShouldBeNil)
+				So(created.ID(), ShouldNotEqual, tsk.ID())
+				So(created.GetName(), ShouldEqual, "exported")
+				So(created.GetStopOnFailure(), ShouldEqual, 3)
+				So(created.MaxRuns(), ShouldEqual, 5)
+				So(created.DeadlineDuration(), ShouldEqual, tsk.DeadlineDuration())
+				So(created.StopAt().Equal(tsk.StopAt()), ShouldBeTrue)
+				So(created.HitCount(), ShouldEqual, 4)
+				So(created.ScheduledRuns(), ShouldEqual, 3)
+				So(created.Labels(), ShouldResemble, map[string]string{"env": "prod"})
+				So(created.State(), ShouldEqual, core.TaskStopped)
+				So(created.Schedule(), ShouldResemble, tsk.Schedule())
+				So(s2.RemoveTask(created.ID()), ShouldBeNil)
+			}
+		})
+		Convey("Should encode the manifest in the given format", func() {
+			js, _ := s.ExportTask(tsk.ID(), ManifestJSON)
+			So(json.Unmarshal(js, &map[string]interface{}{}), ShouldBeNil)
+			yml, _ := s.ExportTask(tsk.ID(), ManifestYAML)
+			So(string(yml), ShouldContainSubstring, "name: exported")
+			_, err := s.ExportTask(tsk.ID(), ManifestFormat(-1))
+			So(err, ShouldEqual, ErrUnknownManifestFormat)
+		})
+		Convey("Should start the task if the exported task was running", func() {
+			So(s.StartTask(tsk.ID()), ShouldBeEmpty)
+			manifest, _ := s.ExportTask(tsk.ID(), ManifestYAML)
+			created, err := s2.CreateTaskFromManifest(manifest)
+			So(err, ShouldBeNil)
+			So(created.State(), ShouldBeIn, []core.TaskState{core.TaskSpinning, core.TaskFiring})
+		})
+		Convey("Should not create a task from an invalid manifest", func() {
+			_, err := s2.CreateTaskFromManifest([]byte("name: [broken"))
+			So(err, ShouldNotBeNil)
+			_, err = s2.CreateTaskFromManifest([]byte("name: no-schedule"))
+			So(err, ShouldNotBeNil)
+			So(s2.GetTasks(), ShouldBeEmpty)
+			_, err = s.ExportTask("unknown", ManifestJSON)
+			So(err, ShouldNotBeNil)
+		})
+		s2.Stop()
+		s.Stop()
+	})
+}
+
+func TestUpdateTask(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+
+	Convey("Calling UpdateTask", t, func() {
+		c := &mockMetricManager{acceptSubscriptions: true}
+		s := New(GetDefaultConfig())
+		s.SetMetricManager(c)
+		s.Start()
+		sch := schedule.NewWindowedSchedule(time.Second*5, nil, nil, 0)
+		tsk, _ := s.CreateTask(sch, newMockWorkflowMap(), true)
+		So(tsk, ShouldNotBeNil)
+		So(atomic.LoadInt32(&c.subscriptionCount), ShouldEqual, 1)
+		w := wmap.NewWorkflowMap()
+		w.Collect.AddMetric("/foo/qux", 1)
+		namespaces := func() []string {
+			var ns []string
+			for _, m := range tsk.(*task).workflow.metrics {
+				ns = append(ns, m.Namespace().String())
+			}
+			return ns
+		}
+
+		Convey("Should swap the workflow and the schedule of a running task", func() {
+			updated := schedule.NewWindowedSchedule(time.Second, nil, nil, 0)
+			So(s.UpdateTask(tsk.ID(), updated, w), ShouldBeNil)
+			So(namespaces(), ShouldResemble, []string{"/foo/qux"})
+			So(tsk.(*task).Schedule(), ShouldEqual, updated)
+			So(tsk.State(), ShouldBeIn, []core.TaskState{core.TaskSpinning, core.TaskFiring})
+			got, err := s.GetTask(tsk.ID())
+			So(err, ShouldBeNil)
+			So(got.ID(), ShouldEqual, tsk.ID())
+			// the new workflow was subscribed under the staging id and the
+			// task id, then the old one and the staging id were unsubscribed
+			So(atomic.LoadInt32(&c.subscriptionCount), ShouldEqual, 3)
+			So(atomic.LoadInt32(&c.unsubscriptionCount), ShouldEqual, 2)
+		})
+		Convey("Should keep the schedule when none is given", func() {
+			So(s.UpdateTask(tsk.ID(), nil, w), ShouldBeNil)
+			So(tsk.(*task).Schedule(), ShouldEqual, sch)
+			So(namespaces(), ShouldResemble, []string{"/foo/qux"})
+		})
+		Convey("Should not subscribe the workflow of a stopped task", func() {
+			So(s.StopTask(tsk.ID()), ShouldBeEmpty)
+			// the plugins are unsubscribed once the task has stopped
+			for i := 0; i < 100 && atomic.LoadInt32(&tsk.(*task).subscribed) == 1; i++ {
+				time.Sleep(time.Millisecond * 10)
+			}
+			unsubscribed := atomic.LoadInt32(&c.unsubscriptionCount)
+			So(s.UpdateTask(tsk.ID(), nil, w), ShouldBeNil)
+			So(namespaces(), ShouldResemble, []string{"/foo/qux"})
+			So(atomic.LoadInt32(&c.subscriptionCount), ShouldEqual, 1)
+			So(atomic.LoadInt32(&c.unsubscriptionCount), ShouldEqual, unsubscribed)
+		})
+		Convey("Should keep the old workflow if the new one cannot be subscribed", func() {
+			c.acceptSubscriptions = false
+			So(s.UpdateTask(tsk.ID(), nil, w), ShouldNotBeNil)
+			So(namespaces(), ShouldContain, "/foo/bar")
+			So(atomic.LoadInt32(&tsk.(*task).subscribed), ShouldEqual, 1)
+			c.acceptSubscriptions = true
+		})
+		Convey("Should keep the old workflow if the new one is not valid", func() {
+			c.failValidatingMetrics = true
+			So(s.UpdateTask(tsk.ID(), nil, w), ShouldNotBeNil)
+			So(namespaces(), ShouldContain, "/foo/bar")
+			c.failValidatingMetrics = false
+		})
+		Convey("Should return an error for an ended task", func() {
+			So(s.StopTask(tsk.ID()), ShouldBeEmpty)
+			tsk.(*task).setState(core.TaskEnded)
+			te := s.UpdateTask(tsk.ID(), nil, w)
+			So(te, ShouldNotBeNil)
+			So(te.Errors()[0].Error(), ShouldEqual, ErrTaskEndedNotUpdatable.Error())
+		})
+		Convey("Should return an error for an unknown task", func() {
+			So(s.UpdateTask("unknown", nil, w), ShouldNotBeNil)
+		})
+		s.Stop()
+	})
+}
+
+func TestTaskSecrets(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	w := newMockWorkflowMap()
+	w.Collect.AddConfigItem("/foo/bar", "password", map[string]interface{}{"secret": "hunter2"})
+
+	Convey("Creating a task with a secret config value", t, func() {
+		s := New(GetDefaultConfig())
+		s.SetMetricManager(&mockMetricManager{acceptSubscriptions: true})
+		s.Start()
+		sch := schedule.NewWindowedSchedule(time.Second, nil, nil, 0)
+		tsk, _ := s.CreateTask(sch, w, false)
+		So(tsk, ShouldNotBeNil)
+		Convey("Should redact the secret from the task", func() {
+			got, err := s.GetTask(tsk.ID())
+			So(err, ShouldBeNil)
+			So(got.WMap().Collect.Config["/foo/bar"]["password"], ShouldEqual, ctypes.SecretRedacted)
+			So(got.WMap().Collect.Config["/foo/bar"]["username"], ShouldEqual, "root")
+		})
+		Convey("Should not export the task without a secret key", func() {
+			_, err := s.ExportTask(tsk.ID(), ManifestJSON)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, ErrSecretNotSavable.Error())
+		})
+		Convey("Should export the secret encrypted", func() {
+			ctypes.SetSecretKeyProvider(ctypes.StaticSecretKey(strings.Repeat("k", 32)))
+			defer ctypes.SetSecretKeyProvider(nil)
+			manifest, err := s.ExportTask(tsk.ID(), ManifestJSON)
+			So(err, ShouldBeNil)
+			So(string(manifest), ShouldNotContainSubstring, "hunter2")
+			So(string(manifest), ShouldContainSubstring, "encrypted")
+			created, err := s.CreateTaskFromManifest(manifest)
+			So(err, ShouldBeNil)
+			ct, err := created.(*task).workflow.workflowMap.Collect.GetConfigTree()
+			So(err, ShouldBeNil)
+			So(ct.Get([]string{"foo", "bar"}).Table()["password"], ShouldResemble, ctypes.NewSecret("hunter2"))
+		})
+		s.Stop()
+	})
+}
+
+func TestTaskTemplate(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	w := newMockWorkflowMap()
+
+	Convey("Calling CreateTaskFromTemplate", t, func() {
+		s := New(GetDefaultConfig())
+		s.SetMetricManager(&mockMetricManager{acceptSubscriptions: true})
+		s.Start()
+		sch := schedule.NewWindowedSchedule(time.Second, nil, nil, 0)
+		tsk, _ := s.CreateTask(sch, w, false, core.SetTaskName("template"))
+		So(tsk, ShouldNotBeNil)
+		manifest, err := s.ExportTask(tsk.ID(), ManifestYAML)
+		So(err, ShouldBeNil)
+		template := strings.Replace(string(manifest), "name: template", "name: collect-{{.hostname}}", 1)
+		template = strings.Replace(template, "interval: 1s", "interval: {{.SNAP_TEMPLATE_INTERVAL}}", 1)
+		So(template, ShouldContainSubstring, "{{.SNAP_TEMPLATE_INTERVAL}}")
+		So(os.Setenv("SNAP_TEMPLATE_INTERVAL", "2s"), ShouldBeNil)
+		defer os.Unsetenv("SNAP_TEMPLATE_INTERVAL")
+
+		Convey("Should create a task for each set of variables", func() {
+			for _, host := range []string{"host1", "host2"} {
+				created, err := s.CreateTaskFromTemplate([]byte(template), map[string]string{"hostname": host})
+				So(err, ShouldBeNil)
+				So(created.GetName(), ShouldEqual, "collect-"+host)
+				So(created.Schedule().(*schedule.WindowedSchedule).Interval, ShouldEqual, 2*time.Second)
+			}
+		})
+		Convey("Should prefer the given variables to the environment", func() {
+			vars := map[string]string{"hostname": "host3", "SNAP_TEMPLATE_INTERVAL": "3s"}
+			created, err := s.CreateTaskFromTemplate([]byte(template), vars)
+			So(err, ShouldBeNil)
+			So(created.Schedule().(*schedule.WindowedSchedule).Interval, ShouldEqual, 3*time.Second)
+		})
+		Convey("Should not create a task with a variable left unresolved", func() {
+			_, err := s.CreateTaskFromTemplate([]byte(template), nil)
+			So(err, ShouldNotBeNil)
+			So(s.GetTasks(), ShouldHaveLength, 1)
+		})
+		s.Stop()
+	})
+}
+
+// memoryTaskStore is a TaskStore keeping the tasks in memory
+type memoryTaskStore struct {
+	sync.Mutex
+	data []byte
+}
+
+func (m *memoryTaskStore) Save(data []byte) error {
+	m.Lock()
+	defer m.Unlock()
+	m.data = data
+	return nil
+}
+
+func (m *memoryTaskStore) Load() ([]byte, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.data, nil
+}
+
+func (m *memoryTaskStore) saved() []savedTask {
+	data, _ := m.Load()
+	saved := []savedTask{}
+	json.Unmarshal(data, &saved)
+	return saved
+}
+
+func TestTaskStore(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	w := newMockWorkflowMap()
+
+	Convey("Starting a scheduler with the task store of a previous one", t, func() {
+		store := &memoryTaskStore{}
+		s := New(GetDefaultConfig())
+		s.SetMetricManager(&mockMetricManager{acceptSubscriptions: true})
+		s.SetTaskStore(store)
+		s.Start()
+		sch := schedule.NewWindowedSchedule(time.Second, nil, nil, 0)
+		running, _ := s.CreateTask(sch, w, true, core.SetTaskName("running"))
+		removed, _ := s.CreateTask(schedule.NewWindowedSchedule(time.Second, nil, nil, 0), w, false)
+		So(running, ShouldNotBeNil)
+		So(removed, ShouldNotBeNil)
+		So(s.RemoveTask(removed.ID()), ShouldBeNil)
+		// the tasks are saved as their events are handled
+		for i := 0; i < 100 && len(store.saved()) != 1; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		Convey("Should keep the tasks which were not removed", func() {
+			saved := store.saved()
+			So(saved, ShouldHaveLength, 1)
+			So(saved[0].ID, ShouldEqual, running.ID())
+		})
+		s.Stop()
+		Convey("Should not save the tasks stopped by stopping the scheduler", func() {
+			saved := store.saved()
+			So(saved, ShouldHaveLength, 1)
+			So(saved[0].State, ShouldEqual, core.TaskSpinning.String())
+		})
+
+		c := &mockMetricManager{acceptSubscriptions: true}
+		s2 := New(GetDefaultConfig())
+		s2.SetMetricManager(c)
+		s2.SetTaskStore(store)
+		s2.Start()
+		Convey("Should restore the tasks and subscribe to their plugins", func() {
+			So(s2.GetTasks(), ShouldHaveLength, 1)
+			r, err := s2.GetTask(running.ID())
+			So(err, ShouldBeNil)
+			So(r.GetName(), ShouldEqual, "running")
+			So(r.State(), ShouldBeIn, []core.TaskState{core.TaskSpinning, core.TaskFiring})
+			So(atomic.LoadInt32(&c.subscriptionCount), ShouldEqual, 1)
+		})
+		s2.Stop()
+	})
+}
+
+func TestLeaderElection(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	w := newMockWorkflowMap()
+
+	Convey("Starting schedulers sharing a task store and a leader lease", t, func() {
+		dir, err := ioutil.TempDir("", "leader-election")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		store := &memoryTaskStore{}
+		lease := filepath.Join(dir, "leader.lease")
+		ttl := 300 * time.Millisecond
+		newInstance := func(c *mockMetricManager) *scheduler {
+			s := New(GetDefaultConfig())
+			s.SetMetricManager(c)
+			s.SetTaskStore(store)
+			s.SetLeaderElector(NewFileLeaderElector(lease, ttl))
+			So(s.Start(), ShouldBeNil)
+			return s
+		}
+		awaitLeader := func(s *scheduler, leader bool) bool {
+			for i := 0; i < 100; i++ {
+				if s.IsLeader() == leader {
+					return true
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			return false
+		}
+		c1 := &mockMetricManager{acceptSubscriptions: true}
+		s1 := newInstance(c1)
+		So(awaitLeader(s1, true), ShouldBeTrue)
+		c2 := &mockMetricManager{acceptSubscriptions: true}
+		s2 := newInstance(c2)
+		tsk, errs := s1.CreateTask(schedule.NewWindowedSchedule(interval, nil, nil, 0), w, true)
+		So(errs.Errors(), ShouldBeEmpty)
+		for i := 0; i < 100 && len(store.saved()) != 1; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		Convey("Should only let the leader fire the tasks", func() {
+			So(s2.IsLeader(), ShouldBeFalse)
+			So(s2.GetTasks(), ShouldBeEmpty)
+			_, errs := s2.CreateTask(schedule.NewWindowedSchedule(interval, nil, nil, 0), w, true)
+			So(errs.Errors(), ShouldHaveLength, 1)
+			So(errs.Errors()[0].Error(), ShouldEqual, ErrSchedulerNotLeader.Error())
+			So(atomic.LoadInt32(&c2.subscriptionCount), ShouldEqual, 0)
+		})
+		Convey("Should let the standby take over the tasks once the leader stops", func() {
+			s1.Stop()
+			So(s1.GetTasks(), ShouldBeEmpty)
+			So(awaitLeader(s2, true), ShouldBeTrue)
+			r, err := s2.GetTask(tsk.ID())
+			So(err, ShouldBeNil)
+			So(r.State(), ShouldBeIn, []core.TaskState{core.TaskSpinning, core.TaskFiring})
+			So(atomic.LoadInt32(&c2.subscriptionCount), ShouldEqual, 1)
+		})
+		Convey("Should step down once the lease is taken over", func() {
+			data, _ := json.Marshal(leaderLease{Holder: "other", Expires: time.Now().Add(time.Hour)})
+			So(ioutil.WriteFile(lease, data, 0600), ShouldBeNil)
+			So(awaitLeader(s1, false), ShouldBeTrue)
+			for i := 0; i < 100 && len(s1.GetTasks()) > 0; i++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+			So(s1.GetTasks(), ShouldBeEmpty)
+			So(atomic.LoadInt32(&c1.unsubscriptionCount), ShouldEqual, 1)
+			// the tasks are kept in the store for the next leader
+			saved := store.saved()
+			So(saved, ShouldHaveLength, 1)
+			So(saved[0].State, ShouldEqual, core.TaskSpinning.String())
+			So(s2.IsLeader(), ShouldBeFalse)
+		})
+		s1.Stop()
+		s2.Stop()
+	})
+}
+
+func TestCreateTaskWithContext(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	c := &mockMetricManager{acceptSubscriptions: true}
+	s := New(GetDefaultConfig())
+	s.SetMetricManager(c)
+	s.Start()
+	w := newMockWorkflowMap()
+
+	Convey("Calling CreateTaskWithContext with a cancelled context", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		sch := schedule.NewWindowedSchedule(interval, nil, nil, 0)
+		tsk, errs := s.CreateTaskWithContext(ctx, sch, w, true)
+		Convey("Should return the error of the context", func() {
+			So(tsk, ShouldBeNil)
+			So(errs.Errors(), ShouldHaveLength, 1)
+			So(errs.Errors()[0].Error(), ShouldEqual, context.Canceled.Error())
+		})
+		Convey("Should neither create the task nor subscribe to its plugins", func() {
+			So(s.GetTasks(), ShouldBeEmpty)
+			So(atomic.LoadInt32(&c.subscriptionCount), ShouldEqual, 0)
+		})
+	})
+	Convey("Cancelling the context while the task is validated", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		c.onValidate = cancel
+		sch := schedule.NewWindowedSchedule(interval, nil, nil, 0)
+		tsk, errs := s.CreateTaskWithContext(ctx, sch, w, true)
+		c.onValidate = nil
+		Convey("Should abort the creation of the task", func() {
+			So(tsk, ShouldBeNil)
+			So(errs.Errors()[0].Error(), ShouldEqual, context.Canceled.Error())
+			So(s.GetTasks(), ShouldBeEmpty)
+			So(atomic.LoadInt32(&c.subscriptionCount), ShouldEqual, 0)
+		})
+	})
+	Convey("Subscribing the plugins of a task with a cancelled context", t, func() {
+		sch := schedule.NewWindowedSchedule(interval, nil, nil, 0)
+		tsk, errs := s.CreateTaskWithContext(context.Background(), sch, w, false)
+		So(errs.Errors(), ShouldBeEmpty)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		st, _ := s.getTask(tsk.ID())
+		_, serrs := st.subscribePlugins(ctx)
+		Convey("Should not subscribe to any plugin", func() {
+			So(serrs, ShouldHaveLength, 1)
+			So(serrs[0].Error(), ShouldEqual, context.Canceled.Error())
+			So(atomic.LoadInt32(&c.subscriptionCount), ShouldEqual, 0)
+		})
+	})
+	s.Stop()
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	c := &mockMetricManager{acceptSubscriptions: true, failCollecting: 1}
+	s := New(GetDefaultConfig(), WithCircuitBreaker(2, 60))
+	s.SetMetricManager(c)
+	s.Start()
+	defer s.Stop()
+	w := newMockWorkflowMap()
+	ch := s.Events()
+	// awaitEvent waits for the next event of the given type on the channel
+	awaitEvent := func(et TaskEventType) (TaskEvent, bool) {
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case e := <-ch:
+				if e.Type == et {
+					return e, true
+				}
+			case <-timeout:
+				return TaskEvent{}, false
+			}
+		}
+	}
+
+	Convey("Calling CreateTask for tasks whose collections fail", t, func() {
+		sch := schedule.NewWindowedSchedule(interval, nil, nil, 0)
+		tsk, _ := s.CreateTask(sch, w, true)
+		So(tsk != nil, ShouldBeTrue)
+		opened, ok := awaitEvent(EventCircuitOpened)
+		So(ok, ShouldBeTrue)
+		So(opened.TaskID, ShouldEqual, tsk.ID())
+		So(opened.Prefix, ShouldBeIn, []string{"/foo/bar", "/foo/baz"})
+		So(s.CircuitBreakers(), ShouldResemble, map[string]CircuitState{"/foo/bar": CircuitOpen, "/foo/baz": CircuitOpen})
+
+		// the collections of the tasks with metrics under the open
+		// prefixes are skipped
+		other, _ := s.CreateTask(schedule.NewWindowedSchedule(interval, nil, nil, 0), w, true)
+		So(other != nil, ShouldBeTrue)
+		time.Sleep(interval * 5)
+		So(tsk.FailedCount(), ShouldEqual, 2)
+		So(other.FailedCount(), ShouldEqual, 0)
+
+		So(s.StopTask(tsk.ID()), ShouldBeEmpty)
+		So(s.StopTask(other.ID()), ShouldBeEmpty)
+	})
+}
+
+func TestMaxConcurrentCollections(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	c := &mockMetricManager{acceptSubscriptions: true, timeToWait: interval * 3}
+	s := New(GetDefaultConfig(), WithMaxConcurrentCollections(1))
+	s.SetMetricManager(c)
+	s.Start()
+	defer s.Stop()
+	w := newMockWorkflowMap()
+
+	Convey("Calling CreateTask for tasks collecting slow metrics", t, func() {
+		var tasks []core.Task
+		for i := 0; i < 3; i++ {
+			tsk, _ := s.CreateTask(schedule.NewWindowedSchedule(interval, nil, nil, 0), w, true)
+			So(tsk != nil, ShouldBeTrue)
+			tasks = append(tasks, tsk)
+		}
+		time.Sleep(interval * 10)
+		Convey("runs one collection of the metrics at a time", func() {
+			So(atomic.LoadInt32(&c.maxCollecting), ShouldEqual, 1)
+		})
+		for _, tsk := range tasks {
+			So(s.StopTask(tsk.ID()), ShouldBeEmpty)
+		}
+	})
+}
+
+// collectionCatcher sends the number of metrics of each collection of a
+// watched task
+type collectionCatcher struct {
+	collections chan int
+}
+
+func (c *collectionCatcher) CatchCollection(mts []core.Metric) {
+	select {
+	case c.collections <- len(mts):
+	default:
+	}
+}
+func (c *collectionCatcher) CatchTaskStarted()        {}
+func (c *collectionCatcher) CatchTaskStopped()        {}
+func (c *collectionCatcher) CatchTaskEnded()          {}
+func (c *collectionCatcher) CatchTaskDisabled(string) {}
+
+func TestTaskDependencies(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	c := &mockMetricManager{
+		acceptSubscriptions: true,
+		collected:           []core.Metric{&metric{namespace: core.NewNamespace("foo", "bar")}},
+	}
+	s := New(GetDefaultConfig())
+	s.SetMetricManager(c)
+	s.Start()
+	defer s.Stop()
+	w := newMockWorkflowMap()
+
+	Convey("Calling CreateTask for a task depending on another", t, func() {
+		upstream, _ := s.CreateTask(schedule.NewWindowedSchedule(interval, nil, nil, 0), w, false)
+		So(upstream != nil, ShouldBeTrue)
+
+		Convey("fails without a dependent schedule", func() {
+			tsk, te := s.CreateTask(schedule.NewWindowedSchedule(interval, nil, nil, 0), w, false, core.OptionDependsOn([]string{upstream.ID()}, false))
+			So(tsk, ShouldBeNil)
+			So(te.Errors()[0].Error(), ShouldEqual, ErrDependentSchedule.Error())
+		})
+		Convey("fails for a dependent schedule without dependencies", func() {
+			tsk, te := s.CreateTask(schedule.NewDependentSchedule(), w, false)
+			So(tsk, ShouldBeNil)
+			So(te.Errors()[0].Error(), ShouldEqual, ErrDependentSchedule.Error())
+		})
+		Convey("fails for a task which does not exist", func() {
+			tsk, te := s.CreateTask(schedule.NewDependentSchedule(), w, false, core.OptionDependsOn([]string{"missing"}, false))
+			So(tsk, ShouldBeNil)
+			So(te.Errors()[0].Error(), ShouldStartWith, ErrDependencyNotFound.Error())
+			phase, code, _ := core.TaskErrorAttribution(te.Errors()[0])
+			So(phase, ShouldEqual, core.TaskPhaseValidation)
+			So(code, ShouldEqual, core.TaskErrorInvalidDependency)
+		})
+		Convey("fails for a task depending on itself", func() {
+			tsk, te := s.CreateTask(schedule.NewDependentSchedule(), w, false, core.SetTaskID("cycle"), core.OptionDependsOn([]string{upstream.ID(), "cycle"}, false))
+			So(tsk, ShouldBeNil)
+			So(te.Errors()[0].Error(), ShouldStartWith, ErrDependencyCycle.Error())
+		})
+		Convey("fires the task after each run of the task it depends on", func() {
+			dependent, te := s.CreateTask(schedule.NewDependentSchedule(), w, false, core.OptionDependsOn([]string{upstream.ID()}, true))
+			So(te.Errors(), ShouldBeEmpty)
+			So(dependent != nil, ShouldBeTrue)
+			catcher := &collectionCatcher{collections: make(chan int, 1)}
+			closer, err := s.WatchTask(dependent.ID(), catcher)
+			So(err, ShouldBeNil)
+			defer closer.Close()
+			So(s.StartTask(dependent.ID()), ShouldBeEmpty)
+			select {
+			case <-catcher.collections:
+				t.Fatal("the dependent task fired before the task it depends on")
+			case <-time.After(interval * 5):
+			}
+			So(s.StartTask(upstream.ID()), ShouldBeEmpty)
+			var collected int
+			select {
+			case collected = <-catcher.collections:
+			case <-time.After(time.Second):
+			}
+			Convey("passing the metrics of the run to the task", func() {
+				So(collected, ShouldEqual, 2)
+			})
+			So(s.StopTask(upstream.ID()), ShouldBeEmpty)
+			So(s.StopTask(dependent.ID()), ShouldBeEmpty)
+			So(s.RemoveTask(dependent.ID()), ShouldBeNil)
+		})
+		So(s.RemoveTask(upstream.ID()), ShouldBeNil)
+	})
+}
+
+// windowCollector records the windows of the collections of a backfill,
+// failing the first collection of the window starting at failAt
+type windowCollector struct {
+	*mockMetricManager
+	sync.Mutex
+	windows []core.CollectWindow
+	failAt  time.Time
+	failed  bool
+}
+
+func (w *windowCollector) CollectMetricsContext(ctx context.Context, id string, tags map[string]map[string]string, _ func(core.Namespace) bool) ([]core.Metric, []error) {
+	window, _ := core.CollectWindowFromContext(ctx)
+	w.Lock()
+	w.windows = append(w.windows, window)
+	fail := !w.failed && window.Start.Equal(w.failAt)
+	w.failed = w.failed || fail
+	w.Unlock()
+	if fail {
+		return nil, []error{errors.New("collection error")}
+	}
+	return w.mockMetricManager.CollectMetrics(id, tags)
+}
+
+func (w *windowCollector) collected() []core.CollectWindow {
+	w.Lock()
+	defer w.Unlock()
+	return append([]core.CollectWindow{}, w.windows...)
+}
+
+func TestBackfill(t *testing.T) {
+	logrus.SetLevel(logrus.FatalLevel)
+	w := newMockWorkflowMap()
+
+	Convey("Running a backfill task", t, func() {
+		end := time.Now().Add(-time.Hour).Truncate(time.Hour)
+		start := end.Add(-3 * time.Hour)
+		c := &windowCollector{
+			mockMetricManager: &mockMetricManager{acceptSubscriptions: true},
+			failAt:            start.Add(time.Hour),
+		}
+		store := &memoryTaskStore{}
+		s := New(GetDefaultConfig())
+		s.SetMetricManager(c)
+		s.SetTaskStore(store)
+		s.Start()
+		defer s.Stop()
+		tsk, errs := s.CreateTask(schedule.NewBackfillSchedule(start, end, time.Hour, 0), w, true)
+		So(errs.Errors(), ShouldBeEmpty)
+		for i := 0; i < 200 && tsk.State() != core.TaskEnded; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		Convey("Should collect each window of the range in order, and a failed window again", func() {
+			So(tsk.State(), ShouldEqual, core.TaskEnded)
+			So(c.collected(), ShouldResemble, []core.CollectWindow{
+				{Start: start, End: start.Add(time.Hour)},
+				{Start: start.Add(time.Hour), End: start.Add(2 * time.Hour)},
+				{Start: start.Add(time.Hour), End: start.Add(2 * time.Hour)},
+				{Start: start.Add(2 * time.Hour), End: end},
+			})
+		})
+		Convey("Should keep the progress of the backfill in the task store", func() {
+			var progress *time.Time
+			for i := 0; i < 100; i++ {
+				if saved := store.saved(); len(saved) == 1 {
+					if progress = saved[0].Task.Schedule.Progress; progress != nil && progress.Equal(end) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			So(progress, ShouldNotBeNil)
+			So(progress.Equal(end), ShouldBeTrue)
+		})
+	})
+}
@@ -22,8 +22,11 @@ limitations under the License.
 package scheduler
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -44,7 +47,25 @@ type mockMetricManager struct {
 	failValidatingMetrics      bool
 	failValidatingMetricsAfter int
 	failuredSoFar              int
+	failCollecting             bool
+	panicCollecting            bool
 	autodiscoverPaths          []string
+	// collectDuration delays each collection, collecting and maxCollecting
+	// track the collections running at the same time
+	collectDuration time.Duration
+	collecting      int32
+	maxCollecting   int32
+	// failFirst is the number of collections which fail before collecting succeeds
+	failFirst int32
+	// collectTimes holds the start time of each collection
+	collectTimes []time.Time
+	timesMutex   sync.Mutex
+}
+
+func (m *mockMetricManager) CollectTimes() []time.Time {
+	m.timesMutex.Lock()
+	defer m.timesMutex.Unlock()
+	return append([]time.Time(nil), m.collectTimes...)
 }
 
 func (m *mockMetricManager) StreamMetrics(string, map[string]map[string]string, time.Duration, int64) (chan []core.Metric, chan error, []error) {
@@ -52,6 +73,23 @@ func (m *mockMetricManager) StreamMetrics(string, map[string]map[string]string,
 }
 
 func (m *mockMetricManager) CollectMetrics(string, map[string]map[string]string) ([]core.Metric, []error) {
+	m.timesMutex.Lock()
+	m.collectTimes = append(m.collectTimes, time.Now())
+	m.timesMutex.Unlock()
+	n := atomic.AddInt32(&m.collecting, 1)
+	defer atomic.AddInt32(&m.collecting, -1)
+	for max := atomic.LoadInt32(&m.maxCollecting); n > max; max = atomic.LoadInt32(&m.maxCollecting) {
+		if atomic.CompareAndSwapInt32(&m.maxCollecting, max, n) {
+			break
+		}
+	}
+	time.Sleep(m.collectDuration)
+	if m.panicCollecting {
+		panic("collector panicked")
+	}
+	if m.failCollecting || atomic.AddInt32(&m.failFirst, -1) >= 0 {
+		return nil, []error{errors.New("collection failed")}
+	}
 	return nil, nil
 }
 
@@ -211,7 +249,8 @@ func TestScheduler(t *testing.T) {
 			So(err, ShouldNotBeNil)
 			fmt.Printf("%d", len(err.Errors()))
 			So(len(err.Errors()), ShouldBeGreaterThan, 0)
-			So(err.Errors()[0], ShouldResemble, serror.New(errors.New("metric validation error")))
+			So(err.Errors()[0], ShouldResemble, core.AttributeTaskError(serror.New(errors.New("metric validation error")),
+				core.TaskPhaseDependencies, core.TaskErrorInvalidDependency))
 
 		})
 		Convey("returns an error when scheduler started and MetricManager is not set", func() {
@@ -222,6 +261,21 @@ func TestScheduler(t *testing.T) {
 			So(err, ShouldResemble, ErrMetricManagerNotSet)
 
 		})
+		Convey("returns an error when scheduler started and a worker pool is empty", func() {
+			s1 := New(GetDefaultConfig(), WithPoolSize(0))
+			s1.SetMetricManager(c)
+			err := s1.Start()
+			So(err, ShouldEqual, ErrInvalidPoolSize)
+			So(s1.state, ShouldEqual, schedulerStopped)
+		})
+		Convey("sizes the process and publish queues and pools on their own", func() {
+			s1 := New(GetDefaultConfig(), WithPoolSize(2), WithProcessPoolSize(1), WithPublishPoolSize(3), WithPublishQueueSize(7))
+			So(s1.workManager.collectWkrs, ShouldHaveLength, 2)
+			So(s1.workManager.processWkrs, ShouldHaveLength, 1)
+			So(s1.workManager.publishWkrs, ShouldHaveLength, 3)
+			So(s1.workManager.processq.limit, ShouldEqual, s1.workManager.collectq.limit)
+			So(s1.workManager.publishq.limit, ShouldEqual, 7)
+		})
 		Convey("returns an error when a schedule does not validate", func() {
 			s1 := New(GetDefaultConfig())
 			s1.Start()
@@ -229,7 +283,8 @@ func TestScheduler(t *testing.T) {
 			_, err := s1.CreateTask(sch, w, false)
 			So(err, ShouldNotBeNil)
 			So(len(err.Errors()), ShouldBeGreaterThan, 0)
-			So(err.Errors()[0], ShouldResemble, serror.New(ErrSchedulerNotStarted))
+			So(err.Errors()[0], ShouldResemble, core.AttributeTaskError(serror.New(ErrSchedulerNotStarted),
+				core.TaskPhaseScheduler, core.TaskErrorSchedulerUnavailable))
 			s1.metricManager = c
 			s1.Start()
 			_, err1 := s1.CreateTask(schedule.NewWindowedSchedule(time.Second*0, nil, nil, 0), w, false)
@@ -284,6 +339,266 @@ func TestScheduler(t *testing.T) {
 			So(len(err.Errors()), ShouldEqual, 0)
 			So(tsk.(*task).maxMetricsBuffer, ShouldEqual, 100)
 		})
+		Convey("returns a task with a base config beneath the workflow config", func() {
+			base := cdata.NewNode()
+			base.AddItem("username", ctypes.ConfigValueStr{Value: "admin"})
+			base.AddItem("timeout", ctypes.ConfigValueInt{Value: 5})
+			tsk, err := s.CreateTask(schedule.NewWindowedSchedule(time.Second, nil, nil, 0), w, false, core.OptionBaseConfig(base))
+			So(len(err.Errors()), ShouldEqual, 0)
+			So(tsk.BaseConfig(), ShouldEqual, base)
+			t := tsk.(*task).workflow.configTree.Get([]string{"foo", "bar"}).Table()
+			So(t["username"], ShouldResemble, ctypes.ConfigValueStr{Value: "root"})
+			So(t["timeout"], ShouldResemble, ctypes.ConfigValueInt{Value: 5})
+		})
+	})
+	Convey("ListTasks()", t, func() {
+		c := new(mockMetricManager)
+		s := New(GetDefaultConfig())
+		s.SetMetricManager(c)
+		So(s.Start(), ShouldBeNil)
+		w1 := wmap.NewWorkflowMap()
+		w1.Collect.AddMetric("/foo/bar", 1)
+		w2 := wmap.NewWorkflowMap()
+		w2.Collect.AddMetric("/baz/qux", 1)
+		for i, n := range []string{"web-1", "web-2", "db-1"} {
+			w, service := w1, "web"
+			if i == 2 {
+				w, service = w2, "db"
+			}
+			labels := map[string]string{"env": "prod", "service": service}
+			_, te := s.CreateTask(schedule.NewWindowedSchedule(time.Second, nil, nil, 0), w, false, core.SetTaskName(n), core.OptionLabels(labels))
+			So(te.Errors(), ShouldBeEmpty)
+		}
+		names := func(tasks []core.Task) []string {
+			n := []string{}
+			for _, t := range tasks {
+				n = append(n, t.GetName())
+			}
+			return n
+		}
+		Convey("lists every task in the order they were created", func() {
+			tasks, err := s.ListTasks(TaskFilter{})
+			So(err, ShouldBeNil)
+			So(names(tasks), ShouldResemble, []string{"web-1", "web-2", "db-1"})
+			So(len(s.GetTasks()), ShouldEqual, 3)
+		})
+		Convey("lists the tasks by name prefix", func() {
+			tasks, err := s.ListTasks(TaskFilter{NamePrefix: "web-"})
+			So(err, ShouldBeNil)
+			So(names(tasks), ShouldResemble, []string{"web-1", "web-2"})
+		})
+		Convey("lists the tasks by namespace prefix", func() {
+			tasks, err := s.ListTasks(TaskFilter{NamespacePrefix: "/baz"})
+			So(err, ShouldBeNil)
+			So(names(tasks), ShouldResemble, []string{"db-1"})
+		})
+		Convey("lists the tasks by label selector", func() {
+			tasks, err := s.GetTasksByLabel("env=prod, service=web")
+			So(err, ShouldBeNil)
+			So(names(tasks), ShouldResemble, []string{"web-1", "web-2"})
+			tasks, err = s.GetTasksByLabel("service=db")
+			So(err, ShouldBeNil)
+			So(names(tasks), ShouldResemble, []string{"db-1"})
+			tasks, err = s.GetTasksByLabel("env=dev,service=web")
+			So(err, ShouldBeNil)
+			So(tasks, ShouldBeEmpty)
+			tasks, err = s.GetTasksByLabel("")
+			So(err, ShouldBeNil)
+			So(len(tasks), ShouldEqual, 3)
+			_, err = s.GetTasksByLabel("env")
+			So(err, ShouldEqual, ErrInvalidLabelSelector)
+			_, err = s.GetTasksByLabel("=prod")
+			So(err, ShouldEqual, ErrInvalidLabelSelector)
+		})
+		Convey("forgets the labels of the removed tasks", func() {
+			web, _ := s.GetTaskByName("web-1")
+			So(s.RemoveTask(web.ID()), ShouldBeNil)
+			tasks, err := s.GetTasksByLabel("service=web")
+			So(err, ShouldBeNil)
+			So(names(tasks), ShouldResemble, []string{"web-2"})
+			db, _ := s.GetTaskByName("db-1")
+			So(s.RemoveTask(db.ID()), ShouldBeNil)
+			So(s.tasks.byLabel, ShouldNotContainKey, "service=db")
+		})
+		Convey("lists the tasks by state", func() {
+			tasks, err := s.ListTasks(TaskFilter{States: []core.TaskState{core.TaskSpinning}})
+			So(err, ShouldBeNil)
+			So(tasks, ShouldBeEmpty)
+			tasks, err = s.ListTasks(TaskFilter{States: []core.TaskState{core.TaskSpinning, core.TaskStopped}})
+			So(err, ShouldBeNil)
+			So(len(tasks), ShouldEqual, 3)
+		})
+		Convey("pages through the tasks", func() {
+			tasks, err := s.ListTasks(TaskFilter{Offset: 1, Limit: 1})
+			So(err, ShouldBeNil)
+			So(names(tasks), ShouldResemble, []string{"web-2"})
+			tasks, err = s.ListTasks(TaskFilter{Offset: 3})
+			So(err, ShouldBeNil)
+			So(tasks, ShouldBeEmpty)
+		})
+		Convey("returns an error for a negative offset or limit", func() {
+			_, err := s.ListTasks(TaskFilter{Offset: -1})
+			So(err, ShouldEqual, ErrInvalidTaskFilter)
+			_, err = s.ListTasks(TaskFilter{Limit: -1})
+			So(err, ShouldEqual, ErrInvalidTaskFilter)
+		})
+	})
+	Convey("a task whose collection panics", t, func() {
+		c := &mockMetricManager{panicCollecting: true}
+		s := New(GetDefaultConfig())
+		s.SetMetricManager(c)
+		So(s.Start(), ShouldBeNil)
+		live := s.Stats().LiveWorkers
+		w := wmap.NewWorkflowMap()
+		w.Collect.AddMetric("/foo/bar", 1)
+		tsk, te := s.CreateTask(schedule.NewWindowedSchedule(time.Millisecond*50, nil, nil, 0), w, false)
+		So(te.Errors(), ShouldBeEmpty)
+		// the mock metric manager fails to subscribe, so the task is spun
+		// without being started
+		tsk.(*task).Spin()
+		time.Sleep(time.Millisecond * 180)
+		tsk.(*task).Stop()
+		Convey("fails its firings and keeps the workers running", func() {
+			stats := s.Stats()
+			So(stats.PanickedJobs, ShouldBeGreaterThanOrEqualTo, 2)
+			So(stats.FailedRuns, ShouldBeGreaterThanOrEqualTo, 2)
+			So(stats.LiveWorkers, ShouldEqual, live)
+			So(tsk.LastError().Error(), ShouldContainSubstring, "collector panicked")
+			So(tsk.LastError().Error(), ShouldContainSubstring, "goroutine")
+		})
+	})
+	Convey("a task on a trigger schedule", t, func() {
+		c := new(mockMetricManager)
+		s := New(GetDefaultConfig())
+		s.SetMetricManager(c)
+		So(s.Start(), ShouldBeNil)
+		w := wmap.NewWorkflowMap()
+		w.Collect.AddMetric("/foo/bar", 1)
+		sch, trigger := schedule.NewTriggerSchedule()
+		tsk, te := s.CreateTask(sch, w, false)
+		So(te.Errors(), ShouldBeEmpty)
+		// the mock metric manager fails to subscribe, so the task is spun
+		// without being started
+		tsk.(*task).Spin()
+		Convey("fires on each trigger and ends once the trigger is closed", func() {
+			time.Sleep(time.Millisecond * 20)
+			So(c.CollectTimes(), ShouldBeEmpty)
+			for i := 0; i < 2; i++ {
+				trigger <- struct{}{}
+				time.Sleep(time.Millisecond * 20)
+			}
+			So(c.CollectTimes(), ShouldHaveLength, 2)
+			So(tsk.HitCount(), ShouldEqual, 2)
+			close(trigger)
+			time.Sleep(time.Millisecond * 20)
+			So(tsk.State(), ShouldEqual, core.TaskEnded)
+		})
+	})
+	Convey("RunTaskNow()", t, func() {
+		c := new(mockMetricManager)
+		s := New(GetDefaultConfig())
+		s.SetMetricManager(c)
+		So(s.Start(), ShouldBeNil)
+		w := wmap.NewWorkflowMap()
+		w.Collect.AddMetric("/foo/bar", 1)
+		tsk, te := s.CreateTask(schedule.NewWindowedSchedule(time.Hour, nil, nil, 0), w, false)
+		So(te.Errors(), ShouldBeEmpty)
+		Convey("returns the error subscribing a stopped task", func() {
+			err := s.RunTaskNow(context.Background(), tsk.ID(), false)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "metric validation error")
+			So(c.CollectTimes(), ShouldBeEmpty)
+		})
+		Convey("with the plugins of the task subscribed", func() {
+			// the mock metric manager fails to subscribe, so the task is
+			// marked as subscribed
+			atomic.StoreInt32(&tsk.(*task).subscribed, 1)
+			Convey("fires the task without counting the firing", func() {
+				So(s.RunTaskNow(context.Background(), tsk.ID(), false), ShouldBeNil)
+				So(c.CollectTimes(), ShouldHaveLength, 1)
+				So(tsk.HitCount(), ShouldEqual, 0)
+				So(tsk.State(), ShouldEqual, core.TaskStopped)
+			})
+			Convey("returns the error of the firing", func() {
+				c.failCollecting = true
+				err := s.RunTaskNow(context.Background(), tsk.ID(), false)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "collection failed")
+				So(tsk.FailedCount(), ShouldEqual, 0)
+				So(tsk.LastError(), ShouldBeNil)
+			})
+			Convey("counts the firing when recorded", func() {
+				c.failCollecting = true
+				So(s.RunTaskNow(context.Background(), tsk.ID(), true), ShouldNotBeNil)
+				So(tsk.HitCount(), ShouldEqual, 1)
+				So(tsk.FailedCount(), ShouldEqual, 1)
+				So(tsk.LastError().Error(), ShouldEqual, "collection failed")
+			})
+			Convey("stops waiting once the context is done", func() {
+				c.collectDuration = time.Millisecond * 200
+				ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+				defer cancel()
+				So(s.RunTaskNow(ctx, tsk.ID(), false), ShouldResemble, context.DeadlineExceeded)
+			})
+		})
+		Convey("returns an error for an ended task", func() {
+			tsk.(*task).setState(core.TaskEnded)
+			So(s.RunTaskNow(context.Background(), tsk.ID(), false), ShouldEqual, ErrTaskEndedNotRunnable)
+		})
+		Convey("returns an error for an unknown task", func() {
+			err := s.RunTaskNow(context.Background(), "unknown", false)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, ErrTaskNotFound.Error())
+		})
+	})
+	Convey("UpdateTaskSchedule()", t, func() {
+		c := new(mockMetricManager)
+		s := New(GetDefaultConfig())
+		s.SetMetricManager(c)
+		So(s.Start(), ShouldBeNil)
+		w := wmap.NewWorkflowMap()
+		w.Collect.AddMetric("/foo/bar", 1)
+		tsk, te := s.CreateTask(schedule.NewWindowedSchedule(time.Second*5, nil, nil, 0), w, false)
+		So(te.Errors(), ShouldBeEmpty)
+		Convey("fires a running task on the new schedule", func() {
+			// the mock metric manager fails to subscribe, so the task is
+			// spun without being started
+			tsk.(*task).Spin()
+			time.Sleep(time.Millisecond * 50)
+			So(c.CollectTimes(), ShouldHaveLength, 1)
+			swapped := time.Now()
+			sch := schedule.NewWindowedSchedule(time.Millisecond*50, nil, nil, 0)
+			So(s.UpdateTaskSchedule(tsk.ID(), sch), ShouldBeNil)
+			So(tsk.(*task).Schedule(), ShouldEqual, sch)
+			time.Sleep(time.Millisecond * 280)
+			tsk.(*task).Stop()
+			// the first collection was on the previous schedule, the
+			// following ones are 50ms apart
+			times := c.CollectTimes()
+			So(len(times), ShouldBeBetweenOrEqual, 5, 7)
+			So(times[1], ShouldHappenAfter, swapped)
+			for i := 2; i < len(times); i++ {
+				So(times[i].Sub(times[i-1]), ShouldBeBetween, time.Millisecond*30, time.Millisecond*75)
+			}
+		})
+		Convey("returns an error for an invalid schedule", func() {
+			err := s.UpdateTaskSchedule(tsk.ID(), schedule.NewWindowedSchedule(0, nil, nil, 0))
+			So(err, ShouldNotBeNil)
+			So(tsk.(*task).Schedule().(*schedule.WindowedSchedule).Interval, ShouldEqual, time.Second*5)
+		})
+		Convey("returns an error for a streaming schedule", func() {
+			err := s.UpdateTaskSchedule(tsk.ID(), schedule.NewStreamingSchedule())
+			So(err, ShouldEqual, ErrStreamingScheduleNotUpdatable)
+		})
+		Convey("returns an error for an ended task", func() {
+			tsk.(*task).state = core.TaskEnded
+			err := s.UpdateTaskSchedule(tsk.ID(), schedule.NewWindowedSchedule(time.Second, nil, nil, 0))
+			So(err, ShouldEqual, ErrTaskEndedScheduleNotUpdatable)
+		})
+		Convey("returns an error for an unknown task", func() {
+			err := s.UpdateTaskSchedule("1234", schedule.NewWindowedSchedule(time.Second, nil, nil, 0))
+			So(err, ShouldNotBeNil)
+		})
 	})
 	Convey("Stop()", t, func() {
 		Convey("Should set scheduler state to SchedulerStopped", func() {
@@ -295,6 +610,80 @@ func TestScheduler(t *testing.T) {
 			So(scheduler.state, ShouldEqual, schedulerStopped)
 		})
 	})
+	Convey("New()", t, func() {
+		Convey("uses the default configuration when none is given", func() {
+			s := New(nil)
+			So(s.workManager.collectWkrSize, ShouldEqual, defaultWorkManagerPoolSize)
+			So(s.workManager.collectQSize, ShouldEqual, defaultWorkManagerQueueSize)
+			So(s.workManager.qFullPolicy, ShouldEqual, PolicyDropNewest)
+		})
+		Convey("applies the options over the configuration", func() {
+			cfg := GetDefaultConfig()
+			s := New(cfg, WithPoolSize(2), WithQueueSize(7), WithQueueFullPolicy(PolicyBlock))
+			So(s.workManager.collectWkrSize, ShouldEqual, 2)
+			So(s.workManager.publishWkrSize, ShouldEqual, 2)
+			So(s.workManager.collectQSize, ShouldEqual, 7)
+			So(s.workManager.qFullPolicy, ShouldEqual, PolicyBlock)
+			So(cfg.WorkManagerPoolSize, ShouldEqual, defaultWorkManagerPoolSize)
+		})
+	})
+	Convey("Health()", t, func() {
+		Convey("returns an error when the scheduler is not started", func() {
+			scheduler := New(GetDefaultConfig())
+			scheduler.SetMetricManager(new(mockMetricManager))
+			So(scheduler.Health().Err(), ShouldEqual, ErrSchedulerNotStarted)
+		})
+		Convey("returns nil when the scheduler is started", func() {
+			scheduler := New(GetDefaultConfig())
+			scheduler.SetMetricManager(new(mockMetricManager))
+			scheduler.Start()
+			So(scheduler.Health().Err(), ShouldBeNil)
+			Convey("and an error once it is stopped", func() {
+				scheduler.Stop()
+				So(scheduler.Health().Err(), ShouldEqual, ErrSchedulerNotStarted)
+			})
+		})
+		Convey("returns an error when the metric manager is unset", func() {
+			scheduler := New(GetDefaultConfig())
+			scheduler.SetMetricManager(new(mockMetricManager))
+			scheduler.Start()
+			scheduler.metricManager = nil
+			So(scheduler.Health().Err(), ShouldEqual, ErrMetricManagerNotSet)
+		})
+		Convey("reports the status of the scheduler", func() {
+			scheduler := New(GetDefaultConfig())
+			scheduler.SetMetricManager(new(mockMetricManager))
+			So(scheduler.Health().Status, ShouldEqual, HealthUnavailable)
+			So(scheduler.Health().Ready, ShouldBeFalse)
+			scheduler.Start()
+			defer scheduler.Stop()
+			h := scheduler.Health()
+			So(h.Status, ShouldEqual, HealthOK)
+			So(h.Ready, ShouldBeTrue)
+			So(h.State, ShouldEqual, "started")
+			So(h.MetricManager, ShouldBeTrue)
+			So(h.Reasons, ShouldBeEmpty)
+			Convey("degraded while tasks are failing", func() {
+				at := time.Now()
+				scheduler.tasks.add(&task{id: "1", name: "failing", lastFireTime: at, lastFailureTime: at})
+				scheduler.tasks.add(&task{id: "2", name: "disabled", state: core.TaskDisabled})
+				scheduler.tasks.add(&task{id: "3", name: "running", lastFireTime: at})
+				h := scheduler.Health()
+				So(h.Status, ShouldEqual, HealthDegraded)
+				So(h.Ready, ShouldBeTrue)
+				So(h.FailingTasks, ShouldEqual, 2)
+				So(h.Reasons, ShouldResemble, []string{"tasks failing"})
+			})
+			Convey("degraded while its task store is failing", func() {
+				scheduler.persistence.setError(errors.New("disk full"))
+				h := scheduler.Health()
+				So(h.Status, ShouldEqual, HealthDegraded)
+				So(h.TaskStoreError, ShouldEqual, "disk full")
+				scheduler.persistence.setError(nil)
+				So(scheduler.Health().Status, ShouldEqual, HealthOK)
+			})
+		})
+	})
 	Convey("SetMetricManager()", t, func() {
 		Convey("Should set metricManager for scheduler", func() {
 			scheduler := New(GetDefaultConfig())
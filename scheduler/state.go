@@ -0,0 +1,160 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/intelsdi-x/snap/core"
+)
+
+// StateVersion is the version of the document written by ExportState, read
+// back by ImportState
+const StateVersion = 1
+
+var (
+	// ErrUnsupportedStateVersion - The error message for a scheduler state document of a version ImportState cannot read
+	ErrUnsupportedStateVersion = errors.New("Unsupported scheduler state version")
+)
+
+// SchedulerState is the document of the state of a scheduler written by ExportState
+type SchedulerState struct {
+	Version    int            `json:"version"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Tasks      []TaskSnapshot `json:"tasks"`
+}
+
+// TaskSnapshot is a task of an exported state: the creation request the task
+// is created again from, its state and its stats
+type TaskSnapshot struct {
+	ID    string                    `json:"id"`
+	State string                    `json:"state"`
+	Task  *core.TaskCreationRequest `json:"task"`
+	Stats core.TaskStats            `json:"stats"`
+}
+
+// ExportState writes to w a single versioned document of the tasks of the
+// scheduler, with their state and stats, to be read back by ImportState on
+// another host or kept as a backup independently of the task store. The
+// tasks SaveTasks does not save are not exported.
+func (s *scheduler) ExportState(w io.Writer) error {
+	saved, err := s.savedTasks()
+	if err != nil {
+		return err
+	}
+	state := SchedulerState{
+		Version:    StateVersion,
+		ExportedAt: time.Now(),
+		Tasks:      make([]TaskSnapshot, 0, len(saved)),
+	}
+	for _, st := range saved {
+		snapshot := TaskSnapshot{
+			ID:    st.ID,
+			State: st.State,
+			Task:  st.Task,
+		}
+		if t := s.tasks.Get(st.ID); t != nil {
+			snapshot.Stats = t.Stats()
+		}
+		state.Tasks = append(state.Tasks, snapshot)
+	}
+	return json.NewEncoder(w).Encode(state)
+}
+
+// ImportState creates the tasks of a document written by ExportState under
+// their original ids, after the tasks they depend on. The missed and failed
+// counts of a task and its last failure are restored along with its fired
+// counts before the task is started again if it was running, and a task
+// which was disabled is restored disabled. A task failing to be imported
+// does not stop the others from being imported; an error is returned for
+// each of them.
+func (s *scheduler) ImportState(r io.Reader) []error {
+	var state SchedulerState
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return []error{err}
+	}
+	if state.Version < 1 || state.Version > StateVersion {
+		return []error{fmt.Errorf("%v: %d", ErrUnsupportedStateVersion, state.Version)}
+	}
+	saved := make([]savedTask, 0, len(state.Tasks))
+	stats := make(map[string]core.TaskStats, len(state.Tasks))
+	for _, snapshot := range state.Tasks {
+		saved = append(saved, savedTask{
+			ID:    snapshot.ID,
+			State: snapshot.State,
+			Task:  snapshot.Task,
+		})
+		stats[snapshot.ID] = snapshot.Stats
+	}
+	var errs []error
+	for _, st := range orderByDependencies(saved) {
+		if err := s.importTask(st, stats[st.ID]); err != nil {
+			schedulerLogger.WithFields(log.Fields{
+				"_block":  "import-state",
+				"_error":  err.Error(),
+				"task-id": st.ID,
+			}).Error(ErrTaskNotRestored)
+			errs = append(errs, fmt.Errorf("%v: ID(%v): %v", ErrTaskNotRestored, st.ID, err))
+		}
+	}
+	return errs
+}
+
+// importTask creates a task of an imported state stopped, restores its
+// stats, then starts it if it was running
+func (s *scheduler) importTask(st savedTask, stats core.TaskStats) error {
+	running := st.State == core.TaskSpinning.String()
+	if running {
+		st.State = core.TaskStopped.String()
+	}
+	if err := s.loadTask(st); err != nil {
+		return err
+	}
+	t, err := s.getTask(st.ID)
+	if err != nil {
+		return err
+	}
+	t.restoreStats(stats)
+	if running {
+		if errs := s.StartTask(st.ID); len(errs) > 0 {
+			return errs[0]
+		}
+	}
+	return nil
+}
+
+// restoreStats sets the missed and failed counts and the last failure of a
+// task which is not running to the ones of imported stats
+func (t *task) restoreStats(stats core.TaskStats) {
+	t.missedIntervals = stats.MissedCount
+	t.failureMutex.Lock()
+	defer t.failureMutex.Unlock()
+	t.failedRuns = stats.FailedCount
+	t.lastFailureMessage = stats.LastFailureMessage
+	if stats.LastFailureMessage != "" {
+		t.lastFailure = errors.New(stats.LastFailureMessage)
+	}
+}
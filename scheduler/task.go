@@ -20,11 +20,13 @@ limitations under the License.
 package scheduler
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -32,6 +34,7 @@ import (
 	"github.com/pborman/uuid"
 
 	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/cdata"
 	"github.com/intelsdi-x/snap/core/scheduler_event"
 	"github.com/intelsdi-x/snap/core/serror"
 	"github.com/intelsdi-x/snap/grpc/controlproxy"
@@ -44,6 +47,15 @@ const (
 	DefaultDeadlineDuration = time.Second * 5
 	// DefaultStopOnFailure is used to set the number of failures before a task is disabled
 	DefaultStopOnFailure = 10
+	// DefaultMaxConcurrent is the default number of firings of a task which may run at the same time
+	DefaultMaxConcurrent = 1
+	// DefaultMaxCatchUp is the default number of missed firings a catching up task fires on a tick
+	DefaultMaxCatchUp = 10
+
+	// nextRunMaxFireTimes and nextRunHorizon bound the preview of the schedule
+	// of a firing task computing its next run
+	nextRunMaxFireTimes = 16
+	nextRunHorizon      = 366 * 24 * time.Hour
 )
 
 var (
@@ -55,6 +67,8 @@ var (
 	ErrTaskNotStopped = errors.New("Task must be stopped")
 	// ErrTaskHasAlreadyBeenAdded - The error message for task has already been added
 	ErrTaskHasAlreadyBeenAdded = errors.New("Task has already been added")
+	// ErrTaskNameAlreadyInUse - The error message for a task name used by another task
+	ErrTaskNameAlreadyInUse = errors.New("Task name is already in use")
 	// ErrTaskDisabledOnFailures - The error message for task disabled due to consecutive failures
 	ErrTaskDisabledOnFailures = errors.New("Task disabled due to consecutive failures")
 	// ErrTaskNotDisabled - The error message for task must be disabled
@@ -62,12 +76,23 @@ var (
 )
 
 type task struct {
-	sync.Mutex //protects state
+	sync.Mutex //protects state transitions
+
+	// stateMutex guards reads of the state so it can be queried while the
+	// task is firing
+	stateMutex sync.RWMutex
+
+	// lifecycleMutex serializes start and stop requests issued against the task
+	lifecycleMutex sync.Mutex
+
+	// pausing is set when the task is stopping to be paused rather than
+	// stopped, it is guarded by the task mutex
+	pausing bool
 
 	id                 string
 	name               string
-	schResponseChan    chan schedule.Response
 	killChan           chan struct{}
+	doneChan           chan struct{}
 	schedule           schedule.Schedule
 	workflow           *schedulerWorkflow
 	state              core.TaskState
@@ -76,19 +101,126 @@ type task struct {
 	manager            managesWork
 	metricsManager     managesMetrics
 	deadlineDuration   time.Duration
-	hitCount           uint
+	timeout            time.Duration
+	stopAt             time.Time
+	hitCount           uint64
 	missedIntervals    uint
 	failureMutex       sync.Mutex
 	failedRuns         uint
 	lastFailureMessage string
+	lastFailure        error
 	lastFailureTime    time.Time
 	stopOnFailure      int
+	maxConcurrent      int
+	retries            int
+	retryBackoff       time.Duration
+	missedFirePolicy   core.MissedFirePolicy
+	maxCatchUp         int
+	priority           int
 	eventEmitter       gomit.Emitter
 	RemoteManagers     managers
 	isStream           bool
+	// queueFullPolicy names the policy applied to the jobs of the task when
+	// their work queue is full, the policy of the scheduler applies if empty
+	queueFullPolicy string
+	// autodiscovered is set for the tasks created from an autodiscover path
+	autodiscovered bool
+	// deadLetter keeps the metrics of a publish job of the task which failed
+	// once its retries were exhausted, nil if they are dropped
+	deadLetter func(t *task, pu *publishNode, mts []core.Metric, errs []error)
+	// breakers skip the collections of the task while the metrics of the
+	// task are failing, nil if disabled
+	breakers *circuitBreakers
+	// throttle limits the collections of the task running at once with the
+	// collections of the other tasks of the same metrics, nil if unlimited
+	throttle *collectionThrottle
+	// tracer returns the tracer of the firings of the task, nil if they
+	// are not traced
+	tracer func() Tracer
 
 	maxCollectDuration time.Duration
 	maxMetricsBuffer   int64
+
+	// collectWindow is the window over which the metrics collected by the
+	// firings are accumulated in window before being processed and published
+	collectWindow time.Duration
+	window        metricWindow
+
+	// subscribed is set to 1 while the plugins of the task are subscribed
+	subscribed int32
+	// subscriptionMutex serializes the unsubscription of the plugins of the
+	// task with the swap of its workflow
+	subscriptionMutex sync.Mutex
+
+	// firings is the number of firings in flight when the firings of the
+	// task may overlap, firingsGroup lets the spin loop wait on them
+	firings      int32
+	firingsGroup sync.WaitGroup
+	// skippedTicks counts the ticks not fired as the previous firings had
+	// not finished
+	skippedTicks uint64
+	// droppedJobs counts the jobs of the task dropped because their deadline
+	// passed or their work queue was full
+	droppedJobs uint64
+	// maxRuns is the number of firings on its schedule after which the task
+	// ends, no maximum if 0, and runs counts these firings
+	maxRuns uint
+	runs    uint64
+	// retriedJobs counts the retries of the failed jobs of the task
+	retriedJobs uint64
+	// abandoned is set to 1 by a scheduler stopping with DrainAbandon, the
+	// metrics buffered by the task being dropped rather than flushed once
+	// it stops
+	abandoned int32
+	// workflowMutex guards the swap of the workflow against the runs off
+	// the schedule, the firings on the schedule are excluded by the lock of
+	// the task
+	workflowMutex sync.RWMutex
+	// flushedBatches counts the publish batches flushed by the workflows the
+	// task ran before its workflow was last swapped
+	flushedBatches uint64
+	// duplicateMetrics counts the metrics dropped as they were collected
+	// more than once by a collection
+	duplicateMetrics uint64
+	// lastFireDuration is how long the last finished firing took in nanoseconds
+	lastFireDuration int64
+	// lastRun is the start of the last firing of the task in nanoseconds
+	// since the epoch, 0 if it never fired. Unlike lastFireTime it is kept
+	// when the task is started again.
+	lastRun int64
+	// fireDurations summarizes how long the finished firings took
+	fireDurations *durationSummary
+	// latencies summarizes how long the jobs of the task took by job type
+	latencies map[jobType]*durationSummary
+	// labels are the key/value labels selecting the task, they are set when
+	// the task is created as the task collection indexes them
+	labels map[string]string
+	// tenant is the tenant the task belongs to, empty if none, and tenants
+	// limits the rate the tasks of the tenant fire at
+	tenant  string
+	tenants *tenantQuotas
+	// history keeps the last runs of the task, nil if disabled, and
+	// historyStore returns the store they are saved in, if any
+	history      *runHistory
+	historyStore func() RunHistoryStore
+	// dependsOn are the ids of the tasks the task fires after, set when the
+	// task is created, and passMetrics whether the metrics they collected
+	// are added to the metrics of the task
+	dependsOn   []string
+	passMetrics bool
+	// upstream holds the runs the tasks the task depends on completed since
+	// the task last fired, guarded by upstreamMutex
+	upstream      upstreamRuns
+	upstreamMutex sync.Mutex
+	// firingOutcomes holds whether each finished overlapping firing failed
+	// until the spin loop accounts for it
+	firingOutcomes []bool
+	outcomesMutex  sync.Mutex
+
+	// scheduleMutex guards the schedule, which may be replaced while the
+	// task is spinning, and rescheduled signals the spin loop when it is
+	scheduleMutex sync.Mutex
+	rescheduled   chan struct{}
 }
 
 //NewTask creates a Task
@@ -110,7 +242,8 @@ func newTask(s schedule.Schedule, wf *schedulerWorkflow, m *workManager, mm mana
 	task := &task{
 		id:               taskID,
 		name:             name,
-		schResponseChan:  make(chan schedule.Response),
+		rescheduled:      make(chan struct{}, 1),
+		fireDurations:    newDurationSummary(),
 		schedule:         s,
 		state:            core.TaskStopped,
 		creationTime:     time.Now(),
@@ -119,14 +252,26 @@ func newTask(s schedule.Schedule, wf *schedulerWorkflow, m *workManager, mm mana
 		metricsManager:   mm,
 		deadlineDuration: DefaultDeadlineDuration,
 		stopOnFailure:    DefaultStopOnFailure,
+		maxConcurrent:    DefaultMaxConcurrent,
+		maxCatchUp:       DefaultMaxCatchUp,
 		eventEmitter:     emitter,
 		RemoteManagers:   mgrs,
 		isStream:         stream,
+		latencies: map[jobType]*durationSummary{
+			collectJobType: newDurationSummary(),
+			processJobType: newDurationSummary(),
+			publishJobType: newDurationSummary(),
+		},
 	}
 	//set options
 	for _, opt := range opts {
 		opt(task)
 	}
+	if task.queueFullPolicy != "" {
+		if _, err := ParseQueueFullPolicy(task.queueFullPolicy); err != nil {
+			return nil, err
+		}
+	}
 	return task, nil
 }
 
@@ -178,13 +323,223 @@ func (t *task) SetDeadlineDuration(d time.Duration) {
 	t.deadlineDuration = d
 }
 
+// Timeout returns how long a worker waits on a job of the task before
+// abandoning it. Zero means the task's jobs never time out.
+func (t *task) Timeout() time.Duration {
+	return t.timeout
+}
+
+func (t *task) SetTimeout(d time.Duration) {
+	t.timeout = d
+}
+
+// StopAt returns the time after which the task ends rather than firing.
+// The zero time means the task runs for as long as its schedule does.
+func (t *task) StopAt() time.Time {
+	return t.stopAt
+}
+
+func (t *task) SetStopAt(at time.Time) {
+	t.stopAt = at
+}
+
+// now returns the current time of the clock shared with the schedules, so
+// that the firing of tasks follows the clock tests set on them
+func now() time.Time {
+	return schedule.CurrentClock().Now()
+}
+
+func (t *task) pastStopTime() bool {
+	return !t.stopAt.IsZero() && !now().Before(t.stopAt)
+}
+
+// MaxConcurrent returns the number of firings of the task which may run at the same time
+func (t *task) MaxConcurrent() int {
+	return t.maxConcurrent
+}
+
+func (t *task) SetMaxConcurrent(n int) {
+	t.maxConcurrent = n
+}
+
+// SkippedCount returns the number of schedule ticks the task did not fire on
+// because its previous firings had not finished.
+func (t *task) SkippedCount() uint64 {
+	return atomic.LoadUint64(&t.skippedTicks)
+}
+
+// DroppedCount returns the number of jobs of the task dropped because their
+// deadline passed before a worker could run them or their work queue was full.
+func (t *task) DroppedCount() uint64 {
+	return atomic.LoadUint64(&t.droppedJobs)
+}
+
+// Retry returns how many times a failed job of the task is retried and the
+// backoff before the first retry. Zero retries means failed jobs are not retried.
+func (t *task) Retry() (int, time.Duration) {
+	return t.retries, t.retryBackoff
+}
+
+func (t *task) SetRetry(count int, backoff time.Duration) {
+	t.retries = count
+	t.retryBackoff = backoff
+}
+
+// MissedFirePolicy returns whether the task fires the firings its schedule missed
+func (t *task) MissedFirePolicy() core.MissedFirePolicy {
+	return t.missedFirePolicy
+}
+
+func (t *task) SetMissedFirePolicy(p core.MissedFirePolicy) {
+	t.missedFirePolicy = p
+}
+
+// MaxCatchUp returns the number of missed firings the task fires at most on a
+// tick of its schedule when catching up
+func (t *task) MaxCatchUp() int {
+	return t.maxCatchUp
+}
+
+func (t *task) SetMaxCatchUp(n int) {
+	t.maxCatchUp = n
+}
+
+// catchUp returns how many of the missed firings the task fires before
+// firing on the tick of its schedule
+func (t *task) catchUp(missed uint) int {
+	switch {
+	case missed == 0 || t.missedFirePolicy == core.MissedFireSkip:
+		return 0
+	case t.missedFirePolicy == core.MissedFireOnce:
+		return 1
+	}
+	if int(missed) > t.maxCatchUp {
+		return t.maxCatchUp
+	}
+	return int(missed)
+}
+
+// Priority returns the priority of the jobs of the task
+func (t *task) Priority() int {
+	return t.priority
+}
+
+// SetPriority sets the priority of the jobs of the task, the jobs of the
+// tasks with a higher priority are worked first
+func (t *task) SetPriority(p int) {
+	t.priority = p
+}
+
+// QueueFullPolicy returns the name of the policy applied to the jobs of the
+// task when their work queue is full, empty if the policy of the scheduler
+// applies
+func (t *task) QueueFullPolicy() string {
+	return t.queueFullPolicy
+}
+
+// SetQueueFullPolicy sets the name of the policy applied to the jobs of the
+// task when their work queue is full
+func (t *task) SetQueueFullPolicy(p string) {
+	t.queueFullPolicy = p
+}
+
+// CollectWindow returns the window over which the metrics collected by the
+// firings of the task are accumulated, 0 if they are not
+func (t *task) CollectWindow() time.Duration {
+	return t.collectWindow
+}
+
+// SetCollectWindow sets the window over which the metrics collected by the
+// firings of the task are accumulated before being processed and published
+func (t *task) SetCollectWindow(d time.Duration) {
+	t.collectWindow = d
+}
+
+// flushBuffers processes and publishes the metrics of the collect window of
+// the task which has not closed yet, then publishes the metrics buffered by
+// the publish batches of its workflow, once the firings are done
+func (t *task) flushBuffers() {
+	if mts, ok := t.window.flush(); ok {
+		t.workflow.workWindow(context.Background(), t, mts, t.RecordFailure)
+	}
+	if t.workflow != nil {
+		t.workflow.flushPublishBatches(t)
+	}
+}
+
+// dropBuffers drops the metrics of the collect window of the task and the
+// metrics buffered by the publish batches of its workflow
+func (t *task) dropBuffers() {
+	t.window.flush()
+	if t.workflow != nil {
+		for _, pu := range t.workflow.publishBatches() {
+			pu.batch.flush()
+		}
+	}
+}
+
+// BaseConfig returns the config applied beneath the config of every
+// namespace of the workflow of the task, or nil
+func (t *task) BaseConfig() *cdata.ConfigDataNode {
+	if t.workflow == nil || t.workflow.configTree == nil {
+		return nil
+	}
+	return t.workflow.configTree.Base()
+}
+
+// SetBaseConfig sets the config applied beneath the config of every
+// namespace of the workflow of the task
+func (t *task) SetBaseConfig(n *cdata.ConfigDataNode) {
+	if t.workflow == nil || t.workflow.configTree == nil {
+		return
+	}
+	t.workflow.configTree.SetBase(n)
+}
+
+// RetriedCount returns the number of times the failed jobs of the task were retried
+func (t *task) RetriedCount() uint64 {
+	return atomic.LoadUint64(&t.retriedJobs)
+}
+
+// work dispatches the job to the work manager and blocks until it has been
+// either run or skipped, retrying it as configured for the task or, when
+// retry is set, for the workflow node of the job. The retries of a job never
+// run past the next tick of an interval schedule.
+func (t *task) work(j job, retry *retryPolicy) []error {
+	j.SetPriority(t.priority)
+	if p, err := ParseQueueFullPolicy(t.queueFullPolicy); t.queueFullPolicy != "" && err == nil {
+		j.SetQueueFullPolicy(p)
+	}
+	policy := retryPolicy{retries: t.retries, backoff: t.retryBackoff}
+	if retry != nil {
+		policy = *retry
+	}
+	if policy.retries > 0 {
+		var until time.Time
+		if sch, ok := t.Schedule().(*schedule.WindowedSchedule); ok {
+			until = now().Add(sch.Interval)
+		}
+		j.SetRetry(policy.retries, policy.backoff, until)
+		j.SetRetryJitter(policy.jitter)
+	}
+	start := time.Now()
+	errs := t.manager.Work(j).Promise().Await()
+	if l, ok := t.latencies[j.Type()]; ok {
+		l.observe(time.Since(start))
+	}
+	if n := j.Retried(); n > 0 {
+		atomic.AddUint64(&t.retriedJobs, uint64(n))
+	}
+	return errs
+}
+
 func (t *task) SetTaskID(id string) {
 	t.id = id
 }
 
 // HitCount returns the number of times the task has fired.
 func (t *task) HitCount() uint {
-	return t.hitCount
+	return uint(atomic.LoadUint64(&t.hitCount))
 }
 
 // Id returns the tasks Id.
@@ -197,6 +552,111 @@ func (t *task) LastRunTime() *time.Time {
 	return &t.lastFireTime
 }
 
+// NextFireTime returns the point in time the task fires next. It returns false
+// when the task is not running, when its schedule does not know when it fires
+// next or when the task will have ended by then.
+func (t *task) NextFireTime() (time.Time, bool) {
+	if state := t.State(); state != core.TaskSpinning && state != core.TaskFiring {
+		return time.Time{}, false
+	}
+	next := t.Schedule().NextFireTime()
+	// while firing, the next firing is not scheduled yet
+	if !next.After(now()) {
+		return time.Time{}, false
+	}
+	if !t.stopAt.IsZero() && !next.Before(t.stopAt) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+// LastRun returns the point in time the last firing of the task started at,
+// false if the task never fired. It is kept when the task is stopped and
+// started again.
+func (t *task) LastRun() (time.Time, bool) {
+	last := atomic.LoadInt64(&t.lastRun)
+	if last == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, last), true
+}
+
+// NextRun returns the point in time the task fires next, as NextFireTime
+// does. While the task is firing, the next firing not being scheduled yet,
+// it is computed from the last run for the schedules which can be previewed.
+// It returns false when it is not known.
+func (t *task) NextRun() (time.Time, bool) {
+	if next, ok := t.NextFireTime(); ok {
+		return next, true
+	}
+	if t.State() != core.TaskFiring {
+		return time.Time{}, false
+	}
+	p, ok := t.Schedule().(schedule.Previewer)
+	if !ok {
+		return time.Time{}, false
+	}
+	last, ok := t.LastRun()
+	if !ok {
+		return time.Time{}, false
+	}
+	for _, next := range p.FireTimes(last, last.Add(nextRunHorizon), nextRunMaxFireTimes) {
+		if !next.After(last) || next.Before(now()) {
+			continue
+		}
+		if !t.stopAt.IsZero() && !next.Before(t.stopAt) {
+			break
+		}
+		return next, true
+	}
+	return time.Time{}, false
+}
+
+// LastFireDuration returns how long the last finished firing of the task took
+func (t *task) LastFireDuration() time.Duration {
+	return time.Duration(atomic.LoadInt64(&t.lastFireDuration))
+}
+
+// DurationStats returns the minimum, maximum, average and 95th percentile of
+// how long the firings of the task took. The percentile only covers the last
+// DurationSampleSize firings.
+func (t *task) DurationStats() (min, max, avg, p95 time.Duration) {
+	return t.fireDurations.stats()
+}
+
+// Stats returns the run counters of the task and the latencies of the
+// collect, process and publish jobs of its workflow
+func (t *task) Stats() core.TaskStats {
+	var buffered uint
+	flushed := atomic.LoadUint64(&t.flushedBatches)
+	if t.workflow != nil {
+		for _, pu := range t.workflow.publishBatches() {
+			n, f := pu.batch.stats()
+			buffered += n
+			flushed += f
+		}
+	}
+	return core.TaskStats{
+		HitCount:           t.HitCount(),
+		MissedCount:        t.MissedCount(),
+		FailedCount:        t.FailedCount(),
+		LastRunTime:        *t.LastRunTime(),
+		LastFailureMessage: t.LastFailureMessage(),
+		Collect:            t.latencies[collectJobType].latency(),
+		Process:            t.latencies[processJobType].latency(),
+		Publish:            t.latencies[publishJobType].latency(),
+		BufferedMetrics:    buffered,
+		FlushedBatches:     flushed,
+		DuplicateMetrics:   atomic.LoadUint64(&t.duplicateMetrics),
+	}
+}
+
+// recordFireDuration records how long a finished firing of the task took
+func (t *task) recordFireDuration(d time.Duration) {
+	atomic.StoreInt64(&t.lastFireDuration, int64(d))
+	t.fireDurations.observe(d)
+}
+
 // MissedCount returns the number of intervals missed.
 func (t *task) MissedCount() uint {
 	return t.missedIntervals
@@ -204,19 +664,47 @@ func (t *task) MissedCount() uint {
 
 // FailedRuns returns the number of intervals missed.
 func (t *task) FailedCount() uint {
+	t.failureMutex.Lock()
+	defer t.failureMutex.Unlock()
 	return t.failedRuns
 }
 
 // LastFailureMessage returns the last error from a task run
 func (t *task) LastFailureMessage() string {
+	t.failureMutex.Lock()
+	defer t.failureMutex.Unlock()
 	return t.lastFailureMessage
 }
 
+// failing returns true if the last firing of the task failed
+func (t *task) failing() bool {
+	t.failureMutex.Lock()
+	defer t.failureMutex.Unlock()
+	return !t.lastFailureTime.IsZero() && t.lastFailureTime == t.lastFireTime
+}
+
+// LastError returns the last error from a task run or nil if the task
+// has never failed
+func (t *task) LastError() error {
+	t.failureMutex.Lock()
+	defer t.failureMutex.Unlock()
+	return t.lastFailure
+}
+
 // State returns state of the task.
 func (t *task) State() core.TaskState {
+	t.stateMutex.RLock()
+	defer t.stateMutex.RUnlock()
 	return t.state
 }
 
+// setState changes the state of the task. The caller must hold the task lock.
+func (t *task) setState(state core.TaskState) {
+	t.stateMutex.Lock()
+	t.state = state
+	t.stateMutex.Unlock()
+}
+
 // Status returns the state of the workflow.
 func (t *task) Status() WorkflowState {
 	return t.workflow.State()
@@ -234,6 +722,83 @@ func (t *task) GetStopOnFailure() int {
 	return t.stopOnFailure
 }
 
+// MaxRuns returns the number of times the task fires on its schedule before
+// it ends, no maximum if 0
+func (t *task) MaxRuns() uint {
+	return t.maxRuns
+}
+
+// SetMaxRuns sets the number of times the task fires on its schedule before
+// it ends
+func (t *task) SetMaxRuns(n uint) {
+	t.maxRuns = n
+}
+
+// ScheduledRuns returns the number of times the task fired on its schedule
+func (t *task) ScheduledRuns() uint {
+	return uint(atomic.LoadUint64(&t.runs))
+}
+
+// SetFiredCount sets the number of times the task fired, and fired on its
+// schedule
+func (t *task) SetFiredCount(hits, scheduledRuns uint) {
+	t.Lock()
+	atomic.StoreUint64(&t.hitCount, uint64(hits))
+	t.Unlock()
+	atomic.StoreUint64(&t.runs, uint64(scheduledRuns))
+}
+
+// Labels returns a copy of the labels of the task
+func (t *task) Labels() map[string]string {
+	return copyLabels(t.labels)
+}
+
+// SetLabels sets the labels of the task, it is meant to be called by
+// OptionLabels before the task is added to the task collection
+func (t *task) SetLabels(labels map[string]string) {
+	t.labels = copyLabels(labels)
+}
+
+// Tenant returns the tenant the task belongs to, empty if none
+func (t *task) Tenant() string {
+	return t.tenant
+}
+
+// SetTenant sets the tenant of the task, it is meant to be called by
+// OptionTenant before the task is added to the task collection
+func (t *task) SetTenant(tenant string) {
+	t.tenant = tenant
+}
+
+// Dependencies returns the ids of the tasks the task depends on and whether
+// the metrics they collected are passed to the task
+func (t *task) Dependencies() ([]string, bool) {
+	return append([]string(nil), t.dependsOn...), t.passMetrics
+}
+
+// SetDependencies sets the dependencies of the task, it is meant to be called
+// by OptionDependsOn before the task is added to the task collection
+func (t *task) SetDependencies(ids []string, passMetrics bool) {
+	t.dependsOn = append([]string(nil), ids...)
+	t.passMetrics = passMetrics
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	if len(labels) == 0 {
+		return nil
+	}
+	c := make(map[string]string, len(labels))
+	for k, v := range labels {
+		c[k] = v
+	}
+	return c
+}
+
+// reachedMaxRuns returns true once the task fired its maximum number of runs
+func (t *task) reachedMaxRuns() bool {
+	return t.maxRuns > 0 && atomic.LoadUint64(&t.runs) >= uint64(t.maxRuns)
+}
+
 // Spin will start a task spinning in its own routine while it waits for its
 // schedule.
 func (t *task) Spin() {
@@ -242,9 +807,13 @@ func (t *task) Spin() {
 	defer t.Unlock()
 	// if this task is a streaming task
 	if t.isStream {
-		t.state = core.TaskSpinning
+		t.setState(core.TaskSpinning)
 		t.killChan = make(chan struct{})
-		go t.stream()
+		t.doneChan = make(chan struct{})
+		go func(done, kill chan struct{}) {
+			defer close(done)
+			t.stream(kill)
+		}(t.doneChan, t.killChan)
 		return
 	}
 
@@ -253,18 +822,25 @@ func (t *task) Spin() {
 	// in time that a task starts spinning. E.g. stopping a task,
 	// waiting a period of time, and starting the task won't show
 	// misses for the interval while stopped.
-	t.lastFireTime = time.Time{}
+	// A task firing the missed firings keeps it so that it fires them.
+	if t.missedFirePolicy == core.MissedFireSkip {
+		t.lastFireTime = time.Time{}
+	}
 
-	if t.state == core.TaskStopped || t.state == core.TaskEnded {
-		t.state = core.TaskSpinning
+	if t.state == core.TaskStopped || t.state == core.TaskEnded || t.state == core.TaskPaused {
+		t.setState(core.TaskSpinning)
 		t.killChan = make(chan struct{})
+		t.doneChan = make(chan struct{})
 		// spin in a goroutine
-		go t.spin()
+		go func(done, kill chan struct{}) {
+			defer close(done)
+			t.spin(kill)
+		}(t.doneChan, t.killChan)
 	}
 }
 
 // Fork stream stuff here
-func (t *task) stream() {
+func (t *task) stream(kill <-chan struct{}) {
 	var consecutiveFailures int
 	resetTime := time.Second * 3
 	for {
@@ -303,9 +879,9 @@ func (t *task) stream() {
 				break
 			}
 			select {
-			case <-t.killChan:
+			case <-kill:
 				t.Lock()
-				t.state = core.TaskStopped
+				t.setState(core.TaskStopped)
 				t.Unlock()
 				done = true
 				event := new(scheduler_event.TaskStoppedEvent)
@@ -320,7 +896,7 @@ func (t *task) stream() {
 				if len(mts) == 0 {
 					continue
 				}
-				t.hitCount++
+				atomic.AddUint64(&t.hitCount, 1)
 				consecutiveFailures = 0
 				t.workflow.StreamStart(t, mts)
 			case err := <-errChan:
@@ -357,14 +933,80 @@ func (t *task) stream() {
 func (t *task) Stop() {
 	t.Lock()
 	defer t.Unlock()
+	// a task being paused is stopped instead
+	t.pausing = false
 	if t.state == core.TaskFiring || t.state == core.TaskSpinning {
-		t.state = core.TaskStopping
+		t.setState(core.TaskStopping)
 		close(t.killChan)
 	}
 }
 
+// Pause stops the task from spinning like Stop, except that the task ends up
+// paused so that its plugins are left subscribed.
+func (t *task) Pause() {
+	t.Lock()
+	defer t.Unlock()
+	if t.state == core.TaskFiring || t.state == core.TaskSpinning {
+		t.pausing = true
+		t.setState(core.TaskStopping)
+		close(t.killChan)
+	}
+}
+
+// waitForStop blocks until a stopping task has finished its last scheduled
+// workflow execution or the timeout elapses. It returns true if the task
+// is not spinning anymore.
+func (t *task) waitForStop(timeout time.Duration) bool {
+	if t.doneChan == nil {
+		return true
+	}
+	select {
+	case <-t.doneChan:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// stopped returns a channel closed once the task is not spinning anymore
+func (t *task) stopped() <-chan struct{} {
+	t.Lock()
+	defer t.Unlock()
+	if t.doneChan == nil {
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	return t.doneChan
+}
+
+// SubscribedMetricTypes returns the metric types the task is subscribed to,
+// with the wildcards of its requested metrics expanded. It returns nil while
+// the task is not subscribed or when its metric manager cannot list them. The
+// returned slice is a copy.
+func (t *task) SubscribedMetricTypes() []core.Metric {
+	if atomic.LoadInt32(&t.subscribed) == 0 {
+		return nil
+	}
+	lister, ok := t.metricsManager.(listsSubscribedMetrics)
+	if !ok {
+		return nil
+	}
+	mts, err := lister.SubscribedMetricTypes(t.id)
+	if err != nil {
+		return nil
+	}
+	return append([]core.Metric(nil), mts...)
+}
+
 // UnsubscribePlugins groups task dependencies by the node they live in workflow and unsubscribe them
+// It does nothing if the plugins are not subscribed, so it is safe to call more than once.
 func (t *task) UnsubscribePlugins() []serror.SnapError {
+	t.subscriptionMutex.Lock()
+	defer t.subscriptionMutex.Unlock()
+	if !atomic.CompareAndSwapInt32(&t.subscribed, 1, 0) {
+		return nil
+	}
 	depGroups := getWorkflowPlugins(t.workflow.processNodes, t.workflow.publishNodes, t.workflow.metrics)
 	var errs []serror.SnapError
 	for k := range depGroups {
@@ -388,36 +1030,68 @@ func (t *task) UnsubscribePlugins() []serror.SnapError {
 			"_block":     "UnsubscribePlugins",
 			"task-id":    t.id,
 			"task-name":  t.name,
-			"task-state": t.state,
+			"task-state": t.State(),
 		}).Error(err)
 	}
 	return errs
 }
 
 // SubscribePlugins groups task dependencies by the node they live in workflow and subscribe them.
+// All the metrics and plugins of a group are subscribed with a single SubscribeDeps call to its manager.
 // If there are errors with subscribing any deps, manage unsubscribing all other deps that may have already been subscribed
 // and then return the errors.
 func (t *task) SubscribePlugins() ([]string, []serror.SnapError) {
-	depGroups := getWorkflowPlugins(t.workflow.processNodes, t.workflow.publishNodes, t.workflow.metrics)
+	return t.subscribePlugins(context.Background())
+}
+
+// subscribePlugins subscribes the task dependencies like SubscribePlugins and
+// stops subscribing the remaining groups once the context is cancelled.
+func (t *task) subscribePlugins(ctx context.Context) ([]string, []serror.SnapError) {
+	subbedDeps, errs := t.subscribeWorkflow(ctx, t.ID(), t.workflow, t.RemoteManagers)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	atomic.StoreInt32(&t.subscribed, 1)
+	return subbedDeps, nil
+}
+
+// subscribeWorkflow subscribes the dependencies of a workflow under the
+// subscription id, through the managers of its nodes. If there are errors
+// with subscribing any deps, the deps already subscribed are unsubscribed.
+func (t *task) subscribeWorkflow(ctx context.Context, id string, wf *schedulerWorkflow, mgrs managers) ([]string, []serror.SnapError) {
+	depGroups := getWorkflowPlugins(wf.processNodes, wf.publishNodes, wf.metrics)
 	var subbedDeps []string
 	for k := range depGroups {
 		var errs []serror.SnapError
-		mgr, err := t.RemoteManagers.Get(k)
-		if err != nil {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, serror.New(err))
+		} else if mgr, err := mgrs.Get(k); err != nil {
 			errs = append(errs, serror.New(err))
 		} else {
-			errs = mgr.SubscribeDeps(t.ID(), depGroups[k].requestedMetrics, depGroups[k].subscribedPlugins, t.workflow.configTree)
+			errs = mgr.SubscribeDeps(id, depGroups[k].requestedMetrics, depGroups[k].subscribedPlugins, wf.configTree)
 		}
 		// If there are errors with subscribing any deps, go through and unsubscribe all other
 		// deps that may have already been subscribed then return the errors.
 		if len(errs) > 0 {
+			namespaces := make([]string, len(depGroups[k].requestedMetrics))
+			for i, m := range depGroups[k].requestedMetrics {
+				namespaces[i] = m.Namespace().String()
+			}
+			taskLogger.WithFields(log.Fields{
+				"_block":     "subscribe-plugins",
+				"task-id":    t.id,
+				"task-name":  t.name,
+				"target":     k,
+				"namespaces": namespaces,
+				"_error":     errs[0].Error(),
+			}).Error("failed to subscribe the plugins of the task")
 			for _, key := range subbedDeps {
-				mgr, err := t.RemoteManagers.Get(key)
+				mgr, err := mgrs.Get(key)
 				if err != nil {
 					errs = append(errs, serror.New(err))
 				} else {
 					// sending empty mts to unsubscribe to indicate task should not start
-					uerrs := mgr.UnsubscribeDeps(t.ID())
+					uerrs := mgr.UnsubscribeDeps(id)
 					errs = append(errs, uerrs...)
 				}
 			}
@@ -426,10 +1100,77 @@ func (t *task) SubscribePlugins() ([]string, []serror.SnapError) {
 		// If subscribed successfully add to subbedDeps
 		subbedDeps = append(subbedDeps, k)
 	}
-
 	return subbedDeps, nil
 }
 
+// unsubscribeWorkflow unsubscribes the dependencies of a workflow subscribed
+// under the subscription id
+func unsubscribeWorkflow(id string, wf *schedulerWorkflow, mgrs managers) []serror.SnapError {
+	var errs []serror.SnapError
+	for k := range getWorkflowPlugins(wf.processNodes, wf.publishNodes, wf.metrics) {
+		mgr, err := mgrs.Get(k)
+		if err != nil {
+			errs = append(errs, serror.New(err))
+			continue
+		}
+		errs = append(errs, mgr.UnsubscribeDeps(id)...)
+	}
+	return errs
+}
+
+// swapWorkflow replaces the workflow of the task with wf, whose nodes are
+// reached through mgrs, without the task firing meanwhile. The plugins of a
+// subscribed task are swapped as well, once the metrics buffered by the
+// collect window and the publish batches of the old workflow are worked
+// through it: wf is subscribed under a staging id before the old plugins are
+// unsubscribed, so that a failure to subscribe leaves the old workflow in
+// place and the plugins kept by the new workflow stay subscribed throughout.
+// Firings overlapping the swap run the workflow they started with.
+func (t *task) swapWorkflow(ctx context.Context, wf *schedulerWorkflow, mgrs managers) []serror.SnapError {
+	t.Lock()
+	defer t.Unlock()
+	t.subscriptionMutex.Lock()
+	defer t.subscriptionMutex.Unlock()
+	old := t.workflow
+	if atomic.LoadInt32(&t.subscribed) == 1 {
+		staging := t.id + "-update"
+		if _, errs := t.subscribeWorkflow(ctx, staging, wf, mgrs); len(errs) > 0 {
+			return errs
+		}
+		defer func() {
+			for _, err := range unsubscribeWorkflow(staging, wf, mgrs) {
+				taskLogger.WithFields(log.Fields{
+					"_block":    "swap-workflow",
+					"task-id":   t.id,
+					"task-name": t.name,
+				}).Error(err)
+			}
+		}()
+		t.flushBuffers()
+		if errs := unsubscribeWorkflow(t.id, old, t.RemoteManagers); len(errs) > 0 {
+			return errs
+		}
+		if _, errs := t.subscribeWorkflow(context.Background(), t.id, wf, mgrs); len(errs) > 0 {
+			// the old plugins are subscribed again so that the task keeps
+			// running its old workflow
+			if _, rerrs := t.subscribeWorkflow(context.Background(), t.id, old, t.RemoteManagers); len(rerrs) > 0 {
+				atomic.StoreInt32(&t.subscribed, 0)
+				errs = append(errs, rerrs...)
+			}
+			return errs
+		}
+	}
+	for _, pu := range old.publishBatches() {
+		_, flushed := pu.batch.stats()
+		atomic.AddUint64(&t.flushedBatches, flushed)
+	}
+	t.RemoteManagers.addMissing(mgrs)
+	t.workflowMutex.Lock()
+	t.workflow = wf
+	t.workflowMutex.Unlock()
+	return nil
+}
+
 //Enable changes the state from Disabled to Stopped
 func (t *task) Enable() error {
 	t.Lock()
@@ -438,7 +1179,7 @@ func (t *task) Enable() error {
 	if t.state != core.TaskDisabled {
 		return ErrTaskNotDisabled
 	}
-	t.state = core.TaskStopped
+	t.setState(core.TaskStopped)
 
 	return nil
 }
@@ -448,71 +1189,131 @@ func (t *task) Kill() {
 	defer t.Unlock()
 	if t.state == core.TaskFiring || t.state == core.TaskSpinning {
 		close(t.killChan)
-		t.state = core.TaskDisabled
+		t.setState(core.TaskDisabled)
 	}
 }
 
+// WMap returns the workflow map of the task, with its secret config values
+// redacted
 func (t *task) WMap() *wmap.WorkflowMap {
-	return t.workflow.workflowMap
+	return t.workflow.workflowMap.Redacted()
 }
 
 func (t *task) Schedule() schedule.Schedule {
+	t.scheduleMutex.Lock()
+	defer t.scheduleMutex.Unlock()
 	return t.schedule
 }
 
-func (t *task) spin() {
+// setSchedule replaces the schedule of the task. A spinning task stops
+// waiting on its previous schedule and fires on the new one from its next
+// tick.
+func (t *task) setSchedule(s schedule.Schedule) {
+	t.scheduleMutex.Lock()
+	t.schedule = s
+	t.scheduleMutex.Unlock()
+	select {
+	case t.rescheduled <- struct{}{}:
+	default:
+	}
+}
+
+// spin fires the task on its schedule until kill is closed, the kill channel
+// is the one of the Spin call starting it as Spin replaces it on restart
+func (t *task) spin(kill <-chan struct{}) {
 	var consecutiveFailures int
 	for {
 		taskLogger.Debug("task spin loop")
-		// Start go routine to wait on schedule
-		go t.waitForSchedule()
+		// Start go routine to wait on schedule, the response channel is
+		// buffered so that a wait on a replaced schedule does not block
+		schResponseChan := make(chan schedule.Response, 1)
+		w := t.waitForSchedule(t.Schedule(), t.lastFireTime, schResponseChan)
 		// wait here on
 		//  schResponseChan - response from schedule
+		//  rescheduled - signals the schedule was replaced
 		//  killChan - signals task needs to be stopped
 		select {
-		case sr := <-t.schResponseChan:
-			switch sr.State() {
+		case <-t.rescheduled:
+			// the tick of the previous schedule is not waited on
+			w.cancel()
+			continue
+		case sr := <-schResponseChan:
+			state := sr.State()
+			// a task past its stop time ends on the tick instead of firing
+			if state == schedule.Active && t.pastStopTime() {
+				taskLogger.WithFields(log.Fields{
+					"_block":    "spin",
+					"task-id":   t.id,
+					"task-name": t.name,
+					"stop-time": t.stopAt,
+				}).Info("Task reached its stop time")
+				state = schedule.Ended
+			}
+			switch state {
 			// If response show this schedule is still active we fire
 			case schedule.Active:
 				t.missedIntervals += sr.Missed()
-				t.fire()
-				if t.lastFailureTime == t.lastFireTime {
-					consecutiveFailures++
+				// the missed firings caught up are fired along with the tick
+				caughtUp := t.catchUp(sr.Missed())
+				if caughtUp > 0 {
 					taskLogger.WithFields(log.Fields{
-						"_block":                    "spin",
-						"task-id":                   t.id,
-						"task-name":                 t.name,
-						"consecutive failures":      consecutiveFailures,
-						"consecutive failure limit": t.stopOnFailure,
-						"error":                     t.lastFailureMessage,
-					}).Warn("Task failed")
+						"_block":    "spin",
+						"task-id":   t.id,
+						"task-name": t.name,
+						"missed":    sr.Missed(),
+						"caught-up": caughtUp,
+					}).Debug("Catching up the missed firings of the task")
+				}
+				var outcomes []bool
+				if t.maxConcurrent > 1 {
+					// the firings may overlap, so fire without waiting on
+					// the firing and account for the firings finished since
+					// the previous tick
+					outcomes = t.takeFiringOutcomes()
+					for i := 0; i <= caughtUp && !t.reachedMaxRuns(); i++ {
+						if int(atomic.LoadInt32(&t.firings)) < t.maxConcurrent {
+							atomic.AddUint64(&t.runs, 1)
+							t.fireConcurrently()
+						} else {
+							atomic.AddUint64(&t.skippedTicks, 1)
+							taskLogger.WithFields(log.Fields{
+								"_block":         "spin",
+								"task-id":        t.id,
+								"task-name":      t.name,
+								"max-concurrent": t.maxConcurrent,
+							}).Debug("Skipping tick as the previous firings of the task have not finished")
+						}
+					}
 				} else {
-					consecutiveFailures = 0
+					// the intervals missed while firing are the skipped ticks
+					atomic.AddUint64(&t.skippedTicks, uint64(sr.Missed())-uint64(caughtUp))
+					for i := 0; i <= caughtUp && !t.reachedMaxRuns(); i++ {
+						atomic.AddUint64(&t.runs, 1)
+						t.fire()
+						outcomes = append(outcomes, t.lastFailureTime == t.lastFireTime)
+					}
 				}
-				if t.stopOnFailure >= 0 && consecutiveFailures >= t.stopOnFailure {
-					taskLogger.WithFields(log.Fields{
-						"_block":               "spin",
-						"task-id":              t.id,
-						"task-name":            t.name,
-						"consecutive failures": consecutiveFailures,
-						"error":                t.lastFailureMessage,
-					}).Error(ErrTaskDisabledOnFailures)
-
+				if t.failedConsecutively(outcomes, &consecutiveFailures) {
 					// disable the task
-					t.disable(t.lastFailureMessage)
+					t.firingsGroup.Wait()
+					t.flushBuffers()
+					t.disable(t.LastFailureMessage())
+					return
+				}
+				if t.reachedMaxRuns() {
+					taskLogger.WithFields(log.Fields{
+						"_block":    "spin",
+						"task-id":   t.id,
+						"task-name": t.name,
+						"max-runs":  t.maxRuns,
+					}).Info("Task reached its maximum number of runs")
+					t.end()
 					return
 				}
 
 			// Schedule has ended
 			case schedule.Ended:
-				// You must lock task to change state
-				t.Lock()
-				t.state = core.TaskEnded
-				t.Unlock()
-				// Send task ended event
-				event := new(scheduler_event.TaskEndedEvent)
-				event.TaskID = t.id
-				defer t.eventEmitter.Emit(event)
+				t.end()
 				return //spin
 
 			// Schedule has errored
@@ -523,12 +1324,36 @@ func (t *task) spin() {
 				return //spin
 
 			}
-		case <-t.killChan:
-			// Only here can it truly be stopped
+		case <-kill:
+			// Only here can it truly be stopped, the wait on the schedule
+			// is over before the task may be started again on it
+			w.cancel()
+			t.firingsGroup.Wait()
+			if atomic.SwapInt32(&t.abandoned, 0) == 1 {
+				t.dropBuffers()
+			} else {
+				t.flushBuffers()
+			}
 			t.Lock()
-			t.state = core.TaskStopped
-			t.lastFireTime = time.Time{}
+			paused := t.pausing
+			t.pausing = false
+			if paused {
+				t.setState(core.TaskPaused)
+			} else {
+				t.setState(core.TaskStopped)
+			}
+			if t.missedFirePolicy == core.MissedFireSkip {
+				t.lastFireTime = time.Time{}
+			}
 			t.Unlock()
+			// the plugins of a paused task stay subscribed, so it does not
+			// send the event unsubscribing them
+			if paused {
+				event := new(scheduler_event.TaskPausedEvent)
+				event.TaskID = t.id
+				defer t.eventEmitter.Emit(event)
+				return
+			}
 			event := new(scheduler_event.TaskStoppedEvent)
 			event.TaskID = t.id
 			defer t.eventEmitter.Emit(event)
@@ -541,17 +1366,155 @@ func (t *task) fire() {
 	t.Lock()
 	defer t.Unlock()
 
-	t.state = core.TaskFiring
-	t.lastFireTime = time.Now()
+	t.setState(core.TaskFiring)
+	t.lastFireTime = now()
+	atomic.StoreInt64(&t.lastRun, t.lastFireTime.UnixNano())
+	t.firingLogger(t.lastFireTime).Debug("task firing started")
 	t.workflow.Start(t)
-	t.hitCount++
-	t.state = core.TaskSpinning
+	d := now().Sub(t.lastFireTime)
+	t.recordFireDuration(d)
+	t.firingLogger(t.lastFireTime).WithField("duration", d).Debug("task firing completed")
+	atomic.AddUint64(&t.hitCount, 1)
+	t.setState(core.TaskSpinning)
+}
+
+// firingLogger returns the logger of the firing of the task started at start
+func (t *task) firingLogger(start time.Time) *log.Entry {
+	return taskLogger.WithFields(log.Fields{
+		"_block":     "fire",
+		"task-id":    t.id,
+		"task-name":  t.name,
+		"fire-start": start,
+	})
+}
+
+// fireConcurrently fires the task without waiting for the firing to finish,
+// so that it may overlap with the following firings of the task. Whether the
+// firing failed is recorded for the spin loop once it has finished.
+func (t *task) fireConcurrently() {
+	atomic.AddInt32(&t.firings, 1)
+	t.firingsGroup.Add(1)
+
+	t.Lock()
+	t.setState(core.TaskFiring)
+	start := now()
+	t.lastFireTime = start
+	atomic.StoreInt64(&t.lastRun, start.UnixNano())
+
+	// the firing runs the workflow of the task when it starts, even if the
+	// workflow is swapped meanwhile
+	wf := t.workflow
+	t.Unlock()
+
+	go func() {
+		defer t.firingsGroup.Done()
+		// a failure recorded while the firing runs is attributed to it
+		failures := t.FailedCount()
+		t.firingLogger(start).Debug("task firing started")
+		wf.Start(t)
+		d := now().Sub(start)
+		t.recordFireDuration(d)
+		t.firingLogger(start).WithField("duration", d).Debug("task firing completed")
+		failed := t.FailedCount() > failures
+
+		t.Lock()
+		atomic.AddUint64(&t.hitCount, 1)
+		if atomic.AddInt32(&t.firings, -1) == 0 && t.state == core.TaskFiring {
+			t.setState(core.TaskSpinning)
+		}
+		t.Unlock()
+
+		t.outcomesMutex.Lock()
+		t.firingOutcomes = append(t.firingOutcomes, failed)
+		t.outcomesMutex.Unlock()
+	}()
+}
+
+// runNow runs the workflow of the task once, off its schedule, and returns
+// the errors of the jobs which failed. The run only counts in the hit count,
+// failures and durations of the task when record is set.
+func (t *task) runNow(record bool) []error {
+	var (
+		errsMutex sync.Mutex
+		errs      []error
+	)
+	recordFailure := func(e []error) {
+		errsMutex.Lock()
+		errs = append(errs, e...)
+		errsMutex.Unlock()
+		if record {
+			t.RecordFailure(e)
+		}
+	}
+	t.workflowMutex.RLock()
+	wf := t.workflow
+	t.workflowMutex.RUnlock()
+	start := now()
+	t.firingLogger(start).WithField("run-now", true).Debug("task firing started")
+	ctx, span := t.startSpan(context.Background(), SpanFire, map[string]string{"run-now": "true"})
+	run := &runRecord{}
+	wf.start(withRunRecord(ctx, run), t, traceFailures(span, run.failures(recordFailure)))
+	span.End()
+	d := now().Sub(start)
+	t.firingLogger(start).WithFields(log.Fields{
+		"run-now":  true,
+		"duration": d,
+	}).Debug("task firing completed")
+	if record {
+		t.recordFireDuration(d)
+		t.recordRun(start, run)
+		t.Lock()
+		atomic.AddUint64(&t.hitCount, 1)
+		t.Unlock()
+	}
+	return errs
+}
+
+// takeFiringOutcomes returns and clears the outcomes of the overlapping
+// firings which finished since it was last called
+func (t *task) takeFiringOutcomes() []bool {
+	t.outcomesMutex.Lock()
+	defer t.outcomesMutex.Unlock()
+	outcomes := t.firingOutcomes
+	t.firingOutcomes = nil
+	return outcomes
+}
+
+// failedConsecutively counts the consecutive failures given the outcomes of
+// the finished firings and returns whether the task must be disabled
+func (t *task) failedConsecutively(outcomes []bool, consecutiveFailures *int) bool {
+	for _, failed := range outcomes {
+		if failed {
+			*consecutiveFailures++
+			taskLogger.WithFields(log.Fields{
+				"_block":                    "spin",
+				"task-id":                   t.id,
+				"task-name":                 t.name,
+				"consecutive failures":      *consecutiveFailures,
+				"consecutive failure limit": t.stopOnFailure,
+				"error":                     t.LastFailureMessage(),
+			}).Warn("Task failed")
+		} else {
+			*consecutiveFailures = 0
+		}
+		if t.stopOnFailure >= 0 && *consecutiveFailures >= t.stopOnFailure {
+			taskLogger.WithFields(log.Fields{
+				"_block":               "spin",
+				"task-id":              t.id,
+				"task-name":            t.name,
+				"consecutive failures": *consecutiveFailures,
+				"error":                t.LastFailureMessage(),
+			}).Error(ErrTaskDisabledOnFailures)
+			return true
+		}
+	}
+	return false
 }
 
 // disable proceeds disabling a task which consists of changing task state to disabled and emitting an appropriate event
 func (t *task) disable(failureMsg string) {
 	t.Lock()
-	t.state = core.TaskDisabled
+	t.setState(core.TaskDisabled)
 	t.Unlock()
 
 	// Send task disabled event
@@ -561,12 +1524,61 @@ func (t *task) disable(failureMsg string) {
 	defer t.eventEmitter.Emit(event)
 }
 
-func (t *task) waitForSchedule() {
-	select {
-	case <-t.killChan:
-		return
-	case t.schResponseChan <- t.schedule.Wait(t.lastFireTime):
+// end waits for the firings in progress to finish and ends the task
+func (t *task) end() {
+	t.firingsGroup.Wait()
+	t.flushBuffers()
+	// You must lock task to change state
+	t.Lock()
+	t.setState(core.TaskEnded)
+	t.Unlock()
+	// Send task ended event
+	event := new(scheduler_event.TaskEndedEvent)
+	event.TaskID = t.id
+	t.eventEmitter.Emit(event)
+}
+
+// scheduleWait is a wait of a task on its schedule
+type scheduleWait struct {
+	cancelChan  chan struct{}
+	done        chan struct{}
+	cancellable bool
+}
+
+// cancel gives up the wait and returns once the wait is over. The wait on a
+// schedule which cannot be cancelled is left to return on its own, its
+// response is dropped.
+func (w *scheduleWait) cancel() {
+	close(w.cancelChan)
+	if w.cancellable {
+		<-w.done
+	}
+}
+
+// waitForSchedule waits on the schedule since last and sends its response
+// on rc unless the wait is cancelled meanwhile. The last firing time is
+// passed in as the task fields may be replaced while it waits.
+func (t *task) waitForSchedule(s schedule.Schedule, last time.Time, rc chan<- schedule.Response) *scheduleWait {
+	w := &scheduleWait{
+		cancelChan: make(chan struct{}),
+		done:       make(chan struct{}),
 	}
+	cs, ok := s.(schedule.CancellableSchedule)
+	w.cancellable = ok
+	go func() {
+		defer close(w.done)
+		if ok {
+			if r := cs.WaitOrCancel(last, w.cancelChan); r != nil {
+				rc <- r
+			}
+			return
+		}
+		select {
+		case <-w.cancelChan:
+		case rc <- s.Wait(last):
+		}
+	}()
+	return w
 }
 
 // RecordFailure updates the failed runs and last failure properties
@@ -576,27 +1588,42 @@ func (t *task) RecordFailure(e []error) {
 	defer t.failureMutex.Unlock()
 	t.failedRuns++
 	t.lastFailureTime = t.lastFireTime
-	t.lastFailureMessage = e[len(e)-1].Error()
+	t.lastFailure = e[len(e)-1]
+	t.lastFailureMessage = t.lastFailure.Error()
 }
 
+// taskCollection is the set of tasks known to the scheduler keyed by task id.
+// The table is only accessed while holding the embedded lock, read lock for
+// lookups and write lock for changes, so the collection may be used from
+// concurrent API calls. Callers iterate over the copy returned by Table.
 type taskCollection struct {
-	*sync.Mutex
+	*sync.RWMutex
 
 	table map[string]*task
+	// byLabel indexes the ids of the tasks by their "key=value" labels
+	byLabel map[string]map[string]struct{}
+	// uniqueNames makes add refuse a task named like another task
+	uniqueNames bool
 }
 
 func newTaskCollection() *taskCollection {
 	return &taskCollection{
-		Mutex: &sync.Mutex{},
+		RWMutex: &sync.RWMutex{},
 
-		table: make(map[string]*task),
+		table:   make(map[string]*task),
+		byLabel: make(map[string]map[string]struct{}),
 	}
 }
 
+// labelPair returns the key of the label index of a label
+func labelPair(k, v string) string {
+	return k + "=" + v
+}
+
 // Get given a task id returns a Task or nil if not found
 func (t *taskCollection) Get(id string) *task {
-	t.Lock()
-	defer t.Unlock()
+	t.RLock()
+	defer t.RUnlock()
 
 	if t, ok := t.table[id]; ok {
 		return t
@@ -604,15 +1631,52 @@ func (t *taskCollection) Get(id string) *task {
 	return nil
 }
 
+// GetByName given a task name returns a Task or nil if not found
+func (t *taskCollection) GetByName(name string) *task {
+	t.RLock()
+	defer t.RUnlock()
+
+	// names are not unique unless uniqueNames is set, the oldest task holding
+	// the name is returned
+	var found *task
+	for _, tsk := range t.table {
+		if tsk.name == name && (found == nil || tsk.creationTime.Before(found.creationTime)) {
+			found = tsk
+		}
+	}
+	return found
+}
+
 // Add given a reference to a task adds it to the collection of tasks.  An
-// error is returned if the task already exists in the collection.
+// error is returned if the task already exists in the collection or if its
+// name is used by another task while names are unique.
 func (t *taskCollection) add(task *task) error {
 	t.Lock()
 	defer t.Unlock()
 
+	for _, tsk := range t.table {
+		if t.uniqueNames && tsk.id != task.id && tsk.name == task.name {
+			taskLogger.WithFields(log.Fields{
+				"_module":   "scheduler-taskCollection",
+				"_block":    "add",
+				"task id":   task.id,
+				"task name": task.name,
+			}).Error(ErrTaskNameAlreadyInUse.Error())
+			return fmt.Errorf("%v: %v", ErrTaskNameAlreadyInUse, task.name)
+		}
+	}
+
 	if _, ok := t.table[task.id]; !ok {
 		//If we don't already have this task in the collection save it
 		t.table[task.id] = task
+		for k, v := range task.labels {
+			ids, ok := t.byLabel[labelPair(k, v)]
+			if !ok {
+				ids = make(map[string]struct{})
+				t.byLabel[labelPair(k, v)] = ids
+			}
+			ids[task.id] = struct{}{}
+		}
 	} else {
 		taskLogger.WithFields(log.Fields{
 			"_module": "scheduler-taskCollection",
@@ -625,13 +1689,14 @@ func (t *taskCollection) add(task *task) error {
 	return nil
 }
 
-// remove will remove a given task from tasks.  The task must be stopped.
+// remove will remove a given task from tasks.  The task must be stopped or paused.
 // Can return errors ErrTaskNotFound and ErrTaskNotStopped.
 func (t *taskCollection) remove(task *task) error {
 	t.Lock()
 	defer t.Unlock()
 	if _, ok := t.table[task.id]; ok {
-		if task.state != core.TaskStopped && task.state != core.TaskDisabled && task.state != core.TaskEnded {
+		state := task.State()
+		if state != core.TaskStopped && state != core.TaskDisabled && state != core.TaskEnded && state != core.TaskPaused {
 			taskLogger.WithFields(log.Fields{
 				"_block":  "remove",
 				"task id": task.id,
@@ -639,6 +1704,13 @@ func (t *taskCollection) remove(task *task) error {
 			return ErrTaskNotStopped
 		}
 		delete(t.table, task.id)
+		for k, v := range task.labels {
+			ids := t.byLabel[labelPair(k, v)]
+			delete(ids, task.id)
+			if len(ids) == 0 {
+				delete(t.byLabel, labelPair(k, v))
+			}
+		}
 	} else {
 		taskLogger.WithFields(log.Fields{
 			"_block":  "remove",
@@ -649,10 +1721,11 @@ func (t *taskCollection) remove(task *task) error {
 	return nil
 }
 
-// Table returns a copy of the taskCollection
+// Table returns a copy of the taskCollection, which is safe to iterate over
+// while tasks are added or removed
 func (t *taskCollection) Table() map[string]*task {
-	t.Lock()
-	defer t.Unlock()
+	t.RLock()
+	defer t.RUnlock()
 	tasks := make(map[string]*task)
 	for id, t := range t.table {
 		tasks[id] = t
@@ -0,0 +1,180 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/intelsdi-x/snap/core"
+)
+
+// TaskFilter selects the tasks listed by ListTasks. A zero TaskFilter
+// selects every task.
+type TaskFilter struct {
+	// States selects the tasks in any of the states, every state if empty
+	States []core.TaskState
+	// NamePrefix selects the tasks whose name starts with the prefix
+	NamePrefix string
+	// NamespacePrefix selects the tasks subscribed to a metric within the
+	// namespace, given as "/intel/mock"
+	NamespacePrefix string
+	// Labels selects the tasks carrying all the labels
+	Labels map[string]string
+	// Tenant selects the tasks of the tenant, every task if empty
+	Tenant string
+	// Offset is the number of the selected tasks skipped
+	Offset int
+	// Limit is the maximum number of tasks listed, no maximum if 0
+	Limit int
+}
+
+func (f TaskFilter) validate() error {
+	if f.Offset < 0 || f.Limit < 0 {
+		return ErrInvalidTaskFilter
+	}
+	return nil
+}
+
+// ParseLabelSelector parses a label selector such as "env=prod, service=web"
+// into the labels it selects. An empty selector selects every task.
+func ParseLabelSelector(selector string) (map[string]string, error) {
+	labels := map[string]string{}
+	if strings.TrimSpace(selector) == "" {
+		return labels, nil
+	}
+	for _, pair := range strings.Split(selector, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, ErrInvalidLabelSelector
+		}
+		k, v := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if k == "" {
+			return nil, ErrInvalidLabelSelector
+		}
+		labels[k] = v
+	}
+	return labels, nil
+}
+
+func (f TaskFilter) matches(t *task, ns []string) bool {
+	if len(f.States) > 0 {
+		state := t.State()
+		found := false
+		for _, s := range f.States {
+			if s == state {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !strings.HasPrefix(t.name, f.NamePrefix) {
+		return false
+	}
+	if f.Tenant != "" && t.tenant != f.Tenant {
+		return false
+	}
+	for k, v := range f.Labels {
+		if l, ok := t.labels[k]; !ok || l != v {
+			return false
+		}
+	}
+	if len(ns) > 0 {
+		if t.workflow == nil {
+			return false
+		}
+		for _, m := range t.workflow.metrics {
+			if namespaceHasPrefix(m.Namespace().Strings(), ns) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// list returns the tasks selected by the filter ordered by their creation
+// time. Only the selected tasks are copied out of the collection.
+func (t *taskCollection) list(f TaskFilter) []*task {
+	ns := []string{}
+	for _, e := range strings.Split(f.NamespacePrefix, "/") {
+		if e != "" {
+			ns = append(ns, e)
+		}
+	}
+
+	t.RLock()
+	tasks := make(byCreationTime, 0)
+	for _, tsk := range t.candidates(f.Labels) {
+		if f.matches(tsk, ns) {
+			tasks = append(tasks, tsk)
+		}
+	}
+	t.RUnlock()
+
+	sort.Sort(tasks)
+	if f.Offset >= len(tasks) {
+		return []*task{}
+	}
+	tasks = tasks[f.Offset:]
+	if f.Limit > 0 && f.Limit < len(tasks) {
+		tasks = tasks[:f.Limit]
+	}
+	return tasks
+}
+
+// candidates returns the tasks which may carry all the labels, the tasks
+// indexed under the least used of the labels, or every task without labels.
+// It is called with the read lock held.
+func (t *taskCollection) candidates(labels map[string]string) map[string]*task {
+	if len(labels) == 0 {
+		return t.table
+	}
+	var ids map[string]struct{}
+	first := true
+	for k, v := range labels {
+		l := t.byLabel[labelPair(k, v)]
+		if first || len(l) < len(ids) {
+			ids = l
+			first = false
+		}
+	}
+	tasks := make(map[string]*task, len(ids))
+	for id := range ids {
+		tasks[id] = t.table[id]
+	}
+	return tasks
+}
+
+// byCreationTime orders tasks by their creation time, and by their id when
+// created at the same time, so that pages of tasks are stable
+type byCreationTime []*task
+
+func (b byCreationTime) Len() int      { return len(b) }
+func (b byCreationTime) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byCreationTime) Less(i, j int) bool {
+	if b[i].creationTime.Equal(b[j].creationTime) {
+		return b[i].id < b[j].id
+	}
+	return b[i].creationTime.Before(b[j].creationTime)
+}
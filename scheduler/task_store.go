@@ -0,0 +1,212 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// TaskStore keeps the tasks of a scheduler across restarts. The scheduler
+// saves its tasks in the store, in the form written by SaveTasks, whenever a
+// task is created, removed or changes state, and restores them on Start.
+type TaskStore interface {
+	// Save replaces the tasks kept in the store
+	Save(data []byte) error
+	// Load returns the tasks kept in the store, nil if none were saved
+	Load() ([]byte, error)
+}
+
+// FileTaskStore is a TaskStore keeping the tasks in a file
+type FileTaskStore struct {
+	path string
+}
+
+// NewFileTaskStore returns a FileTaskStore keeping the tasks in the file at
+// path, which is created on the first save
+func NewFileTaskStore(path string) *FileTaskStore {
+	return &FileTaskStore{path: path}
+}
+
+// Save replaces the content of the file with data. The data is written to a
+// temporary file renamed over the file, so that a crash while saving does
+// not lose the tasks saved previously.
+func (f *FileTaskStore) Save(data []byte) error {
+	return writeFileAtomic(f.path, data)
+}
+
+// writeFileAtomic replaces the content of the file at path with data, through
+// a temporary file renamed over it
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load returns the content of the file, nil if it does not exist
+func (f *FileTaskStore) Load() ([]byte, error) {
+	data, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// taskPersistence saves the tasks of a scheduler in its task store
+type taskPersistence struct {
+	// mutex serializes the saves so that an older snapshot of the tasks is
+	// never written over a newer one
+	mutex sync.Mutex
+	store TaskStore
+	// restoring is set to 1 while the tasks are restored, the tasks are not
+	// saved meanwhile so that the ones not restored yet are not lost
+	restoring int32
+	restored  bool
+	// unrestored holds the saved tasks which could not be restored, they are
+	// kept in the store to be restored on the next start
+	unrestored []savedTask
+	// lastError is the error of the last load or save of the store, nil
+	// once the tasks are saved, guarded by errorMutex
+	lastError  error
+	errorMutex sync.Mutex
+}
+
+func (p *taskPersistence) setError(err error) {
+	p.errorMutex.Lock()
+	p.lastError = err
+	p.errorMutex.Unlock()
+}
+
+// storeError returns the error of the last load or save of the store
+func (p *taskPersistence) storeError() error {
+	p.errorMutex.Lock()
+	defer p.errorMutex.Unlock()
+	return p.lastError
+}
+
+// SetTaskStore sets the store the tasks of the scheduler are kept in across
+// restarts. It must be set before the scheduler is started.
+func (s *scheduler) SetTaskStore(ts TaskStore) {
+	s.persistence.store = ts
+	schedulerLogger.WithFields(log.Fields{
+		"_block": "set-task-store",
+	}).Debug("task store linked")
+}
+
+// restoreTasks creates the tasks kept in the task store, once per scheduler
+func (s *scheduler) restoreTasks() {
+	p := s.persistence
+	if p.store == nil || p.restored {
+		return
+	}
+	p.restored = true
+	logger := schedulerLogger.WithFields(log.Fields{
+		"_block": "restore-tasks",
+	})
+	data, err := p.store.Load()
+	if err != nil {
+		p.setError(err)
+		logger.WithFields(log.Fields{
+			"_error": err.Error(),
+		}).Error("error loading the saved tasks")
+		return
+	}
+	if len(data) > 0 {
+		var saved []savedTask
+		if err := json.Unmarshal(data, &saved); err != nil {
+			p.setError(err)
+			logger.WithFields(log.Fields{
+				"_error": err.Error(),
+			}).Error("error decoding the saved tasks")
+			return
+		}
+		atomic.StoreInt32(&p.restoring, 1)
+		errs, unrestored := s.loadTasks(saved)
+		p.mutex.Lock()
+		p.unrestored = unrestored
+		p.mutex.Unlock()
+		atomic.StoreInt32(&p.restoring, 0)
+		logger.WithFields(log.Fields{
+			"restored":   len(saved) - len(errs),
+			"unrestored": len(errs),
+		}).Info("tasks restored")
+	}
+	s.persistTasks()
+}
+
+// persistTasks saves the tasks of the scheduler in its task store
+func (s *scheduler) persistTasks() {
+	p := s.persistence
+	// the tasks stopped while the scheduler stops are saved as they were
+	// while it was started, and the tasks are only saved by the leader so
+	// that a scheduler standing by or stepping down never writes over the
+	// tasks of the leader
+	if p.store == nil || atomic.LoadInt32(&p.restoring) == 1 || s.getState() != schedulerStarted || !s.IsLeader() {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	logger := schedulerLogger.WithFields(log.Fields{
+		"_block": "persist-tasks",
+	})
+	saved, err := s.savedTasks()
+	if err != nil {
+		p.setError(err)
+		logger.WithFields(log.Fields{
+			"_error": err.Error(),
+		}).Error("error saving the tasks")
+		return
+	}
+	saved = append(saved, p.unrestored...)
+	buf := &bytes.Buffer{}
+	if err := json.NewEncoder(buf).Encode(saved); err != nil {
+		p.setError(err)
+		logger.WithFields(log.Fields{
+			"_error": err.Error(),
+		}).Error("error saving the tasks")
+		return
+	}
+	if err := p.store.Save(buf.Bytes()); err != nil {
+		p.setError(err)
+		logger.WithFields(log.Fields{
+			"_error": err.Error(),
+		}).Error("error saving the tasks")
+		return
+	}
+	p.setError(nil)
+}
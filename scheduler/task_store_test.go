@@ -0,0 +1,60 @@
+// +build legacy
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFileTaskStore(t *testing.T) {
+	Convey("FileTaskStore", t, func() {
+		dir, err := ioutil.TempDir("", "task-store")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		store := NewFileTaskStore(filepath.Join(dir, "tasks.json"))
+		Convey("loads nothing before the first save", func() {
+			data, err := store.Load()
+			So(err, ShouldBeNil)
+			So(data, ShouldBeNil)
+		})
+		Convey("loads the data last saved", func() {
+			So(store.Save([]byte("first")), ShouldBeNil)
+			So(store.Save([]byte("second")), ShouldBeNil)
+			data, err := store.Load()
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "second")
+			// the temporary files are renamed over the file
+			files, err := ioutil.ReadDir(dir)
+			So(err, ShouldBeNil)
+			So(files, ShouldHaveLength, 1)
+		})
+		Convey("fails to save in a missing directory", func() {
+			store := NewFileTaskStore(filepath.Join(dir, "missing", "tasks.json"))
+			So(store.Save([]byte("data")), ShouldNotBeNil)
+		})
+	})
+}
@@ -22,6 +22,7 @@ limitations under the License.
 package scheduler
 
 import (
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -86,6 +87,15 @@ func TestTask(t *testing.T) {
 
 		})
 
+		Convey("Task queue full policy test", func() {
+			sch := schedule.NewWindowedSchedule(time.Millisecond*100, nil, nil, 0)
+			task, err := newTask(sch, wf, newWorkManager(), c, emitter, core.OptionQueueFullPolicy("drop-oldest"))
+			So(err, ShouldBeNil)
+			So(task.QueueFullPolicy(), ShouldEqual, "drop-oldest")
+			_, err = newTask(sch, wf, newWorkManager(), c, emitter, core.OptionQueueFullPolicy("spill-to-disk"))
+			So(err, ShouldNotBeNil)
+		})
+
 		Convey("Tasks are created and creation of task table is checked", func() {
 			sch := schedule.NewWindowedSchedule(time.Millisecond*100, nil, nil, 0)
 			task, err := newTask(sch, wf, newWorkManager(), c, emitter)
@@ -127,6 +137,229 @@ func TestTask(t *testing.T) {
 			task.Stop()
 		})
 
+		Convey("task ends at its stop time", func() {
+			sch := schedule.NewWindowedSchedule(time.Millisecond*10, nil, nil, 0)
+			task, err := newTask(sch, wf, newWorkManager(), c, emitter, core.OptionStopAfter(time.Millisecond*50))
+			So(err, ShouldBeNil)
+			task.Spin()
+			time.Sleep(time.Millisecond * 100)
+			So(task.State(), ShouldEqual, core.TaskEnded)
+			hits := task.HitCount()
+			So(hits, ShouldBeGreaterThan, 0)
+			Convey("and does not fire again once restarted", func() {
+				So(task.StopAt().IsZero(), ShouldBeFalse)
+				task.Spin()
+				time.Sleep(time.Millisecond * 50)
+				So(task.State(), ShouldEqual, core.TaskEnded)
+				So(task.HitCount(), ShouldEqual, hits)
+			})
+		})
+
+		Convey("task reports its next fire time while running", func() {
+			sch := schedule.NewWindowedSchedule(time.Millisecond*200, nil, nil, 0)
+			task, err := newTask(sch, wf, newWorkManager(), c, emitter)
+			So(err, ShouldBeNil)
+			_, ok := task.NextFireTime()
+			So(ok, ShouldBeFalse)
+			task.Spin()
+			time.Sleep(time.Millisecond * 50)
+			next, ok := task.NextFireTime()
+			So(ok, ShouldBeTrue)
+			So(next, ShouldHappenAfter, time.Now())
+			So(next, ShouldHappenBefore, time.Now().Add(time.Millisecond*200))
+			Convey("and updates it after each firing", func() {
+				time.Sleep(next.Sub(time.Now()) + time.Millisecond*50)
+				later, ok := task.NextFireTime()
+				So(ok, ShouldBeTrue)
+				So(later, ShouldHappenWithin, time.Millisecond*10, next.Add(time.Millisecond*200))
+			})
+			Convey("but not once it is stopped", func() {
+				task.Stop()
+				_, ok := task.NextFireTime()
+				So(ok, ShouldBeFalse)
+			})
+			task.Stop()
+		})
+
+		Convey("task reports its last and next runs", func() {
+			sch := schedule.NewWindowedSchedule(time.Millisecond*200, nil, nil, 0)
+			task, err := newTask(sch, wf, newWorkManager(), c, emitter)
+			So(err, ShouldBeNil)
+			_, ok := task.LastRun()
+			So(ok, ShouldBeFalse)
+			_, ok = task.NextRun()
+			So(ok, ShouldBeFalse)
+			task.Spin()
+			time.Sleep(time.Millisecond * 50)
+			last, ok := task.LastRun()
+			So(ok, ShouldBeTrue)
+			So(last, ShouldHappenWithin, time.Millisecond*100, time.Now())
+			next, ok := task.NextRun()
+			So(ok, ShouldBeTrue)
+			So(next, ShouldHappenWithin, time.Millisecond*10, last.Add(time.Millisecond*200))
+			Convey("keeping the last run once it is stopped", func() {
+				task.Stop()
+				stopped, ok := task.LastRun()
+				So(ok, ShouldBeTrue)
+				So(stopped, ShouldResemble, last)
+				_, ok = task.NextRun()
+				So(ok, ShouldBeFalse)
+			})
+			task.Stop()
+		})
+
+		Convey("firing task computes its next run from its schedule", func() {
+			sch := schedule.NewWindowedSchedule(time.Second, nil, nil, 0)
+			task, err := newTask(sch, wf, newWorkManager(), c, emitter)
+			So(err, ShouldBeNil)
+			last := time.Now().Add(-time.Millisecond * 100)
+			task.lastRun = last.UnixNano()
+			task.setState(core.TaskFiring)
+			next, ok := task.NextRun()
+			So(ok, ShouldBeTrue)
+			So(next.UnixNano(), ShouldEqual, last.Add(time.Second).UnixNano())
+			Convey("unless the task ends before", func() {
+				task.stopAt = last.Add(time.Millisecond * 500)
+				_, ok := task.NextRun()
+				So(ok, ShouldBeFalse)
+			})
+		})
+
+		Convey("task skips the firings missed while it was stopped by default", func() {
+			sch := schedule.NewWindowedSchedule(time.Millisecond*100, nil, nil, 0)
+			task, err := newTask(sch, wf, newWorkManager(), c, emitter)
+			So(err, ShouldBeNil)
+			So(task.MissedFirePolicy(), ShouldEqual, core.MissedFireSkip)
+			task.Spin()
+			time.Sleep(time.Millisecond * 20)
+			task.Stop()
+			time.Sleep(time.Millisecond * 500)
+			task.Spin()
+			// fires on start and on the following tick
+			time.Sleep(time.Millisecond * 130)
+			task.Stop()
+			So(task.HitCount(), ShouldEqual, 3)
+		})
+
+		Convey("task catches up the firings missed while it was stopped", func() {
+			sch := schedule.NewWindowedSchedule(time.Millisecond*100, nil, nil, 0)
+			task, err := newTask(sch, wf, newWorkManager(), c, emitter, core.OptionMissedFirePolicy(core.MissedFireCatchUp), core.OptionMaxCatchUp(2))
+			So(err, ShouldBeNil)
+			So(task.MaxCatchUp(), ShouldEqual, 2)
+			task.Spin()
+			time.Sleep(time.Millisecond * 20)
+			task.Stop()
+			time.Sleep(time.Millisecond * 500)
+			task.Spin()
+			// fires two of the missed firings and the tick on the first
+			// tick after it is started
+			time.Sleep(time.Millisecond * 130)
+			task.Stop()
+			So(task.HitCount(), ShouldEqual, 4)
+			So(task.MissedCount(), ShouldBeGreaterThanOrEqualTo, 4)
+		})
+
+		Convey("task fires once for the firings missed while it was stopped", func() {
+			sch := schedule.NewWindowedSchedule(time.Millisecond*100, nil, nil, 0)
+			task, err := newTask(sch, wf, newWorkManager(), c, emitter, core.OptionMissedFirePolicy(core.MissedFireOnce))
+			So(err, ShouldBeNil)
+			task.Spin()
+			time.Sleep(time.Millisecond * 20)
+			task.Stop()
+			time.Sleep(time.Millisecond * 500)
+			task.Spin()
+			// fires once for the missed firings and for the tick on the
+			// first tick after it is started
+			time.Sleep(time.Millisecond * 130)
+			task.Stop()
+			So(task.HitCount(), ShouldEqual, 3)
+			So(task.MissedCount(), ShouldBeGreaterThanOrEqualTo, 4)
+		})
+
+		Convey("task skips the ticks while its previous firing has not finished", func() {
+			slow := &mockMetricManager{collectDuration: time.Millisecond * 35}
+			sch := schedule.NewWindowedSchedule(time.Millisecond*10, nil, nil, 0)
+			task, err := newTask(sch, wf, newWorkManager(), slow, emitter)
+			So(err, ShouldBeNil)
+			So(task.MaxConcurrent(), ShouldEqual, DefaultMaxConcurrent)
+			task.Spin()
+			time.Sleep(time.Millisecond * 150)
+			task.Stop()
+			So(atomic.LoadInt32(&slow.maxCollecting), ShouldEqual, 1)
+			So(task.HitCount(), ShouldBeGreaterThan, 0)
+			So(task.SkippedCount(), ShouldBeGreaterThan, 0)
+		})
+
+		Convey("task with overlapping firings allowed", func() {
+			slow := &mockMetricManager{collectDuration: time.Millisecond * 35}
+			sch := schedule.NewWindowedSchedule(time.Millisecond*10, nil, nil, 0)
+			task, err := newTask(sch, wf, newWorkManager(CollectWkrSizeOption(4)), slow, emitter, core.OptionMaxConcurrent(2))
+			So(err, ShouldBeNil)
+			task.Spin()
+			time.Sleep(time.Millisecond * 150)
+			task.Stop()
+			time.Sleep(time.Millisecond * 50)
+			Convey("fires up to the limit at the same time and skips the other ticks", func() {
+				So(atomic.LoadInt32(&slow.maxCollecting), ShouldEqual, 2)
+				So(task.SkippedCount(), ShouldBeGreaterThan, 0)
+			})
+			Convey("waits for the firings in flight to finish when stopped", func() {
+				So(task.State(), ShouldEqual, core.TaskStopped)
+				So(atomic.LoadInt32(&slow.collecting), ShouldEqual, 0)
+			})
+		})
+
+		Convey("task with overlapping firings is disabled after consecutive failures", func() {
+			failing := &mockMetricManager{failCollecting: true, collectDuration: time.Millisecond * 5}
+			sch := schedule.NewWindowedSchedule(time.Millisecond, nil, nil, 0)
+			task, err := newTask(sch, wf, newWorkManager(CollectWkrSizeOption(4)), failing, emitter, core.OptionMaxConcurrent(3), core.OptionStopOnFailure(2))
+			So(err, ShouldBeNil)
+			task.Spin()
+			time.Sleep(time.Millisecond * 100)
+			So(task.State(), ShouldEqual, core.TaskDisabled)
+			So(task.LastError().Error(), ShouldEqual, "collection failed")
+		})
+
+		Convey("task retries the failed jobs of a firing", func() {
+			flaky := &mockMetricManager{failFirst: 2}
+			sch := schedule.NewWindowedSchedule(time.Millisecond*200, nil, nil, 0)
+			task, err := newTask(sch, wf, newWorkManager(), flaky, emitter, core.OptionRetry(3, time.Millisecond*5))
+			So(err, ShouldBeNil)
+			count, backoff := task.Retry()
+			So(count, ShouldEqual, 3)
+			So(backoff, ShouldEqual, time.Millisecond*5)
+			task.Spin()
+			time.Sleep(time.Millisecond * 100)
+			task.Stop()
+			So(task.HitCount(), ShouldEqual, 1)
+			So(task.FailedCount(), ShouldEqual, 0)
+			So(task.RetriedCount(), ShouldEqual, 2)
+		})
+
+		Convey("task records a failure once the retries of a job are exhausted", func() {
+			failing := &mockMetricManager{failCollecting: true}
+			sch := schedule.NewWindowedSchedule(time.Millisecond*200, nil, nil, 0)
+			task, err := newTask(sch, wf, newWorkManager(), failing, emitter, core.OptionRetry(2, time.Millisecond*5))
+			So(err, ShouldBeNil)
+			task.Spin()
+			time.Sleep(time.Millisecond * 100)
+			task.Stop()
+			So(task.FailedCount(), ShouldEqual, 1)
+			So(task.RetriedCount(), ShouldEqual, 2)
+		})
+
+		Convey("task is disabled after consecutive failures", func() {
+			sch := schedule.NewWindowedSchedule(time.Millisecond, nil, nil, 0)
+			task, err := newTask(sch, wf, newWorkManager(), &mockMetricManager{failCollecting: true}, emitter, core.OptionStopOnFailure(2))
+			So(err, ShouldBeNil)
+			task.Spin()
+			time.Sleep(time.Millisecond * 50)
+			So(task.State(), ShouldEqual, core.TaskDisabled)
+			So(task.FailedCount(), ShouldEqual, 2)
+			So(task.LastError(), ShouldNotBeNil)
+			So(task.LastError().Error(), ShouldEqual, "collection failed")
+		})
+
 		Convey("Enable a running task", func() {
 			sch := schedule.NewWindowedSchedule(time.Millisecond*10, nil, nil, 0)
 			task, err := newTask(sch, wf, newWorkManager(), c, emitter)
@@ -189,6 +422,32 @@ func TestTask(t *testing.T) {
 				So(t, ShouldBeNil)
 			})
 
+			Convey("Get task from collection by name", func() {
+				t := taskCollection.GetByName(task.name)
+				So(t, ShouldNotBeNil)
+				So(t.ID(), ShouldEqual, task.id)
+				So(taskCollection.GetByName("unknown"), ShouldBeNil)
+			})
+
+			Convey("Add another task with the same name", func() {
+				task2, err := newTask(sch, wf, newWorkManager(), &mockMetricManager{}, emitter, core.SetTaskName(task.name))
+				So(err, ShouldBeNil)
+				So(taskCollection.add(task2), ShouldBeNil)
+				So(len(taskCollection.table), ShouldEqual, 2)
+				// the oldest task holding the name is found
+				So(taskCollection.GetByName(task.name).ID(), ShouldEqual, task.id)
+			})
+
+			Convey("Attempt to add another task with the same name while names are unique", func() {
+				taskCollection.uniqueNames = true
+				task2, err := newTask(sch, wf, newWorkManager(), &mockMetricManager{}, emitter, core.SetTaskName(task.name))
+				So(err, ShouldBeNil)
+				err = taskCollection.add(task2)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, ErrTaskNameAlreadyInUse.Error())
+				So(len(taskCollection.table), ShouldEqual, 1)
+			})
+
 			Convey("Create another task and compare the id", func() {
 				task2, err := newTask(sch, wf, newWorkManager(), &mockMetricManager{}, emitter)
 				So(err, ShouldBeNil)
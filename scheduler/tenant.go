@@ -0,0 +1,237 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/intelsdi-x/snap/core"
+)
+
+var (
+	// ErrTenantMismatch - The error message for a task given another tenant than the one of the context it is created with
+	ErrTenantMismatch = errors.New("Task cannot belong to another tenant than the one of its context")
+	// ErrTenantTaskQuotaExceeded - The error message for a tenant which has as many tasks as its quota allows
+	ErrTenantTaskQuotaExceeded = errors.New("Tenant task quota exceeded")
+	// ErrTenantMetricQuotaExceeded - The error message for a task taking the metrics of its tenant over its quota
+	ErrTenantMetricQuotaExceeded = errors.New("Tenant metric quota exceeded")
+)
+
+// TenantQuota limits the tasks of a tenant, the zero fields leaving them
+// unlimited
+type TenantQuota struct {
+	// MaxTasks is the number of tasks the tenant may have
+	MaxTasks uint `json:"max_tasks"`
+	// MaxMetrics is the number of metrics the workflows of the tasks of the
+	// tenant may request in total
+	MaxMetrics uint `json:"max_metrics"`
+	// MaxFiresPerMinute is the number of firings of the tasks of the tenant
+	// per minute, the firings over it skipping their collection
+	MaxFiresPerMinute uint `json:"max_fires_per_minute"`
+}
+
+// TenantUsage is what the tasks of a tenant use of its quota
+type TenantUsage struct {
+	Tenant  string      `json:"tenant"`
+	Quota   TenantQuota `json:"quota"`
+	Tasks   uint        `json:"tasks"`
+	Metrics uint        `json:"metrics"`
+	// ThrottledFirings counts the firings which skipped their collection
+	// as the tenant was firing over its rate
+	ThrottledFirings uint64 `json:"throttled_firings"`
+}
+
+// tenantQuotas holds the quota of each tenant and limits the rate its tasks
+// fire at. The tasks belonging to no tenant are not limited.
+type tenantQuotas struct {
+	mutex        sync.Mutex
+	defaultQuota TenantQuota
+	quotas       map[string]TenantQuota
+	// buckets hold the firings left to each tenant firing under a rate
+	buckets   map[string]*fireBucket
+	throttled map[string]uint64
+}
+
+func newTenantQuotas(defaultQuota TenantQuota) *tenantQuotas {
+	return &tenantQuotas{
+		defaultQuota: defaultQuota,
+		quotas:       make(map[string]TenantQuota),
+		buckets:      make(map[string]*fireBucket),
+		throttled:    make(map[string]uint64),
+	}
+}
+
+// fireBucket refills the firings of a tenant at its rate, up to a second
+// worth of firings and at least one
+type fireBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (t *tenantQuotas) set(tenant string, q TenantQuota) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.quotas[tenant] = q
+	delete(t.buckets, tenant)
+}
+
+func (t *tenantQuotas) get(tenant string) TenantQuota {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.quota(tenant)
+}
+
+func (t *tenantQuotas) quota(tenant string) TenantQuota {
+	if q, ok := t.quotas[tenant]; ok {
+		return q
+	}
+	return t.defaultQuota
+}
+
+// allowFire returns whether a task of the tenant may fire now, taking a
+// firing out of the bucket of the tenant
+func (t *tenantQuotas) allowFire(tenant string) bool {
+	if tenant == "" {
+		return true
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	perMinute := t.quota(tenant).MaxFiresPerMinute
+	if perMinute == 0 {
+		return true
+	}
+	rate := float64(perMinute) / 60
+	burst := rate
+	if burst < 1 {
+		burst = 1
+	}
+	at := now()
+	b, ok := t.buckets[tenant]
+	if !ok {
+		b = &fireBucket{tokens: burst, last: at}
+		t.buckets[tenant] = b
+	}
+	b.tokens += at.Sub(b.last).Seconds() * rate
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = at
+	if b.tokens < 1 {
+		t.throttled[tenant]++
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (t *tenantQuotas) throttledFirings(tenant string) uint64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.throttled[tenant]
+}
+
+// SetTenantQuota sets the quota of the tenant, in place of the one set by
+// the tenant_max_* settings of the configuration. It applies to the next
+// creations and updates of the tasks of the tenant, the tasks it already has
+// over it are kept.
+func (s *scheduler) SetTenantQuota(tenant string, q TenantQuota) {
+	s.tenants.set(tenant, q)
+	schedulerLogger.WithFields(log.Fields{
+		"_block": "set-tenant-quota",
+		"tenant": tenant,
+		"quota":  fmt.Sprintf("%+v", q),
+	}).Debug("tenant quota set")
+}
+
+// TenantUsage returns the quota of the tenant and what its tasks use of it
+func (s *scheduler) TenantUsage(tenant string) TenantUsage {
+	u := TenantUsage{
+		Tenant:           tenant,
+		Quota:            s.tenants.get(tenant),
+		ThrottledFirings: s.tenants.throttledFirings(tenant),
+	}
+	u.Tasks, u.Metrics = s.tenantTasks(tenant, "")
+	return u
+}
+
+// GetTasksWithContext returns the tasks as GetTasks does, only the ones of
+// the tenant when the context carries one
+func (s *scheduler) GetTasksWithContext(ctx context.Context) map[string]core.Task {
+	list, _ := s.ListTasks(TaskFilter{Tenant: core.TenantFromContext(ctx)})
+	tasks := make(map[string]core.Task, len(list))
+	for _, t := range list {
+		tasks[t.ID()] = t
+	}
+	return tasks
+}
+
+// tenantTasks returns the number of tasks of the tenant and the number of
+// metrics their workflows request, leaving out the task excluded
+func (s *scheduler) tenantTasks(tenant, excluded string) (uint, uint) {
+	var tasks, metrics uint
+	for _, t := range s.tasks.list(TaskFilter{Tenant: tenant}) {
+		if t.id == excluded {
+			continue
+		}
+		tasks++
+		if t.workflow != nil {
+			metrics += uint(len(t.workflow.metrics))
+		}
+	}
+	return tasks, metrics
+}
+
+// assignTenant gives the task the tenant carried by the context, the task
+// being created for it
+func assignTenant(ctx context.Context, t *task) error {
+	tenant := core.TenantFromContext(ctx)
+	if tenant == "" {
+		return nil
+	}
+	if t.tenant != "" && t.tenant != tenant {
+		return fmt.Errorf("%v: %v", ErrTenantMismatch, t.tenant)
+	}
+	t.tenant = tenant
+	return nil
+}
+
+// checkTenantQuota returns an error if a task of the tenant whose workflow
+// requests the metrics would take the tenant over its quota, replacing the
+// task of the id replaced, or added if it is empty
+func (s *scheduler) checkTenantQuota(tenant, replaced string, metrics int) error {
+	if tenant == "" {
+		return nil
+	}
+	q := s.tenants.get(tenant)
+	tasks, used := s.tenantTasks(tenant, replaced)
+	if q.MaxTasks > 0 && replaced == "" && tasks >= q.MaxTasks {
+		return fmt.Errorf("%v: tenant %v has %v tasks", ErrTenantTaskQuotaExceeded, tenant, tasks)
+	}
+	if q.MaxMetrics > 0 && used+uint(metrics) > q.MaxMetrics {
+		return fmt.Errorf("%v: tenant %v requests %v metrics, the task %v", ErrTenantMetricQuotaExceeded, tenant, used, metrics)
+	}
+	return nil
+}
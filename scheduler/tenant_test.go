@@ -0,0 +1,87 @@
+// +build legacy
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/pkg/schedule"
+)
+
+func TestTenantQuotas(t *testing.T) {
+	Convey("tenantQuotas", t, func() {
+		c := schedule.NewManualClock(time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC))
+		schedule.SetClock(c)
+		defer schedule.SetClock(nil)
+		q := newTenantQuotas(TenantQuota{MaxFiresPerMinute: 120})
+		Convey("lets a tenant fire up to its rate", func() {
+			So(q.allowFire("a"), ShouldBeTrue)
+			So(q.allowFire("a"), ShouldBeTrue)
+			So(q.allowFire("a"), ShouldBeFalse)
+			So(q.throttledFirings("a"), ShouldEqual, 1)
+			Convey("refilling its firings over time", func() {
+				c.Advance(500 * time.Millisecond)
+				So(q.allowFire("a"), ShouldBeTrue)
+				So(q.allowFire("a"), ShouldBeFalse)
+			})
+			Convey("without limiting the other tenants", func() {
+				So(q.allowFire("b"), ShouldBeTrue)
+			})
+		})
+		Convey("lets a slow rate fire once before refilling", func() {
+			q.set("slow", TenantQuota{MaxFiresPerMinute: 1})
+			So(q.allowFire("slow"), ShouldBeTrue)
+			c.Advance(30 * time.Second)
+			So(q.allowFire("slow"), ShouldBeFalse)
+			c.Advance(30 * time.Second)
+			So(q.allowFire("slow"), ShouldBeTrue)
+		})
+		Convey("does not limit the tasks without a tenant or a rate", func() {
+			q.set("free", TenantQuota{})
+			for i := 0; i < 10; i++ {
+				So(q.allowFire(""), ShouldBeTrue)
+				So(q.allowFire("free"), ShouldBeTrue)
+			}
+		})
+	})
+	Convey("assignTenant", t, func() {
+		ctx := core.WithTenant(context.Background(), "a")
+		Convey("gives the task the tenant of the context", func() {
+			tsk := &task{}
+			So(assignTenant(ctx, tsk), ShouldBeNil)
+			So(tsk.Tenant(), ShouldEqual, "a")
+		})
+		Convey("rejects a task given another tenant", func() {
+			So(assignTenant(ctx, &task{tenant: "b"}), ShouldNotBeNil)
+		})
+		Convey("keeps the tenant of the task without one in the context", func() {
+			tsk := &task{tenant: "b"}
+			So(assignTenant(context.Background(), tsk), ShouldBeNil)
+			So(tsk.Tenant(), ShouldEqual, "b")
+		})
+	})
+}
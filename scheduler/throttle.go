@@ -0,0 +1,102 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/intelsdi-x/snap/core"
+)
+
+// collectionThrottle limits the number of collections of the metrics under a
+// namespace prefix running at once, across all the tasks, so that a slow
+// collector is not stampeded by many tasks collecting it at the same time
+type collectionThrottle struct {
+	mutex sync.Mutex
+	max   uint
+	// depth is the number of elements of a namespace in its prefix
+	depth int
+	// slots holds a semaphore of max slots per prefix
+	slots map[string]chan struct{}
+}
+
+func newCollectionThrottle(max uint, depth int) *collectionThrottle {
+	return &collectionThrottle{
+		max:   max,
+		depth: depth,
+		slots: make(map[string]chan struct{}),
+	}
+}
+
+// prefixes returns the namespace prefixes of the metrics, sorted
+func (c *collectionThrottle) prefixes(mts []core.RequestedMetric) []string {
+	return namespacePrefixes(mts, c.depth)
+}
+
+func (c *collectionThrottle) semaphore(prefix string) chan struct{} {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	sem, ok := c.slots[prefix]
+	if !ok {
+		sem = make(chan struct{}, c.max)
+		c.slots[prefix] = sem
+	}
+	return sem
+}
+
+// acquire takes a slot of each of the prefixes, waiting up to timeout for
+// them to be released by the other collections. It returns false, holding
+// none of the slots, if they could not all be taken in time. The prefixes
+// are taken in order so that two collections waiting on each other's slots
+// cannot deadlock.
+func (c *collectionThrottle) acquire(prefixes []string, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for i, p := range prefixes {
+		select {
+		case c.semaphore(p) <- struct{}{}:
+		case <-timer.C:
+			c.release(prefixes[:i])
+			return false
+		}
+	}
+	return true
+}
+
+// release gives back the slots of the prefixes taken by acquire
+func (c *collectionThrottle) release(prefixes []string) {
+	for _, p := range prefixes {
+		<-c.semaphore(p)
+	}
+}
+
+// inFlight returns the number of collections running under each prefix
+func (c *collectionThrottle) inFlight() map[string]int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	counts := make(map[string]int)
+	for p, sem := range c.slots {
+		if n := len(sem); n > 0 {
+			counts[p] = n
+		}
+	}
+	return counts
+}
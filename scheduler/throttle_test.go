@@ -0,0 +1,72 @@
+// +build legacy
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/core"
+)
+
+func TestCollectionThrottle(t *testing.T) {
+	Convey("collectionThrottle", t, func() {
+		c := newCollectionThrottle(2, 2)
+		snmp := c.prefixes([]core.RequestedMetric{
+			&metric{namespace: core.NewNamespace("intel", "snmp", "ifInOctets")},
+			&metric{namespace: core.NewNamespace("intel", "snmp", "ifOutOctets")},
+		})
+		So(snmp, ShouldResemble, []string{"/intel/snmp"})
+		mock := []string{"/intel/mock"}
+
+		Convey("lets max collections of a prefix run at once", func() {
+			So(c.acquire(snmp, time.Millisecond), ShouldBeTrue)
+			So(c.acquire(snmp, time.Millisecond), ShouldBeTrue)
+			So(c.acquire(snmp, time.Millisecond), ShouldBeFalse)
+			So(c.inFlight(), ShouldResemble, map[string]int{"/intel/snmp": 2})
+			Convey("without limiting the other prefixes", func() {
+				So(c.acquire(mock, time.Millisecond), ShouldBeTrue)
+			})
+			Convey("letting a waiting collection run once one is over", func() {
+				acquired := make(chan bool)
+				go func() {
+					acquired <- c.acquire(snmp, time.Second)
+				}()
+				c.release(snmp)
+				So(<-acquired, ShouldBeTrue)
+				So(c.inFlight(), ShouldResemble, map[string]int{"/intel/snmp": 2})
+			})
+		})
+		Convey("takes none of the slots of the prefixes unless all of them are free", func() {
+			both := []string{"/intel/mock", "/intel/snmp"}
+			So(c.acquire(snmp, time.Millisecond), ShouldBeTrue)
+			So(c.acquire(snmp, time.Millisecond), ShouldBeTrue)
+			So(c.acquire(both, time.Millisecond), ShouldBeFalse)
+			So(c.inFlight(), ShouldResemble, map[string]int{"/intel/snmp": 2})
+			c.release(snmp)
+			c.release(snmp)
+			So(c.inFlight(), ShouldBeEmpty)
+		})
+	})
+}
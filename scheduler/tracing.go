@@ -0,0 +1,134 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"context"
+	"strconv"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// The names of the spans of a task firing
+const (
+	// SpanFire is the span of a firing of a task, the parent of the spans
+	// of its jobs
+	SpanFire = "snap.task.fire"
+	// SpanCollect is the span of the collect job of a firing
+	SpanCollect = "snap.collect"
+	// SpanProcess is the span of a process job, one per step of the workflow
+	SpanProcess = "snap.process"
+	// SpanPublish is the span of a publish job
+	SpanPublish = "snap.publish"
+)
+
+// Tracer starts the spans of the firings of the tasks. It is the extension
+// point an OpenTelemetry tracer, or any other tracing system, is adapted to.
+type Tracer interface {
+	// StartSpan starts a span as a child of the span carried by ctx, if any,
+	// and returns the context carrying the new span
+	StartSpan(ctx context.Context, name string, attrs map[string]string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer. The spans of the jobs of a firing run
+// concurrently, so a Span must be safe for concurrent use.
+type Span interface {
+	// SetError marks the span as failed with err
+	SetError(err error)
+	End()
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetError(error) {}
+func (noopSpan) End()           {}
+
+// SetTracer sets the tracer of the firings of the tasks. The context of a
+// job carries the span of the job, so that it is passed on to the plugins.
+// A nil tracer disables tracing.
+func (s *scheduler) SetTracer(t Tracer) {
+	s.tracerMutex.Lock()
+	s.tracer = t
+	s.tracerMutex.Unlock()
+	schedulerLogger.WithFields(log.Fields{
+		"_block": "set-tracer",
+	}).Debug("tracer linked")
+}
+
+func (s *scheduler) getTracer() Tracer {
+	s.tracerMutex.RLock()
+	defer s.tracerMutex.RUnlock()
+	return s.tracer
+}
+
+// startSpan starts a span of the task with the tracer of the scheduler,
+// the returned span doing nothing if there is none
+func (t *task) startSpan(ctx context.Context, name string, attrs map[string]string) (context.Context, Span) {
+	if t.tracer == nil {
+		return ctx, noopSpan{}
+	}
+	tracer := t.tracer()
+	if tracer == nil {
+		return ctx, noopSpan{}
+	}
+	if attrs == nil {
+		attrs = make(map[string]string)
+	}
+	attrs["task.id"] = t.id
+	attrs["task.name"] = t.name
+	return tracer.StartSpan(ctx, name, attrs)
+}
+
+// startPluginSpan starts the span of a job of a plugin of the task
+func (t *task) startPluginSpan(ctx context.Context, name, pluginName string, pluginVersion int) (context.Context, Span) {
+	return t.startSpan(ctx, name, map[string]string{
+		"plugin.name":    pluginName,
+		"plugin.version": strconv.Itoa(pluginVersion),
+	})
+}
+
+// withSpanContext derives the context of the job from ctx carrying its span
+func withSpanContext(j job, ctx context.Context) {
+	if cj, ok := j.(interface {
+		setParentContext(context.Context)
+	}); ok {
+		cj.setParentContext(ctx)
+	}
+}
+
+// endSpan ends the span of a job, marked as failed with the first of the
+// errors of the job
+func endSpan(span Span, errs []error) {
+	if len(errs) > 0 {
+		span.SetError(errs[0])
+	}
+	span.End()
+}
+
+// traceFailures returns recordFailure marking span as failed with the first
+// error of each failed job before passing them on
+func traceFailures(span Span, recordFailure func([]error)) func([]error) {
+	return func(errs []error) {
+		if len(errs) > 0 {
+			span.SetError(errs[0])
+		}
+		recordFailure(errs)
+	}
+}
@@ -0,0 +1,155 @@
+// +build legacy
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2015 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/core/cdata"
+)
+
+type spanKey struct{}
+
+type recordedSpan struct {
+	name   string
+	parent string
+	attrs  map[string]string
+	err    error
+	ended  bool
+}
+
+// recordingTracer records the spans it starts, the parent of a span being
+// the span carried by its context
+type recordingTracer struct {
+	sync.Mutex
+	spans []*recordedSpan
+}
+
+func (r *recordingTracer) StartSpan(ctx context.Context, name string, attrs map[string]string) (context.Context, Span) {
+	r.Lock()
+	defer r.Unlock()
+	sp := &recordedSpan{name: name, attrs: attrs}
+	if parent, ok := ctx.Value(spanKey{}).(*recordedSpan); ok {
+		sp.parent = parent.name
+	}
+	r.spans = append(r.spans, sp)
+	return context.WithValue(ctx, spanKey{}, sp), &recordingSpan{tracer: r, span: sp}
+}
+
+func (r *recordingTracer) named(name string) []*recordedSpan {
+	r.Lock()
+	defer r.Unlock()
+	var spans []*recordedSpan
+	for _, sp := range r.spans {
+		if sp.name == name {
+			spans = append(spans, sp)
+		}
+	}
+	return spans
+}
+
+type recordingSpan struct {
+	tracer *recordingTracer
+	span   *recordedSpan
+}
+
+func (s *recordingSpan) SetError(err error) {
+	s.tracer.Lock()
+	defer s.tracer.Unlock()
+	s.span.err = err
+}
+
+func (s *recordingSpan) End() {
+	s.tracer.Lock()
+	defer s.tracer.Unlock()
+	s.span.ended = true
+}
+
+func TestTracing(t *testing.T) {
+	Convey("Tracing the jobs of a firing", t, func() {
+		rec := &recordingTracer{}
+		m := &Mock1{queue: make(map[string]int)}
+		pj := newCollectorJob(nil, time.Second, m, nil, "", nil)
+		tsk := &task{manager: m, id: "1", name: "mock", tracer: func() Tracer { return rec }}
+		pr := &processNode{config: cdata.NewNode(), name: "passthru", version: 2}
+		pus := []*publishNode{}
+		for x := 0; x < 2; x++ {
+			pu := &publishNode{config: cdata.NewNode(), name: fmt.Sprintf("file%d", x)}
+			pr.PublishNodes = append(pr.PublishNodes, pu)
+			pus = append(pus, pu)
+		}
+		ctx, span := tsk.startSpan(context.Background(), SpanFire, nil)
+		Convey("starts a span per job, children of the span of the firing", func() {
+			workJobs(ctx, []*processNode{pr}, nil, tsk, pj, traceFailures(span, tsk.RecordFailure))
+			span.End()
+			fire := rec.named(SpanFire)
+			So(fire, ShouldHaveLength, 1)
+			So(fire[0].attrs, ShouldResemble, map[string]string{"task.id": "1", "task.name": "mock"})
+			So(fire[0].err, ShouldBeNil)
+			So(fire[0].ended, ShouldBeTrue)
+			process := rec.named(SpanProcess)
+			So(process, ShouldHaveLength, 1)
+			So(process[0].parent, ShouldEqual, SpanFire)
+			So(process[0].attrs["plugin.name"], ShouldEqual, "passthru")
+			So(process[0].attrs["plugin.version"], ShouldEqual, "2")
+			So(process[0].ended, ShouldBeTrue)
+			publish := rec.named(SpanPublish)
+			So(publish, ShouldHaveLength, 2)
+			for _, sp := range publish {
+				So(sp.parent, ShouldEqual, SpanProcess)
+				So(sp.ended, ShouldBeTrue)
+			}
+		})
+		Convey("marks the spans of a failed job and its firing as failed", func() {
+			m.errorIndex = 1
+			workJobs(ctx, nil, pus, tsk, pj, traceFailures(span, tsk.RecordFailure))
+			span.End()
+			So(rec.named(SpanFire)[0].err, ShouldNotBeNil)
+			var failed int
+			for _, sp := range rec.named(SpanPublish) {
+				if sp.err != nil {
+					failed++
+				}
+			}
+			So(failed, ShouldEqual, 1)
+		})
+		Convey("passes the span of a job to the plugins through the context of the job", func() {
+			j := newCollectorJob(nil, time.Second, m, nil, "", nil)
+			jctx, jspan := tsk.startSpan(ctx, SpanCollect, nil)
+			withSpanContext(j, jctx)
+			So(j.Context().Value(spanKey{}), ShouldEqual, rec.named(SpanCollect)[0])
+			jspan.End()
+		})
+		Convey("does nothing without a tracer", func() {
+			tsk.tracer = func() Tracer { return nil }
+			nctx, nspan := tsk.startSpan(context.Background(), SpanFire, nil)
+			So(nctx.Value(spanKey{}), ShouldBeNil)
+			So(nspan, ShouldResemble, noopSpan{})
+		})
+	})
+}
@@ -133,6 +133,23 @@ func (t *taskWatcherCollection) handleMetricCollected(taskID string, m []core.Me
 	}
 }
 
+func (t *taskWatcherCollection) handleTaskRun(taskID string, run core.TaskRun) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	// Walk the watchers for a task ID which catch runs
+	for _, v := range t.coll[taskID] {
+		h, ok := v.handler.(core.TaskRunWatcherHandler)
+		if !ok {
+			continue
+		}
+		watcherLog.WithFields(log.Fields{
+			"task-id":         taskID,
+			"task-watcher-id": v.id,
+		}).Debug("calling taskwatcher task run func")
+		h.CatchRun(run)
+	}
+}
+
 func (t *taskWatcherCollection) handleTaskStarted(taskID string) {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
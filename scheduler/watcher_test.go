@@ -22,7 +22,9 @@ limitations under the License.
 package scheduler
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/intelsdi-x/snap/core"
 
@@ -63,6 +65,41 @@ func (d *mockCatcher) CatchTaskStarted() {
 	sum++
 }
 
+type mockRunCatcher struct {
+	mockCatcher
+	runs []core.TaskRun
+}
+
+func (d *mockRunCatcher) CatchRun(run core.TaskRun) {
+	d.runs = append(d.runs, run)
+}
+
+func TestTaskRunWatching(t *testing.T) {
+	log.SetLevel(log.FatalLevel)
+	Convey("Watchers catching runs", t, func() {
+		twc := newTaskWatcherCollection()
+		d1 := &mockCatcher{}
+		d2 := &mockRunCatcher{}
+		twc.add("1", d1)
+		twc.add("1", d2)
+
+		start := time.Now()
+		twc.handleTaskRun("1", core.TaskRun{Start: start, Duration: time.Second})
+		twc.handleTaskRun("1", core.TaskRun{Errors: []error{errors.New("collection failed")}})
+		twc.handleTaskRun("2", core.TaskRun{})
+
+		Convey("Should be given every run of the task", func() {
+			So(d2.runs, ShouldHaveLength, 2)
+			So(d2.runs[0].Start, ShouldResemble, start)
+			So(d2.runs[0].Duration, ShouldEqual, time.Second)
+			So(d2.runs[1].Errors, ShouldHaveLength, 1)
+		})
+		Convey("Should leave the other watchers alone", func() {
+			So(d1.count, ShouldEqual, 0)
+		})
+	})
+}
+
 func TestTaskWatching(t *testing.T) {
 	log.SetLevel(log.FatalLevel)
 	Convey("", t, func() {
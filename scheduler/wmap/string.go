@@ -42,6 +42,9 @@ func (c *CollectWorkflowMapNode) String(pad string) string {
 	for k, v := range c.Metrics {
 		out += pad + fmt.Sprintf("      Namespace: %s\n", k)
 		out += pad + fmt.Sprintf("         Version: %d\n", v.Version_)
+		if v.Every_ > 0 {
+			out += pad + fmt.Sprintf("         Every: %d\n", v.Every_)
+		}
 	}
 	out += "\n"
 	out += pad + "Config:\n"
@@ -60,6 +63,14 @@ func (c *CollectWorkflowMapNode) String(pad string) string {
 		}
 	}
 	out += "\n"
+	out += pad + "Match:\n"
+	for k, v := range c.Match {
+		out += pad + "   " + k + "\n"
+		for x, y := range v {
+			out += pad + "      " + fmt.Sprintf("%s=%+v\n", x, y)
+		}
+	}
+	out += "\n"
 	out += pad + "Process Nodes:\n"
 	for _, pr := range c.Process {
 		out += pr.String(pad)
@@ -176,6 +176,7 @@ type CollectWorkflowMapNode struct {
 	Metrics map[string]metricInfo             `json:"metrics"yaml:"metrics"`
 	Config  map[string]map[string]interface{} `json:"config,omitempty"yaml:"config"`
 	Tags    map[string]map[string]string      `json:"tags,omitempty"yaml:"tags"`
+	Match   map[string]map[string]string      `json:"match,omitempty"yaml:"match"`
 	Process []ProcessWorkflowMapNode          `json:"process,omitempty"yaml:"process"`
 	Publish []PublishWorkflowMapNode          `json:"publish,omitempty"yaml:"publish"`
 }
@@ -199,6 +200,10 @@ func (cw *CollectWorkflowMapNode) UnmarshalJSON(data []byte) error {
 			if err := json.Unmarshal(v, &cw.Tags); err != nil {
 				return fmt.Errorf("%v (while parsing 'tags')", err)
 			}
+		case "match":
+			if err := json.Unmarshal(v, &cw.Match); err != nil {
+				return fmt.Errorf("%v (while parsing 'match')", err)
+			}
 		case "process":
 			if err := json.Unmarshal(v, &cw.Process); err != nil {
 				return err
@@ -225,6 +230,7 @@ func (c *CollectWorkflowMapNode) GetMetrics() []Metric {
 		metrics[i] = Metric{
 			namespace: strings.Split(ns, firstChar),
 			version:   v.Version_,
+			every:     v.Every_,
 		}
 		i++
 	}
@@ -235,6 +241,12 @@ func (c *CollectWorkflowMapNode) GetTags() map[string]map[string]string {
 	return c.Tags
 }
 
+// GetMatch returns the tags the collected metrics must carry, keyed by the
+// namespace the metrics fall under
+func (c *CollectWorkflowMapNode) GetMatch() map[string]map[string]string {
+	return c.Match
+}
+
 func NewCollectWorkflowMapNode() *CollectWorkflowMapNode {
 	return &CollectWorkflowMapNode{
 		Metrics: make(map[string]metricInfo),
@@ -277,6 +289,13 @@ func (c *CollectWorkflowMapNode) AddMetric(ns string, v int) error {
 	return nil
 }
 
+// AddMetricEvery adds a metric collected once every given number of firings
+// of the task
+func (c *CollectWorkflowMapNode) AddMetricEvery(ns string, v, every int) error {
+	c.Metrics[ns] = metricInfo{Version_: v, Every_: every}
+	return nil
+}
+
 func (c *CollectWorkflowMapNode) AddConfigItem(ns, key string, value interface{}) {
 	if c.Config[ns] == nil {
 		c.Config[ns] = make(map[string]interface{})
@@ -293,6 +312,17 @@ type ProcessWorkflowMapNode struct {
 	// Config the configuration of a processor.
 	Config map[string]interface{} `json:"config,omitempty"yaml:"config"`
 	Target string                 `json:"target"yaml:"target"`
+	// Retries the number of times a failed job of the node is retried, in
+	// place of the retries of the task when set
+	Retries int `json:"retries,omitempty"yaml:"retries"`
+	// RetryBackoff the backoff before the first retry, which doubles for
+	// each following retry
+	RetryBackoff string `json:"retry_backoff,omitempty"yaml:"retry_backoff"`
+	// RetryJitter the maximum random delay added to the backoff of a retry
+	RetryJitter string `json:"retry_jitter,omitempty"yaml:"retry_jitter"`
+	// Builtin the step run by the scheduler in place of a processor plugin,
+	// filter or downsample, configured by the config of the node
+	Builtin string `json:"builtin,omitempty"yaml:"builtin"`
 }
 
 func (pw *ProcessWorkflowMapNode) UnmarshalJSON(data []byte) error {
@@ -326,6 +356,22 @@ func (pw *ProcessWorkflowMapNode) UnmarshalJSON(data []byte) error {
 			if err := json.Unmarshal(v, &pw.Target); err != nil {
 				return fmt.Errorf("%v (while parsing 'target')", err)
 			}
+		case "retries":
+			if err := json.Unmarshal(v, &pw.Retries); err != nil {
+				return fmt.Errorf("%v (while parsing 'retries')", err)
+			}
+		case "retry_backoff":
+			if err := json.Unmarshal(v, &pw.RetryBackoff); err != nil {
+				return fmt.Errorf("%v (while parsing 'retry_backoff')", err)
+			}
+		case "retry_jitter":
+			if err := json.Unmarshal(v, &pw.RetryJitter); err != nil {
+				return fmt.Errorf("%v (while parsing 'retry_jitter')", err)
+			}
+		case "builtin":
+			if err := json.Unmarshal(v, &pw.Builtin); err != nil {
+				return fmt.Errorf("%v (while parsing 'builtin')", err)
+			}
 		default:
 			return fmt.Errorf("Unrecognized key '%v' in process workflow of task.", k)
 		}
@@ -376,6 +422,20 @@ type PublishWorkflowMapNode struct {
 	// Config the config of a publisher
 	Config map[string]interface{} `json:"config,omitempty"yaml:"config"`
 	Target string                 `json:"target"yaml:"target"`
+	// Retries the number of times a failed job of the node is retried, in
+	// place of the retries of the task when set
+	Retries int `json:"retries,omitempty"yaml:"retries"`
+	// RetryBackoff the backoff before the first retry, which doubles for
+	// each following retry
+	RetryBackoff string `json:"retry_backoff,omitempty"yaml:"retry_backoff"`
+	// RetryJitter the maximum random delay added to the backoff of a retry
+	RetryJitter string `json:"retry_jitter,omitempty"yaml:"retry_jitter"`
+	// BatchSize the number of metrics buffered before they are published
+	// in one job, the metrics of every firing are published if 0
+	BatchSize int `json:"batch_size,omitempty"yaml:"batch_size"`
+	// BatchMaxAge how long the oldest buffered metrics wait before the
+	// buffered metrics are published
+	BatchMaxAge string `json:"batch_max_age,omitempty"yaml:"batch_max_age"`
 }
 
 func (pw *PublishWorkflowMapNode) UnmarshalJSON(data []byte) error {
@@ -401,6 +461,26 @@ func (pw *PublishWorkflowMapNode) UnmarshalJSON(data []byte) error {
 			if err := json.Unmarshal(v, &pw.Target); err != nil {
 				return fmt.Errorf("%v (while parsing 'target')", err)
 			}
+		case "retries":
+			if err := json.Unmarshal(v, &pw.Retries); err != nil {
+				return fmt.Errorf("%v (while parsing 'retries')", err)
+			}
+		case "retry_backoff":
+			if err := json.Unmarshal(v, &pw.RetryBackoff); err != nil {
+				return fmt.Errorf("%v (while parsing 'retry_backoff')", err)
+			}
+		case "retry_jitter":
+			if err := json.Unmarshal(v, &pw.RetryJitter); err != nil {
+				return fmt.Errorf("%v (while parsing 'retry_jitter')", err)
+			}
+		case "batch_size":
+			if err := json.Unmarshal(v, &pw.BatchSize); err != nil {
+				return fmt.Errorf("%v (while parsing 'batch_size')", err)
+			}
+		case "batch_max_age":
+			if err := json.Unmarshal(v, &pw.BatchMaxAge); err != nil {
+				return fmt.Errorf("%v (while parsing 'batch_max_age')", err)
+			}
 		default:
 			return fmt.Errorf("Unrecognized key '%v' in publish workflow of task.", k)
 		}
@@ -432,6 +512,9 @@ func (p *PublishWorkflowMapNode) GetConfigNode() (*cdata.ConfigDataNode, error)
 
 type metricInfo struct {
 	Version_ int `json:"version"yaml:"version"`
+	// Every_ is the number of firings of the task the metric is collected
+	// once every, on every firing if 0
+	Every_ int `json:"every,omitempty"yaml:"every"`
 }
 
 func (m *metricInfo) UnmarshalJSON(data []byte) error {
@@ -445,6 +528,10 @@ func (m *metricInfo) UnmarshalJSON(data []byte) error {
 			if err := json.Unmarshal(v, &m.Version_); err != nil {
 				return fmt.Errorf("%v (while parsing 'version')", err)
 			}
+		case "every":
+			if err := json.Unmarshal(v, &m.Every_); err != nil {
+				return fmt.Errorf("%v (while parsing 'every')", err)
+			}
 		default:
 			return fmt.Errorf("Unrecognized key '%v' in metrics in collect workflow of task", k)
 		}
@@ -455,6 +542,7 @@ func (m *metricInfo) UnmarshalJSON(data []byte) error {
 type Metric struct {
 	namespace []string
 	version   int
+	every     int
 }
 
 func (m Metric) Namespace() []string {
@@ -465,6 +553,18 @@ func (m Metric) Version() int {
 	return m.version
 }
 
+// Every returns the number of firings of the task the metric is collected
+// once every, 0 if it is collected on every firing
+func (m Metric) Every() int {
+	return m.every
+}
+
+// ConfigDataNodeFromMap converts the config items of a map, as decoded from a
+// task manifest, into a config data node
+func ConfigDataNodeFromMap(cmap map[string]interface{}) (*cdata.ConfigDataNode, error) {
+	return configtoConfigDataNode(cmap, "")
+}
+
 func configtoConfigDataNode(cmap map[string]interface{}, ns string) (*cdata.ConfigDataNode, error) {
 	cdn := cdata.NewNode()
 	for ck, cv := range cmap {
@@ -483,6 +583,15 @@ func configtoConfigDataNode(cmap map[string]interface{}, ns string) (*cdata.Conf
 			}
 		case bool:
 			cdn.AddItem(ck, ctypes.ConfigValueBool{Value: v})
+		case map[string]interface{}, map[interface{}]interface{}:
+			if !ctypes.IsSecret(stringKeys(v)) {
+				return nil, errors.New(fmt.Sprintf("Cannot convert config value to config data node: %s=>%+v", ns, v))
+			}
+			secret, err := ctypes.SecretFromManifest(stringKeys(v))
+			if err != nil {
+				return nil, fmt.Errorf("Cannot convert secret config value %s of %s: %v", ck, ns, err)
+			}
+			cdn.AddItem(ck, secret)
 		default:
 			// TODO make sure this is covered in tests!!!
 			return nil, errors.New(fmt.Sprintf("Cannot convert config value to config data node: %s=>%+v", ns, v))
@@ -490,3 +599,120 @@ func configtoConfigDataNode(cmap map[string]interface{}, ns string) (*cdata.Conf
 	}
 	return cdn, nil
 }
+
+// stringKeys returns a map decoded from YAML, keyed by interface{}, keyed by
+// its keys as strings like a map decoded from JSON
+func stringKeys(v interface{}) interface{} {
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return v
+	}
+	sm := make(map[string]interface{}, len(m))
+	for k, mv := range m {
+		sm[fmt.Sprintf("%v", k)] = mv
+	}
+	return sm
+}
+
+// Redacted returns a copy of the workflow map with its secret config values
+// redacted, to be shown in place of the workflow map of a task
+func (w *WorkflowMap) Redacted() *WorkflowMap {
+	r, _ := w.mapSecrets(func(interface{}) (interface{}, error) {
+		return ctypes.SecretRedacted, nil
+	})
+	return r
+}
+
+// Encrypted returns a copy of the workflow map with its secret config values
+// encrypted, the form in which the workflow map of a task is saved or
+// exported. It fails if a secret cannot be encrypted, as when no key
+// provider is set.
+func (w *WorkflowMap) Encrypted() (*WorkflowMap, error) {
+	return w.mapSecrets(func(v interface{}) (interface{}, error) {
+		secret, err := ctypes.SecretFromManifest(v)
+		if err != nil {
+			return nil, err
+		}
+		return ctypes.EncryptedManifestSecret(secret.Value)
+	})
+}
+
+// mapSecrets returns a copy of the workflow map with each of its secret
+// config values replaced by the value returned by f
+func (w *WorkflowMap) mapSecrets(f func(interface{}) (interface{}, error)) (*WorkflowMap, error) {
+	if w == nil || w.Collect == nil {
+		return w, nil
+	}
+	c := *w.Collect
+	if c.Config != nil {
+		c.Config = make(map[string]map[string]interface{}, len(w.Collect.Config))
+		for ns, cmap := range w.Collect.Config {
+			m, err := mapConfigSecrets(cmap, f)
+			if err != nil {
+				return nil, err
+			}
+			c.Config[ns] = m
+		}
+	}
+	var err error
+	if c.Process, err = mapProcessSecrets(c.Process, f); err != nil {
+		return nil, err
+	}
+	if c.Publish, err = mapPublishSecrets(c.Publish, f); err != nil {
+		return nil, err
+	}
+	return &WorkflowMap{Collect: &c}, nil
+}
+
+func mapProcessSecrets(nodes []ProcessWorkflowMapNode, f func(interface{}) (interface{}, error)) ([]ProcessWorkflowMapNode, error) {
+	if nodes == nil {
+		return nil, nil
+	}
+	mapped := make([]ProcessWorkflowMapNode, len(nodes))
+	for i, n := range nodes {
+		var err error
+		if n.Config, err = mapConfigSecrets(n.Config, f); err != nil {
+			return nil, err
+		}
+		if n.Process, err = mapProcessSecrets(n.Process, f); err != nil {
+			return nil, err
+		}
+		if n.Publish, err = mapPublishSecrets(n.Publish, f); err != nil {
+			return nil, err
+		}
+		mapped[i] = n
+	}
+	return mapped, nil
+}
+
+func mapPublishSecrets(nodes []PublishWorkflowMapNode, f func(interface{}) (interface{}, error)) ([]PublishWorkflowMapNode, error) {
+	if nodes == nil {
+		return nil, nil
+	}
+	mapped := make([]PublishWorkflowMapNode, len(nodes))
+	for i, n := range nodes {
+		var err error
+		if n.Config, err = mapConfigSecrets(n.Config, f); err != nil {
+			return nil, err
+		}
+		mapped[i] = n
+	}
+	return mapped, nil
+}
+
+func mapConfigSecrets(cmap map[string]interface{}, f func(interface{}) (interface{}, error)) (map[string]interface{}, error) {
+	if cmap == nil {
+		return nil, nil
+	}
+	mapped := make(map[string]interface{}, len(cmap))
+	for k, v := range cmap {
+		if v = stringKeys(v); ctypes.IsSecret(v) {
+			var err error
+			if v, err = f(v); err != nil {
+				return nil, err
+			}
+		}
+		mapped[k] = v
+	}
+	return mapped, nil
+}
@@ -24,10 +24,12 @@ package wmap
 import (
 	"io/ioutil"
 	"strconv"
+	"strings"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
 
+	"github.com/intelsdi-x/snap/core/ctypes"
 	"github.com/intelsdi-x/snap/scheduler/wmap/fixtures"
 )
 
@@ -101,6 +103,39 @@ func TestTagsOnWorkflow(t *testing.T) {
 	})
 }
 
+func TestMatchOnWorkflow(t *testing.T) {
+	Convey("Extracting the matched tags from workflow", t, func() {
+		wmap, err := FromJson([]byte(`{
+			"collect": {
+				"metrics": {"/intel/mock/*/baz": {}},
+				"match": {"/intel/mock": {"region": "us"}}
+			}
+		}`))
+		So(err, ShouldBeNil)
+		So(wmap.Collect.GetMatch(), ShouldResemble, map[string]map[string]string{
+			"/intel/mock": {"region": "us"},
+		})
+	})
+}
+
+func TestMetricEveryOnWorkflow(t *testing.T) {
+	Convey("Extracting the firings metrics are collected once every", t, func() {
+		wmap, err := FromYaml([]byte(`
+collect:
+  metrics:
+    /intel/disk/*:
+      every: 30
+    /intel/cpu/*: {}
+`))
+		So(err, ShouldBeNil)
+		every := map[string]int{}
+		for _, m := range wmap.Collect.GetMetrics() {
+			every[strings.Join(m.Namespace(), "/")] = m.Every()
+		}
+		So(every, ShouldResemble, map[string]int{"intel/disk/*": 30, "intel/cpu/*": 0})
+	})
+}
+
 func TestWfGetRequestedMetrics(t *testing.T) {
 	Convey("NewWorkFlowMap()/GetRequestedMetrics()", t, func() {
 		wmap := NewWorkflowMap()
@@ -215,6 +250,53 @@ func TestWfGetConfigNodeTree(t *testing.T) {
 	})
 }
 
+func TestWfSecretConfig(t *testing.T) {
+	Convey("Secret config values", t, func() {
+		wmap, err := FromJson(`{"collect": {"metrics": {"/foo/bar": {}},
+			"publish": [{"plugin_name": "stuff", "config": {"user": "stu", "password": {"secret": "hunter2"}}}]}}`)
+		So(err, ShouldBeNil)
+		Convey("are secret in the config node", func() {
+			cdn, err := wmap.Collect.Publish[0].GetConfigNode()
+			So(err, ShouldBeNil)
+			So(cdn.Table()["password"], ShouldResemble, ctypes.NewSecret("hunter2"))
+			So(cdn.Table()["user"], ShouldResemble, ctypes.ConfigValueStr{Value: "stu"})
+		})
+		Convey("are redacted", func() {
+			r := wmap.Redacted()
+			So(r.Collect.Publish[0].Config["password"], ShouldEqual, ctypes.SecretRedacted)
+			So(r.Collect.Publish[0].Config["user"], ShouldEqual, "stu")
+			So(ctypes.IsSecret(wmap.Collect.Publish[0].Config["password"]), ShouldBeTrue)
+		})
+		Convey("cannot be encrypted without a key", func() {
+			_, err := wmap.Encrypted()
+			So(err, ShouldEqual, ctypes.ErrSecretKeyNotSet)
+		})
+		Convey("are encrypted with a key and decrypted back", func() {
+			ctypes.SetSecretKeyProvider(ctypes.StaticSecretKey(strings.Repeat("k", 32)))
+			defer ctypes.SetSecretKeyProvider(nil)
+			e, err := wmap.Encrypted()
+			So(err, ShouldBeNil)
+			js, err := e.ToJson()
+			So(err, ShouldBeNil)
+			So(string(js), ShouldNotContainSubstring, "hunter2")
+			decoded, err := FromJson(js)
+			So(err, ShouldBeNil)
+			cdn, err := decoded.Collect.Publish[0].GetConfigNode()
+			So(err, ShouldBeNil)
+			So(cdn.Table()["password"], ShouldResemble, ctypes.NewSecret("hunter2"))
+		})
+		Convey("encrypted under another key are refused", func() {
+			ctypes.SetSecretKeyProvider(ctypes.StaticSecretKey(strings.Repeat("k", 32)))
+			e, err := wmap.Encrypted()
+			So(err, ShouldBeNil)
+			ctypes.SetSecretKeyProvider(ctypes.StaticSecretKey(strings.Repeat("x", 32)))
+			defer ctypes.SetSecretKeyProvider(nil)
+			_, err = e.Collect.Publish[0].GetConfigNode()
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
 func TestStringByteConvertion(t *testing.T) {
 	Convey("Converts strings to bytes or keeps byte type", t, func() {
 		p, err := inStringBytes("test")
@@ -19,7 +19,22 @@ limitations under the License.
 
 package scheduler
 
-import "sync"
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+var (
+	errWorkManagerDraining = errors.New("work manager is draining")
+	errWorkManagerStopped  = errors.New("work manager is shut down")
+
+	workManagerLogger = schedulerLogger.WithField("_module", "scheduler-workManager")
+)
 
 /*
 
@@ -56,11 +71,95 @@ type workManager struct {
 	collectWkrSize uint
 	publishWkrSize uint
 	processWkrSize uint
+	taskQSize      uint
+	qFullPolicy    QueueFullPolicy
 	collectchan    chan queuedJob
 	publishchan    chan queuedJob
 	processchan    chan queuedJob
 	kill           chan struct{}
 	mutex          *sync.Mutex
+	// draining is set while the work manager refuses new collect jobs
+	draining bool
+	// inFlight counts the jobs queued or being worked
+	inFlight uint
+	// idle is closed once no job is in flight anymore during a drain
+	idle chan struct{}
+	// shutdown is set once the work manager is shut down, until restarted
+	shutdown bool
+	// cancel is closed on shutdown to make the workers abandon the job
+	// they are running and exit
+	cancel chan struct{}
+	// workers counts the workers which have not exited
+	workers sync.WaitGroup
+	// stats counts the jobs run and dropped by the work manager
+	stats workStats
+	// maxWorkerRestarts is the number of crashed workers replaced within
+	// workerRestartWindow, the pool of a worker crashing past it shrinks
+	maxWorkerRestarts   uint
+	workerRestartWindow time.Duration
+	// restarts holds the times the crashed workers were replaced within
+	// the last workerRestartWindow
+	restarts []time.Time
+	// workerRetired is called when a crashed worker is not replaced, may be nil
+	workerRetired func(pool, workerID string)
+	// jobDropped is called when a job is dropped because its queue was full
+	// or its deadline passed, may be nil
+	jobDropped func(queue string, j job, err error)
+}
+
+// workStats holds the job counters of a work manager. The counters are
+// updated atomically by the workers.
+type workStats struct {
+	active    int64
+	live      int64
+	processed uint64
+	dropped   uint64
+	panicked  uint64
+	restarted uint64
+	retired   uint64
+}
+
+// spawned is called when a worker is started
+func (s *workStats) spawned() {
+	if s != nil {
+		atomic.AddInt64(&s.live, 1)
+	}
+}
+
+// exited is called when a worker stops
+func (s *workStats) exited() {
+	if s != nil {
+		atomic.AddInt64(&s.live, -1)
+	}
+}
+
+// started is called when a worker starts running a job
+func (s *workStats) started() {
+	if s != nil {
+		atomic.AddInt64(&s.active, 1)
+	}
+}
+
+// finished is called when a worker is done running a job
+func (s *workStats) finished() {
+	if s != nil {
+		atomic.AddInt64(&s.active, -1)
+		atomic.AddUint64(&s.processed, 1)
+	}
+}
+
+// panic is called when a job panicked while running
+func (s *workStats) panic() {
+	if s != nil {
+		atomic.AddUint64(&s.panicked, 1)
+	}
+}
+
+// drop is called when a job is refused
+func (s *workStats) drop() {
+	if s != nil {
+		atomic.AddUint64(&s.dropped, 1)
+	}
 }
 
 type workManagerState int
@@ -71,6 +170,9 @@ const (
 
 	defaultQSize   uint = 5
 	defaultWkrSize uint = 1
+
+	defaultMaxWorkerRestarts   uint = 5
+	defaultWorkerRestartWindow      = time.Minute
 )
 
 type workManagerOption func(w *workManager) workManagerOption
@@ -135,6 +237,67 @@ func PublishWkrSizeOption(v uint) workManagerOption {
 	}
 }
 
+// TaskQSizeOption sets the number of jobs of each task the queues hold,
+// the queue sizes alone apply if 0, and returns the previous option state.
+func TaskQSizeOption(v uint) workManagerOption {
+	return func(w *workManager) workManagerOption {
+		previous := w.taskQSize
+		w.taskQSize = v
+		return TaskQSizeOption(previous)
+	}
+}
+
+// QueueFullPolicyOption sets what happens to jobs submitted to a full
+// queue and returns the previous policy option state.
+func QueueFullPolicyOption(v QueueFullPolicy) workManagerOption {
+	return func(w *workManager) workManagerOption {
+		previous := w.qFullPolicy
+		w.qFullPolicy = v
+		return QueueFullPolicyOption(previous)
+	}
+}
+
+// MaxWorkerRestartsOption sets the number of crashed workers replaced
+// within the restart window and returns the previous option state.
+func MaxWorkerRestartsOption(v uint) workManagerOption {
+	return func(w *workManager) workManagerOption {
+		previous := w.maxWorkerRestarts
+		w.maxWorkerRestarts = v
+		return MaxWorkerRestartsOption(previous)
+	}
+}
+
+// WorkerRestartWindowOption sets the window the workers restarts are
+// counted over and returns the previous option state.
+func WorkerRestartWindowOption(v time.Duration) workManagerOption {
+	return func(w *workManager) workManagerOption {
+		previous := w.workerRestartWindow
+		w.workerRestartWindow = v
+		return WorkerRestartWindowOption(previous)
+	}
+}
+
+// workerRetiredOption sets the function called when a crashed worker is
+// not replaced and returns the previous option state.
+func workerRetiredOption(v func(pool, workerID string)) workManagerOption {
+	return func(w *workManager) workManagerOption {
+		previous := w.workerRetired
+		w.workerRetired = v
+		return workerRetiredOption(previous)
+	}
+}
+
+// jobDroppedOption sets the function called when a job is dropped because
+// its queue was full or its deadline passed and returns the previous option
+// state.
+func jobDroppedOption(v func(queue string, j job, err error)) workManagerOption {
+	return func(w *workManager) workManagerOption {
+		previous := w.jobDropped
+		w.jobDropped = v
+		return jobDroppedOption(previous)
+	}
+}
+
 func newWorkManager(opts ...workManagerOption) *workManager {
 
 	wm := &workManager{
@@ -148,7 +311,11 @@ func newWorkManager(opts ...workManagerOption) *workManager {
 		publishchan:    make(chan queuedJob),
 		processchan:    make(chan queuedJob),
 		kill:           make(chan struct{}),
+		cancel:         make(chan struct{}),
 		mutex:          &sync.Mutex{},
+
+		maxWorkerRestarts:   defaultMaxWorkerRestarts,
+		workerRestartWindow: defaultWorkerRestartWindow,
 	}
 
 	//set options
@@ -159,6 +326,12 @@ func newWorkManager(opts ...workManagerOption) *workManager {
 	wm.collectq = newQueue(wm.collectQSize, wm.sendToWorker)
 	wm.publishq = newQueue(wm.publishQSize, wm.sendToWorker)
 	wm.processq = newQueue(wm.processQSize, wm.sendToWorker)
+	wm.collectq.policy = wm.qFullPolicy
+	wm.publishq.policy = wm.qFullPolicy
+	wm.processq.policy = wm.qFullPolicy
+	wm.collectq.taskLimit = wm.taskQSize
+	wm.publishq.taskLimit = wm.taskQSize
+	wm.processq.taskLimit = wm.taskQSize
 
 	wm.publishq.Start()
 	wm.collectq.Start()
@@ -167,45 +340,94 @@ func newWorkManager(opts ...workManagerOption) *workManager {
 	wm.collectWkrs = make([]*worker, wm.collectWkrSize)
 	var i uint
 	for i = 0; i < wm.collectWkrSize; i++ {
-		wm.collectWkrs[i] = newWorker(wm.collectchan)
-		go wm.collectWkrs[i].start()
+		wm.collectWkrs[i] = wm.startWorker(wm.collectchan)
 	}
 	wm.publishWkrs = make([]*worker, wm.publishWkrSize)
 	for i = 0; i < wm.publishWkrSize; i++ {
-		wm.publishWkrs[i] = newWorker(wm.publishchan)
-		go wm.publishWkrs[i].start()
+		wm.publishWkrs[i] = wm.startWorker(wm.publishchan)
 	}
 	wm.processWkrs = make([]*worker, wm.processWkrSize)
 	for i = 0; i < wm.processWkrSize; i++ {
-		wm.processWkrs[i] = newWorker(wm.processchan)
-		go wm.processWkrs[i].start()
+		wm.processWkrs[i] = wm.startWorker(wm.processchan)
 	}
 	return wm
 }
 
 // Start workManager's loop just handles queuing errors.
-func (w *workManager) Start() {
+// It returns an error if one of the worker pools is empty or none of the
+// workers is running, as the queued jobs would never be worked.
+func (w *workManager) Start() error {
 
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
 
+	if w.collectWkrSize == 0 || w.processWkrSize == 0 || w.publishWkrSize == 0 {
+		return ErrInvalidPoolSize
+	}
+	if w.shutdown {
+		w.restartWorkers()
+	}
+	if atomic.LoadInt64(&w.stats.live) == 0 {
+		return ErrNoLiveWorkers
+	}
 	if w.state == workManagerStopped {
 		w.state = workManagerRunning
 		go func() {
 			for {
 				select {
-				case <-w.collectq.Err:
-					//TODO: log error
-				case <-w.processq.Err:
-					//TODO: log error
-				case <-w.publishq.Err:
-					//TODO: log error
+				case qe := <-w.collectq.Err:
+					logQueuingError("collect", qe)
+					w.stats.drop()
+					w.dropped("collect", qe.Job, qe.Err)
+				case qe := <-w.processq.Err:
+					logQueuingError("process", qe)
+					w.stats.drop()
+					w.dropped("process", qe.Job, qe.Err)
+				case qe := <-w.publishq.Err:
+					logQueuingError("publish", qe)
+					w.stats.drop()
+					w.dropped("publish", qe.Job, qe.Err)
 				case <-w.kill:
 					return
 				}
 			}
 		}()
 	}
+	return nil
+}
+
+// dropped calls jobDropped for a job dropped from the queue
+func (w *workManager) dropped(queue string, j job, err error) {
+	w.mutex.Lock()
+	jobDropped := w.jobDropped
+	w.mutex.Unlock()
+	if jobDropped != nil {
+		jobDropped(queue, j, err)
+	}
+}
+
+// queueOfJobType returns the name of the queue of the jobs of the type
+func queueOfJobType(t jobType) string {
+	switch t {
+	case collectJobType:
+		return "collect"
+	case processJobType:
+		return "process"
+	case publishJobType:
+		return "publish"
+	}
+	return "unknown"
+}
+
+// logQueuingError logs a job dropped by the queue of the given job type
+func logQueuingError(queue string, qe *queuingError) {
+	workManagerLogger.WithFields(log.Fields{
+		"_block":   "work-manager",
+		"queue":    queue,
+		"task-id":  qe.Job.TaskID(),
+		"job-type": qe.Job.TypeString(),
+		"_error":   qe.Error(),
+	}).Warn("job dropped by the work queue")
 }
 
 // Stop closes the collector queue and worker
@@ -219,9 +441,37 @@ func (w *workManager) Stop() {
 //
 // Returns a queued job to the caller, which will be
 // completed by the work queue aubsystem.
+//
+// While the work manager is draining, collect jobs are refused and
+// completed with an error. Process and publish jobs are still accepted,
+// so workflows which already collected their metrics can finish. Once the
+// work manager is shut down, every job is refused until it is started again.
 func (w *workManager) Work(j job) queuedJob {
 	qj := newQueuedJob(j)
-	switch j.Type() {
+	w.mutex.Lock()
+	if w.draining && j.Type() == collectJobType {
+		w.mutex.Unlock()
+		w.stats.drop()
+		qj.Promise().Complete([]error{errWorkManagerDraining})
+		return qj
+	}
+	if w.shutdown {
+		w.mutex.Unlock()
+		w.stats.drop()
+		qj.Promise().Complete([]error{errWorkManagerStopped})
+		return qj
+	}
+	w.inFlight++
+	w.mutex.Unlock()
+	qj.Promise().AndThen(func([]error) { w.jobDone() })
+
+	w.enqueue(qj)
+	return qj
+}
+
+// enqueue sends the job to the queue of its type
+func (w *workManager) enqueue(qj queuedJob) {
+	switch qj.Job().Type() {
 	case collectJobType:
 		w.collectq.Event <- qj
 	case processJobType:
@@ -229,14 +479,145 @@ func (w *workManager) Work(j job) queuedJob {
 	case publishJobType:
 		w.publishq.Event <- qj
 	}
-	return qj
+}
+
+// retry queues a failed job again once the delay has elapsed, without
+// holding on to the worker in the meantime. The job stays in flight until
+// it is completed, a job waiting to be retried on shutdown is cancelled.
+func (w *workManager) retry(qj queuedJob, delay time.Duration) {
+	w.mutex.Lock()
+	cancel := w.cancel
+	w.mutex.Unlock()
+	go func() {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			w.enqueue(qj)
+		case <-cancel:
+			w.cancelJob(qj)
+		}
+	}()
+}
+
+// cancelJob completes a job which was not worked before the shutdown
+func (w *workManager) cancelJob(qj queuedJob) {
+	qj.Job().AddErrors(errWorkManagerStopped)
+	w.stats.drop()
+	qj.Promise().Complete(qj.Job().Errors())
+}
+
+// Drain stops the work manager from accepting new collect jobs and blocks
+// until all the jobs queued or being worked are completed, or until the
+// context is done. It returns the number of jobs that were still in flight
+// when the context was done.
+func (w *workManager) Drain(ctx context.Context) uint {
+	w.mutex.Lock()
+	w.draining = true
+	if w.inFlight == 0 {
+		w.mutex.Unlock()
+		return 0
+	}
+	if w.idle == nil {
+		w.idle = make(chan struct{})
+	}
+	idle := w.idle
+	w.mutex.Unlock()
+
+	select {
+	case <-idle:
+		return 0
+	case <-ctx.Done():
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+		return w.inFlight
+	}
+}
+
+// Shutdown drains the work manager like Drain, then cancels the jobs still
+// in flight and blocks until all the workers have exited. A job running on
+// shutdown is abandoned like a job exceeding its timeout, and the jobs still
+// queued are completed with an error without being worked. It returns the
+// number of jobs cancelled. The workers are started again by Start.
+func (w *workManager) Shutdown(ctx context.Context) uint {
+	cancelled := w.Drain(ctx)
+
+	w.mutex.Lock()
+	if w.shutdown {
+		w.mutex.Unlock()
+		return cancelled
+	}
+	w.shutdown = true
+	close(w.cancel)
+	w.mutex.Unlock()
+	w.workers.Wait()
+
+	// the jobs left in the queues are handed over to the workers, which
+	// have exited, so they are received and cancelled here
+	for {
+		w.mutex.Lock()
+		if w.inFlight == 0 {
+			w.mutex.Unlock()
+			return cancelled
+		}
+		if w.idle == nil {
+			w.idle = make(chan struct{})
+		}
+		idle := w.idle
+		w.mutex.Unlock()
+
+		select {
+		case qj := <-w.collectchan:
+			w.cancelJob(qj)
+		case qj := <-w.processchan:
+			w.cancelJob(qj)
+		case qj := <-w.publishchan:
+			w.cancelJob(qj)
+		case <-idle:
+		}
+	}
+}
+
+// restartWorkers starts the worker pools of a shut down work manager again,
+// it is called with the mutex held
+func (w *workManager) restartWorkers() {
+	w.shutdown = false
+	w.cancel = make(chan struct{})
+	for i := range w.collectWkrs {
+		w.collectWkrs[i] = w.startWorker(w.collectchan)
+	}
+	for i := range w.processWkrs {
+		w.processWkrs[i] = w.startWorker(w.processchan)
+	}
+	for i := range w.publishWkrs {
+		w.publishWkrs[i] = w.startWorker(w.publishchan)
+	}
+}
+
+// Resume makes a drained work manager accept collect jobs again.
+func (w *workManager) Resume() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.draining = false
+}
+
+// jobDone is called once a job accepted by Work is completed.
+func (w *workManager) jobDone() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.inFlight--
+	if w.inFlight == 0 && w.idle != nil {
+		close(w.idle)
+		w.idle = nil
+	}
 }
 
 // AddCollectWorker adds a new worker to
 // the collector worker pool
 func (w *workManager) AddCollectWorker() {
-	nw := newWorker(w.collectchan)
-	go nw.start()
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	nw := w.startWorker(w.collectchan)
 	w.collectWkrs = append(w.collectWkrs, nw)
 	w.collectWkrSize++
 }
@@ -244,8 +625,9 @@ func (w *workManager) AddCollectWorker() {
 // AddPublishWorker adds a new worker to
 // the publisher worker pool
 func (w *workManager) AddPublishWorker() {
-	nw := newWorker(w.publishchan)
-	go nw.start()
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	nw := w.startWorker(w.publishchan)
 	w.publishWkrs = append(w.publishWkrs, nw)
 	w.publishWkrSize++
 }
@@ -253,12 +635,180 @@ func (w *workManager) AddPublishWorker() {
 // AddProcessWorker adds a new worker to
 // the processor worker pool
 func (w *workManager) AddProcessWorker() {
-	nw := newWorker(w.processchan)
-	go nw.start()
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	nw := w.startWorker(w.processchan)
 	w.processWkrs = append(w.processWkrs, nw)
 	w.processWkrSize++
 }
 
+// SetPoolSize grows or shrinks the worker pool of the jobs of the given
+// type to the given size, leaving the other pools as they are. New workers
+// start immediately, while removed workers finish the job they are working
+// before exiting. Queued jobs are kept.
+func (w *workManager) SetPoolSize(t jobType, size uint) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	switch t {
+	case collectJobType:
+		w.collectWkrs = w.resizePool(w.collectWkrs, size, w.collectchan)
+		w.collectWkrSize = size
+	case publishJobType:
+		w.publishWkrs = w.resizePool(w.publishWkrs, size, w.publishchan)
+		w.publishWkrSize = size
+	case processJobType:
+		w.processWkrs = w.resizePool(w.processWkrs, size, w.processchan)
+		w.processWkrSize = size
+	}
+}
+
+// resizePool returns the pool of workers receiving from rcv grown or
+// shrunk to the given size
+func (w *workManager) resizePool(pool []*worker, size uint, rcv chan queuedJob) []*worker {
+	for uint(len(pool)) < size {
+		pool = append(pool, w.startWorker(rcv))
+	}
+	for uint(len(pool)) > size {
+		close(pool[len(pool)-1].kamikaze)
+		pool = pool[:len(pool)-1]
+	}
+	return pool
+}
+
+// startWorker starts a new worker receiving jobs from rcv
+func (w *workManager) startWorker(rcv chan queuedJob) *worker {
+	nw := newWorker(rcv)
+	nw.stats = &w.stats
+	nw.retry = w.retry
+	nw.crashed = w.workerCrashed
+	nw.overdue = func(j job) { w.dropped(queueOfJobType(j.Type()), j, errJobOverdue) }
+	nw.cancel = w.cancel
+	w.stats.spawned()
+	w.workers.Add(1)
+	go func() {
+		defer w.workers.Done()
+		nw.start()
+	}()
+	return nw
+}
+
+// workerCrashed replaces a worker which exited after a job panicked, unless
+// maxWorkerRestarts workers were already replaced within the restart window.
+// The pool of a worker which is not replaced shrinks by one, so that a job
+// panicking over and over does not restart workers endlessly.
+func (w *workManager) workerCrashed(crashed *worker) {
+	w.mutex.Lock()
+	var (
+		name string
+		pool *[]*worker
+		rcv  chan queuedJob
+	)
+	switch crashed.rcv {
+	case w.collectchan:
+		name, pool, rcv = "collect", &w.collectWkrs, w.collectchan
+	case w.processchan:
+		name, pool, rcv = "process", &w.processWkrs, w.processchan
+	case w.publishchan:
+		name, pool, rcv = "publish", &w.publishWkrs, w.publishchan
+	}
+	i := -1
+	if pool != nil {
+		for j, wkr := range *pool {
+			if wkr == crashed {
+				i = j
+				break
+			}
+		}
+	}
+	// a worker removed from its pool meanwhile is not replaced
+	if i < 0 {
+		w.mutex.Unlock()
+		return
+	}
+
+	now := time.Now()
+	restarts := w.restarts[:0]
+	for _, t := range w.restarts {
+		if now.Sub(t) < w.workerRestartWindow {
+			restarts = append(restarts, t)
+		}
+	}
+	w.restarts = restarts
+	logger := workManagerLogger.WithFields(log.Fields{
+		"_block":    "worker-crashed",
+		"pool":      name,
+		"worker-id": crashed.id,
+		"restarts":  len(w.restarts),
+		"window":    w.workerRestartWindow,
+	})
+
+	if uint(len(w.restarts)) < w.maxWorkerRestarts {
+		w.restarts = append(w.restarts, now)
+		(*pool)[i] = w.startWorker(rcv)
+		atomic.AddUint64(&w.stats.restarted, 1)
+		w.mutex.Unlock()
+		logger.Warn("replaced a worker which crashed")
+		return
+	}
+	*pool = append((*pool)[:i], (*pool)[i+1:]...)
+	atomic.AddUint64(&w.stats.retired, 1)
+	retired := w.workerRetired
+	w.mutex.Unlock()
+	logger.Error("too many workers crashed, the worker pool shrinks")
+	if retired != nil {
+		retired(name, crashed.id)
+	}
+}
+
+// Stats returns a snapshot of the work manager counters
+func (w *workManager) Stats() SchedulerStats {
+	w.mutex.Lock()
+	collectPoolSize := w.collectWkrSize
+	processPoolSize := w.processWkrSize
+	publishPoolSize := w.publishWkrSize
+	queueSize := w.collectQSize
+	w.mutex.Unlock()
+	return SchedulerStats{
+		QueuedJobs:      uint(w.collectq.Len() + w.processq.Len() + w.publishq.Len()),
+		QueueSize:       queueSize,
+		ActiveWorkers:   uint(atomic.LoadInt64(&w.stats.active)),
+		LiveWorkers:     uint(atomic.LoadInt64(&w.stats.live)),
+		PoolSize:        collectPoolSize,
+		CollectPoolSize: collectPoolSize,
+		ProcessPoolSize: processPoolSize,
+		PublishPoolSize: publishPoolSize,
+		ProcessedJobs:   atomic.LoadUint64(&w.stats.processed),
+		DroppedJobs:     atomic.LoadUint64(&w.stats.dropped),
+		PanickedJobs:    atomic.LoadUint64(&w.stats.panicked),
+
+		RestartedWorkers: atomic.LoadUint64(&w.stats.restarted),
+		RetiredWorkers:   atomic.LoadUint64(&w.stats.retired),
+	}
+}
+
+// inFlightJobs returns the number of jobs queued or being worked
+func (w *workManager) inFlightJobs() uint {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.inFlight
+}
+
+// saturation returns the percentage of its limit the fullest work queue is
+// filled to, the jobs held over the limit being left out
+func (w *workManager) saturation() float64 {
+	var max float64
+	for _, q := range []*queue{w.collectq, w.processq, w.publishq} {
+		q.mutex.Lock()
+		if q.limit != 0 {
+			if pct := float64(q.length()) * 100 / float64(q.limit); pct > max {
+				max = pct
+			}
+		}
+		q.mutex.Unlock()
+	}
+	return max
+}
+
 // sendToWorker is the handler given to the queue.
 // it dispatches work to the worker pool.
 func (w *workManager) sendToWorker(j queuedJob) {
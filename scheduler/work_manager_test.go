@@ -22,6 +22,8 @@ limitations under the License.
 package scheduler
 
 import (
+	"context"
+	"errors"
 	"sync"
 	"testing"
 	"time"
@@ -38,10 +40,24 @@ type mockJob struct {
 	errors          []error
 	worked          bool
 	deadline        time.Time
+	timeout         time.Duration
 	starttime       time.Time
 	completePromise Promise
 	numSyncs        int
+	priority        int
 	rvs             []RendezVous
+	// retryMutex guards the runs and retries as RendezVous() holds the job
+	// lock while blocking
+	retryMutex sync.Mutex
+	// failures is the number of runs of the job which fail
+	failures int
+	runs     int
+	retries  int
+	backoff  time.Duration
+	retried  int
+	// the policy applied in place of the policy of the queue when set
+	queueFullPolicy    QueueFullPolicy
+	hasQueueFullPolicy bool
 }
 
 // Create an asynchronous mockJob.
@@ -80,12 +96,58 @@ func (mj *mockJob) AddErrors(errs ...error) {
 	defer mj.Unlock()
 	mj.errors = append(mj.errors, errs...)
 }
-func (mj *mockJob) Errors() []error      { return mj.errors }
-func (mj *mockJob) StartTime() time.Time { return mj.starttime }
-func (mj *mockJob) Deadline() time.Time  { return mj.deadline }
-func (mj *mockJob) Type() jobType        { return collectJobType }
-func (mj *mockJob) TypeString() string   { return "" }
-func (mj *mockJob) TaskID() string       { return "" }
+func (mj *mockJob) Errors() []error {
+	mj.Lock()
+	defer mj.Unlock()
+	return mj.errors
+}
+func (mj *mockJob) StartTime() time.Time       { return mj.starttime }
+func (mj *mockJob) Deadline() time.Time        { return mj.deadline }
+func (mj *mockJob) Timeout() time.Duration     { return mj.timeout }
+func (mj *mockJob) SetTimeout(d time.Duration) { mj.timeout = d }
+func (mj *mockJob) Context() context.Context   { return context.Background() }
+func (mj *mockJob) Cancel()                    {}
+func (mj *mockJob) Type() jobType              { return collectJobType }
+func (mj *mockJob) TypeString() string         { return "" }
+func (mj *mockJob) TaskID() string             { return "" }
+func (mj *mockJob) Priority() int              { return mj.priority }
+func (mj *mockJob) SetPriority(p int)          { mj.priority = p }
+func (mj *mockJob) QueueFullPolicy() (QueueFullPolicy, bool) {
+	return mj.queueFullPolicy, mj.hasQueueFullPolicy
+}
+func (mj *mockJob) SetQueueFullPolicy(p QueueFullPolicy) {
+	mj.queueFullPolicy = p
+	mj.hasQueueFullPolicy = true
+}
+
+func (mj *mockJob) SetRetryJitter(time.Duration) {}
+
+func (mj *mockJob) SetRetry(count int, backoff time.Duration, until time.Time) {
+	mj.retryMutex.Lock()
+	defer mj.retryMutex.Unlock()
+	mj.retries = count
+	mj.backoff = backoff
+}
+func (mj *mockJob) NextRetry() (time.Duration, bool) {
+	mj.retryMutex.Lock()
+	defer mj.retryMutex.Unlock()
+	if mj.retried >= mj.retries {
+		return 0, false
+	}
+	delay := mj.backoff << uint(mj.retried)
+	mj.retried++
+	return delay, true
+}
+func (mj *mockJob) Retried() int {
+	mj.retryMutex.Lock()
+	defer mj.retryMutex.Unlock()
+	return mj.retried
+}
+func (mj *mockJob) ResetErrors() {
+	mj.Lock()
+	defer mj.Unlock()
+	mj.errors = nil
+}
 
 // Complete the first incomplete rendez-vous (if there is one)
 func (mj *mockJob) RendezVous() {
@@ -106,6 +168,13 @@ func (mj *mockJob) Run() {
 	for _, rv := range mj.rvs {
 		rv.A()
 	}
+	mj.retryMutex.Lock()
+	mj.runs++
+	failed := mj.runs <= mj.failures
+	mj.retryMutex.Unlock()
+	if failed {
+		mj.AddErrors(errors.New("mock job failure"))
+	}
 	mj.worked = true
 	mj.completePromise.Complete([]error{})
 }
@@ -162,7 +231,7 @@ func TestWorkerManager(t *testing.T) {
 			So(errs2, ShouldBeEmpty)
 
 			// The work queue should be empty at this point.
-			So(manager.collectq.items, ShouldBeEmpty)
+			So(manager.collectq.Len(), ShouldEqual, 0)
 
 			// The first and second jobs should have been worked.
 			So(j1.worked, ShouldBeTrue)
@@ -212,6 +281,39 @@ func TestWorkerManager(t *testing.T) {
 			manager.Work(j2)
 			manager.Work(j3)
 		})*/
+		Convey("retries a failed job until it succeeds", func() {
+			manager := newWorkManager()
+			j := newMockJob()
+			j.failures = 2
+			j.SetRetry(3, 10*time.Millisecond, time.Time{})
+			errs := manager.Work(j).Promise().Await()
+			So(errs, ShouldBeEmpty)
+			So(j.runs, ShouldEqual, 3)
+			So(j.Retried(), ShouldEqual, 2)
+		})
+		Convey("gives up on a failed job once its retries are exhausted", func() {
+			manager := newWorkManager()
+			j := newMockJob()
+			j.failures = 5
+			j.SetRetry(2, 10*time.Millisecond, time.Time{})
+			errs := manager.Work(j).Promise().Await()
+			So(errs, ShouldHaveLength, 1)
+			So(j.runs, ShouldEqual, 3)
+			So(j.Retried(), ShouldEqual, 2)
+		})
+		Convey("does not retry a failed job without retries", func() {
+			manager := newWorkManager()
+			j := newMockJob()
+			j.failures = 1
+			errs := manager.Work(j).Promise().Await()
+			So(errs, ShouldHaveLength, 1)
+			So(j.runs, ShouldEqual, 1)
+		})
+		Convey("refuses to start with an empty worker pool", func() {
+			manager := newWorkManager(CollectWkrSizeOption(0))
+			So(manager.Start(), ShouldEqual, ErrInvalidPoolSize)
+			So(manager.state, ShouldEqual, workManagerStopped)
+		})
 		Convey("testing workMangerOptions", func() {
 			wMOption1 := CollectQSizeOption(100)
 			wMOption2 := PublishQSizeOption(100)
@@ -235,6 +337,254 @@ func TestWorkerManager(t *testing.T) {
 		})
 	})
 
+	Convey("Drain()", t, func() {
+		Convey("returns the jobs in flight when the context is done", func() {
+			mgr := newWorkManager()
+			j := newMultiSyncMockJob(1)
+			mgr.Work(j)
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			So(mgr.Drain(ctx), ShouldEqual, 1)
+			j.RendezVous()
+			j.Await()
+		})
+		Convey("waits for the jobs in flight to complete", func() {
+			mgr := newWorkManager()
+			j := newMultiSyncMockJob(1)
+			mgr.Work(j)
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				j.RendezVous()
+			}()
+			So(mgr.Drain(context.Background()), ShouldEqual, 0)
+			So(j.worked, ShouldBeTrue)
+		})
+		Convey("refuses collect jobs until resumed", func() {
+			mgr := newWorkManager()
+			So(mgr.Drain(context.Background()), ShouldEqual, 0)
+			j := newMockJob()
+			errs := mgr.Work(j).Promise().Await()
+			So(errs, ShouldNotBeEmpty)
+			So(errs[0], ShouldEqual, errWorkManagerDraining)
+			So(j.worked, ShouldBeFalse)
+			mgr.Resume()
+			errs = mgr.Work(j).Promise().Await()
+			So(errs, ShouldBeEmpty)
+			So(j.worked, ShouldBeTrue)
+		})
+	})
+	Convey("Shutdown()", t, func() {
+		mgr := newWorkManager(CollectWkrSizeOption(1))
+		So(mgr.Start(), ShouldBeNil)
+		Convey("cancels the jobs in flight when the context is done", func() {
+			running := newMultiSyncMockJob(2)
+			qjRunning := mgr.Work(running)
+			running.RendezVous() // running is now being worked
+			qjQueued := mgr.Work(newMockJob())
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			So(mgr.Shutdown(ctx), ShouldEqual, 2)
+			So(mgr.Stats().LiveWorkers, ShouldEqual, 0)
+			So(qjRunning.Promise().Await(), ShouldContain, errWorkManagerStopped)
+			So(qjQueued.Promise().Await(), ShouldContain, errWorkManagerStopped)
+			// the abandoned job is left to complete in the background
+			running.RendezVous()
+		})
+		Convey("refuses every job until started again", func() {
+			So(mgr.Shutdown(context.Background()), ShouldEqual, 0)
+			So(mgr.Stats().LiveWorkers, ShouldEqual, 0)
+			mgr.Resume()
+			j := newMockJob()
+			errs := mgr.Work(j).Promise().Await()
+			So(errs, ShouldResemble, []error{errWorkManagerStopped})
+			So(j.worked, ShouldBeFalse)
+			So(mgr.Start(), ShouldBeNil)
+			So(mgr.Stats().LiveWorkers, ShouldEqual, 3)
+			So(mgr.Work(j).Promise().Await(), ShouldBeEmpty)
+			So(j.worked, ShouldBeTrue)
+		})
+	})
+	Convey("SetPoolSize()", t, func() {
+		Convey("it grows the worker pool", func() {
+			mgr := newWorkManager()
+			mgr.SetPoolSize(collectJobType, 3)
+			So(mgr.collectWkrSize, ShouldEqual, 3)
+			So(len(mgr.collectWkrs), ShouldEqual, 3)
+
+			// two blocking jobs are worked at the same time
+			j1 := newMultiSyncMockJob(1)
+			j2 := newMultiSyncMockJob(1)
+			mgr.Work(j1)
+			mgr.Work(j2)
+			j1.RendezVous()
+			j2.RendezVous()
+			j1.Await()
+			j2.Await()
+			So(j1.worked, ShouldBeTrue)
+			So(j2.worked, ShouldBeTrue)
+		})
+		Convey("it shrinks the worker pools without dropping jobs", func() {
+			mgr := newWorkManager(CollectWkrSizeOption(3))
+			j1 := newMultiSyncMockJob(1)
+			qj1 := mgr.Work(j1)
+			j1.RendezVous()
+			mgr.SetPoolSize(collectJobType, 1)
+			So(mgr.collectWkrSize, ShouldEqual, 1)
+			So(len(mgr.collectWkrs), ShouldEqual, 1)
+			So(qj1.Promise().Await(), ShouldBeEmpty)
+			So(j1.worked, ShouldBeTrue)
+
+			j2 := newMockJob()
+			So(mgr.Work(j2).Promise().Await(), ShouldBeEmpty)
+			So(j2.worked, ShouldBeTrue)
+		})
+		Convey("it leaves the pools of the other job types as they are", func() {
+			mgr := newWorkManager(CollectWkrSizeOption(2), ProcessWkrSizeOption(3), PublishWkrSizeOption(4))
+			mgr.SetPoolSize(processJobType, 1)
+			So(mgr.processWkrSize, ShouldEqual, 1)
+			So(len(mgr.processWkrs), ShouldEqual, 1)
+			So(mgr.collectWkrSize, ShouldEqual, 2)
+			So(len(mgr.collectWkrs), ShouldEqual, 2)
+			So(mgr.publishWkrSize, ShouldEqual, 4)
+			So(len(mgr.publishWkrs), ShouldEqual, 4)
+			stats := mgr.Stats()
+			So(stats.CollectPoolSize, ShouldEqual, 2)
+			So(stats.ProcessPoolSize, ShouldEqual, 1)
+			So(stats.PublishPoolSize, ShouldEqual, 4)
+		})
+	})
+	Convey("Stats()", t, func() {
+		mgr := newWorkManager(CollectQSizeOption(3), CollectWkrSizeOption(2))
+		stats := mgr.Stats()
+		So(stats.PoolSize, ShouldEqual, 2)
+		So(stats.CollectPoolSize, ShouldEqual, 2)
+		So(stats.ProcessPoolSize, ShouldEqual, 1)
+		So(stats.PublishPoolSize, ShouldEqual, 1)
+		So(stats.QueueSize, ShouldEqual, 3)
+		So(stats.QueuedJobs, ShouldEqual, 0)
+		So(stats.ActiveWorkers, ShouldEqual, 0)
+		// two collect workers, one process and one publish worker
+		So(stats.LiveWorkers, ShouldEqual, 4)
+		Convey("counts the live workers as the pools are resized", func() {
+			mgr.SetPoolSize(collectJobType, 1)
+			So(mgr.Stats().LiveWorkers, ShouldBeLessThanOrEqualTo, 4)
+			// removed workers exit once they notice they are killed
+			time.Sleep(time.Millisecond * 10)
+			So(mgr.Stats().LiveWorkers, ShouldEqual, 3)
+		})
+		Convey("counts the active workers and the processed jobs", func() {
+			j := newMultiSyncMockJob(2)
+			qj := mgr.Work(j)
+			j.RendezVous() // j is now running
+			So(mgr.Stats().ActiveWorkers, ShouldEqual, 1)
+			j.RendezVous()
+			qj.Promise().Await()
+			So(j.worked, ShouldBeTrue)
+			stats := mgr.Stats()
+			So(stats.ActiveWorkers, ShouldEqual, 0)
+			So(stats.ProcessedJobs, ShouldEqual, 1)
+			So(stats.DroppedJobs, ShouldEqual, 0)
+		})
+		Convey("counts the dropped jobs", func() {
+			mgr.Drain(context.Background())
+			mgr.Work(newMockJob()).Promise().Await()
+			So(mgr.Stats().DroppedJobs, ShouldEqual, 1)
+		})
+	})
+	Convey("Dropped jobs", t, func() {
+		type drop struct {
+			queue string
+			err   error
+		}
+		dropped := make(chan drop, 2)
+		mgr := newWorkManager(CollectQSizeOption(1), CollectWkrSizeOption(1),
+			jobDroppedOption(func(queue string, j job, err error) { dropped <- drop{queue, err} }))
+		mgr.Start()
+		Convey("are reported when their queue is full", func() {
+			j1 := newMultiSyncMockJob(2)
+			qj1 := mgr.Work(j1)
+			j1.RendezVous() // j1 is now running
+			mgr.Work(newMockJob())
+			mgr.Work(newMockJob()).Promise().Await()
+			d := <-dropped
+			So(d.queue, ShouldEqual, "collect")
+			So(d.err, ShouldEqual, errLimitExceeded)
+			j1.RendezVous()
+			qj1.Promise().Await()
+		})
+		Convey("are reported when their deadline passed", func() {
+			j := newMockJob()
+			j.deadline = time.Now().Add(-time.Second)
+			mgr.Work(j).Promise().Await()
+			d := <-dropped
+			So(d.queue, ShouldEqual, "collect")
+			So(d.err, ShouldEqual, errJobOverdue)
+		})
+	})
+	Convey("Crashed workers", t, func() {
+		retired := make(chan string, 1)
+		mgr := newWorkManager(CollectWkrSizeOption(2), MaxWorkerRestartsOption(1),
+			workerRetiredOption(func(pool, workerID string) { retired <- pool }))
+		// the crash of a worker is handled once its job is completed
+		waitFor := func(done func(SchedulerStats) bool) SchedulerStats {
+			stats := mgr.Stats()
+			for i := 0; i < 100 && !done(stats); i++ {
+				time.Sleep(time.Millisecond * 5)
+				stats = mgr.Stats()
+			}
+			return stats
+		}
+		Convey("are replaced until the restarts are exceeded", func() {
+			So(mgr.Work(&panickingJob{newMockJob()}).Promise().Await(), ShouldNotBeEmpty)
+			stats := waitFor(func(s SchedulerStats) bool { return s.RestartedWorkers == 1 })
+			So(stats.RestartedWorkers, ShouldEqual, 1)
+			So(stats.LiveWorkers, ShouldEqual, 4)
+
+			So(mgr.Work(&panickingJob{newMockJob()}).Promise().Await(), ShouldNotBeEmpty)
+			So(<-retired, ShouldEqual, "collect")
+			stats = waitFor(func(s SchedulerStats) bool { return s.LiveWorkers == 3 })
+			So(stats.RetiredWorkers, ShouldEqual, 1)
+			So(stats.RestartedWorkers, ShouldEqual, 1)
+			So(stats.LiveWorkers, ShouldEqual, 3)
+			So(stats.PoolSize, ShouldEqual, 2)
+
+			// the remaining worker keeps working the jobs
+			j := newMockJob()
+			So(mgr.Work(j).Promise().Await(), ShouldBeEmpty)
+			So(j.worked, ShouldBeTrue)
+		})
+		Convey("are replaced again once the restart window has passed", func() {
+			WorkerRestartWindowOption(time.Millisecond * 20)(mgr)
+			So(mgr.Work(&panickingJob{newMockJob()}).Promise().Await(), ShouldNotBeEmpty)
+			waitFor(func(s SchedulerStats) bool { return s.RestartedWorkers == 1 })
+			time.Sleep(time.Millisecond * 30)
+			So(mgr.Work(&panickingJob{newMockJob()}).Promise().Await(), ShouldNotBeEmpty)
+			stats := waitFor(func(s SchedulerStats) bool { return s.RestartedWorkers == 2 })
+			So(stats.RestartedWorkers, ShouldEqual, 2)
+			So(stats.RetiredWorkers, ShouldEqual, 0)
+			So(stats.LiveWorkers, ShouldEqual, 4)
+		})
+	})
+	Convey("Timeout()", t, func() {
+		Convey("abandons a job exceeding its timeout and frees the worker", func() {
+			mgr := newWorkManager(CollectWkrSizeOption(1))
+			j1 := newMultiSyncMockJob(1)
+			j1.SetTimeout(50 * time.Millisecond)
+			errs := mgr.Work(j1).Promise().Await()
+			So(errs, ShouldHaveLength, 1)
+			So(errs[0].Error(), ShouldContainSubstring, "timeout")
+			So(j1.worked, ShouldBeFalse)
+
+			j2 := newMockJob()
+			So(mgr.Work(j2).Promise().Await(), ShouldBeEmpty)
+			So(j2.worked, ShouldBeTrue)
+
+			// the abandoned job completing late does not add to its errors
+			j1.RendezVous()
+			j1.Await()
+			So(j1.Errors(), ShouldHaveLength, 1)
+		})
+	})
 	Convey("Stop()", t, func() {
 		Convey("Stops the queue and the workers", func() {
 			mgr := newWorkManager()
@@ -21,17 +21,40 @@ package scheduler
 
 import (
 	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
 
+	log "github.com/Sirupsen/logrus"
 	"github.com/intelsdi-x/snap/pkg/chrono"
 	"github.com/pborman/uuid"
 )
 
-var workerKillChan = make(chan struct{})
+var (
+	workerKillChan = make(chan struct{})
+
+	errJobOverdue = errors.New("Worker refused to run overdue job.")
+
+	workerLogger = schedulerLogger.WithField("_module", "scheduler-worker")
+)
 
 type worker struct {
 	id       string
 	rcv      <-chan queuedJob
 	kamikaze chan struct{}
+	// stats of the work manager owning the worker, may be nil
+	stats *workStats
+	// retry queues a failed job again after the given delay, may be nil
+	retry func(queuedJob, time.Duration)
+	// crashed is called when the worker exits after a job panicked, a
+	// worker without it keeps working once the panic is recovered
+	crashed func(*worker)
+	// overdue is called when the worker drops a job whose deadline passed,
+	// may be nil
+	overdue func(job)
+	// cancel is closed to make the worker abandon the job it is running and
+	// exit, may be nil
+	cancel <-chan struct{}
 }
 
 func newWorker(rChan <-chan queuedJob) *worker {
@@ -44,15 +67,35 @@ func newWorker(rChan <-chan queuedJob) *worker {
 
 // begin a worker
 func (w *worker) start() {
+	defer w.stats.exited()
 	for {
 		select {
 		case q := <-w.rcv:
 			// assert that deadline is not exceeded
 			if chrono.Chrono.Now().Before(q.Job().Deadline()) {
-				q.Job().Run()
-			} else {
-				// the deadline was exceeded and this job will not run
-				q.Job().AddErrors(errors.New("Worker refused to run overdue job."))
+				if q.Job().Retried() > 0 {
+					q.Job().ResetErrors()
+				}
+				w.stats.started()
+				abandoned, panicked := w.run(q.Job())
+				w.stats.finished()
+				if !w.retried(q, abandoned) {
+					// mark the job complete
+					q.Promise().Complete(q.Job().Errors())
+				}
+				// the worker may be left broken by the panic, so it is
+				// replaced
+				if panicked && w.crashed != nil {
+					w.crashed(w)
+					return
+				}
+				continue
+			}
+			// the deadline was exceeded and this job will not run
+			q.Job().AddErrors(errJobOverdue)
+			w.stats.drop()
+			if w.overdue != nil {
+				w.overdue(q.Job())
 			}
 
 			// mark the job complete
@@ -65,6 +108,84 @@ func (w *worker) start() {
 		//the broadcast that kills all workers
 		case <-workerKillChan:
 			return
+
+		// the work manager is shut down
+		case <-w.cancel:
+			return
 		}
 	}
 }
+
+// retried queues the failed job again if it has retries left and returns
+// whether it did. An abandoned job may still be running, so it is never
+// retried.
+func (w *worker) retried(q queuedJob, abandoned bool) bool {
+	if abandoned || len(q.Job().Errors()) == 0 || w.retry == nil {
+		return false
+	}
+	delay, ok := q.Job().NextRetry()
+	if !ok {
+		return false
+	}
+	w.retry(q, delay)
+	return true
+}
+
+// run runs the job, giving up on it once its timeout (if any) is exceeded or
+// the worker is cancelled so that a hung job does not hold on to the worker.
+// The context of an abandoned job is cancelled, so that a collection stops
+// waiting on its plugins. An abandoned job otherwise keeps running in the
+// background but its outcome is ignored. It returns whether the job was abandoned and whether it panicked
+// before being abandoned.
+func (w *worker) run(j job) (abandoned, panicked bool) {
+	if j.Timeout() <= 0 && w.cancel == nil {
+		return false, w.runJob(j)
+	}
+	done := make(chan bool, 1)
+	go func() {
+		done <- w.runJob(j)
+	}()
+	var timeout <-chan time.Time
+	if j.Timeout() > 0 {
+		timer := time.NewTimer(j.Timeout())
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case panicked := <-done:
+		return false, panicked
+	case <-timeout:
+		j.AddErrors(fmt.Errorf("Worker abandoned %s job after exceeding timeout of %s.", j.TypeString(), j.Timeout()))
+		j.Cancel()
+		return true, false
+	case <-w.cancel:
+		j.AddErrors(errWorkManagerStopped)
+		j.Cancel()
+		return true, false
+	}
+}
+
+// runJob runs the job, recovering from a panic of the job so that it fails
+// the job, and in turn the firing of its task, instead of taking down the
+// worker. It returns whether the job panicked.
+func (w *worker) runJob(j job) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			// the stack is kept in the error, so that it is reported as
+			// the last error of the task
+			err := fmt.Errorf("Worker recovered from a panic of %s job: %v\n%s", j.TypeString(), r, debug.Stack())
+			workerLogger.WithFields(log.Fields{
+				"_block":    "run-job",
+				"worker-id": w.id,
+				"task-id":   j.TaskID(),
+				"job-type":  j.TypeString(),
+				"_error":    err.Error(),
+			}).Error("job panicked")
+			w.stats.panic()
+			j.AddErrors(err)
+			panicked = true
+		}
+	}()
+	j.Run()
+	return false
+}
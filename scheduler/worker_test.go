@@ -22,14 +22,39 @@ limitations under the License.
 package scheduler
 
 import (
+	"context"
 	"testing"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/intelsdi-x/snap/core"
 	"github.com/intelsdi-x/snap/pkg/chrono"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
+type panickingJob struct {
+	*mockJob
+}
+
+func (p *panickingJob) Run() {
+	panic("job failed")
+}
+
+// hungCollector collects until the context of the collection is cancelled
+type hungCollector struct {
+	cancelled chan struct{}
+}
+
+func (h *hungCollector) CollectMetrics(string, map[string]map[string]string) ([]core.Metric, []error) {
+	select {}
+}
+
+func (h *hungCollector) CollectMetricsContext(ctx context.Context, _ string, _ map[string]map[string]string, _ func(core.Namespace) bool) ([]core.Metric, []error) {
+	<-ctx.Done()
+	close(h.cancelled)
+	return nil, []error{ctx.Err()}
+}
+
 func TestWorker(t *testing.T) {
 	log.SetLevel(log.FatalLevel)
 	Convey("runs a job sent to the worker", t, func() {
@@ -63,6 +88,40 @@ func TestWorker(t *testing.T) {
 		So(errors, ShouldNotBeEmpty)
 		So(mj.worked, ShouldBeFalse)
 	})
+	Convey("fails a job which panics without stopping the worker", t, func() {
+		workerKillChan = make(chan struct{})
+		rcv := make(chan queuedJob)
+		w := newWorker(rcv)
+		go w.start()
+		qj := newQueuedJob(&panickingJob{newMockJob()})
+		rcv <- qj
+		errors := qj.Promise().Await()
+		So(errors, ShouldNotBeEmpty)
+		So(errors[0].Error(), ShouldContainSubstring, "panic")
+		mj := newMockJob()
+		rcv <- newQueuedJob(mj)
+		mj.Await()
+		So(mj.worked, ShouldBeTrue)
+	})
+	Convey("cancels the collection of a job exceeding its timeout", t, func() {
+		workerKillChan = make(chan struct{})
+		rcv := make(chan queuedJob)
+		w := newWorker(rcv)
+		go w.start()
+		hc := &hungCollector{cancelled: make(chan struct{})}
+		j := newCollectorJob(nil, time.Minute, hc, nil, "", nil)
+		j.SetTimeout(10 * time.Millisecond)
+		qj := newQueuedJob(j)
+		rcv <- qj
+		errors := qj.Promise().Await()
+		So(errors, ShouldNotBeEmpty)
+		So(errors[0].Error(), ShouldContainSubstring, "timeout")
+		select {
+		case <-hc.cancelled:
+		case <-time.After(time.Second):
+		}
+		So(j.Context().Err(), ShouldEqual, context.Canceled)
+	})
 	Convey("stops the worker if kamikaze chan is closed", t, func() {
 		workerKillChan = make(chan struct{})
 		rcv := make(chan queuedJob)
@@ -20,9 +20,12 @@ limitations under the License.
 package scheduler
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -31,6 +34,8 @@ import (
 	"github.com/intelsdi-x/snap/core"
 	"github.com/intelsdi-x/snap/core/cdata"
 	"github.com/intelsdi-x/snap/core/scheduler_event"
+	"github.com/intelsdi-x/snap/core/serror"
+	"github.com/intelsdi-x/snap/pkg/schedule"
 	"github.com/intelsdi-x/snap/scheduler/wmap"
 )
 
@@ -54,6 +59,24 @@ var (
 
 	ErrNullCollectNode        = errors.New("Missing collection node in workflow map")
 	ErrNoMetricsInCollectNode = errors.New("Collection node has not metrics defined to collect")
+	// ErrUnnamedWorkflowNode - The error message for a process or publish node without a plugin name
+	ErrUnnamedWorkflowNode = errors.New("Workflow node has no plugin name")
+	// ErrOrphanedProcessNode - The error message for a process node whose output is not consumed
+	ErrOrphanedProcessNode = errors.New("Process node has no process or publish node consuming its output")
+	// ErrInvalidRetryPolicy - The error message for a process or publish node with negative retries or an invalid retry duration
+	ErrInvalidRetryPolicy = errors.New("Workflow node retries must not be negative and its retry_backoff and retry_jitter must be durations")
+	// ErrConfigForUnknownMetric - The error message for collect config not matching any metric of the workflow
+	ErrConfigForUnknownMetric = errors.New("Collect config does not apply to any metric of the workflow")
+	// ErrInvalidMetricInterval - The error message for a metric collected once every negative number of firings
+	ErrInvalidMetricInterval = errors.New("Metric every must not be negative")
+	// ErrUnknownBuiltinStep - The error message for a process node whose builtin is neither filter, downsample nor rate
+	ErrUnknownBuiltinStep = errors.New("Process node builtin must be filter, downsample or rate")
+	// ErrInvalidBuiltinStep - The error message for a built-in step whose config is invalid
+	ErrInvalidBuiltinStep = errors.New("Process node builtin config is invalid, a filter takes a min or a max, a downsample takes a keep_every or an average of at least 1 and a rate takes a mode of rate or delta and a counter_wrap of reset, wrap with a counter_max, or none")
+	// ErrBuiltinStepWithPlugin - The error message for a built-in step also naming a plugin or a target
+	ErrBuiltinStepWithPlugin = errors.New("Process node builtin cannot have a plugin_name or a target")
+	// ErrInvalidPublishBatch - The error message for a publish node with a negative batch_size or an invalid batch_max_age
+	ErrInvalidPublishBatch = errors.New("Publish node batch_size must not be negative and its batch_max_age must be a duration")
 )
 
 // WmapToWorkflow attempts to convert a wmap.WorkflowMap to a schedulerWorkflow instance.
@@ -63,16 +86,233 @@ func wmapToWorkflow(wfMap *wmap.WorkflowMap) (*schedulerWorkflow, error) {
 	if err != nil {
 		return nil, err
 	}
-	// ***
-	// TODO validate workflow makes sense here
-	// - flows that don't end in publishers?
-	// - duplicate child nodes anywhere?
-	//***
 	// Retain a copy of the original workflow map
 	wf.workflowMap = wfMap
 	return wf, nil
 }
 
+// validateWorkflowMap checks that the workflow map is well formed, returning
+// an error naming the path of every offending node. As the nodes of a
+// workflow map can only be nested under their parent, the map is a tree and
+// cannot contain cycles.
+func validateWorkflowMap(wfMap *wmap.WorkflowMap) []serror.SnapError {
+	errs := []serror.SnapError{}
+	if wfMap == nil || wfMap.Collect == nil {
+		return append(errs, serror.New(ErrNullCollectNode))
+	}
+	for ns, m := range wfMap.Collect.Metrics {
+		if m.Every_ < 0 {
+			errs = append(errs, workflowNodeError(ErrInvalidMetricInterval, "collect.metrics["+ns+"]"))
+		}
+	}
+	mts := wfMap.Collect.GetMetrics()
+	for ns := range wfMap.Collect.Config {
+		if !configAppliesToMetrics(ns, mts) {
+			errs = append(errs, workflowNodeError(ErrConfigForUnknownMetric, "collect.config["+ns+"]"))
+		}
+	}
+	errs = append(errs, validateProcessNodes(wfMap.Collect.Process, "collect")...)
+	errs = append(errs, validatePublishNodes(wfMap.Collect.Publish, "collect")...)
+	return errs
+}
+
+func validateProcessNodes(nodes []wmap.ProcessWorkflowMapNode, parent string) []serror.SnapError {
+	errs := []serror.SnapError{}
+	for i, n := range nodes {
+		path := fmt.Sprintf("%s.process[%d]", parent, i)
+		if n.Builtin != "" {
+			if n.PluginName != "" || n.Target != "" {
+				errs = append(errs, workflowNodeError(ErrBuiltinStepWithPlugin, path))
+			}
+			if cdn, err := n.GetConfigNode(); err == nil {
+				if _, err := newBuiltinStep(n.Builtin, cdn); err != nil {
+					errs = append(errs, workflowNodeError(err, path))
+				}
+			}
+		} else if n.PluginName == "" {
+			errs = append(errs, workflowNodeError(ErrUnnamedWorkflowNode, path))
+		}
+		if len(n.Process) == 0 && len(n.Publish) == 0 {
+			errs = append(errs, workflowNodeError(ErrOrphanedProcessNode, path))
+		}
+		if _, err := newRetryPolicy(n.Retries, n.RetryBackoff, n.RetryJitter); err != nil {
+			errs = append(errs, workflowNodeError(ErrInvalidRetryPolicy, path))
+		}
+		errs = append(errs, validateProcessNodes(n.Process, path)...)
+		errs = append(errs, validatePublishNodes(n.Publish, path)...)
+	}
+	return errs
+}
+
+func validatePublishNodes(nodes []wmap.PublishWorkflowMapNode, parent string) []serror.SnapError {
+	errs := []serror.SnapError{}
+	for i, n := range nodes {
+		path := fmt.Sprintf("%s.publish[%d]", parent, i)
+		if n.PluginName == "" {
+			errs = append(errs, workflowNodeError(ErrUnnamedWorkflowNode, path))
+		}
+		if _, err := newRetryPolicy(n.Retries, n.RetryBackoff, n.RetryJitter); err != nil {
+			errs = append(errs, workflowNodeError(ErrInvalidRetryPolicy, path))
+		}
+		if _, err := newPublishBatch(n.BatchSize, n.BatchMaxAge); err != nil {
+			errs = append(errs, workflowNodeError(ErrInvalidPublishBatch, path))
+		}
+	}
+	return errs
+}
+
+func workflowNodeError(err error, path string) serror.SnapError {
+	return serror.New(fmt.Errorf("%v: %v", err, path), map[string]interface{}{
+		"workflow-node": path,
+	})
+}
+
+// configAppliesToMetrics returns true if the config namespace is the
+// namespace, or a prefix of the namespace, of one of the metrics.
+func configAppliesToMetrics(configNs string, mts []wmap.Metric) bool {
+	ns := []string{}
+	for _, e := range strings.Split(configNs, "/") {
+		if e != "" {
+			ns = append(ns, e)
+		}
+	}
+	for _, m := range mts {
+		if namespaceHasPrefix(m.Namespace(), ns) {
+			return true
+		}
+	}
+	return false
+}
+
+// dueMetrics returns the function telling whether a metric is due on the
+// next collection of the workflow, nil if every metric is due on each of
+// them. A metric is due when one of the requested metrics it falls under is.
+func (s *schedulerWorkflow) dueMetrics() func(core.Namespace) bool {
+	if s.intervals == nil {
+		return nil
+	}
+	n := atomic.AddUint64(&s.collections, 1) - 1
+	return func(ns core.Namespace) bool {
+		elems := ns.Strings()
+		requested := false
+		for _, mi := range s.intervals {
+			if !requestedNamespaceMatches(mi.namespace, elems) {
+				continue
+			}
+			if n%mi.every == 0 {
+				return true
+			}
+			requested = true
+		}
+		return !requested
+	}
+}
+
+// requestedNamespaceMatches returns true if the namespace is the requested
+// namespace, or falls under its trailing wildcard
+func requestedNamespaceMatches(requested, ns []string) bool {
+	if len(requested) == 0 || !namespaceHasPrefix(requested, ns) {
+		return false
+	}
+	if len(requested) == len(ns) {
+		return true
+	}
+	return requested[len(requested)-1] == "*" && len(ns) >= len(requested)
+}
+
+// matchingMetrics returns the metrics carrying the tags matched for each of
+// the namespaces they fall under, all of them when nothing is matched
+func matchingMetrics(mts []core.Metric, match map[string]map[string]string) []core.Metric {
+	if len(match) == 0 {
+		return mts
+	}
+	matching := make([]core.Metric, 0, len(mts))
+	for _, m := range mts {
+		if metricMatches(m, match) {
+			matching = append(matching, m)
+		}
+	}
+	return matching
+}
+
+// dedupMetrics drops the metrics collected more than once by a collection,
+// as when a wildcard and an explicit namespace of the workflow overlap, a
+// metric being the same as another when their namespace and timestamp are.
+// The metrics without a timestamp are all kept. It returns the metrics
+// kept, in their order, and the number of duplicates dropped.
+func dedupMetrics(mts []core.Metric) ([]core.Metric, int) {
+	type key struct {
+		ns string
+		at int64
+	}
+	seen := make(map[key]struct{}, len(mts))
+	kept := make([]core.Metric, 0, len(mts))
+	for _, m := range mts {
+		if m.Timestamp().IsZero() {
+			kept = append(kept, m)
+			continue
+		}
+		k := key{ns: m.Namespace().String(), at: m.Timestamp().UnixNano()}
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		kept = append(kept, m)
+	}
+	return kept, len(mts) - len(kept)
+}
+
+func metricMatches(m core.Metric, match map[string]map[string]string) bool {
+	for matchNs, tags := range match {
+		ns := []string{}
+		for _, e := range strings.Split(matchNs, "/") {
+			if e != "" {
+				ns = append(ns, e)
+			}
+		}
+		if !namespaceHasPrefix(m.Namespace().Strings(), ns) {
+			continue
+		}
+		mtags := m.Tags()
+		for k, v := range tags {
+			if mtags[k] != v {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func namespaceHasPrefix(metricNs, prefix []string) bool {
+	for i, e := range prefix {
+		if i >= len(metricNs) {
+			return false
+		}
+		switch me := metricNs[i]; {
+		case me == "*" && i == len(metricNs)-1:
+			// a trailing wildcard matches any namespace below it
+			return true
+		case me == "*" || me == e:
+		case strings.HasPrefix(me, core.TuplePrefix) && strings.HasSuffix(me, core.TupleSuffix):
+			// a tuple matches any of its items
+			found := false
+			tuple := strings.TrimSuffix(strings.TrimPrefix(me, core.TuplePrefix), core.TupleSuffix)
+			for _, item := range strings.Split(tuple, core.TupleSeparator) {
+				if strings.TrimSpace(item) == e {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 func convertCollectionNode(cnode *wmap.CollectWorkflowMapNode, wf *schedulerWorkflow) error {
 	// Collection root
 	// Validate collection node exists
@@ -86,11 +326,27 @@ func convertCollectionNode(cnode *wmap.CollectWorkflowMapNode, wf *schedulerWork
 	// Get core.RequestedMetric metrics
 	mts := cnode.GetMetrics()
 	wf.metrics = make([]core.RequestedMetric, len(mts))
+	intervals := make([]metricInterval, len(mts))
+	collectedLess := false
 	for i, m := range mts {
 		wf.metrics[i] = &metric{namespace: core.NewNamespace(m.Namespace()...), version: m.Version()}
+		if m.Every() < 0 {
+			return ErrInvalidMetricInterval
+		}
+		intervals[i] = metricInterval{namespace: m.Namespace(), every: 1}
+		if m.Every() > 1 {
+			intervals[i].every = uint64(m.Every())
+			collectedLess = true
+		}
+	}
+	// the metrics are all collected on every firing unless one is not
+	if collectedLess {
+		wf.intervals = intervals
 	}
 	// get tags defined
 	wf.tags = cnode.GetTags()
+	// get tags the collected metrics must carry
+	wf.match = cnode.GetMatch()
 
 	// Get our config data tree
 	cdt, err := cnode.GetConfigTree()
@@ -120,6 +376,10 @@ func convertProcessNode(pr []wmap.ProcessWorkflowMapNode) ([]*processNode, error
 		if err != nil {
 			return nil, err
 		}
+		retry, err := newRetryPolicy(p.Retries, p.RetryBackoff, p.RetryJitter)
+		if err != nil {
+			return nil, err
+		}
 		prC, err := convertProcessNode(p.Process)
 		if err != nil {
 			return nil, err
@@ -128,6 +388,13 @@ func convertProcessNode(pr []wmap.ProcessWorkflowMapNode) ([]*processNode, error
 		if err != nil {
 			return nil, err
 		}
+		var builtin *builtinStep
+		if p.Builtin != "" {
+			if builtin, err = newBuiltinStep(p.Builtin, cdn); err != nil {
+				return nil, err
+			}
+			p.PluginName = p.Builtin
+		}
 
 		// If version is not 1+ we use -1 to indicate we want
 		// the plugin manager to select the highest version
@@ -143,6 +410,8 @@ func convertProcessNode(pr []wmap.ProcessWorkflowMapNode) ([]*processNode, error
 			Target:       p.Target,
 			ProcessNodes: prC,
 			PublishNodes: puC,
+			retry:        retry,
+			builtin:      builtin,
 		}
 	}
 	return prNodes, nil
@@ -156,6 +425,14 @@ func convertPublishNode(pu []wmap.PublishWorkflowMapNode) ([]*publishNode, error
 		if err != nil {
 			return nil, err
 		}
+		retry, err := newRetryPolicy(p.Retries, p.RetryBackoff, p.RetryJitter)
+		if err != nil {
+			return nil, err
+		}
+		batch, err := newPublishBatch(p.BatchSize, p.BatchMaxAge)
+		if err != nil {
+			return nil, err
+		}
 		// If version is not 1+ we use -1 to indicate we want
 		// the plugin manager to select the highest version
 		// available on plugin calls
@@ -168,11 +445,46 @@ func convertPublishNode(pu []wmap.PublishWorkflowMapNode) ([]*publishNode, error
 			version: p.PluginVersion,
 			config:  cdn,
 			Target:  p.Target,
+			retry:   retry,
+			batch:   batch,
 		}
 	}
 	return puNodes, nil
 }
 
+// retryPolicy is how many times a failed job is retried and the backoff
+// before the first retry, which doubles for each following retry, along with
+// the maximum random delay added to each backoff
+type retryPolicy struct {
+	retries int
+	backoff time.Duration
+	jitter  time.Duration
+}
+
+// newRetryPolicy returns the retry policy of a workflow node, nil if the
+// node does not retry its failed jobs on its own
+func newRetryPolicy(retries int, backoff, jitter string) (*retryPolicy, error) {
+	if retries < 0 {
+		return nil, ErrInvalidRetryPolicy
+	}
+	if retries == 0 {
+		return nil, nil
+	}
+	p := &retryPolicy{retries: retries}
+	var err error
+	if backoff != "" {
+		if p.backoff, err = time.ParseDuration(backoff); err != nil || p.backoff < 0 {
+			return nil, ErrInvalidRetryPolicy
+		}
+	}
+	if jitter != "" {
+		if p.jitter, err = time.ParseDuration(jitter); err != nil || p.jitter < 0 {
+			return nil, ErrInvalidRetryPolicy
+		}
+	}
+	return p, nil
+}
+
 type schedulerWorkflow struct {
 	state WorkflowState
 	// Metrics to collect
@@ -185,6 +497,19 @@ type schedulerWorkflow struct {
 	workflowMap  *wmap.WorkflowMap
 	eventEmitter gomit.Emitter
 	tags         map[string]map[string]string
+	match        map[string]map[string]string
+	// intervals holds the number of firings each requested metric is
+	// collected once every, nil if they are all collected on every firing,
+	// and collections counts the collections of the workflow
+	intervals   []metricInterval
+	collections uint64
+}
+
+// metricInterval is the number of firings a requested metric is collected
+// once every
+type metricInterval struct {
+	namespace []string
+	every     uint64
 }
 
 type processNode struct {
@@ -195,6 +520,12 @@ type processNode struct {
 	ProcessNodes       []*processNode
 	PublishNodes       []*publishNode
 	InboundContentType string
+	// retry is the retry policy of the jobs of the node, the policy of the
+	// task applies if nil
+	retry *retryPolicy
+	// builtin is the step run by the scheduler in place of a processor
+	// plugin, nil for the nodes of a plugin
+	builtin *builtinStep
 }
 
 func (p *processNode) Name() string {
@@ -219,6 +550,12 @@ type publishNode struct {
 	config             *cdata.ConfigDataNode
 	Target             string
 	InboundContentType string
+	// retry is the retry policy of the jobs of the node, the policy of the
+	// task applies if nil
+	retry *retryPolicy
+	// batch buffers the metrics handed to the node before they are
+	// published, nil if the metrics of every firing are published
+	batch *publishBatch
 }
 
 func (p *publishNode) Name() string {
@@ -239,37 +576,194 @@ func (p *publishNode) TypeName() string {
 
 type wfContentTypes map[string]map[string][]string
 
-// Start starts a workflow
+// Start starts a workflow. The metrics collected for a task with a collect
+// window are accumulated, and only processed and published once the window
+// closes, and a backfill task collects the current window of its range.
+// The firing is traced by a span, the parent of the spans of its jobs.
 func (s *schedulerWorkflow) Start(t *task) {
+	ctx, span := t.startSpan(context.Background(), SpanFire, nil)
+	defer span.End()
+	run := &runRecord{}
+	defer t.recordRun(now(), run)
+	ctx = withRunRecord(ctx, run)
+	recordFailure := traceFailures(span, run.failures(t.RecordFailure))
+	if _, backfill := t.Schedule().(*schedule.BackfillSchedule); backfill || t.collectWindow <= 0 {
+		s.start(ctx, t, recordFailure)
+		return
+	}
+	j, event := s.collect(ctx, t, recordFailure)
+	if event != nil {
+		defer s.eventEmitter.Emit(event)
+	}
+	if j == nil {
+		return
+	}
+	if mts, closed := t.window.add(now(), t.collectWindow, j.metrics); closed {
+		s.workWindow(ctx, t, mts, recordFailure)
+	}
+}
+
+// start runs the workflow once for the task, for the current window of the
+// range of a backfill task, and passes the errors of each failed job to
+// recordFailure. The spans of the jobs are children of the span carried by
+// ctx.
+func (s *schedulerWorkflow) start(ctx context.Context, t *task, recordFailure func([]error)) {
+	if bs, ok := t.Schedule().(*schedule.BackfillSchedule); ok {
+		s.startBackfill(ctx, t, bs, recordFailure)
+		return
+	}
+	j, event := s.collect(ctx, t, recordFailure)
+	if event != nil {
+		defer s.eventEmitter.Emit(event)
+	}
+	if j == nil {
+		return
+	}
+	// walk through the tree and dispatch work
+	workJobs(ctx, s.processNodes, s.publishNodes, t, j, recordFailure)
+}
+
+// collect runs the collect job of the workflow for the task. It returns the
+// job, or nil when it failed, along with the event to emit once the metrics
+// collected have been worked. Neither is returned when the collection is
+// skipped as a circuit breaker of its metrics is open, or as the tenant of
+// the task is firing over its rate.
+func (s *schedulerWorkflow) collect(ctx context.Context, t *task, recordFailure func([]error)) (*collectorJob, gomit.EventBody) {
 	workflowLogger.WithFields(log.Fields{
 		"_block":    "workflow-start",
 		"task-id":   t.id,
 		"task-name": t.name,
 	}).Debug("Starting workflow")
+	if t.tenants != nil && !t.tenants.allowFire(t.tenant) {
+		workflowLogger.WithFields(log.Fields{
+			"_block":    "workflow-start",
+			"task-id":   t.id,
+			"task-name": t.name,
+			"tenant":    t.tenant,
+		}).Warn("Skipping the collection as the tenant of the task is firing over its rate")
+		return nil, nil
+	}
+	// the slots of the throttle are taken before the breakers are asked, so
+	// that a probe let through by a half-open circuit always runs
+	if t.throttle != nil {
+		throttled := t.throttle.prefixes(s.metrics)
+		if !t.throttle.acquire(throttled, t.deadlineDuration) {
+			workflowLogger.WithFields(log.Fields{
+				"_block":    "workflow-start",
+				"task-id":   t.id,
+				"task-name": t.name,
+			}).Warn("Skipping the collection as too many collections of its metrics are running")
+			return nil, nil
+		}
+		defer t.throttle.release(throttled)
+	}
+	var prefixes []string
+	if t.breakers != nil {
+		prefixes = t.breakers.prefixes(s.metrics)
+		if !t.breakers.allow(t.id, prefixes) {
+			workflowLogger.WithFields(log.Fields{
+				"_block":    "workflow-start",
+				"task-id":   t.id,
+				"task-name": t.name,
+			}).Debug("Skipping the collection as a circuit breaker of its metrics is open")
+			return nil, nil
+		}
+	}
 	s.state = WorkflowStarted
 	j := newCollectorJob(s.metrics, t.deadlineDuration, t.metricsManager, t.workflow.configTree, t.id, s.tags)
+	j.SetTimeout(t.timeout)
+	j.(*collectorJob).due = s.dueMetrics()
+	ctx, span := t.startSpan(ctx, SpanCollect, nil)
+	withSpanContext(j, ctx)
 
 	// dispatch 'collect' job to be worked
 	// Block until the job has been either run or skipped.
-	errors := t.manager.Work(j).Promise().Await()
+	start := now()
+	errors := t.work(j, nil)
+	duration := now().Sub(start)
+	endSpan(span, errors)
+	if t.breakers != nil {
+		t.breakers.record(t.id, prefixes, len(errors) > 0)
+	}
 
 	if len(errors) > 0 {
-		t.RecordFailure(errors)
+		recordFailure(errors)
 		event := new(scheduler_event.MetricCollectionFailedEvent)
 		event.TaskID = t.id
 		event.Errors = errors
-		defer s.eventEmitter.Emit(event)
-		return
+		event.Start = start
+		event.Duration = duration
+		return nil, event
 	}
 
-	// Send event
+	cj := j.(*collectorJob)
+	cj.metrics = matchingMetrics(cj.metrics, s.match)
+	var duplicates int
+	if cj.metrics, duplicates = dedupMetrics(cj.metrics); duplicates > 0 {
+		atomic.AddUint64(&t.duplicateMetrics, uint64(duplicates))
+		workflowLogger.WithFields(log.Fields{
+			"_block":     "workflow-start",
+			"task-id":    t.id,
+			"task-name":  t.name,
+			"duplicates": duplicates,
+		}).Debug("Dropped the metrics collected more than once")
+	}
+	if t.passMetrics {
+		cj.metrics = append(cj.metrics, t.takeUpstreamMetrics()...)
+	}
+	recordCollected(ctx, len(cj.metrics))
 	event := new(scheduler_event.MetricCollectedEvent)
 	event.TaskID = t.id
-	event.Metrics = j.(*collectorJob).metrics
-	defer s.eventEmitter.Emit(event)
+	event.Metrics = cj.metrics
+	event.Start = start
+	event.Duration = duration
+	return cj, event
+}
 
-	// walk through the tree and dispatch work
-	workJobs(s.processNodes, s.publishNodes, t, j)
+// workWindow processes and publishes the metrics accumulated over a collect
+// window of the task
+func (s *schedulerWorkflow) workWindow(ctx context.Context, t *task, metrics []core.Metric, recordFailure func([]error)) {
+	workflowLogger.WithFields(log.Fields{
+		"_block":        "work-window",
+		"task-id":       t.id,
+		"task-name":     t.name,
+		"metrics-count": len(metrics),
+	}).Debug("Working the metrics of the collect window")
+	workJobs(ctx, s.processNodes, s.publishNodes, t, newCollectedJob(t, metrics), recordFailure)
+}
+
+// publishBatches returns the publish nodes of the workflow which batch their
+// metrics
+func (s *schedulerWorkflow) publishBatches() []*publishNode {
+	var batched []*publishNode
+	var walk func(prs []*processNode, pus []*publishNode)
+	walk = func(prs []*processNode, pus []*publishNode) {
+		for _, pu := range pus {
+			if pu.batch != nil {
+				batched = append(batched, pu)
+			}
+		}
+		for _, pr := range prs {
+			walk(pr.ProcessNodes, pr.PublishNodes)
+		}
+	}
+	walk(s.processNodes, s.publishNodes)
+	return batched
+}
+
+// flushPublishBatches publishes the metrics buffered by the publish nodes of
+// the workflow whose batch is not full yet
+func (s *schedulerWorkflow) flushPublishBatches(t *task) {
+	wg := &sync.WaitGroup{}
+	for _, pu := range s.publishBatches() {
+		mts, ok := pu.batch.flush()
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go publishMetrics(context.Background(), newCollectedJob(t, mts), t, wg, pu, t.RecordFailure)
+	}
+	wg.Wait()
 }
 
 func (s *schedulerWorkflow) State() WorkflowState {
@@ -281,7 +775,21 @@ func (s *schedulerWorkflow) StateString() string {
 }
 
 func (s *schedulerWorkflow) StreamStart(t *task, metrics []core.Metric) {
-	j := &collectorJob{
+	ctx, span := t.startSpan(context.Background(), SpanFire, nil)
+	defer span.End()
+	j := newCollectedJob(t, matchingMetrics(metrics, s.match))
+	// Send event
+	event := new(scheduler_event.MetricCollectedEvent)
+	event.TaskID = t.id
+	event.Metrics = j.metrics
+	defer s.eventEmitter.Emit(event)
+	workJobs(ctx, s.processNodes, s.publishNodes, t, j, traceFailures(span, t.RecordFailure))
+}
+
+// newCollectedJob returns a collect job holding metrics already collected
+// for the task, for their process and publish jobs to be submitted
+func newCollectedJob(t *task, metrics []core.Metric) *collectorJob {
+	return &collectorJob{
 		collector:      t.metricsManager,
 		metricTypes:    []core.RequestedMetric{},
 		metrics:        metrics,
@@ -289,17 +797,15 @@ func (s *schedulerWorkflow) StreamStart(t *task, metrics []core.Metric) {
 		configDataTree: t.workflow.configTree,
 		tags:           t.workflow.tags,
 	}
-	// Send event
-	event := new(scheduler_event.MetricCollectedEvent)
-	event.TaskID = t.id
-	event.Metrics = j.metrics
-	defer s.eventEmitter.Emit(event)
-	workJobs(s.processNodes, s.publishNodes, t, j)
 }
 
 // workJobs takes a slice of process and publish nodes and submits jobs for each for a task.
-// It then iterates down any process nodes to submit their child node jobs for the task
-func workJobs(prs []*processNode, pus []*publishNode, t *task, pj job) {
+// It then iterates down any process nodes to submit their child node jobs for the task.
+// Sibling nodes are submitted concurrently and workJobs returns once all of their
+// branches are done. A failed node passes its errors to recordFailure and ends its
+// own branch only, leaving its siblings to complete. The spans of the jobs are
+// children of the span carried by ctx.
+func workJobs(ctx context.Context, prs []*processNode, pus []*publishNode, t *task, pj job, recordFailure func([]error)) {
 	// optimize for no jobs
 	if len(prs) == 0 && len(pus) == 0 {
 		return
@@ -319,14 +825,14 @@ func workJobs(prs []*processNode, pus []*publishNode, t *task, pj job) {
 		// increment the wait group (before starting goroutine to prevent a race condition)
 		wg.Add(1)
 		// Start goroutine to submit the process job
-		go submitProcessJob(pj, t, wg, pr)
+		go submitProcessJob(ctx, pj, t, wg, pr, recordFailure)
 	}
 	// range over the publish jobs and call submitPublishJob
 	for _, pu := range pus {
 		// increment the wait group (before starting goroutine to prevent a race condition)
 		wg.Add(1)
 		// Start goroutine to submit the process job
-		go submitPublishJob(pj, t, wg, pu)
+		go submitPublishJob(ctx, pj, t, wg, pu, recordFailure)
 	}
 	// Wait until all job submisson goroutines are done
 	wg.Wait()
@@ -340,13 +846,19 @@ func workJobs(prs []*processNode, pus []*publishNode, t *task, pj job) {
 	}).Debug("Batch submission complete")
 }
 
-func submitProcessJob(pj job, t *task, wg *sync.WaitGroup, pr *processNode) {
+func submitProcessJob(ctx context.Context, pj job, t *task, wg *sync.WaitGroup, pr *processNode, recordFailure func([]error)) {
 	// Decrement the waitgroup
 	defer wg.Done()
 	// Create a new process job
-	mgr, err := t.RemoteManagers.Get(pr.Target)
+	var mgr processesMetrics
+	var err error
+	if pr.builtin != nil {
+		mgr = pr.builtin
+	} else {
+		mgr, err = t.RemoteManagers.Get(pr.Target)
+	}
 	if err != nil {
-		t.RecordFailure([]error{err})
+		recordFailure([]error{err})
 		workflowLogger.WithFields(log.Fields{
 			"_block":           "submit-prblish-job",
 			"task-id":          t.id,
@@ -358,6 +870,9 @@ func submitProcessJob(pj job, t *task, wg *sync.WaitGroup, pr *processNode) {
 		return
 	}
 	j := newProcessJob(pj, pr.Name(), pr.Version(), pr.InboundContentType, pr.config.Table(), mgr, t.id)
+	j.SetTimeout(t.timeout)
+	ctx, span := t.startPluginSpan(ctx, SpanProcess, pr.Name(), pr.Version())
+	withSpanContext(j, ctx)
 	workflowLogger.WithFields(log.Fields{
 		"_block":           "submit-process-job",
 		"task-id":          t.id,
@@ -367,12 +882,13 @@ func submitProcessJob(pj job, t *task, wg *sync.WaitGroup, pr *processNode) {
 		"parent-node-type": pj.TypeString(),
 	}).Debug("Submitting process job")
 	// Submit the job against the task.managesWork
-	errors := t.manager.Work(j).Promise().Await()
+	errors := t.work(j, pr.retry)
+	endSpan(span, errors)
 	// Check for errors and update the task
 	if len(errors) != 0 {
-		// Record the failures in the task
-		// note: this function is thread safe against t
-		t.RecordFailure(errors)
+		// Record the failures of the firing
+		// note: this function must be thread safe
+		recordFailure(errors)
 		workflowLogger.WithFields(log.Fields{
 			"_block":           "submit-process-job",
 			"task-id":          t.id,
@@ -392,16 +908,32 @@ func submitProcessJob(pj job, t *task, wg *sync.WaitGroup, pr *processNode) {
 		"parent-node-type": pj.TypeString(),
 	}).Debug("Process job completed")
 	// Iterate into any child process or publish nodes
-	workJobs(pr.ProcessNodes, pr.PublishNodes, t, j)
+	workJobs(ctx, pr.ProcessNodes, pr.PublishNodes, t, j, recordFailure)
 }
 
-func submitPublishJob(pj job, t *task, wg *sync.WaitGroup, pu *publishNode) {
+// submitPublishJob publishes the metrics of the parent job, or adds them to
+// the batch of the node until the batch is full
+func submitPublishJob(ctx context.Context, pj job, t *task, wg *sync.WaitGroup, pu *publishNode, recordFailure func([]error)) {
+	if pu.batch != nil {
+		mts, full := pu.batch.add(now(), pj.Metrics())
+		if !full {
+			wg.Done()
+			return
+		}
+		pj = newCollectedJob(t, mts)
+	}
+	publishMetrics(ctx, pj, t, wg, pu, recordFailure)
+}
+
+// publishMetrics submits the publish job of the node for the metrics of the
+// parent job
+func publishMetrics(ctx context.Context, pj job, t *task, wg *sync.WaitGroup, pu *publishNode, recordFailure func([]error)) {
 	// Decrement the waitgroup
 	defer wg.Done()
 	// Create a new process job
 	mgr, err := t.RemoteManagers.Get(pu.Target)
 	if err != nil {
-		t.RecordFailure([]error{err})
+		recordFailure([]error{err})
 		workflowLogger.WithFields(log.Fields{
 			"_block":           "submit-publish-job",
 			"task-id":          t.id,
@@ -413,6 +945,9 @@ func submitPublishJob(pj job, t *task, wg *sync.WaitGroup, pu *publishNode) {
 		return
 	}
 	j := newPublishJob(pj, pu.Name(), pu.Version(), pu.InboundContentType, pu.config.Table(), mgr, t.id)
+	j.SetTimeout(t.timeout)
+	ctx, span := t.startPluginSpan(ctx, SpanPublish, pu.Name(), pu.Version())
+	withSpanContext(j, ctx)
 	workflowLogger.WithFields(log.Fields{
 		"_block":           "submit-publish-job",
 		"task-id":          t.id,
@@ -422,12 +957,16 @@ func submitPublishJob(pj job, t *task, wg *sync.WaitGroup, pu *publishNode) {
 		"parent-node-type": pj.TypeString(),
 	}).Debug("Submitting publish job")
 	// Submit the job against the task.managesWork
-	errors := t.manager.Work(j).Promise().Await()
+	errors := t.work(j, pu.retry)
+	endSpan(span, errors)
 	// Check for errors and update the task
 	if len(errors) != 0 {
-		// Record the failures in the task
-		// note: this function is thread safe against t
-		t.RecordFailure(errors)
+		// Record the failures of the firing
+		// note: this function must be thread safe
+		recordFailure(errors)
+		if t.deadLetter != nil {
+			t.deadLetter(t, pu, pj.Metrics(), errors)
+		}
 		workflowLogger.WithFields(log.Fields{
 			"_block":           "submit-publish-job",
 			"task-id":          t.id,
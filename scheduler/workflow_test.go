@@ -22,6 +22,7 @@ limitations under the License.
 package scheduler
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
@@ -30,6 +31,7 @@ import (
 
 	"github.com/intelsdi-x/gomit"
 	"github.com/intelsdi-x/snap/control"
+	"github.com/intelsdi-x/snap/control/plugin"
 	"github.com/intelsdi-x/snap/core"
 	"github.com/intelsdi-x/snap/core/cdata"
 	"github.com/intelsdi-x/snap/core/control_event"
@@ -349,8 +351,9 @@ func TestWorkJobs(t *testing.T) {
 				prs = append(prs, pr)
 				pus = append(pus, pu)
 			}
-			workJobs(prs, pus, t, pj)
+			workJobs(context.Background(), prs, pus, t, pj, t.RecordFailure)
 			So(t.failedRuns, ShouldEqual, 0)
+			So(t.LastError(), ShouldBeNil)
 			So(m1.queue["processor"], ShouldEqual, 3)
 			So(m1.queue["publisher"], ShouldEqual, 3)
 		})
@@ -385,7 +388,7 @@ func TestWorkJobs(t *testing.T) {
 				pr.ProcessNodes = cprs
 				pr.PublishNodes = cpus
 			}
-			workJobs(prs, pus, t, pj)
+			workJobs(context.Background(), prs, pus, t, pj, t.RecordFailure)
 			So(t.failedRuns, ShouldEqual, 0)
 			// (3*3)+3
 			So(m2.queue["processor"], ShouldEqual, 12)
@@ -426,14 +429,394 @@ func TestWorkJobs(t *testing.T) {
 				pr.ProcessNodes = cprs
 				pr.PublishNodes = cpus
 			}
-			workJobs(prs, pus, t, pj)
+			workJobs(context.Background(), prs, pus, t, pj, t.RecordFailure)
 			So(t.failedRuns, ShouldEqual, 1)
 			So(t.lastFailureMessage, ShouldEqual, "I am an error")
+			So(t.LastError(), ShouldNotBeNil)
+			So(t.LastError().Error(), ShouldEqual, "I am an error")
 			// (3*3)+3
 			So(m3.queue["processor"], ShouldEqual, 12)
 			// (3*3)
 			So(m3.queue["publisher"], ShouldEqual, 12)
 		})
+		Convey("run sibling branches concurrently", func() {
+			bp := newBarrierPublisher(2)
+			pj := newCollectorJob(nil, time.Second*1, bp, nil, "", nil)
+			t := &task{
+				manager:        newWorkManager(PublishWkrSizeOption(2)),
+				id:             "1",
+				name:           "mock",
+				RemoteManagers: newManagers(bp),
+			}
+			pus := []*publishNode{
+				{config: cdata.NewNode(), name: "pujob0"},
+				{config: cdata.NewNode(), name: "pujob1"},
+			}
+			Convey("and wait for all of them to complete", func() {
+				workJobs(context.Background(), nil, pus, t, pj, t.RecordFailure)
+				So(t.failedRuns, ShouldEqual, 0)
+				So(bp.published, ShouldResemble, map[string]bool{"pujob0": true, "pujob1": true})
+			})
+			Convey("and complete the other branches when one fails", func() {
+				bp.fail = "pujob0"
+				workJobs(context.Background(), nil, pus, t, pj, t.RecordFailure)
+				So(t.failedRuns, ShouldEqual, 1)
+				So(t.lastFailureMessage, ShouldEqual, "pujob0 failed")
+				So(bp.published, ShouldResemble, map[string]bool{"pujob1": true})
+			})
+		})
+	})
+}
 
+func TestCollectWindow(t *testing.T) {
+	log.SetLevel(log.FatalLevel)
+	Convey("A task with a collect window", t, func() {
+		c := schedule.NewManualClock(time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC))
+		schedule.SetClock(c)
+		defer schedule.SetClock(nil)
+		wp := &windowPublisher{mockMetricManager: &mockMetricManager{}}
+		wf := &schedulerWorkflow{
+			publishNodes: []*publishNode{{config: cdata.NewNode(), name: "pujob"}},
+			eventEmitter: gomit.NewEventController(),
+		}
+		tsk := &task{
+			manager:          newWorkManager(),
+			id:               "1",
+			name:             "mock",
+			workflow:         wf,
+			metricsManager:   wp,
+			RemoteManagers:   newManagers(wp),
+			deadlineDuration: DefaultDeadlineDuration,
+			collectWindow:    time.Minute,
+		}
+		Convey("publishes the metrics collected once the window closes", func() {
+			for i := 0; i < 3; i++ {
+				wf.Start(tsk)
+				c.Advance(20 * time.Second)
+			}
+			So(wp.batches(), ShouldBeEmpty)
+			wf.Start(tsk)
+			So(wp.batches(), ShouldResemble, []int{3})
+			Convey("and flushes the partial window", func() {
+				tsk.flushBuffers()
+				So(wp.batches(), ShouldResemble, []int{3, 1})
+				tsk.flushBuffers()
+				So(wp.batches(), ShouldResemble, []int{3, 1})
+			})
+		})
+		Convey("publishes every firing without a window", func() {
+			tsk.collectWindow = 0
+			wf.Start(tsk)
+			wf.Start(tsk)
+			So(wp.batches(), ShouldResemble, []int{1, 1})
+		})
+	})
+}
+
+func TestPublishBatch(t *testing.T) {
+	log.SetLevel(log.FatalLevel)
+	Convey("A task publishing in batches", t, func() {
+		c := schedule.NewManualClock(time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC))
+		schedule.SetClock(c)
+		defer schedule.SetClock(nil)
+		wp := &windowPublisher{mockMetricManager: &mockMetricManager{}}
+		batch, err := newPublishBatch(3, "1m")
+		So(err, ShouldBeNil)
+		pr := &processNode{
+			config:       cdata.NewNode(),
+			name:         "prjob",
+			PublishNodes: []*publishNode{{config: cdata.NewNode(), name: "pujob", batch: batch}},
+		}
+		wf := &schedulerWorkflow{
+			publishNodes: []*publishNode{{config: cdata.NewNode(), name: "pujob"}},
+			eventEmitter: gomit.NewEventController(),
+		}
+		tsk := &task{
+			manager:          newWorkManager(),
+			id:               "1",
+			name:             "mock",
+			workflow:         wf,
+			metricsManager:   wp,
+			RemoteManagers:   newManagers(wp),
+			deadlineDuration: DefaultDeadlineDuration,
+			latencies: map[jobType]*durationSummary{
+				collectJobType: newDurationSummary(),
+				processJobType: newDurationSummary(),
+				publishJobType: newDurationSummary(),
+			},
+		}
+		Convey("publishes the batch once it is full", func() {
+			wf.publishNodes[0].batch = batch
+			for i := 0; i < 2; i++ {
+				wf.Start(tsk)
+			}
+			So(wp.batches(), ShouldBeEmpty)
+			So(tsk.Stats().BufferedMetrics, ShouldEqual, 2)
+			wf.Start(tsk)
+			So(wp.batches(), ShouldResemble, []int{3})
+			stats := tsk.Stats()
+			So(stats.BufferedMetrics, ShouldEqual, 0)
+			So(stats.FlushedBatches, ShouldEqual, 1)
+		})
+		Convey("publishes the batch once its oldest metrics are too old", func() {
+			wf.publishNodes[0].batch = batch
+			wf.Start(tsk)
+			c.Advance(time.Minute)
+			wf.Start(tsk)
+			So(wp.batches(), ShouldResemble, []int{2})
+		})
+		Convey("flushes the batches of nested publish nodes", func() {
+			wf.processNodes = []*processNode{pr}
+			wf.Start(tsk)
+			So(wp.batches(), ShouldResemble, []int{1})
+			So(tsk.Stats().BufferedMetrics, ShouldEqual, 1)
+			tsk.flushBuffers()
+			So(wp.batches(), ShouldResemble, []int{1, 1})
+			So(tsk.Stats().BufferedMetrics, ShouldEqual, 0)
+			tsk.flushBuffers()
+			So(wp.batches(), ShouldResemble, []int{1, 1})
+		})
+	})
+}
+
+// windowPublisher collects a metric on each collection and records the
+// number of metrics of each publishing
+type windowPublisher struct {
+	*mockMetricManager
+	sync.Mutex
+	published []int
+}
+
+func (w *windowPublisher) CollectMetrics(string, map[string]map[string]string) ([]core.Metric, []error) {
+	return []core.Metric{&metric{namespace: core.NewNamespace("intel", "mock", "foo")}}, nil
+}
+
+func (w *windowPublisher) ProcessMetrics(mts []core.Metric, _ map[string]ctypes.ConfigValue, _ string, _ string, _ int) ([]core.Metric, []error) {
+	return mts, nil
+}
+
+func (w *windowPublisher) PublishMetrics(mts []core.Metric, _ map[string]ctypes.ConfigValue, _ string, _ string, _ int) []error {
+	w.Lock()
+	defer w.Unlock()
+	w.published = append(w.published, len(mts))
+	return nil
+}
+
+func (w *windowPublisher) batches() []int {
+	w.Lock()
+	defer w.Unlock()
+	return append([]int{}, w.published...)
+}
+
+// barrierPublisher publishes only once all of the expected publish jobs are
+// running at the same time, failing them if they are not.
+type barrierPublisher struct {
+	*mockMetricManager
+	sync.Mutex
+	barrier   sync.WaitGroup
+	fail      string
+	published map[string]bool
+}
+
+func newBarrierPublisher(n int) *barrierPublisher {
+	bp := &barrierPublisher{
+		mockMetricManager: &mockMetricManager{},
+		published:         map[string]bool{},
+	}
+	bp.barrier.Add(n)
+	return bp
+}
+
+func (b *barrierPublisher) PublishMetrics(_ []core.Metric, _ map[string]ctypes.ConfigValue, _ string, name string, _ int) []error {
+	b.barrier.Done()
+	done := make(chan struct{})
+	go func() {
+		b.barrier.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		return []error{errors.New("publish jobs did not run concurrently")}
+	}
+	if name == b.fail {
+		return []error{fmt.Errorf("%s failed", name)}
+	}
+	b.Lock()
+	defer b.Unlock()
+	b.published[name] = true
+	return nil
+}
+
+func TestValidateWorkflowMap(t *testing.T) {
+	log.SetLevel(log.FatalLevel)
+	Convey("Validating a workflow map", t, func() {
+		w := wmap.NewWorkflowMap()
+		w.Collect.AddMetric("/intel/mock/foo", 1)
+		w.Collect.AddMetric("/intel/mock/*/baz", 1)
+		w.Collect.AddMetric("/intel/mock/(one;two)/qux", 1)
+		w.Collect.AddConfigItem("/intel/mock", "password", "secret")
+		w.Collect.AddConfigItem("/intel/mock/host1", "user", "root")
+		w.Collect.AddConfigItem("/intel/mock/two", "user", "root")
+		pr := wmap.NewProcessNode("passthru", 1)
+		pr.Add(wmap.NewPublishNode("file", 1))
+		w.Collect.Add(pr)
+		w.Collect.Add(wmap.NewPublishNode("file", 1))
+		Convey("returns no error for a well formed workflow", func() {
+			So(validateWorkflowMap(w), ShouldBeEmpty)
+		})
+		Convey("returns an error for a process node without consumers", func() {
+			pr2 := wmap.NewProcessNode("passthru", 1)
+			pr.Process = nil
+			pr.Publish = nil
+			pr.Add(pr2)
+			w.Collect.Process[0] = *pr
+			errs := validateWorkflowMap(w)
+			So(errs, ShouldHaveLength, 1)
+			So(errs[0].Error(), ShouldEqual, ErrOrphanedProcessNode.Error()+": collect.process[0].process[0]")
+			So(errs[0].Fields()["workflow-node"], ShouldEqual, "collect.process[0].process[0]")
+		})
+		Convey("returns an error for a node without a plugin name", func() {
+			w.Collect.Add(wmap.NewPublishNode("", 1))
+			errs := validateWorkflowMap(w)
+			So(errs, ShouldHaveLength, 1)
+			So(errs[0].Error(), ShouldEqual, ErrUnnamedWorkflowNode.Error()+": collect.publish[1]")
+		})
+		Convey("returns an error for a node with an invalid retry policy", func() {
+			pu := wmap.NewPublishNode("file", 1)
+			pu.Retries = 3
+			pu.RetryBackoff = "soon"
+			w.Collect.Add(pu)
+			errs := validateWorkflowMap(w)
+			So(errs, ShouldHaveLength, 1)
+			So(errs[0].Error(), ShouldEqual, ErrInvalidRetryPolicy.Error()+": collect.publish[1]")
+		})
+		Convey("returns an error for a node with an invalid batch", func() {
+			pu := wmap.NewPublishNode("file", 1)
+			pu.BatchSize = 10
+			pu.BatchMaxAge = "later"
+			w.Collect.Add(pu)
+			errs := validateWorkflowMap(w)
+			So(errs, ShouldHaveLength, 1)
+			So(errs[0].Error(), ShouldEqual, ErrInvalidPublishBatch.Error()+": collect.publish[1]")
+		})
+		Convey("keeps the retry policy of the nodes in the workflow", func() {
+			w.Collect.Publish[0].Retries = 3
+			w.Collect.Publish[0].RetryBackoff = "100ms"
+			w.Collect.Publish[0].RetryJitter = "10ms"
+			wf, err := wmapToWorkflow(w)
+			So(err, ShouldBeNil)
+			So(wf.publishNodes[0].retry, ShouldResemble, &retryPolicy{retries: 3, backoff: 100 * time.Millisecond, jitter: 10 * time.Millisecond})
+			So(wf.processNodes[0].retry, ShouldBeNil)
+		})
+		Convey("returns an error for config not applying to any metric", func() {
+			w.Collect.AddConfigItem("/intel/mock/foo/bar", "user", "root")
+			w.Collect.AddConfigItem("/intel/mock/three/qux", "user", "root")
+			errs := validateWorkflowMap(w)
+			So(errs, ShouldHaveLength, 2)
+			for _, err := range errs {
+				So(err.Error(), ShouldStartWith, ErrConfigForUnknownMetric.Error()+": collect.config[/intel/mock/")
+			}
+		})
+	})
+}
+
+func TestMatchingMetrics(t *testing.T) {
+	Convey("matchingMetrics", t, func() {
+		newMetric := func(tags map[string]string, ns ...string) core.Metric {
+			return plugin.MetricType{Namespace_: core.NewNamespace(ns...), Tags_: tags}
+		}
+		us := newMetric(map[string]string{"region": "us"}, "intel", "mock", "host0", "baz")
+		eu := newMetric(map[string]string{"region": "eu"}, "intel", "mock", "host1", "baz")
+		untagged := newMetric(nil, "intel", "mock", "foo")
+		other := newMetric(nil, "intel", "other", "bar")
+		mts := []core.Metric{us, eu, untagged, other}
+
+		Convey("keeps every metric when nothing is matched", func() {
+			So(matchingMetrics(mts, nil), ShouldResemble, mts)
+		})
+		Convey("keeps the metrics under the namespace carrying the tags", func() {
+			match := map[string]map[string]string{
+				"/intel/mock": {"region": "us"},
+			}
+			So(matchingMetrics(mts, match), ShouldResemble, []core.Metric{us, other})
+		})
+		Convey("requires the tags of every namespace a metric falls under", func() {
+			match := map[string]map[string]string{
+				"/intel":            {"region": "eu"},
+				"/intel/mock/host1": {"region": "us"},
+			}
+			So(matchingMetrics(mts, match), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestDedupMetrics(t *testing.T) {
+	Convey("dedupMetrics", t, func() {
+		at := time.Now()
+		newMetric := func(at time.Time, ns ...string) core.Metric {
+			return plugin.MetricType{Namespace_: core.NewNamespace(ns...), Timestamp_: at}
+		}
+		foo := newMetric(at, "intel", "mock", "foo")
+		bar := newMetric(at, "intel", "mock", "bar")
+		later := newMetric(at.Add(time.Second), "intel", "mock", "foo")
+		untimed := newMetric(time.Time{}, "intel", "mock", "baz")
+
+		Convey("drops the metrics of the same namespace and timestamp", func() {
+			kept, dropped := dedupMetrics([]core.Metric{foo, bar, foo, later, untimed, untimed, bar})
+			So(kept, ShouldResemble, []core.Metric{foo, bar, later, untimed, untimed})
+			So(dropped, ShouldEqual, 2)
+		})
+		Convey("keeps every metric when none is collected twice", func() {
+			kept, dropped := dedupMetrics([]core.Metric{foo, bar, later})
+			So(kept, ShouldResemble, []core.Metric{foo, bar, later})
+			So(dropped, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestDueMetrics(t *testing.T) {
+	Convey("dueMetrics", t, func() {
+		wfMap := wmap.NewWorkflowMap()
+		wfMap.Collect.AddMetricEvery("/intel/cpu/*", 0, 0)
+		wfMap.Collect.AddMetricEvery("/intel/disk/*", 0, 3)
+		wfMap.Collect.AddMetricEvery("/intel/disk/sda/reads", 0, 2)
+		wf, err := wmapToWorkflow(wfMap)
+		So(err, ShouldBeNil)
+		cpu := core.NewNamespace("intel", "cpu", "idle")
+		writes := core.NewNamespace("intel", "disk", "sda", "writes")
+		reads := core.NewNamespace("intel", "disk", "sda", "reads")
+
+		due := make([][]bool, 6)
+		for i := range due {
+			d := wf.dueMetrics()
+			due[i] = []bool{d(cpu), d(writes), d(reads)}
+		}
+		Convey("collects a metric on every firing by default", func() {
+			for i := range due {
+				So(due[i][0], ShouldBeTrue)
+			}
+		})
+		Convey("collects a metric once every few firings", func() {
+			So([]bool{due[0][1], due[1][1], due[2][1], due[3][1]}, ShouldResemble, []bool{true, false, false, true})
+		})
+		Convey("collects a metric when one of its requested metrics is due", func() {
+			So([]bool{due[0][2], due[1][2], due[2][2], due[3][2], due[4][2], due[5][2]}, ShouldResemble,
+				[]bool{true, false, true, true, true, false})
+		})
+	})
+	Convey("dueMetrics of a workflow collecting every metric on every firing", t, func() {
+		wfMap := wmap.NewWorkflowMap()
+		wfMap.Collect.AddMetricEvery("/intel/cpu/*", 0, 1)
+		wf, err := wmapToWorkflow(wfMap)
+		So(err, ShouldBeNil)
+		So(wf.dueMetrics(), ShouldBeNil)
+	})
+	Convey("a workflow with a metric collected every negative number of firings", t, func() {
+		wfMap := wmap.NewWorkflowMap()
+		wfMap.Collect.AddMetricEvery("/intel/cpu/*", 0, -1)
+		errs := validateWorkflowMap(wfMap)
+		So(errs, ShouldHaveLength, 1)
+		So(errs[0].Error(), ShouldContainSubstring, ErrInvalidMetricInterval.Error())
 	})
 }
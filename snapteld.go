@@ -84,6 +84,11 @@ var (
 		Usage:  "A path to a config file",
 		EnvVar: "SNAP_CONFIG_PATH",
 	}
+	flPidFile = cli.StringFlag{
+		Name:   "pid-file",
+		Usage:  "A path to a file to write the pid of snapteld to. Empty path writes no pid file.",
+		EnvVar: "SNAP_PID_FILE",
+	}
 
 	gitversion  string
 	coreModules []coreModule
@@ -115,6 +120,7 @@ const (
 	defaultLogTruncate bool   = false
 	defaultLogColors   bool   = true
 	defaultConfigPath  string = "/etc/snap/snapteld.conf"
+	defaultPidFile     string = ""
 )
 
 // holds the configuration passed in through the SNAP config file
@@ -127,6 +133,7 @@ type Config struct {
 	LogPath     string            `json:"log_path,omitempty"yaml:"log_path,omitempty"`
 	LogTruncate bool              `json:"log_truncate,omitempty"yaml:"log_truncate,omitempty"`
 	LogColors   bool              `json:"log_colors,omitempty"yaml:"log_colors,omitempty"`
+	PidFile     string            `json:"pid_file,omitempty"yaml:"pid_file,omitempty"`
 	Control     *control.Config   `json:"control,omitempty"yaml:"control,omitempty"`
 	Scheduler   *scheduler.Config `json:"scheduler,omitempty"yaml:"scheduler,omitempty"`
 	RestAPI     *rest.Config      `json:"restapi,omitempty"yaml:"restapi,omitempty"`
@@ -162,6 +169,10 @@ const (
 				"type": "integer",
 				"minimum": 1
 			},
+			"pid_file": {
+				"description": "path to the file for snapteld to write its pid to",
+				"type": "string"
+			},
 			"control": { "$ref": "#/definitions/control" },
 			"scheduler": { "$ref": "#/definitions/scheduler"},
 			"restapi" : { "$ref": "#/definitions/restapi"},
@@ -220,6 +231,7 @@ func main() {
 		flLogColors,
 		flMaxProcs,
 		flConfig,
+		flPidFile,
 	}
 	cliApp.Flags = append(cliApp.Flags, control.Flags...)
 	cliApp.Flags = append(cliApp.Flags, scheduler.Flags...)
@@ -401,7 +413,7 @@ func action(ctx *cli.Context) error {
 
 	// Set interrupt handling so we can either restart the app on a SIGHUP or
 	// die gracefully when an interrupt, kill, etc. are received
-	startInterruptHandling(coreModules...)
+	startInterruptHandling(cfg.PidFile, coreModules...)
 
 	// Start our modules
 	var started []coreModule
@@ -503,6 +515,20 @@ func action(ctx *cli.Context) error {
 		}
 	}
 
+	// Write the pid file once every module started, for the service managers
+	// waiting on it to know snapteld is up
+	if cfg.PidFile != "" {
+		if err := writePidFile(cfg.PidFile); err != nil {
+			log.WithFields(
+				log.Fields{
+					"block":    "main",
+					"_module":  logModule,
+					"error":    err.Error(),
+					"pid-file": cfg.PidFile,
+				}).Fatal("unable to write the pid file")
+		}
+	}
+
 	log.WithFields(
 		log.Fields{
 			"block":   "main",
@@ -554,6 +580,7 @@ func getDefaultConfig() *Config {
 		LogPath:     defaultLogPath,
 		LogTruncate: defaultLogTruncate,
 		LogColors:   defaultLogColors,
+		PidFile:     defaultPidFile,
 		Control:     control.GetDefaultConfig(),
 		Scheduler:   scheduler.GetDefaultConfig(),
 		RestAPI:     rest.GetDefaultConfig(),
@@ -843,6 +870,7 @@ func applyCmdLineFlags(cfg *Config, ctx runtimeFlagsContext) {
 	cfg.LogPath = setStringVal(cfg.LogPath, ctx, "log-path")
 	cfg.LogTruncate = setBoolVal(cfg.LogTruncate, ctx, "log-truncate")
 	cfg.LogColors = setBoolVal(cfg.LogColors, ctx, "log-colors")
+	cfg.PidFile = setStringVal(cfg.PidFile, ctx, "pid-file")
 	// next for the flags related to the control package
 	cfg.Control.MaxRunningPlugins = setIntVal(cfg.Control.MaxRunningPlugins, ctx, "max-running-plugins")
 	cfg.Control.PluginLoadTimeout = setIntVal(cfg.Control.PluginLoadTimeout, ctx, "plugin-load-timeout")
@@ -872,6 +900,7 @@ func applyCmdLineFlags(cfg *Config, ctx runtimeFlagsContext) {
 	// next for the scheduler related flags
 	cfg.Scheduler.WorkManagerQueueSize = setUIntVal(cfg.Scheduler.WorkManagerQueueSize, ctx, "work-manager-queue-size")
 	cfg.Scheduler.WorkManagerPoolSize = setUIntVal(cfg.Scheduler.WorkManagerPoolSize, ctx, "work-manager-pool-size")
+	cfg.Scheduler.WorkManagerQueueFullPolicy = setStringVal(cfg.Scheduler.WorkManagerQueueFullPolicy, ctx, "work-manager-queue-full-policy")
 	// and finally for the tribe-related flags
 	cfg.Tribe.Name = setStringVal(cfg.Tribe.Name, ctx, "tribe-node-name")
 	cfg.Tribe.Enable = setBoolVal(cfg.Tribe.Enable, ctx, "tribe")
@@ -1004,6 +1033,10 @@ func (c *Config) UnmarshalJSON(data []byte) error {
 			if err := json.Unmarshal(v, &(c.LogColors)); err != nil {
 				return fmt.Errorf("%v (while parsing 'log_colors')", err)
 			}
+		case "pid_file":
+			if err := json.Unmarshal(v, &(c.PidFile)); err != nil {
+				return fmt.Errorf("%v (while parsing 'pid_file')", err)
+			}
 		case "control":
 			if err := json.Unmarshal(v, c.Control); err != nil {
 				return err
@@ -1050,7 +1083,13 @@ func printErrorAndExit(name string, err error) {
 		}).Fatal("error starting module")
 }
 
-func startInterruptHandling(modules ...coreModule) {
+// writePidFile writes the pid of snapteld to the file at path, replacing the
+// pid written by a previous run
+func writePidFile(path string) error {
+	return ioutil.WriteFile(path, []byte(fmt.Sprintf("%d\n", os.Getpid())), 0644)
+}
+
+func startInterruptHandling(pidFile string, modules ...coreModule) {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, os.Kill, syscall.SIGTERM, syscall.SIGHUP)
 
@@ -1092,6 +1131,9 @@ func startInterruptHandling(modules ...coreModule) {
 					"_module": logModule,
 					"signal":  sig.String(),
 				}).Info("exiting on signal")
+			if pidFile != "" {
+				os.Remove(pidFile)
+			}
 			os.Exit(0)
 		}
 	}()
@@ -38,40 +38,42 @@ import (
 )
 
 var validCmdlineFlags_input = mockFlags{
-	"max-procs":               "11",
-	"log-level":               "1",
-	"log-path":                "/no/logs/allowed",
-	"log-truncate":            "true",
-	"log-colors":              "true",
-	"max-running-plugins":     "12",
-	"plugin-load-timeout":     "20",
-	"plugin-trust":            "1",
-	"auto-discover":           "/no/plugins/here",
-	"keyring-paths":           "/no/keyrings/here",
-	"cache-expiration":        "30ms",
-	"control-listen-addr":     "100.101.102.103",
-	"control-listen-port":     "10400",
-	"pprof":                   "true",
-	"temp_dir_path":           "/no/temp/files",
-	"tls-cert":                "/no/cert/here",
-	"tls-key":                 "/no/key/here",
-	"ca-cert-paths":           "/no/root/certs",
-	"disable-api":             "false",
-	"api-port":                "12400",
-	"api-addr":                "120.121.122.123",
-	"rest-https":              "true",
-	"rest-cert":               "/no/rest/cert",
-	"rest-key":                "/no/rest/key",
-	"rest-auth":               "true",
-	"rest-auth-pwd":           "noway",
-	"allowed_origins":         "140.141.142.143",
-	"work-manager-queue-size": "70",
-	"work-manager-pool-size":  "71",
-	"tribe-node-name":         "bonk",
-	"tribe":                   "true",
-	"tribe-addr":              "160.161.162.163",
-	"tribe-port":              "16400",
-	"tribe-seed":              "180.181.182.183",
+	"max-procs":                      "11",
+	"log-level":                      "1",
+	"log-path":                       "/no/logs/allowed",
+	"log-truncate":                   "true",
+	"log-colors":                     "true",
+	"pid-file":                       "/no/pid/here",
+	"max-running-plugins":            "12",
+	"plugin-load-timeout":            "20",
+	"plugin-trust":                   "1",
+	"auto-discover":                  "/no/plugins/here",
+	"keyring-paths":                  "/no/keyrings/here",
+	"cache-expiration":               "30ms",
+	"control-listen-addr":            "100.101.102.103",
+	"control-listen-port":            "10400",
+	"pprof":                          "true",
+	"temp_dir_path":                  "/no/temp/files",
+	"tls-cert":                       "/no/cert/here",
+	"tls-key":                        "/no/key/here",
+	"ca-cert-paths":                  "/no/root/certs",
+	"disable-api":                    "false",
+	"api-port":                       "12400",
+	"api-addr":                       "120.121.122.123",
+	"rest-https":                     "true",
+	"rest-cert":                      "/no/rest/cert",
+	"rest-key":                       "/no/rest/key",
+	"rest-auth":                      "true",
+	"rest-auth-pwd":                  "noway",
+	"allowed_origins":                "140.141.142.143",
+	"work-manager-queue-size":        "70",
+	"work-manager-pool-size":         "71",
+	"work-manager-queue-full-policy": "block",
+	"tribe-node-name":                "bonk",
+	"tribe":                          "true",
+	"tribe-addr":                     "160.161.162.163",
+	"tribe-port":                     "16400",
+	"tribe-seed":                     "180.181.182.183",
 }
 
 var validCmdlineFlags_expected = &Config{
@@ -110,14 +112,16 @@ var validCmdlineFlags_expected = &Config{
 		Seed:     "180.181.182.183",
 	},
 	Scheduler: &scheduler.Config{
-		WorkManagerQueueSize: 70,
-		WorkManagerPoolSize:  71,
+		WorkManagerQueueSize:       70,
+		WorkManagerPoolSize:        71,
+		WorkManagerQueueFullPolicy: "block",
 	},
 	GoMaxProcs:  11,
 	LogLevel:    1,
 	LogPath:     "/no/logs/allowed",
 	LogTruncate: true,
 	LogColors:   true,
+	PidFile:     "/no/pid/here",
 }
 
 func TestSnapConfig(t *testing.T) {